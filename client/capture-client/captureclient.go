@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/yeti47/cryospy/client/capture-client/client"
 	"github.com/yeti47/cryospy/client/capture-client/config"
@@ -23,9 +24,20 @@ type CaptureClient struct {
 	uploadQueue            uploading.UploadQueue
 	fileTracker            filemanagement.FileTracker
 	clientSettingsProvider config.SettingsProvider[client.ClientSettingsResponse]
+	settingsRefresher      config.SettingsRefresher
+	serverClient           client.CaptureServerClient
 
 	// Configuration
-	cameraDevice string
+	cameraDevice      string
+	heartbeatInterval time.Duration
+	commandWait       time.Duration
+
+	// Status reported with each heartbeat
+	startedAt    time.Time
+	cameraStatus client.CameraStatus
+	lastError    string
+	lastErrorAt  *time.Time
+	statusMu     sync.Mutex
 
 	// State management
 	isRunning    bool
@@ -42,7 +54,11 @@ func NewCaptureClient(
 	uploadQueue uploading.UploadQueue,
 	fileTracker filemanagement.FileTracker,
 	clientSettingsProvider config.SettingsProvider[client.ClientSettingsResponse],
+	settingsRefresher config.SettingsRefresher,
+	serverClient client.CaptureServerClient,
 	cameraDevice string,
+	heartbeatInterval time.Duration,
+	commandWait time.Duration,
 ) *CaptureClient {
 	return &CaptureClient{
 		recorder:               recorder,
@@ -51,7 +67,12 @@ func NewCaptureClient(
 		uploadQueue:            uploadQueue,
 		fileTracker:            fileTracker,
 		clientSettingsProvider: clientSettingsProvider,
+		settingsRefresher:      settingsRefresher,
+		serverClient:           serverClient,
 		cameraDevice:           cameraDevice,
+		heartbeatInterval:      heartbeatInterval,
+		commandWait:            commandWait,
+		cameraStatus:           client.CameraStatusStarting,
 		shutdownChan:           make(chan struct{}),
 	}
 }
@@ -68,6 +89,10 @@ func (c *CaptureClient) Start() error {
 
 	log.Println("Starting capture client...")
 
+	c.statusMu.Lock()
+	c.startedAt = time.Now()
+	c.statusMu.Unlock()
+
 	// Ensure temp directory exists
 	if err := c.fileTracker.EnsureTempDirectory(); err != nil {
 		c.mu.Lock()
@@ -108,6 +133,14 @@ func (c *CaptureClient) Start() error {
 		return fmt.Errorf("recording did not start (recorder busy)")
 	}
 
+	// Report the status of the client to the server until shutdown
+	c.wg.Add(1)
+	go c.sendHeartbeats()
+
+	// Execute the commands sent from the dashboard until shutdown
+	c.wg.Add(1)
+	go c.receiveCommands()
+
 	log.Println("Capture client started successfully")
 	return nil
 }
@@ -152,6 +185,7 @@ func (c *CaptureClient) IsRunning() bool {
 // onRawClipReady is called when a raw video clip has been recorded
 func (c *CaptureClient) onRawClipReady(rawClip *recording.RawClip) error {
 	log.Printf("Raw clip ready: %s", rawClip.Path)
+	c.setCameraStatus(client.CameraStatusOK)
 
 	// Process the clip asynchronously to not block recording
 	c.wg.Add(1)
@@ -166,6 +200,8 @@ func (c *CaptureClient) onRawClipReady(rawClip *recording.RawClip) error {
 // onRecordingError is called when a recording error occurs
 func (c *CaptureClient) onRecordingError(err error) bool {
 	log.Printf("Recording error: %v", err)
+	c.setCameraStatus(client.CameraStatusError)
+	c.recordError(err)
 
 	// Check if we should continue or cancel
 	c.mu.RLock()
@@ -194,6 +230,14 @@ func (c *CaptureClient) processRawClip(rawClip *recording.RawClip) {
 	// Get current client settings
 	clientSettings := c.clientSettingsProvider.GetSettings()
 
+	// Drop clips that were still being recorded when the schedule turned recording off
+	if clientSettings.ActiveMode == client.RecordingModeOff {
+		log.Printf("Recording is off by schedule, discarding %s", rawClip.Path)
+		c.setCameraStatus(client.CameraStatusPaused)
+		c.fileTracker.DeleteFile(rawClip.Path)
+		return
+	}
+
 	// Perform motion detection
 	hasMotion, err := c.motionDetector.DetectMotion(rawClip.Path)
 	if err != nil {
@@ -228,6 +272,7 @@ func (c *CaptureClient) processRawClip(rawClip *recording.RawClip) {
 	processedClip, err := c.postProcessor.ProcessVideo(rawClip)
 	if err != nil {
 		log.Printf("Post-processing failed for %s: %v", rawClip.Path, err)
+		c.recordError(err)
 		c.fileTracker.DeleteFile(rawClip.Path)
 		return
 	}
@@ -239,6 +284,7 @@ func (c *CaptureClient) processRawClip(rawClip *recording.RawClip) {
 		Duration:           processedClip.Duration,
 		RecordingTimestamp: rawClip.Timestamp,
 		Format:             processedClip.Format,
+		SettingsVersion:    clientSettings.SettingsVersion,
 	}
 
 	// Queue for upload
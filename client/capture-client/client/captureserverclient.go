@@ -3,15 +3,24 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// tokenRefreshMargin is how long before its expiry an access token is replaced
+const tokenRefreshMargin = 30 * time.Second
+
 // ClientAuth holds client authentication credentials
 type ClientAuth struct {
 	ClientID     string
@@ -27,59 +36,246 @@ type ProxyAuth struct {
 // CaptureServerClient handles communication with the capture server
 type CaptureServerClient interface {
 	GetClientSettings(ctx context.Context) (*ClientSettingsResponse, error)
+	GetClientSettingsIfChanged(ctx context.Context, etag string, wait time.Duration) (*ClientSettingsResponse, error)
 	UploadClip(ctx context.Context, request UploadClipRequest) error
+	SendHeartbeat(ctx context.Context, request HeartbeatRequest) error
+	WaitForCommands(ctx context.Context, wait time.Duration) ([]Command, error)
+	ReportCommandResult(ctx context.Context, commandID string, request CommandResultRequest) error
 }
 
-// captureServerClient implements ClientService using HTTP
+// captureServerClient implements ClientService using HTTP.
+// It exchanges the client secret for short-lived access tokens and refreshes them as needed.
 type captureServerClient struct {
 	serverURL  string
 	clientAuth ClientAuth
 	proxyAuth  ProxyAuth
 	httpClient *http.Client
+
+	tokenMutex        sync.Mutex
+	accessToken       string
+	tokenExpiresAt    time.Time
+	tokensUnsupported bool // set if the server has no token endpoint, in which case the secret is sent on every request
+}
+
+// tokenStatusError is returned if the server refused to issue an access token
+type tokenStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *tokenStatusError) Error() string {
+	return fmt.Sprintf("server returned status %d when requesting access token: %s", e.StatusCode, e.Body)
+}
+
+// TLSFiles holds the files for TLS connections to the capture server. All fields are optional.
+type TLSFiles struct {
+	ClientCertFile string // Client certificate presented to the server (requires ClientKeyFile)
+	ClientKeyFile  string // Private key of the client certificate
+	CABundleFile   string // CA certificates used to verify the server instead of the system roots
 }
 
-// NewCaptureServerClient creates a new HTTP client service
-func NewCaptureServerClient(serverURL string, clientAuth ClientAuth, proxyAuth ProxyAuth, timeout time.Duration) CaptureServerClient {
+// NewTLSConfig creates the TLS configuration for the given files, or nil if no files are configured
+func NewTLSConfig(files TLSFiles) (*tls.Config, error) {
+	if files.ClientCertFile == "" && files.ClientKeyFile == "" && files.CABundleFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if files.ClientCertFile != "" || files.ClientKeyFile != "" {
+		if files.ClientCertFile == "" || files.ClientKeyFile == "" {
+			return nil, fmt.Errorf("both a client certificate and a client key file are required")
+		}
+		certificate, err := tls.LoadX509KeyPair(files.ClientCertFile, files.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if files.CABundleFile != "" {
+		caBundle, err := os.ReadFile(files.CABundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", files.CABundleFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// NewCaptureServerClient creates a new HTTP client service. If tlsConfig is nil, the default TLS configuration is used.
+func NewCaptureServerClient(serverURL string, clientAuth ClientAuth, proxyAuth ProxyAuth, timeout time.Duration, tlsConfig *tls.Config) CaptureServerClient {
+	httpClient := &http.Client{
+		Timeout: timeout,
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient.Transport = transport
+	}
+
 	return &captureServerClient{
 		serverURL:  serverURL,
 		clientAuth: clientAuth,
 		proxyAuth:  proxyAuth,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		httpClient: httpClient,
 	}
 }
 
-// addAuthHeaders adds both basic auth and proxy auth headers to the request
-func (s *captureServerClient) addAuthHeaders(req *http.Request) {
+// addBasicAuthHeaders adds both basic auth and proxy auth headers to the request
+func (s *captureServerClient) addBasicAuthHeaders(req *http.Request) {
 	// Add Basic Auth header
 	auth := base64.StdEncoding.EncodeToString([]byte(s.clientAuth.ClientID + ":" + s.clientAuth.ClientSecret))
 	req.Header.Set("Authorization", "Basic "+auth)
 
-	// Add proxy auth header if configured
+	s.addProxyAuthHeader(req)
+}
+
+// addProxyAuthHeader adds the proxy auth header if configured
+func (s *captureServerClient) addProxyAuthHeader(req *http.Request) {
 	if s.proxyAuth.Header != "" && s.proxyAuth.Value != "" {
 		req.Header.Set(s.proxyAuth.Header, s.proxyAuth.Value)
 	}
 }
 
+// getAccessToken returns a valid access token, requesting a new one if the current one is about to expire.
+// An empty token means that the server does not support tokens.
+func (s *captureServerClient) getAccessToken(ctx context.Context) (string, error) {
+	s.tokenMutex.Lock()
+	defer s.tokenMutex.Unlock()
+
+	if s.tokensUnsupported {
+		return "", nil
+	}
+	if s.accessToken != "" && time.Now().Add(tokenRefreshMargin).Before(s.tokenExpiresAt) {
+		return s.accessToken, nil
+	}
+
+	url := fmt.Sprintf("%s/api/token", s.serverURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	s.addBasicAuthHeaders(req)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Servers without a token endpoint accept the secret on every request
+	if resp.StatusCode == http.StatusNotFound {
+		s.tokensUnsupported = true
+		return "", nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &tokenStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var tokenResponse TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	s.accessToken = tokenResponse.AccessToken
+	s.tokenExpiresAt = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	return s.accessToken, nil
+}
+
+// invalidateToken discards the access token if it is still the current one
+func (s *captureServerClient) invalidateToken(token string) {
+	s.tokenMutex.Lock()
+	defer s.tokenMutex.Unlock()
+
+	if s.accessToken == token {
+		s.accessToken = ""
+	}
+}
+
+// do sends an authenticated request. If the server rejects the access token, for example because
+// it was restarted, a new token is requested and the request is sent once more.
+func (s *captureServerClient) do(ctx context.Context, method, url string, body []byte, contentType string) (*http.Response, error) {
+	return s.doWithHeaders(ctx, method, url, body, contentType, nil)
+}
+
+// doWithHeaders sends an authenticated request like do, with additional request headers
+func (s *captureServerClient) doWithHeaders(ctx context.Context, method, url string, body []byte, contentType string, headers map[string]string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		token, err := s.getAccessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			s.addBasicAuthHeaders(req)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+			s.addProxyAuthHeader(req)
+		}
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && token != "" && attempt == 0 {
+			resp.Body.Close()
+			s.invalidateToken(token)
+			continue
+		}
+
+		return resp, nil
+	}
+}
+
 // GetClientSettings fetches client settings from the server
 func (s *captureServerClient) GetClientSettings(ctx context.Context) (*ClientSettingsResponse, error) {
-	url := fmt.Sprintf("%s/api/client/settings", s.serverURL)
+	return s.GetClientSettingsIfChanged(ctx, "", 0)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// GetClientSettingsIfChanged fetches client settings from the server unless they still match the ETag,
+// in which case it returns nil. If wait is positive, the server holds the request open until the
+// settings change or the wait time is over.
+func (s *captureServerClient) GetClientSettingsIfChanged(ctx context.Context, etag string, wait time.Duration) (*ClientSettingsResponse, error) {
+	url := fmt.Sprintf("%s/api/client/settings", s.serverURL)
+	if wait > 0 {
+		url = fmt.Sprintf("%s?wait=%d", url, int(wait.Seconds()))
 	}
 
-	// Add authentication headers
-	s.addAuthHeaders(req)
+	var headers map[string]string
+	if etag != "" {
+		headers = map[string]string{"If-None-Match": etag}
+	}
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.doWithHeaders(ctx, "GET", url, nil, "", headers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
 	}
@@ -88,6 +284,7 @@ func (s *captureServerClient) GetClientSettings(ctx context.Context) (*ClientSet
 	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	settings.ETag = resp.Header.Get("ETag")
 
 	return &settings, nil
 }
@@ -120,6 +317,13 @@ func (s *captureServerClient) UploadClip(ctx context.Context, request UploadClip
 		return NewNonRecoverableUploadError(fmt.Errorf("failed to write has_motion field: %w", err))
 	}
 
+	// Add settings_version field, so the server knows which settings produced the clip
+	if request.SettingsVersion > 0 {
+		if err := writer.WriteField("settings_version", strconv.Itoa(request.SettingsVersion)); err != nil {
+			return NewNonRecoverableUploadError(fmt.Errorf("failed to write settings_version field: %w", err))
+		}
+	}
+
 	// Add file field
 	part, err := writer.CreateFormFile("video", "clip.mp4")
 	if err != nil {
@@ -134,32 +338,101 @@ func (s *captureServerClient) UploadClip(ctx context.Context, request UploadClip
 		return NewNonRecoverableUploadError(fmt.Errorf("failed to close writer: %w", err))
 	}
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "POST", url, &buf)
-	if err != nil {
-		return NewNonRecoverableUploadError(fmt.Errorf("failed to create request: %w", err))
-	}
-
-	// Add headers
-	s.addAuthHeaders(req)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
 	// Make request
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(ctx, "POST", url, buf.Bytes(), writer.FormDataContentType())
 	if err != nil {
+		var statusErr *tokenStatusError
+		if errors.As(err, &statusErr) {
+			return NewUploadServerError(isRecoverableStatus(statusErr.StatusCode), err)
+		}
 		// this is a recoverable error, because it could be a temporary network issue
-		return NewRecoverableUploadError(fmt.Errorf("failed to make request: %w", err))
+		return NewRecoverableUploadError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
+		return NewUploadServerError(isRecoverableStatus(resp.StatusCode), fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	return nil
+}
+
+// SendHeartbeat reports the status of the client to the server
+func (s *captureServerClient) SendHeartbeat(ctx context.Context, request HeartbeatRequest) error {
+	url := fmt.Sprintf("%s/api/client/heartbeat", s.serverURL)
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+
+	resp, err := s.do(ctx, "POST", url, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// WaitForCommands fetches the pending commands from the server. If there are none, the server holds
+// the request open for up to wait and returns as soon as a command arrives.
+func (s *captureServerClient) WaitForCommands(ctx context.Context, wait time.Duration) ([]Command, error) {
+	url := fmt.Sprintf("%s/api/client/commands?wait=%d", s.serverURL, int(wait.Seconds()))
+
+	resp, err := s.do(ctx, "GET", url, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var response CommandsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.Commands, nil
+}
+
+// ReportCommandResult reports the result of a command to the server
+func (s *captureServerClient) ReportCommandResult(ctx context.Context, commandID string, request CommandResultRequest) error {
+	url := fmt.Sprintf("%s/api/client/commands/%s/result", s.serverURL, commandID)
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command result: %w", err)
+	}
 
-		// client-side errors are not recoverable (the problem won't go away by retrying)
-		isRecoverable := !(resp.StatusCode >= 400 && resp.StatusCode < 500)
+	resp, err := s.do(ctx, "POST", url, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-		return NewUploadServerError(isRecoverable, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body)))
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(respBody))
 	}
 
 	return nil
 }
+
+// isRecoverableStatus reports whether a failed request may succeed when retried later.
+// Client-side errors are not recoverable (the problem won't go away by retrying), except for
+// rate limiting, such as a temporary block of the client's IP address.
+func isRecoverableStatus(statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return !(statusCode >= 400 && statusCode < 500)
+}
@@ -24,6 +24,96 @@ type ClientSettingsResponse struct {
 	MotionMogVarThresh    float64 `json:"motion_mog_var_thresh"`
 	CaptureCodec          string  `json:"capture_codec"`
 	CaptureFrameRate      float64 `json:"capture_frame_rate"`
+
+	Timezone string          `json:"timezone"` // Timezone the schedule is evaluated in, empty for the local time of the device
+	Schedule []ScheduleEntry `json:"schedule"` // Weekly time ranges with their own recording mode
+
+	ArmMode          string        `json:"arm_mode"`                     // Active system-wide arm mode ("home" or "away")
+	ArmRecordingMode RecordingMode `json:"arm_recording_mode,omitempty"` // Recording mode forced by the arm mode, empty if the schedule applies
+
+	SettingsVersion int `json:"settings_version"` // Version of the settings on the server, 0 if the server does not keep a settings history
+
+	// ETag identifies this version of the settings, as reported by the server. It is empty if the server
+	// does not support conditional requests.
+	ETag string `json:"-"`
+
+	// ActiveMode is the recording mode in effect, set by WithSchedule. It is empty for settings
+	// as received from the server, which have not been evaluated against the schedule yet.
+	ActiveMode RecordingMode `json:"-"`
+}
+
+// TokenResponse represents the access token response from the server
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"` // Lifetime of the token in seconds
+}
+
+// EnrollRequest represents the request to redeem a pairing code
+type EnrollRequest struct {
+	Code string `json:"code"`
+}
+
+// EnrollResponse represents the credentials handed out for a pairing code
+type EnrollResponse struct {
+	ClientID          string `json:"client_id"`
+	ClientSecret      string `json:"client_secret"`
+	ClientCertificate string `json:"client_certificate,omitempty"` // PEM-encoded client certificate, if the server requires one
+	ClientKey         string `json:"client_key,omitempty"`         // PEM-encoded private key of the client certificate
+	CABundle          string `json:"ca_bundle,omitempty"`          // PEM-encoded CA certificate of the server
+}
+
+// CameraStatus is the state of the camera as reported with each heartbeat
+type CameraStatus string
+
+const (
+	CameraStatusStarting CameraStatus = "starting" // No clip has been recorded since the client started
+	CameraStatusOK       CameraStatus = "ok"       // Clips are being recorded
+	CameraStatusPaused   CameraStatus = "paused"   // Recording is off, e.g. by schedule or arm mode
+	CameraStatusError    CameraStatus = "error"    // Recording failed since the last clip
+)
+
+// HeartbeatRequest represents the status report sent to the server with each heartbeat
+type HeartbeatRequest struct {
+	Version       string       `json:"version"`
+	UptimeSeconds int64        `json:"uptime_seconds"`
+	CameraStatus  CameraStatus `json:"camera_status"`
+	QueueDepth    int          `json:"queue_depth"`   // Clips waiting in the upload queue
+	RetryBacklog  int          `json:"retry_backlog"` // Clips waiting for an upload retry
+	TempDirBytes  int64        `json:"temp_dir_bytes"`
+	LastError     string       `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time   `json:"last_error_at,omitempty"`
+}
+
+// CommandType identifies what the client is asked to do by the server
+type CommandType string
+
+const (
+	CommandTypeRestartRecording CommandType = "restart_recording"
+	CommandTypeSnapshot         CommandType = "snapshot"
+	CommandTypeFlushRetryQueue  CommandType = "flush_retry_queue"
+	CommandTypeDiagnostics      CommandType = "diagnostics"
+	CommandTypeReloadSettings   CommandType = "reload_settings"
+)
+
+// Command represents a command sent from the dashboard
+type Command struct {
+	ID       string      `json:"id"`
+	Type     CommandType `json:"type"`
+	IssuedAt time.Time   `json:"issued_at"`
+}
+
+// CommandsResponse represents the pending commands returned by the server
+type CommandsResponse struct {
+	Commands []Command `json:"commands"`
+}
+
+// CommandResultRequest represents the result of a command reported to the server
+type CommandResultRequest struct {
+	Success            bool   `json:"success"`
+	Message            string `json:"message"`
+	Attachment         []byte `json:"attachment,omitempty"` // Optional data, e.g. a snapshot image
+	AttachmentMimeType string `json:"attachment_mime_type,omitempty"`
 }
 
 type UploadClipRequest struct {
@@ -32,4 +122,5 @@ type UploadClipRequest struct {
 	Duration           time.Duration
 	HasMotion          bool
 	RecordingTimestamp time.Time
+	SettingsVersion    int // Version of the client settings the clip was processed with, 0 if unknown
 }
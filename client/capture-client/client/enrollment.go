@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PairingCode is a pairing code from the dashboard, optionally with the details of the cryospy://enroll URI in its QR code
+type PairingCode struct {
+	Code          string
+	ServerURL     string // Capture server URL, empty if not known
+	CAFingerprint string // SHA-256 fingerprint of the CA that issued the server certificate, empty if not known
+}
+
+// ParsePairingCode accepts either a plain pairing code or the cryospy://enroll URI of its QR code
+func ParsePairingCode(input string) (*PairingCode, error) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "cryospy://") {
+		if input == "" {
+			return nil, fmt.Errorf("pairing code is empty")
+		}
+		return &PairingCode{Code: input}, nil
+	}
+
+	uri, err := url.Parse(input)
+	if err != nil || uri.Host != "enroll" {
+		return nil, fmt.Errorf("invalid pairing URI")
+	}
+	query := uri.Query()
+	if query.Get("code") == "" {
+		return nil, fmt.Errorf("pairing URI does not contain a code")
+	}
+
+	return &PairingCode{
+		Code:          query.Get("code"),
+		ServerURL:     query.Get("server"),
+		CAFingerprint: query.Get("ca"),
+	}, nil
+}
+
+// NewPinnedCATLSConfig creates a TLS configuration that only trusts server certificates for the given host
+// that were issued by the CA with the given fingerprint. The server is expected to send the CA along.
+// This lets a new device verify the server before it has received the CA bundle.
+func NewPinnedCATLSConfig(caFingerprint, host string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// The default verification is replaced by the pinned one below
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("server did not present a certificate")
+			}
+			for _, certificate := range state.PeerCertificates[1:] {
+				fingerprint := sha256.Sum256(certificate.Raw)
+				if !strings.EqualFold(hex.EncodeToString(fingerprint[:]), caFingerprint) {
+					continue
+				}
+				roots := x509.NewCertPool()
+				roots.AddCert(certificate)
+				_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{Roots: roots, DNSName: host})
+				return err
+			}
+			return fmt.Errorf("server certificate was not issued by the expected CA")
+		},
+	}
+}
+
+// Enroll redeems a pairing code at the capture server and returns the credentials of the client.
+// If tlsConfig is nil, the default TLS configuration is used.
+func Enroll(ctx context.Context, serverURL, code string, proxyAuth ProxyAuth, tlsConfig *tls.Config, timeout time.Duration) (*EnrollResponse, error) {
+	httpClient := &http.Client{
+		Timeout: timeout,
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient.Transport = transport
+	}
+
+	body, err := json.Marshal(EnrollRequest{Code: code})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/enroll", serverURL), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if proxyAuth.Header != "" && proxyAuth.Value != "" {
+		req.Header.Set(proxyAuth.Header, proxyAuth.Value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("the pairing code is invalid, expired or was already used")
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("too many failed attempts from this address, try again after %s seconds", resp.Header.Get("Retry-After"))
+	default:
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var enrollResponse EnrollResponse
+	if err := json.NewDecoder(resp.Body).Decode(&enrollResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &enrollResponse, nil
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"slices"
+	"time"
+	_ "time/tzdata" // Camera devices often lack a zoneinfo database
+)
+
+// RecordingMode determines what the client records and uploads
+type RecordingMode string
+
+const (
+	RecordingModeOff        RecordingMode = "off"         // Nothing is recorded
+	RecordingModeMotionOnly RecordingMode = "motion_only" // Only clips with motion are uploaded
+	RecordingModeContinuous RecordingMode = "continuous"  // Every clip is uploaded
+)
+
+// MotionSensitivity adjusts the motion detection thresholds while a schedule entry is active
+type MotionSensitivity string
+
+const (
+	MotionSensitivityDefault MotionSensitivity = ""
+	MotionSensitivityLow     MotionSensitivity = "low"
+	MotionSensitivityHigh    MotionSensitivity = "high"
+)
+
+// ScheduleEntry maps a weekly time range to a recording mode.
+// The range starts at Start on each of the given days and ends at End, on the next day if End is not after Start.
+type ScheduleEntry struct {
+	Days        []time.Weekday    `json:"days"`
+	Start       string            `json:"start"` // "HH:MM"
+	End         string            `json:"end"`   // "HH:MM"
+	Mode        RecordingMode     `json:"mode"`
+	Sensitivity MotionSensitivity `json:"sensitivity,omitempty"`
+}
+
+// covers reports whether the range of the entry contains the given time (in the schedule's timezone)
+func (e ScheduleEntry) covers(now time.Time) bool {
+	start, errStart := time.Parse("15:04", e.Start)
+	end, errEnd := time.Parse("15:04", e.End)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	minute := now.Hour()*60 + now.Minute()
+
+	if endMinute > startMinute {
+		return slices.Contains(e.Days, now.Weekday()) && minute >= startMinute && minute < endMinute
+	}
+
+	// The range runs past midnight: it covers the evening of a start day and the morning after it
+	if slices.Contains(e.Days, now.Weekday()) && minute >= startMinute {
+		return true
+	}
+	previousDay := (now.Weekday() + 6) % 7
+	return slices.Contains(e.Days, previousDay) && minute < endMinute
+}
+
+// ActiveScheduleEntry returns the first schedule entry whose range contains the given time, or nil if there is none.
+// The schedule is evaluated in the configured timezone, falling back to the local time of the device.
+func (s ClientSettingsResponse) ActiveScheduleEntry(now time.Time) *ScheduleEntry {
+	if s.Timezone != "" {
+		if location, err := time.LoadLocation(s.Timezone); err == nil {
+			now = now.In(location)
+		}
+	}
+
+	for i := range s.Schedule {
+		if s.Schedule[i].covers(now) {
+			return &s.Schedule[i]
+		}
+	}
+	return nil
+}
+
+// WithSchedule returns the settings that apply at the given time: the recording mode and motion sensitivity
+// of the active schedule entry replace the regular settings, and a recording mode forced by the arm mode
+// takes precedence over both. ActiveMode is always set on the result.
+func (s ClientSettingsResponse) WithSchedule(now time.Time) ClientSettingsResponse {
+	s.ActiveMode = RecordingModeContinuous
+	if s.MotionOnly {
+		s.ActiveMode = RecordingModeMotionOnly
+	}
+
+	if entry := s.ActiveScheduleEntry(now); entry != nil {
+		s.ActiveMode = entry.Mode
+
+		switch entry.Sensitivity {
+		case MotionSensitivityLow:
+			s.MotionMinArea *= 2
+			s.MotionMogVarThresh *= 1.5
+		case MotionSensitivityHigh:
+			s.MotionMinArea /= 2
+			s.MotionMogVarThresh *= 0.75
+		}
+	}
+
+	if s.ArmRecordingMode != "" {
+		s.ActiveMode = s.ArmRecordingMode
+	}
+
+	s.MotionOnly = s.ActiveMode == RecordingModeMotionOnly
+	return s
+}
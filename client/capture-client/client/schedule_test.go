@@ -0,0 +1,85 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithSchedule(t *testing.T) {
+	settings := ClientSettingsResponse{
+		MotionOnly:         true,
+		MotionMinArea:      1000,
+		MotionMogVarThresh: 16,
+		Timezone:           "Europe/Berlin",
+		Schedule: []ScheduleEntry{
+			// Record everything overnight from Friday to Saturday
+			{Days: []time.Weekday{time.Friday}, Start: "22:00", End: "06:00", Mode: RecordingModeContinuous, Sensitivity: MotionSensitivityHigh},
+			// No recording on Sunday evenings
+			{Days: []time.Weekday{time.Sunday}, Start: "18:00", End: "23:00", Mode: RecordingModeOff},
+		},
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		mode       RecordingMode
+		motionOnly bool
+		minArea    int
+	}{
+		{"outside of the schedule", time.Date(2025, 6, 4, 12, 0, 0, 0, berlin), RecordingModeMotionOnly, true, 1000},
+		{"evening of the start day", time.Date(2025, 6, 6, 23, 0, 0, 0, berlin), RecordingModeContinuous, false, 500},
+		{"morning after the start day", time.Date(2025, 6, 7, 5, 59, 0, 0, berlin), RecordingModeContinuous, false, 500},
+		{"end of an overnight range", time.Date(2025, 6, 7, 6, 0, 0, 0, berlin), RecordingModeMotionOnly, true, 1000},
+		{"morning before the start day", time.Date(2025, 6, 6, 5, 0, 0, 0, berlin), RecordingModeMotionOnly, true, 1000},
+		{"recording off", time.Date(2025, 6, 8, 20, 0, 0, 0, berlin), RecordingModeOff, false, 1000},
+		// 17:30 UTC is 19:30 in Berlin during summer time
+		{"evaluated in the client's timezone", time.Date(2025, 6, 8, 17, 30, 0, 0, time.UTC), RecordingModeOff, false, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := settings.WithSchedule(tt.now)
+			if applied.ActiveMode != tt.mode {
+				t.Errorf("Expected mode %s, got %s", tt.mode, applied.ActiveMode)
+			}
+			if applied.MotionOnly != tt.motionOnly {
+				t.Errorf("Expected motion only %v, got %v", tt.motionOnly, applied.MotionOnly)
+			}
+			if applied.MotionMinArea != tt.minArea {
+				t.Errorf("Expected motion min area %d, got %d", tt.minArea, applied.MotionMinArea)
+			}
+		})
+	}
+
+	if settings.MotionMinArea != 1000 || len(settings.Schedule) != 2 {
+		t.Error("WithSchedule must not modify the original settings")
+	}
+}
+
+func TestWithSchedule_ArmRecordingMode(t *testing.T) {
+	settings := ClientSettingsResponse{
+		MotionOnly:       true,
+		MotionMinArea:    1000,
+		Schedule:         []ScheduleEntry{{Days: []time.Weekday{time.Wednesday}, Start: "00:00", End: "00:00", Mode: RecordingModeContinuous, Sensitivity: MotionSensitivityHigh}},
+		ArmMode:          "home",
+		ArmRecordingMode: RecordingModeOff,
+	}
+
+	applied := settings.WithSchedule(time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC))
+	if applied.ActiveMode != RecordingModeOff || applied.MotionOnly {
+		t.Errorf("Expected the arm mode to turn recording off, got mode %s", applied.ActiveMode)
+	}
+	if applied.MotionMinArea != 500 {
+		t.Errorf("Expected the schedule's sensitivity to remain in effect, got motion min area %d", applied.MotionMinArea)
+	}
+
+	settings.ArmRecordingMode = ""
+	if applied := settings.WithSchedule(time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)); applied.ActiveMode != RecordingModeContinuous {
+		t.Errorf("Expected the schedule to apply without an arm recording mode, got %s", applied.ActiveMode)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/yeti47/cryospy/client/capture-client/client"
+)
+
+const (
+	// commandRetryDelay is how long to wait before asking for commands again after a failed request
+	commandRetryDelay = 30 * time.Second
+	// commandTimeout limits how long a single command may take
+	commandTimeout = time.Minute
+)
+
+// receiveCommands waits for commands from the server and executes them until shutdown
+func (c *CaptureClient) receiveCommands() {
+	defer c.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// Abort a pending request on shutdown
+		<-c.shutdownChan
+		cancel()
+	}()
+
+	for {
+		commands, err := c.serverClient.WaitForCommands(ctx, c.commandWait)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to receive commands: %v", err)
+			select {
+			case <-time.After(commandRetryDelay):
+			case <-c.shutdownChan:
+				return
+			}
+			continue
+		}
+
+		for _, command := range commands {
+			c.executeCommand(ctx, command)
+		}
+
+		select {
+		case <-c.shutdownChan:
+			return
+		default:
+		}
+	}
+}
+
+// executeCommand executes a command and reports its result to the server
+func (c *CaptureClient) executeCommand(ctx context.Context, command client.Command) {
+	log.Printf("Executing command %s (%s)", command.ID, command.Type)
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	var result client.CommandResultRequest
+	switch command.Type {
+	case client.CommandTypeRestartRecording:
+		result = commandResult("Recording restarted", c.recorder.RestartRecording())
+	case client.CommandTypeSnapshot:
+		image, err := c.recorder.Snapshot(ctx)
+		result = commandResult(fmt.Sprintf("Snapshot taken (%d bytes)", len(image)), err)
+		if err == nil {
+			result.Attachment = image
+			result.AttachmentMimeType = "image/jpeg"
+		}
+	case client.CommandTypeFlushRetryQueue:
+		flushed := c.uploadQueue.FlushRetries()
+		result = commandResult(fmt.Sprintf("Retrying %d failed uploads", flushed), nil)
+	case client.CommandTypeDiagnostics:
+		result = commandResult(c.diagnostics(ctx), nil)
+	case client.CommandTypeReloadSettings:
+		result = commandResult("Settings reloaded", c.settingsRefresher.Refresh(ctx))
+	default:
+		result = commandResult("", fmt.Errorf("unsupported command type: %s", command.Type))
+	}
+
+	if !result.Success {
+		log.Printf("Command %s (%s) failed: %s", command.ID, command.Type, result.Message)
+	}
+
+	if err := c.serverClient.ReportCommandResult(ctx, command.ID, result); err != nil {
+		log.Printf("Failed to report result of command %s: %v", command.ID, err)
+	}
+}
+
+// commandResult builds the result of a command from its success message or error
+func commandResult(message string, err error) client.CommandResultRequest {
+	if err != nil {
+		return client.CommandResultRequest{Success: false, Message: err.Error()}
+	}
+	return client.CommandResultRequest{Success: true, Message: message}
+}
+
+// diagnostics describes the state of the camera and the client
+func (c *CaptureClient) diagnostics(ctx context.Context) string {
+	status := c.heartbeatRequest()
+	settings := c.clientSettingsProvider.GetSettings()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Version: %s\n", status.Version)
+	fmt.Fprintf(&b, "Uptime: %v\n", time.Duration(status.UptimeSeconds)*time.Second)
+	fmt.Fprintf(&b, "Camera device: %s\n", c.cameraDevice)
+	fmt.Fprintf(&b, "Camera status: %s\n", status.CameraStatus)
+	fmt.Fprintf(&b, "Recording: %v\n", c.recorder.IsRecording())
+	fmt.Fprintf(&b, "Recording mode: %s (arm mode %s)\n", settings.ActiveMode, settings.ArmMode)
+
+	// Check that the camera actually delivers frames
+	frameCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if image, err := c.recorder.Snapshot(frameCtx); err != nil {
+		fmt.Fprintf(&b, "Frame test: failed (%v)\n", err)
+	} else {
+		fmt.Fprintf(&b, "Frame test: ok (%d bytes)\n", len(image))
+	}
+
+	fmt.Fprintf(&b, "Upload queue: %d\n", status.QueueDepth)
+	fmt.Fprintf(&b, "Retry backlog: %d\n", status.RetryBacklog)
+	fmt.Fprintf(&b, "Temp directory: %d bytes\n", status.TempDirBytes)
+	if status.LastError != "" {
+		fmt.Fprintf(&b, "Last error: %s (%s)\n", status.LastError, status.LastErrorAt.Format(time.RFC3339))
+	}
+
+	return b.String()
+}
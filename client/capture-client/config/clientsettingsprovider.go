@@ -13,6 +13,11 @@ import (
 const (
 	// DefaultSettingsCacheTimeout is the default cache timeout period
 	DefaultSettingsCacheTimeout = 5 * time.Minute
+
+	// watchRetryDelay is how long Watch waits after a failed request
+	watchRetryDelay = 30 * time.Second
+	// watchMinInterval keeps Watch from flooding the server if requests return right away
+	watchMinInterval = time.Second
 )
 
 type ClientSettingsProvider struct {
@@ -72,6 +77,17 @@ func (p *ClientSettingsProvider) GetSettings() client.ClientSettingsResponse {
 	return currentSettings
 }
 
+// Refresh fetches the settings from the server right away, implementing SettingsRefresher interface
+func (p *ClientSettingsProvider) Refresh(ctx context.Context) error {
+	settings, err := p.client.GetClientSettings(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch client settings: %w", err)
+	}
+
+	p.update(settings)
+	return nil
+}
+
 // fetchSettingsAsync fetches settings in the background without blocking
 func (p *ClientSettingsProvider) fetchSettingsAsync() {
 	p.mutex.Lock()
@@ -96,16 +112,69 @@ func (p *ClientSettingsProvider) fetchSettingsAsync() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	settings, err := p.client.GetClientSettings(ctx)
+	settings, err := p.client.GetClientSettingsIfChanged(ctx, p.etag(), 0)
 	if err != nil {
 		// Log error but don't update cache - keep using stale settings
 		log.Printf("Failed to fetch client settings from server: %v", err)
 		return
 	}
 
-	// Update cache with fresh settings
+	// Update cache with fresh settings, or just the fetch time if they are unchanged
+	p.update(settings)
+}
+
+// Watch keeps a request open that returns as soon as the settings change on the server, so that changes
+// apply within seconds instead of after the cache timeout. It runs until the context is cancelled.
+// If the server does not support waiting for changes, Watch returns and the cache timeout applies.
+func (p *ClientSettingsProvider) Watch(ctx context.Context, wait time.Duration) {
+	for {
+		etag := p.etag()
+		if etag == "" {
+			log.Println("Server does not report settings versions, settings are synced periodically")
+			return
+		}
+
+		start := time.Now()
+		settings, err := p.client.GetClientSettingsIfChanged(ctx, etag, wait)
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := watchMinInterval - time.Since(start)
+		if err != nil {
+			log.Printf("Failed to wait for client settings changes: %v", err)
+			delay = watchRetryDelay
+		} else {
+			if settings != nil {
+				log.Println("Client settings changed on the server")
+			}
+			p.update(settings)
+		}
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// etag returns the ETag of the cached settings
+func (p *ClientSettingsProvider) etag() string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.cachedSettings.ETag
+}
+
+// update stores fetched settings in the cache. Nil settings mean that the cached settings are still current.
+func (p *ClientSettingsProvider) update(settings *client.ClientSettingsResponse) {
 	p.mutex.Lock()
-	p.cachedSettings = settings
+	defer p.mutex.Unlock()
+
+	if settings != nil {
+		p.cachedSettings = settings
+	}
 	p.lastFetchTime = time.Now()
-	p.mutex.Unlock()
 }
@@ -16,10 +16,14 @@ type Config struct {
 	RetryBufferSize      int    `json:"retry_buffer_size"`      // Number of failed clips to buffer for retry
 	SettingsSyncSeconds  int    `json:"settings_sync_seconds"`  // How often to sync settings from server (in seconds)
 	ServerTimeoutSeconds int    `json:"server_timeout_seconds"` // HTTP timeout for server requests (in seconds)
+	HeartbeatSeconds     int    `json:"heartbeat_seconds"`      // How often to report the client status to the server (in seconds)
 	ProxyAuthHeader      string `json:"proxy_auth_header"`      // Optional header name for proxy authentication (e.g., "X-Proxy-Auth")
 	ProxyAuthValue       string `json:"proxy_auth_value"`       // Optional header value for proxy authentication
 	UploadRetryMinutes   int    `json:"upload_retry_minutes"`   // Minutes to wait before retrying failed uploads
 	UploadMaxRetries     *int   `json:"upload_max_retries"`     // Maximum number of retry attempts before giving up (nil = use default, 0 = disable retries)
+	TLSClientCertFile    string `json:"tls_client_cert_file"`   // Optional client certificate for mutual TLS, issued by the dashboard
+	TLSClientKeyFile     string `json:"tls_client_key_file"`    // Optional private key of the client certificate
+	TLSCABundleFile      string `json:"tls_ca_bundle_file"`     // Optional CA bundle used to verify the server certificate (e.g. the CryoSpy CA)
 }
 
 // LoadConfig loads configuration from a JSON file
@@ -38,6 +42,7 @@ func LoadConfig(filename string) (*Config, error) {
 				RetryBufferSize:      100,                // Larger buffer for failed uploads during outages
 				SettingsSyncSeconds:  300,                // 5 minutes default
 				ServerTimeoutSeconds: 30,                 // 30 seconds default
+				HeartbeatSeconds:     60,                 // 1 minute default
 				ProxyAuthHeader:      "",                 // Optional proxy auth header name
 				ProxyAuthValue:       "",                 // Optional proxy auth header value
 				UploadRetryMinutes:   5,                  // Retry failed uploads after 5 minutes
@@ -73,6 +78,9 @@ func LoadConfig(filename string) (*Config, error) {
 	if config.ServerTimeoutSeconds == 0 {
 		config.ServerTimeoutSeconds = 30 // 30 seconds default
 	}
+	if config.HeartbeatSeconds == 0 {
+		config.HeartbeatSeconds = 60 // 1 minute default
+	}
 	if config.UploadRetryMinutes == 0 {
 		config.UploadRetryMinutes = 5 // 5 minutes default
 	}
@@ -132,6 +140,11 @@ func (c *Config) Override(overrides ConfigOverrides) {
 	}
 }
 
+// Save writes the configuration to a JSON file
+func (c *Config) Save(filename string) error {
+	return saveConfig(filename, c)
+}
+
 // saveConfig saves a configuration to a JSON file
 func saveConfig(filename string, config *Config) error {
 	data, err := json.MarshalIndent(config, "", "  ")
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yeti47/cryospy/client/capture-client/client"
+)
+
+// ScheduledSettingsProvider applies the recording schedule to the settings of another provider,
+// so that the recording mode and motion sensitivity of the active schedule entry take effect
+type ScheduledSettingsProvider struct {
+	provider   SettingsProvider[client.ClientSettingsResponse]
+	now        func() time.Time
+	mutex      sync.Mutex
+	activeMode client.RecordingMode
+}
+
+// NewScheduledSettingsProvider creates a new ScheduledSettingsProvider wrapping the given provider
+func NewScheduledSettingsProvider(provider SettingsProvider[client.ClientSettingsResponse]) *ScheduledSettingsProvider {
+	return &ScheduledSettingsProvider{
+		provider: provider,
+		now:      time.Now,
+	}
+}
+
+// GetSettings returns the settings of the wrapped provider with the schedule applied, implementing SettingsProvider interface
+func (p *ScheduledSettingsProvider) GetSettings() client.ClientSettingsResponse {
+	settings := p.provider.GetSettings().WithSchedule(p.now())
+
+	p.mutex.Lock()
+	if settings.ActiveMode != p.activeMode {
+		log.Printf("Recording mode is now %s", settings.ActiveMode)
+		p.activeMode = settings.ActiveMode
+	}
+	p.mutex.Unlock()
+
+	return settings
+}
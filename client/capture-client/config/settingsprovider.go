@@ -1,6 +1,14 @@
 package config
 
+import "context"
+
 type SettingsProvider[T any] interface {
 	// GetSettings returns the current settings of type T.
 	GetSettings() T
 }
+
+// SettingsRefresher is implemented by settings providers that can fetch fresh settings on demand
+type SettingsRefresher interface {
+	// Refresh fetches the settings right away instead of waiting for the cache to expire
+	Refresh(ctx context.Context) error
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yeti47/cryospy/client/capture-client/client"
+	"github.com/yeti47/cryospy/client/capture-client/config"
+)
+
+// runEnroll implements the enroll command, which redeems a pairing code from the dashboard
+// and writes the received credentials into the configuration file
+func runEnroll(args []string) error {
+	flags := flag.NewFlagSet("enroll", flag.ExitOnError)
+	codeFlag := flags.String("code", "", "Pairing code or cryospy://enroll URI from the dashboard")
+	serverURLFlag := flags.String("server-url", "", "Capture server URL (overrides the URI and config)")
+	configPath := flags.String("config", "config.json", "Configuration file to write the credentials to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	pairingCode, err := client.ParsePairingCode(*codeFlag)
+	if err != nil {
+		return fmt.Errorf("%w (usage: capture-client enroll --code <code>)", err)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	serverURL := cfg.ServerURL
+	if pairingCode.ServerURL != "" {
+		serverURL = pairingCode.ServerURL
+	}
+	if *serverURLFlag != "" {
+		serverURL = *serverURLFlag
+	}
+
+	tlsConfig, err := enrollmentTLSConfig(cfg, serverURL, pairingCode.CAFingerprint)
+	if err != nil {
+		return err
+	}
+
+	proxyAuth := client.ProxyAuth{
+		Header: cfg.ProxyAuthHeader,
+		Value:  cfg.ProxyAuthValue,
+	}
+
+	fmt.Printf("Enrolling with %s...\n", serverURL)
+	credentials, err := client.Enroll(context.Background(), serverURL, pairingCode.Code, proxyAuth, tlsConfig, time.Duration(cfg.ServerTimeoutSeconds)*time.Second)
+	if err != nil {
+		return fmt.Errorf("enrollment failed: %w", err)
+	}
+
+	cfg.ClientID = credentials.ClientID
+	cfg.ClientSecret = credentials.ClientSecret
+	cfg.ServerURL = serverURL
+
+	// Certificate files are stored next to the configuration file
+	configDir := filepath.Dir(*configPath)
+	if credentials.CABundle != "" {
+		cfg.TLSCABundleFile = filepath.Join(configDir, "ca.crt")
+		if err := os.WriteFile(cfg.TLSCABundleFile, []byte(credentials.CABundle), 0644); err != nil {
+			return fmt.Errorf("failed to write CA bundle: %w", err)
+		}
+	}
+	if credentials.ClientCertificate != "" {
+		cfg.TLSClientCertFile = filepath.Join(configDir, "client.crt")
+		cfg.TLSClientKeyFile = filepath.Join(configDir, "client.key")
+		if err := os.WriteFile(cfg.TLSClientCertFile, []byte(credentials.ClientCertificate), 0644); err != nil {
+			return fmt.Errorf("failed to write client certificate: %w", err)
+		}
+		if err := os.WriteFile(cfg.TLSClientKeyFile, []byte(credentials.ClientKey), 0600); err != nil {
+			return fmt.Errorf("failed to write client key: %w", err)
+		}
+	}
+
+	if err := cfg.Save(*configPath); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	fmt.Printf("Enrolled as client %s. Configuration written to %s\n", credentials.ClientID, *configPath)
+	return nil
+}
+
+// enrollmentTLSConfig returns the TLS configuration for the enrollment request. A configured CA bundle is used as is;
+// otherwise the CA fingerprint from the pairing URI is pinned, since the device does not have the CA bundle yet.
+func enrollmentTLSConfig(cfg *config.Config, serverURL, caFingerprint string) (*tls.Config, error) {
+	if cfg.TLSCABundleFile != "" {
+		return client.NewTLSConfig(client.TLSFiles{CABundleFile: cfg.TLSCABundleFile})
+	}
+
+	parsedURL, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if parsedURL.Scheme != "https" || caFingerprint == "" {
+		return nil, nil
+	}
+
+	return client.NewPinnedCATLSConfig(caFingerprint, parsedURL.Hostname()), nil
+}
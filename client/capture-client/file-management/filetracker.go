@@ -17,6 +17,9 @@ type FileTracker interface {
 
 	// CleanupTempDirectory removes all files in the temporary directory
 	CleanupTempDirectory()
+
+	// TempDirectorySize returns the total size of the files in the temporary directory in bytes
+	TempDirectorySize() (int64, error)
 }
 
 // LocalFileTracker implements FileTracker for local filesystem
@@ -77,3 +80,30 @@ func (t *LocalFileTracker) CleanupTempDirectory() {
 		}
 	}
 }
+
+// TempDirectorySize returns the total size of the files in the temporary directory in bytes
+func (t *LocalFileTracker) TempDirectorySize() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(t.tempDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			// Files may be removed while walking the directory
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/yeti47/cryospy/client/capture-client/client"
+)
+
+// version is the version of the capture client reported with each heartbeat.
+// It is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// sendHeartbeats reports the status of the client to the server at the heartbeat interval until shutdown
+func (c *CaptureClient) sendHeartbeats() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		c.sendHeartbeat()
+
+		select {
+		case <-ticker.C:
+		case <-c.shutdownChan:
+			return
+		}
+	}
+}
+
+// sendHeartbeat sends a single heartbeat. Failures are only logged, since the next heartbeat follows shortly.
+func (c *CaptureClient) sendHeartbeat() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.heartbeatInterval)
+	defer cancel()
+
+	if err := c.serverClient.SendHeartbeat(ctx, c.heartbeatRequest()); err != nil {
+		log.Printf("Failed to send heartbeat: %v", err)
+	}
+}
+
+// heartbeatRequest collects the current status of the client
+func (c *CaptureClient) heartbeatRequest() client.HeartbeatRequest {
+	tempDirBytes, err := c.fileTracker.TempDirectorySize()
+	if err != nil {
+		log.Printf("Failed to determine size of temp directory: %v", err)
+	}
+
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	return client.HeartbeatRequest{
+		Version:       version,
+		UptimeSeconds: int64(time.Since(c.startedAt).Seconds()),
+		CameraStatus:  c.cameraStatus,
+		QueueDepth:    c.uploadQueue.Pending(),
+		RetryBacklog:  c.uploadQueue.RetryBacklog(),
+		TempDirBytes:  tempDirBytes,
+		LastError:     c.lastError,
+		LastErrorAt:   c.lastErrorAt,
+	}
+}
+
+// setCameraStatus updates the camera status reported with the next heartbeat
+func (c *CaptureClient) setCameraStatus(status client.CameraStatus) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	c.cameraStatus = status
+}
+
+// recordError remembers an error to be reported with the next heartbeat
+func (c *CaptureClient) recordError(err error) {
+	now := time.Now().UTC()
+
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	c.lastError = err.Error()
+	c.lastErrorAt = &now
+}
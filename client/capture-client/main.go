@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"io"
 	"log"
@@ -21,6 +22,14 @@ import (
 )
 
 func main() {
+	// The enroll command sets up the configuration with a pairing code instead of starting the capture client
+	if len(os.Args) > 1 && os.Args[1] == "enroll" {
+		if err := runEnroll(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Parse command line flags
 	clientID := flag.String("client-id", "", "Client ID (overrides config)")
 	clientSecret := flag.String("client-secret", "", "Client secret (overrides config)")
@@ -67,8 +76,8 @@ func main() {
 	})
 
 	// Log final configuration (without sensitive data)
-	log.Printf("Configuration: ServerURL=%s, CameraDevice=%s, BufferSize=%d, SettingsSyncSeconds=%d",
-		cfg.ServerURL, cfg.CameraDevice, cfg.BufferSize, cfg.SettingsSyncSeconds)
+	log.Printf("Configuration: ServerURL=%s, CameraDevice=%s, BufferSize=%d, SettingsSyncSeconds=%d, HeartbeatSeconds=%d, Version=%s",
+		cfg.ServerURL, cfg.CameraDevice, cfg.BufferSize, cfg.SettingsSyncSeconds, cfg.HeartbeatSeconds, version)
 
 	// Set up temporary directory
 	tempDir := filepath.Join(".", "temp")
@@ -86,7 +95,15 @@ func main() {
 		Header: cfg.ProxyAuthHeader,
 		Value:  cfg.ProxyAuthValue,
 	}
-	serverClient := client.NewCaptureServerClient(cfg.ServerURL, clientAuth, proxyAuth, time.Duration(cfg.ServerTimeoutSeconds)*time.Second)
+	tlsConfig, err := client.NewTLSConfig(client.TLSFiles{
+		ClientCertFile: cfg.TLSClientCertFile,
+		ClientKeyFile:  cfg.TLSClientKeyFile,
+		CABundleFile:   cfg.TLSCABundleFile,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+	serverClient := client.NewCaptureServerClient(cfg.ServerURL, clientAuth, proxyAuth, time.Duration(cfg.ServerTimeoutSeconds)*time.Second, tlsConfig)
 
 	// Create client settings provider
 	settingsCacheDuration := time.Duration(cfg.SettingsSyncSeconds) * time.Second
@@ -95,10 +112,21 @@ func main() {
 		log.Fatalf("Failed to create client settings provider: %v", err)
 	}
 
+	// Requests that wait for news from the server have to return before the server timeout
+	serverWait := max(time.Duration(cfg.ServerTimeoutSeconds-5)*time.Second, time.Second)
+
+	// Pick up settings changes as soon as they are made on the dashboard
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go clientSettingsProvider.Watch(watchCtx, serverWait)
+
+	// Apply the recording schedule on top of the synced settings
+	scheduledSettingsProvider := config.NewScheduledSettingsProvider(clientSettingsProvider)
+
 	// Create domain-specific settings providers
-	motionSettingsProvider := motiondetection.NewMotionDetectionSettingsProvider(clientSettingsProvider)
-	postProcessingSettingsProvider := postprocessing.NewPostProcessingSettingsProvider(clientSettingsProvider)
-	recordingSettingsProvider := recording.NewRecordingSettingsProvider(clientSettingsProvider)
+	motionSettingsProvider := motiondetection.NewMotionDetectionSettingsProvider(scheduledSettingsProvider)
+	postProcessingSettingsProvider := postprocessing.NewPostProcessingSettingsProvider(scheduledSettingsProvider)
+	recordingSettingsProvider := recording.NewRecordingSettingsProvider(scheduledSettingsProvider)
 
 	// Create codec provider for video post-processing
 	codecProvider := common.NewFFmpegCodecProvider()
@@ -125,8 +153,12 @@ func main() {
 		postProcessor,
 		uploadQueue,
 		fileTracker,
+		scheduledSettingsProvider,
 		clientSettingsProvider,
+		serverClient,
 		cfg.CameraDevice,
+		time.Duration(cfg.HeartbeatSeconds)*time.Second,
+		serverWait,
 	)
 
 	// Handle graceful shutdown
@@ -143,6 +175,7 @@ func main() {
 	log.Println("Capture client running. Press Ctrl+C to stop.")
 	<-sigChan
 	log.Println("Shutdown signal received, stopping capture...")
+	stopWatching()
 
 	// Stop the capture client
 	if err := captureClient.Stop(); err != nil {
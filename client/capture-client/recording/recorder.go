@@ -1,6 +1,8 @@
 package recording
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -19,6 +21,12 @@ var DefaultRecordingSettings = RecordingSettings{
 	FrameRate:    15.0,             // Default frame rate of 30 FPS
 }
 
+// pausedPollInterval is how often a paused recorder checks whether it should resume
+const pausedPollInterval = 5 * time.Second
+
+// restartTimeout is how long a restart waits for the current recording to stop
+const restartTimeout = 30 * time.Second
+
 type RecordingCallback func(clip *RawClip) error
 type RecordingErrorCallback func(err error) (cancel bool)
 
@@ -30,6 +38,16 @@ type Recorder interface {
 	StopRecording() error
 	// IsRecording checks if a recording is currently in progress
 	IsRecording() bool
+	// RestartRecording stops the current recording session, which closes the camera, and starts a new one
+	// with the same callbacks
+	RestartRecording() error
+	// Snapshot returns the next frame from the camera as a JPEG image
+	Snapshot(ctx context.Context) ([]byte, error)
+}
+
+type snapshotResult struct {
+	data []byte
+	err  error
 }
 
 type GoCVRecorder struct {
@@ -38,6 +56,14 @@ type GoCVRecorder struct {
 	settingsProvider config.SettingsProvider[RecordingSettings]
 	isRecording      bool
 	mu               sync.RWMutex
+
+	// State of the current recording session, needed to restart it
+	callback      RecordingCallback
+	errorCallback RecordingErrorCallback
+	stopped       chan struct{} // Closed once the recording goroutine has closed the camera
+
+	// snapshotRequests is served by the recording goroutine, which owns the camera
+	snapshotRequests chan chan snapshotResult
 }
 
 func NewGoCVRecorder(device string, clipDirectory string, provider config.SettingsProvider[RecordingSettings]) *GoCVRecorder {
@@ -46,6 +72,7 @@ func NewGoCVRecorder(device string, clipDirectory string, provider config.Settin
 		clipDirectory:    clipDirectory,
 		settingsProvider: provider,
 		isRecording:      false,
+		snapshotRequests: make(chan chan snapshotResult),
 	}
 }
 
@@ -77,6 +104,10 @@ func (r *GoCVRecorder) StartRecording(callback RecordingCallback, errorCallback
 		return false, nil // Already recording
 	}
 	r.isRecording = true
+	r.callback = callback
+	r.errorCallback = errorCallback
+	stopped := make(chan struct{})
+	r.stopped = stopped
 	r.mu.Unlock()
 
 	// Open webcam
@@ -93,6 +124,7 @@ func (r *GoCVRecorder) StartRecording(callback RecordingCallback, errorCallback
 		r.mu.Lock()
 		r.isRecording = false
 		r.mu.Unlock()
+		close(stopped)
 		return false, fmt.Errorf("failed to open webcam: %w", err)
 	}
 
@@ -108,6 +140,7 @@ func (r *GoCVRecorder) StartRecording(callback RecordingCallback, errorCallback
 			}
 			r.isRecording = false
 			r.mu.Unlock()
+			close(stopped)
 		}()
 
 		for clipIndex := 0; ; clipIndex++ {
@@ -122,6 +155,17 @@ func (r *GoCVRecorder) StartRecording(callback RecordingCallback, errorCallback
 			// The provider is responsible for its own thread safety.
 			settingsSnapshot := r.settingsProvider.GetSettings()
 
+			if settingsSnapshot.Paused {
+				// Keep the camera open, so that recording resumes without reinitializing the device.
+				// Snapshots can still be taken while paused.
+				select {
+				case reply := <-r.snapshotRequests:
+					reply <- r.readSnapshot(webcam)
+				case <-time.After(pausedPollInterval):
+				}
+				continue
+			}
+
 			clip, err := r.recordNextClip(webcam, clipIndex, settingsSnapshot)
 			if err != nil {
 				log.Printf("Error recording clip %d: %v", clipIndex, err)
@@ -237,6 +281,13 @@ func (r *GoCVRecorder) recordNextClip(webcam *gocv.VideoCapture, clipIndex int,
 			continue
 		}
 
+		// Hand out the frame if a snapshot was requested
+		select {
+		case reply := <-r.snapshotRequests:
+			reply <- encodeSnapshot(img)
+		default:
+		}
+
 		// Write frame to video
 		if err := writer.Write(img); err != nil {
 			log.Printf("Failed to write frame %d to video: %v", frameCount, err)
@@ -277,3 +328,79 @@ func (r *GoCVRecorder) StopRecording() error {
 	// The recording goroutine will handle closing and cleaning up the webcam.
 	return nil
 }
+
+// RestartRecording stops the current recording session, which closes the camera, and starts a new one
+// with the same callbacks. The clip being recorded is cut short and handed to the callback as usual.
+func (r *GoCVRecorder) RestartRecording() error {
+	r.mu.Lock()
+	if !r.isRecording {
+		r.mu.Unlock()
+		return fmt.Errorf("recorder is not recording")
+	}
+	callback, errorCallback, stopped := r.callback, r.errorCallback, r.stopped
+	r.isRecording = false
+	r.mu.Unlock()
+
+	log.Println("Restarting recording...")
+
+	// Wait for the recording goroutine to close the camera before opening it again
+	select {
+	case <-stopped:
+	case <-time.After(restartTimeout):
+		return fmt.Errorf("recording did not stop within %v", restartTimeout)
+	}
+
+	started, err := r.StartRecording(callback, errorCallback)
+	if err != nil {
+		return err
+	}
+	if !started {
+		return fmt.Errorf("recording did not start (recorder busy)")
+	}
+	return nil
+}
+
+// Snapshot returns the next frame from the camera as a JPEG image.
+// The frame is taken by the recording goroutine, so recording has to be in progress.
+func (r *GoCVRecorder) Snapshot(ctx context.Context) ([]byte, error) {
+	if !r.IsRecording() {
+		return nil, fmt.Errorf("recorder is not recording")
+	}
+
+	reply := make(chan snapshotResult, 1)
+	select {
+	case r.snapshotRequests <- reply:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("camera did not deliver a frame: %w", ctx.Err())
+	}
+
+	select {
+	case result := <-reply:
+		return result.data, result.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("camera did not deliver a frame: %w", ctx.Err())
+	}
+}
+
+// readSnapshot reads a single frame for a snapshot while no clip is being recorded
+func (r *GoCVRecorder) readSnapshot(webcam *gocv.VideoCapture) snapshotResult {
+	img := gocv.NewMat()
+	defer img.Close()
+
+	if ok := webcam.Read(&img); !ok || img.Empty() {
+		return snapshotResult{err: fmt.Errorf("failed to read frame from webcam")}
+	}
+	return encodeSnapshot(img)
+}
+
+// encodeSnapshot encodes a frame as a JPEG image
+func encodeSnapshot(img gocv.Mat) snapshotResult {
+	buffer, err := gocv.IMEncode(gocv.JPEGFileExt, img)
+	if err != nil {
+		return snapshotResult{err: fmt.Errorf("failed to encode snapshot: %w", err)}
+	}
+	defer buffer.Close()
+
+	// The bytes are backed by the native buffer, which is released on close
+	return snapshotResult{data: bytes.Clone(buffer.GetBytes())}
+}
@@ -11,6 +11,7 @@ type RecordingSettings struct {
 	ClipDuration time.Duration // Duration of each recorded clip
 	Codec        string        // Video codec to use (e.g., "MJPG", "H264")
 	FrameRate    float64       // Frame rate for video capture
+	Paused       bool          // Whether the schedule currently turns recording off
 }
 
 // RecordingSettingsProvider implements SettingsProvider for RecordingSettings
@@ -33,5 +34,6 @@ func (p *RecordingSettingsProvider) GetSettings() RecordingSettings {
 		ClipDuration: time.Duration(clientSettings.ClipDurationSeconds) * time.Second,
 		Codec:        clientSettings.CaptureCodec,
 		FrameRate:    clientSettings.CaptureFrameRate,
+		Paused:       clientSettings.ActiveMode == client.RecordingModeOff,
 	}
 }
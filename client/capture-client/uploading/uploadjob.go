@@ -11,5 +11,6 @@ type UploadJob struct {
 	Duration           time.Duration
 	RecordingTimestamp time.Time
 	Format             string // Video format (e.g., "mp4", "avi") for MIME type determination
+	SettingsVersion    int    // Version of the client settings the clip was processed with
 	RetryCount         int    // Number of retry attempts made
 }
@@ -22,6 +22,16 @@ type UploadQueue interface {
 
 	// Drain processes remaining uploads during shutdown with timeout
 	Drain(timeout time.Duration)
+
+	// Pending returns the number of clips waiting in the queue
+	Pending() int
+
+	// RetryBacklog returns the number of failed clips waiting for a retry
+	RetryBacklog() int
+
+	// FlushRetries retries all failed clips right away instead of waiting for their retry delay.
+	// It returns the number of clips that were waiting.
+	FlushRetries() int
 }
 
 // uploadQueue implements UploadService for server uploads
@@ -35,6 +45,7 @@ type uploadQueue struct {
 	retryBufferSize int
 	activeRetries   int
 	activeRetriesMu sync.Mutex
+	retryNow        chan struct{} // Closed to wake up all waiting retries, then replaced
 }
 
 // NewUploadQueue creates a new server upload service
@@ -47,6 +58,7 @@ func NewUploadQueue(serverClient client.CaptureServerClient, bufferSize int, ret
 		retryMinutes:    retryMinutes,
 		maxRetries:      maxRetries,
 		retryBufferSize: retryBufferSize,
+		retryNow:        make(chan struct{}),
 	}
 }
 
@@ -84,6 +96,28 @@ func (s *uploadQueue) Drain(timeout time.Duration) {
 	s.drainQueueWithCallback(timeout, nil, nil)
 }
 
+// Pending returns the number of clips waiting in the queue
+func (s *uploadQueue) Pending() int {
+	return len(s.uploadQueue)
+}
+
+// RetryBacklog returns the number of failed clips waiting for a retry
+func (s *uploadQueue) RetryBacklog() int {
+	s.activeRetriesMu.Lock()
+	defer s.activeRetriesMu.Unlock()
+	return s.activeRetries
+}
+
+// FlushRetries retries all failed clips right away instead of waiting for their retry delay
+func (s *uploadQueue) FlushRetries() int {
+	s.activeRetriesMu.Lock()
+	defer s.activeRetriesMu.Unlock()
+
+	close(s.retryNow)
+	s.retryNow = make(chan struct{})
+	return s.activeRetries
+}
+
 // drainQueueWithCallback processes remaining uploads with optional callback
 func (s *uploadQueue) drainQueueWithCallback(timeout time.Duration, successCallback func(job *UploadJob), failureCallback func(job *UploadJob)) {
 	// Calculate actual timeout based on queue length - each upload could take the full timeout
@@ -132,6 +166,7 @@ func (s *uploadQueue) uploadClip(job *UploadJob, stopChan <-chan struct{}, succe
 		Duration:           job.Duration,
 		HasMotion:          job.HasMotion,
 		RecordingTimestamp: job.RecordingTimestamp,
+		SettingsVersion:    job.SettingsVersion,
 	}
 
 	// Upload to server with timeout
@@ -147,6 +182,7 @@ func (s *uploadQueue) uploadClip(job *UploadJob, stopChan <-chan struct{}, succe
 			s.activeRetriesMu.Lock()
 			if s.activeRetries < s.retryBufferSize {
 				s.activeRetries++
+				retryNow := s.retryNow
 				s.activeRetriesMu.Unlock()
 				job.RetryCount++
 				log.Printf("Scheduling retry for %s in %d minutes (attempt %d/%d)",
@@ -172,6 +208,8 @@ func (s *uploadQueue) uploadClip(job *UploadJob, stopChan <-chan struct{}, succe
 						return
 					case <-time.After(retryDelay):
 						// Retry after sleep
+					case <-retryNow:
+						log.Printf("Retrying %s right away", job.FilePath)
 					}
 					// Only retry if not shutting down
 					select {
@@ -24,6 +24,12 @@ if [[ "${1:-}" == "--configure" ]]; then
   exec /usr/local/bin/configure-client.sh "$@"
 fi
 
+# Redeem a pairing code from the dashboard; this writes config.json into the volume
+if [[ "${1:-}" == "enroll" ]]; then
+  cd "${CONFIG_DIR}"
+  exec capture-client "$@"
+fi
+
 # Wait for config file to appear (useful when volume is mounted with config from host)
 waited=0
 while [ ! -f "${CONFIG_FILE}" ] && [ "$waited" -lt "$CONFIG_WAIT_TIMEOUT" ]; do
@@ -36,7 +42,8 @@ if [ ! -f "${CONFIG_FILE}" ]; then
   echo "❌ config.json not found at ${CONFIG_FILE}"
   echo ""
   echo "A copy of config.example.json has been placed in ${CONFIG_DIR}."
-  echo "Update it with your server credentials and rename it to config.json, or rerun with --configure."
+  echo "Update it with your server credentials and rename it to config.json, or rerun with --configure"
+  echo "or with 'enroll --code <pairing code>'."
   exit 1
 fi
 
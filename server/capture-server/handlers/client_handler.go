@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
@@ -10,19 +15,29 @@ import (
 
 // ClientHandler handles client-related operations
 type ClientHandler struct {
-	logger        logging.Logger
-	clientService clients.ClientService
+	logger           logging.Logger
+	clientService    clients.ClientService
+	armModeService   clients.ArmModeService
+	heartbeatService clients.HeartbeatService
+	settingsMaxWait  time.Duration
 }
 
+// settingsPollInterval is how often the settings of a client waiting for a change are checked.
+// Settings are changed on the dashboard, which runs in its own process, so changes are found by checking the database.
+const settingsPollInterval = time.Second
+
 // NewClientHandler creates a new client handler
-func NewClientHandler(logger logging.Logger, clientService clients.ClientService) *ClientHandler {
+func NewClientHandler(logger logging.Logger, clientService clients.ClientService, armModeService clients.ArmModeService, heartbeatService clients.HeartbeatService, settingsMaxWait time.Duration) *ClientHandler {
 	if logger == nil {
 		logger = logging.NopLogger
 	}
 
 	return &ClientHandler{
-		logger:        logger,
-		clientService: clientService,
+		logger:           logger,
+		clientService:    clientService,
+		armModeService:   armModeService,
+		heartbeatService: heartbeatService,
+		settingsMaxWait:  settingsMaxWait,
 	}
 }
 
@@ -48,12 +63,20 @@ type ClientSettingsResponse struct {
 	MotionMogVarThresh    float64 `json:"motion_mog_var_thresh"`
 	CaptureCodec          string  `json:"capture_codec"`
 	CaptureFrameRate      float64 `json:"capture_frame_rate"`
+
+	Timezone string                  `json:"timezone"` // Timezone the schedule is evaluated in, empty for the local time of the device
+	Schedule []clients.ScheduleEntry `json:"schedule"` // Weekly recording schedule, applied by the client
+
+	ArmMode          clients.ArmMode       `json:"arm_mode"`                     // Active system-wide arm mode
+	ArmRecordingMode clients.RecordingMode `json:"arm_recording_mode,omitempty"` // Recording mode the client must use in the active arm mode, overriding the schedule
+
+	SettingsVersion int `json:"settings_version"` // Version of the settings in the settings history, reported back with each clip
 }
 
-// GetClientSettings handles GET /api/client/settings
+// GetClientSettings handles GET /api/client/settings[?wait=<seconds>].
+// The response carries an ETag. If the request's If-None-Match header matches the current settings, the server
+// answers 304 Not Modified, after waiting up to the given number of seconds for the settings to change.
 func (h *ClientHandler) GetClientSettings(c *gin.Context) {
-	h.logger.Info("Received client settings request")
-
 	// Get client information from middleware
 	clientInterface, exists := c.Get("client")
 	if !exists {
@@ -69,10 +92,88 @@ func (h *ClientHandler) GetClientSettings(c *gin.Context) {
 		return
 	}
 
-	h.logger.Info("Returning client settings", "clientID", client.ID)
+	wait := time.Duration(0)
+	if waitStr := c.Query("wait"); waitStr != "" {
+		seconds, err := strconv.Atoi(waitStr)
+		if err != nil || seconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wait time"})
+			return
+		}
+		wait = min(time.Duration(seconds)*time.Second, h.settingsMaxWait)
+	}
+
+	response, etag, err := h.settingsResponse(client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if etag == ifNoneMatch && wait > 0 {
+		response, etag, err = h.waitForSettingsChange(c, client.ID, etag, wait)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+	}
+
+	c.Header("ETag", etag)
+	if etag == ifNoneMatch {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	h.logger.Info("Returning client settings", "clientID", client.ID, "armMode", response.ArmMode, "etag", etag)
+	c.JSON(http.StatusOK, response)
+}
+
+// waitForSettingsChange checks the settings of a client until they no longer match the ETag, the wait time
+// is over or the client disconnects. It returns the settings as of the last check.
+func (h *ClientHandler) waitForSettingsChange(c *gin.Context, clientID, etag string, wait time.Duration) (*ClientSettingsResponse, string, error) {
+	ticker := time.NewTicker(settingsPollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			return nil, etag, nil
+		case <-c.Request.Context().Done():
+			return nil, etag, nil
+		}
+
+		client, err := h.clientService.GetClient(clientID)
+		if err != nil {
+			h.logger.Error("Failed to get client", err)
+			return nil, "", err
+		}
+		if client == nil {
+			// Deleted while waiting, the next request will be rejected
+			return nil, etag, nil
+		}
+
+		response, currentETag, err := h.settingsResponse(client)
+		if err != nil {
+			return nil, "", err
+		}
+		if currentETag != etag {
+			return response, currentETag, nil
+		}
+	}
+}
+
+// settingsResponse builds the settings response of a client together with its ETag,
+// which is derived from the content, so that it changes with any setting
+func (h *ClientHandler) settingsResponse(client *clients.Client) (*ClientSettingsResponse, string, error) {
+	armState, err := h.armModeService.GetArmState()
+	if err != nil {
+		h.logger.Error("Failed to get arm state", err)
+		return nil, "", err
+	}
 
-	// Create response
-	response := ClientSettingsResponse{
+	response := &ClientSettingsResponse{
 		ID:                    client.ID,
 		StorageLimitMegabytes: client.StorageLimitMegabytes,
 		ClipDurationSeconds:   client.ClipDurationSeconds,
@@ -93,7 +194,70 @@ func (h *ClientHandler) GetClientSettings(c *gin.Context) {
 		MotionMogVarThresh:    client.MotionMogVarThresh,
 		CaptureCodec:          client.CaptureCodec,
 		CaptureFrameRate:      client.CaptureFrameRate,
+		Timezone:              client.Timezone,
+		Schedule:              client.Schedule,
+		ArmMode:               armState.Mode,
+		ArmRecordingMode:      client.ArmBehavior(armState.Mode).RecordingMode,
+		SettingsVersion:       client.SettingsVersion,
 	}
 
-	c.JSON(http.StatusOK, response)
+	data, err := json.Marshal(response)
+	if err != nil {
+		h.logger.Error("Failed to marshal client settings", err)
+		return nil, "", err
+	}
+	hash := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	return response, etag, nil
+}
+
+// HeartbeatRequest represents the status report a client sends with each heartbeat
+type HeartbeatRequest struct {
+	Version       string     `json:"version"`
+	UptimeSeconds int64      `json:"uptime_seconds"`
+	CameraStatus  string     `json:"camera_status"` // One of "starting", "ok", "paused" or "error"
+	QueueDepth    int        `json:"queue_depth"`   // Clips waiting in the upload queue
+	RetryBacklog  int        `json:"retry_backlog"` // Clips waiting for an upload retry
+	TempDirBytes  int64      `json:"temp_dir_bytes"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+}
+
+// ReportHeartbeat handles POST /api/client/heartbeat
+func (h *ClientHandler) ReportHeartbeat(c *gin.Context) {
+	client, ok := c.Get("client")
+	if !ok {
+		h.logger.Error("Client not found in context")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	clientID := client.(*clients.Client).ID
+
+	var request HeartbeatRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.heartbeatService.RecordHeartbeat(clientID, clients.HeartbeatReport{
+		Version:       request.Version,
+		UptimeSeconds: request.UptimeSeconds,
+		CameraStatus:  clients.CameraStatus(request.CameraStatus),
+		QueueDepth:    request.QueueDepth,
+		RetryBacklog:  request.RetryBacklog,
+		TempDirBytes:  request.TempDirBytes,
+		LastError:     request.LastError,
+		LastErrorAt:   request.LastErrorAt,
+	})
+	if err != nil {
+		if clients.IsClientValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/yeti47/cryospy/server/capture-server/utils"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
 	"github.com/yeti47/cryospy/server/core/videos"
 )
 
@@ -32,9 +33,10 @@ func NewClipHandler(logger logging.Logger, clipCreator videos.ClipCreator) *Clip
 
 // UploadClipRequest represents the expected form data for clip upload
 type UploadClipRequest struct {
-	Timestamp string `form:"timestamp" binding:"required"`
-	Duration  string `form:"duration" binding:"required"`
-	HasMotion string `form:"has_motion"`
+	Timestamp       string `form:"timestamp" binding:"required"`
+	Duration        string `form:"duration" binding:"required"`
+	HasMotion       string `form:"has_motion"`
+	SettingsVersion string `form:"settings_version"` // Version of the settings the clip was recorded with, as received with the client settings
 }
 
 // UploadClip handles POST /api/clips
@@ -49,15 +51,16 @@ func (h *ClipHandler) UploadClip(c *gin.Context) {
 		return
 	}
 
-	clientSecret, exists := c.Get("clientSecret")
+	// The credential is either the client secret or an access token
+	clientCredential, exists := c.Get("clientCredential")
 	if !exists {
-		h.logger.Error("Client secret not found in context")
+		h.logger.Error("Client credential not found in context")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
 	clientIDStr := clientID.(string)
-	clientSecretStr := clientSecret.(string)
+	clientCredentialStr := clientCredential.(string)
 
 	// Parse form data
 	var req UploadClipRequest
@@ -102,6 +105,23 @@ func (h *ClipHandler) UploadClip(c *gin.Context) {
 		}
 	}
 
+	// Parse settings_version (optional). Clients that do not report it are assumed to use their current settings.
+	var settingsVersion int
+	var timezone string
+	if client, ok := c.Get("client"); ok {
+		timezone = client.(*clients.Client).Timezone
+	}
+	if req.SettingsVersion != "" {
+		settingsVersion, err = strconv.Atoi(req.SettingsVersion)
+		if err != nil || settingsVersion < 0 {
+			h.logger.Warn("Invalid settings_version format", "settings_version", req.SettingsVersion)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings_version format. Expected a version number"})
+			return
+		}
+	} else if client, ok := c.Get("client"); ok {
+		settingsVersion = client.(*clients.Client).SettingsVersion
+	}
+
 	// Get uploaded file
 	fileHeader, err := c.FormFile("video")
 	if err != nil {
@@ -145,14 +165,16 @@ func (h *ClipHandler) UploadClip(c *gin.Context) {
 
 	// Create clip request
 	createReq := videos.CreateClipRequest{
-		TimeStamp: timestamp,
-		Duration:  duration,
-		HasMotion: hasMotion,
-		Video:     videoData,
+		TimeStamp:       timestamp,
+		Duration:        duration,
+		HasMotion:       hasMotion,
+		Video:           videoData,
+		SettingsVersion: settingsVersion,
+		Timezone:        timezone,
 	}
 
 	// Create the clip
-	clip, err := h.clipCreator.CreateClip(createReq, clientIDStr, clientSecretStr)
+	clip, err := h.clipCreator.CreateClip(createReq, clientIDStr, clientCredentialStr)
 	if err != nil {
 		h.logger.Error("Failed to create clip", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create clip"})
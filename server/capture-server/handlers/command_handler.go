@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/commands"
+)
+
+// CommandHandler hands out the commands sent from the dashboard and receives their results
+type CommandHandler struct {
+	logger         logging.Logger
+	commandService commands.CommandService
+	maxWait        time.Duration
+}
+
+// NewCommandHandler creates a new command handler. Requests for commands are held open for at most maxWait.
+func NewCommandHandler(logger logging.Logger, commandService commands.CommandService, maxWait time.Duration) *CommandHandler {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &CommandHandler{
+		logger:         logger,
+		commandService: commandService,
+		maxWait:        maxWait,
+	}
+}
+
+// CommandResponse represents a command handed out to a client
+type CommandResponse struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// CommandResultRequest represents the result a client reports for a command
+type CommandResultRequest struct {
+	Success            bool   `json:"success"`
+	Message            string `json:"message"`
+	Attachment         []byte `json:"attachment,omitempty"` // Base64 encoded in JSON
+	AttachmentMimeType string `json:"attachment_mime_type,omitempty"`
+}
+
+// GetCommands handles GET /api/client/commands?wait=<seconds>.
+// If there are no pending commands, the request is held open until one arrives or the wait time is over.
+func (h *CommandHandler) GetCommands(c *gin.Context) {
+	clientID, exists := c.Get("clientID")
+	if !exists {
+		h.logger.Error("Client ID not found in context")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	wait := time.Duration(0)
+	if waitStr := c.Query("wait"); waitStr != "" {
+		seconds, err := strconv.Atoi(waitStr)
+		if err != nil || seconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wait time"})
+			return
+		}
+		wait = min(time.Duration(seconds)*time.Second, h.maxWait)
+	}
+
+	pending, err := h.commandService.WaitForCommands(c.Request.Context(), clientID.(string), wait)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	response := make([]CommandResponse, len(pending))
+	for i, command := range pending {
+		response[i] = CommandResponse{
+			ID:       command.ID,
+			Type:     string(command.Type),
+			IssuedAt: command.IssuedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"commands": response})
+}
+
+// ReportResult handles POST /api/client/commands/:id/result
+func (h *CommandHandler) ReportResult(c *gin.Context) {
+	clientID, exists := c.Get("clientID")
+	if !exists {
+		h.logger.Error("Client ID not found in context")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// The credential is either the client secret or an access token
+	clientCredential, exists := c.Get("clientCredential")
+	if !exists {
+		h.logger.Error("Client credential not found in context")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var request CommandResultRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.commandService.CompleteCommand(clientID.(string), clientCredential.(string), c.Param("id"), commands.CommandResult{
+		Success:            request.Success,
+		Message:            request.Message,
+		Attachment:         request.Attachment,
+		AttachmentMimeType: request.AttachmentMimeType,
+	})
+	if err != nil {
+		switch {
+		case commands.IsCommandNotFoundError(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Command not found or not waiting for a result"})
+		case commands.IsCommandValidationError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/auth"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/pairing"
+)
+
+// pairingFailureClientID is recorded as the client ID of failed enrollments, which have no client yet
+const pairingFailureClientID = "(pairing code)"
+
+// EnrollmentHandler hands out client credentials to new capture devices in exchange for a pairing code
+type EnrollmentHandler struct {
+	logger         logging.Logger
+	pairingService pairing.PairingService
+	failureTracker auth.FailureTracker
+	ipBlocklist    auth.IPBlocklist
+}
+
+// NewEnrollmentHandler creates a new enrollment handler
+func NewEnrollmentHandler(logger logging.Logger, pairingService pairing.PairingService, failureTracker auth.FailureTracker, ipBlocklist auth.IPBlocklist) *EnrollmentHandler {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	if failureTracker == nil {
+		failureTracker = auth.NopFailureTracker
+	}
+
+	if ipBlocklist == nil {
+		ipBlocklist = auth.NopIPBlocklist
+	}
+
+	return &EnrollmentHandler{
+		logger:         logger,
+		pairingService: pairingService,
+		failureTracker: failureTracker,
+		ipBlocklist:    ipBlocklist,
+	}
+}
+
+// EnrollRequest represents the enrollment request of a capture device
+type EnrollRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// Enroll handles POST /api/enroll
+func (h *EnrollmentHandler) Enroll(c *gin.Context) {
+	// Guessing codes counts like guessing secrets, so blocked IP addresses are rejected here as well
+	block, err := h.ipBlocklist.GetBlock(c.ClientIP(), time.Now())
+	if err != nil {
+		h.logger.Error("Failed to check IP blocklist", err)
+	} else if block != nil {
+		retryAfter := int(time.Until(block.BlockedUntil).Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed authentication attempts"})
+		return
+	}
+
+	var req EnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing pairing code"})
+		return
+	}
+
+	credentials, err := h.pairingService.Redeem(req.Code)
+	if err != nil {
+		if pairing.IsInvalidPairingCodeError(err) {
+			h.logger.Warn("Invalid pairing code", "clientIP", c.ClientIP())
+			h.failureTracker.RecordFailure(pairingFailureClientID, c.ClientIP(), time.Now())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid, expired or already used pairing code"})
+			return
+		}
+		h.logger.Error("Failed to redeem pairing code", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem pairing code"})
+		return
+	}
+
+	h.logger.Info("Capture device enrolled", "clientId", credentials.ClientID, "clientIP", c.ClientIP())
+	c.JSON(http.StatusOK, credentials)
+}
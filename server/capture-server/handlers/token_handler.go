@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+)
+
+// TokenHandler issues access tokens to capture clients
+type TokenHandler struct {
+	logger       logging.Logger
+	tokenService clients.ClientTokenService
+}
+
+// NewTokenHandler creates a new token handler
+func NewTokenHandler(logger logging.Logger, tokenService clients.ClientTokenService) *TokenHandler {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &TokenHandler{
+		logger:       logger,
+		tokenService: tokenService,
+	}
+}
+
+// TokenResponse represents the access token response
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"` // Lifetime of the token in seconds
+}
+
+// IssueToken handles POST /api/token
+func (h *TokenHandler) IssueToken(c *gin.Context) {
+	// Get client information from middleware, which only accepts the client secret on this route
+	clientID := c.GetString("clientID")
+	clientSecret := c.GetString("clientCredential")
+	if clientID == "" || clientSecret == "" {
+		h.logger.Error("Client credentials not found in context")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	token, err := h.tokenService.IssueToken(clientID, clientSecret)
+	if err != nil {
+		if clients.IsClientVerificationError(err) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		h.logger.Error("Failed to issue access token", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue access token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: token.Token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(token.ExpiresAt).Seconds()),
+	})
+}
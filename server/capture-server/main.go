@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,15 +17,23 @@ import (
 	"github.com/yeti47/cryospy/server/capture-server/middleware"
 	"github.com/yeti47/cryospy/server/core/ccc/auth"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/ccc/pki"
 	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/commands"
 	"github.com/yeti47/cryospy/server/core/config"
 	"github.com/yeti47/cryospy/server/core/encryption"
+	"github.com/yeti47/cryospy/server/core/events"
+	"github.com/yeti47/cryospy/server/core/homeassistant"
 	"github.com/yeti47/cryospy/server/core/notifications"
+	"github.com/yeti47/cryospy/server/core/pairing"
 	"github.com/yeti47/cryospy/server/core/videos"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// shutdownTimeout is how long a shutdown waits for the requests in flight, such as uploads and long polls
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load configuration from default path in user's home directory
 	cfg, err := config.LoadConfig("")
@@ -61,10 +73,42 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create client repository: %v", err)
 	}
+	settingsVersionRepo, err := clients.NewSQLiteSettingsVersionRepository(database)
+	if err != nil {
+		log.Fatalf("Failed to create settings version repository: %v", err)
+	}
+	clientGroupRepo, err := clients.NewSQLiteClientGroupRepository(database)
+	if err != nil {
+		log.Fatalf("Failed to create client group repository: %v", err)
+	}
 	clientVerifier := clients.NewClientVerifier(clientRepo, encryptor)
-	clientService := clients.NewClientService(logger, clientRepo, encryptor)
+	clientService := clients.NewClientService(logger, clientRepo, settingsVersionRepo, clientGroupRepo, encryptor)
 	clientMekProvider := clients.NewClientMekProvider(encryptor, clientRepo, clientVerifier)
 
+	// Access tokens spare clients the key derivation on every request. The MEK unwrapped at issue time
+	// is held in memory for the lifetime of the token.
+	tokenSettings := config.DefaultClientTokenSettings()
+	if cfg.ClientTokenSettings != nil {
+		tokenSettings = *cfg.ClientTokenSettings
+	}
+	tokenService, err := clients.NewClientTokenService(logger, clientRepo, clientMekProvider, clients.ClientTokenSettings{
+		Lifetime:        time.Duration(tokenSettings.LifetimeMinutes) * time.Minute,
+		MaxCachedTokens: tokenSettings.MaxCachedTokens,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create token service: %v", err)
+	}
+
+	// Set up TLS, optionally verifying client certificates against the CryoSpy CA
+	var tlsConfig *tls.Config
+	var certService clients.ClientCertificateService
+	if cfg.CaptureTLSSettings != nil {
+		tlsConfig, certService, err = createTLSConfig(cfg, logger, clientRepo)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+	}
+
 	// Initialize video services
 	videoMetadataExtractor := videos.NewFFmpegMetadataExtractor(logger)
 	thumbnailGenerator := videos.NewFFmpegThumbnailGenerator(logger)
@@ -74,7 +118,9 @@ func main() {
 		log.Fatalf("Failed to create clip repository: %v", err)
 	}
 
-	// Initialize notifiers based on configuration
+	// Initialize notifiers based on configuration. Notifications name clients by their display names and show times
+	// in their timezones.
+	clientDirectory := clients.NewClientDirectory(logger, clientRepo)
 	var storageNotifier notifications.StorageNotifier
 	var motionNotifier notifications.MotionNotifier
 
@@ -99,7 +145,7 @@ func main() {
 			MinInterval:      time.Duration(cfg.StorageNotificationSettings.MinIntervalMinutes) * time.Minute,
 			WarningThreshold: cfg.StorageNotificationSettings.WarningThreshold,
 		}
-		storageNotifier = notifications.NewEmailStorageNotifier(storageNotifierSettings, emailSender, logger)
+		storageNotifier = notifications.NewEmailStorageNotifier(storageNotifierSettings, emailSender, clientDirectory, logger)
 		logger.Info("Storage notifications enabled", "recipient", cfg.StorageNotificationSettings.Recipient)
 	}
 
@@ -109,13 +155,14 @@ func main() {
 			Recipient:   cfg.MotionNotificationSettings.Recipient,
 			MinInterval: time.Duration(cfg.MotionNotificationSettings.MinIntervalMinutes) * time.Minute,
 		}
-		motionNotifier = notifications.NewEmailMotionNotifier(motionNotifierSettings, emailSender, logger)
+		motionNotifier = notifications.NewEmailMotionNotifier(motionNotifierSettings, emailSender, clientDirectory, logger)
 		logger.Info("Motion notifications enabled", "recipient", cfg.MotionNotificationSettings.Recipient)
 	}
 
 	// Initialize auth notifier and failure tracker from unified auth event settings
 	var authNotifier notifications.AuthNotifier
 	var failureTracker auth.FailureTracker
+	var ipBlocklist auth.IPBlocklist
 
 	if cfg.AuthEventSettings != nil {
 		// Create failure tracker settings
@@ -123,12 +170,31 @@ func main() {
 			Threshold:  cfg.AuthEventSettings.AutoDisableThreshold, // 0 means no auto-disable
 			TimeWindow: time.Duration(cfg.AuthEventSettings.TimeWindowMinutes) * time.Minute,
 		}
-		failureTracker = auth.NewMemoryFailureTracker(autoDisableSettings)
+		ipBlockSettings := auth.IPBlockSettings{
+			Threshold:     cfg.AuthEventSettings.IPBlockThreshold, // 0 means no IP blocking
+			TimeWindow:    time.Duration(cfg.AuthEventSettings.IPBlockWindowMinutes) * time.Minute,
+			BlockDuration: time.Duration(cfg.AuthEventSettings.IPBlockMinutes) * time.Minute,
+		}
+		if ipBlockSettings.TimeWindow == 0 {
+			// Configs from before the separate IP block window count the failures of an IP like those of a client
+			ipBlockSettings.TimeWindow = autoDisableSettings.TimeWindow
+		}
+
+		// Failures are stored in the database so that they survive a restart and show up on the dashboard
+		sqliteFailureTracker, err := auth.NewSQLiteFailureTracker(logger, database, autoDisableSettings, ipBlockSettings)
+		if err != nil {
+			log.Fatalf("Failed to create failure tracker: %v", err)
+		}
+		failureTracker = sqliteFailureTracker
+		ipBlocklist = sqliteFailureTracker
 
 		// Log what features are enabled
 		if cfg.AuthEventSettings.AutoDisableThreshold > 0 {
 			logger.Info("Authentication auto-disable enabled", "threshold", cfg.AuthEventSettings.AutoDisableThreshold, "timeWindow", autoDisableSettings.TimeWindow)
 		}
+		if cfg.AuthEventSettings.IPBlockThreshold > 0 {
+			logger.Info("IP blocking enabled", "threshold", cfg.AuthEventSettings.IPBlockThreshold, "timeWindow", ipBlockSettings.TimeWindow, "blockDuration", ipBlockSettings.BlockDuration)
+		}
 
 		// Initialize auth notifier if notifications are configured
 		if cfg.AuthEventSettings.NotificationRecipient != "" && cfg.AuthEventSettings.NotificationThreshold > 0 && emailSender != notifications.NopSender {
@@ -137,7 +203,7 @@ func main() {
 				MinInterval:      time.Duration(cfg.AuthEventSettings.MinIntervalMinutes) * time.Minute,
 				FailureThreshold: cfg.AuthEventSettings.NotificationThreshold,
 			}
-			authNotifier = notifications.NewEmailAuthNotifier(authNotifierSettings, emailSender, logger)
+			authNotifier = notifications.NewEmailAuthNotifier(authNotifierSettings, emailSender, clientDirectory, logger)
 			logger.Info("Authentication failure notifications enabled", "recipient", cfg.AuthEventSettings.NotificationRecipient, "threshold", cfg.AuthEventSettings.NotificationThreshold)
 		} else {
 			authNotifier = notifications.NopAuthNotifier
@@ -148,22 +214,137 @@ func main() {
 		// No auth event settings configured
 		authNotifier = notifications.NopAuthNotifier
 		failureTracker = auth.NopFailureTracker
+		ipBlocklist = auth.NopIPBlocklist
+	}
+
+	// The arm mode is switched on the dashboard and decides per client whether motion notifications go out
+	armStateRepo, err := clients.NewSQLiteArmStateRepository(database)
+	if err != nil {
+		log.Fatalf("Failed to create arm state repository: %v", err)
+	}
+	armModeService := clients.NewArmModeService(logger, armStateRepo, clientRepo)
+	armed := func(notifier notifications.MotionNotifier) notifications.MotionNotifier {
+		if notifier == nil {
+			return nil
+		}
+		return notifications.NewPolicyMotionNotifier(notifier, armModeService, logger)
 	}
 
-	storageManager := videos.NewStorageManager(logger, clipRepo, clientRepo, storageNotifier, motionNotifier)
+	// Uploads and authentication publish their events on the bus. Each notifier gets them through a queue of its own,
+	// so that a slow mail server or webhook never holds up an upload.
+	// The bus is closed on shutdown, after the last request has been handled.
+	eventBus := events.NewBus(logger, events.BusSettings{})
+	notifications.SubscribeNotifiers(eventBus, "email", logger, notifications.Notifiers{
+		Motion:  armed(motionNotifier),
+		Storage: storageNotifier,
+		Auth:    authNotifier,
+	})
+
+	// Webhooks receive the same events as the email notifications, as signed JSON payloads, and additionally every
+	// stored or evicted clip and every client disabled by the capture server
+	if webhookNotifier := newWebhookNotifier(cfg, clientDirectory, logger); webhookNotifier != nil {
+		notifications.SubscribeNotifiers(eventBus, "webhook", logger, notifications.Notifiers{
+			Motion:  armed(webhookNotifier),
+			Storage: webhookNotifier,
+			Auth:    webhookNotifier,
+			Clips:   webhookNotifier,
+			Clients: webhookNotifier,
+		})
+	}
+
+	// Push notifications reach phones much faster than emails
+	for _, pushNotifier := range newPushNotifiers(cfg, clientDirectory, logger) {
+		notifications.SubscribeNotifiers(eventBus, "push", logger, notifications.Notifiers{
+			Motion:  armed(pushNotifier),
+			Storage: pushNotifier,
+			Auth:    pushNotifier,
+		})
+	}
+
+	// Home Assistant sees the cameras through MQTT. Its motion sensors show all motion, whatever the arm mode.
+	var mqttPublisher homeassistant.MQTTPublisher
+	if mqttSettings := cfg.MQTTSettings; mqttSettings != nil && mqttSettings.BrokerURL != "" {
+		mqttPublisher = newMQTTPublisher(mqttSettings, logger, clientRepo, armModeService, clipRepo)
+		notifications.SubscribeNotifiers(eventBus, "mqtt", logger, notifications.Notifiers{
+			Motion:  mqttPublisher,
+			Storage: mqttPublisher,
+			Auth:    mqttPublisher,
+			Clips:   mqttPublisher,
+			Clients: mqttPublisher,
+		})
+		mqttPublisher.Start()
+		go refreshMQTTStates(mqttPublisher, time.Duration(mqttSettings.StateIntervalSeconds)*time.Second)
+		logger.Info("MQTT publishing enabled", "broker", mqttSettings.BrokerURL, "armCommands", mqttSettings.ArmCommandsEnabled)
+	}
+
+	// Watch the heartbeats of the capture clients and report clients that go silent
+	heartbeatSettings := config.DefaultHeartbeatSettings()
+	if cfg.HeartbeatSettings != nil {
+		heartbeatSettings = *cfg.HeartbeatSettings
+	}
+	heartbeatRepo, err := clients.NewSQLiteHeartbeatRepository(database)
+	if err != nil {
+		log.Fatalf("Failed to create heartbeat repository: %v", err)
+	}
+	var heartbeatNotifier notifications.HeartbeatNotifier
+	if heartbeatSettings.NotificationRecipient != "" && emailSender != notifications.NopSender {
+		heartbeatNotifier = notifications.NewEmailHeartbeatNotifier(notifications.HeartbeatNotificationSettings{
+			Recipient: heartbeatSettings.NotificationRecipient,
+		}, emailSender, clientDirectory, logger)
+		logger.Info("Client offline notifications enabled", "recipient", heartbeatSettings.NotificationRecipient, "offlineAfterMinutes", heartbeatSettings.OfflineAfterMinutes)
+	}
+	if mqttPublisher != nil {
+		heartbeatNotifier = notifications.NewMultiHeartbeatNotifier(heartbeatNotifier, mqttPublisher)
+	}
+	heartbeatService := clients.NewHeartbeatService(logger, heartbeatRepo, clientRepo, heartbeatNotifier, clients.HeartbeatSettings{
+		OfflineAfter: time.Duration(heartbeatSettings.OfflineAfterMinutes) * time.Minute,
+	})
+	go monitorHeartbeats(heartbeatService, time.Duration(heartbeatSettings.CheckIntervalSeconds)*time.Second)
+
+	storageManager := videos.NewStorageManager(logger, clipRepo, clientRepo, eventBus, videos.StorageManagerSettings{
+		WarningThreshold: storageWarningThreshold(cfg),
+	})
 	clipCreator := videos.NewClipCreator(
 		logger,
 		storageManager,
 		encryptor,
-		clientMekProvider,
+		tokenService,
 		videoMetadataExtractor,
 		thumbnailGenerator,
 	)
 
 	// Initialize handlers and middleware
-	authMiddleware := middleware.NewAuthMiddleware(logger, clientVerifier, authNotifier, clientService, failureTracker)
+	authMiddleware := middleware.NewAuthMiddleware(logger, clientVerifier, eventBus, clientService, failureTracker, ipBlocklist, tokenService, certService)
 	clipHandler := handlers.NewClipHandler(logger, clipCreator)
-	clientHandler := handlers.NewClientHandler(logger, clientService)
+	settingsSyncSettings := config.DefaultSettingsSyncSettings()
+	if cfg.SettingsSyncSettings != nil {
+		settingsSyncSettings = *cfg.SettingsSyncSettings
+	}
+	clientHandler := handlers.NewClientHandler(logger, clientService, armModeService, heartbeatService, time.Duration(settingsSyncSettings.MaxWaitSeconds)*time.Second)
+	tokenHandler := handlers.NewTokenHandler(logger, tokenService)
+
+	// Commands are issued on the dashboard and handed out to the clients that wait for them here
+	commandSettings := config.DefaultCommandSettings()
+	if cfg.CommandSettings != nil {
+		commandSettings = *cfg.CommandSettings
+	}
+	commandRepo, err := commands.NewSQLiteCommandRepository(database)
+	if err != nil {
+		log.Fatalf("Failed to create command repository: %v", err)
+	}
+	commandService := commands.NewCommandService(logger, commandRepo, clientRepo, encryptor, tokenService, commands.CommandSettings{
+		PendingLifetime: time.Duration(commandSettings.PendingLifetimeMinutes) * time.Minute,
+		ResultTimeout:   time.Duration(commandSettings.ResultTimeoutMinutes) * time.Minute,
+	})
+	commandHandler := handlers.NewCommandHandler(logger, commandService, time.Duration(commandSettings.MaxWaitSeconds)*time.Second)
+
+	// Only redemption happens here, so the pairing settings used for creating codes do not matter
+	pairingRepo, err := pairing.NewSQLitePairingCodeRepository(database)
+	if err != nil {
+		log.Fatalf("Failed to create pairing code repository: %v", err)
+	}
+	pairingService := pairing.NewPairingService(logger, pairingRepo, encryptor, pairing.PairingSettings{})
+	enrollmentHandler := handlers.NewEnrollmentHandler(logger, pairingService, failureTracker, ipBlocklist)
 
 	// Set up Gin router
 	router := initializeGin(cfg)
@@ -173,20 +354,242 @@ func main() {
 	router.Use(gin.Recovery())
 
 	// Set up routes
-	setupRoutes(router, authMiddleware, clipHandler, clientHandler)
+	setupRoutes(router, authMiddleware, clipHandler, clientHandler, tokenHandler, enrollmentHandler, commandHandler)
 
 	// Start server
 	addr := fmt.Sprintf(":%d", cfg.CapturePort)
-	logger.Info("Server listening", "address", addr)
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   router,
+		TLSConfig: tlsConfig,
+	}
 
-	if err := http.ListenAndServe(addr, router); err != nil {
+	// SIGINT and SIGTERM shut the server down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			logger.Info("Server listening with TLS", "address", addr, "clientCertificates", certService != nil)
+			serverErrors <- server.ListenAndServeTLS("", "")
+		} else {
+			logger.Info("Server listening", "address", addr)
+			serverErrors <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serverErrors:
 		logger.Error("Server failed to start", err)
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// Uploads in flight still publish their events, so the bus is closed only once they are done. Closing it waits
+	// until the notifiers have handled the queued events.
+	logger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down server gracefully", err)
+	}
+	eventBus.Close()
+	if mqttPublisher != nil {
+		mqttPublisher.Stop()
+	}
+	logger.Info("Server stopped")
+}
+
+// monitorHeartbeats looks for clients that stopped sending heartbeats, for as long as the server runs
+func monitorHeartbeats(heartbeatService clients.HeartbeatService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		heartbeatService.CheckMissedHeartbeats(now)
 	}
 }
 
+// refreshMQTTStates republishes the discovery configs and states, so that Home Assistant picks up clients added on the
+// dashboard, storage usage and arm mode changes
+func refreshMQTTStates(publisher homeassistant.MQTTPublisher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		publisher.PublishStates()
+	}
+}
+
+// newMQTTPublisher creates the publisher for Home Assistant. The broker marks CryoSpy as unavailable when the
+// connection of the capture server is lost.
+func newMQTTPublisher(settings *config.MQTTSettings, logger logging.Logger, clientRepo clients.ClientRepository, armModeService clients.ArmModeService, clipRepo videos.ClipRepository) homeassistant.MQTTPublisher {
+	publisherSettings := homeassistant.MQTTPublisherSettings{
+		TopicPrefix:        settings.TopicPrefix,
+		DiscoveryPrefix:    settings.DiscoveryPrefix,
+		MotionOffDelay:     time.Duration(settings.MotionOffDelaySeconds) * time.Second,
+		WarningThreshold:   settings.StorageWarningThreshold,
+		FailureThreshold:   settings.AuthFailureThreshold,
+		ArmCommandsEnabled: settings.ArmCommandsEnabled,
+	}
+	conn := homeassistant.NewPahoConnection(homeassistant.PahoSettings{
+		BrokerURL:   settings.BrokerURL,
+		ClientID:    settings.ClientID,
+		Username:    settings.Username,
+		Password:    settings.Password,
+		WillTopic:   homeassistant.AvailabilityTopic(settings.TopicPrefix),
+		WillPayload: homeassistant.AvailabilityOffline,
+	}, logger)
+	return homeassistant.NewMQTTPublisher(logger, publisherSettings, conn, clientRepo, armModeService, clipRepo)
+}
+
+// storageWarningThreshold returns the lowest storage warning threshold of the enabled notifiers, since each of them
+// decides on its own whether a capacity warning published by the storage manager is worth a notification. It returns
+// 0 if no notifier warns about storage.
+func storageWarningThreshold(cfg *config.Config) float64 {
+	var thresholds []float64
+	if cfg.SMTPSettings != nil && cfg.StorageNotificationSettings != nil {
+		thresholds = append(thresholds, cfg.StorageNotificationSettings.WarningThreshold)
+	}
+	if webhook := cfg.WebhookSettings; webhook != nil && len(webhook.URLs) > 0 {
+		thresholds = append(thresholds, webhook.StorageWarningThreshold)
+	}
+	if push := cfg.PushNotificationSettings; push != nil && (push.Ntfy != nil || push.Gotify != nil) {
+		thresholds = append(thresholds, push.StorageWarningThreshold)
+	}
+	if mqtt := cfg.MQTTSettings; mqtt != nil && mqtt.BrokerURL != "" {
+		thresholds = append(thresholds, mqtt.StorageWarningThreshold)
+	}
+
+	var lowest float64
+	for _, threshold := range thresholds {
+		if threshold > 0 && (lowest == 0 || threshold < lowest) {
+			lowest = threshold
+		}
+	}
+	return lowest
+}
+
+// newWebhookNotifier creates the notifier that posts events to the configured webhooks, or returns nil if no
+// webhook URLs are configured
+func newWebhookNotifier(cfg *config.Config, directory notifications.ClientDirectory, logger logging.Logger) notifications.WebhookNotifier {
+	settings := cfg.WebhookSettings
+	if settings == nil || len(settings.URLs) == 0 {
+		return nil
+	}
+
+	logger.Info("Webhook notifications enabled", "urls", len(settings.URLs), "events", settings.Events, "signed", settings.Secret != "")
+	return notifications.NewWebhookNotifier(notifications.WebhookSettings{
+		URLs:             settings.URLs,
+		Secret:           settings.Secret,
+		Events:           settings.Events,
+		MinInterval:      time.Duration(settings.MinIntervalMinutes) * time.Minute,
+		MaxRetries:       settings.MaxRetries,
+		RetryBackoff:     time.Duration(settings.RetryBackoffSeconds) * time.Second,
+		Timeout:          time.Duration(settings.TimeoutSeconds) * time.Second,
+		WarningThreshold: settings.StorageWarningThreshold,
+		FailureThreshold: settings.AuthFailureThreshold,
+	}, directory, logger)
+}
+
+// newPushNotifiers creates a notifier for each configured push service
+func newPushNotifiers(cfg *config.Config, directory notifications.ClientDirectory, logger logging.Logger) []notifications.PushNotifier {
+	settings := cfg.PushNotificationSettings
+	if settings == nil {
+		return nil
+	}
+
+	notifierSettings := func(priorities map[string]int) notifications.PushNotificationSettings {
+		return notifications.PushNotificationSettings{
+			MinInterval:      time.Duration(settings.MinIntervalMinutes) * time.Minute,
+			Priorities:       priorities,
+			DashboardURL:     settings.DashboardURL,
+			WarningThreshold: settings.StorageWarningThreshold,
+			FailureThreshold: settings.AuthFailureThreshold,
+		}
+	}
+
+	var pushNotifiers []notifications.PushNotifier
+	if ntfy := settings.Ntfy; ntfy != nil {
+		sender := notifications.NewNtfySender(ntfy.ServerURL, ntfy.Topic, ntfy.AccessToken)
+		pushNotifiers = append(pushNotifiers, notifications.NewPushNotifier(notifierSettings(ntfy.Priorities), sender, directory, logger))
+		logger.Info("ntfy push notifications enabled", "server", ntfy.ServerURL, "topic", ntfy.Topic)
+	}
+	if gotify := settings.Gotify; gotify != nil {
+		sender := notifications.NewGotifySender(gotify.ServerURL, gotify.AppToken)
+		pushNotifiers = append(pushNotifiers, notifications.NewPushNotifier(notifierSettings(gotify.Priorities), sender, directory, logger))
+		logger.Info("Gotify push notifications enabled", "server", gotify.ServerURL)
+	}
+	return pushNotifiers
+}
+
+// createTLSConfig creates the TLS configuration of the capture server. Unless a certificate file is configured,
+// the server certificate is issued by the CryoSpy CA on every start. If client certificates are required,
+// the returned certificate service checks them against the client records.
+func createTLSConfig(cfg *config.Config, logger logging.Logger, clientRepo clients.ClientRepository) (*tls.Config, clients.ClientCertificateService, error) {
+	settings := cfg.CaptureTLSSettings
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	var ca pki.CertificateAuthority
+	if settings.CertFile == "" || settings.RequireClientCertificates {
+		fileCA, err := pki.LoadOrCreateCertificateAuthority(logger, cfg.ResolveCADirectory())
+		if err != nil {
+			return nil, nil, err
+		}
+		ca = fileCA
+	}
+
+	if settings.CertFile != "" {
+		serverCert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load server certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{serverCert}
+	} else {
+		serverCert, err := ca.IssueServerCertificate(settings.ServerHosts, 365*24*time.Hour)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to issue server certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{*serverCert}
+		logger.Info("Issued server certificate from CryoSpy CA", "hosts", settings.ServerHosts)
+	}
+
+	if !settings.RequireClientCertificates {
+		return tlsConfig, nil, nil
+	}
+
+	validity := time.Duration(settings.ClientCertificateValidityDays) * 24 * time.Hour
+	certService := clients.NewClientCertificateService(logger, clientRepo, ca, validity)
+
+	// Devices that enroll with a pairing code do not have a certificate yet, so the handshake
+	// succeeds without one. The auth middleware requires a certificate for all other API routes.
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	tlsConfig.ClientCAs = ca.CertPool()
+	// Refuse the handshake for disabled clients and replaced certificates. The auth middleware
+	// repeats the check on every request, since connections are kept alive.
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return nil
+		}
+		_, err := certService.VerifyCertificate(state.PeerCertificates[0])
+		return err
+	}
+
+	return tlsConfig, certService, nil
+}
+
 // setupRoutes configures the HTTP routes
-func setupRoutes(router *gin.Engine, authMiddleware *middleware.AuthMiddleware, clipHandler *handlers.ClipHandler, clientHandler *handlers.ClientHandler) {
+func setupRoutes(router *gin.Engine, authMiddleware *middleware.AuthMiddleware, clipHandler *handlers.ClipHandler, clientHandler *handlers.ClientHandler, tokenHandler *handlers.TokenHandler, enrollmentHandler *handlers.EnrollmentHandler, commandHandler *handlers.CommandHandler) {
+	// Token endpoint (requires the client secret)
+	router.POST("/api/token", authMiddleware.RequireSecret(), tokenHandler.IssueToken)
+
+	// Enrollment endpoint (requires a pairing code instead of client credentials)
+	router.POST("/api/enroll", enrollmentHandler.Enroll)
+
 	// API routes group
 	api := router.Group("/api")
 
@@ -199,6 +602,13 @@ func setupRoutes(router *gin.Engine, authMiddleware *middleware.AuthMiddleware,
 	// Client settings endpoint
 	api.GET("/client/settings", clientHandler.GetClientSettings)
 
+	// Client heartbeat endpoint
+	api.POST("/client/heartbeat", clientHandler.ReportHeartbeat)
+
+	// Client command endpoints
+	api.GET("/client/commands", commandHandler.GetCommands)
+	api.POST("/client/commands/:id/result", commandHandler.ReportResult)
+
 	// Health check endpoint (no auth required)
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
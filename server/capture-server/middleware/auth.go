@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,46 +10,78 @@ import (
 	"github.com/yeti47/cryospy/server/core/ccc/auth"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
 	"github.com/yeti47/cryospy/server/core/clients"
-	"github.com/yeti47/cryospy/server/core/notifications"
+	"github.com/yeti47/cryospy/server/core/events"
 )
 
 // AuthMiddleware provides client authentication middleware for Gin
 type AuthMiddleware struct {
 	logger         logging.Logger
 	verifier       clients.ClientVerifier
-	authNotifier   notifications.AuthNotifier
+	bus            events.Bus
 	clientService  clients.ClientService
 	failureTracker auth.FailureTracker
+	ipBlocklist    auth.IPBlocklist
+	tokenService   clients.ClientTokenService
+	certService    clients.ClientCertificateService
 }
 
 // NewAuthMiddleware creates a new authentication middleware
-func NewAuthMiddleware(logger logging.Logger, verifier clients.ClientVerifier, authNotifier notifications.AuthNotifier, clientService clients.ClientService, failureTracker auth.FailureTracker) *AuthMiddleware {
+func NewAuthMiddleware(logger logging.Logger, verifier clients.ClientVerifier, bus events.Bus, clientService clients.ClientService, failureTracker auth.FailureTracker, ipBlocklist auth.IPBlocklist, tokenService clients.ClientTokenService, certService clients.ClientCertificateService) *AuthMiddleware {
 	if logger == nil {
 		logger = logging.NopLogger
 	}
 
-	if authNotifier == nil {
-		authNotifier = notifications.NopAuthNotifier
+	if bus == nil {
+		bus = events.NopBus
 	}
 
 	if failureTracker == nil {
 		failureTracker = auth.NopFailureTracker
 	}
 
+	if ipBlocklist == nil {
+		ipBlocklist = auth.NopIPBlocklist
+	}
+
 	return &AuthMiddleware{
 		logger:         logger,
 		verifier:       verifier,
-		authNotifier:   authNotifier,
+		bus:            bus,
 		clientService:  clientService,
 		failureTracker: failureTracker,
+		ipBlocklist:    ipBlocklist,
+		tokenService:   tokenService,
+		certService:    certService,
 	}
 }
 
-// RequireAuth middleware that requires client authentication
+// RequireAuth middleware that requires client authentication with either the client secret or an access token
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
+	return m.authenticate(m.tokenService != nil)
+}
+
+// RequireSecret middleware that requires client authentication with the client secret, e.g. to issue an access token
+func (m *AuthMiddleware) RequireSecret() gin.HandlerFunc {
+	return m.authenticate(false)
+}
+
+func (m *AuthMiddleware) authenticate(allowTokens bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Reject blocked IP addresses before looking at the credentials
+		block, err := m.ipBlocklist.GetBlock(c.ClientIP(), time.Now())
+		if err != nil {
+			m.logger.Error("Failed to check IP blocklist", err)
+		} else if block != nil {
+			m.logger.Warn("Rejected request from blocked IP address", "clientIP", c.ClientIP(), "blockedUntil", block.BlockedUntil)
+			retryAfter := int(time.Until(block.BlockedUntil).Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed authentication attempts"})
+			c.Abort()
+			return
+		}
+
 		// Extract client ID and secret from Authorization header
-		// Expected format: "Basic <base64(clientId:clientSecret)>"
+		// Expected format: "Basic <base64(clientId:clientSecret)>" or "Bearer <access token>"
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			m.logger.Warn("Missing Authorization header")
@@ -57,6 +90,11 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if token, ok := strings.CutPrefix(authHeader, "Bearer "); ok && allowTokens {
+			m.authenticateToken(c, token)
+			return
+		}
+
 		// Check if it's Basic auth
 		if !strings.HasPrefix(authHeader, "Basic ") {
 			m.logger.Warn("Invalid Authorization header format")
@@ -80,10 +118,7 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			// Check if it's a client verification error (authentication failure)
 			if clients.IsClientVerificationError(err) {
 				m.logger.Warn("Client verification failed", "clientID", clientID, "clientIP", c.ClientIP())
-				// Only track failures if we have a valid client (exists and enabled)
-				if client != nil {
-					m.recordAuthFailure(clientID, c)
-				}
+				m.recordAuthFailure(clientID, client != nil, c)
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 				c.Abort()
 				return
@@ -97,43 +132,113 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 
 		if !valid {
 			m.logger.Warn("Invalid client credentials", "clientID", clientID, "clientIP", c.ClientIP())
-			// Only track failures if we have a valid client (exists and enabled)
-			if client != nil {
-				m.recordAuthFailure(clientID, c)
-			}
+			m.recordAuthFailure(clientID, client != nil, c)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			c.Abort()
 			return
 		}
 
+		if !m.checkClientCertificate(c, clientID) {
+			return
+		}
+
 		// Store client information in context
 		c.Set("client", client)
 		c.Set("clientID", clientID)
-		c.Set("clientSecret", clientSecret)
+		c.Set("clientCredential", clientSecret)
 
 		c.Next()
 	}
 }
 
-// recordAuthFailure records an authentication failure and sends notification if threshold is exceeded
-func (am *AuthMiddleware) recordAuthFailure(clientID string, c *gin.Context) {
+// authenticateToken verifies an access token. The expensive secret verification and key derivation
+// already happened when the token was issued, so this only checks the signature and the client state.
+// Rejected tokens do not count as authentication failures, since clients routinely present expired ones.
+func (m *AuthMiddleware) authenticateToken(c *gin.Context, token string) {
+	client, err := m.tokenService.VerifyToken(token)
+	if err != nil {
+		if clients.IsClientVerificationError(err) {
+			m.logger.Info("Rejected invalid or expired access token", "clientIP", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired access token"})
+			c.Abort()
+			return
+		}
+		m.logger.Error("Error verifying access token", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication error"})
+		c.Abort()
+		return
+	}
+
+	if !m.checkClientCertificate(c, client.ID) {
+		return
+	}
+
+	// Store client information in context. The token stands in for the secret when the MEK is needed.
+	c.Set("client", client)
+	c.Set("clientID", client.ID)
+	c.Set("clientCredential", token)
+
+	c.Next()
+}
+
+// checkClientCertificate makes sure that the client certificate of the connection belongs to the authenticated client.
+// The certificate was verified against the CA during the handshake, but a connection may outlive
+// the client being disabled or its certificate being replaced, so its state is checked on every request.
+func (m *AuthMiddleware) checkClientCertificate(c *gin.Context, clientID string) bool {
+	if m.certService == nil {
+		return true
+	}
+
+	if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+		m.logger.Warn("Missing client certificate", "clientID", clientID, "clientIP", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Client certificate required"})
+		c.Abort()
+		return false
+	}
+
+	certClient, err := m.certService.VerifyCertificate(c.Request.TLS.PeerCertificates[0])
+	if err != nil && !clients.IsClientVerificationError(err) {
+		m.logger.Error("Error verifying client certificate", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication error"})
+		c.Abort()
+		return false
+	}
+
+	if err != nil || certClient.ID != clientID {
+		m.logger.Warn("Client certificate rejected", "clientID", clientID, "certificate", c.Request.TLS.PeerCertificates[0].Subject.CommonName, "clientIP", c.ClientIP())
+		m.recordAuthFailure(clientID, true, c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid client certificate"})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// recordAuthFailure records an authentication failure and publishes it on the event bus.
+// Failures for unknown or disabled client IDs count towards blocking the IP address, but only
+// failures of an existing, enabled client are published and can trigger auto-disable.
+func (am *AuthMiddleware) recordAuthFailure(clientID string, knownClient bool, c *gin.Context) {
 	// Get client IP
 	clientIP := c.ClientIP()
 
 	// Record the failure and get current count
 	failureCount := am.failureTracker.RecordFailure(clientID, clientIP, time.Now())
-
-	// Check if we should notify
-	if am.authNotifier.ShouldNotify(failureCount) {
-		go am.authNotifier.NotifyRepeatedAuthFailure(clientID, failureCount, clientIP)
+	if !knownClient {
+		return
 	}
 
+	// The notifiers decide from the failure count whether to notify
+	am.bus.Publish(events.AuthFailure{ClientID: clientID, ClientIP: clientIP, FailureCount: failureCount})
+
 	// Check if client should be auto-disabled
 	if am.failureTracker.ShouldAutoDisable(failureCount) {
 		am.logger.Warn("Auto-disabling client due to repeated authentication failures", "clientId", clientID)
 		err := am.clientService.DisableClient(clientID)
 		if err != nil {
 			am.logger.Error("Failed to auto-disable client", "clientId", clientID, "error", err)
+			return
 		}
+		am.bus.Publish(events.ClientDisabled{ClientID: clientID, Reason: events.DisabledForAuthFailures, FailureCount: failureCount})
 	}
 }
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginThrottleSettings holds configuration for brute-force protection of interactive logins
+type LoginThrottleSettings struct {
+	BaseDelay        time.Duration // Delay after the first failure, doubled with every further failure
+	MaxDelay         time.Duration // Upper bound for the delay between two attempts (0 to keep the delay at BaseDelay)
+	LockoutThreshold int           // Number of failures within the time window that lock the login (0 to disable)
+	LockoutDuration  time.Duration // How long a lockout lasts
+	TimeWindow       time.Duration // Time window for counting failures
+}
+
+// LoginFailureResult describes the state of an account and IP address after a failed login
+type LoginFailureResult struct {
+	AccountFailures int           // Failures for the account within the time window
+	IPFailures      int           // Failures from the IP address within the time window
+	RetryAfter      time.Duration // Time until the next attempt is accepted
+	Locked          bool          // True if this failure started a lockout
+	LockedUntil     time.Time     // End of the lockout, zero if not locked
+	SourceIPs       []string      // Distinct IP addresses that failed to log in to the account within the time window
+}
+
+// LoginThrottle slows down repeated failed logins per account and per IP address
+type LoginThrottle interface {
+	// Check returns how long the caller has to wait before a login for the account from the IP address is accepted.
+	// Zero means that the attempt may proceed.
+	Check(account, ip string, now time.Time) time.Duration
+	// RecordFailure records a failed login and returns the resulting backoff and lockout state
+	RecordFailure(account, ip string, now time.Time) LoginFailureResult
+	// RecordSuccess clears the failures of the account after a successful login.
+	// Failures of the IP address are kept so that a single valid account cannot be used to reset them.
+	RecordSuccess(account string)
+}
+
+// nopLoginThrottle is a no-operation implementation
+type nopLoginThrottle struct{}
+
+var NopLoginThrottle LoginThrottle = &nopLoginThrottle{}
+
+func (n *nopLoginThrottle) Check(account, ip string, now time.Time) time.Duration {
+	return 0
+}
+
+func (n *nopLoginThrottle) RecordFailure(account, ip string, now time.Time) LoginFailureResult {
+	return LoginFailureResult{}
+}
+
+func (n *nopLoginThrottle) RecordSuccess(account string) {}
+
+// throttleState holds the recent failures of a single account or IP address
+type throttleState struct {
+	failures    []FailureRecord
+	lockedUntil time.Time
+}
+
+// memoryLoginThrottle implements LoginThrottle using in-memory storage
+type memoryLoginThrottle struct {
+	settings LoginThrottleSettings
+	states   map[string]*throttleState
+	mutex    sync.Mutex
+}
+
+// NewMemoryLoginThrottle creates a new in-memory login throttle
+func NewMemoryLoginThrottle(settings LoginThrottleSettings) LoginThrottle {
+	return &memoryLoginThrottle{
+		settings: settings,
+		states:   make(map[string]*throttleState),
+	}
+}
+
+func (t *memoryLoginThrottle) Check(account, ip string, now time.Time) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return max(t.waitTime(accountKey(account), now), t.waitTime(ipKey(ip), now))
+}
+
+func (t *memoryLoginThrottle) RecordFailure(account, ip string, now time.Time) LoginFailureResult {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.prune(now)
+
+	record := FailureRecord{ClientID: account, ClientIP: ip, Timestamp: now}
+	accountState := t.state(accountKey(account))
+	ipState := t.state(ipKey(ip))
+	accountState.failures = append(accountState.failures, record)
+	ipState.failures = append(ipState.failures, record)
+
+	result := LoginFailureResult{
+		AccountFailures: len(accountState.failures),
+		IPFailures:      len(ipState.failures),
+	}
+
+	for _, state := range []*throttleState{accountState, ipState} {
+		if t.settings.LockoutThreshold > 0 && len(state.failures) >= t.settings.LockoutThreshold && !now.Before(state.lockedUntil) {
+			state.lockedUntil = now.Add(t.settings.LockoutDuration)
+			result.Locked = true
+		}
+		if state.lockedUntil.After(result.LockedUntil) {
+			result.LockedUntil = state.lockedUntil
+		}
+	}
+
+	for _, failure := range accountState.failures {
+		if !slices.Contains(result.SourceIPs, failure.ClientIP) {
+			result.SourceIPs = append(result.SourceIPs, failure.ClientIP)
+		}
+	}
+
+	result.RetryAfter = max(t.waitTime(accountKey(account), now), t.waitTime(ipKey(ip), now))
+	return result
+}
+
+func (t *memoryLoginThrottle) RecordSuccess(account string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.states, accountKey(account))
+}
+
+// waitTime returns the remaining lockout or backoff for a key. The caller must hold the lock.
+func (t *memoryLoginThrottle) waitTime(key string, now time.Time) time.Duration {
+	state, ok := t.states[key]
+	if !ok {
+		return 0
+	}
+
+	if now.Before(state.lockedUntil) {
+		return state.lockedUntil.Sub(now)
+	}
+
+	recent := t.recentFailures(state, now)
+	if len(recent) == 0 {
+		return 0
+	}
+
+	nextAttempt := recent[len(recent)-1].Timestamp.Add(t.backoff(len(recent)))
+	if nextAttempt.After(now) {
+		return nextAttempt.Sub(now)
+	}
+	return 0
+}
+
+// backoff returns the delay after the given number of consecutive failures: BaseDelay, 2*BaseDelay, 4*BaseDelay, ...
+func (t *memoryLoginThrottle) backoff(failures int) time.Duration {
+	delay := t.settings.BaseDelay
+	for i := 1; i < failures && delay < t.settings.MaxDelay; i++ {
+		delay *= 2
+	}
+	if t.settings.MaxDelay > 0 && delay > t.settings.MaxDelay {
+		delay = t.settings.MaxDelay
+	}
+	return delay
+}
+
+func (t *memoryLoginThrottle) recentFailures(state *throttleState, now time.Time) []FailureRecord {
+	cutoffTime := now.Add(-t.settings.TimeWindow)
+	for i, failure := range state.failures {
+		if !failure.Timestamp.Before(cutoffTime) {
+			return state.failures[i:]
+		}
+	}
+	return nil
+}
+
+// prune drops failures outside the time window and forgets keys without failures or lockout.
+// The caller must hold the lock.
+func (t *memoryLoginThrottle) prune(now time.Time) {
+	for key, state := range t.states {
+		state.failures = t.recentFailures(state, now)
+		if len(state.failures) == 0 && !now.Before(state.lockedUntil) {
+			delete(t.states, key)
+		}
+	}
+}
+
+// state returns the state of a key, creating it if necessary. The caller must hold the lock.
+func (t *memoryLoginThrottle) state(key string) *throttleState {
+	state, ok := t.states[key]
+	if !ok {
+		state = &throttleState{}
+		t.states[key] = state
+	}
+	return state
+}
+
+func accountKey(account string) string {
+	return "account:" + strings.ToLower(account)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func testLoginThrottleSettings() LoginThrottleSettings {
+	return LoginThrottleSettings{
+		BaseDelay:        time.Second,
+		MaxDelay:         8 * time.Second,
+		LockoutThreshold: 6,
+		LockoutDuration:  15 * time.Minute,
+		TimeWindow:       time.Hour,
+	}
+}
+
+func TestMemoryLoginThrottle_ExponentialBackoff(t *testing.T) {
+	throttle := NewMemoryLoginThrottle(testLoginThrottleSettings())
+	now := time.Now()
+
+	if wait := throttle.Check("admin", "10.0.0.1", now); wait != 0 {
+		t.Fatalf("Expected no wait before any failure, got %v", wait)
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for i, delay := range expected {
+		result := throttle.RecordFailure("admin", "10.0.0.1", now)
+		if result.RetryAfter != delay {
+			t.Errorf("Failure %d: expected retry after %v, got %v", i+1, delay, result.RetryAfter)
+		}
+		if wait := throttle.Check("admin", "10.0.0.1", now.Add(delay-time.Millisecond)); wait <= 0 {
+			t.Errorf("Failure %d: expected attempt before the delay to be rejected", i+1)
+		}
+		now = now.Add(delay)
+		if wait := throttle.Check("admin", "10.0.0.1", now); wait != 0 {
+			t.Errorf("Failure %d: expected attempt after the delay to be accepted, got wait %v", i+1, wait)
+		}
+	}
+}
+
+func TestMemoryLoginThrottle_PerAccountAndIP(t *testing.T) {
+	throttle := NewMemoryLoginThrottle(testLoginThrottleSettings())
+	now := time.Now()
+
+	throttle.RecordFailure("alice", "10.0.0.1", now)
+
+	// The account is throttled from every IP address
+	if wait := throttle.Check("Alice", "10.0.0.2", now); wait == 0 {
+		t.Error("Expected account to be throttled from another IP address")
+	}
+	// The IP address is throttled for every account
+	if wait := throttle.Check("bob", "10.0.0.1", now); wait == 0 {
+		t.Error("Expected IP address to be throttled for another account")
+	}
+	if wait := throttle.Check("bob", "10.0.0.2", now); wait != 0 {
+		t.Errorf("Expected unrelated account and IP address to be accepted, got wait %v", wait)
+	}
+
+	// A successful login clears the account, but not the IP address
+	throttle.RecordSuccess("alice")
+	if wait := throttle.Check("alice", "10.0.0.2", now); wait != 0 {
+		t.Errorf("Expected account to be accepted after success, got wait %v", wait)
+	}
+	if wait := throttle.Check("bob", "10.0.0.1", now); wait == 0 {
+		t.Error("Expected IP address to stay throttled after another account logged in")
+	}
+}
+
+func TestMemoryLoginThrottle_Lockout(t *testing.T) {
+	settings := testLoginThrottleSettings()
+	throttle := NewMemoryLoginThrottle(settings)
+	now := time.Now()
+
+	var result LoginFailureResult
+	for i := range settings.LockoutThreshold {
+		ip := "10.0.0.1"
+		if i%2 == 1 {
+			ip = "10.0.0.2"
+		}
+		result = throttle.RecordFailure("admin", ip, now)
+		if i < settings.LockoutThreshold-1 && result.Locked {
+			t.Fatalf("Expected no lockout after %d failures", i+1)
+		}
+		now = now.Add(result.RetryAfter)
+	}
+
+	if !result.Locked {
+		t.Fatal("Expected lockout at the threshold")
+	}
+	if result.AccountFailures != settings.LockoutThreshold {
+		t.Errorf("Expected %d account failures, got %d", settings.LockoutThreshold, result.AccountFailures)
+	}
+	if len(result.SourceIPs) != 2 {
+		t.Errorf("Expected 2 source IPs, got %v", result.SourceIPs)
+	}
+	if result.RetryAfter != settings.LockoutDuration {
+		t.Errorf("Expected retry after the lockout duration, got %v", result.RetryAfter)
+	}
+
+	if wait := throttle.Check("admin", "10.0.0.3", result.LockedUntil.Add(-time.Second)); wait <= 0 {
+		t.Error("Expected account to stay locked during the lockout")
+	}
+	if wait := throttle.Check("admin", "10.0.0.3", result.LockedUntil); wait != 0 {
+		t.Errorf("Expected lockout to end, got wait %v", wait)
+	}
+}
+
+func TestMemoryLoginThrottle_TimeWindow(t *testing.T) {
+	throttle := NewMemoryLoginThrottle(testLoginThrottleSettings())
+	now := time.Now()
+
+	throttle.RecordFailure("admin", "10.0.0.1", now)
+	throttle.RecordFailure("admin", "10.0.0.1", now.Add(time.Second))
+
+	// Failures outside the time window no longer count towards the backoff
+	result := throttle.RecordFailure("admin", "10.0.0.1", now.Add(2*time.Hour))
+	if result.AccountFailures != 1 || result.RetryAfter != time.Second {
+		t.Errorf("Expected a fresh backoff, got %d failures and retry after %v", result.AccountFailures, result.RetryAfter)
+	}
+}
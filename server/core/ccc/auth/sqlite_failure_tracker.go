@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+// failureRetention is how long failure records are kept for review on the dashboard
+const failureRetention = 7 * 24 * time.Hour
+
+// IPBlockSettings holds configuration for blocking IP addresses after repeated authentication failures
+type IPBlockSettings struct {
+	Threshold     int           // Number of failures from one IP address within the time window that block it (0 to disable)
+	TimeWindow    time.Duration // Time window for counting the failures of one IP address
+	BlockDuration time.Duration // How long a blocked IP address stays blocked
+}
+
+// IPBlock is an IP address that was blocked after repeated authentication failures
+type IPBlock struct {
+	IP           string
+	FailureCount int       // Failures that led to the block
+	BlockedAt    time.Time // Time the block started
+	BlockedUntil time.Time // Time the block ends
+}
+
+// IPBlocklist gives access to blocked IP addresses and the failures behind them
+type IPBlocklist interface {
+	// GetBlock returns the active block of an IP address, or nil if the address is not blocked
+	GetBlock(ip string, now time.Time) (*IPBlock, error)
+	// GetActiveBlocks returns all active blocks, most recent first
+	GetActiveBlocks(now time.Time) ([]*IPBlock, error)
+	// Unblock ends the block of an IP address. Failures before the unblock no longer count towards a new block.
+	Unblock(ip string, now time.Time) error
+	// GetRecentFailures returns up to limit of the most recent authentication failures
+	GetRecentFailures(limit int) ([]FailureRecord, error)
+}
+
+// nopIPBlocklist is a no-operation implementation
+type nopIPBlocklist struct{}
+
+var NopIPBlocklist IPBlocklist = &nopIPBlocklist{}
+
+func (n *nopIPBlocklist) GetBlock(ip string, now time.Time) (*IPBlock, error) {
+	return nil, nil
+}
+
+func (n *nopIPBlocklist) GetActiveBlocks(now time.Time) ([]*IPBlock, error) {
+	return nil, nil
+}
+
+func (n *nopIPBlocklist) Unblock(ip string, now time.Time) error {
+	return nil
+}
+
+func (n *nopIPBlocklist) GetRecentFailures(limit int) ([]FailureRecord, error) {
+	return nil, nil
+}
+
+// SQLiteFailureTracker implements FailureTracker and IPBlocklist using SQLite,
+// so failures and blocks survive a restart and are visible to the dashboard
+type SQLiteFailureTracker struct {
+	logger      logging.Logger
+	db          *sql.DB
+	autoDisable AutoDisableSettings
+	ipBlock     IPBlockSettings
+	mutex       sync.Mutex
+}
+
+// NewSQLiteFailureTracker creates a new SQLite-based failure tracker
+func NewSQLiteFailureTracker(logger logging.Logger, db *sql.DB, autoDisable AutoDisableSettings, ipBlock IPBlockSettings) (*SQLiteFailureTracker, error) {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+	if ipBlock.Threshold > 0 && (ipBlock.TimeWindow <= 0 || ipBlock.BlockDuration <= 0) {
+		return nil, fmt.Errorf("IP blocking needs a positive time window and block duration")
+	}
+
+	tracker := &SQLiteFailureTracker{
+		logger:      logger,
+		db:          db,
+		autoDisable: autoDisable,
+		ipBlock:     ipBlock,
+	}
+	if err := tracker.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return tracker, nil
+}
+
+// createTables ensures that the required tables exist
+func (t *SQLiteFailureTracker) createTables() error {
+	createFailuresTable := `
+	CREATE TABLE IF NOT EXISTS auth_failures (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id TEXT NOT NULL,
+		client_ip TEXT NOT NULL,
+		timestamp TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_auth_failures_client_id ON auth_failures(client_id, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_auth_failures_client_ip ON auth_failures(client_ip, timestamp);`
+
+	if _, err := t.db.Exec(createFailuresTable); err != nil {
+		return err
+	}
+
+	createBlocksTable := `
+	CREATE TABLE IF NOT EXISTS blocked_ips (
+		ip TEXT PRIMARY KEY,
+		failure_count INTEGER NOT NULL,
+		blocked_at TEXT NOT NULL,
+		blocked_until TEXT NOT NULL
+	);`
+
+	_, err := t.db.Exec(createBlocksTable)
+	return err
+}
+
+func (t *SQLiteFailureTracker) ShouldAutoDisable(failureCount int) bool {
+	return t.autoDisable.Threshold > 0 && failureCount >= t.autoDisable.Threshold
+}
+
+// RecordFailure stores the failure, blocks the IP address if it reached the block threshold,
+// and returns the failure count of the client within the time window.
+// Database errors are logged, since failing authentication must not turn into a server error.
+func (t *SQLiteFailureTracker) RecordFailure(clientID string, clientIP string, timestamp time.Time) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	timestamp = timestamp.UTC()
+
+	_, err := t.db.Exec(`INSERT INTO auth_failures (client_id, client_ip, timestamp) VALUES (?, ?, ?)`,
+		clientID, clientIP, db.TimeToString(timestamp))
+	if err != nil {
+		t.logger.Error("Failed to record authentication failure", err)
+		return 0
+	}
+
+	if _, err := t.db.Exec(`DELETE FROM auth_failures WHERE timestamp < ?`, db.TimeToString(timestamp.Add(-failureRetention))); err != nil {
+		t.logger.Error("Failed to prune authentication failures", err)
+	}
+
+	var clientCount int
+	err = t.db.QueryRow(`SELECT COUNT(*) FROM auth_failures WHERE client_id = ? AND timestamp >= ?`, clientID, db.TimeToString(timestamp.Add(-t.autoDisable.TimeWindow))).Scan(&clientCount)
+	if err != nil {
+		t.logger.Error("Failed to count authentication failures", err)
+		return 0
+	}
+
+	if t.ipBlock.Threshold > 0 {
+		if err := t.blockIfNeeded(clientIP, timestamp); err != nil {
+			t.logger.Error("Failed to block IP address", err)
+		}
+	}
+
+	return clientCount
+}
+
+// blockIfNeeded blocks the IP address once its failures since the window start, or since its last block ended,
+// reach the threshold. The caller must hold the lock.
+func (t *SQLiteFailureTracker) blockIfNeeded(clientIP string, now time.Time) error {
+	cutoff := now.Add(-t.ipBlock.TimeWindow)
+	previous, err := t.getBlock(clientIP)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		if previous.BlockedUntil.After(now) {
+			return nil
+		}
+		if previous.BlockedUntil.After(cutoff) {
+			cutoff = previous.BlockedUntil.UTC()
+		}
+	}
+
+	var ipCount int
+	err = t.db.QueryRow(`SELECT COUNT(*) FROM auth_failures WHERE client_ip = ? AND timestamp >= ?`, clientIP, db.TimeToString(cutoff)).Scan(&ipCount)
+	if err != nil {
+		return fmt.Errorf("failed to count failures of IP address: %w", err)
+	}
+	if ipCount < t.ipBlock.Threshold {
+		return nil
+	}
+
+	blockedUntil := now.Add(t.ipBlock.BlockDuration)
+	_, err = t.db.Exec(`
+	INSERT INTO blocked_ips (ip, failure_count, blocked_at, blocked_until) VALUES (?, ?, ?, ?)
+	ON CONFLICT(ip) DO UPDATE SET failure_count = excluded.failure_count, blocked_at = excluded.blocked_at, blocked_until = excluded.blocked_until`,
+		clientIP, ipCount, db.TimeToString(now), db.TimeToString(blockedUntil))
+	if err != nil {
+		return fmt.Errorf("failed to store IP block: %w", err)
+	}
+
+	t.logger.Warn("Blocked IP address after repeated authentication failures", "clientIP", clientIP, "failures", ipCount, "blockedUntil", blockedUntil)
+	return nil
+}
+
+func (t *SQLiteFailureTracker) GetBlock(ip string, now time.Time) (*IPBlock, error) {
+	block, err := t.getBlock(ip)
+	if err != nil || block == nil || !block.BlockedUntil.After(now) {
+		return nil, err
+	}
+	return block, nil
+}
+
+// getBlock returns the most recent block of an IP address, whether or not it is still active
+func (t *SQLiteFailureTracker) getBlock(ip string) (*IPBlock, error) {
+	row := t.db.QueryRow(`SELECT ip, failure_count, blocked_at, blocked_until FROM blocked_ips WHERE ip = ?`, ip)
+	block, err := scanIPBlock(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return block, err
+}
+
+func (t *SQLiteFailureTracker) GetActiveBlocks(now time.Time) ([]*IPBlock, error) {
+	rows, err := t.db.Query(`SELECT ip, failure_count, blocked_at, blocked_until FROM blocked_ips ORDER BY blocked_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocked IP addresses: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []*IPBlock
+	for rows.Next() {
+		block, err := scanIPBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+		if block.BlockedUntil.After(now) {
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks, rows.Err()
+}
+
+func (t *SQLiteFailureTracker) Unblock(ip string, now time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// The row is kept with an end in the past, so only failures after the unblock count towards a new block
+	_, err := t.db.Exec(`UPDATE blocked_ips SET blocked_until = ? WHERE ip = ?`, db.TimeToString(now.UTC()), ip)
+	if err != nil {
+		return fmt.Errorf("failed to unblock IP address: %w", err)
+	}
+	return nil
+}
+
+func (t *SQLiteFailureTracker) GetRecentFailures(limit int) ([]FailureRecord, error) {
+	rows, err := t.db.Query(`SELECT client_id, client_ip, timestamp FROM auth_failures ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query authentication failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []FailureRecord
+	for rows.Next() {
+		var failure FailureRecord
+		var timestampStr string
+		if err := rows.Scan(&failure.ClientID, &failure.ClientIP, &timestampStr); err != nil {
+			return nil, fmt.Errorf("failed to scan authentication failure row: %w", err)
+		}
+
+		failure.Timestamp, err = db.StringToTime(timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+
+		failures = append(failures, failure)
+	}
+
+	return failures, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanIPBlock(row rowScanner) (*IPBlock, error) {
+	block := &IPBlock{}
+	var blockedAtStr, blockedUntilStr string
+	if err := row.Scan(&block.IP, &block.FailureCount, &blockedAtStr, &blockedUntilStr); err != nil {
+		return nil, err
+	}
+
+	var err error
+	block.BlockedAt, err = db.StringToTime(blockedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse blocked_at timestamp: %w", err)
+	}
+
+	block.BlockedUntil, err = db.StringToTime(blockedUntilStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse blocked_until timestamp: %w", err)
+	}
+
+	return block, nil
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+func newTestSQLiteFailureTracker(t *testing.T, autoDisable AutoDisableSettings, ipBlock IPBlockSettings) *SQLiteFailureTracker {
+	t.Helper()
+
+	database, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	tracker, err := NewSQLiteFailureTracker(nil, database, autoDisable, ipBlock)
+	if err != nil {
+		t.Fatalf("Failed to create failure tracker: %v", err)
+	}
+	return tracker
+}
+
+func TestSQLiteFailureTracker_RecordFailure(t *testing.T) {
+	tracker := newTestSQLiteFailureTracker(t, AutoDisableSettings{Threshold: 3, TimeWindow: time.Hour}, IPBlockSettings{})
+	now := time.Now()
+
+	for i := 1; i <= 3; i++ {
+		count := tracker.RecordFailure("test-client", "192.168.1.100", now.Add(time.Duration(i)*time.Minute))
+		if count != i {
+			t.Errorf("Expected failure count %d, got %d", i, count)
+		}
+	}
+
+	// Failures of other client IDs, including unknown ones, are counted separately
+	if count := tracker.RecordFailure("unknown-client", "192.168.1.100", now.Add(4*time.Minute)); count != 1 {
+		t.Errorf("Expected failure count 1 for other client, got %d", count)
+	}
+
+	// Failures outside the time window are not counted
+	if count := tracker.RecordFailure("test-client", "192.168.1.100", now.Add(2*time.Hour)); count != 1 {
+		t.Errorf("Expected failure count 1 after time window, got %d", count)
+	}
+
+	if !tracker.ShouldAutoDisable(3) {
+		t.Error("Expected auto-disable at threshold")
+	}
+
+	failures, err := tracker.GetRecentFailures(10)
+	if err != nil {
+		t.Fatalf("Failed to get recent failures: %v", err)
+	}
+	if len(failures) != 5 {
+		t.Fatalf("Expected 5 recorded failures, got %d", len(failures))
+	}
+	if failures[1].ClientID != "unknown-client" {
+		t.Errorf("Expected failures newest first, got %s second", failures[1].ClientID)
+	}
+}
+
+func TestSQLiteFailureTracker_BlocksIP(t *testing.T) {
+	tracker := newTestSQLiteFailureTracker(t,
+		AutoDisableSettings{TimeWindow: time.Hour},
+		IPBlockSettings{Threshold: 3, TimeWindow: time.Hour, BlockDuration: 30 * time.Minute})
+	now := time.Now()
+
+	// Random client IDs from the same IP address add up
+	tracker.RecordFailure("guess-1", "10.0.0.1", now)
+	tracker.RecordFailure("guess-2", "10.0.0.1", now.Add(time.Second))
+	if block, _ := tracker.GetBlock("10.0.0.1", now.Add(time.Second)); block != nil {
+		t.Fatal("Expected IP not to be blocked below threshold")
+	}
+
+	tracker.RecordFailure("guess-3", "10.0.0.1", now.Add(2*time.Second))
+	block, err := tracker.GetBlock("10.0.0.1", now.Add(3*time.Second))
+	if err != nil {
+		t.Fatalf("Failed to get block: %v", err)
+	}
+	if block == nil {
+		t.Fatal("Expected IP to be blocked at threshold")
+	}
+	if block.FailureCount != 3 {
+		t.Errorf("Expected failure count 3, got %d", block.FailureCount)
+	}
+
+	if block, _ := tracker.GetBlock("10.0.0.2", now.Add(3*time.Second)); block != nil {
+		t.Error("Expected other IP not to be blocked")
+	}
+
+	blocks, err := tracker.GetActiveBlocks(now.Add(3 * time.Second))
+	if err != nil {
+		t.Fatalf("Failed to get active blocks: %v", err)
+	}
+	if len(blocks) != 1 {
+		t.Errorf("Expected 1 active block, got %d", len(blocks))
+	}
+
+	// The block expires after its duration
+	if block, _ := tracker.GetBlock("10.0.0.1", now.Add(31*time.Minute)); block != nil {
+		t.Error("Expected block to expire")
+	}
+}
+
+func TestSQLiteFailureTracker_Unblock(t *testing.T) {
+	tracker := newTestSQLiteFailureTracker(t,
+		AutoDisableSettings{TimeWindow: time.Hour},
+		IPBlockSettings{Threshold: 2, TimeWindow: time.Hour, BlockDuration: time.Hour})
+	now := time.Now()
+
+	tracker.RecordFailure("client", "10.0.0.1", now)
+	tracker.RecordFailure("client", "10.0.0.1", now.Add(time.Second))
+	if block, _ := tracker.GetBlock("10.0.0.1", now.Add(2*time.Second)); block == nil {
+		t.Fatal("Expected IP to be blocked")
+	}
+
+	if err := tracker.Unblock("10.0.0.1", now.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to unblock: %v", err)
+	}
+	if block, _ := tracker.GetBlock("10.0.0.1", now.Add(time.Minute)); block != nil {
+		t.Fatal("Expected IP to be unblocked")
+	}
+
+	// Failures from before the unblock no longer count
+	tracker.RecordFailure("client", "10.0.0.1", now.Add(2*time.Minute))
+	if block, _ := tracker.GetBlock("10.0.0.1", now.Add(2*time.Minute)); block != nil {
+		t.Error("Expected a single failure after unblock not to block the IP again")
+	}
+
+	tracker.RecordFailure("client", "10.0.0.1", now.Add(3*time.Minute))
+	if block, _ := tracker.GetBlock("10.0.0.1", now.Add(3*time.Minute)); block == nil {
+		t.Error("Expected IP to be blocked again after reaching the threshold")
+	}
+}
+
+func TestSQLiteFailureTracker_BlocksIPWithinItsOwnTimeWindow(t *testing.T) {
+	tracker := newTestSQLiteFailureTracker(t,
+		AutoDisableSettings{Threshold: 10, TimeWindow: time.Hour},
+		IPBlockSettings{Threshold: 3, TimeWindow: 10 * time.Minute, BlockDuration: time.Hour})
+	now := time.Now()
+
+	// Three failures within the client time window, but not within the IP time window
+	for i := 0; i < 3; i++ {
+		if count := tracker.RecordFailure("client", "10.0.0.1", now.Add(time.Duration(i)*20*time.Minute)); count != i+1 {
+			t.Errorf("Expected failure count %d within the client time window, got %d", i+1, count)
+		}
+	}
+	if block, _ := tracker.GetBlock("10.0.0.1", now.Add(40*time.Minute)); block != nil {
+		t.Fatal("Expected failures outside the IP time window not to block the IP")
+	}
+
+	tracker.RecordFailure("client", "10.0.0.1", now.Add(41*time.Minute))
+	tracker.RecordFailure("client", "10.0.0.1", now.Add(42*time.Minute))
+	block, _ := tracker.GetBlock("10.0.0.1", now.Add(42*time.Minute))
+	if block == nil {
+		t.Fatal("Expected IP to be blocked after reaching the threshold within the IP time window")
+	}
+	if block.FailureCount != 3 {
+		t.Errorf("Expected failure count 3, got %d", block.FailureCount)
+	}
+}
+
+func TestNewSQLiteFailureTracker_RejectsIPBlockWithoutDuration(t *testing.T) {
+	database, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	invalid := []IPBlockSettings{
+		{Threshold: 3, TimeWindow: time.Hour},    // Blocks that end as soon as they start
+		{Threshold: 3, BlockDuration: time.Hour}, // No failures to count
+		{Threshold: 3, TimeWindow: time.Hour, BlockDuration: -time.Minute},
+	}
+	for _, ipBlock := range invalid {
+		if _, err := NewSQLiteFailureTracker(nil, database, AutoDisableSettings{}, ipBlock); err == nil {
+			t.Errorf("Expected IP block settings %+v to be rejected", ipBlock)
+		}
+	}
+
+	// Without a threshold, IP blocking is disabled and needs no duration
+	if _, err := NewSQLiteFailureTracker(nil, database, AutoDisableSettings{}, IPBlockSettings{}); err != nil {
+		t.Errorf("Expected disabled IP blocking to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package codes
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// Alphabet holds the characters of generated codes. It has no ambiguous characters like 0/O and 1/I, and its 32
+// characters divide 256, so that random bytes map onto it without bias.
+const Alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Generate returns a random code of the given number of characters from the Alphabet, split by dashes into groups of
+// groupSize characters, e.g. "ABCD-EFGH-JKLM"
+func Generate(length int, groupSize int) (string, error) {
+	random := make([]byte, length)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range random {
+		if i > 0 && i%groupSize == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(Alphabet[int(b)%len(Alphabet)])
+	}
+
+	return code.String(), nil
+}
+
+// Normalize upper-cases a code and strips separators and whitespace, so that codes typed with different formatting
+// match
+func Normalize(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
@@ -0,0 +1,42 @@
+package codes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate_FormatsGroups(t *testing.T) {
+	code, err := Generate(12, 4)
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	groups := strings.Split(code, "-")
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %q", code)
+	}
+	for _, group := range groups {
+		if len(group) != 4 {
+			t.Errorf("Expected groups of 4 characters, got %q", code)
+		}
+		for _, r := range group {
+			if !strings.ContainsRune(Alphabet, r) {
+				t.Errorf("Unexpected character %q in %q", r, code)
+			}
+		}
+	}
+
+	other, err := Generate(12, 4)
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	if other == code {
+		t.Error("Expected different codes")
+	}
+}
+
+func TestNormalize_IgnoresCaseAndSeparators(t *testing.T) {
+	if normalized := Normalize(" abcd-EFGH\tjk lm\n"); normalized != "ABCDEFGHJKLM" {
+		t.Errorf("Unexpected normalized code: %q", normalized)
+	}
+}
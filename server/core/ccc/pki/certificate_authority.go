@@ -0,0 +1,321 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+const (
+	caCertificateFile = "ca.crt"
+	caPrivateKeyFile  = "ca.key"
+	caValidity        = 10 * 365 * 24 * time.Hour
+)
+
+// IssuedCertificate is a certificate issued by the CA together with its private key
+type IssuedCertificate struct {
+	SerialNumber   string    // Serial number of the certificate (hex encoded)
+	CertificatePEM []byte    // PEM-encoded certificate
+	PrivateKeyPEM  []byte    // PEM-encoded private key
+	ExpiresAt      time.Time // Time after which the certificate is no longer valid
+}
+
+// CertificateAuthority issues the certificates used for TLS between capture clients and the capture server
+type CertificateAuthority interface {
+	// CertificatePEM returns the PEM-encoded CA certificate, which clients use as their CA bundle
+	CertificatePEM() []byte
+	// CertPool returns a pool containing only the CA certificate
+	CertPool() *x509.CertPool
+	// Fingerprint returns the SHA-256 fingerprint of the CA certificate (hex encoded), which clients can pin before they have the CA bundle
+	Fingerprint() string
+	// IssueClientCertificate issues a certificate for TLS client authentication with the given common name
+	IssueClientCertificate(commonName string, validity time.Duration) (*IssuedCertificate, error)
+	// IssueServerCertificate issues a certificate for a TLS server reachable under the given host names or IP addresses
+	IssueServerCertificate(hosts []string, validity time.Duration) (*tls.Certificate, error)
+}
+
+type fileCertificateAuthority struct {
+	logger         logging.Logger
+	certificate    *x509.Certificate
+	certificatePEM []byte
+	privateKey     *ecdsa.PrivateKey
+}
+
+// LoadOrCreateCertificateAuthority loads the CA certificate and key from the given directory.
+// If the directory does not contain a CA yet, a new one is generated and stored there.
+func LoadOrCreateCertificateAuthority(logger logging.Logger, directory string) (*fileCertificateAuthority, error) {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	certPath := filepath.Join(directory, caCertificateFile)
+	keyPath := filepath.Join(directory, caPrivateKeyFile)
+
+	ca, err := loadCertificateAuthority(certPath, keyPath)
+	if err == nil {
+		ca.logger = logger
+		return ca, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create CA directory: %w", err)
+	}
+
+	ca, err = createCertificateAuthority(certPath, keyPath)
+	if errors.Is(err, os.ErrExist) {
+		// Another CryoSpy process created the CA in the meantime
+		return loadCertificateAuthority(certPath, keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ca.logger = logger
+	logger.Info("Created certificate authority", "directory", directory)
+	return ca, nil
+}
+
+func loadCertificateAuthority(certPath, keyPath string) (*fileCertificateAuthority, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid CA certificate in %s", certPath)
+	}
+	certificate, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("invalid CA private key in %s", keyPath)
+	}
+	privateKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA private key: %w", err)
+	}
+
+	return &fileCertificateAuthority{
+		logger:         logging.NopLogger,
+		certificate:    certificate,
+		certificatePEM: certPEM,
+		privateKey:     privateKey,
+	}, nil
+}
+
+func createCertificateAuthority(certPath, keyPath string) (*fileCertificateAuthority, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA private key: %w", err)
+	}
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: "CryoSpy CA", Organization: []string{"CryoSpy"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	keyPEM, err := encodePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	// The key is written first and exclusively, so that concurrent processes do not overwrite each other's CA
+	if err := writeNewFile(keyPath, keyPEM, 0600); err != nil {
+		return nil, err
+	}
+	if err := writeNewFile(certPath, certPEM, 0644); err != nil {
+		return nil, err
+	}
+
+	return &fileCertificateAuthority{
+		certificate:    certificate,
+		certificatePEM: certPEM,
+		privateKey:     privateKey,
+	}, nil
+}
+
+func (ca *fileCertificateAuthority) CertificatePEM() []byte {
+	return ca.certificatePEM
+}
+
+func (ca *fileCertificateAuthority) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.certificate)
+	return pool
+}
+
+func (ca *fileCertificateAuthority) Fingerprint() string {
+	fingerprint := sha256.Sum256(ca.certificate.Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+func (ca *fileCertificateAuthority) IssueClientCertificate(commonName string, validity time.Duration) (*IssuedCertificate, error) {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName, Organization: []string{"CryoSpy"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, privateKey, err := ca.issue(template, validity)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := encodePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ca.logger.Info("Issued client certificate", "commonName", commonName, "serial", SerialNumber(template))
+	return &IssuedCertificate{
+		SerialNumber:   SerialNumber(template),
+		CertificatePEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		PrivateKeyPEM:  keyPEM,
+		ExpiresAt:      template.NotAfter,
+	}, nil
+}
+
+func (ca *fileCertificateAuthority) IssueServerCertificate(hosts []string, validity time.Duration) (*tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("at least one host is required for a server certificate")
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0], Organization: []string{"CryoSpy"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, privateKey, err := ca.issue(template, validity)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server certificate: %w", err)
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der, ca.certificate.Raw},
+		PrivateKey:  privateKey,
+		Leaf:        leaf,
+	}, nil
+}
+
+// issue generates a key pair and signs a certificate for it based on the template.
+// The serial number and validity period of the template are filled in.
+func (ca *fileCertificateAuthority) issue(template *x509.Certificate, validity time.Duration) ([]byte, *ecdsa.PrivateKey, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template.SerialNumber = serialNumber
+	template.NotBefore = now.Add(-time.Hour) // Tolerate clocks that are slightly behind
+	template.NotAfter = now.Add(validity)
+	if template.NotAfter.After(ca.certificate.NotAfter) {
+		template.NotAfter = ca.certificate.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &privateKey.PublicKey, ca.privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return der, privateKey, nil
+}
+
+// SerialNumber returns the serial number of a certificate in the hex encoding used by CryoSpy
+func SerialNumber(certificate *x509.Certificate) string {
+	return certificate.SerialNumber.Text(16)
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serialNumber, nil
+}
+
+func encodePrivateKey(privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// writeNewFile writes data to a file that must not exist yet
+func writeNewFile(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
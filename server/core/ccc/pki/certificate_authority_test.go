@@ -0,0 +1,104 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func parseCertificatePEM(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("Failed to decode certificate PEM")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return certificate
+}
+
+func TestLoadOrCreateCertificateAuthority_ReusesExistingCA(t *testing.T) {
+	dir := t.TempDir()
+
+	created, err := LoadOrCreateCertificateAuthority(nil, dir)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	loaded, err := LoadOrCreateCertificateAuthority(nil, dir)
+	if err != nil {
+		t.Fatalf("Failed to load CA: %v", err)
+	}
+
+	if !bytes.Equal(created.CertificatePEM(), loaded.CertificatePEM()) {
+		t.Error("Expected the stored CA to be loaded instead of creating a new one")
+	}
+}
+
+func TestCertificateAuthority_IssueClientCertificate(t *testing.T) {
+	ca, err := LoadOrCreateCertificateAuthority(nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	issued, err := ca.IssueClientCertificate("camera-1", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue client certificate: %v", err)
+	}
+
+	certificate := parseCertificatePEM(t, issued.CertificatePEM)
+	if certificate.Subject.CommonName != "camera-1" {
+		t.Errorf("Expected common name camera-1, got %s", certificate.Subject.CommonName)
+	}
+	if SerialNumber(certificate) != issued.SerialNumber {
+		t.Errorf("Expected serial %s, got %s", issued.SerialNumber, SerialNumber(certificate))
+	}
+
+	_, err = certificate.Verify(x509.VerifyOptions{
+		Roots:     ca.CertPool(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Errorf("Expected client certificate to verify against the CA: %v", err)
+	}
+
+	other, err := LoadOrCreateCertificateAuthority(nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create second CA: %v", err)
+	}
+	_, err = certificate.Verify(x509.VerifyOptions{
+		Roots:     other.CertPool(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err == nil {
+		t.Error("Expected client certificate not to verify against another CA")
+	}
+}
+
+func TestCertificateAuthority_IssueServerCertificate(t *testing.T) {
+	ca, err := LoadOrCreateCertificateAuthority(nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	serverCert, err := ca.IssueServerCertificate([]string{"cryospy.local", "127.0.0.1"}, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue server certificate: %v", err)
+	}
+
+	for _, host := range []string{"cryospy.local", "127.0.0.1"} {
+		_, err := serverCert.Leaf.Verify(x509.VerifyOptions{Roots: ca.CertPool(), DNSName: host})
+		if err != nil {
+			t.Errorf("Expected server certificate to be valid for %s: %v", host, err)
+		}
+	}
+
+	if _, err := ca.IssueServerCertificate(nil, time.Hour); err == nil {
+		t.Error("Expected an error when no hosts are given")
+	}
+}
@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"slices"
+	"time"
+)
+
+// ArmMode is the system-wide mode that switches the behaviour of all clients at once
+type ArmMode string
+
+const (
+	ArmModeHome ArmMode = "home" // Somebody is at home, e.g. indoor cameras can stop recording
+	ArmModeAway ArmMode = "away" // Nobody is at home, all cameras are on watch
+)
+
+// DefaultArmMode is the mode in effect before the mode has been changed for the first time
+const DefaultArmMode = ArmModeAway
+
+var supportedArmModes = []ArmMode{ArmModeHome, ArmModeAway}
+
+// SupportedArmModes returns the arm modes that can be set
+func SupportedArmModes() []ArmMode {
+	return slices.Clone(supportedArmModes)
+}
+
+// ArmBehavior describes what a client does while an arm mode is active
+type ArmBehavior struct {
+	RecordingMode           RecordingMode // Recording mode forced while the arm mode is active, empty to keep the regular settings and schedule
+	MuteMotionNotifications bool          // Suppresses motion notifications for the client while the arm mode is active
+}
+
+// ArmState is the currently active arm mode
+type ArmState struct {
+	Mode      ArmMode   // Active arm mode
+	ChangedAt time.Time // Time of the last change, zero if the mode has never been changed
+	ChangedBy string    // Dashboard user or "api" that changed the mode last
+}
+
+// ArmBehavior returns the behaviour of the client in the given arm mode
+func (c *Client) ArmBehavior(mode ArmMode) ArmBehavior {
+	if mode == ArmModeHome {
+		return c.HomeBehavior
+	}
+	return c.AwayBehavior
+}
+
+// validateArmBehavior checks the behaviour of a client in one arm mode
+func validateArmBehavior(mode ArmMode, behavior ArmBehavior) error {
+	if behavior.RecordingMode != "" && !slices.Contains(supportedRecordingModes, behavior.RecordingMode) {
+		return NewClientValidationError("unsupported recording mode for arm mode " + string(mode))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package clients
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type ArmStateRepository interface {
+	// Get retrieves the stored arm state, or nil if the mode has never been changed
+	Get(ctx context.Context) (*ArmState, error)
+	// Save replaces the stored arm state
+	Save(ctx context.Context, state *ArmState) error
+}
+
+// SQLiteArmStateRepository implements ArmStateRepository using SQLite
+type SQLiteArmStateRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteArmStateRepository creates a new SQLite-based ArmStateRepository
+func NewSQLiteArmStateRepository(db *sql.DB) (*SQLiteArmStateRepository, error) {
+	repo := &SQLiteArmStateRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist. The table holds a single row.
+func (r *SQLiteArmStateRepository) createTables() error {
+	createArmStateTable := `
+	CREATE TABLE IF NOT EXISTS arm_state (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		mode TEXT NOT NULL,
+		changed_at TEXT NOT NULL,
+		changed_by TEXT NOT NULL DEFAULT ''
+	);`
+
+	_, err := r.db.Exec(createArmStateTable)
+	return err
+}
+
+// Get retrieves the stored arm state, or nil if the mode has never been changed
+func (r *SQLiteArmStateRepository) Get(ctx context.Context) (*ArmState, error) {
+	query := `SELECT mode, changed_at, changed_by FROM arm_state WHERE id = 1`
+
+	state := &ArmState{}
+	var changedAtStr string
+	err := r.db.QueryRowContext(ctx, query).Scan(&state.Mode, &changedAtStr, &state.ChangedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get arm state: %w", err)
+	}
+
+	state.ChangedAt, err = db.StringToTime(changedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse changed_at timestamp: %w", err)
+	}
+
+	return state, nil
+}
+
+// Save replaces the stored arm state
+func (r *SQLiteArmStateRepository) Save(ctx context.Context, state *ArmState) error {
+	query := `
+	INSERT INTO arm_state (id, mode, changed_at, changed_by) VALUES (1, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET mode = excluded.mode, changed_at = excluded.changed_at, changed_by = excluded.changed_by`
+
+	_, err := r.db.ExecContext(ctx, query, state.Mode, db.TimeToString(state.ChangedAt), state.ChangedBy)
+	if err != nil {
+		return fmt.Errorf("failed to save arm state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package clients
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+// ArmModeService switches the system-wide arm mode and manages how each client behaves in it.
+// The dashboard and the capture server share the state through the database, so a change reaches
+// the notifiers right away and the cameras with their next settings sync.
+type ArmModeService interface {
+	// GetArmState returns the active arm mode
+	GetArmState() (*ArmState, error)
+	// SetArmMode activates an arm mode. changedBy names the user or integration that made the change.
+	SetArmMode(mode ArmMode, changedBy string) (*ArmState, error)
+	// UpdateClientArmBehavior replaces the behaviour of a client in the Home and Away modes
+	UpdateClientArmBehavior(clientID string, home ArmBehavior, away ArmBehavior) error
+	// MotionNotificationsEnabled reports whether motion notifications are sent for a client in the active arm mode
+	MotionNotificationsEnabled(clientID string) bool
+}
+
+type armModeService struct {
+	logger     logging.Logger
+	stateRepo  ArmStateRepository
+	clientRepo ClientRepository
+}
+
+func NewArmModeService(logger logging.Logger, stateRepo ArmStateRepository, clientRepo ClientRepository) *armModeService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &armModeService{
+		logger:     logger,
+		stateRepo:  stateRepo,
+		clientRepo: clientRepo,
+	}
+}
+
+func (s *armModeService) GetArmState() (*ArmState, error) {
+	state, err := s.stateRepo.Get(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to retrieve arm state", err)
+		return nil, err
+	}
+	if state == nil {
+		return &ArmState{Mode: DefaultArmMode}, nil
+	}
+	return state, nil
+}
+
+func (s *armModeService) SetArmMode(mode ArmMode, changedBy string) (*ArmState, error) {
+	if !slices.Contains(supportedArmModes, mode) {
+		return nil, NewClientValidationError("unsupported arm mode: " + string(mode))
+	}
+
+	state := &ArmState{
+		Mode:      mode,
+		ChangedAt: time.Now().UTC(),
+		ChangedBy: changedBy,
+	}
+
+	if err := s.stateRepo.Save(context.Background(), state); err != nil {
+		s.logger.Error("Failed to save arm state", err)
+		return nil, err
+	}
+
+	s.logger.Info("Arm mode changed", "mode", mode, "by", changedBy)
+	return state, nil
+}
+
+func (s *armModeService) UpdateClientArmBehavior(clientID string, home ArmBehavior, away ArmBehavior) error {
+	if err := validateArmBehavior(ArmModeHome, home); err != nil {
+		return err
+	}
+	if err := validateArmBehavior(ArmModeAway, away); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	client, err := s.clientRepo.GetByID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return err
+	}
+	if client == nil {
+		return NewClientNotFoundError(clientID)
+	}
+
+	client.HomeBehavior = home
+	client.AwayBehavior = away
+	client.UpdatedAt = time.Now().UTC()
+
+	if err := s.clientRepo.Update(ctx, client); err != nil {
+		s.logger.Error("Failed to update client arm behaviour", err)
+		return err
+	}
+
+	s.logger.Info("Updated client arm behaviour", "id", clientID)
+	return nil
+}
+
+// MotionNotificationsEnabled implements notifications.MotionNotificationPolicy. If the state cannot be read,
+// notifications are sent, since a missed alert is worse than an unwanted one.
+func (s *armModeService) MotionNotificationsEnabled(clientID string) bool {
+	state, err := s.GetArmState()
+	if err != nil {
+		return true
+	}
+
+	client, err := s.clientRepo.GetByID(context.Background(), clientID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return true
+	}
+	if client == nil {
+		return true
+	}
+
+	return !client.ArmBehavior(state.Mode).MuteMotionNotifications
+}
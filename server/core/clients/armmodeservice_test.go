@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func setupTestArmModeService(t *testing.T) (*armModeService, *Client) {
+	t.Helper()
+
+	repo, cleanup := setupTestClientRepo(t)
+	t.Cleanup(cleanup)
+
+	stateRepo, err := NewSQLiteArmStateRepository(repo.db)
+	if err != nil {
+		t.Fatalf("Failed to create arm state repository: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	return NewArmModeService(nil, stateRepo, repo), client
+}
+
+func TestArmModeService_SetArmMode(t *testing.T) {
+	service, _ := setupTestArmModeService(t)
+
+	state, err := service.GetArmState()
+	if err != nil {
+		t.Fatalf("Failed to get arm state: %v", err)
+	}
+	if state.Mode != DefaultArmMode {
+		t.Errorf("Expected default mode %s, got %s", DefaultArmMode, state.Mode)
+	}
+
+	if _, err := service.SetArmMode(ArmModeHome, "admin"); err != nil {
+		t.Fatalf("Failed to set arm mode: %v", err)
+	}
+
+	state, _ = service.GetArmState()
+	if state.Mode != ArmModeHome || state.ChangedBy != "admin" || state.ChangedAt.IsZero() {
+		t.Errorf("Unexpected arm state: %+v", state)
+	}
+
+	if _, err := service.SetArmMode("vacation", "admin"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for unknown mode, got %v", err)
+	}
+}
+
+func TestArmModeService_MotionNotificationsEnabled(t *testing.T) {
+	service, client := setupTestArmModeService(t)
+
+	if !service.MotionNotificationsEnabled(client.ID) {
+		t.Error("Expected motion notifications to be enabled by default")
+	}
+
+	home := ArmBehavior{RecordingMode: RecordingModeOff, MuteMotionNotifications: true}
+	if err := service.UpdateClientArmBehavior(client.ID, home, ArmBehavior{}); err != nil {
+		t.Fatalf("Failed to update arm behaviour: %v", err)
+	}
+
+	if !service.MotionNotificationsEnabled(client.ID) {
+		t.Error("Expected motion notifications to be enabled while away")
+	}
+
+	service.SetArmMode(ArmModeHome, "api")
+	if service.MotionNotificationsEnabled(client.ID) {
+		t.Error("Expected motion notifications to be muted while home")
+	}
+
+	stored, _ := service.clientRepo.GetByID(t.Context(), client.ID)
+	if stored.ArmBehavior(ArmModeHome) != home {
+		t.Errorf("Expected home behaviour %+v, got %+v", home, stored.HomeBehavior)
+	}
+
+	err := service.UpdateClientArmBehavior(client.ID, ArmBehavior{RecordingMode: "sometimes"}, ArmBehavior{})
+	if !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for unknown recording mode, got %v", err)
+	}
+}
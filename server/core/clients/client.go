@@ -1,9 +1,15 @@
 package clients
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 type Client struct {
 	ID                    string    // Unique identifier for the client
+	DisplayName           string    // Friendly name shown instead of the ID (e.g. "Front Door"), empty to show the ID
+	Location              string    // Where the camera is installed (e.g. "Garage"), empty if not set
+	Notes                 string    // Free-form notes about the client, e.g. about its hardware or mounting
 	SecretHash            string    // Hashed secret for authentication (base 64 encoded)
 	SecretSalt            string    // Salt used for hashing the secret (base 64 encoded)
 	CreatedAt             time.Time // Timestamp when the client was created
@@ -36,4 +42,68 @@ type Client struct {
 	// Recording settings
 	CaptureCodec     string  // Video codec to use for raw capture (e.g., "MJPG")
 	CaptureFrameRate float64 // Frame rate for video capture
+
+	// Previous credentials, kept after a secret rotation until the grace period ends
+	PreviousSecretHash        string     // Hashed previous secret (base 64 encoded), empty if there is none
+	PreviousSecretSalt        string     // Salt used for hashing the previous secret (base 64 encoded)
+	PreviousEncryptedMek      string     // MEK encrypted with key derived from the previous secret (base 64 encoded)
+	PreviousKeyDerivationSalt string     // Salt used for deriving the encryption key from the previous secret (base 64 encoded)
+	PreviousSecretExpiresAt   *time.Time // Time after which the previous secret is no longer accepted
+
+	// Client certificates for mutual TLS, identified by their serial numbers (hex encoded)
+	CertificateSerial         string // Serial number of the current client certificate, empty if none was issued
+	PreviousCertificateSerial string // Serial number of the certificate replaced during the secret grace period, empty if there is none
+
+	// Group membership
+	GroupID           string   // ID of the client group whose settings template the client inherits, empty if the client is not in a group
+	SettingsOverrides []string // Keys of the settings (see SettingKeys) that the client keeps instead of inheriting them from its group
+
+	// Recording schedule
+	Timezone string          // IANA timezone in which the schedule is evaluated (e.g. "Europe/Berlin"), empty for the local time of the capture device
+	Schedule []ScheduleEntry // Weekly time ranges with their own recording mode, empty if the client always uses its regular settings
+
+	// Settings history
+	SettingsVersion int // Version of the current settings in the settings history, 0 if no version has been recorded yet
+
+	// Behaviour in the system-wide arm modes
+	HomeBehavior ArmBehavior // Behaviour while the arm mode is Home
+	AwayBehavior ArmBehavior // Behaviour while the arm mode is Away
+
+	// Archiving
+	ArchivedAt *time.Time // Time at which the client was deleted and its clips were kept in this archived record, nil for regular clients
+}
+
+// Name returns the display name of the client, or its ID if it has none
+func (c *Client) Name() string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+	return c.ID
+}
+
+// IsArchived reports whether the client is an archived record that only holds the clips of a deleted client
+func (c *Client) IsArchived() bool {
+	return c.ArchivedAt != nil
+}
+
+// TimeLocation returns the timezone of the client, or UTC if it has none or it is unknown
+func (c *Client) TimeLocation() *time.Location {
+	if c.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// HasActivePreviousSecret reports whether the previous secret is still accepted at the given time
+func (c *Client) HasActivePreviousSecret(now time.Time) bool {
+	return c.PreviousSecretHash != "" && c.PreviousSecretExpiresAt != nil && now.Before(*c.PreviousSecretExpiresAt)
+}
+
+// IsOverridden reports whether the client keeps its own value for a setting instead of inheriting it from its group
+func (c *Client) IsOverridden(key string) bool {
+	return slices.Contains(c.SettingsOverrides, key)
 }
@@ -0,0 +1,106 @@
+package clients
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/ccc/pki"
+)
+
+// ClientCertificateService issues the certificates that clients present for mutual TLS and checks them on later connections
+type ClientCertificateService interface {
+	// IssueCertificate issues a new certificate for a client, which replaces the client's current certificate.
+	// While the client's previous secret is in its grace period, the replaced certificate stays valid as well.
+	IssueCertificate(clientID string) (*pki.IssuedCertificate, error)
+	// VerifyCertificate returns the client a certificate (already verified against the CA) was issued to.
+	// The certificate is rejected if the client is disabled or the certificate has been replaced.
+	VerifyCertificate(certificate *x509.Certificate) (*Client, error)
+	// CACertificatePEM returns the PEM-encoded CA certificate that clients use to verify the server
+	CACertificatePEM() []byte
+}
+
+type clientCertificateService struct {
+	logger     logging.Logger
+	clientRepo ClientRepository
+	ca         pki.CertificateAuthority
+	validity   time.Duration
+}
+
+// NewClientCertificateService creates a ClientCertificateService that issues certificates with the given validity
+func NewClientCertificateService(logger logging.Logger, clientRepo ClientRepository, ca pki.CertificateAuthority, validity time.Duration) *clientCertificateService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &clientCertificateService{
+		logger:     logger,
+		clientRepo: clientRepo,
+		ca:         ca,
+		validity:   validity,
+	}
+}
+
+func (s *clientCertificateService) IssueCertificate(clientID string) (*pki.IssuedCertificate, error) {
+	ctx := context.Background()
+
+	client, err := s.clientRepo.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, NewClientNotFoundError(clientID)
+	}
+
+	issued, err := s.ca.IssueClientCertificate(clientID, s.validity)
+	if err != nil {
+		s.logger.Error("Failed to issue client certificate", err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	// Follow the secret: a device that may still use its old secret may also still use its old certificate
+	if client.HasActivePreviousSecret(now) {
+		client.PreviousCertificateSerial = client.CertificateSerial
+	} else {
+		client.PreviousCertificateSerial = ""
+	}
+	client.CertificateSerial = issued.SerialNumber
+	client.UpdatedAt = now
+
+	if err := s.clientRepo.Update(ctx, client); err != nil {
+		s.logger.Error("Failed to save client certificate serial", err)
+		return nil, err
+	}
+
+	s.logger.Info("Issued client certificate", "clientId", clientID, "serial", issued.SerialNumber, "expiresAt", issued.ExpiresAt)
+	return issued, nil
+}
+
+func (s *clientCertificateService) VerifyCertificate(certificate *x509.Certificate) (*Client, error) {
+	clientID := certificate.Subject.CommonName
+
+	client, err := s.clientRepo.GetByID(context.Background(), clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || client.IsDisabled {
+		return nil, NewClientVerificationError(clientID)
+	}
+
+	serial := pki.SerialNumber(certificate)
+	if serial == client.CertificateSerial {
+		return client, nil
+	}
+	if serial == client.PreviousCertificateSerial && client.HasActivePreviousSecret(time.Now().UTC()) {
+		return client, nil
+	}
+
+	return nil, NewClientVerificationError(clientID)
+}
+
+func (s *clientCertificateService) CACertificatePEM() []byte {
+	return s.ca.CertificatePEM()
+}
@@ -0,0 +1,111 @@
+package clients
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/pki"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func setupTestCertificateService(t *testing.T) (*clientCertificateService, *clientService, *Client, []byte) {
+	t.Helper()
+
+	repo, cleanup := setupTestClientRepo(t)
+	t.Cleanup(cleanup)
+
+	ca, err := pki.LoadOrCreateCertificateAuthority(nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	certificateService := NewClientCertificateService(nil, repo, ca, 24*time.Hour)
+
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+	return certificateService, service, client, mek
+}
+
+func issueTestCertificate(t *testing.T, service *clientCertificateService, clientID string) *x509.Certificate {
+	t.Helper()
+
+	issued, err := service.IssueCertificate(clientID)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	block, _ := pem.Decode(issued.CertificatePEM)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return certificate
+}
+
+func TestClientCertificateService_VerifyFollowsClientState(t *testing.T) {
+	certificateService, service, client, _ := setupTestCertificateService(t)
+
+	certificate := issueTestCertificate(t, certificateService, client.ID)
+
+	verified, err := certificateService.VerifyCertificate(certificate)
+	if err != nil {
+		t.Fatalf("Failed to verify certificate: %v", err)
+	}
+	if verified.ID != client.ID {
+		t.Errorf("Expected client %s, got %s", client.ID, verified.ID)
+	}
+
+	if err := service.DisableClient(client.ID); err != nil {
+		t.Fatalf("Failed to disable client: %v", err)
+	}
+	if _, err := certificateService.VerifyCertificate(certificate); !IsClientVerificationError(err) {
+		t.Errorf("Expected certificate of disabled client to be rejected, got %v", err)
+	}
+
+	if err := service.EnableClient(client.ID); err != nil {
+		t.Fatalf("Failed to enable client: %v", err)
+	}
+	if _, err := certificateService.VerifyCertificate(certificate); err != nil {
+		t.Errorf("Expected certificate to be accepted again after enabling the client, got %v", err)
+	}
+
+	if _, err := certificateService.IssueCertificate("missing-client"); !IsClientNotFoundError(err) {
+		t.Errorf("Expected not found error for unknown client, got %v", err)
+	}
+}
+
+func TestClientCertificateService_ReplacedCertificates(t *testing.T) {
+	certificateService, service, client, mek := setupTestCertificateService(t)
+
+	first := issueTestCertificate(t, certificateService, client.ID)
+
+	// Rotation with a grace period keeps the replaced certificate valid
+	if _, _, err := service.RotateClientSecret(client.ID, time.Hour, &testMekStore{mek: mek}); err != nil {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+	second := issueTestCertificate(t, certificateService, client.ID)
+
+	if _, err := certificateService.VerifyCertificate(first); err != nil {
+		t.Errorf("Expected replaced certificate to be accepted during the grace period, got %v", err)
+	}
+	if _, err := certificateService.VerifyCertificate(second); err != nil {
+		t.Errorf("Expected new certificate to be accepted, got %v", err)
+	}
+
+	// Rotation without a grace period revokes it right away
+	if _, _, err := service.RotateClientSecret(client.ID, 0, &testMekStore{mek: mek}); err != nil {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+	third := issueTestCertificate(t, certificateService, client.ID)
+
+	if _, err := certificateService.VerifyCertificate(second); !IsClientVerificationError(err) {
+		t.Errorf("Expected replaced certificate to be rejected, got %v", err)
+	}
+	if _, err := certificateService.VerifyCertificate(third); err != nil {
+		t.Errorf("Expected new certificate to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"context"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/notifications"
+)
+
+// clientDirectory describes clients in notifications by their display names, locations and timezones
+type clientDirectory struct {
+	logger logging.Logger
+	repo   ClientRepository
+}
+
+// NewClientDirectory creates a notifications.ClientDirectory backed by the client repository
+func NewClientDirectory(logger logging.Logger, repo ClientRepository) *clientDirectory {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &clientDirectory{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+// DescribeClient returns the description of a client. Clients that cannot be loaded, e.g. unknown IDs from failed
+// authentication attempts, are described by their ID.
+func (d *clientDirectory) DescribeClient(clientID string) notifications.ClientDescription {
+	client, err := d.repo.GetByID(context.Background(), clientID)
+	if err != nil {
+		d.logger.Error("Failed to retrieve client for notification", err)
+	}
+	if client == nil {
+		return notifications.PlainClientDirectory.DescribeClient(clientID)
+	}
+
+	return notifications.ClientDescription{
+		Name:     client.Name(),
+		Location: client.Location,
+		Timezone: client.TimeLocation(),
+	}
+}
@@ -0,0 +1,107 @@
+package clients
+
+import "time"
+
+// ClientGroup is a set of clients (for example all cameras at one site) that share a settings template.
+// Members inherit every template setting they do not override.
+type ClientGroup struct {
+	ID          string    // Unique identifier for the group
+	Name        string    // Display name of the group
+	Description string    // Optional description of the group
+	CreatedAt   time.Time // Timestamp when the group was created
+	UpdatedAt   time.Time // Timestamp when the group was last updated
+
+	// Settings template, with the same meaning as the corresponding Client fields
+	StorageLimitMegabytes int
+	ClipDurationSeconds   int
+	MotionOnly            bool
+	Grayscale             bool
+	DownscaleResolution   string
+	OutputFormat          string
+	OutputCodec           string
+	VideoBitRate          string
+	MotionMinArea         int
+	MotionMaxFrames       int
+	MotionWarmUpFrames    int
+	MotionMinWidth        int
+	MotionMinHeight       int
+	MotionMinAspect       float64
+	MotionMaxAspect       float64
+	MotionMogHistory      int
+	MotionMogVarThresh    float64
+	CaptureCodec          string
+	CaptureFrameRate      float64
+}
+
+// groupSetting describes a setting that clients can inherit from their group
+type groupSetting struct {
+	key     string
+	inherit func(client *Client, group *ClientGroup)
+}
+
+// groupSettings lists the inheritable settings. The keys match the field names of the dashboard settings forms.
+var groupSettings = []groupSetting{
+	{"storage_limit", func(c *Client, g *ClientGroup) { c.StorageLimitMegabytes = g.StorageLimitMegabytes }},
+	{"clip_duration", func(c *Client, g *ClientGroup) { c.ClipDurationSeconds = g.ClipDurationSeconds }},
+	{"motion_only", func(c *Client, g *ClientGroup) { c.MotionOnly = g.MotionOnly }},
+	{"grayscale", func(c *Client, g *ClientGroup) { c.Grayscale = g.Grayscale }},
+	{"downscale_resolution", func(c *Client, g *ClientGroup) { c.DownscaleResolution = g.DownscaleResolution }},
+	{"output_format", func(c *Client, g *ClientGroup) { c.OutputFormat = g.OutputFormat }},
+	{"output_codec", func(c *Client, g *ClientGroup) { c.OutputCodec = g.OutputCodec }},
+	{"video_bitrate", func(c *Client, g *ClientGroup) { c.VideoBitRate = g.VideoBitRate }},
+	{"motion_min_area", func(c *Client, g *ClientGroup) { c.MotionMinArea = g.MotionMinArea }},
+	{"motion_max_frames", func(c *Client, g *ClientGroup) { c.MotionMaxFrames = g.MotionMaxFrames }},
+	{"motion_warm_up_frames", func(c *Client, g *ClientGroup) { c.MotionWarmUpFrames = g.MotionWarmUpFrames }},
+	{"motion_min_width", func(c *Client, g *ClientGroup) { c.MotionMinWidth = g.MotionMinWidth }},
+	{"motion_min_height", func(c *Client, g *ClientGroup) { c.MotionMinHeight = g.MotionMinHeight }},
+	{"motion_min_aspect", func(c *Client, g *ClientGroup) { c.MotionMinAspect = g.MotionMinAspect }},
+	{"motion_max_aspect", func(c *Client, g *ClientGroup) { c.MotionMaxAspect = g.MotionMaxAspect }},
+	{"motion_mog_history", func(c *Client, g *ClientGroup) { c.MotionMogHistory = g.MotionMogHistory }},
+	{"motion_mog_var_thresh", func(c *Client, g *ClientGroup) { c.MotionMogVarThresh = g.MotionMogVarThresh }},
+	{"capture_codec", func(c *Client, g *ClientGroup) { c.CaptureCodec = g.CaptureCodec }},
+	{"capture_frame_rate", func(c *Client, g *ClientGroup) { c.CaptureFrameRate = g.CaptureFrameRate }},
+}
+
+// SettingKeys returns the keys of all settings that a client can inherit from its group or override
+func SettingKeys() []string {
+	keys := make([]string, len(groupSettings))
+	for i, setting := range groupSettings {
+		keys[i] = setting.key
+	}
+	return keys
+}
+
+// applyTemplate copies the group's template into every setting the client does not override
+func (g *ClientGroup) applyTemplate(client *Client) {
+	for _, setting := range groupSettings {
+		if !client.IsOverridden(setting.key) {
+			setting.inherit(client, g)
+		}
+	}
+}
+
+// settingsRequest returns the template as an UpdateClientSettingsRequest, so it can be validated like client settings
+func (g *ClientGroup) settingsRequest() UpdateClientSettingsRequest {
+	return UpdateClientSettingsRequest{
+		ID:                    g.ID,
+		StorageLimitMegabytes: g.StorageLimitMegabytes,
+		ClipDurationSeconds:   g.ClipDurationSeconds,
+		MotionOnly:            g.MotionOnly,
+		Grayscale:             g.Grayscale,
+		DownscaleResolution:   g.DownscaleResolution,
+		OutputFormat:          g.OutputFormat,
+		OutputCodec:           g.OutputCodec,
+		VideoBitRate:          g.VideoBitRate,
+		MotionMinArea:         g.MotionMinArea,
+		MotionMaxFrames:       g.MotionMaxFrames,
+		MotionWarmUpFrames:    g.MotionWarmUpFrames,
+		MotionMinWidth:        g.MotionMinWidth,
+		MotionMinHeight:       g.MotionMinHeight,
+		MotionMinAspect:       g.MotionMinAspect,
+		MotionMaxAspect:       g.MotionMaxAspect,
+		MotionMogHistory:      g.MotionMogHistory,
+		MotionMogVarThresh:    g.MotionMogVarThresh,
+		CaptureCodec:          g.CaptureCodec,
+		CaptureFrameRate:      g.CaptureFrameRate,
+	}
+}
@@ -0,0 +1,224 @@
+package clients
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type ClientGroupRepository interface {
+	// GetByID retrieves a ClientGroup by its ID
+	GetByID(ctx context.Context, id string) (*ClientGroup, error)
+	// GetAll retrieves all ClientGroups, ordered by name
+	GetAll(ctx context.Context) ([]*ClientGroup, error)
+	// Create adds a new ClientGroup to the repository
+	Create(ctx context.Context, group *ClientGroup) error
+	// Update modifies an existing ClientGroup in the repository
+	Update(ctx context.Context, group *ClientGroup) error
+	// Delete removes a ClientGroup from the repository
+	Delete(ctx context.Context, id string) error
+}
+
+// SQLiteClientGroupRepository implements ClientGroupRepository using SQLite
+type SQLiteClientGroupRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteClientGroupRepository creates a new SQLite-based ClientGroupRepository
+func NewSQLiteClientGroupRepository(db *sql.DB) (*SQLiteClientGroupRepository, error) {
+	repo := &SQLiteClientGroupRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLiteClientGroupRepository) createTables() error {
+	createGroupsTable := `
+	CREATE TABLE IF NOT EXISTS client_groups (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		storage_limit_megabytes INTEGER NOT NULL,
+		clip_duration_seconds INTEGER NOT NULL,
+		motion_only INTEGER NOT NULL,
+		grayscale INTEGER NOT NULL,
+		downscale_resolution TEXT NOT NULL,
+		output_format TEXT NOT NULL,
+		output_codec TEXT NOT NULL,
+		video_bitrate TEXT NOT NULL,
+		motion_min_area INTEGER NOT NULL,
+		motion_max_frames INTEGER NOT NULL,
+		motion_warm_up_frames INTEGER NOT NULL,
+		motion_min_width INTEGER NOT NULL,
+		motion_min_height INTEGER NOT NULL,
+		motion_min_aspect REAL NOT NULL,
+		motion_max_aspect REAL NOT NULL,
+		motion_mog_history INTEGER NOT NULL,
+		motion_mog_var_thresh REAL NOT NULL,
+		capture_codec TEXT NOT NULL,
+		capture_frame_rate REAL NOT NULL
+	);`
+
+	_, err := r.db.Exec(createGroupsTable)
+	return err
+}
+
+// clientGroupColumns lists the columns selected for a ClientGroup, in the order expected by scanClientGroup
+const clientGroupColumns = `id, name, description, created_at, updated_at,
+		storage_limit_megabytes, clip_duration_seconds, motion_only, grayscale, downscale_resolution,
+		output_format, output_codec, video_bitrate,
+		motion_min_area, motion_max_frames, motion_warm_up_frames,
+		motion_min_width, motion_min_height, motion_min_aspect, motion_max_aspect, motion_mog_history, motion_mog_var_thresh,
+		capture_codec, capture_frame_rate`
+
+// scanClientGroup scans a single group row selected with clientGroupColumns
+func scanClientGroup(row rowScanner) (*ClientGroup, error) {
+	group := &ClientGroup{}
+	var createdAtStr, updatedAtStr string
+	err := row.Scan(
+		&group.ID, &group.Name, &group.Description, &createdAtStr, &updatedAtStr,
+		&group.StorageLimitMegabytes, &group.ClipDurationSeconds, &group.MotionOnly, &group.Grayscale, &group.DownscaleResolution,
+		&group.OutputFormat, &group.OutputCodec, &group.VideoBitRate,
+		&group.MotionMinArea, &group.MotionMaxFrames, &group.MotionWarmUpFrames,
+		&group.MotionMinWidth, &group.MotionMinHeight, &group.MotionMinAspect, &group.MotionMaxAspect, &group.MotionMogHistory, &group.MotionMogVarThresh,
+		&group.CaptureCodec, &group.CaptureFrameRate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	group.CreatedAt, err = db.StringToTime(createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+	}
+
+	group.UpdatedAt, err = db.StringToTime(updatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at timestamp: %w", err)
+	}
+
+	return group, nil
+}
+
+// GetByID retrieves a ClientGroup by its ID
+func (r *SQLiteClientGroupRepository) GetByID(ctx context.Context, id string) (*ClientGroup, error) {
+	query := `SELECT ` + clientGroupColumns + ` FROM client_groups WHERE id = ?`
+
+	group, err := scanClientGroup(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get client group by ID: %w", err)
+	}
+
+	return group, nil
+}
+
+// GetAll retrieves all ClientGroups, ordered by name
+func (r *SQLiteClientGroupRepository) GetAll(ctx context.Context) ([]*ClientGroup, error) {
+	query := `SELECT ` + clientGroupColumns + ` FROM client_groups ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*ClientGroup
+	for rows.Next() {
+		group, err := scanClientGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan client group row: %w", err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// Create adds a new ClientGroup to the repository
+func (r *SQLiteClientGroupRepository) Create(ctx context.Context, group *ClientGroup) error {
+	query := `
+	INSERT INTO client_groups (` + clientGroupColumns + `)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		group.ID, group.Name, group.Description,
+		db.TimeToString(group.CreatedAt), db.TimeToString(group.UpdatedAt),
+		group.StorageLimitMegabytes, group.ClipDurationSeconds, group.MotionOnly, group.Grayscale, group.DownscaleResolution,
+		group.OutputFormat, group.OutputCodec, group.VideoBitRate,
+		group.MotionMinArea, group.MotionMaxFrames, group.MotionWarmUpFrames,
+		group.MotionMinWidth, group.MotionMinHeight, group.MotionMinAspect, group.MotionMaxAspect, group.MotionMogHistory, group.MotionMogVarThresh,
+		group.CaptureCodec, group.CaptureFrameRate,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create client group: %w", err)
+	}
+
+	return nil
+}
+
+// Update modifies an existing ClientGroup in the repository
+func (r *SQLiteClientGroupRepository) Update(ctx context.Context, group *ClientGroup) error {
+	query := `
+	UPDATE client_groups
+	SET name = ?, description = ?, updated_at = ?,
+		storage_limit_megabytes = ?, clip_duration_seconds = ?, motion_only = ?, grayscale = ?, downscale_resolution = ?,
+		output_format = ?, output_codec = ?, video_bitrate = ?,
+		motion_min_area = ?, motion_max_frames = ?, motion_warm_up_frames = ?,
+		motion_min_width = ?, motion_min_height = ?, motion_min_aspect = ?, motion_max_aspect = ?, motion_mog_history = ?, motion_mog_var_thresh = ?,
+		capture_codec = ?, capture_frame_rate = ?
+	WHERE id = ?`
+
+	result, err := r.db.ExecContext(ctx, query,
+		group.Name, group.Description, db.TimeToString(group.UpdatedAt),
+		group.StorageLimitMegabytes, group.ClipDurationSeconds, group.MotionOnly, group.Grayscale, group.DownscaleResolution,
+		group.OutputFormat, group.OutputCodec, group.VideoBitRate,
+		group.MotionMinArea, group.MotionMaxFrames, group.MotionWarmUpFrames,
+		group.MotionMinWidth, group.MotionMinHeight, group.MotionMinAspect, group.MotionMaxAspect, group.MotionMogHistory, group.MotionMogVarThresh,
+		group.CaptureCodec, group.CaptureFrameRate,
+		group.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update client group: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("client group with ID %s not found", group.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a ClientGroup from the repository
+func (r *SQLiteClientGroupRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM client_groups WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete client group: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("client group with ID %s not found", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+package clients
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+type ClientGroupRequest struct {
+	ID          string // ID of the group to update, ignored when creating a group
+	Name        string
+	Description string
+	Settings    UpdateClientSettingsRequest // Settings template of the group (the ID of the settings request is ignored)
+}
+
+// ClientGroupService manages client groups and keeps the settings of their members in line with the group templates.
+// Inherited settings are written to the members directly, so clients pick up template changes with their next settings sync.
+type ClientGroupService interface {
+	// CreateGroup creates a new group with the given settings template
+	CreateGroup(req ClientGroupRequest) (*ClientGroup, error)
+	// GetGroup retrieves a group by its ID
+	GetGroup(id string) (*ClientGroup, error)
+	// GetGroups retrieves all groups
+	GetGroups() ([]*ClientGroup, error)
+	// UpdateGroup updates a group and applies its template to all members
+	UpdateGroup(req ClientGroupRequest, changedBy string) error
+	// DeleteGroup deletes a group. Its members keep their current settings.
+	DeleteGroup(id string) error
+	// AssignClient moves a client into a group (or out of any group if groupID is empty) and applies the group's template
+	// to every setting that is not listed in overrides
+	AssignClient(clientID, groupID string, overrides []string, changedBy string) error
+	// GetMemberIDs returns the IDs of the clients in a group
+	GetMemberIDs(groupID string) ([]string, error)
+}
+
+type clientGroupService struct {
+	logger     logging.Logger
+	groupRepo  ClientGroupRepository
+	clientRepo ClientRepository
+	history    settingsHistory
+}
+
+func NewClientGroupService(logger logging.Logger, groupRepo ClientGroupRepository, clientRepo ClientRepository, versionRepo SettingsVersionRepository) *clientGroupService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &clientGroupService{
+		logger:     logger,
+		groupRepo:  groupRepo,
+		clientRepo: clientRepo,
+		history:    settingsHistory{repo: versionRepo},
+	}
+}
+
+func (s *clientGroupService) CreateGroup(req ClientGroupRequest) (*ClientGroup, error) {
+	now := time.Now().UTC()
+	group := &ClientGroup{
+		ID:        uuid.New().String(),
+		CreatedAt: now,
+	}
+	setGroupFields(group, req, now)
+
+	if err := s.validateGroup(group); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Creating client group", "name", group.Name)
+
+	if err := s.groupRepo.Create(context.Background(), group); err != nil {
+		s.logger.Error("Failed to save client group", err)
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (s *clientGroupService) GetGroup(id string) (*ClientGroup, error) {
+	group, err := s.groupRepo.GetByID(context.Background(), id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client group", err)
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *clientGroupService) GetGroups() ([]*ClientGroup, error) {
+	groups, err := s.groupRepo.GetAll(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to retrieve client groups", err)
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (s *clientGroupService) UpdateGroup(req ClientGroupRequest, changedBy string) error {
+	ctx := context.Background()
+
+	group, err := s.groupRepo.GetByID(ctx, req.ID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client group", err)
+		return err
+	}
+	if group == nil {
+		return NewClientGroupNotFoundError(req.ID)
+	}
+
+	now := time.Now().UTC()
+	setGroupFields(group, req, now)
+
+	if err := s.validateGroup(group); err != nil {
+		return err
+	}
+
+	s.logger.Info("Updating client group", "id", group.ID, "name", group.Name)
+
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		s.logger.Error("Failed to update client group", err)
+		return err
+	}
+
+	members, err := s.getMembers(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	for _, client := range members {
+		before := settingsOf(client)
+		group.applyTemplate(client)
+		client.UpdatedAt = now
+		if err := s.history.record(ctx, client, &before, changedBy, "Template of group "+group.Name+" changed"); err != nil {
+			s.logger.Error("Failed to record settings version", err)
+			return err
+		}
+		if err := s.clientRepo.Update(ctx, client); err != nil {
+			s.logger.Error("Failed to apply group template to client", err)
+			return err
+		}
+	}
+
+	s.logger.Info("Applied group template to members", "id", group.ID, "members", len(members))
+	return nil
+}
+
+func (s *clientGroupService) DeleteGroup(id string) error {
+	ctx := context.Background()
+
+	group, err := s.groupRepo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client group", err)
+		return err
+	}
+	if group == nil {
+		return nil // No error if the group does not exist
+	}
+
+	members, err := s.getMembers(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Members keep the settings they inherited, so their devices are not reconfigured by the deletion
+	now := time.Now().UTC()
+	for _, client := range members {
+		client.GroupID = ""
+		client.SettingsOverrides = nil
+		client.UpdatedAt = now
+		if err := s.clientRepo.Update(ctx, client); err != nil {
+			s.logger.Error("Failed to remove client from group", err)
+			return err
+		}
+	}
+
+	if err := s.groupRepo.Delete(ctx, id); err != nil {
+		s.logger.Error("Failed to delete client group", err)
+		return err
+	}
+
+	s.logger.Info("Deleted client group", "id", id, "name", group.Name)
+	return nil
+}
+
+func (s *clientGroupService) AssignClient(clientID, groupID string, overrides []string, changedBy string) error {
+	keys := SettingKeys()
+	for _, key := range overrides {
+		if !slices.Contains(keys, key) {
+			return NewClientValidationError("unknown setting: " + key)
+		}
+	}
+
+	ctx := context.Background()
+
+	client, err := s.clientRepo.GetByID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return err
+	}
+	if client == nil {
+		return NewClientNotFoundError(clientID)
+	}
+
+	before := settingsOf(client)
+	reason := "Removed from group"
+
+	client.GroupID = groupID
+	client.SettingsOverrides = nil
+
+	if groupID != "" {
+		group, err := s.groupRepo.GetByID(ctx, groupID)
+		if err != nil {
+			s.logger.Error("Failed to retrieve client group", err)
+			return err
+		}
+		if group == nil {
+			return NewClientGroupNotFoundError(groupID)
+		}
+
+		client.SettingsOverrides = slices.Compact(slices.Sorted(slices.Values(overrides)))
+		group.applyTemplate(client)
+		reason = "Assigned to group " + group.Name
+	}
+
+	if err := s.history.record(ctx, client, &before, changedBy, reason); err != nil {
+		s.logger.Error("Failed to record settings version", err)
+		return err
+	}
+
+	client.UpdatedAt = time.Now().UTC()
+	if err := s.clientRepo.Update(ctx, client); err != nil {
+		s.logger.Error("Failed to update client group membership", err)
+		return err
+	}
+
+	s.logger.Info("Updated client group membership", "clientId", clientID, "groupId", groupID, "overrides", len(client.SettingsOverrides))
+	return nil
+}
+
+func (s *clientGroupService) GetMemberIDs(groupID string) ([]string, error) {
+	members, err := s.getMembers(context.Background(), groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(members))
+	for i, client := range members {
+		ids[i] = client.ID
+	}
+	return ids, nil
+}
+
+// getMembers returns the clients in a group
+func (s *clientGroupService) getMembers(ctx context.Context, groupID string) ([]*Client, error) {
+	all, err := s.clientRepo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("Failed to retrieve clients", err)
+		return nil, err
+	}
+
+	var members []*Client
+	for _, client := range all {
+		if client.GroupID == groupID {
+			members = append(members, client)
+		}
+	}
+	return members, nil
+}
+
+func (s *clientGroupService) validateGroup(group *ClientGroup) error {
+	if group.Name == "" {
+		return NewClientValidationError("group name cannot be empty")
+	}
+
+	if err := validateClientSettings(group.settingsRequest()); err != nil {
+		return err
+	}
+
+	groups, err := s.groupRepo.GetAll(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to retrieve client groups", err)
+		return err
+	}
+	for _, other := range groups {
+		if other.ID != group.ID && strings.EqualFold(other.Name, group.Name) {
+			return NewClientValidationError("a group with this name already exists")
+		}
+	}
+
+	return nil
+}
+
+// setGroupFields copies the name, description and settings template from a request into a group
+func setGroupFields(group *ClientGroup, req ClientGroupRequest, now time.Time) {
+	group.Name = strings.TrimSpace(req.Name)
+	group.Description = strings.TrimSpace(req.Description)
+	group.UpdatedAt = now
+
+	settings := req.Settings
+	group.StorageLimitMegabytes = settings.StorageLimitMegabytes
+	group.ClipDurationSeconds = settings.ClipDurationSeconds
+	group.MotionOnly = settings.MotionOnly
+	group.Grayscale = settings.Grayscale
+	group.DownscaleResolution = settings.DownscaleResolution
+	group.OutputFormat = settings.OutputFormat
+	group.OutputCodec = settings.OutputCodec
+	group.VideoBitRate = settings.VideoBitRate
+	group.MotionMinArea = settings.MotionMinArea
+	group.MotionMaxFrames = settings.MotionMaxFrames
+	group.MotionWarmUpFrames = settings.MotionWarmUpFrames
+	group.MotionMinWidth = settings.MotionMinWidth
+	group.MotionMinHeight = settings.MotionMinHeight
+	group.MotionMinAspect = settings.MotionMinAspect
+	group.MotionMaxAspect = settings.MotionMaxAspect
+	group.MotionMogHistory = settings.MotionMogHistory
+	group.MotionMogVarThresh = settings.MotionMogVarThresh
+	group.CaptureCodec = settings.CaptureCodec
+	group.CaptureFrameRate = settings.CaptureFrameRate
+}
@@ -0,0 +1,130 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func setupTestGroupService(t *testing.T) (*clientGroupService, *clientService, *Client) {
+	t.Helper()
+
+	repo, cleanup := setupTestClientRepo(t)
+	t.Cleanup(cleanup)
+
+	groupRepo, err := NewSQLiteClientGroupRepository(repo.db)
+	if err != nil {
+		t.Fatalf("Failed to create group repository: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), groupRepo, encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	return NewClientGroupService(nil, groupRepo, repo, newTestVersionRepo(t, repo)), service, client
+}
+
+func testGroupRequest(name string, clipDuration int) ClientGroupRequest {
+	return ClientGroupRequest{
+		Name: name,
+		Settings: UpdateClientSettingsRequest{
+			StorageLimitMegabytes: 2048,
+			ClipDurationSeconds:   clipDuration,
+			MotionOnly:            true,
+			DownscaleResolution:   "720p",
+			OutputFormat:          "mkv",
+			OutputCodec:           "libx265",
+			VideoBitRate:          "1500k",
+			MotionMinArea:         500,
+			CaptureCodec:          "MJPG",
+			CaptureFrameRate:      10,
+		},
+	}
+}
+
+func TestClientGroupService_TemplateInheritance(t *testing.T) {
+	groupService, clientService, client := setupTestGroupService(t)
+
+	group, err := groupService.CreateGroup(testGroupRequest("Warehouse", 120))
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+
+	if err := groupService.AssignClient(client.ID, group.ID, []string{"clip_duration"}, "admin"); err != nil {
+		t.Fatalf("Failed to assign client: %v", err)
+	}
+
+	member, _ := clientService.GetClient(client.ID)
+	if member.GroupID != group.ID {
+		t.Errorf("Expected group %s, got %s", group.ID, member.GroupID)
+	}
+	if member.OutputCodec != "libx265" || member.StorageLimitMegabytes != 2048 || !member.MotionOnly {
+		t.Error("Expected client to inherit the group template")
+	}
+	if member.ClipDurationSeconds != client.ClipDurationSeconds {
+		t.Errorf("Expected overridden clip duration %d to be kept, got %d", client.ClipDurationSeconds, member.ClipDurationSeconds)
+	}
+	if member.SettingsVersion != client.SettingsVersion+1 {
+		t.Errorf("Expected the inherited settings to be recorded as a new version, got version %d", member.SettingsVersion)
+	}
+
+	// Template changes propagate to members, except for overridden settings
+	update := testGroupRequest("Warehouse", 300)
+	update.ID = group.ID
+	update.Settings.OutputCodec = "libx264"
+	if err := groupService.UpdateGroup(update, "admin"); err != nil {
+		t.Fatalf("Failed to update group: %v", err)
+	}
+
+	member, _ = clientService.GetClient(client.ID)
+	if member.OutputCodec != "libx264" {
+		t.Errorf("Expected template change to propagate, got codec %s", member.OutputCodec)
+	}
+	if member.ClipDurationSeconds != client.ClipDurationSeconds {
+		t.Errorf("Expected overridden clip duration to be kept, got %d", member.ClipDurationSeconds)
+	}
+
+	ids, err := groupService.GetMemberIDs(group.ID)
+	if err != nil || len(ids) != 1 || ids[0] != client.ID {
+		t.Errorf("Expected member IDs [%s], got %v (%v)", client.ID, ids, err)
+	}
+
+	// Deleting the group keeps the inherited values
+	if err := groupService.DeleteGroup(group.ID); err != nil {
+		t.Fatalf("Failed to delete group: %v", err)
+	}
+	member, _ = clientService.GetClient(client.ID)
+	if member.GroupID != "" || len(member.SettingsOverrides) != 0 {
+		t.Error("Expected client to leave the deleted group")
+	}
+	if member.OutputCodec != "libx264" {
+		t.Errorf("Expected inherited settings to be kept, got codec %s", member.OutputCodec)
+	}
+}
+
+func TestClientGroupService_Validation(t *testing.T) {
+	groupService, _, client := setupTestGroupService(t)
+
+	if _, err := groupService.CreateGroup(testGroupRequest("", 120)); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for empty name, got %v", err)
+	}
+	if _, err := groupService.CreateGroup(testGroupRequest("Garden", 5)); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for invalid template, got %v", err)
+	}
+
+	group, err := groupService.CreateGroup(testGroupRequest("Garden", 120))
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if _, err := groupService.CreateGroup(testGroupRequest("garden", 120)); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for duplicate name, got %v", err)
+	}
+
+	if err := groupService.AssignClient(client.ID, group.ID, []string{"no_such_setting"}, "admin"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for unknown override, got %v", err)
+	}
+	if err := groupService.AssignClient(client.ID, "missing", nil, "admin"); !IsClientGroupNotFoundError(err) {
+		t.Errorf("Expected group not found error, got %v", err)
+	}
+}
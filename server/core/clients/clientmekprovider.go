@@ -3,6 +3,7 @@ package clients
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"time"
 
 	"github.com/yeti47/cryospy/server/core/encryption"
 )
@@ -13,6 +14,14 @@ type ClientMekProvider interface {
 	UncoverMek(clientID, clientSecret string) ([]byte, error)
 }
 
+// SecretMekProvider is a ClientMekProvider that also tells which of the client's secrets unwrapped the MEK
+type SecretMekProvider interface {
+	ClientMekProvider
+	// UncoverMekWithSecretHash works like UncoverMek and also returns the hash of the secret that was used,
+	// which is the hash of the previous secret if it was accepted during a rotation grace period.
+	UncoverMekWithSecretHash(clientID, clientSecret string) ([]byte, string, error)
+}
+
 type clientMekProvider struct {
 	encryptor      encryption.Encryptor
 	clientRepo     ClientRepository
@@ -30,44 +39,63 @@ func NewClientMekProvider(encryptor encryption.Encryptor, clientRepo ClientRepos
 // UncoverMek decrypts the MEK for the client using the provided secret.
 // The clientSecret parameter must be hex-encoded.
 func (p *clientMekProvider) UncoverMek(clientID, clientSecret string) ([]byte, error) {
+	mek, _, err := p.UncoverMekWithSecretHash(clientID, clientSecret)
+	return mek, err
+}
+
+// UncoverMekWithSecretHash decrypts the MEK for the client using the provided secret and returns the hash of
+// that secret. The clientSecret parameter must be hex-encoded.
+func (p *clientMekProvider) UncoverMekWithSecretHash(clientID, clientSecret string) ([]byte, string, error) {
 
 	// Verify the client secret
 	isValid, client, err := p.clientVerifier.VerifyClient(clientID, clientSecret)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if !isValid {
-		return nil, NewClientVerificationError(clientID)
+		return nil, "", NewClientVerificationError(clientID)
 	}
 
-	// Decode the base64 encoded values
-	encryptedMek, err := base64.StdEncoding.DecodeString(client.EncryptedMek)
+	// Derive the encryption key from the client secret using the salt.
+	// The client secret is hex-encoded, so we need to decode it first
+	clientSecretBytes, err := hex.DecodeString(clientSecret)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	keyDerivationSalt, err := base64.StdEncoding.DecodeString(client.KeyDerivationSalt)
-	if err != nil {
-		return nil, err
+	mek, err := p.unwrapMek(client.EncryptedMek, client.KeyDerivationSalt, clientSecretBytes)
+	if err == nil {
+		return mek, client.SecretHash, nil
 	}
 
-	// Derive the encryption key from the client secret using the salt.
-	// The client secret is hex-encoded, so we need to decode it first
-	clientSecretBytes, err := hex.DecodeString(clientSecret)
+	// The secret may be the previous one, which is still valid during a rotation grace period
+	if client.HasActivePreviousSecret(time.Now().UTC()) {
+		if previousMek, previousErr := p.unwrapMek(client.PreviousEncryptedMek, client.PreviousKeyDerivationSalt, clientSecretBytes); previousErr == nil {
+			return previousMek, client.PreviousSecretHash, nil
+		}
+	}
+
+	return nil, "", err
+}
+
+// unwrapMek decrypts a (base 64 encoded) encrypted MEK with a key derived from the client secret
+func (p *clientMekProvider) unwrapMek(encryptedMekBase64, keyDerivationSaltBase64 string, clientSecret []byte) ([]byte, error) {
+	// Decode the base64 encoded values
+	encryptedMek, err := base64.StdEncoding.DecodeString(encryptedMekBase64)
 	if err != nil {
 		return nil, err
 	}
 
-	derivedKey, err := p.encryptor.DeriveKeyFromSecret(clientSecretBytes, keyDerivationSalt)
+	keyDerivationSalt, err := base64.StdEncoding.DecodeString(keyDerivationSaltBase64)
 	if err != nil {
 		return nil, err
 	}
 
-	// Decrypt the MEK using the derived key
-	mek, err := p.encryptor.Decrypt(encryptedMek, derivedKey)
+	derivedKey, err := p.encryptor.DeriveKeyFromSecret(clientSecret, keyDerivationSalt)
 	if err != nil {
 		return nil, err
 	}
 
-	return mek, nil
+	// Decrypt the MEK using the derived key
+	return p.encryptor.Decrypt(encryptedMek, derivedKey)
 }
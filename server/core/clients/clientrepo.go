@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/yeti47/cryospy/server/core/ccc/db"
@@ -68,6 +69,26 @@ func (r *SQLiteClientRepository) createTables() error {
 		, motion_max_aspect REAL NOT NULL DEFAULT 3.0
 		, motion_mog_history INTEGER NOT NULL DEFAULT 500
 		, motion_mog_var_thresh REAL NOT NULL DEFAULT 16.0
+		, previous_secret_hash TEXT NOT NULL DEFAULT ''
+		, previous_secret_salt TEXT NOT NULL DEFAULT ''
+		, previous_encrypted_mek TEXT NOT NULL DEFAULT ''
+		, previous_key_derivation_salt TEXT NOT NULL DEFAULT ''
+		, previous_secret_expires_at TEXT
+		, certificate_serial TEXT NOT NULL DEFAULT ''
+		, previous_certificate_serial TEXT NOT NULL DEFAULT ''
+		, group_id TEXT NOT NULL DEFAULT ''
+		, settings_overrides TEXT NOT NULL DEFAULT ''
+		, timezone TEXT NOT NULL DEFAULT ''
+		, schedule TEXT NOT NULL DEFAULT ''
+		, home_recording_mode TEXT NOT NULL DEFAULT ''
+		, home_mute_motion_notifications INTEGER NOT NULL DEFAULT 0
+		, away_recording_mode TEXT NOT NULL DEFAULT ''
+		, away_mute_motion_notifications INTEGER NOT NULL DEFAULT 0
+		, settings_version INTEGER NOT NULL DEFAULT 0
+		, display_name TEXT NOT NULL DEFAULT ''
+		, location TEXT NOT NULL DEFAULT ''
+		, notes TEXT NOT NULL DEFAULT ''
+		, archived_at TEXT
 	);`
 
 	_, err := r.db.Exec(createClientsTable)
@@ -95,25 +116,57 @@ func (r *SQLiteClientRepository) createTables() error {
 	db.AddColumn(r.db, "clients", "motion_max_aspect", "REAL NOT NULL DEFAULT 3.0")
 	db.AddColumn(r.db, "clients", "motion_mog_history", "INTEGER NOT NULL DEFAULT 500")
 	db.AddColumn(r.db, "clients", "motion_mog_var_thresh", "REAL NOT NULL DEFAULT 16.0")
+	db.AddColumn(r.db, "clients", "previous_secret_hash", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "previous_secret_salt", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "previous_encrypted_mek", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "previous_key_derivation_salt", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "previous_secret_expires_at", "TEXT")
+	db.AddColumn(r.db, "clients", "certificate_serial", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "previous_certificate_serial", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "group_id", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "settings_overrides", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "timezone", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "schedule", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "home_recording_mode", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "home_mute_motion_notifications", "INTEGER NOT NULL DEFAULT 0")
+	db.AddColumn(r.db, "clients", "away_recording_mode", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "away_mute_motion_notifications", "INTEGER NOT NULL DEFAULT 0")
+	db.AddColumn(r.db, "clients", "settings_version", "INTEGER NOT NULL DEFAULT 0")
+	db.AddColumn(r.db, "clients", "display_name", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "location", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "notes", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "clients", "archived_at", "TEXT")
 
 	return nil
 }
 
-// GetByID retrieves a Client by its ID
-func (r *SQLiteClientRepository) GetByID(ctx context.Context, id string) (*Client, error) {
-	query := `
-	SELECT id, secret_hash, secret_salt, created_at, updated_at, encrypted_mek, key_derivation_salt, storage_limit_megabytes,
+// clientColumns lists the columns selected for a Client, in the order expected by scanClient
+const clientColumns = `id, secret_hash, secret_salt, created_at, updated_at, encrypted_mek, key_derivation_salt, storage_limit_megabytes,
 		is_disabled, clip_duration_seconds, motion_only, grayscale, downscale_resolution,
 		output_format, output_codec, video_bitrate,
 		motion_min_area, motion_max_frames, motion_warm_up_frames,
 		motion_min_width, motion_min_height, motion_min_aspect, motion_max_aspect, motion_mog_history, motion_mog_var_thresh,
-		capture_codec, capture_frame_rate
-	FROM clients WHERE id = ?`
-
-	row := r.db.QueryRowContext(ctx, query, id)
+		capture_codec, capture_frame_rate,
+		previous_secret_hash, previous_secret_salt, previous_encrypted_mek, previous_key_derivation_salt, previous_secret_expires_at,
+		certificate_serial, previous_certificate_serial,
+		group_id, settings_overrides,
+		timezone, schedule,
+		home_recording_mode, home_mute_motion_notifications, away_recording_mode, away_mute_motion_notifications,
+		settings_version,
+		display_name, location, notes,
+		archived_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+// scanClient scans a single client row selected with clientColumns
+func scanClient(row rowScanner) (*Client, error) {
 	client := &Client{}
 	var createdAtStr, updatedAtStr string
+	var previousSecretExpiresAtStr, archivedAtStr sql.NullString
+	var settingsOverridesStr, scheduleStr string
 	err := row.Scan(
 		&client.ID, &client.SecretHash, &client.SecretSalt, &createdAtStr, &updatedAtStr,
 		&client.EncryptedMek, &client.KeyDerivationSalt, &client.StorageLimitMegabytes,
@@ -122,12 +175,18 @@ func (r *SQLiteClientRepository) GetByID(ctx context.Context, id string) (*Clien
 		&client.MotionMinArea, &client.MotionMaxFrames, &client.MotionWarmUpFrames,
 		&client.MotionMinWidth, &client.MotionMinHeight, &client.MotionMinAspect, &client.MotionMaxAspect, &client.MotionMogHistory, &client.MotionMogVarThresh,
 		&client.CaptureCodec, &client.CaptureFrameRate,
+		&client.PreviousSecretHash, &client.PreviousSecretSalt, &client.PreviousEncryptedMek, &client.PreviousKeyDerivationSalt, &previousSecretExpiresAtStr,
+		&client.CertificateSerial, &client.PreviousCertificateSerial,
+		&client.GroupID, &settingsOverridesStr,
+		&client.Timezone, &scheduleStr,
+		&client.HomeBehavior.RecordingMode, &client.HomeBehavior.MuteMotionNotifications,
+		&client.AwayBehavior.RecordingMode, &client.AwayBehavior.MuteMotionNotifications,
+		&client.SettingsVersion,
+		&client.DisplayName, &client.Location, &client.Notes,
+		&archivedAtStr,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("failed to get client by ID: %w", err)
+		return nil, err
 	}
 
 	// Convert string timestamps back to time.Time
@@ -141,19 +200,52 @@ func (r *SQLiteClientRepository) GetByID(ctx context.Context, id string) (*Clien
 		return nil, fmt.Errorf("failed to parse updated_at timestamp: %w", err)
 	}
 
+	if previousSecretExpiresAtStr.Valid {
+		expiresAt, err := db.StringToTime(previousSecretExpiresAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse previous_secret_expires_at timestamp: %w", err)
+		}
+		client.PreviousSecretExpiresAt = &expiresAt
+	}
+
+	if archivedAtStr.Valid {
+		archivedAt, err := db.StringToTime(archivedAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse archived_at timestamp: %w", err)
+		}
+		client.ArchivedAt = &archivedAt
+	}
+
+	if settingsOverridesStr != "" {
+		client.SettingsOverrides = strings.Split(settingsOverridesStr, ",")
+	}
+
+	client.Schedule, err = decodeSchedule(scheduleStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// GetByID retrieves a Client by its ID
+func (r *SQLiteClientRepository) GetByID(ctx context.Context, id string) (*Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM clients WHERE id = ?`
+
+	client, err := scanClient(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get client by ID: %w", err)
+	}
+
 	return client, nil
 }
 
 // GetAll retrieves all Clients
 func (r *SQLiteClientRepository) GetAll(ctx context.Context) ([]*Client, error) {
-	query := `
-	SELECT id, secret_hash, secret_salt, created_at, updated_at, encrypted_mek, key_derivation_salt, storage_limit_megabytes,
-		is_disabled, clip_duration_seconds, motion_only, grayscale, downscale_resolution,
-		output_format, output_codec, video_bitrate,
-		motion_min_area, motion_max_frames, motion_warm_up_frames,
-		motion_min_width, motion_min_height, motion_min_aspect, motion_max_aspect, motion_mog_history, motion_mog_var_thresh,
-		capture_codec, capture_frame_rate
-	FROM clients ORDER BY created_at DESC`
+	query := `SELECT ` + clientColumns + ` FROM clients ORDER BY created_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
@@ -163,32 +255,11 @@ func (r *SQLiteClientRepository) GetAll(ctx context.Context) ([]*Client, error)
 
 	var clients []*Client
 	for rows.Next() {
-		client := &Client{}
-		var createdAtStr, updatedAtStr string
-		err := rows.Scan(
-			&client.ID, &client.SecretHash, &client.SecretSalt, &createdAtStr, &updatedAtStr,
-			&client.EncryptedMek, &client.KeyDerivationSalt, &client.StorageLimitMegabytes,
-			&client.IsDisabled, &client.ClipDurationSeconds, &client.MotionOnly, &client.Grayscale, &client.DownscaleResolution,
-			&client.OutputFormat, &client.OutputCodec, &client.VideoBitRate,
-			&client.MotionMinArea, &client.MotionMaxFrames, &client.MotionWarmUpFrames,
-			&client.MotionMinWidth, &client.MotionMinHeight, &client.MotionMinAspect, &client.MotionMaxAspect, &client.MotionMogHistory, &client.MotionMogVarThresh,
-			&client.CaptureCodec, &client.CaptureFrameRate,
-		)
+		client, err := scanClient(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan client row: %w", err)
 		}
 
-		// Convert string timestamps back to time.Time
-		client.CreatedAt, err = db.StringToTime(createdAtStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
-		}
-
-		client.UpdatedAt, err = db.StringToTime(updatedAtStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse updated_at timestamp: %w", err)
-		}
-
 		clients = append(clients, client)
 	}
 
@@ -203,10 +274,23 @@ func (r *SQLiteClientRepository) Create(ctx context.Context, client *Client) err
 		output_format, output_codec, video_bitrate,
 		motion_min_area, motion_max_frames, motion_warm_up_frames,
 		motion_min_width, motion_min_height, motion_min_aspect, motion_max_aspect, motion_mog_history, motion_mog_var_thresh,
-		capture_codec, capture_frame_rate)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		capture_codec, capture_frame_rate,
+		previous_secret_hash, previous_secret_salt, previous_encrypted_mek, previous_key_derivation_salt, previous_secret_expires_at,
+		certificate_serial, previous_certificate_serial,
+		group_id, settings_overrides,
+		timezone, schedule,
+		home_recording_mode, home_mute_motion_notifications, away_recording_mode, away_mute_motion_notifications,
+		settings_version,
+		display_name, location, notes,
+		archived_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	schedule, err := encodeSchedule(client.Schedule)
+	if err != nil {
+		return err
+	}
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		client.ID, client.SecretHash, client.SecretSalt,
 		db.TimeToString(client.CreatedAt), db.TimeToString(client.UpdatedAt),
 		client.EncryptedMek, client.KeyDerivationSalt, client.StorageLimitMegabytes,
@@ -215,6 +299,16 @@ func (r *SQLiteClientRepository) Create(ctx context.Context, client *Client) err
 		client.MotionMinArea, client.MotionMaxFrames, client.MotionWarmUpFrames,
 		client.MotionMinWidth, client.MotionMinHeight, client.MotionMinAspect, client.MotionMaxAspect, client.MotionMogHistory, client.MotionMogVarThresh,
 		client.CaptureCodec, client.CaptureFrameRate,
+		client.PreviousSecretHash, client.PreviousSecretSalt, client.PreviousEncryptedMek, client.PreviousKeyDerivationSalt,
+		db.TimePtrToString(client.PreviousSecretExpiresAt),
+		client.CertificateSerial, client.PreviousCertificateSerial,
+		client.GroupID, strings.Join(client.SettingsOverrides, ","),
+		client.Timezone, schedule,
+		client.HomeBehavior.RecordingMode, client.HomeBehavior.MuteMotionNotifications,
+		client.AwayBehavior.RecordingMode, client.AwayBehavior.MuteMotionNotifications,
+		client.SettingsVersion,
+		client.DisplayName, client.Location, client.Notes,
+		db.TimePtrToString(client.ArchivedAt),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create client: %w", err)
@@ -233,9 +327,23 @@ func (r *SQLiteClientRepository) Update(ctx context.Context, client *Client) err
 		output_format = ?, output_codec = ?, video_bitrate = ?,
 		motion_min_area = ?, motion_max_frames = ?, motion_warm_up_frames = ?,
 		motion_min_width = ?, motion_min_height = ?, motion_min_aspect = ?, motion_max_aspect = ?, motion_mog_history = ?, motion_mog_var_thresh = ?,
-		capture_codec = ?, capture_frame_rate = ?
+		capture_codec = ?, capture_frame_rate = ?,
+		previous_secret_hash = ?, previous_secret_salt = ?, previous_encrypted_mek = ?,
+		previous_key_derivation_salt = ?, previous_secret_expires_at = ?,
+		certificate_serial = ?, previous_certificate_serial = ?,
+		group_id = ?, settings_overrides = ?,
+		timezone = ?, schedule = ?,
+		home_recording_mode = ?, home_mute_motion_notifications = ?, away_recording_mode = ?, away_mute_motion_notifications = ?,
+		settings_version = ?,
+		display_name = ?, location = ?, notes = ?,
+		archived_at = ?
 	WHERE id = ?`
 
+	schedule, err := encodeSchedule(client.Schedule)
+	if err != nil {
+		return err
+	}
+
 	result, err := r.db.ExecContext(ctx, query,
 		client.SecretHash, client.SecretSalt, db.TimeToString(client.UpdatedAt),
 		client.EncryptedMek, client.KeyDerivationSalt, client.StorageLimitMegabytes, client.IsDisabled,
@@ -244,6 +352,16 @@ func (r *SQLiteClientRepository) Update(ctx context.Context, client *Client) err
 		client.MotionMinArea, client.MotionMaxFrames, client.MotionWarmUpFrames,
 		client.MotionMinWidth, client.MotionMinHeight, client.MotionMinAspect, client.MotionMaxAspect, client.MotionMogHistory, client.MotionMogVarThresh,
 		client.CaptureCodec, client.CaptureFrameRate,
+		client.PreviousSecretHash, client.PreviousSecretSalt, client.PreviousEncryptedMek,
+		client.PreviousKeyDerivationSalt, db.TimePtrToString(client.PreviousSecretExpiresAt),
+		client.CertificateSerial, client.PreviousCertificateSerial,
+		client.GroupID, strings.Join(client.SettingsOverrides, ","),
+		client.Timezone, schedule,
+		client.HomeBehavior.RecordingMode, client.HomeBehavior.MuteMotionNotifications,
+		client.AwayBehavior.RecordingMode, client.AwayBehavior.MuteMotionNotifications,
+		client.SettingsVersion,
+		client.DisplayName, client.Location, client.Notes,
+		db.TimePtrToString(client.ArchivedAt),
 		client.ID,
 	)
 	if err != nil {
@@ -445,3 +445,52 @@ func TestSQLiteClientRepository_TimeConversion(t *testing.T) {
 		t.Errorf("UpdatedAt not preserved: expected %v, got %v", client.UpdatedAt, retrieved.UpdatedAt)
 	}
 }
+
+func TestSQLiteClientRepository_PreviousSecret(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	client := createTestClient()
+
+	if err := repo.Create(ctx, client); err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	retrieved, err := repo.GetByID(ctx, client.ID)
+	if err != nil {
+		t.Fatalf("Failed to get client: %v", err)
+	}
+	if retrieved.PreviousSecretExpiresAt != nil || retrieved.HasActivePreviousSecret(time.Now()) {
+		t.Error("Expected no previous secret for a new client")
+	}
+
+	expiresAt := time.Now().UTC().Add(time.Hour)
+	retrieved.PreviousSecretHash = "previousHash"
+	retrieved.PreviousSecretSalt = "previousSalt"
+	retrieved.PreviousEncryptedMek = "previousMek"
+	retrieved.PreviousKeyDerivationSalt = "previousKdSalt"
+	retrieved.PreviousSecretExpiresAt = &expiresAt
+
+	if err := repo.Update(ctx, retrieved); err != nil {
+		t.Fatalf("Failed to update client: %v", err)
+	}
+
+	updated, err := repo.GetByID(ctx, client.ID)
+	if err != nil {
+		t.Fatalf("Failed to get client: %v", err)
+	}
+	if updated.PreviousSecretHash != "previousHash" || updated.PreviousSecretSalt != "previousSalt" ||
+		updated.PreviousEncryptedMek != "previousMek" || updated.PreviousKeyDerivationSalt != "previousKdSalt" {
+		t.Errorf("Previous credentials were not persisted: %+v", updated)
+	}
+	if updated.PreviousSecretExpiresAt == nil || !updated.PreviousSecretExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected previous secret expiry %v, got %v", expiresAt, updated.PreviousSecretExpiresAt)
+	}
+	if !updated.HasActivePreviousSecret(time.Now()) {
+		t.Error("Expected previous secret to be active")
+	}
+	if updated.HasActivePreviousSecret(expiresAt.Add(time.Second)) {
+		t.Error("Expected previous secret to be inactive after expiry")
+	}
+}
@@ -3,6 +3,7 @@ package clients
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"strings"
 	"time"
 
@@ -58,6 +59,30 @@ type UpdateClientSettingsRequest struct {
 	CaptureFrameRate      float64
 }
 
+// UpdateClientDetailsRequest holds the descriptive details of a client. They only affect how the client is presented,
+// not how it records.
+type UpdateClientDetailsRequest struct {
+	ID          string
+	DisplayName string
+	Location    string
+	Timezone    string
+	Notes       string
+}
+
+// BulkUpdateResult is the outcome of a bulk settings update for one client
+type BulkUpdateResult struct {
+	ClientID   string          // ID of the client
+	Changes    []SettingChange // Settings that changed, empty if the client already had the values
+	Overridden []string        // Keys that the client now overrides, because it would otherwise inherit them from its group
+	Err        error           // Why the client was not updated, nil on success
+}
+
+const (
+	maxDisplayNameLength = 64
+	maxLocationLength    = 64
+	maxNotesLength       = 2000
+)
+
 var supportedDownscaleResolutions = []string{"", "360p", "480p", "640x480", "720p", "800x600", "1024x768", "1080p"}
 var supportedCaptureCodecs = []string{"MJPG", "YUYV", "H264"}
 var supportedOutputCodecs = []string{"libx264", "libopenh264", "libx265", "libvpx-vp9", "ffv1"}
@@ -65,20 +90,51 @@ var supportedOutputFormats = []string{"mp4", "avi", "mkv", "webm", "mov"}
 var supportedVideoBitrates = []string{"500k", "1000k", "1500k", "4000k", "8000k", "15000k"}
 
 type ClientService interface {
-	// CreateClient creates a new client with the given details
-	CreateClient(req CreateClientRequest, mekStore encryption.MekStore) (client *Client, secret []byte, err error)
+	// CreateClient creates a new client with the given details. Its settings are recorded as the first settings version.
+	CreateClient(req CreateClientRequest, mekStore encryption.MekStore, createdBy string) (client *Client, secret []byte, err error)
 	// GetClient retrieves a client by its ID
 	GetClient(id string) (*Client, error)
 	// GetClients retrieves all clients
 	GetClients() ([]*Client, error)
-	// UpdateClientSettings updates the settings for a client
-	UpdateClientSettings(req UpdateClientSettingsRequest) error
+	// CloneClient creates a new client with the settings, group membership, schedule and arm mode behaviour of an existing
+	// client. The new client gets its own credentials; descriptive details such as the display name are not copied.
+	CloneClient(sourceID, id string, mekStore encryption.MekStore, createdBy string) (client *Client, secret []byte, err error)
+	// UpdateClientSettings updates the settings for a client and records them as a new settings version. A client in a
+	// group keeps inheriting the settings it does not override; a different value for one of them is rejected.
+	UpdateClientSettings(req UpdateClientSettingsRequest, changedBy string) error
+	// UpdateClientSettingsAndGroup updates the settings, the group (empty for none) and the overridden settings of a
+	// client in one change with one settings version. Settings that are not overridden take the group template; a
+	// different value for one of them is rejected.
+	UpdateClientSettingsAndGroup(req UpdateClientSettingsRequest, groupID string, overrides []string, changedBy string) error
+	// BulkUpdateClientSettings applies the settings with the given keys (see SettingKeys) to several clients. Each client
+	// is validated and updated on its own, so one invalid client does not stop the others. An error is only returned
+	// for unknown keys, before any client is changed.
+	BulkUpdateClientSettings(ids []string, settings ClientSettings, keys []string, changedBy string) ([]BulkUpdateResult, error)
+	// GetSettingsVersions retrieves the settings history of a client, newest first
+	GetSettingsVersions(id string) ([]*SettingsVersion, error)
+	// GetSettingsVersion retrieves a single settings version of a client, or nil if it does not exist
+	GetSettingsVersion(id string, version int) (*SettingsVersion, error)
+	// RollbackClientSettings restores the settings of an earlier version. The rollback is recorded as a new version.
+	// A client in a group keeps the template values of the settings it does not override.
+	RollbackClientSettings(id string, version int, changedBy string) error
+	// UpdateClientDetails updates the display name, location, timezone and notes of a client
+	UpdateClientDetails(req UpdateClientDetailsRequest, changedBy string) error
+	// UpdateClientSchedule replaces the recording schedule of a client and the timezone it is evaluated in, and records
+	// them as a new settings version
+	UpdateClientSchedule(id string, timezone string, schedule []ScheduleEntry, changedBy string) error
 	// DeleteClient deletes a client by its ID
 	DeleteClient(id string) error
+	// ArchiveClient disables a client and copies it to a new archived record, which takes over its settings history.
+	// The archived record has no credentials and cannot connect. It is meant to keep the clips of a client that is
+	// being deleted: the caller moves the clips to the returned record and then deletes the original client.
+	ArchiveClient(id string) (*Client, error)
 	// DisableClient disables a client (soft delete)
 	DisableClient(id string) error
 	// EnableClient enables a disabled client
 	EnableClient(id string) error
+	// RotateClientSecret generates a new secret for a client and re-wraps the MEK with it.
+	// If gracePeriod is positive, the previous secret keeps working until the grace period has passed.
+	RotateClientSecret(id string, gracePeriod time.Duration, mekStore encryption.MekStore) (client *Client, secret []byte, err error)
 	// GetSupportedDownscaleResolutions returns a list of supported downscale resolutions
 	GetSupportedDownscaleResolutions() []string
 	// GetSupportedCaptureCodecs returns a list of supported capture codecs
@@ -92,25 +148,46 @@ type ClientService interface {
 }
 
 type clientService struct {
-	logger    logging.Logger
-	repo      ClientRepository
-	encryptor encryption.Encryptor
+	logger      logging.Logger
+	repo        ClientRepository
+	versionRepo SettingsVersionRepository
+	groupRepo   ClientGroupRepository
+	history     settingsHistory
+	encryptor   encryption.Encryptor
+}
+
+// groupMembership is the group of a client together with the settings it overrides
+type groupMembership struct {
+	groupID   string
+	overrides []string
+}
+
+// settingsChange describes what updateClientSettings changes besides the settings of the request, and why
+type settingsChange struct {
+	changedBy  string
+	reason     string
+	restored   *ClientSettings  // Settings version whose timezone and schedule are restored, nil to keep them
+	membership *groupMembership // New group and overrides, nil to keep the current ones
+	inherit    bool             // Replace differing inherited settings with the group template instead of rejecting them
 }
 
-func NewClientService(logger logging.Logger, repo ClientRepository, encryptor encryption.Encryptor) *clientService {
+func NewClientService(logger logging.Logger, repo ClientRepository, versionRepo SettingsVersionRepository, groupRepo ClientGroupRepository, encryptor encryption.Encryptor) *clientService {
 
 	if logger == nil {
 		logger = logging.NopLogger
 	}
 
 	return &clientService{
-		logger:    logger,
-		repo:      repo,
-		encryptor: encryptor,
+		logger:      logger,
+		repo:        repo,
+		versionRepo: versionRepo,
+		groupRepo:   groupRepo,
+		history:     settingsHistory{repo: versionRepo},
+		encryptor:   encryptor,
 	}
 }
 
-func (s *clientService) validateClientSettings(req UpdateClientSettingsRequest) error {
+func validateClientSettings(req UpdateClientSettingsRequest) error {
 	if req.ClipDurationSeconds < 30 || req.ClipDurationSeconds > 1800 { // 30 minutes = 1800 seconds
 		return NewClientValidationError("clip duration must be between 30 and 1800 seconds")
 	}
@@ -138,9 +215,29 @@ func (s *clientService) validateClientSettings(req UpdateClientSettingsRequest)
 	return nil
 }
 
-func (s *clientService) CreateClient(req CreateClientRequest, mekStore encryption.MekStore) (*Client, []byte, error) {
+func (s *clientService) CreateClient(req CreateClientRequest, mekStore encryption.MekStore, createdBy string) (*Client, []byte, error) {
+	return s.createClient(req, mekStore, createdBy, "Created", nil)
+}
+
+func (s *clientService) CloneClient(sourceID, id string, mekStore encryption.MekStore, createdBy string) (*Client, []byte, error) {
+	source, err := s.repo.GetByID(context.Background(), sourceID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client to clone", err)
+		return nil, nil, err
+	}
+	if source == nil {
+		return nil, nil, NewClientNotFoundError(sourceID)
+	}
+
+	s.logger.Info("Cloning client", "source", sourceID, "id", id)
+	return s.createClient(settingsOf(source).createRequest(id), mekStore, createdBy, "Cloned from "+sourceID, source)
+}
+
+// createClient creates a client and records its settings as the first version with the given reason. If source is not
+// nil, the client also takes over its group membership, schedule and arm mode behaviour.
+func (s *clientService) createClient(req CreateClientRequest, mekStore encryption.MekStore, createdBy, reason string, source *Client) (*Client, []byte, error) {
 	updateReq := UpdateClientSettingsRequest(req)
-	if err := s.validateClientSettings(updateReq); err != nil {
+	if err := validateClientSettings(updateReq); err != nil {
 		return nil, nil, err
 	}
 
@@ -166,25 +263,6 @@ func (s *clientService) CreateClient(req CreateClientRequest, mekStore encryptio
 		return nil, nil, NewClientAlreadyExistsError(id)
 	}
 
-	// Generate a new secret for the client
-	secret, err := s.encryptor.GenerateKey()
-	if err != nil {
-		s.logger.Error("Failed to generate client secret", err)
-		return nil, nil, err
-	}
-
-	// hash the secret
-	hashedSecret, salt, err := s.encryptor.Hash(secret)
-	if err != nil {
-		s.logger.Error("Failed to hash client secret", err)
-		return nil, nil, err
-	}
-
-	hashedSecretBase64 := base64.StdEncoding.EncodeToString(hashedSecret)
-	saltBase64 := base64.StdEncoding.EncodeToString(salt)
-
-	now := time.Now().UTC()
-
 	// Get the MEK from the store
 	// The MEK is guaranteed to not be nil. If it is not found, an error will be returned.
 	mek, err := mekStore.GetMek()
@@ -193,36 +271,23 @@ func (s *clientService) CreateClient(req CreateClientRequest, mekStore encryptio
 		return nil, nil, err
 	}
 
-	// Generate a key-derivation salt
-	keyDerivationSalt, err := s.encryptor.GenerateSalt()
-	if err != nil {
-		s.logger.Error("Failed to generate key-derivation salt", err)
-		return nil, nil, err
-	}
-
-	// Derive a key from the secret
-	secretDerivedKey, err := s.encryptor.DeriveKeyFromSecret(secret, keyDerivationSalt)
+	// Generate a new secret for the client and wrap the MEK with it
+	secret, credentials, err := s.generateCredentials(mek)
 	if err != nil {
-		s.logger.Error("Failed to derive key from secret", err)
 		return nil, nil, err
 	}
 
-	// Re-encrypt the MEK using the new client's secret
-	encryptedMek, err := s.encryptor.Encrypt(mek, secretDerivedKey)
-	if err != nil {
-		s.logger.Error("Failed to encrypt MEK", err)
-		return nil, nil, err
-	}
+	now := time.Now().UTC()
 
 	// creat the client
 	client := &Client{
 		ID:                    id,
-		SecretHash:            hashedSecretBase64,
-		SecretSalt:            saltBase64,
+		SecretHash:            credentials.secretHash,
+		SecretSalt:            credentials.secretSalt,
 		CreatedAt:             now,
 		UpdatedAt:             now,
-		EncryptedMek:          base64.StdEncoding.EncodeToString(encryptedMek),
-		KeyDerivationSalt:     base64.StdEncoding.EncodeToString(keyDerivationSalt),
+		EncryptedMek:          credentials.encryptedMek,
+		KeyDerivationSalt:     credentials.keyDerivationSalt,
 		StorageLimitMegabytes: req.StorageLimitMegabytes,
 		ClipDurationSeconds:   req.ClipDurationSeconds,
 		MotionOnly:            req.MotionOnly,
@@ -244,6 +309,20 @@ func (s *clientService) CreateClient(req CreateClientRequest, mekStore encryptio
 		CaptureFrameRate:      req.CaptureFrameRate,
 	}
 
+	if source != nil {
+		client.GroupID = source.GroupID
+		client.SettingsOverrides = slices.Clone(source.SettingsOverrides)
+		client.Timezone = source.Timezone
+		client.Schedule = slices.Clone(source.Schedule)
+		client.HomeBehavior = source.HomeBehavior
+		client.AwayBehavior = source.AwayBehavior
+	}
+
+	if err := s.history.record(ctx, client, nil, createdBy, reason); err != nil {
+		s.logger.Error("Failed to record settings version", err)
+		return nil, nil, err
+	}
+
 	// Save the client to the repository
 	if err := s.repo.Create(ctx, client); err != nil {
 		s.logger.Error("Failed to save client to repository", err)
@@ -288,10 +367,36 @@ func (s *clientService) GetClients() ([]*Client, error) {
 	return clients, nil
 }
 
-func (s *clientService) UpdateClientSettings(req UpdateClientSettingsRequest) error {
-	if err := s.validateClientSettings(req); err != nil {
+func (s *clientService) UpdateClientSettings(req UpdateClientSettingsRequest, changedBy string) error {
+	return s.updateClientSettings(req, settingsChange{changedBy: changedBy, reason: "Updated"})
+}
+
+func (s *clientService) UpdateClientSettingsAndGroup(req UpdateClientSettingsRequest, groupID string, overrides []string, changedBy string) error {
+	keys := SettingKeys()
+	for _, key := range overrides {
+		if !slices.Contains(keys, key) {
+			return NewClientValidationError("unknown setting: " + key)
+		}
+	}
+
+	membership := &groupMembership{groupID: groupID}
+	if groupID != "" {
+		membership.overrides = slices.Compact(slices.Sorted(slices.Values(overrides)))
+	}
+	return s.updateClientSettings(req, settingsChange{changedBy: changedBy, reason: "Updated", membership: membership})
+}
+
+// updateClientSettings updates the settings for a client, applies the template of its group to the settings it does not
+// override and records the change in the settings history. Everything is stored with a single update.
+func (s *clientService) updateClientSettings(req UpdateClientSettingsRequest, change settingsChange) error {
+	if err := validateClientSettings(req); err != nil {
 		return err
 	}
+	if change.restored != nil {
+		if err := validateSchedule(change.restored.Timezone, change.restored.Schedule); err != nil {
+			return err
+		}
+	}
 
 	s.logger.Info("Updating client settings", "id", req.ID)
 
@@ -308,7 +413,66 @@ func (s *clientService) UpdateClientSettings(req UpdateClientSettingsRequest) er
 		return NewClientNotFoundError(req.ID)
 	}
 
+	before := settingsOf(client)
+	previousGroupID := client.GroupID
+	if change.membership != nil {
+		client.GroupID = change.membership.groupID
+		client.SettingsOverrides = change.membership.overrides
+	}
+
+	var group *ClientGroup
+	if client.GroupID != "" {
+		group, err = s.groupRepo.GetByID(ctx, client.GroupID)
+		if err != nil {
+			s.logger.Error("Failed to retrieve client group", err)
+			return err
+		}
+		if group == nil {
+			return NewClientGroupNotFoundError(client.GroupID)
+		}
+	}
+
+	reason := change.reason
+	if group != nil && client.GroupID != previousGroupID {
+		reason += ", assigned to group " + group.Name
+	} else if group == nil && previousGroupID != "" {
+		reason += ", removed from group"
+	}
+
 	// Update the client's settings
+	applySettingsRequest(client, req)
+	if change.restored != nil {
+		client.Timezone = change.restored.Timezone
+		client.Schedule = slices.Clone(change.restored.Schedule)
+	}
+
+	if group != nil {
+		if !change.inherit {
+			if err := checkInheritedSettings(client, before); err != nil {
+				return err
+			}
+		}
+		group.applyTemplate(client)
+	}
+	client.UpdatedAt = time.Now().UTC()
+
+	if err := s.history.record(ctx, client, &before, change.changedBy, reason); err != nil {
+		s.logger.Error("Failed to record settings version", err)
+		return err
+	}
+
+	// Save the updated client to the repository
+	if err := s.repo.Update(ctx, client); err != nil {
+		s.logger.Error("Failed to update client in repository", err)
+		return err
+	}
+
+	s.logger.Info("Successfully updated client settings", "id", client.ID)
+	return nil
+}
+
+// applySettingsRequest copies the settings of a request into a client
+func applySettingsRequest(client *Client, req UpdateClientSettingsRequest) {
 	client.StorageLimitMegabytes = req.StorageLimitMegabytes
 	client.ClipDurationSeconds = req.ClipDurationSeconds
 	client.MotionOnly = req.MotionOnly
@@ -328,15 +492,213 @@ func (s *clientService) UpdateClientSettings(req UpdateClientSettingsRequest) er
 	client.MotionMogVarThresh = req.MotionMogVarThresh
 	client.CaptureCodec = req.CaptureCodec
 	client.CaptureFrameRate = req.CaptureFrameRate
+}
+
+// checkInheritedSettings rejects changes to the settings that a group member inherits, which the group template would
+// otherwise silently undo
+func checkInheritedSettings(client *Client, before ClientSettings) error {
+	previous, submitted := before.values(), settingsOf(client).values()
+	for _, key := range SettingKeys() {
+		if !client.IsOverridden(key) && submitted[key] != previous[key] {
+			return NewClientValidationError(fmt.Sprintf("setting %s is inherited from the group, override it to change it", key))
+		}
+	}
+	return nil
+}
+
+func (s *clientService) BulkUpdateClientSettings(ids []string, settings ClientSettings, keys []string, changedBy string) ([]BulkUpdateResult, error) {
+	// Merging into empty settings rejects unknown keys before any client is touched
+	if _, err := (ClientSettings{}).merge(settings, keys); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Bulk updating client settings", "clients", len(ids), "settings", strings.Join(keys, ","))
+
+	results := make([]BulkUpdateResult, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, s.bulkUpdateClient(id, settings, keys, changedBy))
+	}
+	return results, nil
+}
+
+// bulkUpdateClient applies the settings of a bulk update to one client. Settings the client inherits from its group are
+// marked as overridden in the same update, so that the group template does not undo the change.
+func (s *clientService) bulkUpdateClient(id string, settings ClientSettings, keys []string, changedBy string) BulkUpdateResult {
+	result := BulkUpdateResult{ClientID: id}
+	ctx := context.Background()
+
+	client, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		result.Err = err
+		return result
+	}
+	if client == nil {
+		result.Err = NewClientNotFoundError(id)
+		return result
+	}
+
+	before := settingsOf(client)
+	merged, err := before.merge(settings, keys)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	change := settingsChange{changedBy: changedBy, reason: "Bulk edit"}
+	if client.GroupID != "" {
+		for _, key := range keys {
+			if !client.IsOverridden(key) {
+				result.Overridden = append(result.Overridden, key)
+			}
+		}
+		overrides := slices.Sorted(slices.Values(append(slices.Clone(client.SettingsOverrides), result.Overridden...)))
+		change.membership = &groupMembership{groupID: client.GroupID, overrides: overrides}
+	}
+
+	if err := s.updateClientSettings(merged.Request(id), change); err != nil {
+		result.Overridden = nil
+		result.Err = err
+		return result
+	}
+	result.Changes = DiffSettings(before, merged)
+	return result
+}
+
+func (s *clientService) GetSettingsVersions(id string) ([]*SettingsVersion, error) {
+	versions, err := s.versionRepo.GetByClientID(context.Background(), id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve settings versions", err)
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (s *clientService) GetSettingsVersion(id string, version int) (*SettingsVersion, error) {
+	settingsVersion, err := s.versionRepo.GetVersion(context.Background(), id, version)
+	if err != nil {
+		s.logger.Error("Failed to retrieve settings version", err)
+		return nil, err
+	}
+	return settingsVersion, nil
+}
+
+func (s *clientService) RollbackClientSettings(id string, version int, changedBy string) error {
+	settingsVersion, err := s.GetSettingsVersion(id, version)
+	if err != nil {
+		return err
+	}
+	if settingsVersion == nil {
+		return NewClientValidationError(fmt.Sprintf("settings version %d does not exist", version))
+	}
+
+	s.logger.Info("Rolling back client settings", "id", id, "version", version)
+	var restored *ClientSettings
+	if !settingsVersion.Settings.withoutSchedule {
+		restored = &settingsVersion.Settings
+	}
+	return s.updateClientSettings(settingsVersion.Settings.Request(id), settingsChange{
+		changedBy: changedBy,
+		reason:    fmt.Sprintf("Rolled back to version %d", version),
+		restored:  restored,
+		inherit:   true, // The template may have changed since the version was recorded
+	})
+}
+
+func validateClientDetails(req UpdateClientDetailsRequest) error {
+	if len(req.DisplayName) > maxDisplayNameLength {
+		return NewClientValidationError(fmt.Sprintf("display name must not be longer than %d characters", maxDisplayNameLength))
+	}
+	if len(req.Location) > maxLocationLength {
+		return NewClientValidationError(fmt.Sprintf("location must not be longer than %d characters", maxLocationLength))
+	}
+	if len(req.Notes) > maxNotesLength {
+		return NewClientValidationError(fmt.Sprintf("notes must not be longer than %d characters", maxNotesLength))
+	}
+	return validateTimezone(req.Timezone)
+}
+
+func (s *clientService) UpdateClientDetails(req UpdateClientDetailsRequest, changedBy string) error {
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	req.Location = strings.TrimSpace(req.Location)
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	req.Notes = strings.TrimSpace(req.Notes)
+	if err := validateClientDetails(req); err != nil {
+		return err
+	}
+
+	s.logger.Info("Updating client details", "id", req.ID)
+
+	ctx := context.Background()
+
+	client, err := s.repo.GetByID(ctx, req.ID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return err
+	}
+	if client == nil {
+		s.logger.Info("Client not found", "id", req.ID)
+		return NewClientNotFoundError(req.ID)
+	}
+
+	before := settingsOf(client)
+	client.DisplayName = req.DisplayName
+	client.Location = req.Location
+	client.Timezone = req.Timezone
+	client.Notes = req.Notes
 	client.UpdatedAt = time.Now().UTC()
 
-	// Save the updated client to the repository
+	// The timezone is versioned along with the schedule it applies to; the other details are not
+	if err := s.history.record(ctx, client, &before, changedBy, "Timezone changed"); err != nil {
+		s.logger.Error("Failed to record settings version", err)
+		return err
+	}
+
 	if err := s.repo.Update(ctx, client); err != nil {
-		s.logger.Error("Failed to update client in repository", err)
+		s.logger.Error("Failed to update client details", err)
 		return err
 	}
 
-	s.logger.Info("Successfully updated client settings", "id", client.ID)
+	s.logger.Info("Successfully updated client details", "id", req.ID)
+	return nil
+}
+
+func (s *clientService) UpdateClientSchedule(id string, timezone string, schedule []ScheduleEntry, changedBy string) error {
+	timezone = strings.TrimSpace(timezone)
+	if err := validateSchedule(timezone, schedule); err != nil {
+		return err
+	}
+
+	s.logger.Info("Updating client schedule", "id", id, "entries", len(schedule))
+
+	ctx := context.Background()
+
+	client, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return err
+	}
+	if client == nil {
+		s.logger.Info("Client not found", "id", id)
+		return NewClientNotFoundError(id)
+	}
+
+	before := settingsOf(client)
+	client.Timezone = timezone
+	client.Schedule = schedule
+	client.UpdatedAt = time.Now().UTC()
+
+	if err := s.history.record(ctx, client, &before, changedBy, "Schedule updated"); err != nil {
+		s.logger.Error("Failed to record settings version", err)
+		return err
+	}
+
+	if err := s.repo.Update(ctx, client); err != nil {
+		s.logger.Error("Failed to update client schedule", err)
+		return err
+	}
+
+	s.logger.Info("Successfully updated client schedule", "id", id)
 	return nil
 }
 
@@ -382,10 +744,84 @@ func (s *clientService) DeleteClient(id string) error {
 		return err
 	}
 
+	if err := s.versionRepo.DeleteByClientID(ctx, id); err != nil {
+		s.logger.Error("Failed to delete settings history", err)
+		return err
+	}
+
 	s.logger.Info("Successfully deleted client", "id", id)
 	return nil
 }
 
+func (s *clientService) ArchiveClient(id string) (*Client, error) {
+	s.logger.Info("Archiving client", "id", id)
+
+	ctx := context.Background()
+
+	client, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return nil, err
+	}
+	if client == nil {
+		return nil, NewClientNotFoundError(id)
+	}
+	if client.IsArchived() {
+		return nil, NewClientValidationError(fmt.Sprintf("client %s is already archived", id))
+	}
+
+	now := time.Now().UTC()
+
+	// Disable the original first, so that it stops uploading while its clips are moved
+	if !client.IsDisabled {
+		client.IsDisabled = true
+		client.UpdatedAt = now
+		if err := s.repo.Update(ctx, client); err != nil {
+			s.logger.Error("Failed to disable client", err)
+			return nil, err
+		}
+	}
+
+	archived := *client
+	archived.ID = fmt.Sprintf("%s-archived-%s", id, now.Format("20060102-150405"))
+	archived.DisplayName = client.Name()
+	archived.UpdatedAt = now
+	archived.ArchivedAt = &now
+	archived.IsDisabled = true
+
+	// The archived record only holds clips, so it gets no credentials, certificates or group membership
+	archived.SecretHash, archived.SecretSalt = "", ""
+	archived.EncryptedMek, archived.KeyDerivationSalt = "", ""
+	archived.PreviousSecretHash, archived.PreviousSecretSalt = "", ""
+	archived.PreviousEncryptedMek, archived.PreviousKeyDerivationSalt = "", ""
+	archived.PreviousSecretExpiresAt = nil
+	archived.CertificateSerial, archived.PreviousCertificateSerial = "", ""
+	archived.GroupID = ""
+	archived.SettingsOverrides = nil
+
+	existing, err := s.repo.GetByID(ctx, archived.ID)
+	if err != nil {
+		s.logger.Error("Failed to check for existing client", err)
+		return nil, err
+	}
+	if existing != nil {
+		return nil, NewClientAlreadyExistsError(archived.ID)
+	}
+
+	if err := s.repo.Create(ctx, &archived); err != nil {
+		s.logger.Error("Failed to save archived client", err)
+		return nil, err
+	}
+
+	if err := s.versionRepo.ReassignClient(ctx, id, archived.ID); err != nil {
+		s.logger.Error("Failed to move settings history to archived client", err)
+		return nil, err
+	}
+
+	s.logger.Info("Successfully archived client", "id", id, "archivedId", archived.ID)
+	return &archived, nil
+}
+
 func (s *clientService) DisableClient(id string) error {
 	s.logger.Info("Disabling client", "id", id)
 
@@ -430,6 +866,9 @@ func (s *clientService) EnableClient(id string) error {
 		s.logger.Info("Client not found", "id", id)
 		return nil // No error if the client does not exist
 	}
+	if client.IsArchived() {
+		return NewClientValidationError(fmt.Sprintf("client %s is archived and cannot be enabled", id))
+	}
 
 	// Update the client to enable it
 	client.IsDisabled = false
@@ -443,3 +882,120 @@ func (s *clientService) EnableClient(id string) error {
 	s.logger.Info("Successfully enabled client", "id", id)
 	return nil
 }
+
+func (s *clientService) RotateClientSecret(id string, gracePeriod time.Duration, mekStore encryption.MekStore) (*Client, []byte, error) {
+	s.logger.Info("Rotating client secret", "id", id, "grace_period", gracePeriod)
+
+	if gracePeriod < 0 {
+		return nil, nil, NewClientValidationError("grace period cannot be negative")
+	}
+
+	ctx := context.Background()
+
+	client, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return nil, nil, err
+	}
+	if client == nil {
+		s.logger.Info("Client not found", "id", id)
+		return nil, nil, NewClientNotFoundError(id)
+	}
+
+	mek, err := mekStore.GetMek()
+	if err != nil {
+		s.logger.Error("Failed to get MEK from store", err)
+		return nil, nil, err
+	}
+
+	secret, credentials, err := s.generateCredentials(mek)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now().UTC()
+
+	// Keep the current credentials as the previous ones if a grace period was requested.
+	// Any older previous credentials are dropped, so only one old secret is ever accepted.
+	if gracePeriod > 0 {
+		expiresAt := now.Add(gracePeriod)
+		client.PreviousSecretHash = client.SecretHash
+		client.PreviousSecretSalt = client.SecretSalt
+		client.PreviousEncryptedMek = client.EncryptedMek
+		client.PreviousKeyDerivationSalt = client.KeyDerivationSalt
+		client.PreviousSecretExpiresAt = &expiresAt
+	} else {
+		client.PreviousSecretHash = ""
+		client.PreviousSecretSalt = ""
+		client.PreviousEncryptedMek = ""
+		client.PreviousKeyDerivationSalt = ""
+		client.PreviousSecretExpiresAt = nil
+	}
+
+	client.SecretHash = credentials.secretHash
+	client.SecretSalt = credentials.secretSalt
+	client.EncryptedMek = credentials.encryptedMek
+	client.KeyDerivationSalt = credentials.keyDerivationSalt
+	client.UpdatedAt = now
+
+	if err := s.repo.Update(ctx, client); err != nil {
+		s.logger.Error("Failed to save rotated client secret", err)
+		return nil, nil, err
+	}
+
+	s.logger.Info("Successfully rotated client secret", "id", id)
+	return client, secret, nil
+}
+
+// clientCredentials holds the stored, base 64 encoded representation of a client secret
+type clientCredentials struct {
+	secretHash        string
+	secretSalt        string
+	encryptedMek      string
+	keyDerivationSalt string
+}
+
+// generateCredentials generates a new client secret, hashes it and encrypts the MEK with a key derived from it
+func (s *clientService) generateCredentials(mek []byte) ([]byte, *clientCredentials, error) {
+	// Generate a new secret for the client
+	secret, err := s.encryptor.GenerateKey()
+	if err != nil {
+		s.logger.Error("Failed to generate client secret", err)
+		return nil, nil, err
+	}
+
+	// hash the secret
+	hashedSecret, salt, err := s.encryptor.Hash(secret)
+	if err != nil {
+		s.logger.Error("Failed to hash client secret", err)
+		return nil, nil, err
+	}
+
+	// Generate a key-derivation salt
+	keyDerivationSalt, err := s.encryptor.GenerateSalt()
+	if err != nil {
+		s.logger.Error("Failed to generate key-derivation salt", err)
+		return nil, nil, err
+	}
+
+	// Derive a key from the secret
+	secretDerivedKey, err := s.encryptor.DeriveKeyFromSecret(secret, keyDerivationSalt)
+	if err != nil {
+		s.logger.Error("Failed to derive key from secret", err)
+		return nil, nil, err
+	}
+
+	// Encrypt the MEK using the client's secret
+	encryptedMek, err := s.encryptor.Encrypt(mek, secretDerivedKey)
+	if err != nil {
+		s.logger.Error("Failed to encrypt MEK", err)
+		return nil, nil, err
+	}
+
+	return secret, &clientCredentials{
+		secretHash:        base64.StdEncoding.EncodeToString(hashedSecret),
+		secretSalt:        base64.StdEncoding.EncodeToString(salt),
+		encryptedMek:      base64.StdEncoding.EncodeToString(encryptedMek),
+		keyDerivationSalt: base64.StdEncoding.EncodeToString(keyDerivationSalt),
+	}, nil
+}
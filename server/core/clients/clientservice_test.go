@@ -0,0 +1,726 @@
+package clients
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+type testMekStore struct {
+	mek []byte
+}
+
+func (s *testMekStore) GetMek() ([]byte, error) { return s.mek, nil }
+func (s *testMekStore) SetMek(mek []byte) error { s.mek = mek; return nil }
+func (s *testMekStore) ClearMek() error         { s.mek = nil; return nil }
+
+func createTestClientViaService(t *testing.T, service *clientService, mekStore encryption.MekStore) (*Client, string) {
+	client, secret, err := service.CreateClient(CreateClientRequest{
+		ID:                    "rotating-client",
+		StorageLimitMegabytes: 1024,
+		ClipDurationSeconds:   60,
+		OutputFormat:          "mp4",
+		OutputCodec:           "libx264",
+		VideoBitRate:          "1000k",
+		CaptureCodec:          "MJPG",
+		CaptureFrameRate:      15,
+	}, mekStore, "admin")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client, hex.EncodeToString(secret)
+}
+
+func newTestVersionRepo(t *testing.T, repo *SQLiteClientRepository) *SQLiteSettingsVersionRepository {
+	t.Helper()
+
+	versionRepo, err := NewSQLiteSettingsVersionRepository(repo.db)
+	if err != nil {
+		t.Fatalf("Failed to create settings version repository: %v", err)
+	}
+	return versionRepo
+}
+
+func newTestGroupRepo(t *testing.T, repo *SQLiteClientRepository) *SQLiteClientGroupRepository {
+	t.Helper()
+
+	groupRepo, err := NewSQLiteClientGroupRepository(repo.db)
+	if err != nil {
+		t.Fatalf("Failed to create group repository: %v", err)
+	}
+	return groupRepo
+}
+
+func TestClientService_RotateClientSecret(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	verifier := NewClientVerifier(repo, encryptor)
+	mekProvider := NewClientMekProvider(encryptor, repo, verifier)
+
+	mek, _ := encryptor.GenerateKey()
+	mekStore := &testMekStore{mek: mek}
+
+	client, oldSecret := createTestClientViaService(t, service, mekStore)
+
+	rotated, newSecretBytes, err := service.RotateClientSecret(client.ID, 0, mekStore)
+	if err != nil {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+	newSecret := hex.EncodeToString(newSecretBytes)
+
+	if newSecret == oldSecret {
+		t.Fatal("Expected a new secret")
+	}
+	if rotated.KeyDerivationSalt == client.KeyDerivationSalt {
+		t.Error("Expected a new key derivation salt")
+	}
+	if rotated.PreviousSecretHash != "" || rotated.PreviousSecretExpiresAt != nil {
+		t.Error("Expected no previous secret without a grace period")
+	}
+
+	if _, _, err := verifier.VerifyClient(client.ID, oldSecret); !IsClientVerificationError(err) {
+		t.Errorf("Expected old secret to be rejected, got %v", err)
+	}
+
+	uncovered, err := mekProvider.UncoverMek(client.ID, newSecret)
+	if err != nil {
+		t.Fatalf("Failed to uncover MEK with new secret: %v", err)
+	}
+	if string(uncovered) != string(mek) {
+		t.Error("Uncovered MEK does not match")
+	}
+}
+
+func TestClientService_RotateClientSecret_GracePeriod(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	verifier := NewClientVerifier(repo, encryptor)
+	mekProvider := NewClientMekProvider(encryptor, repo, verifier)
+
+	mek, _ := encryptor.GenerateKey()
+	mekStore := &testMekStore{mek: mek}
+
+	client, oldSecret := createTestClientViaService(t, service, mekStore)
+
+	_, newSecretBytes, err := service.RotateClientSecret(client.ID, time.Hour, mekStore)
+	if err != nil {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+
+	for name, secret := range map[string]string{"old": oldSecret, "new": hex.EncodeToString(newSecretBytes)} {
+		uncovered, err := mekProvider.UncoverMek(client.ID, secret)
+		if err != nil {
+			t.Fatalf("Failed to uncover MEK with %s secret: %v", name, err)
+		}
+		if string(uncovered) != string(mek) {
+			t.Errorf("Uncovered MEK with %s secret does not match", name)
+		}
+	}
+
+	// Expire the grace period
+	stored, _ := repo.GetByID(t.Context(), client.ID)
+	expired := time.Now().UTC().Add(-time.Minute)
+	stored.PreviousSecretExpiresAt = &expired
+	if err := repo.Update(t.Context(), stored); err != nil {
+		t.Fatalf("Failed to update client: %v", err)
+	}
+
+	if _, _, err := verifier.VerifyClient(client.ID, oldSecret); !IsClientVerificationError(err) {
+		t.Errorf("Expected old secret to be rejected after the grace period, got %v", err)
+	}
+}
+
+func TestClientService_RotateClientSecret_NotFound(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+
+	if _, _, err := service.RotateClientSecret("missing", 0, &testMekStore{mek: mek}); !IsClientNotFoundError(err) {
+		t.Errorf("Expected ClientNotFoundError, got %v", err)
+	}
+}
+
+func TestClientService_UpdateClientSchedule(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	schedule := []ScheduleEntry{
+		{Days: []time.Weekday{time.Monday, time.Friday}, Start: "22:00", End: "06:00", Mode: RecordingModeContinuous, Sensitivity: MotionSensitivityHigh},
+		{Days: []time.Weekday{time.Sunday}, Start: "18:00", End: "23:00", Mode: RecordingModeOff},
+	}
+	if err := service.UpdateClientSchedule(client.ID, "Europe/Berlin", schedule, "alice"); err != nil {
+		t.Fatalf("Failed to update schedule: %v", err)
+	}
+
+	stored, _ := service.GetClient(client.ID)
+	if stored.Timezone != "Europe/Berlin" {
+		t.Errorf("Expected timezone Europe/Berlin, got %q", stored.Timezone)
+	}
+	if len(stored.Schedule) != 2 || stored.Schedule[0].Sensitivity != MotionSensitivityHigh || stored.Schedule[1].Mode != RecordingModeOff {
+		t.Errorf("Schedule was not stored correctly: %+v", stored.Schedule)
+	}
+	if len(stored.Schedule[0].Days) != 2 || stored.Schedule[0].Days[1] != time.Friday {
+		t.Errorf("Schedule days were not stored correctly: %v", stored.Schedule[0].Days)
+	}
+
+	invalid := map[string][]ScheduleEntry{
+		"no days":          {{Start: "08:00", End: "09:00", Mode: RecordingModeOff}},
+		"bad time":         {{Days: []time.Weekday{time.Monday}, Start: "8am", End: "09:00", Mode: RecordingModeOff}},
+		"bad mode":         {{Days: []time.Weekday{time.Monday}, Start: "08:00", End: "09:00", Mode: "sometimes"}},
+		"bad sensitivity":  {{Days: []time.Weekday{time.Monday}, Start: "08:00", End: "09:00", Mode: RecordingModeOff, Sensitivity: "extreme"}},
+		"day out of range": {{Days: []time.Weekday{7}, Start: "08:00", End: "09:00", Mode: RecordingModeOff}},
+	}
+	for name, entries := range invalid {
+		if err := service.UpdateClientSchedule(client.ID, "", entries, "alice"); !IsClientValidationError(err) {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+	if err := service.UpdateClientSchedule(client.ID, "Mars/Olympus_Mons", nil, "alice"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for unknown timezone, got %v", err)
+	}
+
+	// Clearing the schedule
+	if err := service.UpdateClientSchedule(client.ID, "", nil, "alice"); err != nil {
+		t.Fatalf("Failed to clear schedule: %v", err)
+	}
+	stored, _ = service.GetClient(client.ID)
+	if len(stored.Schedule) != 0 || stored.Timezone != "" {
+		t.Error("Expected schedule to be cleared")
+	}
+}
+
+func TestClientService_SettingsHistory(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	if client.SettingsVersion != 1 {
+		t.Fatalf("Expected a new client to start at settings version 1, got %d", client.SettingsVersion)
+	}
+
+	req := settingsOf(client).Request(client.ID)
+	req.ClipDurationSeconds = 120
+	req.MotionMinArea = 2500
+	if err := service.UpdateClientSettings(req, "alice"); err != nil {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+
+	// Saving unchanged settings does not add a version
+	if err := service.UpdateClientSettings(req, "alice"); err != nil {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+
+	versions, err := service.GetSettingsVersions(client.ID)
+	if err != nil {
+		t.Fatalf("Failed to get settings versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[0].ChangedBy != "alice" {
+		t.Fatalf("Expected version 2 by alice on top of the history, got %+v", versions)
+	}
+
+	changes := DiffSettings(versions[1].Settings, versions[0].Settings)
+	if len(changes) != 2 || changes[0] != (SettingChange{Key: "clip_duration", Old: "60", New: "120"}) || changes[1].Key != "motion_min_area" {
+		t.Errorf("Unexpected diff: %+v", changes)
+	}
+
+	if err := service.RollbackClientSettings(client.ID, 1, "bob"); err != nil {
+		t.Fatalf("Failed to roll back settings: %v", err)
+	}
+
+	stored, _ := service.GetClient(client.ID)
+	if stored.ClipDurationSeconds != 60 || stored.MotionMinArea != client.MotionMinArea {
+		t.Error("Expected the settings of version 1 to be restored")
+	}
+	if stored.SettingsVersion != 3 {
+		t.Errorf("Expected the rollback to be recorded as version 3, got %d", stored.SettingsVersion)
+	}
+
+	rollback, _ := service.GetSettingsVersion(client.ID, 3)
+	if rollback == nil || rollback.ChangedBy != "bob" || rollback.Reason != "Rolled back to version 1" {
+		t.Errorf("Unexpected rollback version: %+v", rollback)
+	}
+
+	if err := service.RollbackClientSettings(client.ID, 42, "bob"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for a missing version, got %v", err)
+	}
+}
+
+func TestDiffSettings_CoversAllSettingKeys(t *testing.T) {
+	values := ClientSettings{}.values()
+	if len(values) != len(versionedSettingKeys()) {
+		t.Fatalf("Expected %d settings, got %d", len(versionedSettingKeys()), len(values))
+	}
+	for _, key := range versionedSettingKeys() {
+		if _, ok := values[key]; !ok {
+			t.Errorf("Setting %s is missing from ClientSettings", key)
+		}
+	}
+}
+
+func TestDiffSettings_FormatsValuesByType(t *testing.T) {
+	from := ClientSettings{MotionMogVarThresh: 16, CaptureFrameRate: 1000000, MotionMinAspect: 0.25}
+	to := from
+	to.MotionOnly = true
+	to.CaptureFrameRate = 12.5
+	to.Schedule = []ScheduleEntry{{Days: []time.Weekday{time.Monday, time.Friday}, Start: "22:00", End: "06:00", Mode: RecordingModeOff, Sensitivity: MotionSensitivityHigh}}
+
+	changes := DiffSettings(from, to)
+	expected := []SettingChange{
+		{Key: "motion_only", Old: "false", New: "true"},
+		{Key: "capture_frame_rate", Old: "1000000", New: "12.5"},
+		{Key: "schedule", Old: "", New: "Mon,Fri 22:00-06:00 off (high)"},
+	}
+	if !slices.Equal(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+}
+
+func TestClientService_ScheduleIsVersioned(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	schedule := []ScheduleEntry{{Days: []time.Weekday{time.Saturday}, Start: "08:00", End: "20:00", Mode: RecordingModeMotionOnly}}
+	if err := service.UpdateClientSchedule(client.ID, "Europe/Berlin", schedule, "alice"); err != nil {
+		t.Fatalf("Failed to update schedule: %v", err)
+	}
+	stored, _ := service.GetClient(client.ID)
+	if stored.SettingsVersion != 2 {
+		t.Fatalf("Expected the schedule change to be recorded as version 2, got %d", stored.SettingsVersion)
+	}
+
+	version, _ := service.GetSettingsVersion(client.ID, 2)
+	changes := DiffSettings(settingsOf(client), version.Settings)
+	if len(changes) != 2 || changes[0].Key != "timezone" || changes[1] != (SettingChange{Key: "schedule", New: "Sat 08:00-20:00 motion_only"}) {
+		t.Errorf("Unexpected diff: %+v", changes)
+	}
+
+	// Changing only the display name records no version
+	if err := service.UpdateClientDetails(UpdateClientDetailsRequest{ID: client.ID, DisplayName: "Porch", Timezone: "Europe/Berlin"}, "alice"); err != nil {
+		t.Fatalf("Failed to update details: %v", err)
+	}
+	if stored, _ = service.GetClient(client.ID); stored.SettingsVersion != 2 {
+		t.Errorf("Expected no new version for the display name, got %d", stored.SettingsVersion)
+	}
+
+	if err := service.RollbackClientSettings(client.ID, 1, "bob"); err != nil {
+		t.Fatalf("Failed to roll back settings: %v", err)
+	}
+	stored, _ = service.GetClient(client.ID)
+	if stored.Timezone != "" || len(stored.Schedule) != 0 || stored.SettingsVersion != 3 {
+		t.Errorf("Expected the rollback to restore the empty schedule as version 3, got %q %+v version %d", stored.Timezone, stored.Schedule, stored.SettingsVersion)
+	}
+}
+
+func TestClientService_RollbackToVersionWithoutScheduleKeepsSchedule(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	versionRepo := newTestVersionRepo(t, repo)
+	service := NewClientService(nil, repo, versionRepo, newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	// A version recorded before the schedule was versioned
+	legacy := settingsOf(client)
+	legacy.ClipDurationSeconds = 30
+	data, _ := json.Marshal(legacy)
+	var values map[string]json.RawMessage
+	json.Unmarshal(data, &values)
+	delete(values, "timezone")
+	delete(values, "schedule")
+	data, _ = json.Marshal(values)
+	if _, err := versionRepo.db.Exec(`UPDATE client_settings_versions SET settings = ? WHERE client_id = ? AND version = 1`, string(data), client.ID); err != nil {
+		t.Fatalf("Failed to store legacy version: %v", err)
+	}
+
+	schedule := []ScheduleEntry{{Days: []time.Weekday{time.Sunday}, Start: "00:00", End: "00:00", Mode: RecordingModeOff}}
+	if err := service.UpdateClientSchedule(client.ID, "Europe/Berlin", schedule, "alice"); err != nil {
+		t.Fatalf("Failed to update schedule: %v", err)
+	}
+	versions, _ := service.GetSettingsVersions(client.ID)
+	for _, change := range DiffSettings(versions[1].Settings, versions[0].Settings) {
+		if change.Key == "schedule" || change.Key == "timezone" {
+			t.Errorf("Expected no schedule diff against a legacy version, got %+v", change)
+		}
+	}
+
+	if err := service.RollbackClientSettings(client.ID, 1, "bob"); err != nil {
+		t.Fatalf("Failed to roll back settings: %v", err)
+	}
+	stored, _ := service.GetClient(client.ID)
+	if stored.ClipDurationSeconds != 30 || stored.Timezone != "Europe/Berlin" || len(stored.Schedule) != 1 {
+		t.Errorf("Expected the legacy settings with the current schedule, got %d %q %+v", stored.ClipDurationSeconds, stored.Timezone, stored.Schedule)
+	}
+}
+
+func TestClientService_UpdateClientDetails(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	if client.Name() != client.ID {
+		t.Errorf("Expected a client without display name to be named by its ID, got %q", client.Name())
+	}
+
+	err := service.UpdateClientDetails(UpdateClientDetailsRequest{
+		ID:          client.ID,
+		DisplayName: "  Front Door ",
+		Location:    "Garage",
+		Timezone:    "Europe/Berlin",
+		Notes:       "Mounted above the gate",
+	}, "alice")
+	if err != nil {
+		t.Fatalf("Failed to update details: %v", err)
+	}
+
+	stored, _ := service.GetClient(client.ID)
+	if stored.Name() != "Front Door" || stored.Location != "Garage" || stored.Notes != "Mounted above the gate" {
+		t.Errorf("Details were not stored correctly: %+v", stored)
+	}
+	if stored.TimeLocation().String() != "Europe/Berlin" {
+		t.Errorf("Expected timezone Europe/Berlin, got %s", stored.TimeLocation())
+	}
+	// The timezone applies to the schedule and is versioned with it
+	if stored.SettingsVersion != client.SettingsVersion+1 {
+		t.Error("Expected the new timezone to record a settings version")
+	}
+
+	description := NewClientDirectory(nil, repo).DescribeClient(client.ID)
+	if description.Label() != "Front Door (Garage)" || description.Timezone.String() != "Europe/Berlin" {
+		t.Errorf("Unexpected client description: %+v", description)
+	}
+	if unknown := NewClientDirectory(nil, repo).DescribeClient("unknown"); unknown.Name != "unknown" {
+		t.Errorf("Expected an unknown client to be described by its ID, got %+v", unknown)
+	}
+
+	invalid := map[string]UpdateClientDetailsRequest{
+		"unknown timezone":  {ID: client.ID, Timezone: "Mars/Olympus_Mons"},
+		"long display name": {ID: client.ID, DisplayName: strings.Repeat("x", maxDisplayNameLength+1)},
+		"long notes":        {ID: client.ID, Notes: strings.Repeat("x", maxNotesLength+1)},
+	}
+	for name, req := range invalid {
+		if err := service.UpdateClientDetails(req, "alice"); !IsClientValidationError(err) {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+
+	if err := service.UpdateClientDetails(UpdateClientDetailsRequest{ID: "missing"}, "alice"); !IsClientNotFoundError(err) {
+		t.Errorf("Expected ClientNotFoundError, got %v", err)
+	}
+}
+
+func TestClientService_CloneClient(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	verifier := NewClientVerifier(repo, encryptor)
+	mek, _ := encryptor.GenerateKey()
+	mekStore := &testMekStore{mek: mek}
+	source, _ := createTestClientViaService(t, service, mekStore)
+
+	req := settingsOf(source).Request(source.ID)
+	req.VideoBitRate = "4000k"
+	req.MotionMinArea = 2500
+	if err := service.UpdateClientSettings(req, "alice"); err != nil {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+	schedule := []ScheduleEntry{{Days: []time.Weekday{time.Monday}, Start: "22:00", End: "06:00", Mode: RecordingModeContinuous}}
+	if err := service.UpdateClientSchedule(source.ID, "Europe/Berlin", schedule, "alice"); err != nil {
+		t.Fatalf("Failed to update schedule: %v", err)
+	}
+	if err := service.UpdateClientDetails(UpdateClientDetailsRequest{ID: source.ID, DisplayName: "Front Door", Timezone: "Europe/Berlin"}, "alice"); err != nil {
+		t.Fatalf("Failed to update details: %v", err)
+	}
+	source, _ = service.GetClient(source.ID)
+
+	clone, secret, err := service.CloneClient(source.ID, "cloned-client", mekStore, "bob")
+	if err != nil {
+		t.Fatalf("Failed to clone client: %v", err)
+	}
+
+	if !settingsOf(clone).equal(settingsOf(source)) {
+		t.Error("Expected the clone to have the settings of the source")
+	}
+	if clone.Timezone != "Europe/Berlin" || len(clone.Schedule) != 1 || clone.Schedule[0].Mode != RecordingModeContinuous {
+		t.Errorf("Expected the clone to have the schedule of the source, got %q %+v", clone.Timezone, clone.Schedule)
+	}
+	if clone.DisplayName != "" {
+		t.Errorf("Expected the display name not to be copied, got %q", clone.DisplayName)
+	}
+	if clone.SecretHash == source.SecretHash || clone.EncryptedMek == source.EncryptedMek {
+		t.Error("Expected the clone to get its own credentials")
+	}
+	if _, _, err := verifier.VerifyClient(clone.ID, hex.EncodeToString(secret)); err != nil {
+		t.Errorf("Expected the clone's secret to be accepted: %v", err)
+	}
+
+	versions, _ := service.GetSettingsVersions(clone.ID)
+	if len(versions) != 1 || versions[0].Reason != "Cloned from "+source.ID || versions[0].ChangedBy != "bob" {
+		t.Errorf("Expected the clone to start with one version recording its source, got %+v", versions)
+	}
+
+	if _, _, err := service.CloneClient("missing", "another-client", mekStore, "bob"); !IsClientNotFoundError(err) {
+		t.Errorf("Expected ClientNotFoundError for a missing source, got %v", err)
+	}
+	if _, _, err := service.CloneClient(source.ID, source.ID, mekStore, "bob"); !IsClientAlreadyExistsError(err) {
+		t.Errorf("Expected ClientAlreadyExistsError for an existing ID, got %v", err)
+	}
+}
+
+func TestClientService_ArchiveClient(t *testing.T) {
+	repo, cleanup := setupTestClientRepo(t)
+	defer cleanup()
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	verifier := NewClientVerifier(repo, encryptor)
+	mek, _ := encryptor.GenerateKey()
+	mekStore := &testMekStore{mek: mek}
+	client, secret := createTestClientViaService(t, service, mekStore)
+
+	archived, err := service.ArchiveClient(client.ID)
+	if err != nil {
+		t.Fatalf("Failed to archive client: %v", err)
+	}
+
+	if !archived.IsArchived() || !archived.IsDisabled || archived.ID == client.ID {
+		t.Errorf("Expected a disabled archived record with its own ID, got %+v", archived)
+	}
+	if archived.DisplayName != client.ID {
+		t.Errorf("Expected the archived record to be named after the client, got %q", archived.DisplayName)
+	}
+	if archived.SecretHash != "" || archived.EncryptedMek != "" {
+		t.Error("Expected the archived record to have no credentials")
+	}
+
+	original, _ := service.GetClient(client.ID)
+	if original == nil || !original.IsDisabled {
+		t.Error("Expected the original client to be disabled")
+	}
+	if _, _, err := verifier.VerifyClient(client.ID, secret); err == nil {
+		t.Error("Expected the original client to be rejected after archiving")
+	}
+
+	versions, _ := service.GetSettingsVersions(archived.ID)
+	if len(versions) != 1 {
+		t.Errorf("Expected the settings history to move to the archived record, got %d versions", len(versions))
+	}
+	if versions, _ := service.GetSettingsVersions(client.ID); len(versions) != 0 {
+		t.Errorf("Expected no settings history left on the original client, got %d versions", len(versions))
+	}
+
+	if _, err := service.ArchiveClient(archived.ID); !IsClientValidationError(err) {
+		t.Errorf("Expected ClientValidationError when archiving an archived record, got %v", err)
+	}
+	if err := service.EnableClient(archived.ID); !IsClientValidationError(err) {
+		t.Errorf("Expected ClientValidationError when enabling an archived record, got %v", err)
+	}
+	if _, err := service.ArchiveClient("missing"); !IsClientNotFoundError(err) {
+		t.Errorf("Expected ClientNotFoundError for a missing client, got %v", err)
+	}
+}
+
+func TestClientService_BulkUpdateClientSettings(t *testing.T) {
+	groupService, service, first := setupTestGroupService(t)
+	mek, _ := service.encryptor.GenerateKey()
+	second, _, err := service.CloneClient(first.ID, "second-client", &testMekStore{mek: mek}, "admin")
+	if err != nil {
+		t.Fatalf("Failed to clone client: %v", err)
+	}
+
+	group, err := groupService.CreateGroup(testGroupRequest("Warehouse", 120))
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if err := groupService.AssignClient(second.ID, group.ID, nil, "admin"); err != nil {
+		t.Fatalf("Failed to assign client: %v", err)
+	}
+
+	settings := ClientSettings{VideoBitRate: "8000k", ClipDurationSeconds: 90}
+	results, err := service.BulkUpdateClientSettings([]string{first.ID, second.ID, "missing"}, settings, []string{"video_bitrate", "clip_duration"}, "alice")
+	if err != nil {
+		t.Fatalf("Failed to bulk update settings: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected a result per client, got %d", len(results))
+	}
+	if results[0].Err != nil || len(results[0].Changes) != 2 || len(results[0].Overridden) != 0 {
+		t.Errorf("Unexpected result for the first client: %+v", results[0])
+	}
+	if results[1].Err != nil || len(results[1].Overridden) != 2 {
+		t.Errorf("Expected the group member to override the edited settings: %+v", results[1])
+	}
+	if !IsClientNotFoundError(results[2].Err) {
+		t.Errorf("Expected ClientNotFoundError for the missing client, got %v", results[2].Err)
+	}
+
+	for _, id := range []string{first.ID, second.ID} {
+		stored, _ := service.GetClient(id)
+		if stored.VideoBitRate != "8000k" || stored.ClipDurationSeconds != 90 {
+			t.Errorf("%s: expected the bulk edit to be applied, got %s and %d", id, stored.VideoBitRate, stored.ClipDurationSeconds)
+		}
+	}
+	member, _ := service.GetClient(second.ID)
+	if member.OutputCodec != "libx265" || !member.IsOverridden("video_bitrate") {
+		t.Error("Expected the member to keep its other inherited settings and override the edited ones")
+	}
+
+	// Invalid values are reported per client and leave the client unchanged
+	results, err = service.BulkUpdateClientSettings([]string{first.ID}, ClientSettings{ClipDurationSeconds: 5}, []string{"clip_duration"}, "alice")
+	if err != nil || !IsClientValidationError(results[0].Err) {
+		t.Errorf("Expected a validation error in the result, got %v / %+v", err, results)
+	}
+
+	if _, err := service.BulkUpdateClientSettings([]string{first.ID}, settings, []string{"volume"}, "alice"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for an unknown setting, got %v", err)
+	}
+}
+
+func TestClientSettings_SetValue(t *testing.T) {
+	var settings ClientSettings
+	for key, value := range map[string]string{"clip_duration": "120", "motion_only": "on", "motion_min_aspect": "0.5", "output_codec": "libx265"} {
+		if err := settings.SetValue(key, value); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	if settings.ClipDurationSeconds != 120 || !settings.MotionOnly || settings.MotionMinAspect != 0.5 || settings.OutputCodec != "libx265" {
+		t.Errorf("Settings were not set correctly: %+v", settings)
+	}
+
+	if err := settings.SetValue("clip_duration", "long"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for an invalid number, got %v", err)
+	}
+	if err := settings.SetValue("volume", "11"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for an unknown setting, got %v", err)
+	}
+}
+
+func TestClientService_UpdateClientSettingsAndGroup(t *testing.T) {
+	groupService, service, client := setupTestGroupService(t)
+
+	group, err := groupService.CreateGroup(testGroupRequest("Warehouse", 120))
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+
+	// The overridden setting keeps the submitted value, the untouched ones take the template
+	req := settingsOf(client).Request(client.ID)
+	req.ClipDurationSeconds = 90
+	if err := service.UpdateClientSettingsAndGroup(req, group.ID, []string{"clip_duration"}, "alice"); err != nil {
+		t.Fatalf("Failed to update settings and group: %v", err)
+	}
+
+	member, _ := service.GetClient(client.ID)
+	if member.GroupID != group.ID || !member.IsOverridden("clip_duration") || member.ClipDurationSeconds != 90 {
+		t.Errorf("Expected the member to keep its overridden clip duration, got %+v", member)
+	}
+	if member.OutputCodec != "libx265" || member.StorageLimitMegabytes != 2048 {
+		t.Error("Expected the member to inherit the group template")
+	}
+	versions, _ := service.GetSettingsVersions(client.ID)
+	if len(versions) != 2 || versions[0].Reason != "Updated, assigned to group Warehouse" {
+		t.Fatalf("Expected a single version for the change, got %d versions", len(versions))
+	}
+
+	// Changing an inherited setting is rejected rather than undone by the template
+	req = settingsOf(member).Request(client.ID)
+	req.OutputCodec = "libx264"
+	if err := service.UpdateClientSettingsAndGroup(req, group.ID, []string{"clip_duration"}, "alice"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for an inherited setting, got %v", err)
+	}
+	if err := service.UpdateClientSettings(req, "alice"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for an inherited setting, got %v", err)
+	}
+
+	// A missing group leaves the client unchanged
+	req = settingsOf(member).Request(client.ID)
+	req.ClipDurationSeconds = 300
+	if err := service.UpdateClientSettingsAndGroup(req, "missing", nil, "alice"); !IsClientGroupNotFoundError(err) {
+		t.Errorf("Expected group not found error, got %v", err)
+	}
+	if err := service.UpdateClientSettingsAndGroup(req, group.ID, []string{"volume"}, "alice"); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for an unknown override, got %v", err)
+	}
+	unchanged, _ := service.GetClient(client.ID)
+	if unchanged.ClipDurationSeconds != 90 || unchanged.SettingsVersion != member.SettingsVersion {
+		t.Errorf("Expected failed updates to leave the client unchanged, got %+v", unchanged)
+	}
+
+	// Leaving the group keeps the values and allows any change
+	req.OutputCodec = "libx264"
+	if err := service.UpdateClientSettingsAndGroup(req, "", []string{"clip_duration"}, "alice"); err != nil {
+		t.Fatalf("Failed to remove client from group: %v", err)
+	}
+	left, _ := service.GetClient(client.ID)
+	if left.GroupID != "" || len(left.SettingsOverrides) != 0 || left.OutputCodec != "libx264" || left.ClipDurationSeconds != 300 {
+		t.Errorf("Expected the client to leave the group with the submitted settings, got %+v", left)
+	}
+}
+
+func TestClientService_RollbackKeepsGroupTemplate(t *testing.T) {
+	groupService, service, client := setupTestGroupService(t)
+
+	group, err := groupService.CreateGroup(testGroupRequest("Warehouse", 120))
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	req := settingsOf(client).Request(client.ID)
+	if err := service.UpdateClientSettingsAndGroup(req, group.ID, []string{"clip_duration"}, "alice"); err != nil {
+		t.Fatalf("Failed to assign client: %v", err)
+	}
+
+	update := testGroupRequest("Warehouse", 120)
+	update.ID = group.ID
+	update.Settings.OutputCodec = "libvpx-vp9"
+	update.Settings.OutputFormat = "webm"
+	if err := groupService.UpdateGroup(update, "admin"); err != nil {
+		t.Fatalf("Failed to update group: %v", err)
+	}
+
+	// Version 1 predates the group; its inherited values must not come back
+	if err := service.RollbackClientSettings(client.ID, 1, "bob"); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	member, _ := service.GetClient(client.ID)
+	if member.OutputCodec != "libvpx-vp9" || member.StorageLimitMegabytes != 2048 {
+		t.Errorf("Expected the member to keep the current template, got codec %s and limit %d", member.OutputCodec, member.StorageLimitMegabytes)
+	}
+	if member.ClipDurationSeconds != client.ClipDurationSeconds {
+		t.Errorf("Expected the overridden clip duration to be restored, got %d", member.ClipDurationSeconds)
+	}
+}
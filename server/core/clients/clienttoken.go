@@ -0,0 +1,262 @@
+package clients
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+// AccessToken is a short-lived token that a client uses instead of its secret
+type AccessToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// ClientTokenSettings holds configuration for client access tokens
+type ClientTokenSettings struct {
+	Lifetime        time.Duration // How long an access token is valid
+	MaxCachedTokens int           // Upper bound for the number of tokens (and unwrapped MEKs) held in memory
+}
+
+// ClientTokenService issues access tokens to clients and resolves them on later requests.
+// It also implements ClientMekProvider: the MEK for an access token comes from memory,
+// while a client secret is passed on to the wrapped provider.
+type ClientTokenService interface {
+	ClientMekProvider
+	// IssueToken verifies the client secret, unwraps the MEK once and returns a signed access token.
+	// The clientSecret parameter must be hex-encoded.
+	IssueToken(clientID, clientSecret string) (*AccessToken, error)
+	// VerifyToken returns the client an access token was issued to.
+	// The token is rejected if it expired, or if the client was disabled or its secret was revoked since.
+	VerifyToken(token string) (*Client, error)
+}
+
+// tokenClaims is the signed payload of an access token
+type tokenClaims struct {
+	ClientID  string `json:"cid"`
+	TokenID   string `json:"tid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// cachedToken is the server-side state of an issued access token
+type cachedToken struct {
+	clientID   string
+	mek        []byte
+	secretHash string // Hash of the secret the token was issued for
+	expiresAt  time.Time
+}
+
+type clientTokenService struct {
+	logger      logging.Logger
+	clientRepo  ClientRepository
+	mekProvider SecretMekProvider
+	settings    ClientTokenSettings
+	signingKey  []byte
+	mutex       sync.Mutex
+	tokens      map[string]*cachedToken
+	now         func() time.Time
+}
+
+// NewClientTokenService creates a ClientTokenService with a random signing key.
+// Tokens are only valid for the lifetime of the process, since the unwrapped MEKs are only held in memory.
+func NewClientTokenService(logger logging.Logger, clientRepo ClientRepository, mekProvider SecretMekProvider, settings ClientTokenSettings) (*clientTokenService, error) {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	signingKey := make([]byte, 32)
+	if _, err := rand.Read(signingKey); err != nil {
+		return nil, fmt.Errorf("failed to generate token signing key: %w", err)
+	}
+
+	return &clientTokenService{
+		logger:      logger,
+		clientRepo:  clientRepo,
+		mekProvider: mekProvider,
+		settings:    settings,
+		signingKey:  signingKey,
+		tokens:      make(map[string]*cachedToken),
+		now:         time.Now,
+	}, nil
+}
+
+func (s *clientTokenService) IssueToken(clientID, clientSecret string) (*AccessToken, error) {
+	// A token issued for the previous secret during a rotation grace period must not outlive that grace period,
+	// so the token remembers which secret it was issued for
+	mek, secretHash, err := s.mekProvider.UncoverMekWithSecretHash(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenIDBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenIDBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	expiresAt := s.now().Add(s.settings.Lifetime)
+	claims := tokenClaims{
+		ClientID:  clientID,
+		TokenID:   base64.RawURLEncoding.EncodeToString(tokenIDBytes),
+		ExpiresAt: expiresAt.Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode token claims: %w", err)
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	token := encodedPayload + "." + base64.RawURLEncoding.EncodeToString(s.sign(encodedPayload))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.makeRoom()
+	s.tokens[claims.TokenID] = &cachedToken{
+		clientID:   clientID,
+		mek:        mek,
+		secretHash: secretHash,
+		expiresAt:  expiresAt,
+	}
+
+	s.logger.Info("Issued access token", "clientId", clientID, "expiresAt", expiresAt)
+	return &AccessToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+func (s *clientTokenService) VerifyToken(token string) (*Client, error) {
+	claims, cached := s.lookup(token)
+	if cached == nil {
+		return nil, NewClientVerificationError(claims.ClientID)
+	}
+
+	client, err := s.clientRepo.GetByID(context.Background(), claims.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Disabling a client or revoking its secret ends its tokens right away
+	if client == nil || client.IsDisabled || !s.secretStillValid(client, cached.secretHash) {
+		s.revoke(claims.TokenID)
+		return nil, NewClientVerificationError(claims.ClientID)
+	}
+
+	return client, nil
+}
+
+// UncoverMek returns the cached MEK if the credential is an access token, and otherwise
+// unwraps the MEK with the client secret through the wrapped provider
+func (s *clientTokenService) UncoverMek(clientID, credential string) ([]byte, error) {
+	if !IsAccessToken(credential) {
+		return s.mekProvider.UncoverMek(clientID, credential)
+	}
+
+	claims, cached := s.lookup(credential)
+	if cached == nil || claims.ClientID != clientID {
+		return nil, NewClientVerificationError(clientID)
+	}
+	return cached.mek, nil
+}
+
+// IsAccessToken reports whether a credential is an access token rather than a (hex-encoded) client secret
+func IsAccessToken(credential string) bool {
+	return strings.Contains(credential, ".")
+}
+
+// lookup checks the signature and expiry of a token and returns its claims and a copy of its cached state,
+// so that the MEK stays usable even if the token is evicted meanwhile. The cached state is nil if the token is invalid.
+func (s *clientTokenService) lookup(token string) (tokenClaims, *cachedToken) {
+	var claims tokenClaims
+
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, nil
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.sign(encodedPayload)) {
+		return claims, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cached, ok := s.tokens[claims.TokenID]
+	if !ok || cached.clientID != claims.ClientID {
+		return claims, nil
+	}
+	if !s.now().Before(cached.expiresAt) {
+		s.remove(claims.TokenID)
+		return claims, nil
+	}
+
+	copied := *cached
+	copied.mek = slices.Clone(cached.mek)
+	return claims, &copied
+}
+
+// secretStillValid reports whether the secret a token was issued for is still accepted.
+// Tokens issued before a rotation stay valid while the previous secret is in its grace period.
+func (s *clientTokenService) secretStillValid(client *Client, secretHash string) bool {
+	if client.SecretHash == secretHash {
+		return true
+	}
+	return client.PreviousSecretHash == secretHash && client.HasActivePreviousSecret(s.now().UTC())
+}
+
+func (s *clientTokenService) sign(encodedPayload string) []byte {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(encodedPayload))
+	return mac.Sum(nil)
+}
+
+func (s *clientTokenService) revoke(tokenID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.remove(tokenID)
+}
+
+// makeRoom drops expired tokens and, if the cache is still full, the tokens closest to expiry.
+// The caller must hold the lock.
+func (s *clientTokenService) makeRoom() {
+	now := s.now()
+	for id, cached := range s.tokens {
+		if !now.Before(cached.expiresAt) {
+			s.remove(id)
+		}
+	}
+
+	for s.settings.MaxCachedTokens > 0 && len(s.tokens) >= s.settings.MaxCachedTokens {
+		var oldestID string
+		var oldest *cachedToken
+		for id, cached := range s.tokens {
+			if oldest == nil || cached.expiresAt.Before(oldest.expiresAt) {
+				oldestID, oldest = id, cached
+			}
+		}
+		s.logger.Warn("Token cache full, evicting access token", "clientId", oldest.clientID)
+		s.remove(oldestID)
+	}
+}
+
+// remove deletes a token and wipes its MEK from memory. The caller must hold the lock.
+func (s *clientTokenService) remove(tokenID string) {
+	if cached, ok := s.tokens[tokenID]; ok {
+		clear(cached.mek)
+		delete(s.tokens, tokenID)
+	}
+}
@@ -0,0 +1,195 @@
+package clients
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func setupTestTokenService(t *testing.T, settings ClientTokenSettings) (*clientTokenService, *clientService, *Client, string, []byte) {
+	t.Helper()
+
+	repo, cleanup := setupTestClientRepo(t)
+	t.Cleanup(cleanup)
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	verifier := NewClientVerifier(repo, encryptor)
+	mekProvider := NewClientMekProvider(encryptor, repo, verifier)
+
+	tokenService, err := NewClientTokenService(nil, repo, mekProvider, settings)
+	if err != nil {
+		t.Fatalf("Failed to create token service: %v", err)
+	}
+
+	mek, _ := encryptor.GenerateKey()
+	client, secret := createTestClientViaService(t, service, &testMekStore{mek: mek})
+	return tokenService, service, client, secret, mek
+}
+
+func TestClientTokenService_IssueAndVerify(t *testing.T) {
+	tokenService, _, client, secret, mek := setupTestTokenService(t, ClientTokenSettings{Lifetime: 15 * time.Minute, MaxCachedTokens: 10})
+
+	token, err := tokenService.IssueToken(client.ID, secret)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	if !IsAccessToken(token.Token) {
+		t.Error("Expected issued token to be recognized as an access token")
+	}
+	if IsAccessToken(secret) {
+		t.Error("Expected client secret not to be recognized as an access token")
+	}
+
+	verified, err := tokenService.VerifyToken(token.Token)
+	if err != nil {
+		t.Fatalf("Failed to verify token: %v", err)
+	}
+	if verified.ID != client.ID {
+		t.Errorf("Expected client %s, got %s", client.ID, verified.ID)
+	}
+
+	uncovered, err := tokenService.UncoverMek(client.ID, token.Token)
+	if err != nil {
+		t.Fatalf("Failed to uncover MEK with token: %v", err)
+	}
+	if string(uncovered) != string(mek) {
+		t.Error("MEK from token does not match")
+	}
+
+	// Secrets are still accepted and passed on to the wrapped provider
+	uncovered, err = tokenService.UncoverMek(client.ID, secret)
+	if err != nil {
+		t.Fatalf("Failed to uncover MEK with secret: %v", err)
+	}
+	if string(uncovered) != string(mek) {
+		t.Error("MEK from secret does not match")
+	}
+
+	// A token cannot be used for another client
+	if _, err := tokenService.UncoverMek("other-client", token.Token); !IsClientVerificationError(err) {
+		t.Errorf("Expected verification error for other client, got %v", err)
+	}
+}
+
+func TestClientTokenService_RejectsInvalidTokens(t *testing.T) {
+	tokenService, _, client, secret, _ := setupTestTokenService(t, ClientTokenSettings{Lifetime: 15 * time.Minute, MaxCachedTokens: 10})
+
+	wrongSecret := "0" + secret[1:]
+	if secret[0] == '0' {
+		wrongSecret = "1" + secret[1:]
+	}
+	if _, err := tokenService.IssueToken(client.ID, wrongSecret); !IsClientVerificationError(err) {
+		t.Errorf("Expected verification error for wrong secret, got %v", err)
+	}
+
+	token, err := tokenService.IssueToken(client.ID, secret)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	tampered := token.Token[:len(token.Token)-2] + "AA"
+	if _, err := tokenService.VerifyToken(tampered); !IsClientVerificationError(err) {
+		t.Errorf("Expected verification error for tampered token, got %v", err)
+	}
+	if _, err := tokenService.VerifyToken("not-a-token"); !IsClientVerificationError(err) {
+		t.Errorf("Expected verification error for malformed token, got %v", err)
+	}
+
+	// Tokens expire after their lifetime
+	tokenService.now = func() time.Time { return time.Now().Add(16 * time.Minute) }
+	if _, err := tokenService.VerifyToken(token.Token); !IsClientVerificationError(err) {
+		t.Errorf("Expected verification error for expired token, got %v", err)
+	}
+}
+
+func TestClientTokenService_DisableAndRotateEndTokens(t *testing.T) {
+	tokenService, service, client, secret, mek := setupTestTokenService(t, ClientTokenSettings{Lifetime: 15 * time.Minute, MaxCachedTokens: 10})
+
+	token, err := tokenService.IssueToken(client.ID, secret)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	if err := service.DisableClient(client.ID); err != nil {
+		t.Fatalf("Failed to disable client: %v", err)
+	}
+	if _, err := tokenService.VerifyToken(token.Token); !IsClientVerificationError(err) {
+		t.Errorf("Expected token of disabled client to be rejected, got %v", err)
+	}
+
+	if err := service.EnableClient(client.ID); err != nil {
+		t.Fatalf("Failed to enable client: %v", err)
+	}
+	token, err = tokenService.IssueToken(client.ID, secret)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	if _, _, err := service.RotateClientSecret(client.ID, 0, &testMekStore{mek: mek}); err != nil {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+	if _, err := tokenService.VerifyToken(token.Token); !IsClientVerificationError(err) {
+		t.Errorf("Expected token issued for a revoked secret to be rejected, got %v", err)
+	}
+}
+
+func TestClientTokenService_PreviousSecretTokenEndsWithGracePeriod(t *testing.T) {
+	tokenService, service, client, oldSecret, mek := setupTestTokenService(t, ClientTokenSettings{Lifetime: 2 * time.Hour, MaxCachedTokens: 10})
+
+	_, newSecretBytes, err := service.RotateClientSecret(client.ID, time.Hour, &testMekStore{mek: mek})
+	if err != nil {
+		t.Fatalf("Failed to rotate secret: %v", err)
+	}
+	newSecret := hex.EncodeToString(newSecretBytes)
+
+	oldToken, err := tokenService.IssueToken(client.ID, oldSecret)
+	if err != nil {
+		t.Fatalf("Failed to issue token with the previous secret during the grace period: %v", err)
+	}
+	newToken, err := tokenService.IssueToken(client.ID, newSecret)
+	if err != nil {
+		t.Fatalf("Failed to issue token with the new secret: %v", err)
+	}
+	if _, err := tokenService.VerifyToken(oldToken.Token); err != nil {
+		t.Errorf("Expected token of the previous secret to be valid during the grace period, got %v", err)
+	}
+
+	// Both tokens are still unexpired, but the grace period of the previous secret is over
+	tokenService.now = func() time.Time { return time.Now().Add(90 * time.Minute) }
+	if _, err := tokenService.VerifyToken(oldToken.Token); !IsClientVerificationError(err) {
+		t.Errorf("Expected token issued for the previous secret to be rejected after the grace period, got %v", err)
+	}
+	if _, err := tokenService.VerifyToken(newToken.Token); err != nil {
+		t.Errorf("Expected token issued for the new secret to stay valid, got %v", err)
+	}
+}
+
+func TestClientTokenService_BoundedCache(t *testing.T) {
+	tokenService, _, client, secret, _ := setupTestTokenService(t, ClientTokenSettings{Lifetime: 15 * time.Minute, MaxCachedTokens: 2})
+
+	var tokens []*AccessToken
+	for i := 0; i < 3; i++ {
+		// Later tokens expire later, so the first one is evicted
+		issuedAt := time.Now().Add(time.Duration(i) * time.Second)
+		tokenService.now = func() time.Time { return issuedAt }
+
+		token, err := tokenService.IssueToken(client.ID, secret)
+		if err != nil {
+			t.Fatalf("Failed to issue token: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if len(tokenService.tokens) != 2 {
+		t.Errorf("Expected 2 cached tokens, got %d", len(tokenService.tokens))
+	}
+	if _, err := tokenService.VerifyToken(tokens[0].Token); !IsClientVerificationError(err) {
+		t.Errorf("Expected evicted token to be rejected, got %v", err)
+	}
+	if _, err := tokenService.VerifyToken(tokens[2].Token); err != nil {
+		t.Errorf("Expected newest token to be valid, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/hex"
+	"time"
 
 	"github.com/yeti47/cryospy/server/core/encryption"
 )
@@ -50,22 +51,39 @@ func (v *clientVerifier) VerifyClient(clientID, clientSecret string) (bool, *Cli
 		return false, nil, NewClientVerificationError(clientID) // don't specify the reason to avoid leaking information
 	}
 
-	// Base64-decode the secret salt from the client
-	decodedSecretSalt, err := base64.StdEncoding.DecodeString(client.SecretSalt)
+	matches, err := v.secretMatches(client.SecretHash, client.SecretSalt, decodedClientSecret)
 	if err != nil {
 		return false, nil, err
 	}
 
-	// Also decode the secret hash from the client
-	decodedSecretHash, err := base64.StdEncoding.DecodeString(client.SecretHash)
-	if err != nil {
-		return false, nil, err
+	// After a rotation, the previous secret is accepted until its grace period ends
+	if !matches && client.HasActivePreviousSecret(time.Now().UTC()) {
+		matches, err = v.secretMatches(client.PreviousSecretHash, client.PreviousSecretSalt, decodedClientSecret)
+		if err != nil {
+			return false, nil, err
+		}
 	}
 
-	// Compare hash
-	if !v.encryptor.CompareHash(decodedSecretHash, decodedClientSecret, decodedSecretSalt) {
+	if !matches {
 		return false, nil, NewClientVerificationError(clientID) // don't specify the reason to avoid leaking information
 	}
 
 	return true, client, nil
 }
+
+// secretMatches compares a decoded secret against a stored (base 64 encoded) hash and salt
+func (v *clientVerifier) secretMatches(secretHash, secretSalt string, secret []byte) (bool, error) {
+	// Base64-decode the secret salt from the client
+	decodedSecretSalt, err := base64.StdEncoding.DecodeString(secretSalt)
+	if err != nil {
+		return false, err
+	}
+
+	// Also decode the secret hash from the client
+	decodedSecretHash, err := base64.StdEncoding.DecodeString(secretHash)
+	if err != nil {
+		return false, err
+	}
+
+	return v.encryptor.CompareHash(decodedSecretHash, secret, decodedSecretSalt), nil
+}
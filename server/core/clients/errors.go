@@ -17,6 +17,10 @@ type ClientValidationError struct {
 	Message string
 }
 
+type ClientGroupNotFoundError struct {
+	ID string
+}
+
 func (e *ClientAlreadyExistsError) Error() string {
 	return "Client already exists: " + e.ID
 }
@@ -33,6 +37,10 @@ func (e *ClientValidationError) Error() string {
 	return "Client validation failed: " + e.Message
 }
 
+func (e *ClientGroupNotFoundError) Error() string {
+	return "Client group not found: " + e.ID
+}
+
 // helper functions for error handling
 
 func IsClientAlreadyExistsError(err error) bool {
@@ -55,6 +63,11 @@ func IsClientVerificationError(err error) bool {
 	return ok
 }
 
+func IsClientGroupNotFoundError(err error) bool {
+	_, ok := err.(*ClientGroupNotFoundError)
+	return ok
+}
+
 // helper function to create a new ClientAlreadyExistsError
 func NewClientAlreadyExistsError(id string) error {
 	return &ClientAlreadyExistsError{ID: id}
@@ -71,3 +84,7 @@ func NewClientVerificationError(clientID string) error {
 func NewClientValidationError(message string) error {
 	return &ClientValidationError{Message: message}
 }
+
+func NewClientGroupNotFoundError(id string) error {
+	return &ClientGroupNotFoundError{ID: id}
+}
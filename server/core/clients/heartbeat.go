@@ -0,0 +1,58 @@
+package clients
+
+import "time"
+
+// CameraStatus is the state of the camera as reported by a capture client
+type CameraStatus string
+
+const (
+	CameraStatusStarting CameraStatus = "starting" // No clip has been recorded since the client started
+	CameraStatusOK       CameraStatus = "ok"       // Clips are being recorded
+	CameraStatusPaused   CameraStatus = "paused"   // Recording is off, e.g. by schedule or arm mode
+	CameraStatusError    CameraStatus = "error"    // Recording failed since the last clip
+)
+
+// ClientStatus summarizes the health of a client for the dashboard
+type ClientStatus string
+
+const (
+	ClientStatusUnknown  ClientStatus = "unknown"  // The client has never sent a heartbeat
+	ClientStatusOnline   ClientStatus = "online"   // The client sends heartbeats and its camera works
+	ClientStatusDegraded ClientStatus = "degraded" // The client sends heartbeats, but reports a camera error
+	ClientStatusOffline  ClientStatus = "offline"  // The client has missed its heartbeats
+)
+
+// HeartbeatReport is what a capture client reports about itself with each heartbeat
+type HeartbeatReport struct {
+	Version       string       // Version of the capture client
+	UptimeSeconds int64        // Time since the capture client started
+	CameraStatus  CameraStatus // State of the camera
+	QueueDepth    int          // Clips waiting in the upload queue
+	RetryBacklog  int          // Clips waiting for an upload retry
+	TempDirBytes  int64        // Disk space used by the temporary clip directory
+	LastError     string       // Most recent error, empty if there was none
+	LastErrorAt   *time.Time   // Time of the most recent error
+}
+
+// Heartbeat is the latest report of a client together with the monitoring state derived from it
+type Heartbeat struct {
+	ClientID string
+	HeartbeatReport
+	ReceivedAt        time.Time  // Time the report was received
+	OfflineNotifiedAt *time.Time // Time the client was reported offline, nil while it sends heartbeats
+}
+
+// Status returns the status of the client at the given time. A client is offline once no heartbeat
+// has arrived for offlineAfter.
+func (h *Heartbeat) Status(now time.Time, offlineAfter time.Duration) ClientStatus {
+	if h == nil {
+		return ClientStatusUnknown
+	}
+	if now.Sub(h.ReceivedAt) > offlineAfter {
+		return ClientStatusOffline
+	}
+	if h.CameraStatus == CameraStatusError {
+		return ClientStatusDegraded
+	}
+	return ClientStatusOnline
+}
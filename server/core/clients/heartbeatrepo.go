@@ -0,0 +1,168 @@
+package clients
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type HeartbeatRepository interface {
+	// GetByClientID retrieves the latest heartbeat of a client, or nil if it has never sent one
+	GetByClientID(ctx context.Context, clientID string) (*Heartbeat, error)
+	// GetAll retrieves the latest heartbeat of every client that has sent one
+	GetAll(ctx context.Context) ([]*Heartbeat, error)
+	// Save replaces the latest heartbeat of a client
+	Save(ctx context.Context, heartbeat *Heartbeat) error
+	// MarkOfflineNotified records that a client was reported offline, unless a newer heartbeat than the one received at receivedAt has arrived
+	MarkOfflineNotified(ctx context.Context, clientID string, receivedAt time.Time, notifiedAt time.Time) error
+}
+
+// SQLiteHeartbeatRepository implements HeartbeatRepository using SQLite
+type SQLiteHeartbeatRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteHeartbeatRepository creates a new SQLite-based HeartbeatRepository
+func NewSQLiteHeartbeatRepository(db *sql.DB) (*SQLiteHeartbeatRepository, error) {
+	repo := &SQLiteHeartbeatRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLiteHeartbeatRepository) createTables() error {
+	createHeartbeatsTable := `
+	CREATE TABLE IF NOT EXISTS client_heartbeats (
+		client_id TEXT PRIMARY KEY,
+		received_at TEXT NOT NULL,
+		version TEXT NOT NULL DEFAULT '',
+		uptime_seconds INTEGER NOT NULL DEFAULT 0,
+		camera_status TEXT NOT NULL DEFAULT '',
+		queue_depth INTEGER NOT NULL DEFAULT 0,
+		retry_backlog INTEGER NOT NULL DEFAULT 0,
+		temp_dir_bytes INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		last_error_at TEXT,
+		offline_notified_at TEXT
+	);`
+
+	_, err := r.db.Exec(createHeartbeatsTable)
+	return err
+}
+
+// heartbeatColumns lists the columns selected for a Heartbeat, in the order expected by scanHeartbeat
+const heartbeatColumns = `client_id, received_at, version, uptime_seconds, camera_status,
+		queue_depth, retry_backlog, temp_dir_bytes, last_error, last_error_at, offline_notified_at`
+
+func scanHeartbeat(row rowScanner) (*Heartbeat, error) {
+	heartbeat := &Heartbeat{}
+	var receivedAtStr string
+	var lastErrorAtStr, offlineNotifiedAtStr sql.NullString
+	err := row.Scan(
+		&heartbeat.ClientID, &receivedAtStr, &heartbeat.Version, &heartbeat.UptimeSeconds, &heartbeat.CameraStatus,
+		&heartbeat.QueueDepth, &heartbeat.RetryBacklog, &heartbeat.TempDirBytes, &heartbeat.LastError, &lastErrorAtStr, &offlineNotifiedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeat.ReceivedAt, err = db.StringToTime(receivedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse received_at timestamp: %w", err)
+	}
+
+	if lastErrorAtStr.Valid {
+		lastErrorAt, err := db.StringToTime(lastErrorAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse last_error_at timestamp: %w", err)
+		}
+		heartbeat.LastErrorAt = &lastErrorAt
+	}
+
+	if offlineNotifiedAtStr.Valid {
+		offlineNotifiedAt, err := db.StringToTime(offlineNotifiedAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse offline_notified_at timestamp: %w", err)
+		}
+		heartbeat.OfflineNotifiedAt = &offlineNotifiedAt
+	}
+
+	return heartbeat, nil
+}
+
+// GetByClientID retrieves the latest heartbeat of a client, or nil if it has never sent one
+func (r *SQLiteHeartbeatRepository) GetByClientID(ctx context.Context, clientID string) (*Heartbeat, error) {
+	query := `SELECT ` + heartbeatColumns + ` FROM client_heartbeats WHERE client_id = ?`
+
+	heartbeat, err := scanHeartbeat(r.db.QueryRowContext(ctx, query, clientID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get heartbeat: %w", err)
+	}
+
+	return heartbeat, nil
+}
+
+// GetAll retrieves the latest heartbeat of every client that has sent one
+func (r *SQLiteHeartbeatRepository) GetAll(ctx context.Context) ([]*Heartbeat, error) {
+	query := `SELECT ` + heartbeatColumns + ` FROM client_heartbeats ORDER BY client_id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query heartbeats: %w", err)
+	}
+	defer rows.Close()
+
+	var heartbeats []*Heartbeat
+	for rows.Next() {
+		heartbeat, err := scanHeartbeat(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan heartbeat row: %w", err)
+		}
+		heartbeats = append(heartbeats, heartbeat)
+	}
+
+	return heartbeats, rows.Err()
+}
+
+// Save replaces the latest heartbeat of a client
+func (r *SQLiteHeartbeatRepository) Save(ctx context.Context, heartbeat *Heartbeat) error {
+	query := `
+	INSERT INTO client_heartbeats (` + heartbeatColumns + `)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(client_id) DO UPDATE SET
+		received_at = excluded.received_at, version = excluded.version, uptime_seconds = excluded.uptime_seconds,
+		camera_status = excluded.camera_status, queue_depth = excluded.queue_depth, retry_backlog = excluded.retry_backlog,
+		temp_dir_bytes = excluded.temp_dir_bytes, last_error = excluded.last_error, last_error_at = excluded.last_error_at,
+		offline_notified_at = excluded.offline_notified_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		heartbeat.ClientID, db.TimeToString(heartbeat.ReceivedAt), heartbeat.Version, heartbeat.UptimeSeconds, heartbeat.CameraStatus,
+		heartbeat.QueueDepth, heartbeat.RetryBacklog, heartbeat.TempDirBytes, heartbeat.LastError,
+		db.TimePtrToString(heartbeat.LastErrorAt), db.TimePtrToString(heartbeat.OfflineNotifiedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// MarkOfflineNotified records that a client was reported offline, unless a newer heartbeat than the one received at receivedAt has arrived
+func (r *SQLiteHeartbeatRepository) MarkOfflineNotified(ctx context.Context, clientID string, receivedAt time.Time, notifiedAt time.Time) error {
+	query := `UPDATE client_heartbeats SET offline_notified_at = ? WHERE client_id = ? AND received_at = ?`
+
+	_, err := r.db.ExecContext(ctx, query, db.TimeToString(notifiedAt), clientID, db.TimeToString(receivedAt))
+	if err != nil {
+		return fmt.Errorf("failed to mark heartbeat as offline notified: %w", err)
+	}
+	return nil
+}
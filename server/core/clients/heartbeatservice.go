@@ -0,0 +1,151 @@
+package clients
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/notifications"
+)
+
+// maxLastErrorLength limits how much of a reported error message is stored
+const maxLastErrorLength = 1000
+
+var supportedCameraStatuses = []CameraStatus{CameraStatusStarting, CameraStatusOK, CameraStatusPaused, CameraStatusError}
+
+type HeartbeatSettings struct {
+	OfflineAfter time.Duration // A client is offline once no heartbeat has arrived for this long
+}
+
+// HeartbeatService keeps the latest heartbeat of each client and reports clients that stop sending them
+type HeartbeatService interface {
+	// RecordHeartbeat stores the report of a client. If the client was reported offline, it is reported back online.
+	RecordHeartbeat(clientID string, report HeartbeatReport) error
+	// GetHeartbeats returns the latest heartbeat of every client that has sent one, by client ID
+	GetHeartbeats() (map[string]*Heartbeat, error)
+	// OfflineAfter returns how long a client may go without heartbeats before it is considered offline
+	OfflineAfter() time.Duration
+	// CheckMissedHeartbeats reports every enabled client that went offline since the last check
+	CheckMissedHeartbeats(now time.Time) error
+}
+
+type heartbeatService struct {
+	logger        logging.Logger
+	heartbeatRepo HeartbeatRepository
+	clientRepo    ClientRepository
+	notifier      notifications.HeartbeatNotifier
+	settings      HeartbeatSettings
+}
+
+func NewHeartbeatService(logger logging.Logger, heartbeatRepo HeartbeatRepository, clientRepo ClientRepository, notifier notifications.HeartbeatNotifier, settings HeartbeatSettings) *heartbeatService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+	if notifier == nil {
+		notifier = notifications.NopHeartbeatNotifier
+	}
+
+	return &heartbeatService{
+		logger:        logger,
+		heartbeatRepo: heartbeatRepo,
+		clientRepo:    clientRepo,
+		notifier:      notifier,
+		settings:      settings,
+	}
+}
+
+func (s *heartbeatService) RecordHeartbeat(clientID string, report HeartbeatReport) error {
+	if !slices.Contains(supportedCameraStatuses, report.CameraStatus) {
+		return NewClientValidationError("unsupported camera status: " + string(report.CameraStatus))
+	}
+	if report.UptimeSeconds < 0 || report.QueueDepth < 0 || report.RetryBacklog < 0 || report.TempDirBytes < 0 {
+		return NewClientValidationError("heartbeat values must not be negative")
+	}
+	if len(report.LastError) > maxLastErrorLength {
+		report.LastError = report.LastError[:maxLastErrorLength]
+	}
+
+	ctx := context.Background()
+
+	previous, err := s.heartbeatRepo.GetByClientID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve heartbeat", err)
+		return err
+	}
+
+	heartbeat := &Heartbeat{
+		ClientID:        clientID,
+		HeartbeatReport: report,
+		ReceivedAt:      time.Now().UTC(),
+	}
+	if err := s.heartbeatRepo.Save(ctx, heartbeat); err != nil {
+		s.logger.Error("Failed to save heartbeat", err)
+		return err
+	}
+
+	if previous != nil && previous.OfflineNotifiedAt != nil {
+		s.logger.Info("Client is back online", "client", clientID)
+		if err := s.notifier.NotifyClientOnline(clientID, *previous.OfflineNotifiedAt); err != nil {
+			s.logger.Error("Failed to send client online notification", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *heartbeatService) GetHeartbeats() (map[string]*Heartbeat, error) {
+	heartbeats, err := s.heartbeatRepo.GetAll(context.Background())
+	if err != nil {
+		s.logger.Error("Failed to retrieve heartbeats", err)
+		return nil, err
+	}
+
+	byClientID := make(map[string]*Heartbeat, len(heartbeats))
+	for _, heartbeat := range heartbeats {
+		byClientID[heartbeat.ClientID] = heartbeat
+	}
+	return byClientID, nil
+}
+
+func (s *heartbeatService) OfflineAfter() time.Duration {
+	return s.settings.OfflineAfter
+}
+
+func (s *heartbeatService) CheckMissedHeartbeats(now time.Time) error {
+	ctx := context.Background()
+
+	heartbeats, err := s.heartbeatRepo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("Failed to retrieve heartbeats", err)
+		return err
+	}
+
+	for _, heartbeat := range heartbeats {
+		if heartbeat.OfflineNotifiedAt != nil || heartbeat.Status(now, s.settings.OfflineAfter) != ClientStatusOffline {
+			continue
+		}
+
+		// Disabled and deleted clients are expected to be silent
+		client, err := s.clientRepo.GetByID(ctx, heartbeat.ClientID)
+		if err != nil {
+			s.logger.Error("Failed to retrieve client", err)
+			continue
+		}
+		if client == nil || client.IsDisabled {
+			continue
+		}
+
+		s.logger.Warn("Client missed its heartbeats", "client", heartbeat.ClientID, "lastSeen", heartbeat.ReceivedAt)
+		if err := s.notifier.NotifyClientOffline(heartbeat.ClientID, heartbeat.ReceivedAt); err != nil {
+			// Try again with the next check
+			continue
+		}
+
+		if err := s.heartbeatRepo.MarkOfflineNotified(ctx, heartbeat.ClientID, heartbeat.ReceivedAt, now.UTC()); err != nil {
+			s.logger.Error("Failed to mark client as reported offline", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+type mockHeartbeatNotifier struct {
+	offline []string
+	online  []string
+}
+
+func (n *mockHeartbeatNotifier) NotifyClientOffline(clientID string, lastSeen time.Time) error {
+	n.offline = append(n.offline, clientID)
+	return nil
+}
+
+func (n *mockHeartbeatNotifier) NotifyClientOnline(clientID string, offlineSince time.Time) error {
+	n.online = append(n.online, clientID)
+	return nil
+}
+
+func setupTestHeartbeatService(t *testing.T) (*heartbeatService, *mockHeartbeatNotifier, *clientService, *Client) {
+	t.Helper()
+
+	repo, cleanup := setupTestClientRepo(t)
+	t.Cleanup(cleanup)
+
+	heartbeatRepo, err := NewSQLiteHeartbeatRepository(repo.db)
+	if err != nil {
+		t.Fatalf("Failed to create heartbeat repository: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	service := NewClientService(nil, repo, newTestVersionRepo(t, repo), newTestGroupRepo(t, repo), encryptor)
+	mek, _ := encryptor.GenerateKey()
+	client, _ := createTestClientViaService(t, service, &testMekStore{mek: mek})
+
+	notifier := &mockHeartbeatNotifier{}
+	heartbeatService := NewHeartbeatService(nil, heartbeatRepo, repo, notifier, HeartbeatSettings{OfflineAfter: 5 * time.Minute})
+	return heartbeatService, notifier, service, client
+}
+
+func TestHeartbeatService_RecordHeartbeat(t *testing.T) {
+	service, _, _, client := setupTestHeartbeatService(t)
+
+	report := HeartbeatReport{
+		Version:       "1.2.0",
+		UptimeSeconds: 3600,
+		CameraStatus:  CameraStatusError,
+		QueueDepth:    2,
+		RetryBacklog:  5,
+		TempDirBytes:  1 << 20,
+		LastError:     "camera disconnected",
+	}
+	if err := service.RecordHeartbeat(client.ID, report); err != nil {
+		t.Fatalf("Failed to record heartbeat: %v", err)
+	}
+
+	heartbeats, err := service.GetHeartbeats()
+	if err != nil {
+		t.Fatalf("Failed to get heartbeats: %v", err)
+	}
+	heartbeat := heartbeats[client.ID]
+	if heartbeat == nil {
+		t.Fatal("Expected a heartbeat for the client")
+	}
+	if heartbeat.HeartbeatReport != report {
+		t.Errorf("Expected report %+v, got %+v", report, heartbeat.HeartbeatReport)
+	}
+	if status := heartbeat.Status(time.Now(), service.OfflineAfter()); status != ClientStatusDegraded {
+		t.Errorf("Expected status %s, got %s", ClientStatusDegraded, status)
+	}
+
+	report.CameraStatus = "melted"
+	if err := service.RecordHeartbeat(client.ID, report); !IsClientValidationError(err) {
+		t.Errorf("Expected validation error for unknown camera status, got %v", err)
+	}
+}
+
+func TestHeartbeatService_CheckMissedHeartbeats(t *testing.T) {
+	service, notifier, clientService, client := setupTestHeartbeatService(t)
+
+	if err := service.RecordHeartbeat(client.ID, HeartbeatReport{CameraStatus: CameraStatusOK}); err != nil {
+		t.Fatalf("Failed to record heartbeat: %v", err)
+	}
+
+	service.CheckMissedHeartbeats(time.Now())
+	if len(notifier.offline) != 0 {
+		t.Fatalf("Expected no offline notification for a live client, got %v", notifier.offline)
+	}
+
+	// Each outage is reported only once
+	later := time.Now().Add(10 * time.Minute)
+	service.CheckMissedHeartbeats(later)
+	service.CheckMissedHeartbeats(later.Add(time.Minute))
+	if len(notifier.offline) != 1 {
+		t.Fatalf("Expected one offline notification, got %v", notifier.offline)
+	}
+
+	if err := service.RecordHeartbeat(client.ID, HeartbeatReport{CameraStatus: CameraStatusOK}); err != nil {
+		t.Fatalf("Failed to record heartbeat: %v", err)
+	}
+	if len(notifier.online) != 1 {
+		t.Errorf("Expected one online notification, got %v", notifier.online)
+	}
+
+	// Disabled clients are expected to go silent
+	clientService.DisableClient(client.ID)
+	service.CheckMissedHeartbeats(later.Add(time.Hour))
+	if len(notifier.offline) != 1 {
+		t.Errorf("Expected no offline notification for a disabled client, got %v", notifier.offline)
+	}
+}
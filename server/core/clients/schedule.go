@@ -0,0 +1,111 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+	_ "time/tzdata" // Timezones must resolve on hosts without a zoneinfo database, e.g. minimal containers
+)
+
+// RecordingMode determines what a client records and uploads
+type RecordingMode string
+
+const (
+	RecordingModeOff        RecordingMode = "off"         // The client does not record at all
+	RecordingModeMotionOnly RecordingMode = "motion_only" // The client only uploads clips in which motion was detected
+	RecordingModeContinuous RecordingMode = "continuous"  // The client uploads every clip
+)
+
+// MotionSensitivity adjusts the motion detection thresholds of a client while a schedule entry is active
+type MotionSensitivity string
+
+const (
+	MotionSensitivityDefault MotionSensitivity = ""     // The client's motion detection settings are used as they are
+	MotionSensitivityLow     MotionSensitivity = "low"  // Larger movements are needed to count as motion
+	MotionSensitivityHigh    MotionSensitivity = "high" // Smaller movements already count as motion
+)
+
+var supportedRecordingModes = []RecordingMode{RecordingModeOff, RecordingModeMotionOnly, RecordingModeContinuous}
+var supportedMotionSensitivities = []MotionSensitivity{MotionSensitivityDefault, MotionSensitivityLow, MotionSensitivityHigh}
+
+// ScheduleEntry maps a weekly time range to a recording mode.
+// The range starts at Start on each of the given days and ends at End, which is on the next day if End is not after Start.
+// Times are evaluated in the client's timezone. Outside of all entries, the client's regular settings apply;
+// where entries overlap, the first one wins.
+type ScheduleEntry struct {
+	Days        []time.Weekday    `json:"days"`                  // Days on which the range starts
+	Start       string            `json:"start"`                 // Start time of the range ("HH:MM")
+	End         string            `json:"end"`                   // End time of the range ("HH:MM")
+	Mode        RecordingMode     `json:"mode"`                  // Recording mode while the range is active
+	Sensitivity MotionSensitivity `json:"sensitivity,omitempty"` // Optional motion sensitivity while the range is active
+}
+
+// validateTimezone checks that a timezone is empty or a known IANA timezone
+func validateTimezone(timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return NewClientValidationError("unknown timezone: " + timezone)
+	}
+	return nil
+}
+
+// validateSchedule checks the timezone and the entries of a client schedule
+func validateSchedule(timezone string, schedule []ScheduleEntry) error {
+	if err := validateTimezone(timezone); err != nil {
+		return err
+	}
+
+	for i, entry := range schedule {
+		position := fmt.Sprintf("schedule entry %d: ", i+1)
+
+		if len(entry.Days) == 0 {
+			return NewClientValidationError(position + "at least one day is required")
+		}
+		for _, day := range entry.Days {
+			if day < time.Sunday || day > time.Saturday {
+				return NewClientValidationError(position + "invalid day")
+			}
+		}
+		if _, err := time.Parse("15:04", entry.Start); err != nil {
+			return NewClientValidationError(position + "start time must be formatted as HH:MM")
+		}
+		if _, err := time.Parse("15:04", entry.End); err != nil {
+			return NewClientValidationError(position + "end time must be formatted as HH:MM")
+		}
+		if !slices.Contains(supportedRecordingModes, entry.Mode) {
+			return NewClientValidationError(position + "unsupported recording mode")
+		}
+		if !slices.Contains(supportedMotionSensitivities, entry.Sensitivity) {
+			return NewClientValidationError(position + "unsupported motion sensitivity")
+		}
+	}
+
+	return nil
+}
+
+// encodeSchedule returns the JSON representation of a schedule stored in the database
+func encodeSchedule(schedule []ScheduleEntry) (string, error) {
+	if len(schedule) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode schedule: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeSchedule parses a schedule stored in the database
+func decodeSchedule(data string) ([]ScheduleEntry, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var schedule []ScheduleEntry
+	if err := json.Unmarshal([]byte(data), &schedule); err != nil {
+		return nil, fmt.Errorf("failed to decode schedule: %w", err)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,300 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClientSettings is a snapshot of the versioned settings of a client. The JSON names match the setting keys (see
+// SettingKeys), followed by the timezone and the recording schedule, which are versioned but not inherited from groups.
+type ClientSettings struct {
+	StorageLimitMegabytes int     `json:"storage_limit"`
+	ClipDurationSeconds   int     `json:"clip_duration"`
+	MotionOnly            bool    `json:"motion_only"`
+	Grayscale             bool    `json:"grayscale"`
+	DownscaleResolution   string  `json:"downscale_resolution"`
+	OutputFormat          string  `json:"output_format"`
+	OutputCodec           string  `json:"output_codec"`
+	VideoBitRate          string  `json:"video_bitrate"`
+	MotionMinArea         int     `json:"motion_min_area"`
+	MotionMaxFrames       int     `json:"motion_max_frames"`
+	MotionWarmUpFrames    int     `json:"motion_warm_up_frames"`
+	MotionMinWidth        int     `json:"motion_min_width"`
+	MotionMinHeight       int     `json:"motion_min_height"`
+	MotionMinAspect       float64 `json:"motion_min_aspect"`
+	MotionMaxAspect       float64 `json:"motion_max_aspect"`
+	MotionMogHistory      int     `json:"motion_mog_history"`
+	MotionMogVarThresh    float64 `json:"motion_mog_var_thresh"`
+	CaptureCodec          string  `json:"capture_codec"`
+	CaptureFrameRate      float64 `json:"capture_frame_rate"`
+
+	Timezone string          `json:"timezone"`
+	Schedule []ScheduleEntry `json:"schedule"`
+
+	// Versions recorded before the schedule was versioned hold neither timezone nor schedule. They are left out of
+	// diffs, and rolling back to such a version keeps the current ones.
+	withoutSchedule bool
+}
+
+// Keys of the versioned settings that are not inherited from groups
+const (
+	timezoneKey = "timezone"
+	scheduleKey = "schedule"
+)
+
+// versionedSettingKeys returns the keys of all versioned settings, in the order in which they are diffed
+func versionedSettingKeys() []string {
+	return append(SettingKeys(), timezoneKey, scheduleKey)
+}
+
+// SettingsVersion is a recorded state of the settings of a client. A new version is recorded every time the settings change.
+type SettingsVersion struct {
+	ClientID  string         // ID of the client the settings belong to
+	Version   int            // Version number, counting up from 1 for each client
+	Settings  ClientSettings // The settings of this version
+	ChangedAt time.Time      // Timestamp when the version was recorded
+	ChangedBy string         // User who made the change, empty if it was not made by a user
+	Reason    string         // What caused the change (e.g. "Updated", "Rolled back to version 3")
+}
+
+// SettingChange is a setting that differs between two versions
+type SettingChange struct {
+	Key string // Key of the setting (see SettingKeys)
+	Old string // Value in the older version
+	New string // Value in the newer version
+}
+
+// settingsOf returns a snapshot of the versioned settings of a client
+func settingsOf(client *Client) ClientSettings {
+	return ClientSettings{
+		StorageLimitMegabytes: client.StorageLimitMegabytes,
+		ClipDurationSeconds:   client.ClipDurationSeconds,
+		MotionOnly:            client.MotionOnly,
+		Grayscale:             client.Grayscale,
+		DownscaleResolution:   client.DownscaleResolution,
+		OutputFormat:          client.OutputFormat,
+		OutputCodec:           client.OutputCodec,
+		VideoBitRate:          client.VideoBitRate,
+		MotionMinArea:         client.MotionMinArea,
+		MotionMaxFrames:       client.MotionMaxFrames,
+		MotionWarmUpFrames:    client.MotionWarmUpFrames,
+		MotionMinWidth:        client.MotionMinWidth,
+		MotionMinHeight:       client.MotionMinHeight,
+		MotionMinAspect:       client.MotionMinAspect,
+		MotionMaxAspect:       client.MotionMaxAspect,
+		MotionMogHistory:      client.MotionMogHistory,
+		MotionMogVarThresh:    client.MotionMogVarThresh,
+		CaptureCodec:          client.CaptureCodec,
+		CaptureFrameRate:      client.CaptureFrameRate,
+		Timezone:              client.Timezone,
+		Schedule:              slices.Clone(client.Schedule),
+	}
+}
+
+// equal reports whether two snapshots hold the same settings
+func (s ClientSettings) equal(other ClientSettings) bool {
+	return maps.Equal(s.values(), other.values())
+}
+
+// Request returns the settings as an UpdateClientSettingsRequest for the given client
+func (s ClientSettings) Request(clientID string) UpdateClientSettingsRequest {
+	return UpdateClientSettingsRequest{
+		ID:                    clientID,
+		StorageLimitMegabytes: s.StorageLimitMegabytes,
+		ClipDurationSeconds:   s.ClipDurationSeconds,
+		MotionOnly:            s.MotionOnly,
+		Grayscale:             s.Grayscale,
+		DownscaleResolution:   s.DownscaleResolution,
+		OutputFormat:          s.OutputFormat,
+		OutputCodec:           s.OutputCodec,
+		VideoBitRate:          s.VideoBitRate,
+		MotionMinArea:         s.MotionMinArea,
+		MotionMaxFrames:       s.MotionMaxFrames,
+		MotionWarmUpFrames:    s.MotionWarmUpFrames,
+		MotionMinWidth:        s.MotionMinWidth,
+		MotionMinHeight:       s.MotionMinHeight,
+		MotionMinAspect:       s.MotionMinAspect,
+		MotionMaxAspect:       s.MotionMaxAspect,
+		MotionMogHistory:      s.MotionMogHistory,
+		MotionMogVarThresh:    s.MotionMogVarThresh,
+		CaptureCodec:          s.CaptureCodec,
+		CaptureFrameRate:      s.CaptureFrameRate,
+	}
+}
+
+// createRequest returns the settings as a CreateClientRequest for a new client with the given ID
+func (s ClientSettings) createRequest(clientID string) CreateClientRequest {
+	return CreateClientRequest(s.Request(clientID))
+}
+
+// SetValue sets a setting by its key (see SettingKeys) from its text form, as submitted by the dashboard forms.
+// Booleans are true for "on" and "true" and false otherwise.
+func (s *ClientSettings) SetValue(key, value string) error {
+	if !slices.Contains(SettingKeys(), key) {
+		return NewClientValidationError("unknown setting: " + key)
+	}
+
+	settings := reflect.ValueOf(s).Elem()
+	for i := range settings.NumField() {
+		if settings.Type().Field(i).Tag.Get("json") != key {
+			continue
+		}
+
+		field := settings.Field(i)
+		value = strings.TrimSpace(value)
+		switch field.Kind() {
+		case reflect.Bool:
+			field.SetBool(value == "on" || value == "true")
+		case reflect.Int:
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return NewClientValidationError("invalid value for " + key)
+			}
+			field.SetInt(int64(number))
+		case reflect.Float64:
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return NewClientValidationError("invalid value for " + key)
+			}
+			field.SetFloat(number)
+		default:
+			field.SetString(value)
+		}
+		return nil
+	}
+	return NewClientValidationError("unknown setting: " + key)
+}
+
+// merge returns the settings with the values of the given keys taken from other
+func (s ClientSettings) merge(other ClientSettings, keys []string) (ClientSettings, error) {
+	data, err := json.Marshal(other)
+	if err != nil {
+		return s, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return s, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok || !slices.Contains(SettingKeys(), key) {
+			return s, NewClientValidationError("unknown setting: " + key)
+		}
+		selected[key] = value
+	}
+
+	if data, err = json.Marshal(selected); err != nil {
+		return s, err
+	}
+	merged := s
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return s, err
+	}
+	return merged, nil
+}
+
+// values returns the settings as strings by setting key
+func (s ClientSettings) values() map[string]string {
+	return map[string]string{
+		"storage_limit":         strconv.Itoa(s.StorageLimitMegabytes),
+		"clip_duration":         strconv.Itoa(s.ClipDurationSeconds),
+		"motion_only":           strconv.FormatBool(s.MotionOnly),
+		"grayscale":             strconv.FormatBool(s.Grayscale),
+		"downscale_resolution":  s.DownscaleResolution,
+		"output_format":         s.OutputFormat,
+		"output_codec":          s.OutputCodec,
+		"video_bitrate":         s.VideoBitRate,
+		"motion_min_area":       strconv.Itoa(s.MotionMinArea),
+		"motion_max_frames":     strconv.Itoa(s.MotionMaxFrames),
+		"motion_warm_up_frames": strconv.Itoa(s.MotionWarmUpFrames),
+		"motion_min_width":      strconv.Itoa(s.MotionMinWidth),
+		"motion_min_height":     strconv.Itoa(s.MotionMinHeight),
+		"motion_min_aspect":     formatFloat(s.MotionMinAspect),
+		"motion_max_aspect":     formatFloat(s.MotionMaxAspect),
+		"motion_mog_history":    strconv.Itoa(s.MotionMogHistory),
+		"motion_mog_var_thresh": formatFloat(s.MotionMogVarThresh),
+		"capture_codec":         s.CaptureCodec,
+		"capture_frame_rate":    formatFloat(s.CaptureFrameRate),
+		timezoneKey:             s.Timezone,
+		scheduleKey:             formatSchedule(s.Schedule),
+	}
+}
+
+// formatFloat formats a setting without an exponent and with as many decimals as needed, e.g. "0.25" or "1000000"
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatSchedule describes a schedule in one line, e.g. "Mon,Fri 22:00-06:00 off; Sat 08:00-20:00 motion_only (high)"
+func formatSchedule(schedule []ScheduleEntry) string {
+	entries := make([]string, len(schedule))
+	for i, entry := range schedule {
+		days := make([]string, len(entry.Days))
+		for j, day := range entry.Days {
+			days[j] = day.String()[:3]
+		}
+		entries[i] = fmt.Sprintf("%s %s-%s %s", strings.Join(days, ","), entry.Start, entry.End, entry.Mode)
+		if entry.Sensitivity != MotionSensitivityDefault {
+			entries[i] += fmt.Sprintf(" (%s)", entry.Sensitivity)
+		}
+	}
+	return strings.Join(entries, "; ")
+}
+
+// DiffSettings returns the settings that differ between two versions, in the order of SettingKeys followed by the
+// timezone and the schedule
+func DiffSettings(from, to ClientSettings) []SettingChange {
+	oldValues := from.values()
+	newValues := to.values()
+
+	var changes []SettingChange
+	for _, key := range versionedSettingKeys() {
+		if (key == timezoneKey || key == scheduleKey) && (from.withoutSchedule || to.withoutSchedule) {
+			continue
+		}
+		if oldValues[key] != newValues[key] {
+			changes = append(changes, SettingChange{Key: key, Old: oldValues[key], New: newValues[key]})
+		}
+	}
+	return changes
+}
+
+// settingsHistory records new settings versions for the services that change client settings
+type settingsHistory struct {
+	repo SettingsVersionRepository
+}
+
+// record stores the settings of the client as a new version and sets client.SettingsVersion, unless they are unchanged
+// from before. Pass nil as before for a new client. Clients that changed settings before versions were recorded first get
+// their previous settings recorded as a baseline, so that the change can be rolled back.
+func (h settingsHistory) record(ctx context.Context, client *Client, before *ClientSettings, changedBy, reason string) error {
+	settings := settingsOf(client)
+	if before != nil && before.equal(settings) {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if before != nil && client.SettingsVersion == 0 {
+		baseline := &SettingsVersion{ClientID: client.ID, Settings: *before, ChangedAt: now, Reason: "Settings before history was recorded"}
+		if _, err := h.repo.Add(ctx, baseline); err != nil {
+			return err
+		}
+	}
+
+	version, err := h.repo.Add(ctx, &SettingsVersion{ClientID: client.ID, Settings: settings, ChangedAt: now, ChangedBy: changedBy, Reason: reason})
+	if err != nil {
+		return err
+	}
+
+	client.SettingsVersion = version
+	return nil
+}
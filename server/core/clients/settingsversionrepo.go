@@ -0,0 +1,163 @@
+package clients
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type SettingsVersionRepository interface {
+	// Add stores the settings as the next version of the client and returns its version number
+	Add(ctx context.Context, version *SettingsVersion) (int, error)
+	// GetByClientID retrieves all settings versions of a client, newest first
+	GetByClientID(ctx context.Context, clientID string) ([]*SettingsVersion, error)
+	// GetVersion retrieves a single settings version of a client, or nil if it does not exist
+	GetVersion(ctx context.Context, clientID string, version int) (*SettingsVersion, error)
+	// DeleteByClientID removes all settings versions of a client
+	DeleteByClientID(ctx context.Context, clientID string) error
+	// ReassignClient moves all settings versions of a client to another client
+	ReassignClient(ctx context.Context, fromClientID, toClientID string) error
+}
+
+// SQLiteSettingsVersionRepository implements SettingsVersionRepository using SQLite
+type SQLiteSettingsVersionRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteSettingsVersionRepository creates a new SQLite-based SettingsVersionRepository
+func NewSQLiteSettingsVersionRepository(db *sql.DB) (*SQLiteSettingsVersionRepository, error) {
+	repo := &SQLiteSettingsVersionRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLiteSettingsVersionRepository) createTables() error {
+	createVersionsTable := `
+	CREATE TABLE IF NOT EXISTS client_settings_versions (
+		client_id TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		changed_at TEXT NOT NULL,
+		changed_by TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		settings TEXT NOT NULL,
+		PRIMARY KEY (client_id, version)
+	);`
+
+	_, err := r.db.Exec(createVersionsTable)
+	return err
+}
+
+// settingsVersionColumns lists the columns selected for a SettingsVersion, in the order expected by scanSettingsVersion
+const settingsVersionColumns = `client_id, version, changed_at, changed_by, reason, settings`
+
+func scanSettingsVersion(row rowScanner) (*SettingsVersion, error) {
+	version := &SettingsVersion{}
+	var changedAtStr, settingsStr string
+	err := row.Scan(&version.ClientID, &version.Version, &changedAtStr, &version.ChangedBy, &version.Reason, &settingsStr)
+	if err != nil {
+		return nil, err
+	}
+
+	version.ChangedAt, err = db.StringToTime(changedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse changed_at timestamp: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(settingsStr), &version.Settings); err != nil {
+		return nil, fmt.Errorf("failed to decode settings: %w", err)
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(settingsStr), &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode settings: %w", err)
+	}
+	_, hasSchedule := keys[scheduleKey]
+	version.Settings.withoutSchedule = !hasSchedule
+
+	return version, nil
+}
+
+// Add stores the settings as the next version of the client and returns its version number
+func (r *SQLiteSettingsVersionRepository) Add(ctx context.Context, version *SettingsVersion) (int, error) {
+	settings, err := json.Marshal(version.Settings)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode settings: %w", err)
+	}
+
+	// The version number is assigned in the same statement, so concurrent changes cannot get the same number
+	query := `
+	INSERT INTO client_settings_versions (` + settingsVersionColumns + `)
+	SELECT ?, COALESCE(MAX(version), 0) + 1, ?, ?, ?, ? FROM client_settings_versions WHERE client_id = ?
+	RETURNING version`
+
+	var number int
+	err = r.db.QueryRowContext(ctx, query,
+		version.ClientID, db.TimeToString(version.ChangedAt), version.ChangedBy, version.Reason, string(settings), version.ClientID,
+	).Scan(&number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add settings version: %w", err)
+	}
+
+	return number, nil
+}
+
+// GetByClientID retrieves all settings versions of a client, newest first
+func (r *SQLiteSettingsVersionRepository) GetByClientID(ctx context.Context, clientID string) ([]*SettingsVersion, error) {
+	query := `SELECT ` + settingsVersionColumns + ` FROM client_settings_versions WHERE client_id = ? ORDER BY version DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settings versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*SettingsVersion
+	for rows.Next() {
+		version, err := scanSettingsVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settings version row: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+// GetVersion retrieves a single settings version of a client, or nil if it does not exist
+func (r *SQLiteSettingsVersionRepository) GetVersion(ctx context.Context, clientID string, version int) (*SettingsVersion, error) {
+	query := `SELECT ` + settingsVersionColumns + ` FROM client_settings_versions WHERE client_id = ? AND version = ?`
+
+	settingsVersion, err := scanSettingsVersion(r.db.QueryRowContext(ctx, query, clientID, version))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get settings version: %w", err)
+	}
+
+	return settingsVersion, nil
+}
+
+// DeleteByClientID removes all settings versions of a client
+func (r *SQLiteSettingsVersionRepository) DeleteByClientID(ctx context.Context, clientID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM client_settings_versions WHERE client_id = ?`, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete settings versions: %w", err)
+	}
+	return nil
+}
+
+// ReassignClient moves all settings versions of a client to another client
+func (r *SQLiteSettingsVersionRepository) ReassignClient(ctx context.Context, fromClientID, toClientID string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE client_settings_versions SET client_id = ? WHERE client_id = ?`, toClientID, fromClientID)
+	if err != nil {
+		return fmt.Errorf("failed to reassign settings versions: %w", err)
+	}
+	return nil
+}
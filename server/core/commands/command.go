@@ -0,0 +1,75 @@
+package commands
+
+import "time"
+
+// CommandType identifies what a capture client is asked to do
+type CommandType string
+
+const (
+	CommandTypeRestartRecording CommandType = "restart_recording" // Close and reopen the camera
+	CommandTypeSnapshot         CommandType = "snapshot"          // Take a still image right away
+	CommandTypeFlushRetryQueue  CommandType = "flush_retry_queue" // Retry failed uploads right away instead of waiting
+	CommandTypeDiagnostics      CommandType = "diagnostics"       // Report the state of the camera and the client
+	CommandTypeReloadSettings   CommandType = "reload_settings"   // Fetch the client settings right away
+)
+
+// SupportedCommandTypes returns the command types capture clients understand, in display order
+func SupportedCommandTypes() []CommandType {
+	return []CommandType{
+		CommandTypeRestartRecording,
+		CommandTypeSnapshot,
+		CommandTypeFlushRetryQueue,
+		CommandTypeDiagnostics,
+		CommandTypeReloadSettings,
+	}
+}
+
+// CommandStatus is the progress of a command
+type CommandStatus string
+
+const (
+	CommandStatusPending   CommandStatus = "pending"   // Waiting for the client to pick the command up
+	CommandStatusDelivered CommandStatus = "delivered" // Picked up by the client, no result yet
+	CommandStatusSucceeded CommandStatus = "succeeded" // The client reported success
+	CommandStatusFailed    CommandStatus = "failed"    // The client reported a failure
+	CommandStatusExpired   CommandStatus = "expired"   // The client did not pick the command up or answer in time
+)
+
+// IsFinal reports whether the command can no longer change
+func (s CommandStatus) IsFinal() bool {
+	return s == CommandStatusSucceeded || s == CommandStatusFailed || s == CommandStatusExpired
+}
+
+// Command is a request sent from the dashboard to a capture client. Commands are kept after they
+// are done, so that they form the audit trail of what was sent to a client, by whom and with what result.
+type Command struct {
+	ID                 string
+	ClientID           string
+	Type               CommandType
+	Status             CommandStatus
+	IssuedBy           string     // Dashboard user who sent the command
+	IssuedAt           time.Time  // Time the command was sent
+	DeliveredAt        *time.Time // Time the client picked the command up
+	CompletedAt        *time.Time // Time the client reported the result, or the command expired
+	Result             string     // Message reported by the client, e.g. the diagnostics output or an error
+	AttachmentMimeType string     // MIME type of the attachment (e.g. a snapshot), empty if there is none
+}
+
+// HasAttachment reports whether the client reported an attachment with the result
+func (c *Command) HasAttachment() bool {
+	return c.AttachmentMimeType != ""
+}
+
+// CommandResult is what a capture client reports after executing a command
+type CommandResult struct {
+	Success            bool
+	Message            string
+	Attachment         []byte // Optional data, e.g. a snapshot image
+	AttachmentMimeType string
+}
+
+// Attachment is the decrypted attachment of a command
+type Attachment struct {
+	Data     []byte
+	MimeType string
+}
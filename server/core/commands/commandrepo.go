@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type CommandRepository interface {
+	// Create stores a new command
+	Create(ctx context.Context, command *Command) error
+	// GetByID retrieves a command, or nil if it does not exist
+	GetByID(ctx context.Context, id string) (*Command, error)
+	// GetByClientID retrieves the most recent commands of a client, newest first
+	GetByClientID(ctx context.Context, clientID string, limit int) ([]*Command, error)
+	// TakePending marks the pending commands of a client as delivered and returns them, oldest first.
+	// A command is only ever returned by one call.
+	TakePending(ctx context.Context, clientID string, deliveredAt time.Time) ([]*Command, error)
+	// Complete stores the result of a delivered command of the given client.
+	// Returns false if there is no such command or it is not waiting for a result.
+	Complete(ctx context.Context, id, clientID string, status CommandStatus, result string, encryptedAttachment []byte, attachmentMimeType string, completedAt time.Time) (bool, error)
+	// GetEncryptedAttachment retrieves the encrypted attachment of a command, or nil if it has none
+	GetEncryptedAttachment(ctx context.Context, id string) ([]byte, error)
+	// ExpireStale expires the commands issued before pendingBefore that were never picked up,
+	// and the commands delivered before deliveredBefore that never got a result
+	ExpireStale(ctx context.Context, pendingBefore, deliveredBefore, now time.Time) error
+}
+
+// SQLiteCommandRepository implements CommandRepository using SQLite
+type SQLiteCommandRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteCommandRepository creates a new SQLite-based CommandRepository
+func NewSQLiteCommandRepository(db *sql.DB) (*SQLiteCommandRepository, error) {
+	repo := &SQLiteCommandRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLiteCommandRepository) createTables() error {
+	createCommandsTable := `
+	CREATE TABLE IF NOT EXISTS client_commands (
+		id TEXT PRIMARY KEY,
+		client_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		status TEXT NOT NULL,
+		issued_by TEXT NOT NULL DEFAULT '',
+		issued_at TEXT NOT NULL,
+		delivered_at TEXT,
+		completed_at TEXT,
+		result TEXT NOT NULL DEFAULT '',
+		attachment_mime_type TEXT NOT NULL DEFAULT '',
+		encrypted_attachment BLOB
+	);`
+
+	createIndex := `CREATE INDEX IF NOT EXISTS idx_client_commands_client_status ON client_commands (client_id, status, issued_at);`
+
+	if _, err := r.db.Exec(createCommandsTable); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(createIndex)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// commandColumns lists the columns selected for a Command, in the order expected by scanCommand.
+// The attachment itself is only loaded on request.
+const commandColumns = `id, client_id, type, status, issued_by, issued_at, delivered_at, completed_at, result, attachment_mime_type`
+
+func scanCommand(row rowScanner) (*Command, error) {
+	command := &Command{}
+	var issuedAtStr string
+	var deliveredAtStr, completedAtStr sql.NullString
+	err := row.Scan(
+		&command.ID, &command.ClientID, &command.Type, &command.Status, &command.IssuedBy,
+		&issuedAtStr, &deliveredAtStr, &completedAtStr, &command.Result, &command.AttachmentMimeType,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	command.IssuedAt, err = db.StringToTime(issuedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issued_at timestamp: %w", err)
+	}
+
+	if deliveredAtStr.Valid {
+		deliveredAt, err := db.StringToTime(deliveredAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse delivered_at timestamp: %w", err)
+		}
+		command.DeliveredAt = &deliveredAt
+	}
+
+	if completedAtStr.Valid {
+		completedAt, err := db.StringToTime(completedAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse completed_at timestamp: %w", err)
+		}
+		command.CompletedAt = &completedAt
+	}
+
+	return command, nil
+}
+
+func (r *SQLiteCommandRepository) Create(ctx context.Context, command *Command) error {
+	query := `
+	INSERT INTO client_commands (id, client_id, type, status, issued_by, issued_at)
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		command.ID, command.ClientID, command.Type, command.Status, command.IssuedBy, db.TimeToString(command.IssuedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create command: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteCommandRepository) GetByID(ctx context.Context, id string) (*Command, error) {
+	query := `SELECT ` + commandColumns + ` FROM client_commands WHERE id = ?`
+
+	command, err := scanCommand(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get command: %w", err)
+	}
+
+	return command, nil
+}
+
+func (r *SQLiteCommandRepository) GetByClientID(ctx context.Context, clientID string, limit int) ([]*Command, error) {
+	query := `SELECT ` + commandColumns + ` FROM client_commands WHERE client_id = ? ORDER BY issued_at DESC LIMIT ?`
+	return r.queryCommands(ctx, query, clientID, limit)
+}
+
+func (r *SQLiteCommandRepository) TakePending(ctx context.Context, clientID string, deliveredAt time.Time) ([]*Command, error) {
+	query := `SELECT ` + commandColumns + ` FROM client_commands WHERE client_id = ? AND status = ? ORDER BY issued_at`
+
+	pending, err := r.queryCommands(ctx, query, clientID, CommandStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another request of the same client may take a command in the meantime, so only the
+	// commands whose status is still pending when they are updated are returned
+	var taken []*Command
+	for _, command := range pending {
+		result, err := r.db.ExecContext(ctx,
+			`UPDATE client_commands SET status = ?, delivered_at = ? WHERE id = ? AND status = ?`,
+			CommandStatusDelivered, db.TimeToString(deliveredAt), command.ID, CommandStatusPending,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mark command as delivered: %w", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			continue
+		}
+
+		command.Status = CommandStatusDelivered
+		command.DeliveredAt = &deliveredAt
+		taken = append(taken, command)
+	}
+
+	return taken, nil
+}
+
+func (r *SQLiteCommandRepository) Complete(ctx context.Context, id, clientID string, status CommandStatus, result string, encryptedAttachment []byte, attachmentMimeType string, completedAt time.Time) (bool, error) {
+	query := `
+	UPDATE client_commands
+	SET status = ?, result = ?, encrypted_attachment = ?, attachment_mime_type = ?, completed_at = ?
+	WHERE id = ? AND client_id = ? AND status = ?`
+
+	res, err := r.db.ExecContext(ctx, query,
+		status, result, encryptedAttachment, attachmentMimeType, db.TimeToString(completedAt),
+		id, clientID, CommandStatusDelivered,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete command: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteCommandRepository) GetEncryptedAttachment(ctx context.Context, id string) ([]byte, error) {
+	var attachment []byte
+	err := r.db.QueryRowContext(ctx, `SELECT encrypted_attachment FROM client_commands WHERE id = ?`, id).Scan(&attachment)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get command attachment: %w", err)
+	}
+
+	return attachment, nil
+}
+
+func (r *SQLiteCommandRepository) ExpireStale(ctx context.Context, pendingBefore, deliveredBefore, now time.Time) error {
+	query := `
+	UPDATE client_commands SET status = ?, completed_at = ?
+	WHERE (status = ? AND issued_at < ?) OR (status = ? AND delivered_at < ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		CommandStatusExpired, db.TimeToString(now),
+		CommandStatusPending, db.TimeToString(pendingBefore),
+		CommandStatusDelivered, db.TimeToString(deliveredBefore),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to expire commands: %w", err)
+	}
+
+	return nil
+}
+
+// queryCommands runs a query selecting commandColumns and scans all rows
+func (r *SQLiteCommandRepository) queryCommands(ctx context.Context, query string, args ...any) ([]*Command, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query commands: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []*Command
+	for rows.Next() {
+		command, err := scanCommand(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan command row: %w", err)
+		}
+		commands = append(commands, command)
+	}
+
+	return commands, rows.Err()
+}
@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+const (
+	// maxResultLength limits how much of a reported result message is stored
+	maxResultLength = 10000
+	// maxAttachmentSize limits the size of a reported attachment
+	maxAttachmentSize = 10 * 1024 * 1024
+	// Defaults for settings that are not configured
+	defaultPendingLifetime = 10 * time.Minute
+	defaultResultTimeout   = 10 * time.Minute
+	defaultPollInterval    = time.Second
+)
+
+type CommandSettings struct {
+	PendingLifetime time.Duration // A command that is not picked up within this time expires
+	ResultTimeout   time.Duration // A picked up command without a result after this time expires
+	PollInterval    time.Duration // How often a waiting client is checked for new commands
+}
+
+type CommandService interface {
+	// IssueCommand queues a command for a client on behalf of a dashboard user
+	IssueCommand(clientID string, commandType CommandType, issuedBy string) (*Command, error)
+	// WaitForCommands hands out the pending commands of a client. If there are none, it waits up to maxWait
+	// for new ones and returns an empty list if none arrive. The returned commands are marked as delivered.
+	WaitForCommands(ctx context.Context, clientID string, maxWait time.Duration) ([]*Command, error)
+	// CompleteCommand stores the result a client reported for one of its delivered commands.
+	// The client credential is needed to encrypt an attachment with the MEK.
+	CompleteCommand(clientID, clientCredential, commandID string, result CommandResult) error
+	// GetCommands returns the most recent commands sent to a client, newest first
+	GetCommands(clientID string, limit int) ([]*Command, error)
+	// GetCommand returns a command by ID
+	GetCommand(commandID string) (*Command, error)
+	// GetAttachment returns the decrypted attachment of a command, or nil if it has none
+	GetAttachment(commandID string, mekStore encryption.MekStore) (*Attachment, error)
+}
+
+type commandService struct {
+	logger      logging.Logger
+	repo        CommandRepository
+	clientRepo  clients.ClientRepository
+	encryptor   encryption.Encryptor
+	mekProvider clients.ClientMekProvider
+	settings    CommandSettings
+	now         func() time.Time
+}
+
+// NewCommandService creates a new CommandService. The MEK provider is only needed for storing
+// attachments reported by clients and may be nil where no results are received.
+func NewCommandService(logger logging.Logger, repo CommandRepository, clientRepo clients.ClientRepository, encryptor encryption.Encryptor, mekProvider clients.ClientMekProvider, settings CommandSettings) *commandService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+	if settings.PendingLifetime <= 0 {
+		settings.PendingLifetime = defaultPendingLifetime
+	}
+	if settings.ResultTimeout <= 0 {
+		settings.ResultTimeout = defaultResultTimeout
+	}
+	if settings.PollInterval <= 0 {
+		settings.PollInterval = defaultPollInterval
+	}
+
+	return &commandService{
+		logger:      logger,
+		repo:        repo,
+		clientRepo:  clientRepo,
+		encryptor:   encryptor,
+		mekProvider: mekProvider,
+		settings:    settings,
+		now:         time.Now,
+	}
+}
+
+func (s *commandService) IssueCommand(clientID string, commandType CommandType, issuedBy string) (*Command, error) {
+	if !slices.Contains(SupportedCommandTypes(), commandType) {
+		return nil, NewCommandValidationError("unsupported command type: " + string(commandType))
+	}
+
+	ctx := context.Background()
+
+	client, err := s.clientRepo.GetByID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve client", err)
+		return nil, err
+	}
+	if client == nil {
+		return nil, clients.NewClientNotFoundError(clientID)
+	}
+	if client.IsDisabled {
+		return nil, NewCommandValidationError("commands cannot be sent to a disabled client")
+	}
+
+	s.expireStale(ctx)
+
+	command := &Command{
+		ID:       uuid.New().String(),
+		ClientID: clientID,
+		Type:     commandType,
+		Status:   CommandStatusPending,
+		IssuedBy: issuedBy,
+		IssuedAt: s.now().UTC(),
+	}
+	if err := s.repo.Create(ctx, command); err != nil {
+		s.logger.Error("Failed to create command", err)
+		return nil, err
+	}
+
+	s.logger.Info("Command issued", "command", command.ID, "type", commandType, "client", clientID, "issuedBy", issuedBy)
+	return command, nil
+}
+
+func (s *commandService) WaitForCommands(ctx context.Context, clientID string, maxWait time.Duration) ([]*Command, error) {
+	s.expireStale(ctx)
+
+	// The dashboard runs in its own process, so new commands are found by checking the database
+	ticker := time.NewTicker(s.settings.PollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(maxWait)
+	defer timeout.Stop()
+
+	for {
+		commands, err := s.repo.TakePending(ctx, clientID, s.now().UTC())
+		if err != nil {
+			s.logger.Error("Failed to take pending commands", err)
+			return nil, err
+		}
+		if len(commands) > 0 {
+			for _, command := range commands {
+				s.logger.Info("Command delivered", "command", command.ID, "type", command.Type, "client", clientID)
+			}
+			return commands, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			return []*Command{}, nil
+		case <-ctx.Done():
+			return []*Command{}, nil
+		}
+	}
+}
+
+func (s *commandService) CompleteCommand(clientID, clientCredential, commandID string, result CommandResult) error {
+	if len(result.Message) > maxResultLength {
+		result.Message = result.Message[:maxResultLength]
+	}
+	if len(result.Attachment) > maxAttachmentSize {
+		return NewCommandValidationError("attachment is too large")
+	}
+	if len(result.Attachment) > 0 && result.AttachmentMimeType == "" {
+		return NewCommandValidationError("attachment MIME type is required")
+	}
+
+	var encryptedAttachment []byte
+	attachmentMimeType := ""
+	if len(result.Attachment) > 0 {
+		mek, err := s.mekProvider.UncoverMek(clientID, clientCredential)
+		if err != nil {
+			s.logger.Error("Failed to uncover MEK", err)
+			return err
+		}
+
+		encryptedAttachment, err = s.encryptor.Encrypt(result.Attachment, mek)
+		if err != nil {
+			s.logger.Error("Failed to encrypt command attachment", err)
+			return err
+		}
+		attachmentMimeType = result.AttachmentMimeType
+	}
+
+	status := CommandStatusFailed
+	if result.Success {
+		status = CommandStatusSucceeded
+	}
+
+	completed, err := s.repo.Complete(context.Background(), commandID, clientID, status, result.Message, encryptedAttachment, attachmentMimeType, s.now().UTC())
+	if err != nil {
+		s.logger.Error("Failed to complete command", err)
+		return err
+	}
+	if !completed {
+		// Unknown, belongs to another client, already completed or expired
+		return NewCommandNotFoundError(commandID)
+	}
+
+	s.logger.Info("Command completed", "command", commandID, "client", clientID, "status", status)
+	return nil
+}
+
+func (s *commandService) GetCommands(clientID string, limit int) ([]*Command, error) {
+	ctx := context.Background()
+	s.expireStale(ctx)
+
+	commands, err := s.repo.GetByClientID(ctx, clientID, limit)
+	if err != nil {
+		s.logger.Error("Failed to retrieve commands", err)
+		return nil, err
+	}
+	return commands, nil
+}
+
+func (s *commandService) GetCommand(commandID string) (*Command, error) {
+	command, err := s.repo.GetByID(context.Background(), commandID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve command", err)
+		return nil, err
+	}
+	if command == nil {
+		return nil, NewCommandNotFoundError(commandID)
+	}
+	return command, nil
+}
+
+func (s *commandService) GetAttachment(commandID string, mekStore encryption.MekStore) (*Attachment, error) {
+	command, err := s.GetCommand(commandID)
+	if err != nil {
+		return nil, err
+	}
+	if !command.HasAttachment() {
+		return nil, nil
+	}
+
+	mek, err := mekStore.GetMek()
+	if err != nil {
+		s.logger.Error("Failed to get MEK for command attachment", err)
+		return nil, err
+	}
+
+	encrypted, err := s.repo.GetEncryptedAttachment(context.Background(), commandID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve command attachment", err)
+		return nil, err
+	}
+
+	data, err := s.encryptor.Decrypt(encrypted, mek)
+	if err != nil {
+		s.logger.Error("Failed to decrypt command attachment", err)
+		return nil, err
+	}
+
+	return &Attachment{Data: data, MimeType: command.AttachmentMimeType}, nil
+}
+
+// expireStale expires the commands that were not picked up or answered in time
+func (s *commandService) expireStale(ctx context.Context) {
+	now := s.now().UTC()
+	if err := s.repo.ExpireStale(ctx, now.Add(-s.settings.PendingLifetime), now.Add(-s.settings.ResultTimeout), now); err != nil {
+		s.logger.Warn("Failed to expire stale commands", "error", err)
+	}
+}
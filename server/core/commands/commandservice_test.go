@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+type testMekProvider struct {
+	mek []byte
+}
+
+func (p *testMekProvider) UncoverMek(clientID, clientSecret string) ([]byte, error) {
+	return p.mek, nil
+}
+
+type testMekStore struct {
+	mek []byte
+}
+
+func (s *testMekStore) GetMek() ([]byte, error) { return s.mek, nil }
+func (s *testMekStore) SetMek(mek []byte) error { s.mek = mek; return nil }
+func (s *testMekStore) ClearMek() error         { s.mek = nil; return nil }
+
+func setupTestCommandService(t *testing.T) (*commandService, []byte) {
+	t.Helper()
+
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	clientRepo, err := clients.NewSQLiteClientRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create client repository: %v", err)
+	}
+	if err := clientRepo.Create(t.Context(), &clients.Client{ID: "cam", CreatedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	repo, err := NewSQLiteCommandRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create command repository: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	mek, _ := encryptor.GenerateKey()
+	service := NewCommandService(nil, repo, clientRepo, encryptor, &testMekProvider{mek: mek}, CommandSettings{
+		PollInterval: 10 * time.Millisecond,
+	})
+	return service, mek
+}
+
+func TestCommandService_IssueAndComplete(t *testing.T) {
+	service, mek := setupTestCommandService(t)
+
+	command, err := service.IssueCommand("cam", CommandTypeSnapshot, "alice")
+	if err != nil {
+		t.Fatalf("Failed to issue command: %v", err)
+	}
+
+	delivered, err := service.WaitForCommands(t.Context(), "cam", time.Second)
+	if err != nil {
+		t.Fatalf("Failed to wait for commands: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0].ID != command.ID || delivered[0].Status != CommandStatusDelivered {
+		t.Fatalf("Expected the issued command to be delivered, got %+v", delivered)
+	}
+
+	// A command is delivered only once
+	delivered, err = service.WaitForCommands(t.Context(), "cam", 50*time.Millisecond)
+	if err != nil || len(delivered) != 0 {
+		t.Fatalf("Expected no further commands, got %+v (err %v)", delivered, err)
+	}
+
+	image := []byte("jpeg data")
+	result := CommandResult{Success: true, Message: "snapshot taken", Attachment: image, AttachmentMimeType: "image/jpeg"}
+	if err := service.CompleteCommand("other", "", command.ID, result); !IsCommandNotFoundError(err) {
+		t.Errorf("Expected not found error for another client, got %v", err)
+	}
+	if err := service.CompleteCommand("cam", "secret", command.ID, result); err != nil {
+		t.Fatalf("Failed to complete command: %v", err)
+	}
+	if err := service.CompleteCommand("cam", "secret", command.ID, result); !IsCommandNotFoundError(err) {
+		t.Errorf("Expected not found error for a completed command, got %v", err)
+	}
+
+	history, err := service.GetCommands("cam", 10)
+	if err != nil {
+		t.Fatalf("Failed to get commands: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != CommandStatusSucceeded || history[0].IssuedBy != "alice" || history[0].Result != "snapshot taken" {
+		t.Fatalf("Unexpected command history: %+v", history)
+	}
+
+	attachment, err := service.GetAttachment(command.ID, &testMekStore{mek: mek})
+	if err != nil {
+		t.Fatalf("Failed to get attachment: %v", err)
+	}
+	if attachment == nil || !bytes.Equal(attachment.Data, image) || attachment.MimeType != "image/jpeg" {
+		t.Errorf("Unexpected attachment: %+v", attachment)
+	}
+}
+
+func TestCommandService_IssueCommandValidation(t *testing.T) {
+	service, _ := setupTestCommandService(t)
+
+	if _, err := service.IssueCommand("cam", "self_destruct", "alice"); !IsCommandValidationError(err) {
+		t.Errorf("Expected validation error for unknown command type, got %v", err)
+	}
+	if _, err := service.IssueCommand("missing", CommandTypeDiagnostics, "alice"); !clients.IsClientNotFoundError(err) {
+		t.Errorf("Expected not found error for unknown client, got %v", err)
+	}
+}
+
+func TestCommandService_ExpireStale(t *testing.T) {
+	service, _ := setupTestCommandService(t)
+
+	command, err := service.IssueCommand("cam", CommandTypeReloadSettings, "alice")
+	if err != nil {
+		t.Fatalf("Failed to issue command: %v", err)
+	}
+
+	// The client does not pick the command up in time
+	service.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	delivered, err := service.WaitForCommands(t.Context(), "cam", 0)
+	if err != nil || len(delivered) != 0 {
+		t.Fatalf("Expected the stale command not to be delivered, got %+v (err %v)", delivered, err)
+	}
+
+	expired, err := service.GetCommand(command.ID)
+	if err != nil {
+		t.Fatalf("Failed to get command: %v", err)
+	}
+	if expired.Status != CommandStatusExpired || expired.CompletedAt == nil {
+		t.Errorf("Expected the command to be expired, got %+v", expired)
+	}
+}
@@ -0,0 +1,38 @@
+package commands
+
+// Error types for commands
+type CommandNotFoundError struct {
+	ID string
+}
+
+type CommandValidationError struct {
+	Message string
+}
+
+func (e *CommandNotFoundError) Error() string {
+	return "Command not found: " + e.ID
+}
+
+func (e *CommandValidationError) Error() string {
+	return "Command validation failed: " + e.Message
+}
+
+// helper functions for error handling
+
+func IsCommandNotFoundError(err error) bool {
+	_, ok := err.(*CommandNotFoundError)
+	return ok
+}
+
+func IsCommandValidationError(err error) bool {
+	_, ok := err.(*CommandValidationError)
+	return ok
+}
+
+func NewCommandNotFoundError(id string) error {
+	return &CommandNotFoundError{ID: id}
+}
+
+func NewCommandValidationError(message string) error {
+	return &CommandValidationError{Message: message}
+}
@@ -3,8 +3,10 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Config holds the configuration for the dashboard and capture server applications
@@ -14,6 +16,8 @@ type Config struct {
 	CapturePort                 int                          `json:"capture_port"`
 	DatabasePath                string                       `json:"database_path"`
 	LogPath                     string                       `json:"log_path"`
+	ExportPath                  string                       `json:"export_path,omitempty"`
+	ExportLifetimeHours         int                          `json:"export_lifetime_hours,omitempty"`
 	LogLevel                    string                       `json:"log_level"`
 	TrustedProxies              *TrustedProxySettings        `json:"trusted_proxies,omitempty"`
 	StorageNotificationSettings *StorageNotificationSettings `json:"storage_notification_settings,omitempty"`
@@ -21,6 +25,18 @@ type Config struct {
 	AuthEventSettings           *AuthEventSettings           `json:"auth_event_settings,omitempty"`
 	SMTPSettings                *SMTPSettings                `json:"smtp_settings,omitempty"`
 	StreamingSettings           *StreamingSettings           `json:"streaming_settings,omitempty"`
+	DashboardSessionSettings    *DashboardSessionSettings    `json:"dashboard_session_settings,omitempty"`
+	DashboardLoginSettings      *DashboardLoginSettings      `json:"dashboard_login_settings,omitempty"`
+	ClientTokenSettings         *ClientTokenSettings         `json:"client_token_settings,omitempty"`
+	CaptureTLSSettings          *CaptureTLSSettings          `json:"capture_tls_settings,omitempty"`
+	PairingSettings             *PairingSettings             `json:"pairing_settings,omitempty"`
+	AutomationAPISettings       *AutomationAPISettings       `json:"automation_api_settings,omitempty"`
+	HeartbeatSettings           *HeartbeatSettings           `json:"heartbeat_settings,omitempty"`
+	CommandSettings             *CommandSettings             `json:"command_settings,omitempty"`
+	SettingsSyncSettings        *SettingsSyncSettings        `json:"settings_sync_settings,omitempty"`
+	WebhookSettings             *WebhookSettings             `json:"webhook_settings,omitempty"`
+	PushNotificationSettings    *PushNotificationSettings    `json:"push_notification_settings,omitempty"`
+	MQTTSettings                *MQTTSettings                `json:"mqtt_settings,omitempty"`
 }
 
 // StorageNotificationSettings holds the configuration for storage notifications
@@ -44,11 +60,14 @@ type MotionNotificationSettings struct {
 
 // AuthEventSettings holds the configuration for authentication failure tracking and notifications
 type AuthEventSettings struct {
-	TimeWindowMinutes     int    `json:"time_window_minutes"`    // Time window for counting failures
-	AutoDisableThreshold  int    `json:"auto_disable_threshold"` // Number of failed attempts after which to auto-disable the client (0 to disable)
-	NotificationRecipient string `json:"notification_recipient"` // Email recipient for notifications (empty to disable notifications)
-	NotificationThreshold int    `json:"notification_threshold"` // Number of failures that trigger notification (0 to disable)
-	MinIntervalMinutes    int    `json:"min_interval_minutes"`   // Minimum interval between notifications for the same client
+	TimeWindowMinutes     int    `json:"time_window_minutes"`     // Time window for counting failures
+	AutoDisableThreshold  int    `json:"auto_disable_threshold"`  // Number of failed attempts after which to auto-disable the client (0 to disable)
+	NotificationRecipient string `json:"notification_recipient"`  // Email recipient for notifications (empty to disable notifications)
+	NotificationThreshold int    `json:"notification_threshold"`  // Number of failures that trigger notification (0 to disable)
+	MinIntervalMinutes    int    `json:"min_interval_minutes"`    // Minimum interval between notifications for the same client
+	IPBlockThreshold      int    `json:"ip_block_threshold"`      // Number of failures from one IP address after which to block it, for any client ID (0 to disable)
+	IPBlockWindowMinutes  int    `json:"ip_block_window_minutes"` // Time window for counting the failures of one IP address (0 to use time_window_minutes)
+	IPBlockMinutes        int    `json:"ip_block_minutes"`        // How long a blocked IP address stays blocked
 }
 
 // SMTPSettings holds the configuration for SMTP email sending
@@ -60,6 +79,256 @@ type SMTPSettings struct {
 	FromAddr string `json:"from_addr"`
 }
 
+// DashboardSessionSettings holds the configuration for server-side dashboard sessions
+type DashboardSessionSettings struct {
+	IdleTimeoutMinutes    int    `json:"idle_timeout_minutes"`    // Sessions expire after this many minutes without activity
+	AbsoluteLifetimeHours int    `json:"absolute_lifetime_hours"` // Sessions expire this many hours after login, regardless of activity
+	PersistSessions       bool   `json:"persist_sessions"`        // Keep sessions across restarts in an encrypted file
+	PersistencePath       string `json:"persistence_path"`        // Path of the encrypted session file (empty for the default location)
+}
+
+// DefaultDashboardSessionSettings returns default configuration for dashboard sessions
+func DefaultDashboardSessionSettings() DashboardSessionSettings {
+	return DashboardSessionSettings{
+		IdleTimeoutMinutes:    30,
+		AbsoluteLifetimeHours: 12,
+		PersistSessions:       false,
+	}
+}
+
+// DashboardLoginSettings holds the configuration for brute-force protection of the dashboard login
+type DashboardLoginSettings struct {
+	BaseDelaySeconds  int `json:"base_delay_seconds"`  // Delay after the first failed login, doubled with every further failure
+	MaxDelaySeconds   int `json:"max_delay_seconds"`   // Upper bound for the delay between two login attempts
+	LockoutThreshold  int `json:"lockout_threshold"`   // Failed logins within the time window that lock the login (0 to disable lockouts)
+	LockoutMinutes    int `json:"lockout_minutes"`     // How long the login stays locked
+	TimeWindowMinutes int `json:"time_window_minutes"` // Time window for counting failed logins
+}
+
+// DefaultDashboardLoginSettings returns default configuration for dashboard login protection
+func DefaultDashboardLoginSettings() DashboardLoginSettings {
+	return DashboardLoginSettings{
+		BaseDelaySeconds:  1,
+		MaxDelaySeconds:   60,
+		LockoutThreshold:  10,
+		LockoutMinutes:    15,
+		TimeWindowMinutes: 15,
+	}
+}
+
+// ClientTokenSettings holds the configuration for the access tokens that capture clients use instead of their secret
+type ClientTokenSettings struct {
+	LifetimeMinutes int `json:"lifetime_minutes"`  // How long an access token is valid
+	MaxCachedTokens int `json:"max_cached_tokens"` // Maximum number of tokens, and thus unwrapped MEKs, held in memory
+}
+
+// DefaultClientTokenSettings returns default configuration for client access tokens
+func DefaultClientTokenSettings() ClientTokenSettings {
+	return ClientTokenSettings{
+		LifetimeMinutes: 15,
+		MaxCachedTokens: 256,
+	}
+}
+
+// CaptureTLSSettings holds the configuration for serving the capture API over TLS, optionally with client certificates
+type CaptureTLSSettings struct {
+	CertFile                      string   `json:"cert_file"`                        // Server certificate (empty to use a certificate issued by the CryoSpy CA)
+	KeyFile                       string   `json:"key_file"`                         // Private key of the server certificate
+	ServerHosts                   []string `json:"server_hosts"`                     // Host names and IP addresses for the certificate issued by the CryoSpy CA
+	RequireClientCertificates     bool     `json:"require_client_certificates"`      // Only accept clients that present a certificate issued by the CryoSpy CA
+	CADirectory                   string   `json:"ca_directory"`                     // Directory holding the CryoSpy CA (empty for a "ca" directory next to the database)
+	ClientCertificateValidityDays int      `json:"client_certificate_validity_days"` // How long issued client certificates are valid
+}
+
+// DefaultCaptureTLSSettings returns default configuration for TLS on the capture server
+func DefaultCaptureTLSSettings() CaptureTLSSettings {
+	return CaptureTLSSettings{
+		ServerHosts:                   []string{"localhost", "127.0.0.1"},
+		RequireClientCertificates:     true,
+		ClientCertificateValidityDays: 825,
+	}
+}
+
+// ClientCertificatesEnabled reports whether capture clients need a certificate issued by the CryoSpy CA
+func (c *Config) ClientCertificatesEnabled() bool {
+	return c.CaptureTLSSettings != nil && c.CaptureTLSSettings.RequireClientCertificates
+}
+
+// ResolveCADirectory returns the directory of the CryoSpy CA
+func (c *Config) ResolveCADirectory() string {
+	if c.CaptureTLSSettings != nil && c.CaptureTLSSettings.CADirectory != "" {
+		return c.CaptureTLSSettings.CADirectory
+	}
+	return filepath.Join(filepath.Dir(c.DatabasePath), "ca")
+}
+
+// ResolveExportDirectory returns the directory in which the clips of deleted clients are exported
+func (c *Config) ResolveExportDirectory() string {
+	if c.ExportPath != "" {
+		return c.ExportPath
+	}
+	return filepath.Join(filepath.Dir(c.DatabasePath), "exports")
+}
+
+// defaultExportLifetimeHours is how long the exports of deleted clients are kept when the config does not say
+const defaultExportLifetimeHours = 24
+
+// ResolveExportLifetime returns how long the exports of deleted clients are kept before they are deleted
+func (c *Config) ResolveExportLifetime() time.Duration {
+	if c.ExportLifetimeHours > 0 {
+		return time.Duration(c.ExportLifetimeHours) * time.Hour
+	}
+	return defaultExportLifetimeHours * time.Hour
+}
+
+// PairingSettings holds the configuration for the one-time codes that enroll new capture devices
+type PairingSettings struct {
+	CodeLifetimeMinutes int    `json:"code_lifetime_minutes"` // How long a pairing code can be redeemed
+	CaptureServerURL    string `json:"capture_server_url"`    // Capture server URL as seen by the devices, included in the QR code (empty to leave it out)
+}
+
+// DefaultPairingSettings returns default configuration for pairing codes
+func DefaultPairingSettings() PairingSettings {
+	return PairingSettings{
+		CodeLifetimeMinutes: 15,
+	}
+}
+
+// AutomationAPISettings holds the configuration for the dashboard API used by home automation systems
+type AutomationAPISettings struct {
+	Token string `json:"token"` // Bearer token that API requests must carry (empty to disable the API)
+}
+
+// HeartbeatSettings holds the configuration for monitoring the heartbeats of capture clients
+type HeartbeatSettings struct {
+	OfflineAfterMinutes   int    `json:"offline_after_minutes"`  // A client is offline once it has not sent a heartbeat for this long
+	CheckIntervalSeconds  int    `json:"check_interval_seconds"` // How often the capture server looks for clients that went offline
+	NotificationRecipient string `json:"notification_recipient"` // Email recipient for offline notifications (empty to disable notifications)
+}
+
+// DefaultHeartbeatSettings returns default configuration for heartbeat monitoring
+func DefaultHeartbeatSettings() HeartbeatSettings {
+	return HeartbeatSettings{
+		OfflineAfterMinutes:  5,
+		CheckIntervalSeconds: 60,
+	}
+}
+
+// CommandSettings holds the configuration for commands sent from the dashboard to capture clients
+type CommandSettings struct {
+	PendingLifetimeMinutes int `json:"pending_lifetime_minutes"` // A command that is not picked up within this time expires
+	ResultTimeoutMinutes   int `json:"result_timeout_minutes"`   // A picked up command without a result after this time expires
+	MaxWaitSeconds         int `json:"max_wait_seconds"`         // Longest time the capture server holds a request of a waiting client open
+}
+
+// DefaultCommandSettings returns default configuration for client commands
+func DefaultCommandSettings() CommandSettings {
+	return CommandSettings{
+		PendingLifetimeMinutes: 10,
+		ResultTimeoutMinutes:   10,
+		MaxWaitSeconds:         60,
+	}
+}
+
+// SettingsSyncSettings holds the configuration for capture clients waiting for settings changes
+type SettingsSyncSettings struct {
+	MaxWaitSeconds int `json:"max_wait_seconds"` // Longest time the capture server holds a request of a client waiting for a change open
+}
+
+// DefaultSettingsSyncSettings returns default configuration for settings synchronization
+func DefaultSettingsSyncSettings() SettingsSyncSettings {
+	return SettingsSyncSettings{
+		MaxWaitSeconds: 60,
+	}
+}
+
+// WebhookSettings holds the configuration for webhooks that receive notification events as signed JSON payloads
+type WebhookSettings struct {
+	URLs                    []string `json:"urls"`                      // Every event is posted to each of these URLs (empty to disable webhooks)
+	Secret                  string   `json:"secret"`                    // Key for the HMAC-SHA256 signature of the payloads (empty to send them unsigned)
+	Events                  []string `json:"events"`                    // Event types to send, e.g. "motion_detected" (empty for all events)
+	MinIntervalMinutes      int      `json:"min_interval_minutes"`      // Minimum interval between events of the same type for the same client
+	MaxRetries              int      `json:"max_retries"`               // How often a failed delivery is retried
+	RetryBackoffSeconds     int      `json:"retry_backoff_seconds"`     // Wait before the first retry, doubled for every further retry
+	TimeoutSeconds          int      `json:"timeout_seconds"`           // Timeout of a single delivery attempt
+	StorageWarningThreshold float64  `json:"storage_warning_threshold"` // Fraction of the storage limit above which capacity warnings are sent
+	AuthFailureThreshold    int      `json:"auth_failure_threshold"`    // Number of failed client authentications that trigger an event
+}
+
+// DefaultWebhookSettings returns default configuration for webhooks, without any URLs
+func DefaultWebhookSettings() WebhookSettings {
+	return WebhookSettings{
+		MinIntervalMinutes:      5,
+		MaxRetries:              3,
+		RetryBackoffSeconds:     2,
+		TimeoutSeconds:          10,
+		StorageWarningThreshold: 0.8,
+		AuthFailureThreshold:    5,
+	}
+}
+
+// PushNotificationSettings holds the configuration for push notifications to phones through ntfy or Gotify
+type PushNotificationSettings struct {
+	Ntfy                    *NtfySettings   `json:"ntfy,omitempty"`            // Publish to an ntfy topic (omit to disable)
+	Gotify                  *GotifySettings `json:"gotify,omitempty"`          // Publish to a Gotify application (omit to disable)
+	DashboardURL            string          `json:"dashboard_url"`             // Public address of the dashboard, for links to clips (empty for no links)
+	MinIntervalMinutes      int             `json:"min_interval_minutes"`      // Minimum interval between notifications of the same type for the same client
+	StorageWarningThreshold float64         `json:"storage_warning_threshold"` // Fraction of the storage limit above which capacity warnings are sent
+	AuthFailureThreshold    int             `json:"auth_failure_threshold"`    // Number of failed client authentications that trigger a notification
+}
+
+// NtfySettings holds the configuration for publishing to an ntfy server
+type NtfySettings struct {
+	ServerURL   string         `json:"server_url"`   // Address of the ntfy server, e.g. "https://ntfy.example.com"
+	Topic       string         `json:"topic"`        // Topic the phones subscribe to
+	AccessToken string         `json:"access_token"` // Access token for protected topics (empty to publish anonymously)
+	Priorities  map[string]int `json:"priorities"`   // Priority (1-5) per event type, e.g. {"motion_detected": 4}
+}
+
+// GotifySettings holds the configuration for publishing to a Gotify server
+type GotifySettings struct {
+	ServerURL  string         `json:"server_url"` // Address of the Gotify server, e.g. "https://gotify.example.com"
+	AppToken   string         `json:"app_token"`  // Token of the Gotify application that publishes the messages
+	Priorities map[string]int `json:"priorities"` // Priority (0-10) per event type, e.g. {"motion_detected": 8}
+}
+
+// DefaultPushNotificationSettings returns default configuration for push notifications, without any push service
+func DefaultPushNotificationSettings() PushNotificationSettings {
+	return PushNotificationSettings{
+		MinIntervalMinutes:      1,
+		StorageWarningThreshold: 0.8,
+		AuthFailureThreshold:    5,
+	}
+}
+
+// MQTTSettings holds the configuration for publishing events and states to an MQTT broker, with Home Assistant discovery
+type MQTTSettings struct {
+	BrokerURL               string  `json:"broker_url"`                // Address of the broker, e.g. "tcp://localhost:1883" or "ssl://broker:8883" (empty to disable MQTT)
+	Username                string  `json:"username"`                  // Username for the broker (empty for anonymous access)
+	Password                string  `json:"password"`                  // Password for the broker
+	ClientID                string  `json:"client_id"`                 // MQTT client ID of the capture server
+	TopicPrefix             string  `json:"topic_prefix"`              // Prefix of the state and command topics
+	DiscoveryPrefix         string  `json:"discovery_prefix"`          // Prefix under which Home Assistant looks for discovery configs
+	MotionOffDelaySeconds   int     `json:"motion_off_delay_seconds"`  // How long a motion sensor stays on after the last clip with motion
+	StateIntervalSeconds    int     `json:"state_interval_seconds"`    // How often discovery configs, storage usage and the arm mode are republished
+	StorageWarningThreshold float64 `json:"storage_warning_threshold"` // Fraction of the storage limit above which the storage status turns to "warning"
+	AuthFailureThreshold    int     `json:"auth_failure_threshold"`    // Number of failed client authentications that trigger an event
+	ArmCommandsEnabled      bool    `json:"arm_commands_enabled"`      // Lets anyone who can publish to the broker switch the arm mode
+}
+
+// DefaultMQTTSettings returns default configuration for MQTT, without a broker
+func DefaultMQTTSettings() MQTTSettings {
+	return MQTTSettings{
+		ClientID:                "cryospy-capture-server",
+		TopicPrefix:             "cryospy",
+		DiscoveryPrefix:         "homeassistant",
+		MotionOffDelaySeconds:   60,
+		StateIntervalSeconds:    300,
+		StorageWarningThreshold: 0.8,
+		AuthFailureThreshold:    5,
+	}
+}
+
 // StreamingSettings contains configuration for the streaming service
 type StreamingSettings struct {
 	// Cache configuration
@@ -113,15 +382,29 @@ func DefaultConfig() *Config {
 	}
 
 	defaultStreamingSettings := DefaultStreamingSettings()
+	defaultDashboardSessionSettings := DefaultDashboardSessionSettings()
+	defaultDashboardLoginSettings := DefaultDashboardLoginSettings()
+	defaultClientTokenSettings := DefaultClientTokenSettings()
+	defaultPairingSettings := DefaultPairingSettings()
+	defaultWebhookSettings := DefaultWebhookSettings()
+	defaultPushNotificationSettings := DefaultPushNotificationSettings()
+	defaultMQTTSettings := DefaultMQTTSettings()
 
 	return &Config{
-		WebAddr:           "127.0.0.1",
-		WebPort:           8080,
-		CapturePort:       8081,
-		DatabasePath:      filepath.Join(dbDir, "cryospy.db"),
-		LogPath:           filepath.Join(dbDir, "logs"),
-		LogLevel:          "info",
-		StreamingSettings: &defaultStreamingSettings,
+		WebAddr:                  "127.0.0.1",
+		WebPort:                  8080,
+		CapturePort:              8081,
+		DatabasePath:             filepath.Join(dbDir, "cryospy.db"),
+		LogPath:                  filepath.Join(dbDir, "logs"),
+		LogLevel:                 "info",
+		StreamingSettings:        &defaultStreamingSettings,
+		DashboardSessionSettings: &defaultDashboardSessionSettings,
+		DashboardLoginSettings:   &defaultDashboardLoginSettings,
+		ClientTokenSettings:      &defaultClientTokenSettings,
+		PairingSettings:          &defaultPairingSettings,
+		WebhookSettings:          &defaultWebhookSettings,
+		PushNotificationSettings: &defaultPushNotificationSettings,
+		MQTTSettings:             &defaultMQTTSettings,
 	}
 }
 
@@ -164,6 +447,52 @@ func (c *Config) Validate() error {
 	if c.CapturePort <= 0 || c.CapturePort > 65535 {
 		return fmt.Errorf("invalid capture port: %d", c.CapturePort)
 	}
+	if c.CaptureTLSSettings != nil {
+		if (c.CaptureTLSSettings.CertFile == "") != (c.CaptureTLSSettings.KeyFile == "") {
+			return fmt.Errorf("capture TLS settings need both a certificate and a key file, or neither")
+		}
+		if c.CaptureTLSSettings.CertFile == "" && len(c.CaptureTLSSettings.ServerHosts) == 0 {
+			return fmt.Errorf("capture TLS settings need server hosts when no certificate file is given")
+		}
+		if c.CaptureTLSSettings.RequireClientCertificates && c.CaptureTLSSettings.ClientCertificateValidityDays <= 0 {
+			return fmt.Errorf("invalid client certificate validity: %d days", c.CaptureTLSSettings.ClientCertificateValidityDays)
+		}
+	}
+	if c.AuthEventSettings != nil && c.AuthEventSettings.IPBlockThreshold > 0 {
+		if c.AuthEventSettings.IPBlockMinutes <= 0 {
+			return fmt.Errorf("invalid IP block duration: %d minutes", c.AuthEventSettings.IPBlockMinutes)
+		}
+		if c.AuthEventSettings.IPBlockWindowMinutes < 0 {
+			return fmt.Errorf("invalid IP block time window: %d minutes", c.AuthEventSettings.IPBlockWindowMinutes)
+		}
+	}
+	if c.WebhookSettings != nil {
+		for _, rawURL := range c.WebhookSettings.URLs {
+			parsed, err := url.Parse(rawURL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("invalid webhook URL: %q", rawURL)
+			}
+		}
+		if c.WebhookSettings.MaxRetries < 0 {
+			return fmt.Errorf("invalid webhook retry count: %d", c.WebhookSettings.MaxRetries)
+		}
+	}
+	if c.PushNotificationSettings != nil {
+		if ntfy := c.PushNotificationSettings.Ntfy; ntfy != nil && (ntfy.ServerURL == "" || ntfy.Topic == "") {
+			return fmt.Errorf("ntfy settings need a server URL and a topic")
+		}
+		if gotify := c.PushNotificationSettings.Gotify; gotify != nil && (gotify.ServerURL == "" || gotify.AppToken == "") {
+			return fmt.Errorf("gotify settings need a server URL and an app token")
+		}
+	}
+	if c.MQTTSettings != nil && c.MQTTSettings.BrokerURL != "" {
+		if c.MQTTSettings.TopicPrefix == "" || c.MQTTSettings.DiscoveryPrefix == "" {
+			return fmt.Errorf("MQTT settings need a topic prefix and a discovery prefix")
+		}
+		if c.MQTTSettings.StateIntervalSeconds <= 0 {
+			return fmt.Errorf("invalid MQTT state interval: %d seconds", c.MQTTSettings.StateIntervalSeconds)
+		}
+	}
 	return nil
 }
 
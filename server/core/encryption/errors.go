@@ -19,6 +19,23 @@ func (e *MekAlreadyExistsError) Error() string {
 	return fmt.Sprintf("MEK already exists with ID: %s", e.ID)
 }
 
+// create an error type that indicates that a secret did not unlock the MEK
+type InvalidMekSecretError struct {
+}
+
+func (e *InvalidMekSecretError) Error() string {
+	return "secret does not unlock the MEK"
+}
+
+// create an error type that indicates that a MEK key slot does not exist
+type MekSlotNotFoundError struct {
+	ID string
+}
+
+func (e *MekSlotNotFoundError) Error() string {
+	return fmt.Sprintf("MEK slot not found: %s", e.ID)
+}
+
 // helper functions for error handling
 func IsMekNotFoundError(err error) bool {
 	_, ok := err.(*MekNotFoundError)
@@ -29,6 +46,15 @@ func IsMekAlreadyExistsError(err error) bool {
 	return ok
 }
 
+func IsInvalidMekSecretError(err error) bool {
+	_, ok := err.(*InvalidMekSecretError)
+	return ok
+}
+func IsMekSlotNotFoundError(err error) bool {
+	_, ok := err.(*MekSlotNotFoundError)
+	return ok
+}
+
 // factory functions for mek-related errors
 func NewMekNotFoundError() error {
 	return &MekNotFoundError{}
@@ -36,3 +62,9 @@ func NewMekNotFoundError() error {
 func NewMekAlreadyExistsError(id string) error {
 	return &MekAlreadyExistsError{ID: id}
 }
+func NewInvalidMekSecretError() error {
+	return &InvalidMekSecretError{}
+}
+func NewMekSlotNotFoundError(id string) error {
+	return &MekSlotNotFoundError{ID: id}
+}
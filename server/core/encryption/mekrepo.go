@@ -2,6 +2,7 @@ package encryption
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -13,6 +14,14 @@ type MekRepository interface {
 	Get() (*Mek, error)
 	Update(mek *Mek) error
 	Delete() error
+	// CreateSlot adds an additional key slot for the MEK
+	CreateSlot(slot *MekSlot) error
+	// GetSlots retrieves all additional key slots, oldest first
+	GetSlots() ([]*MekSlot, error)
+	// GetSlotByID retrieves a key slot by its ID. Returns nil if the slot does not exist.
+	GetSlotByID(id string) (*MekSlot, error)
+	// DeleteSlot removes a key slot by its ID
+	DeleteSlot(id string) error
 }
 
 // SQLiteMekRepository implements MekRepository using SQLite
@@ -42,6 +51,23 @@ func (r *SQLiteMekRepository) createTables() error {
 	);`
 
 	_, err := r.db.Exec(createMekTable)
+	if err != nil {
+		return err
+	}
+
+	createMekSlotsTable := `
+	CREATE TABLE IF NOT EXISTS mek_slots (
+		id TEXT PRIMARY KEY,
+		mek_id TEXT NOT NULL,
+		label TEXT NOT NULL,
+		slot_type TEXT NOT NULL,
+		encrypted_encryption_key TEXT NOT NULL,
+		encryption_key_salt TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);`
+
+	_, err = r.db.Exec(createMekSlotsTable)
 	return err
 }
 
@@ -135,8 +161,12 @@ func (r *SQLiteMekRepository) Update(mek *Mek) error {
 	return nil
 }
 
-// Delete removes the MEK from the repository
+// Delete removes the MEK and all of its key slots from the repository
 func (r *SQLiteMekRepository) Delete() error {
+	if _, err := r.db.Exec(`DELETE FROM mek_slots`); err != nil {
+		return fmt.Errorf("failed to delete MEK slots: %w", err)
+	}
+
 	query := `DELETE FROM meks`
 
 	result, err := r.db.Exec(query)
@@ -155,3 +185,117 @@ func (r *SQLiteMekRepository) Delete() error {
 
 	return nil
 }
+
+// CreateSlot adds an additional key slot for the MEK
+func (r *SQLiteMekRepository) CreateSlot(slot *MekSlot) error {
+	query := `
+	INSERT INTO mek_slots (id, mek_id, label, slot_type, encrypted_encryption_key, encryption_key_salt, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.Exec(query,
+		slot.ID, slot.MekID, slot.Label, string(slot.Type),
+		slot.EncryptedEncryptionKey, slot.EncryptionKeySalt,
+		db.TimeToString(slot.CreatedAt), db.TimeToString(slot.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create MEK slot: %w", err)
+	}
+
+	return nil
+}
+
+// GetSlots retrieves all additional key slots, oldest first
+func (r *SQLiteMekRepository) GetSlots() ([]*MekSlot, error) {
+	query := `
+	SELECT id, mek_id, label, slot_type, encrypted_encryption_key, encryption_key_salt, created_at, updated_at
+	FROM mek_slots ORDER BY created_at ASC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MEK slots: %w", err)
+	}
+	defer rows.Close()
+
+	var slots []*MekSlot
+	for rows.Next() {
+		slot, err := scanMekSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+
+	return slots, rows.Err()
+}
+
+// GetSlotByID retrieves a key slot by its ID
+// Returns nil if the slot does not exist (this is not an error)
+func (r *SQLiteMekRepository) GetSlotByID(id string) (*MekSlot, error) {
+	query := `
+	SELECT id, mek_id, label, slot_type, encrypted_encryption_key, encryption_key_salt, created_at, updated_at
+	FROM mek_slots WHERE id = ?`
+
+	slot, err := scanMekSlot(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return slot, nil
+}
+
+// DeleteSlot removes a key slot by its ID
+func (r *SQLiteMekRepository) DeleteSlot(id string) error {
+	result, err := r.db.Exec(`DELETE FROM mek_slots WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete MEK slot: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("MEK slot with ID %s not found", id)
+	}
+
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMekSlot reads a single key slot from a row
+func scanMekSlot(row rowScanner) (*MekSlot, error) {
+	slot := &MekSlot{}
+	var slotType, createdAtStr, updatedAtStr string
+	err := row.Scan(
+		&slot.ID, &slot.MekID, &slot.Label, &slotType,
+		&slot.EncryptedEncryptionKey, &slot.EncryptionKeySalt,
+		&createdAtStr, &updatedAtStr,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan MEK slot: %w", err)
+	}
+	slot.Type = MekSlotType(slotType)
+
+	slot.CreatedAt, err = db.StringToTime(createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+	}
+
+	slot.UpdatedAt, err = db.StringToTime(updatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at timestamp: %w", err)
+	}
+
+	return slot, nil
+}
@@ -398,3 +398,123 @@ func TestMekRepository_IntegrationCRUD(t *testing.T) {
 		t.Error("MEK should be nil after deletion")
 	}
 }
+
+func TestMekRepository_Slots(t *testing.T) {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer testDB.Close()
+
+	repo, err := NewSQLiteMekRepository(testDB)
+	if err != nil {
+		t.Fatalf("NewSQLiteMekRepository() failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	first := &MekSlot{
+		ID:                     "slot-1",
+		MekID:                  "test-mek-id",
+		Label:                  "Backup password",
+		Type:                   MekSlotTypePassword,
+		EncryptedEncryptionKey: "encrypted-1",
+		EncryptionKeySalt:      "salt-1",
+		CreatedAt:              now,
+		UpdatedAt:              now,
+	}
+	second := &MekSlot{
+		ID:                     "slot-2",
+		MekID:                  "test-mek-id",
+		Label:                  "Recovery key",
+		Type:                   MekSlotTypeRecoveryKey,
+		EncryptedEncryptionKey: "encrypted-2",
+		EncryptionKeySalt:      "salt-2",
+		CreatedAt:              now.Add(time.Minute),
+		UpdatedAt:              now.Add(time.Minute),
+	}
+
+	if err := repo.CreateSlot(second); err != nil {
+		t.Fatalf("CreateSlot() failed: %v", err)
+	}
+	if err := repo.CreateSlot(first); err != nil {
+		t.Fatalf("CreateSlot() failed: %v", err)
+	}
+
+	slots, err := repo.GetSlots()
+	if err != nil {
+		t.Fatalf("GetSlots() failed: %v", err)
+	}
+	if len(slots) != 2 {
+		t.Fatalf("Expected 2 slots, got %d", len(slots))
+	}
+	if slots[0].ID != "slot-1" || slots[1].ID != "slot-2" {
+		t.Error("Slots should be ordered by creation time")
+	}
+	if slots[1].Type != MekSlotTypeRecoveryKey {
+		t.Errorf("Expected slot type %s, got %s", MekSlotTypeRecoveryKey, slots[1].Type)
+	}
+
+	retrieved, err := repo.GetSlotByID("slot-1")
+	if err != nil {
+		t.Fatalf("GetSlotByID() failed: %v", err)
+	}
+	if retrieved == nil || retrieved.Label != "Backup password" {
+		t.Errorf("Unexpected slot retrieved: %+v", retrieved)
+	}
+
+	missing, err := repo.GetSlotByID("missing")
+	if err != nil {
+		t.Fatalf("GetSlotByID() for missing slot failed: %v", err)
+	}
+	if missing != nil {
+		t.Error("GetSlotByID() should return nil for a missing slot")
+	}
+
+	if err := repo.DeleteSlot("slot-1"); err != nil {
+		t.Fatalf("DeleteSlot() failed: %v", err)
+	}
+	if err := repo.DeleteSlot("slot-1"); err == nil {
+		t.Error("DeleteSlot() should fail for a slot that no longer exists")
+	}
+
+	slots, err = repo.GetSlots()
+	if err != nil {
+		t.Fatalf("GetSlots() failed: %v", err)
+	}
+	if len(slots) != 1 {
+		t.Errorf("Expected 1 slot after deletion, got %d", len(slots))
+	}
+}
+
+func TestMekRepository_Delete_RemovesSlots(t *testing.T) {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer testDB.Close()
+
+	repo, err := NewSQLiteMekRepository(testDB)
+	if err != nil {
+		t.Fatalf("NewSQLiteMekRepository() failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	if err := repo.Create(&Mek{ID: "test-mek-id", EncryptedEncryptionKey: "key", EncryptionKeySalt: "salt", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if err := repo.CreateSlot(&MekSlot{ID: "slot-1", MekID: "test-mek-id", Label: "Backup", Type: MekSlotTypePassword, EncryptedEncryptionKey: "key", EncryptionKeySalt: "salt", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateSlot() failed: %v", err)
+	}
+
+	if err := repo.Delete(); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	slots, err := repo.GetSlots()
+	if err != nil {
+		t.Fatalf("GetSlots() failed: %v", err)
+	}
+	if len(slots) != 0 {
+		t.Errorf("Expected no slots after deleting the MEK, got %d", len(slots))
+	}
+}
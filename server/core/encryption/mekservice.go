@@ -16,9 +16,21 @@ type MekService interface {
 	// GetMek retrieves the MEK from the repository
 	GetMek() (*Mek, error)
 	// ChangeMekPassword updates the existing MEK with a new password (requires old password to decrypt)
+	// The old password may also be the secret of any key slot, e.g. a recovery key.
 	ChangeMekPassword(oldPassword, newPassword string) (*Mek, error)
 	// DeleteMek deletes the MEK from the database
 	DeleteMek() error
+	// UnlockMek decrypts the MEK value with a secret, trying the primary password and every key slot
+	UnlockMek(secret string) ([]byte, error)
+	// GetMekSlots retrieves all additional key slots
+	GetMekSlots() ([]*MekSlot, error)
+	// AddPasswordSlot adds a key slot unlocked by another password (requires a current secret)
+	AddPasswordSlot(currentSecret, label, newPassword string) (*MekSlot, error)
+	// AddRecoveryKeySlot adds a key slot unlocked by a newly generated recovery key (requires a current secret)
+	// The formatted recovery key is returned once and is not stored anywhere.
+	AddRecoveryKeySlot(currentSecret, label string) (*MekSlot, string, error)
+	// RemoveMekSlot removes a key slot (requires a current secret)
+	RemoveMekSlot(currentSecret, slotID string) error
 }
 
 type mekService struct {
@@ -142,30 +154,11 @@ func (s *mekService) ChangeMekPassword(oldPassword, newPassword string) (*Mek, e
 		return nil, NewMekNotFoundError()
 	}
 
-	// Decrypt the current MEK using the old password
-	oldSaltBytes, err := base64.StdEncoding.DecodeString(mek.EncryptionKeySalt)
-	if err != nil {
-		s.logger.Error("Failed to decode old MEK salt", err)
-		return nil, fmt.Errorf("failed to decode old MEK salt: %w", err)
-	}
-
-	oldKey, err := s.encryptor.DeriveKeyFromSecret([]byte(oldPassword), oldSaltBytes)
-	if err != nil {
-		s.logger.Error("Failed to derive key from old password", err)
-		return nil, fmt.Errorf("failed to derive key from old password: %w", err)
-	}
-
-	encryptedMekBytes, err := base64.StdEncoding.DecodeString(mek.EncryptedEncryptionKey)
-	if err != nil {
-		s.logger.Error("Failed to decode encrypted MEK", err)
-		return nil, fmt.Errorf("failed to decode encrypted MEK: %w", err)
-	}
-
-	// Decrypt the MEK value using the old password
-	mekValue, err := s.encryptor.Decrypt(encryptedMekBytes, oldKey)
+	// Decrypt the current MEK using the old password (or any other slot secret)
+	mekValue, err := s.unlockMekValue(mek, oldPassword)
 	if err != nil {
 		s.logger.Error("Failed to decrypt MEK with old password", err)
-		return nil, fmt.Errorf("failed to decrypt MEK with old password (invalid old password?): %w", err)
+		return nil, err
 	}
 
 	// Generate a new salt and derive a key from the new password
@@ -215,3 +208,170 @@ func (s *mekService) DeleteMek() error {
 	s.logger.Info("MEK deleted successfully")
 	return nil
 }
+
+func (s *mekService) UnlockMek(secret string) ([]byte, error) {
+	mek, err := s.GetMek()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.unlockMekValue(mek, secret)
+}
+
+// unlockMekValue tries the primary password wrap first and then every key slot.
+// Recovery key slots are tried with the normalized form of the secret.
+func (s *mekService) unlockMekValue(mek *Mek, secret string) ([]byte, error) {
+	if mekValue, err := DecryptMek(mek, secret, s.encryptor); err == nil {
+		return mekValue, nil
+	}
+
+	slots, err := s.repo.GetSlots()
+	if err != nil {
+		s.logger.Error("Failed to get MEK slots", err)
+		return nil, fmt.Errorf("failed to get MEK slots: %w", err)
+	}
+
+	for _, slot := range slots {
+		if slot.MekID != mek.ID {
+			continue
+		}
+
+		candidate := secret
+		if slot.Type == MekSlotTypeRecoveryKey {
+			candidate = NormalizeRecoveryKey(secret)
+		}
+
+		slotMek := &Mek{
+			ID:                     slot.MekID,
+			EncryptedEncryptionKey: slot.EncryptedEncryptionKey,
+			EncryptionKeySalt:      slot.EncryptionKeySalt,
+		}
+		if mekValue, err := DecryptMek(slotMek, candidate, s.encryptor); err == nil {
+			s.logger.Info("MEK unlocked via key slot", "slotId", slot.ID, "label", slot.Label)
+			return mekValue, nil
+		}
+	}
+
+	return nil, NewInvalidMekSecretError()
+}
+
+func (s *mekService) GetMekSlots() ([]*MekSlot, error) {
+	slots, err := s.repo.GetSlots()
+	if err != nil {
+		s.logger.Error("Failed to get MEK slots from repository", err)
+		return nil, fmt.Errorf("failed to get MEK slots: %w", err)
+	}
+
+	return slots, nil
+}
+
+func (s *mekService) AddPasswordSlot(currentSecret, label, newPassword string) (*MekSlot, error) {
+	s.logger.Info("Adding password slot to MEK", "label", label)
+
+	if newPassword == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+
+	return s.addSlot(currentSecret, label, MekSlotTypePassword, newPassword)
+}
+
+func (s *mekService) AddRecoveryKeySlot(currentSecret, label string) (*MekSlot, string, error) {
+	s.logger.Info("Adding recovery key slot to MEK", "label", label)
+
+	// The recovery key has the same entropy as the MEK itself
+	keyMaterial, err := s.encryptor.GenerateKey()
+	if err != nil {
+		s.logger.Error("Failed to generate recovery key", err)
+		return nil, "", err
+	}
+	recoveryKey := FormatRecoveryKey(keyMaterial)
+
+	slot, err := s.addSlot(currentSecret, label, MekSlotTypeRecoveryKey, NormalizeRecoveryKey(recoveryKey))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return slot, recoveryKey, nil
+}
+
+// addSlot unlocks the MEK with the current secret and wraps it again with the slot secret
+func (s *mekService) addSlot(currentSecret, label string, slotType MekSlotType, slotSecret string) (*MekSlot, error) {
+	mek, err := s.GetMek()
+	if err != nil {
+		return nil, err
+	}
+
+	mekValue, err := s.unlockMekValue(mek, currentSecret)
+	if err != nil {
+		s.logger.Warn("Failed to unlock MEK for adding a key slot", "error", err)
+		return nil, err
+	}
+
+	salt, err := s.encryptor.GenerateSalt()
+	if err != nil {
+		s.logger.Error("Failed to generate salt for MEK slot", err)
+		return nil, err
+	}
+
+	key, err := s.encryptor.DeriveKeyFromSecret([]byte(slotSecret), salt)
+	if err != nil {
+		s.logger.Error("Failed to derive key for MEK slot", err)
+		return nil, err
+	}
+
+	encryptedKey, err := s.encryptor.Encrypt(mekValue, key)
+	if err != nil {
+		s.logger.Error("Failed to encrypt MEK for slot", err)
+		return nil, err
+	}
+
+	if label == "" {
+		label = string(slotType)
+	}
+
+	now := time.Now().UTC()
+	slot := &MekSlot{
+		ID:                     uuid.NewString(),
+		MekID:                  mek.ID,
+		Label:                  label,
+		Type:                   slotType,
+		EncryptedEncryptionKey: base64.StdEncoding.EncodeToString(encryptedKey),
+		EncryptionKeySalt:      base64.StdEncoding.EncodeToString(salt),
+		CreatedAt:              now,
+		UpdatedAt:              now,
+	}
+
+	if err := s.repo.CreateSlot(slot); err != nil {
+		s.logger.Error("Failed to create MEK slot in repository", err)
+		return nil, fmt.Errorf("failed to create MEK slot: %w", err)
+	}
+
+	s.logger.Info("MEK slot added successfully", "slotId", slot.ID, "type", slot.Type)
+	return slot, nil
+}
+
+func (s *mekService) RemoveMekSlot(currentSecret, slotID string) error {
+	s.logger.Info("Removing MEK slot", "slotId", slotID)
+
+	if _, err := s.UnlockMek(currentSecret); err != nil {
+		s.logger.Warn("Failed to unlock MEK for removing a key slot", "error", err)
+		return err
+	}
+
+	slot, err := s.repo.GetSlotByID(slotID)
+	if err != nil {
+		s.logger.Error("Failed to get MEK slot", err)
+		return err
+	}
+	if slot == nil {
+		return NewMekSlotNotFoundError(slotID)
+	}
+
+	if err := s.repo.DeleteSlot(slotID); err != nil {
+		s.logger.Error("Failed to delete MEK slot from repository", err)
+		return fmt.Errorf("failed to delete MEK slot: %w", err)
+	}
+
+	s.logger.Info("MEK slot removed successfully", "slotId", slotID)
+	return nil
+}
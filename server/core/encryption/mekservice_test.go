@@ -0,0 +1,161 @@
+package encryption
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+func setupTestMekService(t *testing.T) (*mekService, func()) {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	repo, err := NewSQLiteMekRepository(testDB)
+	if err != nil {
+		testDB.Close()
+		t.Fatalf("NewSQLiteMekRepository() failed: %v", err)
+	}
+
+	return NewMekService(logging.NopLogger, repo, NewAESEncryptor()), func() { testDB.Close() }
+}
+
+func TestMekService_UnlockMek_PrimaryPassword(t *testing.T) {
+	service, cleanup := setupTestMekService(t)
+	defer cleanup()
+
+	mek, err := service.CreateMek("admin-password")
+	if err != nil {
+		t.Fatalf("CreateMek() failed: %v", err)
+	}
+
+	expected, err := DecryptMek(mek, "admin-password", NewAESEncryptor())
+	if err != nil {
+		t.Fatalf("DecryptMek() failed: %v", err)
+	}
+
+	unlocked, err := service.UnlockMek("admin-password")
+	if err != nil {
+		t.Fatalf("UnlockMek() failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, expected) {
+		t.Error("UnlockMek() returned a different MEK value")
+	}
+
+	_, err = service.UnlockMek("wrong-password")
+	if !IsInvalidMekSecretError(err) {
+		t.Errorf("Expected InvalidMekSecretError for wrong password, got %v", err)
+	}
+}
+
+func TestMekService_PasswordSlot(t *testing.T) {
+	service, cleanup := setupTestMekService(t)
+	defer cleanup()
+
+	if _, err := service.CreateMek("admin-password"); err != nil {
+		t.Fatalf("CreateMek() failed: %v", err)
+	}
+	expected, _ := service.UnlockMek("admin-password")
+
+	if _, err := service.AddPasswordSlot("wrong-password", "Backup", "backup-password"); !IsInvalidMekSecretError(err) {
+		t.Errorf("AddPasswordSlot() should require a valid current secret, got %v", err)
+	}
+
+	slot, err := service.AddPasswordSlot("admin-password", "Backup", "backup-password")
+	if err != nil {
+		t.Fatalf("AddPasswordSlot() failed: %v", err)
+	}
+	if slot.Type != MekSlotTypePassword {
+		t.Errorf("Expected slot type %s, got %s", MekSlotTypePassword, slot.Type)
+	}
+
+	unlocked, err := service.UnlockMek("backup-password")
+	if err != nil {
+		t.Fatalf("UnlockMek() with slot password failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, expected) {
+		t.Error("Slot password unlocked a different MEK value")
+	}
+
+	// The slot can be used to authorize removing itself, the primary password keeps working
+	if err := service.RemoveMekSlot("backup-password", slot.ID); err != nil {
+		t.Fatalf("RemoveMekSlot() failed: %v", err)
+	}
+	if _, err := service.UnlockMek("backup-password"); !IsInvalidMekSecretError(err) {
+		t.Errorf("Removed slot should no longer unlock the MEK, got %v", err)
+	}
+	if _, err := service.UnlockMek("admin-password"); err != nil {
+		t.Errorf("Primary password should still unlock the MEK: %v", err)
+	}
+
+	if err := service.RemoveMekSlot("admin-password", slot.ID); !IsMekSlotNotFoundError(err) {
+		t.Errorf("Expected MekSlotNotFoundError, got %v", err)
+	}
+}
+
+func TestMekService_RecoveryKeySlot(t *testing.T) {
+	service, cleanup := setupTestMekService(t)
+	defer cleanup()
+
+	if _, err := service.CreateMek("admin-password"); err != nil {
+		t.Fatalf("CreateMek() failed: %v", err)
+	}
+	expected, _ := service.UnlockMek("admin-password")
+
+	slot, recoveryKey, err := service.AddRecoveryKeySlot("admin-password", "Recovery key")
+	if err != nil {
+		t.Fatalf("AddRecoveryKeySlot() failed: %v", err)
+	}
+	if slot.Type != MekSlotTypeRecoveryKey {
+		t.Errorf("Expected slot type %s, got %s", MekSlotTypeRecoveryKey, slot.Type)
+	}
+	if !strings.Contains(recoveryKey, "-") {
+		t.Errorf("Recovery key should be formatted in groups, got %s", recoveryKey)
+	}
+
+	// Formatting differences must not matter
+	typed := strings.ToLower(strings.ReplaceAll(recoveryKey, "-", " "))
+	unlocked, err := service.UnlockMek(typed)
+	if err != nil {
+		t.Fatalf("UnlockMek() with recovery key failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, expected) {
+		t.Error("Recovery key unlocked a different MEK value")
+	}
+
+	// A forgotten password can be replaced using the recovery key
+	if _, err := service.ChangeMekPassword(recoveryKey, "new-password"); err != nil {
+		t.Fatalf("ChangeMekPassword() with recovery key failed: %v", err)
+	}
+	if _, err := service.UnlockMek("admin-password"); !IsInvalidMekSecretError(err) {
+		t.Errorf("Old password should no longer unlock the MEK, got %v", err)
+	}
+	unlocked, err = service.UnlockMek("new-password")
+	if err != nil {
+		t.Fatalf("UnlockMek() with new password failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, expected) {
+		t.Error("New password unlocked a different MEK value")
+	}
+}
+
+func TestFormatRecoveryKey_RoundTrip(t *testing.T) {
+	formatted := FormatRecoveryKey(bytes.Repeat([]byte{0xAB}, 32))
+
+	for _, group := range strings.Split(formatted, "-") {
+		if len(group) > recoveryKeyGroupSize {
+			t.Errorf("Group %q is longer than %d characters", group, recoveryKeyGroupSize)
+		}
+	}
+
+	if NormalizeRecoveryKey(formatted) != NormalizeRecoveryKey(strings.ToLower(formatted)) {
+		t.Error("Normalization should be case-insensitive")
+	}
+	if strings.Contains(NormalizeRecoveryKey(formatted), "-") {
+		t.Error("Normalized key should not contain separators")
+	}
+}
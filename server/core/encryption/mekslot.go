@@ -0,0 +1,27 @@
+package encryption
+
+import "time"
+
+// MekSlotType describes what kind of secret unlocks a key slot
+type MekSlotType string
+
+const (
+	// MekSlotTypePassword is a slot unlocked by an additional admin password
+	MekSlotTypePassword MekSlotType = "password"
+	// MekSlotTypeRecoveryKey is a slot unlocked by a generated high-entropy recovery key
+	MekSlotTypeRecoveryKey MekSlotType = "recovery_key"
+)
+
+// MekSlot is an additional wrap of the MEK, similar to a LUKS key slot.
+// The primary password wrap is stored on the Mek itself; every slot holds
+// the same MEK value encrypted with a key derived from a different secret.
+type MekSlot struct {
+	ID                     string      // Unique identifier for the slot
+	MekID                  string      // ID of the MEK this slot unlocks
+	Label                  string      // Human-readable label (e.g. "Backup password", "Recovery key")
+	Type                   MekSlotType // Kind of secret that unlocks this slot
+	EncryptedEncryptionKey string      // MEK encrypted with key derived from the slot secret (base 64 encoded)
+	EncryptionKeySalt      string      // Salt used for deriving the encryption key (base 64 encoded)
+	CreatedAt              time.Time   // Timestamp when the slot was created
+	UpdatedAt              time.Time   // Timestamp when the slot was last updated
+}
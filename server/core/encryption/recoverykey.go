@@ -0,0 +1,32 @@
+package encryption
+
+import (
+	"encoding/base32"
+	"strings"
+
+	"github.com/yeti47/cryospy/server/core/ccc/codes"
+)
+
+const recoveryKeyGroupSize = 4 // Characters per dash-separated group of a formatted recovery key
+
+var recoveryKeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// FormatRecoveryKey encodes raw key material as a recovery key that can be written down,
+// e.g. "ABCD-EFGH-IJKL-...". The key uses base32 to avoid ambiguous characters and case issues.
+func FormatRecoveryKey(keyMaterial []byte) string {
+	encoded := recoveryKeyEncoding.EncodeToString(keyMaterial)
+
+	var groups []string
+	for i := 0; i < len(encoded); i += recoveryKeyGroupSize {
+		end := min(i+recoveryKeyGroupSize, len(encoded))
+		groups = append(groups, encoded[i:end])
+	}
+
+	return strings.Join(groups, "-")
+}
+
+// NormalizeRecoveryKey normalizes a recovery key like any other code, so that keys typed with different formatting
+// derive the same encryption key
+func NormalizeRecoveryKey(recoveryKey string) string {
+	return codes.Normalize(recoveryKey)
+}
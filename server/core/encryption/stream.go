@@ -0,0 +1,169 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Constants for the chunked stream format
+const (
+	streamChunkSize   = 1 << 20 // Plaintext bytes per chunk
+	streamHeaderSize  = 9       // Chunk index (8 bytes) and final flag (1 byte), encrypted with the chunk
+	streamLengthSize  = 4       // Length prefix of every encrypted chunk
+	streamMaxOverhead = 1024    // Upper bound for the nonce, tag and header added to a chunk
+)
+
+// encryptingWriter encrypts a stream in chunks, so that large files never have to be held in memory.
+// Every chunk carries its index and the last one a final flag, which lets the reader detect chunks that were
+// reordered, dropped or cut off at the end.
+type encryptingWriter struct {
+	w         io.Writer
+	encryptor Encryptor
+	key       []byte
+	buf       []byte
+	index     uint64
+	closed    bool
+}
+
+// NewEncryptingWriter returns a writer that encrypts everything written to it with the key and writes it to w.
+// Close must be called to write the last chunk; it does not close w.
+func NewEncryptingWriter(w io.Writer, encryptor Encryptor, key []byte) io.WriteCloser {
+	return &encryptingWriter{
+		w:         w,
+		encryptor: encryptor,
+		key:       key,
+		buf:       make([]byte, 0, streamChunkSize),
+	}
+}
+
+func (e *encryptingWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypting writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only written once more data follows, because the last chunk has to be flagged
+		if len(e.buf) == streamChunkSize {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := min(len(p), streamChunkSize-len(e.buf))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptingWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.flush(true)
+}
+
+// flush encrypts and writes the buffered chunk
+func (e *encryptingWriter) flush(final bool) error {
+	plain := make([]byte, streamHeaderSize+len(e.buf))
+	binary.BigEndian.PutUint64(plain, e.index)
+	if final {
+		plain[8] = 1
+	}
+	copy(plain[streamHeaderSize:], e.buf)
+
+	sealed, err := e.encryptor.Encrypt(plain, e.key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt chunk: %w", err)
+	}
+
+	length := make([]byte, streamLengthSize)
+	binary.BigEndian.PutUint32(length, uint32(len(sealed)))
+	if _, err := e.w.Write(length); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+
+	e.index++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decryptingReader reads a stream written by an encryptingWriter
+type decryptingReader struct {
+	r         io.Reader
+	encryptor Encryptor
+	key       []byte
+	buf       []byte
+	index     uint64
+	final     bool
+}
+
+// NewDecryptingReader returns a reader that decrypts a stream written by NewEncryptingWriter with the same key.
+// A stream that was tampered with or cut off results in an error rather than io.EOF.
+func NewDecryptingReader(r io.Reader, encryptor Encryptor, key []byte) io.Reader {
+	return &decryptingReader{
+		r:         r,
+		encryptor: encryptor,
+		key:       key,
+	}
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.final {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// next reads and decrypts the next chunk
+func (d *decryptingReader) next() error {
+	length := make([]byte, streamLengthSize)
+	if _, err := io.ReadFull(d.r, length); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF // The final chunk is missing
+		}
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(length)
+	if size > streamChunkSize+streamMaxOverhead {
+		return errors.New("encrypted chunk too large")
+	}
+
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	plain, err := d.encryptor.Decrypt(sealed, d.key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt chunk: %w", err)
+	}
+	if len(plain) < streamHeaderSize || binary.BigEndian.Uint64(plain) != d.index {
+		return errors.New("encrypted chunk out of order")
+	}
+
+	d.index++
+	d.final = plain[8] == 1
+	d.buf = plain[streamHeaderSize:]
+	return nil
+}
@@ -0,0 +1,65 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestEncryptingWriter_RoundTrip(t *testing.T) {
+	encryptor := NewAESEncryptor()
+	key, _ := encryptor.GenerateKey()
+
+	for _, size := range []int{0, 10, streamChunkSize, streamChunkSize + 1, 3*streamChunkSize - 7} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		var encrypted bytes.Buffer
+		w := NewEncryptingWriter(&encrypted, encryptor, key)
+		// Odd write sizes, so that writes straddle the chunk boundaries
+		for rest := data; len(rest) > 0; {
+			n := min(len(rest), 100003)
+			if _, err := w.Write(rest[:n]); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			rest = rest[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if size > 0 && bytes.Contains(encrypted.Bytes(), data[:min(size, 64)]) {
+			t.Errorf("Expected the stream of %d bytes to be encrypted", size)
+		}
+
+		decrypted, err := io.ReadAll(NewDecryptingReader(&encrypted, encryptor, key))
+		if err != nil {
+			t.Fatalf("Failed to decrypt stream of %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("Expected the stream of %d bytes to round trip, got %d bytes", size, len(decrypted))
+		}
+	}
+}
+
+func TestDecryptingReader_RejectsTruncatedStream(t *testing.T) {
+	encryptor := NewAESEncryptor()
+	key, _ := encryptor.GenerateKey()
+
+	var encrypted bytes.Buffer
+	w := NewEncryptingWriter(&encrypted, encryptor, key)
+	w.Write(make([]byte, 2*streamChunkSize+5))
+	w.Close()
+
+	// Drop the final chunk; every full chunk grows by its length, header, nonce and tag
+	stream := encrypted.Bytes()
+	firstTwo := 2 * (streamLengthSize + streamHeaderSize + streamChunkSize + nonceLength + 16)
+	if _, err := io.ReadAll(NewDecryptingReader(bytes.NewReader(stream[:firstTwo]), encryptor, key)); err == nil {
+		t.Error("Expected an error for a stream without its final chunk")
+	}
+
+	otherKey, _ := encryptor.GenerateKey()
+	if _, err := io.ReadAll(NewDecryptingReader(bytes.NewReader(stream), encryptor, otherKey)); err == nil {
+		t.Error("Expected an error for a stream decrypted with the wrong key")
+	}
+}
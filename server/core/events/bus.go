@@ -0,0 +1,157 @@
+package events
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+// DefaultQueueSize is the number of events a subscriber can fall behind before events are dropped for it
+const DefaultQueueSize = 256
+
+// Handler handles an event that was delivered to a subscriber
+type Handler func(event Event)
+
+// Bus passes events from the services that publish them on to the subscribers, such as the notifiers. Publishing
+// never waits for the subscribers.
+type Bus interface {
+	// Publish queues the event for every subscriber of its type. If the queue of a subscriber is full, the event is
+	// dropped for that subscriber.
+	Publish(event Event)
+	// Subscribe calls the handler for the events of the given types, or for all events without any types. The handler
+	// runs in a goroutine of its own and gets the events one at a time, in the order in which they were published.
+	// The name identifies the subscriber in the log.
+	Subscribe(name string, handler Handler, eventTypes ...string)
+	// Close stops accepting events and waits until the subscribers have handled the events in their queues
+	Close()
+}
+
+// BusSettings holds the settings of the event bus
+type BusSettings struct {
+	QueueSize int // Number of events queued per subscriber (DefaultQueueSize if not positive)
+}
+
+type subscriber struct {
+	name       string
+	handler    Handler
+	eventTypes []string
+	queue      chan Event
+}
+
+func (s *subscriber) accepts(event Event) bool {
+	return len(s.eventTypes) == 0 || slices.Contains(s.eventTypes, event.EventType())
+}
+
+type bus struct {
+	logger      logging.Logger
+	settings    BusSettings
+	mutex       sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+	running     sync.WaitGroup
+}
+
+// NewBus creates an event bus that delivers events asynchronously, through a buffered queue per subscriber
+func NewBus(logger logging.Logger, settings BusSettings) Bus {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+	if settings.QueueSize <= 0 {
+		settings.QueueSize = DefaultQueueSize
+	}
+
+	return &bus{
+		logger:   logger,
+		settings: settings,
+	}
+}
+
+func (b *bus) Publish(event Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if b.closed {
+		b.logger.Warn("Dropping event published after the event bus was closed", "event", event.EventType())
+		return
+	}
+
+	for _, s := range b.subscribers {
+		if !s.accepts(event) {
+			continue
+		}
+		select {
+		case s.queue <- event:
+		default:
+			b.logger.Warn("Dropping event for a subscriber that is falling behind", "subscriber", s.name, "event", event.EventType(), "queueSize", b.settings.QueueSize)
+		}
+	}
+}
+
+func (b *bus) Subscribe(name string, handler Handler, eventTypes ...string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		b.logger.Warn("Ignoring subscription to a closed event bus", "subscriber", name)
+		return
+	}
+
+	s := &subscriber{
+		name:       name,
+		handler:    handler,
+		eventTypes: eventTypes,
+		queue:      make(chan Event, b.settings.QueueSize),
+	}
+	b.subscribers = append(b.subscribers, s)
+
+	b.running.Add(1)
+	go b.deliver(s)
+}
+
+func (b *bus) Close() {
+	b.mutex.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, s := range b.subscribers {
+			close(s.queue)
+		}
+	}
+	b.mutex.Unlock()
+
+	b.running.Wait()
+}
+
+// deliver hands the queued events to the handler of a subscriber until the bus is closed
+func (b *bus) deliver(s *subscriber) {
+	defer b.running.Done()
+
+	for event := range s.queue {
+		b.handle(s, event)
+	}
+}
+
+// handle calls the handler of a subscriber, so that a panicking handler only loses its current event
+func (b *bus) handle(s *subscriber, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger.Error("Event handler panicked", "panic", r, "subscriber", s.name, "event", event.EventType())
+		}
+	}()
+
+	s.handler(event)
+}
+
+type nopBus struct{}
+
+// NopBus drops all events
+var NopBus Bus = &nopBus{}
+
+// Publish does nothing
+func (n *nopBus) Publish(event Event) {}
+
+// Subscribe does nothing, the handler is never called
+func (n *nopBus) Subscribe(name string, handler Handler, eventTypes ...string) {}
+
+// Close does nothing
+func (n *nopBus) Close() {}
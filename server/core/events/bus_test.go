@@ -0,0 +1,151 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the events delivered to a subscriber
+type recorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (r *recorder) handle(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) received() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func TestBus_DeliversEventsInOrder(t *testing.T) {
+	b := NewBus(nil, BusSettings{})
+	all := &recorder{}
+	b.Subscribe("all", all.handle)
+
+	for i := 0; i < 10; i++ {
+		b.Publish(AuthFailure{ClientID: "client", FailureCount: i})
+	}
+	b.Close()
+
+	received := all.received()
+	if len(received) != 10 {
+		t.Fatalf("Expected 10 events, got %d", len(received))
+	}
+	for i, event := range received {
+		if failure, ok := event.(AuthFailure); !ok || failure.FailureCount != i {
+			t.Errorf("Expected failure %d at position %d, got %+v", i, i, event)
+		}
+	}
+}
+
+func TestBus_FiltersByEventType(t *testing.T) {
+	b := NewBus(nil, BusSettings{})
+	motion := &recorder{}
+	storage := &recorder{}
+	b.Subscribe("motion", motion.handle, TypeMotionDetected)
+	b.Subscribe("storage", storage.handle, TypeCapacityWarning, TypeCapacityReached)
+
+	b.Publish(ClipStored{ClientID: "client", ClipID: "clip-1", HasMotion: true})
+	b.Publish(MotionDetected{ClientID: "client", ClipID: "clip-1"})
+	b.Publish(CapacityWarning{ClientID: "client", UsedMegaBytes: 90, TotalMegaBytes: 100})
+	b.Publish(CapacityReached{ClientID: "client", UsedMegaBytes: 100, TotalMegaBytes: 100})
+	b.Close()
+
+	if received := motion.received(); len(received) != 1 || received[0].EventType() != TypeMotionDetected {
+		t.Errorf("Expected only the motion event, got %+v", received)
+	}
+	if received := storage.received(); len(received) != 2 {
+		t.Errorf("Expected the two capacity events, got %+v", received)
+	}
+}
+
+func TestBus_SlowSubscriberDoesNotBlockPublishing(t *testing.T) {
+	b := NewBus(nil, BusSettings{QueueSize: 2})
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	slow := &recorder{}
+	b.Subscribe("slow", func(event Event) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		slow.handle(event)
+	})
+
+	b.Publish(AuthFailure{ClientID: "client", FailureCount: 0})
+	<-started
+
+	published := make(chan struct{})
+	go func() {
+		for i := 1; i < 20; i++ {
+			b.Publish(AuthFailure{ClientID: "client", FailureCount: i})
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Expected publishing not to wait for a blocked subscriber")
+	}
+
+	// The subscriber holds one event and queues two, the rest is dropped
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for len(slow.received()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Once it caught up, the subscriber receives new events again
+	b.Publish(AuthFailure{ClientID: "client", FailureCount: 20})
+	b.Close()
+
+	received := slow.received()
+	if len(received) != 4 {
+		t.Fatalf("Expected 3 of the first events and the last one, got %d", len(received))
+	}
+	if last := received[3].(AuthFailure); last.FailureCount != 20 {
+		t.Errorf("Expected the last event to be delivered, got %+v", last)
+	}
+}
+
+func TestBus_RecoversFromPanickingHandler(t *testing.T) {
+	b := NewBus(nil, BusSettings{})
+	handled := &recorder{}
+	b.Subscribe("flaky", func(event Event) {
+		if failure := event.(AuthFailure); failure.FailureCount == 1 {
+			panic("handler failed")
+		}
+		handled.handle(event)
+	})
+
+	b.Publish(AuthFailure{ClientID: "client", FailureCount: 1})
+	b.Publish(AuthFailure{ClientID: "client", FailureCount: 2})
+	b.Close()
+
+	if received := handled.received(); len(received) != 1 {
+		t.Errorf("Expected the event after the panic to be handled, got %+v", received)
+	}
+}
+
+func TestBus_DropsEventsAfterClose(t *testing.T) {
+	b := NewBus(nil, BusSettings{})
+	all := &recorder{}
+	b.Subscribe("all", all.handle)
+	b.Close()
+
+	b.Publish(ClientDisabled{ClientID: "client", Reason: DisabledForAuthFailures})
+	b.Close()
+
+	if received := all.received(); len(received) != 0 {
+		t.Errorf("Expected no events after closing, got %+v", received)
+	}
+}
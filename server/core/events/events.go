@@ -0,0 +1,107 @@
+package events
+
+import "time"
+
+// Event is something that happened in CryoSpy and is published on the Bus
+type Event interface {
+	// EventType names the kind of event, which subscribers can filter by
+	EventType() string
+}
+
+// Types of the events. They also name the events in webhook payloads, push priorities and the configuration.
+const (
+	TypeClipStored      = "clip_stored"
+	TypeMotionDetected  = "motion_detected"
+	TypeCapacityWarning = "storage_capacity_warning"
+	TypeCapacityReached = "storage_capacity_reached"
+	TypeClipEvicted     = "clip_evicted"
+	TypeAuthFailure     = "auth_failure"
+	TypeClientDisabled  = "client_disabled"
+
+	TypeDashboardLoginLockout = "dashboard_login_lockout"
+)
+
+// ClipStored is published for every clip that was stored
+type ClipStored struct {
+	ClientID  string
+	ClipID    string
+	ClipTitle string
+	TimeStamp time.Time
+	HasMotion bool
+	SizeBytes int64 // Size of the encrypted video
+}
+
+func (ClipStored) EventType() string { return TypeClipStored }
+
+// MotionDetected is published when a clip with motion was stored
+type MotionDetected struct {
+	ClientID  string
+	ClipID    string
+	ClipTitle string
+	TimeStamp time.Time
+}
+
+func (MotionDetected) EventType() string { return TypeMotionDetected }
+
+// CapacityWarning is published when a clip is stored for a client whose storage usage is above the warning threshold
+// of the storage manager. Subscribers with a higher threshold of their own check the usage again.
+type CapacityWarning struct {
+	ClientID       string
+	UsedMegaBytes  int64
+	TotalMegaBytes int64
+}
+
+func (CapacityWarning) EventType() string { return TypeCapacityWarning }
+
+// CapacityReached is published when a new clip does not fit into the storage limit of its client, before the oldest
+// clips are evicted to make room for it
+type CapacityReached struct {
+	ClientID       string
+	UsedMegaBytes  int64
+	TotalMegaBytes int64
+}
+
+func (CapacityReached) EventType() string { return TypeCapacityReached }
+
+// ClipEvicted is published for every clip that was deleted to make room for a new one
+type ClipEvicted struct {
+	ClientID  string
+	ClipID    string
+	ClipTitle string
+	TimeStamp time.Time
+}
+
+func (ClipEvicted) EventType() string { return TypeClipEvicted }
+
+// AuthFailure is published for every failed authentication of an existing, enabled client
+type AuthFailure struct {
+	ClientID     string
+	ClientIP     string
+	FailureCount int // Failures of the client within the time window of the failure tracker, including this one
+}
+
+func (AuthFailure) EventType() string { return TypeAuthFailure }
+
+// Reasons for disabling a client
+const (
+	DisabledForAuthFailures = "auth_failures"
+)
+
+// ClientDisabled is published when the capture server disables a client by itself
+type ClientDisabled struct {
+	ClientID     string
+	Reason       string
+	FailureCount int
+}
+
+func (ClientDisabled) EventType() string { return TypeClientDisabled }
+
+// DashboardLoginLockout is published when repeated failed logins lock a dashboard account
+type DashboardLoginLockout struct {
+	Account      string
+	FailureCount int
+	SourceIPs    []string
+	LockedUntil  time.Time
+}
+
+func (DashboardLoginLockout) EventType() string { return TypeDashboardLoginLockout }
@@ -4,8 +4,25 @@ go 1.24.3
 
 require github.com/mattn/go-sqlite3 v1.14.24
 
-require golang.org/x/crypto v0.41.0
+require golang.org/x/crypto v0.42.0
 
 require github.com/google/uuid v1.6.0
 
-require github.com/xfrr/goffmpeg v1.0.0 
+require (
+	github.com/pquerna/otp v1.5.0
+	github.com/xfrr/goffmpeg v1.0.0
+)
+
+require (
+	github.com/boombuler/barcode v1.0.1-0.20190219062509-6c824513bacc
+	github.com/eclipse/paho.mqtt.golang v1.5.1
+	github.com/mochi-mqtt/server/v2 v2.7.9
+)
+
+require (
+	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/rs/xid v1.4.0 // indirect
+	golang.org/x/net v0.44.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
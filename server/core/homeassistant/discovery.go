@@ -0,0 +1,183 @@
+package homeassistant
+
+import (
+	"strings"
+
+	"github.com/yeti47/cryospy/server/core/clients"
+)
+
+// discoveryDevice groups the entities of a camera, or of the CryoSpy server, into one Home Assistant device
+type discoveryDevice struct {
+	Identifiers   []string `json:"identifiers"`
+	Name          string   `json:"name"`
+	Manufacturer  string   `json:"manufacturer"`
+	Model         string   `json:"model"`
+	SuggestedArea string   `json:"suggested_area,omitempty"`
+	ViaDevice     string   `json:"via_device,omitempty"`
+}
+
+// discoveryConfig is the retained message that makes Home Assistant create an entity
+type discoveryConfig struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	Device              discoveryDevice `json:"device"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	StateTopic          string          `json:"state_topic"`
+	CommandTopic        string          `json:"command_topic,omitempty"`
+	JSONAttributesTopic string          `json:"json_attributes_topic,omitempty"`
+	ValueTemplate       string          `json:"value_template,omitempty"`
+	DeviceClass         string          `json:"device_class,omitempty"`
+	StateClass          string          `json:"state_class,omitempty"`
+	UnitOfMeasurement   string          `json:"unit_of_measurement,omitempty"`
+	PayloadOn           string          `json:"payload_on,omitempty"`
+	PayloadOff          string          `json:"payload_off,omitempty"`
+	Options             []string        `json:"options,omitempty"`
+	EventTypes          []string        `json:"event_types,omitempty"`
+	Icon                string          `json:"icon,omitempty"`
+}
+
+// discoveryEntity is a discovery config together with the component and object ID that make up its topic
+type discoveryEntity struct {
+	component string // Home Assistant platform, e.g. "binary_sensor"
+	objectID  string
+	config    discoveryConfig
+}
+
+const (
+	serverNodeID        = "cryospy"
+	deviceManufacturer  = "CryoSpy"
+	clientDeviceModel   = "Capture Client"
+	serverDeviceModel   = "Server"
+	armModeCommandActor = "mqtt" // Recorded as the originator of arm mode changes from MQTT
+)
+
+// Payloads of the availability topic
+const (
+	AvailabilityOnline  = "online"
+	AvailabilityOffline = "offline"
+)
+
+// Payloads of the state topics
+const (
+	clientOnlinePayload    = "online"
+	clientOfflinePayload   = "offline"
+	motionOnPayload        = "ON"
+	motionOffPayload       = "OFF"
+	authFailureEventType   = "repeated_failures"
+	clientDisabledEvent    = "client_disabled"
+	storageStatusOK        = "ok"
+	storageStatusWarning   = "warning"
+	storageStatusFull      = "full"
+	storageUsedTemplate    = "{{ value_json.used_mb }}"
+	storagePercentTemplate = "{{ value_json.used_percent }}"
+	lastClipTemplate       = "{{ value_json.timestamp }}"
+)
+
+// topicSegment turns an ID into a single MQTT topic level that is also a valid Home Assistant object ID. Client IDs
+// of failed authentications are chosen by whoever connects, so they may contain anything.
+func topicSegment(id string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+func clientNodeID(clientID string) string {
+	return serverNodeID + "_" + topicSegment(clientID)
+}
+
+// clientEntities returns the entities of a camera: motion, connectivity, the last clip, storage usage and
+// authentication failures
+func (p *mqttPublisher) clientEntities(client *clients.Client) []discoveryEntity {
+	nodeID := clientNodeID(client.ID)
+	device := discoveryDevice{
+		Identifiers:   []string{nodeID},
+		Name:          client.Name(),
+		Manufacturer:  deviceManufacturer,
+		Model:         clientDeviceModel,
+		SuggestedArea: client.Location,
+		ViaDevice:     serverNodeID,
+	}
+	entity := func(component, objectID, name string, config discoveryConfig) discoveryEntity {
+		config.Name = name
+		config.UniqueID = nodeID + "_" + objectID
+		config.Device = device
+		config.AvailabilityTopic = p.availabilityTopic()
+		return discoveryEntity{component: component, objectID: objectID, config: config}
+	}
+
+	entities := []discoveryEntity{
+		entity("binary_sensor", "motion", "Motion", discoveryConfig{
+			StateTopic:          p.clientTopic(client.ID, "motion"),
+			JSONAttributesTopic: p.clientTopic(client.ID, "motion/attributes"),
+			DeviceClass:         "motion",
+		}),
+		entity("binary_sensor", "status", "Status", discoveryConfig{
+			StateTopic:  p.clientTopic(client.ID, "status"),
+			DeviceClass: "connectivity",
+			PayloadOn:   clientOnlinePayload,
+			PayloadOff:  clientOfflinePayload,
+		}),
+		entity("sensor", "last_clip", "Last clip", discoveryConfig{
+			StateTopic:          p.clientTopic(client.ID, "clip"),
+			JSONAttributesTopic: p.clientTopic(client.ID, "clip"),
+			ValueTemplate:       lastClipTemplate,
+			DeviceClass:         "timestamp",
+			Icon:                "mdi:filmstrip",
+		}),
+		entity("sensor", "storage_used", "Storage used", discoveryConfig{
+			StateTopic:          p.clientTopic(client.ID, "storage"),
+			JSONAttributesTopic: p.clientTopic(client.ID, "storage"),
+			ValueTemplate:       storageUsedTemplate,
+			DeviceClass:         "data_size",
+			StateClass:          "measurement",
+			UnitOfMeasurement:   "MB",
+		}),
+		entity("event", "auth_failure", "Authentication failures", discoveryConfig{
+			StateTopic: p.clientTopic(client.ID, "auth"),
+			EventTypes: []string{authFailureEventType, clientDisabledEvent},
+			Icon:       "mdi:shield-alert",
+		}),
+	}
+	// Clients with unlimited storage have no percentage
+	if client.StorageLimitMegabytes > 0 {
+		entities = append(entities, entity("sensor", "storage_used_percent", "Storage used percent", discoveryConfig{
+			StateTopic:        p.clientTopic(client.ID, "storage"),
+			ValueTemplate:     storagePercentTemplate,
+			StateClass:        "measurement",
+			UnitOfMeasurement: "%",
+			Icon:              "mdi:harddisk",
+		}))
+	}
+	return entities
+}
+
+// serverEntities returns the entities of the CryoSpy server itself, i.e. the arm mode. It can only be switched from
+// Home Assistant if arm commands are enabled.
+func (p *mqttPublisher) serverEntities() []discoveryEntity {
+	config := discoveryConfig{
+		Name:     "Arm mode",
+		UniqueID: serverNodeID + "_arm_mode",
+		Device: discoveryDevice{
+			Identifiers:  []string{serverNodeID},
+			Name:         "CryoSpy",
+			Manufacturer: deviceManufacturer,
+			Model:        serverDeviceModel,
+		},
+		AvailabilityTopic: p.availabilityTopic(),
+		StateTopic:        p.armModeTopic(),
+		Icon:              "mdi:shield-home",
+	}
+
+	component := "sensor"
+	if p.settings.ArmCommandsEnabled {
+		component = "select"
+		config.CommandTopic = p.armModeCommandTopic()
+		for _, mode := range clients.SupportedArmModes() {
+			config.Options = append(config.Options, string(mode))
+		}
+	}
+	return []discoveryEntity{{component: component, objectID: "arm_mode", config: config}}
+}
@@ -0,0 +1,105 @@
+package homeassistant
+
+import (
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+const mqttOperationTimeout = 10 * time.Second
+
+// MQTTConnection is the link to an MQTT broker. All messages are sent with QoS 1.
+type MQTTConnection interface {
+	// Connect starts connecting to the broker in the background. onConnect is called after every connect and
+	// reconnect, so that it can restore subscriptions and retained messages.
+	Connect(onConnect func())
+	// Publish sends a message to a topic
+	Publish(topic string, payload []byte, retained bool) error
+	// Subscribe calls the handler for every message on a topic
+	Subscribe(topic string, handler func(payload []byte)) error
+	// Disconnect closes the connection to the broker
+	Disconnect()
+}
+
+// PahoSettings holds the broker address, the credentials and the last will of a paho connection
+type PahoSettings struct {
+	BrokerURL   string
+	ClientID    string
+	Username    string
+	Password    string
+	WillTopic   string // Topic of the message the broker publishes when the connection is lost, empty for none
+	WillPayload string
+}
+
+type pahoConnection struct {
+	settings PahoSettings
+	logger   logging.Logger
+	client   mqtt.Client
+}
+
+// NewPahoConnection creates an MQTTConnection based on the Eclipse Paho client. It reconnects by itself after
+// the connection is lost.
+func NewPahoConnection(settings PahoSettings, logger logging.Logger) *pahoConnection {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &pahoConnection{
+		settings: settings,
+		logger:   logger,
+	}
+}
+
+func (c *pahoConnection) Connect(onConnect func()) {
+	options := mqtt.NewClientOptions().
+		AddBroker(c.settings.BrokerURL).
+		SetClientID(c.settings.ClientID).
+		SetUsername(c.settings.Username).
+		SetPassword(c.settings.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(mqtt.Client) {
+			c.logger.Info("Connected to MQTT broker", "broker", c.settings.BrokerURL)
+			onConnect()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			c.logger.Warn("Lost connection to MQTT broker", "broker", c.settings.BrokerURL, "error", err)
+		})
+	if c.settings.WillTopic != "" {
+		options.SetWill(c.settings.WillTopic, c.settings.WillPayload, 1, true)
+	}
+
+	c.client = mqtt.NewClient(options)
+	// With connect retries the token only completes once connected, so it is not waited for
+	c.client.Connect()
+}
+
+func (c *pahoConnection) Publish(topic string, payload []byte, retained bool) error {
+	return waitForToken(c.client.Publish(topic, 1, retained, payload), "publish to "+topic)
+}
+
+func (c *pahoConnection) Subscribe(topic string, handler func(payload []byte)) error {
+	token := c.client.Subscribe(topic, 1, func(_ mqtt.Client, message mqtt.Message) {
+		handler(message.Payload())
+	})
+	return waitForToken(token, "subscribe to "+topic)
+}
+
+func (c *pahoConnection) Disconnect() {
+	if c.client != nil {
+		c.client.Disconnect(uint(time.Second.Milliseconds()))
+	}
+}
+
+func waitForToken(token mqtt.Token, operation string) error {
+	if !token.WaitTimeout(mqttOperationTimeout) {
+		return fmt.Errorf("failed to %s: timed out", operation)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to %s: %w", operation, err)
+	}
+	return nil
+}
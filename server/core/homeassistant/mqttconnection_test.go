@@ -0,0 +1,236 @@
+package homeassistant
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/yeti47/cryospy/server/core/clients"
+)
+
+const testBrokerTimeout = 5 * time.Second
+
+// testBroker is an embedded MQTT broker for the paho connection, which records every message published on it
+type testBroker struct {
+	server   *mqtt.Server
+	listener *listeners.TCP
+
+	mutex   sync.Mutex
+	history map[string][]string // Every payload published to a topic, in order
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	server := mqtt.New(&mqtt.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("Failed to add auth hook: %v", err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatalf("Failed to add listener: %v", err)
+	}
+
+	broker := &testBroker{server: server, listener: listener, history: make(map[string][]string)}
+	err := server.Subscribe("#", 1, func(_ *mqtt.Client, _ packets.Subscription, pk packets.Packet) {
+		broker.mutex.Lock()
+		defer broker.mutex.Unlock()
+		broker.history[pk.TopicName] = append(broker.history[pk.TopicName], string(pk.Payload))
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe to the broker: %v", err)
+	}
+
+	if err := server.Serve(); err != nil {
+		t.Fatalf("Failed to start broker: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return broker
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Address()
+}
+
+// publish sends a message as another MQTT client, e.g. Home Assistant, would
+func (b *testBroker) publish(t *testing.T, topic string, payload string, retained bool) {
+	t.Helper()
+	if err := b.server.Publish(topic, []byte(payload), retained, 0); err != nil {
+		t.Fatalf("Failed to publish to %s: %v", topic, err)
+	}
+}
+
+func (b *testBroker) retainedMessage(topic string) (string, bool) {
+	messages := b.server.Topics.Messages(topic)
+	if len(messages) == 0 {
+		return "", false
+	}
+	return string(messages[0].Payload), true
+}
+
+func (b *testBroker) payloads(topic string) []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return slices.Clone(b.history[topic])
+}
+
+// subscribed reports whether a client other than the test itself subscribed to the topic
+func (b *testBroker) subscribed(topic string) bool {
+	return len(b.server.Topics.Subscribers(topic).Subscriptions) > 0
+}
+
+// connectedClients counts the connected clients, apart from the inline client of the test
+func (b *testBroker) connectedClients() int {
+	count := 0
+	for _, client := range b.server.Clients.GetAll() {
+		if !client.Net.Inline && !client.Closed() {
+			count++
+		}
+	}
+	return count
+}
+
+// dropConnections closes the connections of all clients without a DISCONNECT, as if the network failed
+func (b *testBroker) dropConnections() {
+	for _, client := range b.server.Clients.GetAll() {
+		if !client.Net.Inline {
+			client.Net.Conn.Close()
+		}
+	}
+}
+
+// waitFor polls the condition until it holds or the broker timeout passes
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testBrokerTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startPahoPublisher starts a publisher that talks to the broker through a paho connection
+func startPahoPublisher(t *testing.T, broker *testBroker, onConnect func()) *publisherTestEnv {
+	env := setupPublisher(t, true)
+	conn := NewPahoConnection(PahoSettings{
+		BrokerURL:   broker.url(),
+		ClientID:    "cryospy-test",
+		WillTopic:   AvailabilityTopic("cryospy"),
+		WillPayload: AvailabilityOffline,
+	}, nil)
+	env.publisher = NewMQTTPublisher(nil, env.publisher.settings, conn, env.clientRepo, env.armModeService, env.publisher.storageReader)
+
+	conn.Connect(func() {
+		env.publisher.onConnect()
+		onConnect()
+	})
+	t.Cleanup(conn.Disconnect)
+	return env
+}
+
+func TestPahoConnection_PublishesDiscoveryAndHandlesArmCommands(t *testing.T) {
+	broker := newTestBroker(t)
+	var connects atomic.Int32
+	env := startPahoPublisher(t, broker, func() { connects.Add(1) })
+
+	waitFor(t, "the first connect", func() bool { return connects.Load() == 1 })
+
+	if payload, _ := broker.retainedMessage("cryospy/status"); payload != AvailabilityOnline {
+		t.Errorf("Expected CryoSpy to be available, got %q", payload)
+	}
+	payload, ok := broker.retainedMessage("homeassistant/binary_sensor/cryospy_front-door/motion/config")
+	if !ok {
+		t.Fatal("Expected a retained motion discovery config")
+	}
+	var config discoveryConfig
+	if err := json.Unmarshal([]byte(payload), &config); err != nil {
+		t.Fatalf("Failed to decode discovery config: %v", err)
+	}
+	if config.StateTopic != "cryospy/front-door/motion" || config.AvailabilityTopic != "cryospy/status" || config.Device.Name != "Front Door" {
+		t.Errorf("Unexpected motion config: %+v", config)
+	}
+	payload, ok = broker.retainedMessage("homeassistant/select/cryospy/arm_mode/config")
+	if !ok {
+		t.Fatal("Expected a retained arm mode select config")
+	}
+	json.Unmarshal([]byte(payload), &config)
+	if config.CommandTopic != "cryospy/arm_mode/set" {
+		t.Errorf("Expected the arm mode command topic, got %q", config.CommandTopic)
+	}
+	if payload, _ := broker.retainedMessage("cryospy/arm_mode"); payload != string(clients.DefaultArmMode) {
+		t.Errorf("Expected the default arm mode, got %q", payload)
+	}
+
+	// Home Assistant sends a command, CryoSpy switches the arm mode and reports it back
+	waitFor(t, "the arm mode subscription", func() bool { return broker.subscribed("cryospy/arm_mode/set") })
+	broker.publish(t, "cryospy/arm_mode/set", "arm", false)
+	waitFor(t, "the new arm mode", func() bool {
+		payload, _ := broker.retainedMessage("cryospy/arm_mode")
+		return payload == string(clients.ArmModeAway)
+	})
+	if state, _ := env.armModeService.GetArmState(); state.Mode != clients.ArmModeAway {
+		t.Errorf("Expected the arm mode to be switched, got %q", state.Mode)
+	}
+}
+
+func TestPahoConnection_ReconnectsAfterConnectionLoss(t *testing.T) {
+	broker := newTestBroker(t)
+	var connects atomic.Int32
+	env := startPahoPublisher(t, broker, func() { connects.Add(1) })
+
+	waitFor(t, "the first connect", func() bool { return connects.Load() == 1 })
+	waitFor(t, "the arm mode subscription", func() bool { return broker.subscribed("cryospy/arm_mode/set") })
+
+	// The broker publishes the last will when the connection is lost, and paho reconnects by itself
+	broker.dropConnections()
+	waitFor(t, "the reconnect", func() bool { return connects.Load() == 2 })
+
+	statuses := broker.payloads("cryospy/status")
+	if !slices.Equal(statuses, []string{AvailabilityOnline, AvailabilityOffline, AvailabilityOnline}) {
+		t.Errorf("Expected the last will between the two connects, got %v", statuses)
+	}
+
+	// Subscriptions are restored after the reconnect
+	waitFor(t, "the restored subscription", func() bool { return broker.subscribed("cryospy/arm_mode/set") })
+	broker.publish(t, "cryospy/arm_mode/set", "disarm", false)
+	waitFor(t, "the new arm mode", func() bool {
+		state, _ := env.armModeService.GetArmState()
+		return state.Mode == clients.ArmModeHome
+	})
+
+	// A clean stop marks CryoSpy as unavailable without the last will
+	env.publisher.Stop()
+	waitFor(t, "the disconnect", func() bool { return broker.connectedClients() == 0 })
+	statuses = broker.payloads("cryospy/status")
+	if statuses[len(statuses)-1] != AvailabilityOffline || len(statuses) != 4 {
+		t.Errorf("Expected a single offline status after stopping, got %v", statuses)
+	}
+}
+
+func TestPahoConnection_ReceivesRetainedCommandOnSubscribe(t *testing.T) {
+	broker := newTestBroker(t)
+	// A command retained by Home Assistant before CryoSpy connects is delivered with the subscription
+	broker.publish(t, "cryospy/arm_mode/set", "disarm", true)
+
+	env := startPahoPublisher(t, broker, func() {})
+
+	waitFor(t, "the retained arm mode command", func() bool {
+		payload, _ := broker.retainedMessage("cryospy/arm_mode")
+		return payload == string(clients.ArmModeHome)
+	})
+	if state, _ := env.armModeService.GetArmState(); state.Mode != clients.ArmModeHome {
+		t.Errorf("Expected the retained command to switch the arm mode, got %q", state.Mode)
+	}
+}
@@ -0,0 +1,410 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/notifications"
+)
+
+const bytesInMegabyte = 1024 * 1024
+
+// StorageUsageReader provides the storage used by the clips of a client, e.g. the clip repository
+type StorageUsageReader interface {
+	// GetTotalStorageUsage returns the bytes used by the clips of a client
+	GetTotalStorageUsage(ctx context.Context, clientID string) (int64, error)
+}
+
+type MQTTPublisherSettings struct {
+	TopicPrefix        string
+	DiscoveryPrefix    string
+	MotionOffDelay     time.Duration // How long a motion sensor stays on after the last clip with motion
+	WarningThreshold   float64
+	FailureThreshold   int
+	ArmCommandsEnabled bool // Subscribe to the arm mode command topic
+}
+
+// MQTTPublisher publishes the events of the capture server to an MQTT broker, together with retained Home Assistant
+// discovery configs that turn each camera into a device with motion, connectivity, clip and storage sensors. It is a
+// notifier for motion, storage, authentication, clip, client status and heartbeat events.
+type MQTTPublisher interface {
+	notifications.MotionNotifier
+	notifications.StorageNotifier
+	notifications.AuthNotifier
+	notifications.ClipNotifier
+	notifications.ClientStatusNotifier
+	notifications.HeartbeatNotifier
+	// Start connects to the broker in the background. Discovery configs and states are published after every connect.
+	Start()
+	// PublishStates publishes the discovery configs, the storage usage of every client and the arm mode, and removes
+	// the discovery configs of deleted clients
+	PublishStates()
+	// Stop marks CryoSpy as unavailable and disconnects from the broker
+	Stop()
+}
+
+type mqttPublisher struct {
+	logger         logging.Logger
+	settings       MQTTPublisherSettings
+	conn           MQTTConnection
+	clientRepo     clients.ClientRepository
+	armModeService clients.ArmModeService
+	storageReader  StorageUsageReader
+
+	mutex           sync.Mutex
+	motionTimers    map[string]*time.Timer // Timers that turn the motion sensors off, per client
+	discoveryTopics map[string][]string    // Discovery topics published per client, to remove them once the client is deleted
+	statesMutex     sync.Mutex             // Keeps refreshes after reconnects and from the interval from interleaving
+}
+
+// storageState is the JSON state of the storage sensors of a client
+type storageState struct {
+	UsedMegaBytes  int64    `json:"used_mb"`
+	TotalMegaBytes int64    `json:"total_mb"` // 0 for unlimited storage
+	UsedPercent    *float64 `json:"used_percent,omitempty"`
+	Status         string   `json:"status"` // "ok", "warning" or "full"
+}
+
+// motionAttributes describes the clip that turned a motion sensor on
+type motionAttributes struct {
+	ClipID    string    `json:"clip_id"`
+	ClipTitle string    `json:"clip_title"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// clipState describes the last clip stored for a client
+type clipState struct {
+	ClipID    string    `json:"clip_id"`
+	ClipTitle string    `json:"clip_title"`
+	Timestamp time.Time `json:"timestamp"`
+	HasMotion bool      `json:"has_motion"`
+}
+
+// authFailureEvent is the payload of the authentication failure event entity, for repeated failures and for clients
+// that were disabled because of them
+type authFailureEvent struct {
+	EventType    string `json:"event_type"`
+	FailureCount int    `json:"failure_count"`
+	SourceIP     string `json:"source_ip,omitempty"`
+	Reason       string `json:"reason,omitempty"` // Why the client was disabled
+}
+
+// lockoutEvent is published when a dashboard account is locked
+type lockoutEvent struct {
+	Account      string    `json:"account"`
+	FailureCount int       `json:"failure_count"`
+	SourceIPs    []string  `json:"source_ips"`
+	LockedUntil  time.Time `json:"locked_until"`
+}
+
+func NewMQTTPublisher(logger logging.Logger, settings MQTTPublisherSettings, conn MQTTConnection, clientRepo clients.ClientRepository, armModeService clients.ArmModeService, storageReader StorageUsageReader) *mqttPublisher {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &mqttPublisher{
+		logger:          logger,
+		settings:        settings,
+		conn:            conn,
+		clientRepo:      clientRepo,
+		armModeService:  armModeService,
+		storageReader:   storageReader,
+		motionTimers:    make(map[string]*time.Timer),
+		discoveryTopics: make(map[string][]string),
+	}
+}
+
+// AvailabilityTopic returns the topic that tells Home Assistant whether CryoSpy is available, for the last will of
+// the MQTT connection
+func AvailabilityTopic(topicPrefix string) string {
+	return topicPrefix + "/status"
+}
+
+func (p *mqttPublisher) availabilityTopic() string {
+	return AvailabilityTopic(p.settings.TopicPrefix)
+}
+
+func (p *mqttPublisher) armModeTopic() string {
+	return p.settings.TopicPrefix + "/arm_mode"
+}
+
+func (p *mqttPublisher) armModeCommandTopic() string {
+	return p.settings.TopicPrefix + "/arm_mode/set"
+}
+
+func (p *mqttPublisher) clientTopic(clientID string, suffix string) string {
+	return p.settings.TopicPrefix + "/" + topicSegment(clientID) + "/" + suffix
+}
+
+func (p *mqttPublisher) discoveryTopic(entity discoveryEntity, nodeID string) string {
+	return strings.Join([]string{p.settings.DiscoveryPrefix, entity.component, nodeID, entity.objectID, "config"}, "/")
+}
+
+func (p *mqttPublisher) Start() {
+	p.conn.Connect(p.onConnect)
+}
+
+func (p *mqttPublisher) onConnect() {
+	if err := p.conn.Publish(p.availabilityTopic(), []byte(AvailabilityOnline), true); err != nil {
+		p.logger.Error("Failed to publish MQTT availability", err)
+	}
+	if p.settings.ArmCommandsEnabled {
+		if err := p.conn.Subscribe(p.armModeCommandTopic(), p.handleArmModeCommand); err != nil {
+			p.logger.Error("Failed to subscribe to MQTT arm mode commands", err)
+		}
+	}
+	p.PublishStates()
+}
+
+func (p *mqttPublisher) Stop() {
+	p.mutex.Lock()
+	for clientID, timer := range p.motionTimers {
+		timer.Stop()
+		delete(p.motionTimers, clientID)
+	}
+	p.mutex.Unlock()
+
+	if err := p.conn.Publish(p.availabilityTopic(), []byte(AvailabilityOffline), true); err != nil {
+		p.logger.Warn("Failed to publish MQTT availability", "error", err)
+	}
+	p.conn.Disconnect()
+}
+
+func (p *mqttPublisher) PublishStates() {
+	p.statesMutex.Lock()
+	defer p.statesMutex.Unlock()
+
+	p.publishServerState()
+
+	clientList, err := p.clientRepo.GetAll(context.Background())
+	if err != nil {
+		p.logger.Error("Failed to retrieve clients for MQTT", err)
+		return
+	}
+
+	current := make(map[string]bool, len(clientList))
+	for _, client := range clientList {
+		// Archived records of deleted clients no longer record
+		if client.IsArchived() {
+			continue
+		}
+		current[client.ID] = true
+		p.publishClientDiscovery(client)
+		p.publishStorageState(client)
+	}
+
+	p.mutex.Lock()
+	var removed map[string][]string
+	for clientID, topics := range p.discoveryTopics {
+		if !current[clientID] {
+			if removed == nil {
+				removed = make(map[string][]string)
+			}
+			removed[clientID] = topics
+			delete(p.discoveryTopics, clientID)
+		}
+	}
+	p.mutex.Unlock()
+
+	// An empty retained config makes Home Assistant remove the entity
+	for clientID, topics := range removed {
+		p.logger.Info("Removing Home Assistant entities of deleted client", "client_id", clientID)
+		for _, topic := range topics {
+			p.publish(topic, []byte{}, true)
+		}
+	}
+}
+
+func (p *mqttPublisher) publishServerState() {
+	for _, entity := range p.serverEntities() {
+		p.publishJSON(p.discoveryTopic(entity, serverNodeID), entity.config, true)
+	}
+	// Remove the entity of the other kind, in case arm commands were switched on or off since the last start
+	otherComponent := "select"
+	if p.settings.ArmCommandsEnabled {
+		otherComponent = "sensor"
+	}
+	p.publish(p.discoveryTopic(discoveryEntity{component: otherComponent, objectID: "arm_mode"}, serverNodeID), []byte{}, true)
+
+	state, err := p.armModeService.GetArmState()
+	if err != nil {
+		p.logger.Error("Failed to retrieve arm mode for MQTT", err)
+		return
+	}
+	p.publish(p.armModeTopic(), []byte(state.Mode), true)
+}
+
+func (p *mqttPublisher) publishClientDiscovery(client *clients.Client) {
+	nodeID := clientNodeID(client.ID)
+	var topics []string
+	for _, entity := range p.clientEntities(client) {
+		topic := p.discoveryTopic(entity, nodeID)
+		topics = append(topics, topic)
+		p.publishJSON(topic, entity.config, true)
+	}
+
+	p.mutex.Lock()
+	p.discoveryTopics[client.ID] = topics
+	p.mutex.Unlock()
+}
+
+func (p *mqttPublisher) publishStorageState(client *clients.Client) {
+	usageBytes, err := p.storageReader.GetTotalStorageUsage(context.Background(), client.ID)
+	if err != nil {
+		p.logger.Error("Failed to retrieve storage usage for MQTT", err, "client_id", client.ID)
+		return
+	}
+
+	usedMegaBytes := usageBytes / bytesInMegabyte
+	totalMegaBytes := int64(client.StorageLimitMegabytes)
+	status := storageStatusOK
+	if totalMegaBytes > 0 && usedMegaBytes >= totalMegaBytes {
+		status = storageStatusFull
+	} else if p.ShouldWarn(usedMegaBytes, totalMegaBytes) {
+		status = storageStatusWarning
+	}
+	p.publishJSON(p.clientTopic(client.ID, "storage"), newStorageState(usedMegaBytes, totalMegaBytes, status), true)
+}
+
+func newStorageState(usedMegaBytes int64, totalMegaBytes int64, status string) storageState {
+	state := storageState{UsedMegaBytes: usedMegaBytes, TotalMegaBytes: totalMegaBytes, Status: status}
+	if totalMegaBytes > 0 {
+		percent := float64(usedMegaBytes*1000/totalMegaBytes) / 10
+		state.UsedPercent = &percent
+	}
+	return state
+}
+
+// handleArmModeCommand switches the arm mode. Besides the mode names, "arm" and "disarm" are accepted for Away and Home.
+func (p *mqttPublisher) handleArmModeCommand(payload []byte) {
+	command := strings.ToLower(strings.TrimSpace(string(payload)))
+	mode := clients.ArmMode(command)
+	switch command {
+	case "arm":
+		mode = clients.ArmModeAway
+	case "disarm":
+		mode = clients.ArmModeHome
+	}
+
+	state, err := p.armModeService.SetArmMode(mode, armModeCommandActor)
+	if err != nil {
+		p.logger.Warn("Rejected MQTT arm mode command", "command", command, "error", err)
+		return
+	}
+	p.publish(p.armModeTopic(), []byte(state.Mode), true)
+}
+
+func (p *mqttPublisher) NotifyMotionDetected(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
+	attributes := motionAttributes{ClipID: clipID, ClipTitle: clipTitle, Timestamp: timestamp.UTC()}
+	if err := p.publishJSON(p.clientTopic(clientID, "motion/attributes"), attributes, true); err != nil {
+		return err
+	}
+	if err := p.publish(p.clientTopic(clientID, "motion"), []byte(motionOnPayload), true); err != nil {
+		return err
+	}
+
+	// Every clip with motion keeps the sensor on for another delay
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if timer, ok := p.motionTimers[clientID]; ok {
+		timer.Stop()
+	}
+	p.motionTimers[clientID] = time.AfterFunc(p.settings.MotionOffDelay, func() {
+		p.mutex.Lock()
+		delete(p.motionTimers, clientID)
+		p.mutex.Unlock()
+		p.publish(p.clientTopic(clientID, "motion"), []byte(motionOffPayload), true)
+	})
+	return nil
+}
+
+func (p *mqttPublisher) ShouldWarn(usedMegaBytes int64, totalMegaBytes int64) bool {
+	if totalMegaBytes == 0 {
+		return false
+	}
+	return float64(usedMegaBytes)/float64(totalMegaBytes) >= p.settings.WarningThreshold
+}
+
+func (p *mqttPublisher) NotifyCapacityWarning(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
+	return p.publishJSON(p.clientTopic(clientID, "storage"), newStorageState(usedMegaBytes, totalMegaBytes, storageStatusWarning), true)
+}
+
+func (p *mqttPublisher) NotifyCapacityReached(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
+	return p.publishJSON(p.clientTopic(clientID, "storage"), newStorageState(usedMegaBytes, totalMegaBytes, storageStatusFull), true)
+}
+
+func (p *mqttPublisher) ShouldNotify(failureCount int) bool {
+	return failureCount >= p.settings.FailureThreshold
+}
+
+func (p *mqttPublisher) NotifyRepeatedAuthFailure(clientID string, failureCount int, clientIP string) error {
+	event := authFailureEvent{EventType: authFailureEventType, FailureCount: failureCount, SourceIP: clientIP}
+	return p.publishJSON(p.clientTopic(clientID, "auth"), event, false)
+}
+
+func (p *mqttPublisher) NotifyClientDisabled(clientID string, reason string, failureCount int) error {
+	event := authFailureEvent{EventType: clientDisabledEvent, FailureCount: failureCount, Reason: reason}
+	return p.publishJSON(p.clientTopic(clientID, "auth"), event, false)
+}
+
+func (p *mqttPublisher) NotifyClipStored(clientID string, clipID string, clipTitle string, timestamp time.Time, hasMotion bool) error {
+	state := clipState{ClipID: clipID, ClipTitle: clipTitle, Timestamp: timestamp.UTC(), HasMotion: hasMotion}
+	if err := p.publishJSON(p.clientTopic(clientID, "clip"), state, true); err != nil {
+		return err
+	}
+	return p.refreshStorageState(clientID)
+}
+
+func (p *mqttPublisher) NotifyClipEvicted(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
+	return p.refreshStorageState(clientID)
+}
+
+// refreshStorageState publishes the storage usage of a client after its clips changed, instead of waiting for the
+// next refresh of all states
+func (p *mqttPublisher) refreshStorageState(clientID string) error {
+	client, err := p.clientRepo.GetByID(context.Background(), clientID)
+	if err != nil {
+		p.logger.Error("Failed to retrieve client for MQTT", err, "client_id", clientID)
+		return err
+	}
+	if client == nil {
+		return nil
+	}
+	p.publishStorageState(client)
+	return nil
+}
+
+func (p *mqttPublisher) NotifyDashboardLoginLockout(account string, failureCount int, sourceIPs []string, lockedUntil time.Time) error {
+	event := lockoutEvent{Account: account, FailureCount: failureCount, SourceIPs: sourceIPs, LockedUntil: lockedUntil.UTC()}
+	return p.publishJSON(p.settings.TopicPrefix+"/dashboard/lockout", event, false)
+}
+
+func (p *mqttPublisher) NotifyClientOffline(clientID string, lastSeen time.Time) error {
+	return p.publish(p.clientTopic(clientID, "status"), []byte(clientOfflinePayload), true)
+}
+
+func (p *mqttPublisher) NotifyClientOnline(clientID string, offlineSince time.Time) error {
+	return p.publish(p.clientTopic(clientID, "status"), []byte(clientOnlinePayload), true)
+}
+
+func (p *mqttPublisher) publishJSON(topic string, value any, retained bool) error {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		p.logger.Error("Failed to encode MQTT payload", err, "topic", topic)
+		return err
+	}
+	return p.publish(topic, payload, retained)
+}
+
+func (p *mqttPublisher) publish(topic string, payload []byte, retained bool) error {
+	if err := p.conn.Publish(topic, payload, retained); err != nil {
+		p.logger.Warn("Failed to publish MQTT message", "topic", topic, "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/clients"
+)
+
+type publishedMessage struct {
+	payload  string
+	retained bool
+}
+
+// fakeConnection stands in for the broker. It keeps the last message per topic, like retained messages on a broker.
+type fakeConnection struct {
+	mutex       sync.Mutex
+	messages    map[string]publishedMessage
+	subscribers map[string]func(payload []byte)
+}
+
+func newFakeConnection() *fakeConnection {
+	return &fakeConnection{
+		messages:    make(map[string]publishedMessage),
+		subscribers: make(map[string]func(payload []byte)),
+	}
+}
+
+func (c *fakeConnection) Connect(onConnect func()) { onConnect() }
+func (c *fakeConnection) Disconnect()              {}
+
+func (c *fakeConnection) Publish(topic string, payload []byte, retained bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.messages[topic] = publishedMessage{payload: string(payload), retained: retained}
+	return nil
+}
+
+func (c *fakeConnection) Subscribe(topic string, handler func(payload []byte)) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.subscribers[topic] = handler
+	return nil
+}
+
+func (c *fakeConnection) message(topic string) (publishedMessage, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	message, ok := c.messages[topic]
+	return message, ok
+}
+
+type stubStorageReader map[string]int64
+
+func (r stubStorageReader) GetTotalStorageUsage(ctx context.Context, clientID string) (int64, error) {
+	return r[clientID], nil
+}
+
+type publisherTestEnv struct {
+	publisher      *mqttPublisher
+	conn           *fakeConnection
+	clientRepo     clients.ClientRepository
+	armModeService clients.ArmModeService
+}
+
+func setupPublisher(t *testing.T, armCommands bool) *publisherTestEnv {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	clientRepo, err := clients.NewSQLiteClientRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create client repository: %v", err)
+	}
+	armStateRepo, err := clients.NewSQLiteArmStateRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create arm state repository: %v", err)
+	}
+	armModeService := clients.NewArmModeService(nil, armStateRepo, clientRepo)
+
+	now := time.Now().UTC()
+	for _, client := range []*clients.Client{
+		{ID: "front-door", DisplayName: "Front Door", Location: "Porch", StorageLimitMegabytes: 100, CreatedAt: now, UpdatedAt: now},
+		{ID: "garage cam", StorageLimitMegabytes: 0, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := clientRepo.Create(context.Background(), client); err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+	}
+
+	conn := newFakeConnection()
+	storage := stubStorageReader{"front-door": 85 * bytesInMegabyte, "garage cam": 10 * bytesInMegabyte}
+	publisher := NewMQTTPublisher(nil, MQTTPublisherSettings{
+		TopicPrefix:        "cryospy",
+		DiscoveryPrefix:    "homeassistant",
+		MotionOffDelay:     20 * time.Millisecond,
+		WarningThreshold:   0.8,
+		FailureThreshold:   3,
+		ArmCommandsEnabled: armCommands,
+	}, conn, clientRepo, armModeService, storage)
+
+	return &publisherTestEnv{publisher: publisher, conn: conn, clientRepo: clientRepo, armModeService: armModeService}
+}
+
+func TestMQTTPublisher_PublishesDiscoveryAndStates(t *testing.T) {
+	env := setupPublisher(t, false)
+	env.publisher.Start()
+
+	if message, _ := env.conn.message("cryospy/status"); message.payload != "online" || !message.retained {
+		t.Errorf("Expected retained availability, got %+v", message)
+	}
+
+	message, ok := env.conn.message("homeassistant/binary_sensor/cryospy_front-door/motion/config")
+	if !ok || !message.retained {
+		t.Fatalf("Expected a retained motion discovery config, got %+v", message)
+	}
+	var config discoveryConfig
+	if err := json.Unmarshal([]byte(message.payload), &config); err != nil {
+		t.Fatalf("Failed to decode discovery config: %v", err)
+	}
+	if config.StateTopic != "cryospy/front-door/motion" || config.DeviceClass != "motion" {
+		t.Errorf("Unexpected motion config: %+v", config)
+	}
+	if config.Device.Name != "Front Door" || config.Device.SuggestedArea != "Porch" {
+		t.Errorf("Expected the client details on the device, got %+v", config.Device)
+	}
+
+	// Client IDs are turned into valid topic levels
+	if _, ok := env.conn.message("homeassistant/binary_sensor/cryospy_garage_cam/motion/config"); !ok {
+		t.Error("Expected a discovery config for the client with a space in its ID")
+	}
+	if _, ok := env.conn.message("homeassistant/sensor/cryospy_garage_cam/storage_used_percent/config"); ok {
+		t.Error("Expected no percentage sensor for a client with unlimited storage")
+	}
+
+	var storage storageState
+	message, _ = env.conn.message("cryospy/front-door/storage")
+	json.Unmarshal([]byte(message.payload), &storage)
+	if storage.UsedMegaBytes != 85 || storage.TotalMegaBytes != 100 || storage.Status != storageStatusWarning || storage.UsedPercent == nil || *storage.UsedPercent != 85 {
+		t.Errorf("Unexpected storage state: %s", message.payload)
+	}
+
+	if message, _ := env.conn.message("cryospy/arm_mode"); message.payload != string(clients.DefaultArmMode) {
+		t.Errorf("Expected the default arm mode, got %q", message.payload)
+	}
+	if _, ok := env.conn.message("homeassistant/sensor/cryospy/arm_mode/config"); !ok {
+		t.Error("Expected a read-only arm mode sensor without arm commands")
+	}
+	if len(env.conn.subscribers) != 0 {
+		t.Error("Expected no subscriptions without arm commands")
+	}
+}
+
+func TestMQTTPublisher_MotionTurnsOffAfterDelay(t *testing.T) {
+	env := setupPublisher(t, false)
+	env.publisher.Start()
+
+	timestamp := time.Date(2025, time.July, 1, 20, 30, 0, 0, time.UTC)
+	if err := env.publisher.NotifyMotionDetected("front-door", "clip-1", "clip.mp4", timestamp); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if message, _ := env.conn.message("cryospy/front-door/motion"); message.payload != "ON" {
+		t.Errorf("Expected motion to be on, got %q", message.payload)
+	}
+	var attributes motionAttributes
+	message, _ := env.conn.message("cryospy/front-door/motion/attributes")
+	json.Unmarshal([]byte(message.payload), &attributes)
+	if attributes.ClipID != "clip-1" || !attributes.Timestamp.Equal(timestamp) {
+		t.Errorf("Unexpected motion attributes: %s", message.payload)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if message, _ := env.conn.message("cryospy/front-door/motion"); message.payload == "OFF" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected motion to turn off after the delay")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMQTTPublisher_ArmModeCommands(t *testing.T) {
+	env := setupPublisher(t, true)
+	env.publisher.Start()
+
+	if _, ok := env.conn.message("homeassistant/select/cryospy/arm_mode/config"); !ok {
+		t.Fatal("Expected an arm mode select with arm commands")
+	}
+	handler := env.conn.subscribers["cryospy/arm_mode/set"]
+	if handler == nil {
+		t.Fatal("Expected a subscription to the arm mode command topic")
+	}
+
+	handler([]byte("DISARM"))
+	state, _ := env.armModeService.GetArmState()
+	if state.Mode != clients.ArmModeHome || state.ChangedBy != "mqtt" {
+		t.Errorf("Expected disarm to switch to Home, got %+v", state)
+	}
+	if message, _ := env.conn.message("cryospy/arm_mode"); message.payload != "home" {
+		t.Errorf("Expected the new arm mode to be published, got %q", message.payload)
+	}
+
+	handler([]byte("vacation"))
+	if state, _ := env.armModeService.GetArmState(); state.Mode != clients.ArmModeHome {
+		t.Errorf("Expected an unknown mode to be ignored, got %s", state.Mode)
+	}
+
+	handler([]byte("away"))
+	if state, _ := env.armModeService.GetArmState(); state.Mode != clients.ArmModeAway {
+		t.Errorf("Expected the arm mode to switch to Away, got %s", state.Mode)
+	}
+}
+
+func TestMQTTPublisher_RemovesDeletedClients(t *testing.T) {
+	env := setupPublisher(t, false)
+	env.publisher.Start()
+
+	if err := env.clientRepo.Delete(context.Background(), "front-door"); err != nil {
+		t.Fatalf("Failed to delete client: %v", err)
+	}
+	env.publisher.PublishStates()
+
+	message, _ := env.conn.message("homeassistant/binary_sensor/cryospy_front-door/motion/config")
+	if message.payload != "" || !message.retained {
+		t.Errorf("Expected the discovery config of the deleted client to be cleared, got %+v", message)
+	}
+	if message, _ := env.conn.message("homeassistant/binary_sensor/cryospy_garage_cam/motion/config"); message.payload == "" {
+		t.Error("Expected the discovery config of the remaining client to be kept")
+	}
+}
+
+func TestMQTTPublisher_ClientEvents(t *testing.T) {
+	env := setupPublisher(t, false)
+
+	env.publisher.NotifyClientOffline("front-door", time.Now())
+	if message, _ := env.conn.message("cryospy/front-door/status"); message.payload != "offline" || !message.retained {
+		t.Errorf("Expected a retained offline status, got %+v", message)
+	}
+
+	if !env.publisher.ShouldNotify(3) || env.publisher.ShouldNotify(2) {
+		t.Error("Expected authentication failures to be reported from the threshold on")
+	}
+	env.publisher.NotifyRepeatedAuthFailure("front-door", 3, "192.0.2.1")
+	message, _ := env.conn.message("cryospy/front-door/auth")
+	var event authFailureEvent
+	json.Unmarshal([]byte(message.payload), &event)
+	if message.retained || event.EventType != authFailureEventType || event.SourceIP != "192.0.2.1" {
+		t.Errorf("Unexpected authentication failure event: %+v", message)
+	}
+
+	env.publisher.NotifyCapacityReached("front-door", 100, 100)
+	var storage storageState
+	message, _ = env.conn.message("cryospy/front-door/storage")
+	json.Unmarshal([]byte(message.payload), &storage)
+	if storage.Status != storageStatusFull {
+		t.Errorf("Expected the storage to be full, got %s", message.payload)
+	}
+}
+
+func TestMQTTPublisher_ClipEvents(t *testing.T) {
+	env := setupPublisher(t, false)
+
+	timestamp := time.Date(2025, time.July, 1, 20, 30, 0, 0, time.UTC)
+	env.publisher.NotifyClipStored("front-door", "clip-1", "clip.mp4", timestamp, true)
+	message, _ := env.conn.message("cryospy/front-door/clip")
+	var clip clipState
+	json.Unmarshal([]byte(message.payload), &clip)
+	if !message.retained || clip.ClipID != "clip-1" || !clip.Timestamp.Equal(timestamp) || !clip.HasMotion {
+		t.Errorf("Unexpected last clip state: %+v", message)
+	}
+	// A new clip changes the storage usage right away
+	if _, ok := env.conn.message("cryospy/front-door/storage"); !ok {
+		t.Error("Expected the storage state to be refreshed for a stored clip")
+	}
+
+	env.publisher.NotifyClipEvicted("garage cam", "clip-0", "old.mp4", timestamp)
+	if _, ok := env.conn.message("cryospy/garage_cam/storage"); !ok {
+		t.Error("Expected the storage state to be refreshed for an evicted clip")
+	}
+	// Clips of unknown clients are ignored
+	if err := env.publisher.NotifyClipEvicted("unknown", "clip-0", "old.mp4", timestamp); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	env.publisher.NotifyClientDisabled("front-door", "auth_failures", 10)
+	message, _ = env.conn.message("cryospy/front-door/auth")
+	var event authFailureEvent
+	json.Unmarshal([]byte(message.payload), &event)
+	if message.retained || event.EventType != clientDisabledEvent || event.Reason != "auth_failures" || event.FailureCount != 10 {
+		t.Errorf("Unexpected client disabled event: %+v", message)
+	}
+}
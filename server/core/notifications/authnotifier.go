@@ -2,6 +2,7 @@ package notifications
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +14,8 @@ type AuthNotifier interface {
 	NotifyRepeatedAuthFailure(clientID string, failureCount int, clientIP string) error
 	// ShouldNotify returns true if the failure count exceeds the threshold within the time window.
 	ShouldNotify(failureCount int) bool
+	// NotifyDashboardLoginLockout notifies when repeated failed logins lock a dashboard account.
+	NotifyDashboardLoginLockout(account string, failureCount int, sourceIPs []string, lockedUntil time.Time) error
 }
 
 type nopAuthNotifier struct{}
@@ -30,6 +33,11 @@ func (n *nopAuthNotifier) ShouldNotify(failureCount int) bool {
 	return false
 }
 
+// NotifyDashboardLoginLockout does nothing and returns nil.
+func (n *nopAuthNotifier) NotifyDashboardLoginLockout(account string, failureCount int, sourceIPs []string, lockedUntil time.Time) error {
+	return nil
+}
+
 type AuthNotificationSettings struct {
 	Recipient        string
 	MinInterval      time.Duration
@@ -39,15 +47,22 @@ type AuthNotificationSettings struct {
 type emailAuthNotifier struct {
 	settings          AuthNotificationSettings
 	sender            EmailSender
+	directory         ClientDirectory
 	logger            logging.Logger
 	lastNotification  map[string]time.Time
 	notificationMutex sync.Mutex
 }
 
-func NewEmailAuthNotifier(settings AuthNotificationSettings, sender EmailSender, logger logging.Logger) AuthNotifier {
+// NewEmailAuthNotifier creates an AuthNotifier that sends emails. The directory provides the names of known clients;
+// nil describes clients by their IDs.
+func NewEmailAuthNotifier(settings AuthNotificationSettings, sender EmailSender, directory ClientDirectory, logger logging.Logger) AuthNotifier {
+	if directory == nil {
+		directory = PlainClientDirectory
+	}
 	return &emailAuthNotifier{
 		settings:         settings,
 		sender:           sender,
+		directory:        directory,
 		logger:           logger,
 		lastNotification: make(map[string]time.Time),
 	}
@@ -67,7 +82,8 @@ func (n *emailAuthNotifier) NotifyRepeatedAuthFailure(clientID string, failureCo
 	}
 
 	subject := "CryoSpy repeated authentication failures detected"
-	body := fmt.Sprintf("Repeated authentication failures detected for client '%s'.\n\nFailure count: %d\nClient IP: %s\n\nThis may indicate a brute force attack or misconfigured client. Please investigate and consider updating client credentials or blocking the IP address if necessary.",
+	body := fmt.Sprintf("Repeated authentication failures detected for client '%s'.\n\nClient ID: %s\nFailure count: %d\nClient IP: %s\n\nThis may indicate a brute force attack or misconfigured client. Please investigate and consider updating client credentials or blocking the IP address if necessary.",
+		n.directory.DescribeClient(clientID).Label(),
 		clientID,
 		failureCount,
 		clientIP)
@@ -82,3 +98,32 @@ func (n *emailAuthNotifier) NotifyRepeatedAuthFailure(clientID string, failureCo
 	n.lastNotification[clientID] = time.Now()
 	return nil
 }
+
+func (n *emailAuthNotifier) NotifyDashboardLoginLockout(account string, failureCount int, sourceIPs []string, lockedUntil time.Time) error {
+	n.notificationMutex.Lock()
+	defer n.notificationMutex.Unlock()
+
+	// Dashboard accounts are rate limited separately from capture clients with the same name
+	key := "dashboard:" + account
+	if time.Since(n.lastNotification[key]) < n.settings.MinInterval {
+		n.logger.Info("Skipping dashboard lockout notification due to rate limiting.", "account", account)
+		return nil
+	}
+
+	subject := "CryoSpy dashboard login locked"
+	body := fmt.Sprintf("Repeated failed logins locked the dashboard account '%s'.\n\nFailure count: %d\nSource IPs: %s\nLocked until: %s\n\nIf these attempts were not made by you, someone may be trying to guess a dashboard password. Consider blocking the IP addresses and changing the password.",
+		account,
+		failureCount,
+		strings.Join(sourceIPs, ", "),
+		lockedUntil.Local().Format("2006-01-02 15:04:05"))
+
+	n.logger.Info("Sending dashboard lockout notification.", "account", account, "recipient", n.settings.Recipient, "failureCount", failureCount)
+	err := n.sender.SendEmail(n.settings.Recipient, subject, body)
+	if err != nil {
+		n.logger.Error("Failed to send dashboard lockout notification.", "error", err, "account", account)
+		return err
+	}
+
+	n.lastNotification[key] = time.Now()
+	return nil
+}
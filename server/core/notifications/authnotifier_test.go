@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -16,7 +17,7 @@ func TestEmailAuthNotifier_ShouldNotify(t *testing.T) {
 
 	mockSender := &mockEmailSender{}
 	logger := logging.NopLogger
-	notifier := NewEmailAuthNotifier(settings, mockSender, logger)
+	notifier := NewEmailAuthNotifier(settings, mockSender, nil, logger)
 
 	// Should not notify below threshold
 	if notifier.ShouldNotify(2) {
@@ -43,7 +44,7 @@ func TestEmailAuthNotifier_NotifyRepeatedAuthFailure(t *testing.T) {
 
 	mockSender := &mockEmailSender{}
 	logger := logging.NopLogger
-	notifier := NewEmailAuthNotifier(settings, mockSender, logger)
+	notifier := NewEmailAuthNotifier(settings, mockSender, nil, logger)
 
 	clientID := "test-client"
 	clientIP := "192.168.1.100"
@@ -80,6 +81,44 @@ func TestEmailAuthNotifier_NotifyRepeatedAuthFailure(t *testing.T) {
 	}
 }
 
+func TestEmailAuthNotifier_NotifyDashboardLoginLockout(t *testing.T) {
+	settings := AuthNotificationSettings{
+		Recipient:        "admin@example.com",
+		MinInterval:      5 * time.Minute,
+		FailureThreshold: 3,
+	}
+
+	mockSender := &mockEmailSender{}
+	notifier := NewEmailAuthNotifier(settings, mockSender, nil, logging.NopLogger)
+
+	lockedUntil := time.Now().Add(15 * time.Minute)
+	if err := notifier.NotifyDashboardLoginLockout("admin", 10, []string{"10.0.0.1", "10.0.0.2"}, lockedUntil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(mockSender.sentEmails) != 1 {
+		t.Fatalf("Expected 1 email to be sent, got %d", len(mockSender.sentEmails))
+	}
+	email := mockSender.sentEmails[0]
+	if email.subject != "CryoSpy dashboard login locked" {
+		t.Errorf("Unexpected email subject: %s", email.subject)
+	}
+	if !strings.Contains(email.body, "10.0.0.1, 10.0.0.2") {
+		t.Errorf("Expected source IPs in email body, got: %s", email.body)
+	}
+
+	// A capture client with the same name is rate limited independently
+	if err := notifier.NotifyRepeatedAuthFailure("admin", 5, "10.0.0.3"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := notifier.NotifyDashboardLoginLockout("admin", 11, []string{"10.0.0.1"}, lockedUntil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(mockSender.sentEmails) != 2 {
+		t.Errorf("Expected 2 emails (second lockout rate limited), got %d", len(mockSender.sentEmails))
+	}
+}
+
 func TestNopAuthNotifier(t *testing.T) {
 	notifier := NopAuthNotifier
 
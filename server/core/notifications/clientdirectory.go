@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClientDescription is how a client is presented to the people reading notifications
+type ClientDescription struct {
+	Name     string         // Display name of the client, or its ID if it has none
+	Location string         // Where the camera is installed (e.g. "Garage"), empty if unknown
+	Timezone *time.Location // Timezone in which times are shown, nil for UTC
+}
+
+// Label returns the name of the client, followed by its location if it has one
+func (d ClientDescription) Label() string {
+	if d.Location == "" {
+		return d.Name
+	}
+	return fmt.Sprintf("%s (%s)", d.Name, d.Location)
+}
+
+// FormatTime formats a timestamp in the timezone of the client, including the zone abbreviation
+func (d ClientDescription) FormatTime(t time.Time) string {
+	location := d.Timezone
+	if location == nil {
+		location = time.UTC
+	}
+	return t.In(location).Format("2006-01-02 15:04:05 MST")
+}
+
+// ClientDirectory looks up the descriptions of clients for notifications
+type ClientDirectory interface {
+	// DescribeClient returns the description of a client. Unknown clients are described by their ID.
+	DescribeClient(clientID string) ClientDescription
+}
+
+type plainClientDirectory struct{}
+
+// PlainClientDirectory describes every client by its ID, with times in UTC
+var PlainClientDirectory ClientDirectory = &plainClientDirectory{}
+
+// DescribeClient returns a description that only contains the client ID.
+func (d *plainClientDirectory) DescribeClient(clientID string) ClientDescription {
+	return ClientDescription{Name: clientID, Timezone: time.UTC}
+}
@@ -0,0 +1,17 @@
+package notifications
+
+import "time"
+
+// ClipNotifier reports every clip that is stored or evicted, for sinks that keep track of the recordings themselves
+type ClipNotifier interface {
+	// NotifyClipStored notifies when a clip was stored
+	NotifyClipStored(clientID string, clipID string, clipTitle string, timestamp time.Time, hasMotion bool) error
+	// NotifyClipEvicted notifies when a clip was deleted to make room for a new one
+	NotifyClipEvicted(clientID string, clipID string, clipTitle string, timestamp time.Time) error
+}
+
+// ClientStatusNotifier reports clients that the capture server disabled by itself
+type ClientStatusNotifier interface {
+	// NotifyClientDisabled notifies when a client was disabled, e.g. after repeated authentication failures
+	NotifyClientDisabled(clientID string, reason string, failureCount int) error
+}
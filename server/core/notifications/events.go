@@ -0,0 +1,43 @@
+package notifications
+
+import "github.com/yeti47/cryospy/server/core/events"
+
+// Types of the events that notifiers report. They are the types of the events on the bus.
+const (
+	EventMotionDetected        = events.TypeMotionDetected
+	EventCapacityWarning       = events.TypeCapacityWarning
+	EventCapacityReached       = events.TypeCapacityReached
+	EventAuthFailure           = events.TypeAuthFailure
+	EventDashboardLoginLockout = events.TypeDashboardLoginLockout
+	EventClipStored            = events.TypeClipStored
+	EventClipEvicted           = events.TypeClipEvicted
+	EventClientDisabled        = events.TypeClientDisabled
+)
+
+// EventTypes lists all event types that notifiers report
+var EventTypes = []string{
+	EventMotionDetected,
+	EventCapacityWarning,
+	EventCapacityReached,
+	EventAuthFailure,
+	EventDashboardLoginLockout,
+	EventClipStored,
+	EventClipEvicted,
+	EventClientDisabled,
+}
+
+// PerClipEventTypes are reported for every single clip, so sinks only receive them when they ask for them explicitly
+var PerClipEventTypes = []string{
+	EventClipStored,
+	EventClipEvicted,
+}
+
+// IsEventType reports whether the name is one of the EventTypes
+func IsEventType(name string) bool {
+	for _, eventType := range EventTypes {
+		if eventType == name {
+			return true
+		}
+	}
+	return false
+}
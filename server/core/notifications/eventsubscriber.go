@@ -0,0 +1,82 @@
+package notifications
+
+import (
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/events"
+)
+
+// Notifiers are the notifiers of one sink, such as email or webhooks. Nil and nop notifiers are left out.
+type Notifiers struct {
+	Motion  MotionNotifier
+	Storage StorageNotifier
+	Auth    AuthNotifier
+	Clips   ClipNotifier
+	Clients ClientStatusNotifier
+}
+
+// SubscribeNotifiers passes the events on the bus on to the notifiers of a sink, through a queue of their own, so that
+// a slow notifier neither delays uploads nor other notifiers. Capacity warnings and authentication failures only go
+// out if the notifier's own threshold is reached.
+func SubscribeNotifiers(bus events.Bus, name string, logger logging.Logger, notifiers Notifiers) {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+	if notifiers.Motion == NopMotionNotifier {
+		notifiers.Motion = nil
+	}
+	if notifiers.Storage == NopStorageNotifier {
+		notifiers.Storage = nil
+	}
+	if notifiers.Auth == NopAuthNotifier {
+		notifiers.Auth = nil
+	}
+
+	var eventTypes []string
+	if notifiers.Motion != nil {
+		eventTypes = append(eventTypes, events.TypeMotionDetected)
+	}
+	if notifiers.Storage != nil {
+		eventTypes = append(eventTypes, events.TypeCapacityWarning, events.TypeCapacityReached)
+	}
+	if notifiers.Auth != nil {
+		eventTypes = append(eventTypes, events.TypeAuthFailure, events.TypeDashboardLoginLockout)
+	}
+	if notifiers.Clips != nil {
+		eventTypes = append(eventTypes, events.TypeClipStored, events.TypeClipEvicted)
+	}
+	if notifiers.Clients != nil {
+		eventTypes = append(eventTypes, events.TypeClientDisabled)
+	}
+	if len(eventTypes) == 0 {
+		return
+	}
+
+	bus.Subscribe(name, func(event events.Event) {
+		var err error
+		switch e := event.(type) {
+		case events.MotionDetected:
+			err = notifiers.Motion.NotifyMotionDetected(e.ClientID, e.ClipID, e.ClipTitle, e.TimeStamp)
+		case events.CapacityWarning:
+			if notifiers.Storage.ShouldWarn(e.UsedMegaBytes, e.TotalMegaBytes) {
+				err = notifiers.Storage.NotifyCapacityWarning(e.ClientID, e.UsedMegaBytes, e.TotalMegaBytes)
+			}
+		case events.CapacityReached:
+			err = notifiers.Storage.NotifyCapacityReached(e.ClientID, e.UsedMegaBytes, e.TotalMegaBytes)
+		case events.AuthFailure:
+			if notifiers.Auth.ShouldNotify(e.FailureCount) {
+				err = notifiers.Auth.NotifyRepeatedAuthFailure(e.ClientID, e.FailureCount, e.ClientIP)
+			}
+		case events.DashboardLoginLockout:
+			err = notifiers.Auth.NotifyDashboardLoginLockout(e.Account, e.FailureCount, e.SourceIPs, e.LockedUntil)
+		case events.ClipStored:
+			err = notifiers.Clips.NotifyClipStored(e.ClientID, e.ClipID, e.ClipTitle, e.TimeStamp, e.HasMotion)
+		case events.ClipEvicted:
+			err = notifiers.Clips.NotifyClipEvicted(e.ClientID, e.ClipID, e.ClipTitle, e.TimeStamp)
+		case events.ClientDisabled:
+			err = notifiers.Clients.NotifyClientDisabled(e.ClientID, e.Reason, e.FailureCount)
+		}
+		if err != nil {
+			logger.Warn("Failed to send notification", "notifier", name, "event", event.EventType(), "error", err)
+		}
+	}, eventTypes...)
+}
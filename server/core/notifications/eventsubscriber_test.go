@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/events"
+)
+
+func TestSubscribeNotifiers_PassesEventsOn(t *testing.T) {
+	bus := events.NewBus(nil, events.BusSettings{})
+	sender := &mockEmailSender{}
+	SubscribeNotifiers(bus, "email", logging.NopLogger, Notifiers{
+		Motion:  NewEmailMotionNotifier(MotionNotificationSettings{Recipient: "admin@example.com"}, sender, nil, logging.NopLogger),
+		Storage: NewEmailStorageNotifier(StorageNotificationSettings{Recipient: "admin@example.com", WarningThreshold: 0.9}, sender, nil, logging.NopLogger),
+		Auth:    NewEmailAuthNotifier(AuthNotificationSettings{Recipient: "admin@example.com", FailureThreshold: 3}, sender, nil, logging.NopLogger),
+	})
+
+	bus.Publish(events.ClipStored{ClientID: "cam-1", ClipID: "clip-1", HasMotion: true})
+	bus.Publish(events.MotionDetected{ClientID: "cam-1", ClipID: "clip-1", ClipTitle: "clip.mp4", TimeStamp: time.Now()})
+	// Below the threshold of the notifier
+	bus.Publish(events.CapacityWarning{ClientID: "cam-1", UsedMegaBytes: 80, TotalMegaBytes: 100})
+	bus.Publish(events.AuthFailure{ClientID: "cam-1", ClientIP: "192.0.2.1", FailureCount: 2})
+	bus.Close()
+
+	if len(sender.sentEmails) != 1 {
+		t.Fatalf("Expected only the motion email, got %d emails", len(sender.sentEmails))
+	}
+}
+
+func TestSubscribeNotifiers_ChecksThresholds(t *testing.T) {
+	bus := events.NewBus(nil, events.BusSettings{})
+	sender := &mockEmailSender{}
+	SubscribeNotifiers(bus, "email", logging.NopLogger, Notifiers{
+		Storage: NewEmailStorageNotifier(StorageNotificationSettings{Recipient: "admin@example.com", WarningThreshold: 0.9}, sender, nil, logging.NopLogger),
+		Auth:    NewEmailAuthNotifier(AuthNotificationSettings{Recipient: "admin@example.com", FailureThreshold: 3}, sender, nil, logging.NopLogger),
+	})
+
+	bus.Publish(events.MotionDetected{ClientID: "cam-1", ClipID: "clip-1"})
+	bus.Publish(events.CapacityWarning{ClientID: "cam-1", UsedMegaBytes: 95, TotalMegaBytes: 100})
+	bus.Publish(events.AuthFailure{ClientID: "cam-2", ClientIP: "192.0.2.1", FailureCount: 3})
+	bus.Close()
+
+	if len(sender.sentEmails) != 2 {
+		t.Fatalf("Expected a capacity warning and an authentication failure email, got %d emails", len(sender.sentEmails))
+	}
+}
+
+func TestSubscribeNotifiers_PassesClipClientAndLockoutEvents(t *testing.T) {
+	recorder := &webhookRecorder{}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{Events: EventTypes})
+	bus := events.NewBus(nil, events.BusSettings{})
+	SubscribeNotifiers(bus, "webhook", logging.NopLogger, Notifiers{Auth: notifier, Clips: notifier, Clients: notifier})
+
+	bus.Publish(events.ClipStored{ClientID: "cam-1", ClipID: "clip-2", HasMotion: true})
+	bus.Publish(events.ClipEvicted{ClientID: "cam-1", ClipID: "clip-1"})
+	bus.Publish(events.ClientDisabled{ClientID: "cam-2", Reason: events.DisabledForAuthFailures, FailureCount: 10})
+	bus.Publish(events.DashboardLoginLockout{Account: "admin", FailureCount: 5, LockedUntil: time.Now().Add(time.Hour)})
+	// Not subscribed without a motion notifier
+	bus.Publish(events.MotionDetected{ClientID: "cam-1", ClipID: "clip-2"})
+	bus.Close()
+	notifier.deliveries.Wait()
+
+	var received []string
+	for _, request := range recorder.requests {
+		received = append(received, request.header.Get(webhookEventHeader))
+	}
+	slices.Sort(received)
+	expected := []string{EventClientDisabled, EventClipEvicted, EventClipStored, EventDashboardLoginLockout}
+	if !slices.Equal(received, expected) {
+		t.Errorf("Expected events %v, got %v", expected, received)
+	}
+}
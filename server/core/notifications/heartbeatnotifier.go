@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+type HeartbeatNotifier interface {
+	// NotifyClientOffline notifies when a client has stopped sending heartbeats.
+	NotifyClientOffline(clientID string, lastSeen time.Time) error
+	// NotifyClientOnline notifies when a client that was reported offline sends heartbeats again.
+	NotifyClientOnline(clientID string, offlineSince time.Time) error
+}
+
+type nopHeartbeatNotifier struct{}
+
+var NopHeartbeatNotifier HeartbeatNotifier = &nopHeartbeatNotifier{}
+
+// NotifyClientOffline does nothing and returns nil.
+func (n *nopHeartbeatNotifier) NotifyClientOffline(clientID string, lastSeen time.Time) error {
+	return nil
+}
+
+// NotifyClientOnline does nothing and returns nil.
+func (n *nopHeartbeatNotifier) NotifyClientOnline(clientID string, offlineSince time.Time) error {
+	return nil
+}
+
+type HeartbeatNotificationSettings struct {
+	Recipient string
+}
+
+// emailHeartbeatNotifier sends one email when a client goes offline and one when it is back.
+// It needs no rate limiting, since the heartbeat service reports every outage only once.
+type emailHeartbeatNotifier struct {
+	settings  HeartbeatNotificationSettings
+	sender    EmailSender
+	directory ClientDirectory
+	logger    logging.Logger
+}
+
+// NewEmailHeartbeatNotifier creates a HeartbeatNotifier that sends emails. The directory provides the client names and
+// timezones shown in them; nil describes clients by their IDs.
+func NewEmailHeartbeatNotifier(settings HeartbeatNotificationSettings, sender EmailSender, directory ClientDirectory, logger logging.Logger) HeartbeatNotifier {
+	if directory == nil {
+		directory = PlainClientDirectory
+	}
+	return &emailHeartbeatNotifier{
+		settings:  settings,
+		sender:    sender,
+		directory: directory,
+		logger:    logger,
+	}
+}
+
+func (n *emailHeartbeatNotifier) NotifyClientOffline(clientID string, lastSeen time.Time) error {
+	client := n.directory.DescribeClient(clientID)
+	subject := fmt.Sprintf("CryoSpy client offline: %s", client.Name)
+	body := fmt.Sprintf("Client '%s' has stopped sending heartbeats.\n\nLast heartbeat: %s\n\nThe device may have crashed, lost its camera or its network connection. No clips will be recorded until it is back.",
+		client.Label(),
+		client.FormatTime(lastSeen))
+
+	n.logger.Info("Sending client offline notification.", "client", clientID, "recipient", n.settings.Recipient)
+	if err := n.sender.SendEmail(n.settings.Recipient, subject, body); err != nil {
+		n.logger.Error("Failed to send client offline notification.", "error", err, "client", clientID)
+		return err
+	}
+	return nil
+}
+
+func (n *emailHeartbeatNotifier) NotifyClientOnline(clientID string, offlineSince time.Time) error {
+	client := n.directory.DescribeClient(clientID)
+	subject := fmt.Sprintf("CryoSpy client back online: %s", client.Name)
+	body := fmt.Sprintf("Client '%s' is sending heartbeats again.\n\nReported offline at: %s",
+		client.Label(),
+		client.FormatTime(offlineSince))
+
+	n.logger.Info("Sending client online notification.", "client", clientID, "recipient", n.settings.Recipient)
+	if err := n.sender.SendEmail(n.settings.Recipient, subject, body); err != nil {
+		n.logger.Error("Failed to send client online notification.", "error", err, "client", clientID)
+		return err
+	}
+	return nil
+}
@@ -10,7 +10,7 @@ import (
 
 type MotionNotifier interface {
 	// NotifyMotionDetected sends a notification when motion is detected.
-	NotifyMotionDetected(clientID string, clipTitle string, timestamp time.Time) error
+	NotifyMotionDetected(clientID string, clipID string, clipTitle string, timestamp time.Time) error
 }
 
 type nopMotionNotifier struct{}
@@ -18,7 +18,7 @@ type nopMotionNotifier struct{}
 var NopMotionNotifier MotionNotifier = &nopMotionNotifier{}
 
 // NotifyMotionDetected does nothing and returns nil.
-func (n *nopMotionNotifier) NotifyMotionDetected(clientID string, clipTitle string, timestamp time.Time) error {
+func (n *nopMotionNotifier) NotifyMotionDetected(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
 	// No operation performed
 	return nil
 }
@@ -31,21 +31,28 @@ type MotionNotificationSettings struct {
 type emailMotionNotifier struct {
 	settings          MotionNotificationSettings
 	sender            EmailSender
+	directory         ClientDirectory
 	logger            logging.Logger
 	lastNotification  map[string]time.Time
 	notificationMutex sync.Mutex
 }
 
-func NewEmailMotionNotifier(settings MotionNotificationSettings, sender EmailSender, logger logging.Logger) MotionNotifier {
+// NewEmailMotionNotifier creates a MotionNotifier that sends emails. The directory provides the client names and
+// timezones shown in them; nil describes clients by their IDs.
+func NewEmailMotionNotifier(settings MotionNotificationSettings, sender EmailSender, directory ClientDirectory, logger logging.Logger) MotionNotifier {
+	if directory == nil {
+		directory = PlainClientDirectory
+	}
 	return &emailMotionNotifier{
 		settings:         settings,
 		sender:           sender,
+		directory:        directory,
 		logger:           logger,
 		lastNotification: make(map[string]time.Time),
 	}
 }
 
-func (n *emailMotionNotifier) NotifyMotionDetected(clientID string, clipTitle string, timestamp time.Time) error {
+func (n *emailMotionNotifier) NotifyMotionDetected(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
 	n.notificationMutex.Lock()
 	defer n.notificationMutex.Unlock()
 
@@ -54,10 +61,11 @@ func (n *emailMotionNotifier) NotifyMotionDetected(clientID string, clipTitle st
 		return nil
 	}
 
-	subject := "CryoSpy motion detected"
+	client := n.directory.DescribeClient(clientID)
+	subject := fmt.Sprintf("CryoSpy motion detected: %s", client.Name)
 	body := fmt.Sprintf("Motion was detected by client '%s' at %s.\n\nClip: %s\n\nPlease check the dashboard for more details.",
-		clientID,
-		timestamp.Format("2006-01-02 15:04:05 UTC"),
+		client.Label(),
+		client.FormatTime(timestamp),
 		clipTitle)
 
 	n.logger.Info("Sending motion detection notification.", "client", clientID, "recipient", n.settings.Recipient)
@@ -70,3 +78,36 @@ func (n *emailMotionNotifier) NotifyMotionDetected(clientID string, clipTitle st
 	n.lastNotification[clientID] = time.Now()
 	return nil
 }
+
+// MotionNotificationPolicy decides whether motion notifications are sent for a client, e.g. depending on the arm mode
+type MotionNotificationPolicy interface {
+	// MotionNotificationsEnabled reports whether motion notifications are currently sent for the client
+	MotionNotificationsEnabled(clientID string) bool
+}
+
+type policyMotionNotifier struct {
+	notifier MotionNotifier
+	policy   MotionNotificationPolicy
+	logger   logging.Logger
+}
+
+// NewPolicyMotionNotifier wraps a MotionNotifier so that it only notifies while the policy allows it
+func NewPolicyMotionNotifier(notifier MotionNotifier, policy MotionNotificationPolicy, logger logging.Logger) MotionNotifier {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &policyMotionNotifier{
+		notifier: notifier,
+		policy:   policy,
+		logger:   logger,
+	}
+}
+
+func (n *policyMotionNotifier) NotifyMotionDetected(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
+	if !n.policy.MotionNotificationsEnabled(clientID) {
+		n.logger.Info("Skipping motion notification for the active arm mode.", "client", clientID)
+		return nil
+	}
+	return n.notifier.NotifyMotionDetected(clientID, clipID, clipTitle, timestamp)
+}
@@ -0,0 +1,56 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+type stubClientDirectory map[string]ClientDescription
+
+func (d stubClientDirectory) DescribeClient(clientID string) ClientDescription {
+	if description, ok := d[clientID]; ok {
+		return description
+	}
+	return PlainClientDirectory.DescribeClient(clientID)
+}
+
+func TestEmailMotionNotifier_UsesClientDescription(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+	directory := stubClientDirectory{
+		"cam-1": {Name: "Front Door", Location: "Garage", Timezone: berlin},
+	}
+
+	mockSender := &mockEmailSender{}
+	notifier := NewEmailMotionNotifier(MotionNotificationSettings{Recipient: "admin@example.com"}, mockSender, directory, logging.NopLogger)
+
+	timestamp := time.Date(2025, time.July, 1, 20, 30, 0, 0, time.UTC)
+	if err := notifier.NotifyMotionDetected("cam-1", "clip-1", "clip.mp4", timestamp); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := notifier.NotifyMotionDetected("cam-2", "clip-1", "clip.mp4", timestamp); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(mockSender.sentEmails) != 2 {
+		t.Fatalf("Expected 2 emails to be sent, got %d", len(mockSender.sentEmails))
+	}
+
+	described := mockSender.sentEmails[0]
+	if described.subject != "CryoSpy motion detected: Front Door" {
+		t.Errorf("Unexpected email subject: %s", described.subject)
+	}
+	if !strings.Contains(described.body, "'Front Door (Garage)' at 2025-07-01 22:30:00 CEST") {
+		t.Errorf("Expected the display name and local time in the email body, got: %s", described.body)
+	}
+
+	plain := mockSender.sentEmails[1]
+	if !strings.Contains(plain.body, "'cam-2' at 2025-07-01 20:30:00 UTC") {
+		t.Errorf("Expected the client ID and UTC time in the email body, got: %s", plain.body)
+	}
+}
@@ -0,0 +1,44 @@
+package notifications
+
+import (
+	"errors"
+	"time"
+)
+
+type multiHeartbeatNotifier struct {
+	notifiers []HeartbeatNotifier
+}
+
+// NewMultiHeartbeatNotifier creates a HeartbeatNotifier that passes every notification on to all given notifiers.
+// Nil notifiers are skipped; without any notifier NopHeartbeatNotifier is returned.
+func NewMultiHeartbeatNotifier(notifiers ...HeartbeatNotifier) HeartbeatNotifier {
+	var active []HeartbeatNotifier
+	for _, notifier := range notifiers {
+		if notifier != nil {
+			active = append(active, notifier)
+		}
+	}
+	switch len(active) {
+	case 0:
+		return NopHeartbeatNotifier
+	case 1:
+		return active[0]
+	}
+	return &multiHeartbeatNotifier{notifiers: active}
+}
+
+func (n *multiHeartbeatNotifier) NotifyClientOffline(clientID string, lastSeen time.Time) error {
+	var errs []error
+	for _, notifier := range n.notifiers {
+		errs = append(errs, notifier.NotifyClientOffline(clientID, lastSeen))
+	}
+	return errors.Join(errs...)
+}
+
+func (n *multiHeartbeatNotifier) NotifyClientOnline(clientID string, offlineSince time.Time) error {
+	var errs []error
+	for _, notifier := range n.notifiers {
+		errs = append(errs, notifier.NotifyClientOnline(clientID, offlineSince))
+	}
+	return errors.Join(errs...)
+}
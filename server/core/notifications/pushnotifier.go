@@ -0,0 +1,149 @@
+package notifications
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+type PushNotificationSettings struct {
+	MinInterval      time.Duration
+	Priorities       map[string]int // Priority per event type; missing event types use the default of the server
+	DashboardURL     string         // Public address of the dashboard that notifications link to, empty for no links
+	WarningThreshold float64
+	FailureThreshold int
+}
+
+// PushNotifier sends notifications through a push service such as ntfy or Gotify. It is a MotionNotifier,
+// StorageNotifier and AuthNotifier.
+type PushNotifier interface {
+	MotionNotifier
+	StorageNotifier
+	AuthNotifier
+}
+
+type pushNotifier struct {
+	settings          PushNotificationSettings
+	sender            PushSender
+	directory         ClientDirectory
+	logger            logging.Logger
+	lastNotification  map[string]time.Time
+	notificationMutex sync.Mutex
+}
+
+// NewPushNotifier creates a PushNotifier that publishes through the sender. Events of the same type for the same
+// client are rate limited to one per MinInterval, like email notifications. The directory provides the client names
+// and timezones shown in the notifications; nil describes clients by their IDs.
+func NewPushNotifier(settings PushNotificationSettings, sender PushSender, directory ClientDirectory, logger logging.Logger) PushNotifier {
+	if directory == nil {
+		directory = PlainClientDirectory
+	}
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+	return &pushNotifier{
+		settings:         settings,
+		sender:           sender,
+		directory:        directory,
+		logger:           logger,
+		lastNotification: make(map[string]time.Time),
+	}
+}
+
+func (n *pushNotifier) NotifyMotionDetected(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
+	client := n.directory.DescribeClient(clientID)
+	return n.send(EventMotionDetected, clientID, PushMessage{
+		Title:    fmt.Sprintf("Motion detected: %s", client.Name),
+		Message:  fmt.Sprintf("Motion was detected by client '%s' at %s.\nClip: %s", client.Label(), client.FormatTime(timestamp), clipTitle),
+		ClickURL: n.dashboardLink("clips", clipID),
+		Tags:     []string{"rotating_light"},
+	})
+}
+
+func (n *pushNotifier) ShouldWarn(usedMegaBytes int64, totalMegaBytes int64) bool {
+	if totalMegaBytes == 0 {
+		return false
+	}
+	return float64(usedMegaBytes)/float64(totalMegaBytes) >= n.settings.WarningThreshold
+}
+
+func (n *pushNotifier) NotifyCapacityWarning(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
+	client := n.directory.DescribeClient(clientID)
+	return n.send(EventCapacityWarning, clientID, PushMessage{
+		Title:    fmt.Sprintf("Storage capacity warning: %s", client.Name),
+		Message:  fmt.Sprintf("Storage for client '%s' is nearing its limit: %d of %d MB used.", client.Label(), usedMegaBytes, totalMegaBytes),
+		ClickURL: n.dashboardLink("clients"),
+		Tags:     []string{"warning"},
+	})
+}
+
+func (n *pushNotifier) NotifyCapacityReached(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
+	client := n.directory.DescribeClient(clientID)
+	return n.send(EventCapacityReached, clientID, PushMessage{
+		Title:    fmt.Sprintf("Storage capacity reached: %s", client.Name),
+		Message:  fmt.Sprintf("Storage for client '%s' is full (%d of %d MB). Old footage is being overwritten.", client.Label(), usedMegaBytes, totalMegaBytes),
+		ClickURL: n.dashboardLink("clients"),
+		Tags:     []string{"warning"},
+	})
+}
+
+func (n *pushNotifier) ShouldNotify(failureCount int) bool {
+	return failureCount >= n.settings.FailureThreshold
+}
+
+func (n *pushNotifier) NotifyRepeatedAuthFailure(clientID string, failureCount int, clientIP string) error {
+	return n.send(EventAuthFailure, clientID, PushMessage{
+		Title:    "Repeated authentication failures",
+		Message:  fmt.Sprintf("%d failed authentications for client '%s' from %s.", failureCount, n.directory.DescribeClient(clientID).Label(), clientIP),
+		ClickURL: n.dashboardLink("clients"),
+		Tags:     []string{"lock"},
+	})
+}
+
+func (n *pushNotifier) NotifyDashboardLoginLockout(account string, failureCount int, sourceIPs []string, lockedUntil time.Time) error {
+	// Dashboard accounts are rate limited separately from capture clients with the same name
+	return n.send(EventDashboardLoginLockout, "dashboard:"+account, PushMessage{
+		Title:   "Dashboard login locked",
+		Message: fmt.Sprintf("%d failed logins locked the dashboard account '%s' until %s.\nSource IPs: %s", failureCount, account, lockedUntil.Local().Format("2006-01-02 15:04:05"), strings.Join(sourceIPs, ", ")),
+		Tags:    []string{"lock"},
+	})
+}
+
+// send publishes a message with the priority of its event type, unless the same event was recently sent for the key
+func (n *pushNotifier) send(event string, key string, message PushMessage) error {
+	n.notificationMutex.Lock()
+	defer n.notificationMutex.Unlock()
+
+	rateLimitKey := event + ":" + key
+	if time.Since(n.lastNotification[rateLimitKey]) < n.settings.MinInterval {
+		n.logger.Info("Skipping push notification due to rate limiting.", "event", event, "key", key)
+		return nil
+	}
+
+	message.Priority = n.settings.Priorities[event]
+	n.logger.Info("Sending push notification.", "event", event, "key", key)
+	if err := n.sender.SendPush(message); err != nil {
+		n.logger.Error("Failed to send push notification.", "error", err, "event", event, "key", key)
+		return err
+	}
+
+	n.lastNotification[rateLimitKey] = time.Now()
+	return nil
+}
+
+// dashboardLink returns the URL of a dashboard page, or an empty string if no dashboard URL is configured
+func (n *pushNotifier) dashboardLink(pathSegments ...string) string {
+	if n.settings.DashboardURL == "" {
+		return ""
+	}
+	link, err := url.JoinPath(n.settings.DashboardURL, pathSegments...)
+	if err != nil {
+		n.logger.Warn("Invalid dashboard URL for push notifications", "url", n.settings.DashboardURL, "error", err)
+		return ""
+	}
+	return link
+}
@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+type pushRequest struct {
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+// newPushServer starts a mock push server that records the requests it receives and answers with the status
+func newPushServer(t *testing.T, status int) (*httptest.Server, *[]pushRequest) {
+	requests := &[]pushRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("Expected a JSON body, got %q", raw)
+		}
+		*requests = append(*requests, pushRequest{path: r.URL.Path, header: r.Header.Clone(), body: body})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestPushNotifier_Ntfy(t *testing.T) {
+	server, requests := newPushServer(t, http.StatusOK)
+	directory := stubClientDirectory{"cam-1": {Name: "Front Door", Timezone: time.UTC}}
+	notifier := NewPushNotifier(PushNotificationSettings{
+		Priorities:   map[string]int{EventMotionDetected: 5},
+		DashboardURL: "https://cryospy.example.com/",
+	}, NewNtfySender(server.URL+"/", "cameras", "tk_secret"), directory, logging.NopLogger)
+
+	timestamp := time.Date(2025, time.July, 1, 20, 30, 0, 0, time.UTC)
+	if err := notifier.NotifyMotionDetected("cam-1", "clip-1", "clip.mp4", timestamp); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := notifier.NotifyCapacityReached("cam-1", 100, 100); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	motion := (*requests)[0]
+	if motion.path != "/" || motion.header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("Unexpected request: path %s, authorization %q", motion.path, motion.header.Get("Authorization"))
+	}
+	if motion.body["topic"] != "cameras" || motion.body["title"] != "Motion detected: Front Door" {
+		t.Errorf("Unexpected topic or title: %v", motion.body)
+	}
+	if motion.body["priority"] != float64(5) {
+		t.Errorf("Expected the configured priority, got %v", motion.body["priority"])
+	}
+	if motion.body["click"] != "https://cryospy.example.com/clips/clip-1" {
+		t.Errorf("Expected a link to the clip, got %v", motion.body["click"])
+	}
+
+	if _, hasPriority := (*requests)[1].body["priority"]; hasPriority {
+		t.Error("Expected no priority for an event type without one, so that the server default applies")
+	}
+}
+
+func TestPushNotifier_Gotify(t *testing.T) {
+	server, requests := newPushServer(t, http.StatusOK)
+	notifier := NewPushNotifier(PushNotificationSettings{
+		Priorities:   map[string]int{EventAuthFailure: 8},
+		DashboardURL: "https://cryospy.example.com",
+	}, NewGotifySender(server.URL, "app-token"), nil, logging.NopLogger)
+
+	if err := notifier.NotifyRepeatedAuthFailure("cam-1", 5, "192.0.2.1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(*requests))
+	}
+	request := (*requests)[0]
+	if request.path != "/message" || request.header.Get("X-Gotify-Key") != "app-token" {
+		t.Errorf("Unexpected request: path %s, key %q", request.path, request.header.Get("X-Gotify-Key"))
+	}
+	if request.body["priority"] != float64(8) {
+		t.Errorf("Expected the configured priority, got %v", request.body["priority"])
+	}
+	extras, _ := request.body["extras"].(map[string]any)
+	notification, _ := extras["client::notification"].(map[string]any)
+	click, _ := notification["click"].(map[string]any)
+	if click["url"] != "https://cryospy.example.com/clients" {
+		t.Errorf("Expected a click URL in the extras, got %v", request.body["extras"])
+	}
+}
+
+func TestPushNotifier_RateLimiting(t *testing.T) {
+	server, requests := newPushServer(t, http.StatusOK)
+	notifier := NewPushNotifier(PushNotificationSettings{MinInterval: time.Hour}, NewNtfySender(server.URL, "cameras", ""), nil, logging.NopLogger)
+
+	notifier.NotifyMotionDetected("cam-1", "clip-1", "clip.mp4", time.Now())
+	notifier.NotifyMotionDetected("cam-1", "clip-2", "clip.mp4", time.Now())
+	notifier.NotifyMotionDetected("cam-2", "clip-3", "clip.mp4", time.Now())
+
+	if len(*requests) != 2 {
+		t.Errorf("Expected the second notification for cam-1 to be skipped, got %d requests", len(*requests))
+	}
+	if _, hasClick := (*requests)[0].body["click"]; hasClick {
+		t.Error("Expected no click URL without a dashboard URL")
+	}
+	if _, hasAuthorization := (*requests)[0].header["Authorization"]; hasAuthorization {
+		t.Error("Expected no authorization header without an access token")
+	}
+}
+
+func TestPushNotifier_FailedSendIsNotRateLimited(t *testing.T) {
+	server, requests := newPushServer(t, http.StatusForbidden)
+	notifier := NewPushNotifier(PushNotificationSettings{MinInterval: time.Hour}, NewGotifySender(server.URL, "wrong-token"), nil, logging.NopLogger)
+
+	if err := notifier.NotifyCapacityWarning("cam-1", 80, 100); err == nil {
+		t.Error("Expected an error for a rejected message")
+	}
+	notifier.NotifyCapacityWarning("cam-1", 80, 100)
+
+	if len(*requests) != 2 {
+		t.Errorf("Expected a failed notification to be retried with the next event, got %d requests", len(*requests))
+	}
+}
@@ -0,0 +1,150 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const pushTimeout = 10 * time.Second
+
+// PushMessage is a notification shown on the phones subscribed to a push service
+type PushMessage struct {
+	Title    string
+	Message  string
+	Priority int    // Priority on the scale of the push service, 0 for the default of the server
+	ClickURL string // Opened when the notification is tapped, empty for none
+	Tags     []string
+}
+
+type PushSender interface {
+	// SendPush publishes a message to the push service.
+	SendPush(message PushMessage) error
+}
+
+// NtfySender implements the PushSender interface for ntfy servers. Priorities range from 1 (min) to 5 (urgent).
+type NtfySender struct {
+	ServerURL   string
+	Topic       string
+	AccessToken string // Sent as bearer token, empty for topics that allow anonymous publishing
+	client      *http.Client
+}
+
+// NewNtfySender creates a new NtfySender.
+func NewNtfySender(serverURL, topic, accessToken string) *NtfySender {
+	return &NtfySender{
+		ServerURL:   strings.TrimRight(serverURL, "/"),
+		Topic:       topic,
+		AccessToken: accessToken,
+		client:      &http.Client{Timeout: pushTimeout},
+	}
+}
+
+type ntfyRequest struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority,omitempty"`
+	Click    string   `json:"click,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// SendPush publishes the message as JSON to the root of the ntfy server.
+func (s *NtfySender) SendPush(message PushMessage) error {
+	body, err := json.Marshal(ntfyRequest{
+		Topic:    s.Topic,
+		Title:    message.Title,
+		Message:  message.Message,
+		Priority: message.Priority,
+		Click:    message.ClickURL,
+		Tags:     message.Tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.ServerURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.AccessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	}
+
+	return sendPushRequest(s.client, request, "ntfy")
+}
+
+// GotifySender implements the PushSender interface for Gotify servers. Priorities range from 0 to 10; clients
+// usually only alert for 4 and above.
+type GotifySender struct {
+	ServerURL string
+	AppToken  string
+	client    *http.Client
+}
+
+// NewGotifySender creates a new GotifySender.
+func NewGotifySender(serverURL, appToken string) *GotifySender {
+	return &GotifySender{
+		ServerURL: strings.TrimRight(serverURL, "/"),
+		AppToken:  appToken,
+		client:    &http.Client{Timeout: pushTimeout},
+	}
+}
+
+type gotifyRequest struct {
+	Title    string         `json:"title,omitempty"`
+	Message  string         `json:"message"`
+	Priority *int           `json:"priority,omitempty"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+// SendPush posts the message to the message endpoint of the Gotify server.
+func (s *GotifySender) SendPush(message PushMessage) error {
+	gotifyMessage := gotifyRequest{
+		Title:   message.Title,
+		Message: message.Message,
+	}
+	if message.Priority != 0 {
+		gotifyMessage.Priority = &message.Priority
+	}
+	if message.ClickURL != "" {
+		gotifyMessage.Extras = map[string]any{
+			"client::notification": map[string]any{
+				"click": map[string]string{"url": message.ClickURL},
+			},
+		}
+	}
+
+	body, err := json.Marshal(gotifyMessage)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.ServerURL+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Gotify-Key", s.AppToken)
+
+	return sendPushRequest(s.client, request, "gotify")
+}
+
+func sendPushRequest(client *http.Client, request *http.Request, service string) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s server: %w", service, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s server responded with status %d: %s", service, response.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -47,6 +47,7 @@ type StorageNotificationSettings struct {
 type emailStorageNotifier struct {
 	settings          StorageNotificationSettings
 	sender            EmailSender
+	directory         ClientDirectory
 	logger            logging.Logger
 	lastNotification  map[string]time.Time
 	notificationMutex sync.Mutex
@@ -54,10 +55,16 @@ type emailStorageNotifier struct {
 	warningMutex      sync.Mutex
 }
 
-func NewEmailStorageNotifier(settings StorageNotificationSettings, sender EmailSender, logger logging.Logger) StorageNotifier {
+// NewEmailStorageNotifier creates a StorageNotifier that sends emails. The directory provides the client names shown
+// in them; nil describes clients by their IDs.
+func NewEmailStorageNotifier(settings StorageNotificationSettings, sender EmailSender, directory ClientDirectory, logger logging.Logger) StorageNotifier {
+	if directory == nil {
+		directory = PlainClientDirectory
+	}
 	return &emailStorageNotifier{
 		settings:         settings,
 		sender:           sender,
+		directory:        directory,
 		logger:           logger,
 		lastNotification: make(map[string]time.Time),
 		lastWarning:      make(map[string]time.Time),
@@ -81,9 +88,10 @@ func (n *emailStorageNotifier) NotifyCapacityReached(clientID string, usedMegaBy
 		return nil
 	}
 
-	subject := "CryoSpy client storage capacity reached"
+	client := n.directory.DescribeClient(clientID)
+	subject := fmt.Sprintf("CryoSpy client storage capacity reached: %s", client.Name)
 	body := fmt.Sprintf("Storage capacity for client '%s' has been reached.\n\nUsed: %d MB\nTotal: %d MB\n\nOld video footage will now be overwritten until capacity is freed.",
-		clientID,
+		client.Label(),
 		usedMegaBytes,
 		totalMegaBytes)
 
@@ -107,9 +115,10 @@ func (n *emailStorageNotifier) NotifyCapacityWarning(clientID string, usedMegaBy
 		return nil
 	}
 
-	subject := "CryoSpy client storage capacity warning"
+	client := n.directory.DescribeClient(clientID)
+	subject := fmt.Sprintf("CryoSpy client storage capacity warning: %s", client.Name)
 	body := fmt.Sprintf("Storage capacity for client '%s' is nearing its limit.\n\nUsed: %d MB\nTotal: %d MB\n\nPlease consider freeing up space to avoid overwriting old footage.",
-		clientID,
+		client.Label(),
 		usedMegaBytes,
 		totalMegaBytes)
 
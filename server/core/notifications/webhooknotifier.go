@@ -0,0 +1,327 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+const (
+	webhookSignatureHeader = "X-CryoSpy-Signature"
+	webhookTimestampHeader = "X-CryoSpy-Timestamp"
+	webhookEventHeader     = "X-CryoSpy-Event"
+	webhookDeliveryHeader  = "X-CryoSpy-Delivery"
+	webhookSignaturePrefix = "sha256="
+	defaultWebhookTimeout  = 10 * time.Second
+)
+
+type WebhookSettings struct {
+	URLs             []string
+	Secret           string   // Key for the HMAC-SHA256 signature, empty for unsigned payloads
+	Events           []string // Event types to post, empty for all but the PerClipEventTypes
+	MinInterval      time.Duration
+	MaxRetries       int
+	RetryBackoff     time.Duration // Wait before the first retry, doubled for every further retry
+	Timeout          time.Duration
+	WarningThreshold float64
+	FailureThreshold int
+}
+
+// WebhookPayload is the JSON body posted to webhooks. Only the sections that belong to the event type are set.
+type WebhookPayload struct {
+	ID         string                 `json:"id"`
+	Event      string                 `json:"event"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Client     *WebhookClientPayload  `json:"client,omitempty"`
+	Clip       *WebhookClipPayload    `json:"clip,omitempty"`
+	Storage    *WebhookStoragePayload `json:"storage,omitempty"`
+	Auth       *WebhookAuthPayload    `json:"auth,omitempty"`
+}
+
+type WebhookClientPayload struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Location       string `json:"location,omitempty"`
+	DisabledReason string `json:"disabled_reason,omitempty"` // Why the capture server disabled the client
+}
+
+type WebhookClipPayload struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Timestamp time.Time `json:"timestamp"`
+	HasMotion *bool     `json:"has_motion,omitempty"` // Only set for stored clips
+}
+
+type WebhookStoragePayload struct {
+	UsedMegaBytes  int64   `json:"used_mb"`
+	TotalMegaBytes int64   `json:"total_mb"`
+	UsedFraction   float64 `json:"used_fraction"`
+}
+
+type WebhookAuthPayload struct {
+	FailureCount int        `json:"failure_count"`
+	SourceIPs    []string   `json:"source_ips,omitempty"`
+	Account      string     `json:"account,omitempty"`      // Dashboard account, for lockouts
+	LockedUntil  *time.Time `json:"locked_until,omitempty"` // End of a dashboard lockout
+}
+
+// SignWebhookPayload returns the signature of a payload as sent in the X-CryoSpy-Signature header. The timestamp
+// from the X-CryoSpy-Timestamp header is signed along with the body, so that receivers can reject replayed requests.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookNotifier posts notification events to webhooks. It is a MotionNotifier, StorageNotifier, AuthNotifier,
+// ClipNotifier and ClientStatusNotifier.
+type WebhookNotifier interface {
+	MotionNotifier
+	StorageNotifier
+	AuthNotifier
+	ClipNotifier
+	ClientStatusNotifier
+}
+
+type webhookNotifier struct {
+	settings          WebhookSettings
+	events            map[string]bool
+	directory         ClientDirectory
+	httpClient        *http.Client
+	logger            logging.Logger
+	lastNotification  map[string]time.Time
+	notificationMutex sync.Mutex
+	deliveries        sync.WaitGroup // Deliveries in flight, waited for by tests
+}
+
+// NewWebhookNotifier creates a notifier that posts events to the configured URLs. Events are delivered in the
+// background, so the notify methods return before the webhooks have been called. The directory provides the client
+// names in the payloads; nil describes clients by their IDs.
+func NewWebhookNotifier(settings WebhookSettings, directory ClientDirectory, logger logging.Logger) WebhookNotifier {
+	if directory == nil {
+		directory = PlainClientDirectory
+	}
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+	if settings.Timeout <= 0 {
+		settings.Timeout = defaultWebhookTimeout
+	}
+
+	events := make(map[string]bool, len(EventTypes))
+	if len(settings.Events) > 0 {
+		for _, event := range settings.Events {
+			if !IsEventType(event) {
+				logger.Warn("Ignoring unknown webhook event type", "event", event)
+				continue
+			}
+			events[event] = true
+		}
+	} else {
+		for _, event := range EventTypes {
+			events[event] = !slices.Contains(PerClipEventTypes, event)
+		}
+	}
+
+	return &webhookNotifier{
+		settings:         settings,
+		events:           events,
+		directory:        directory,
+		httpClient:       &http.Client{Timeout: settings.Timeout},
+		logger:           logger,
+		lastNotification: make(map[string]time.Time),
+	}
+}
+
+func (n *webhookNotifier) NotifyMotionDetected(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
+	payload := n.newPayload(EventMotionDetected, clientID)
+	payload.Clip = &WebhookClipPayload{ID: clipID, Title: clipTitle, Timestamp: timestamp.UTC()}
+	n.post(payload, clientID)
+	return nil
+}
+
+func (n *webhookNotifier) ShouldWarn(usedMegaBytes int64, totalMegaBytes int64) bool {
+	if totalMegaBytes == 0 {
+		return false
+	}
+	return float64(usedMegaBytes)/float64(totalMegaBytes) >= n.settings.WarningThreshold
+}
+
+func (n *webhookNotifier) NotifyCapacityWarning(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
+	payload := n.newPayload(EventCapacityWarning, clientID)
+	payload.Storage = newWebhookStoragePayload(usedMegaBytes, totalMegaBytes)
+	n.post(payload, clientID)
+	return nil
+}
+
+func (n *webhookNotifier) NotifyCapacityReached(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
+	payload := n.newPayload(EventCapacityReached, clientID)
+	payload.Storage = newWebhookStoragePayload(usedMegaBytes, totalMegaBytes)
+	n.post(payload, clientID)
+	return nil
+}
+
+func (n *webhookNotifier) ShouldNotify(failureCount int) bool {
+	return failureCount >= n.settings.FailureThreshold
+}
+
+func (n *webhookNotifier) NotifyRepeatedAuthFailure(clientID string, failureCount int, clientIP string) error {
+	payload := n.newPayload(EventAuthFailure, clientID)
+	payload.Auth = &WebhookAuthPayload{FailureCount: failureCount, SourceIPs: []string{clientIP}}
+	n.post(payload, clientID)
+	return nil
+}
+
+func (n *webhookNotifier) NotifyDashboardLoginLockout(account string, failureCount int, sourceIPs []string, lockedUntil time.Time) error {
+	payload := n.newPayload(EventDashboardLoginLockout, "")
+	lockedUntil = lockedUntil.UTC()
+	payload.Auth = &WebhookAuthPayload{FailureCount: failureCount, SourceIPs: sourceIPs, Account: account, LockedUntil: &lockedUntil}
+	// Dashboard accounts are rate limited separately from capture clients with the same name
+	n.post(payload, "dashboard:"+account)
+	return nil
+}
+
+func (n *webhookNotifier) NotifyClipStored(clientID string, clipID string, clipTitle string, timestamp time.Time, hasMotion bool) error {
+	payload := n.newPayload(EventClipStored, clientID)
+	payload.Clip = &WebhookClipPayload{ID: clipID, Title: clipTitle, Timestamp: timestamp.UTC(), HasMotion: &hasMotion}
+	// Every clip is reported, however many a client uploads
+	n.post(payload, "")
+	return nil
+}
+
+func (n *webhookNotifier) NotifyClipEvicted(clientID string, clipID string, clipTitle string, timestamp time.Time) error {
+	payload := n.newPayload(EventClipEvicted, clientID)
+	payload.Clip = &WebhookClipPayload{ID: clipID, Title: clipTitle, Timestamp: timestamp.UTC()}
+	n.post(payload, "")
+	return nil
+}
+
+func (n *webhookNotifier) NotifyClientDisabled(clientID string, reason string, failureCount int) error {
+	payload := n.newPayload(EventClientDisabled, clientID)
+	payload.Client.DisabledReason = reason
+	if failureCount > 0 {
+		payload.Auth = &WebhookAuthPayload{FailureCount: failureCount}
+	}
+	n.post(payload, clientID)
+	return nil
+}
+
+func newWebhookStoragePayload(usedMegaBytes int64, totalMegaBytes int64) *WebhookStoragePayload {
+	storage := &WebhookStoragePayload{UsedMegaBytes: usedMegaBytes, TotalMegaBytes: totalMegaBytes}
+	if totalMegaBytes > 0 {
+		storage.UsedFraction = float64(usedMegaBytes) / float64(totalMegaBytes)
+	}
+	return storage
+}
+
+func (n *webhookNotifier) newPayload(event string, clientID string) *WebhookPayload {
+	payload := &WebhookPayload{
+		ID:         uuid.NewString(),
+		Event:      event,
+		OccurredAt: time.Now().UTC(),
+	}
+	if clientID != "" {
+		client := n.directory.DescribeClient(clientID)
+		payload.Client = &WebhookClientPayload{ID: clientID, Name: client.Name, Location: client.Location}
+	}
+	return payload
+}
+
+// post delivers a payload to all webhooks in the background, unless its event type is filtered out or an event of
+// the same type was recently sent for the key. An empty key skips the rate limit, for events that are reported for
+// every single clip and would otherwise leave an entry per clip behind.
+func (n *webhookNotifier) post(payload *WebhookPayload, key string) {
+	if !n.events[payload.Event] {
+		return
+	}
+
+	if key != "" {
+		n.notificationMutex.Lock()
+		rateLimitKey := payload.Event + ":" + key
+		if time.Since(n.lastNotification[rateLimitKey]) < n.settings.MinInterval {
+			n.notificationMutex.Unlock()
+			n.logger.Info("Skipping webhook event due to rate limiting.", "event", payload.Event, "key", key)
+			return
+		}
+		n.lastNotification[rateLimitKey] = time.Now()
+		n.notificationMutex.Unlock()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		n.logger.Error("Failed to encode webhook payload.", "error", err, "event", payload.Event)
+		return
+	}
+
+	for _, url := range n.settings.URLs {
+		n.deliveries.Add(1)
+		go func(url string) {
+			defer n.deliveries.Done()
+			n.deliver(url, payload, body)
+		}(url)
+	}
+}
+
+// deliver posts a payload to a webhook, retrying with exponential backoff until it is accepted or the retries are used up
+func (n *webhookNotifier) deliver(url string, payload *WebhookPayload, body []byte) {
+	backoff := n.settings.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.send(url, payload, body)
+		if err == nil {
+			n.logger.Info("Webhook event delivered.", "event", payload.Event, "url", url, "attempts", attempt+1)
+			return
+		}
+		if !retry || attempt >= n.settings.MaxRetries {
+			n.logger.Error("Failed to deliver webhook event.", "error", err, "event", payload.Event, "url", url, "attempts", attempt+1)
+			return
+		}
+
+		n.logger.Warn("Webhook delivery failed, retrying.", "event", payload.Event, "url", url, "error", err, "backoff", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send makes a single delivery attempt. It reports whether a failed attempt is worth retrying.
+func (n *webhookNotifier) send(url string, payload *WebhookPayload, body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "CryoSpy-Webhook")
+	request.Header.Set(webhookEventHeader, payload.Event)
+	request.Header.Set(webhookDeliveryHeader, payload.ID)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	if n.settings.Secret != "" {
+		request.Header.Set(webhookSignatureHeader, SignWebhookPayload(n.settings.Secret, timestamp, body))
+	}
+
+	response, err := n.httpClient.Do(request)
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	// Other client errors mean the webhook rejects the request, which a retry does not change
+	retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("webhook responded with status %d", response.StatusCode)
+}
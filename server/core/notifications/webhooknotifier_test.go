@@ -0,0 +1,225 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookRecorder is a webhook that answers with the given status codes in turn, and 200 once they are used up
+type webhookRecorder struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []webhookRequest
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests = append(r.requests, webhookRequest{header: req.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestWebhookNotifier(t *testing.T, recorder *webhookRecorder, settings WebhookSettings) *webhookNotifier {
+	server := httptest.NewServer(recorder)
+	t.Cleanup(server.Close)
+
+	settings.URLs = []string{server.URL}
+	return NewWebhookNotifier(settings, nil, logging.NopLogger).(*webhookNotifier)
+}
+
+func TestWebhookNotifier_PostsSignedPayload(t *testing.T) {
+	recorder := &webhookRecorder{}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{Secret: "s3cret"})
+
+	timestamp := time.Date(2025, time.July, 1, 20, 30, 0, 0, time.UTC)
+	if err := notifier.NotifyMotionDetected("cam-1", "clip-1", "clip.mp4", timestamp); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(recorder.requests))
+	}
+	request := recorder.requests[0]
+
+	expected := SignWebhookPayload("s3cret", request.header.Get(webhookTimestampHeader), request.body)
+	if request.header.Get(webhookSignatureHeader) != expected {
+		t.Errorf("Expected signature %s, got %s", expected, request.header.Get(webhookSignatureHeader))
+	}
+	if request.header.Get(webhookEventHeader) != EventMotionDetected {
+		t.Errorf("Unexpected event header: %s", request.header.Get(webhookEventHeader))
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(request.body, &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if payload.Event != EventMotionDetected || payload.Client == nil || payload.Client.ID != "cam-1" {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+	if payload.Clip == nil || payload.Clip.ID != "clip-1" || !payload.Clip.Timestamp.Equal(timestamp) {
+		t.Errorf("Unexpected clip in payload: %+v", payload.Clip)
+	}
+	if payload.ID != request.header.Get(webhookDeliveryHeader) {
+		t.Errorf("Expected the delivery header to carry the payload ID")
+	}
+}
+
+func TestWebhookNotifier_FiltersEvents(t *testing.T) {
+	recorder := &webhookRecorder{}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{
+		Events: []string{EventCapacityReached},
+	})
+
+	notifier.NotifyMotionDetected("cam-1", "clip-1", "clip.mp4", time.Now())
+	notifier.NotifyCapacityWarning("cam-1", 80, 100)
+	notifier.NotifyCapacityReached("cam-1", 100, 100)
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 1 {
+		t.Fatalf("Expected only the capacity reached event, got %d requests", len(recorder.requests))
+	}
+	var payload WebhookPayload
+	json.Unmarshal(recorder.requests[0].body, &payload)
+	if payload.Event != EventCapacityReached || payload.Storage == nil || payload.Storage.UsedFraction != 1 {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+	if _, signed := recorder.requests[0].header[webhookSignatureHeader]; signed {
+		t.Error("Expected no signature without a secret")
+	}
+}
+
+func TestWebhookNotifier_SendsClipEventsOnlyWhenListed(t *testing.T) {
+	recorder := &webhookRecorder{}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{MinInterval: time.Hour})
+
+	notifier.NotifyClipStored("cam-1", "clip-1", "clip.mp4", time.Now(), true)
+	notifier.NotifyClientDisabled("cam-1", "auth_failures", 10)
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 1 {
+		t.Fatalf("Expected only the client disabled event, got %d requests", len(recorder.requests))
+	}
+	var payload WebhookPayload
+	json.Unmarshal(recorder.requests[0].body, &payload)
+	if payload.Event != EventClientDisabled || payload.Client.DisabledReason != "auth_failures" || payload.Auth == nil || payload.Auth.FailureCount != 10 {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+
+	recorder = &webhookRecorder{}
+	notifier = newTestWebhookNotifier(t, recorder, WebhookSettings{
+		Events:      []string{EventClipStored, EventClipEvicted},
+		MinInterval: time.Hour,
+	})
+
+	// Every clip is posted, despite the rate limit
+	notifier.NotifyClipStored("cam-1", "clip-1", "clip-1.mp4", time.Now(), true)
+	notifier.NotifyClipStored("cam-1", "clip-2", "clip-2.mp4", time.Now(), false)
+	notifier.NotifyClipEvicted("cam-1", "clip-0", "clip-0.mp4", time.Now())
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 3 {
+		t.Fatalf("Expected 3 clip events, got %d requests", len(recorder.requests))
+	}
+	for _, request := range recorder.requests {
+		json.Unmarshal(request.body, &payload)
+		if payload.Clip == nil {
+			t.Fatalf("Expected a clip section: %+v", payload)
+		}
+		if payload.Clip.ID == "clip-2" && (payload.Clip.HasMotion == nil || *payload.Clip.HasMotion) {
+			t.Errorf("Expected the second clip without motion, got %+v", payload.Clip)
+		}
+	}
+}
+
+func TestWebhookNotifier_ClipEventsLeaveNoRateLimitEntries(t *testing.T) {
+	recorder := &webhookRecorder{}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{
+		Events:      []string{EventClipStored, EventClipEvicted, EventMotionDetected},
+		MinInterval: time.Hour,
+	})
+
+	for i := range 1000 {
+		notifier.NotifyClipStored("cam-1", fmt.Sprintf("clip-%d", i), "clip.mp4", time.Now(), false)
+		notifier.NotifyClipEvicted("cam-1", fmt.Sprintf("clip-old-%d", i), "clip.mp4", time.Now())
+	}
+	notifier.NotifyMotionDetected("cam-1", "clip-1", "clip.mp4", time.Now())
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 2001 {
+		t.Errorf("Expected every clip event to be posted, got %d requests", len(recorder.requests))
+	}
+	notifier.notificationMutex.Lock()
+	defer notifier.notificationMutex.Unlock()
+	if len(notifier.lastNotification) != 1 {
+		t.Errorf("Expected only the motion event to be rate limited, got %d entries", len(notifier.lastNotification))
+	}
+}
+
+func TestWebhookNotifier_RetriesWithBackoff(t *testing.T) {
+	recorder := &webhookRecorder{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{
+		MaxRetries:   3,
+		RetryBackoff: 10 * time.Millisecond,
+	})
+
+	start := time.Now()
+	notifier.NotifyRepeatedAuthFailure("cam-1", 5, "192.0.2.1")
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 3 {
+		t.Fatalf("Expected 2 failed attempts and a successful one, got %d requests", len(recorder.requests))
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected the retries to back off, took %v", elapsed)
+	}
+	if string(recorder.requests[0].body) != string(recorder.requests[2].body) {
+		t.Error("Expected retries to post the same payload")
+	}
+}
+
+func TestWebhookNotifier_DoesNotRetryRejectedRequests(t *testing.T) {
+	recorder := &webhookRecorder{statuses: []int{http.StatusBadRequest}}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{MaxRetries: 3})
+
+	notifier.NotifyDashboardLoginLockout("alice", 10, []string{"192.0.2.1"}, time.Now().Add(time.Hour))
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 1 {
+		t.Errorf("Expected a rejected request not to be retried, got %d requests", len(recorder.requests))
+	}
+}
+
+func TestWebhookNotifier_RateLimitsPerEventAndClient(t *testing.T) {
+	recorder := &webhookRecorder{}
+	notifier := newTestWebhookNotifier(t, recorder, WebhookSettings{MinInterval: time.Hour})
+
+	notifier.NotifyCapacityWarning("cam-1", 80, 100)
+	notifier.NotifyCapacityWarning("cam-1", 81, 100)
+	notifier.NotifyCapacityReached("cam-1", 100, 100)
+	notifier.NotifyCapacityWarning("cam-2", 80, 100)
+	notifier.deliveries.Wait()
+
+	if len(recorder.requests) != 3 {
+		t.Errorf("Expected the second warning for cam-1 to be skipped, got %d requests", len(recorder.requests))
+	}
+}
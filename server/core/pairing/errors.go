@@ -0,0 +1,19 @@
+package pairing
+
+// Error types for pairing codes
+type InvalidPairingCodeError struct{}
+
+func (e *InvalidPairingCodeError) Error() string {
+	return "invalid, expired or already redeemed pairing code"
+}
+
+// helper functions for error handling
+
+func IsInvalidPairingCodeError(err error) bool {
+	_, ok := err.(*InvalidPairingCodeError)
+	return ok
+}
+
+func NewInvalidPairingCodeError() error {
+	return &InvalidPairingCodeError{}
+}
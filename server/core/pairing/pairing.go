@@ -0,0 +1,31 @@
+package pairing
+
+import "time"
+
+// PairingCode is a pending one-time code that hands out the credentials of a client to a new capture device
+type PairingCode struct {
+	ID                string    // SHA-256 hash of the normalized code (hex encoded), used to look the code up
+	ClientID          string    // ID of the client the credentials belong to
+	EncryptedPayload  string    // Credentials encrypted with a key derived from the code (base 64 encoded)
+	KeyDerivationSalt string    // Salt used for deriving the encryption key from the code (base 64 encoded)
+	CreatedBy         string    // Dashboard user who created the code
+	CreatedAt         time.Time // Timestamp when the code was created
+	ExpiresAt         time.Time // Time after which the code can no longer be redeemed
+}
+
+// Credentials is what a capture device receives when it redeems a pairing code
+type Credentials struct {
+	ClientID          string `json:"client_id"`
+	ClientSecret      string `json:"client_secret"`                // Hex-encoded client secret
+	ClientCertificate string `json:"client_certificate,omitempty"` // PEM-encoded client certificate, if client certificates are required
+	ClientKey         string `json:"client_key,omitempty"`         // PEM-encoded private key of the client certificate
+	CABundle          string `json:"ca_bundle,omitempty"`          // PEM-encoded CryoSpy CA certificate
+}
+
+// IssuedCode is a newly created pairing code as shown on the dashboard
+type IssuedCode struct {
+	Code      string    // The code to enter on the capture device
+	URI       string    // cryospy://enroll URI encoded in the QR code
+	QRCodePNG []byte    // PNG image of the QR code
+	ExpiresAt time.Time // Time after which the code can no longer be redeemed
+}
@@ -0,0 +1,126 @@
+package pairing
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type PairingCodeRepository interface {
+	// Create stores a new pairing code
+	Create(code *PairingCode) error
+	// Take retrieves and deletes a pairing code in one step, so that it can only be taken once.
+	// Returns nil if the code does not exist or was already taken.
+	Take(id string) (*PairingCode, error)
+	// DeleteByClientID removes all pairing codes of a client
+	DeleteByClientID(clientID string) error
+	// DeleteExpired removes all pairing codes that expired before the given time
+	DeleteExpired(now time.Time) error
+}
+
+// SQLitePairingCodeRepository implements PairingCodeRepository using SQLite
+type SQLitePairingCodeRepository struct {
+	db *sql.DB
+}
+
+// NewSQLitePairingCodeRepository creates a new SQLite-based PairingCodeRepository
+func NewSQLitePairingCodeRepository(db *sql.DB) (*SQLitePairingCodeRepository, error) {
+	repo := &SQLitePairingCodeRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLitePairingCodeRepository) createTables() error {
+	createPairingCodesTable := `
+	CREATE TABLE IF NOT EXISTS pairing_codes (
+		id TEXT PRIMARY KEY,
+		client_id TEXT NOT NULL,
+		encrypted_payload TEXT NOT NULL,
+		key_derivation_salt TEXT NOT NULL,
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL
+	);`
+
+	_, err := r.db.Exec(createPairingCodesTable)
+	return err
+}
+
+func (r *SQLitePairingCodeRepository) Create(code *PairingCode) error {
+	query := `
+	INSERT INTO pairing_codes (id, client_id, encrypted_payload, key_derivation_salt, created_by, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.Exec(query,
+		code.ID, code.ClientID, code.EncryptedPayload, code.KeyDerivationSalt, code.CreatedBy,
+		db.TimeToString(code.CreatedAt), db.TimeToString(code.ExpiresAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create pairing code: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLitePairingCodeRepository) Take(id string) (*PairingCode, error) {
+	query := `
+	SELECT id, client_id, encrypted_payload, key_derivation_salt, created_by, created_at, expires_at
+	FROM pairing_codes WHERE id = ?`
+
+	code := &PairingCode{}
+	var createdAtStr, expiresAtStr string
+	err := r.db.QueryRow(query, id).Scan(
+		&code.ID, &code.ClientID, &code.EncryptedPayload, &code.KeyDerivationSalt, &code.CreatedBy, &createdAtStr, &expiresAtStr,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pairing code: %w", err)
+	}
+
+	// Only the request that actually deletes the code gets to use it
+	result, err := r.db.Exec(`DELETE FROM pairing_codes WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete pairing code: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+
+	code.CreatedAt, err = db.StringToTime(createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+	}
+	code.ExpiresAt, err = db.StringToTime(expiresAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expires_at timestamp: %w", err)
+	}
+
+	return code, nil
+}
+
+func (r *SQLitePairingCodeRepository) DeleteByClientID(clientID string) error {
+	if _, err := r.db.Exec(`DELETE FROM pairing_codes WHERE client_id = ?`, clientID); err != nil {
+		return fmt.Errorf("failed to delete pairing codes: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLitePairingCodeRepository) DeleteExpired(now time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM pairing_codes WHERE expires_at <= ?`, db.TimeToString(now.UTC())); err != nil {
+		return fmt.Errorf("failed to delete expired pairing codes: %w", err)
+	}
+	return nil
+}
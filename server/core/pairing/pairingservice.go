@@ -0,0 +1,211 @@
+package pairing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image/png"
+	"net/url"
+	"time"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/yeti47/cryospy/server/core/ccc/codes"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+const (
+	codeLength    = 12
+	codeGroupSize = 4 // Characters per dash-separated group of a formatted code
+	qrCodeSize    = 256
+)
+
+// PairingSettings holds the configuration for pairing codes
+type PairingSettings struct {
+	CodeLifetime  time.Duration // How long a pairing code can be redeemed
+	ServerURL     string        // Capture server URL included in the QR code (empty to leave it out)
+	CAFingerprint string        // SHA-256 fingerprint of the CA that issued the capture server certificate (empty to leave it out)
+}
+
+type PairingService interface {
+	// CreateCode stores the credentials under a new pairing code, replacing any pending code of the same client.
+	// The credentials are encrypted with a key derived from the code, which itself is not stored.
+	CreateCode(credentials Credentials, createdBy string) (*IssuedCode, error)
+	// Redeem returns the credentials of a pairing code. Each code can be redeemed only once.
+	Redeem(code string) (*Credentials, error)
+}
+
+type pairingService struct {
+	logger    logging.Logger
+	repo      PairingCodeRepository
+	encryptor encryption.Encryptor
+	settings  PairingSettings
+	now       func() time.Time
+}
+
+func NewPairingService(logger logging.Logger, repo PairingCodeRepository, encryptor encryption.Encryptor, settings PairingSettings) *pairingService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &pairingService{
+		logger:    logger,
+		repo:      repo,
+		encryptor: encryptor,
+		settings:  settings,
+		now:       time.Now,
+	}
+}
+
+func (s *pairingService) CreateCode(credentials Credentials, createdBy string) (*IssuedCode, error) {
+	now := s.now().UTC()
+
+	if err := s.repo.DeleteExpired(now); err != nil {
+		s.logger.Warn("Failed to delete expired pairing codes", "error", err)
+	}
+
+	code, err := codes.Generate(codeLength, codeGroupSize)
+	if err != nil {
+		s.logger.Error("Failed to generate pairing code", err)
+		return nil, err
+	}
+	normalized := codes.Normalize(code)
+
+	payload, err := json.Marshal(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := s.encryptor.GenerateSalt()
+	if err != nil {
+		s.logger.Error("Failed to generate key-derivation salt", err)
+		return nil, err
+	}
+	key, err := s.encryptor.DeriveKeyFromSecret([]byte(normalized), salt)
+	if err != nil {
+		s.logger.Error("Failed to derive key from pairing code", err)
+		return nil, err
+	}
+	encryptedPayload, err := s.encryptor.Encrypt(payload, key)
+	if err != nil {
+		s.logger.Error("Failed to encrypt pairing payload", err)
+		return nil, err
+	}
+
+	// A new code supersedes any pending one, whose secret has usually been rotated away anyway
+	if err := s.repo.DeleteByClientID(credentials.ClientID); err != nil {
+		s.logger.Error("Failed to delete previous pairing codes", err)
+		return nil, err
+	}
+
+	pairingCode := &PairingCode{
+		ID:                codeID(normalized),
+		ClientID:          credentials.ClientID,
+		EncryptedPayload:  base64.StdEncoding.EncodeToString(encryptedPayload),
+		KeyDerivationSalt: base64.StdEncoding.EncodeToString(salt),
+		CreatedBy:         createdBy,
+		CreatedAt:         now,
+		ExpiresAt:         now.Add(s.settings.CodeLifetime),
+	}
+	if err := s.repo.Create(pairingCode); err != nil {
+		s.logger.Error("Failed to store pairing code", err)
+		return nil, err
+	}
+
+	uri := s.buildURI(code)
+	qrCodePNG, err := renderQRCode(uri)
+	if err != nil {
+		s.logger.Error("Failed to render pairing QR code", err)
+		return nil, err
+	}
+
+	s.logger.Info("Pairing code created", "clientId", credentials.ClientID, "by", createdBy, "expiresAt", pairingCode.ExpiresAt)
+	return &IssuedCode{
+		Code:      code,
+		URI:       uri,
+		QRCodePNG: qrCodePNG,
+		ExpiresAt: pairingCode.ExpiresAt,
+	}, nil
+}
+
+func (s *pairingService) Redeem(code string) (*Credentials, error) {
+	normalized := codes.Normalize(code)
+	if len(normalized) != codeLength {
+		return nil, NewInvalidPairingCodeError()
+	}
+
+	pairingCode, err := s.repo.Take(codeID(normalized))
+	if err != nil {
+		s.logger.Error("Failed to look up pairing code", err)
+		return nil, err
+	}
+	if pairingCode == nil || !s.now().Before(pairingCode.ExpiresAt) {
+		return nil, NewInvalidPairingCodeError()
+	}
+
+	encryptedPayload, err := base64.StdEncoding.DecodeString(pairingCode.EncryptedPayload)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := base64.StdEncoding.DecodeString(pairingCode.KeyDerivationSalt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.encryptor.DeriveKeyFromSecret([]byte(normalized), salt)
+	if err != nil {
+		s.logger.Error("Failed to derive key from pairing code", err)
+		return nil, err
+	}
+	payload, err := s.encryptor.Decrypt(encryptedPayload, key)
+	if err != nil {
+		return nil, NewInvalidPairingCodeError()
+	}
+
+	var credentials Credentials
+	if err := json.Unmarshal(payload, &credentials); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Pairing code redeemed", "clientId", credentials.ClientID)
+	return &credentials, nil
+}
+
+// buildURI builds the cryospy://enroll URI that the capture client accepts in place of the plain code
+func (s *pairingService) buildURI(code string) string {
+	query := url.Values{}
+	query.Set("code", code)
+	if s.settings.ServerURL != "" {
+		query.Set("server", s.settings.ServerURL)
+	}
+	if s.settings.CAFingerprint != "" {
+		query.Set("ca", s.settings.CAFingerprint)
+	}
+	return "cryospy://enroll?" + query.Encode()
+}
+
+func renderQRCode(content string) ([]byte, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	code, err = barcode.Scale(code, qrCodeSize, qrCodeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var image bytes.Buffer
+	if err := png.Encode(&image, code); err != nil {
+		return nil, err
+	}
+	return image.Bytes(), nil
+}
+
+// codeID returns the identifier under which a normalized code is stored
+func codeID(normalized string) string {
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,95 @@
+package pairing
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func setupTestPairingService(t *testing.T) (*pairingService, func()) {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+
+	repo, err := NewSQLitePairingCodeRepository(testDB)
+	if err != nil {
+		testDB.Close()
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	service := NewPairingService(nil, repo, encryption.NewAESEncryptor(), PairingSettings{
+		CodeLifetime:  15 * time.Minute,
+		ServerURL:     "https://cryospy.local:8081",
+		CAFingerprint: "abcdef",
+	})
+	return service, func() { testDB.Close() }
+}
+
+func TestPairingService_RedeemOnce(t *testing.T) {
+	service, cleanup := setupTestPairingService(t)
+	defer cleanup()
+
+	credentials := Credentials{ClientID: "camera-1", ClientSecret: "0011aabb", CABundle: "-----BEGIN CERTIFICATE-----"}
+	issued, err := service.CreateCode(credentials, "admin")
+	if err != nil {
+		t.Fatalf("Failed to create pairing code: %v", err)
+	}
+	if len(issued.QRCodePNG) == 0 {
+		t.Error("Expected a QR code image")
+	}
+
+	uri, err := url.Parse(issued.URI)
+	if err != nil {
+		t.Fatalf("Failed to parse pairing URI: %v", err)
+	}
+	if uri.Query().Get("code") != issued.Code || uri.Query().Get("server") != "https://cryospy.local:8081" || uri.Query().Get("ca") != "abcdef" {
+		t.Errorf("Unexpected pairing URI: %s", issued.URI)
+	}
+
+	// Codes are accepted regardless of case and separators
+	redeemed, err := service.Redeem(strings.ToLower(strings.ReplaceAll(issued.Code, "-", " ")))
+	if err != nil {
+		t.Fatalf("Failed to redeem pairing code: %v", err)
+	}
+	if *redeemed != credentials {
+		t.Errorf("Expected credentials %+v, got %+v", credentials, *redeemed)
+	}
+
+	if _, err := service.Redeem(issued.Code); !IsInvalidPairingCodeError(err) {
+		t.Errorf("Expected second redemption to fail, got %v", err)
+	}
+}
+
+func TestPairingService_RejectsExpiredAndSupersededCodes(t *testing.T) {
+	service, cleanup := setupTestPairingService(t)
+	defer cleanup()
+
+	first, err := service.CreateCode(Credentials{ClientID: "camera-1", ClientSecret: "01"}, "admin")
+	if err != nil {
+		t.Fatalf("Failed to create pairing code: %v", err)
+	}
+	second, err := service.CreateCode(Credentials{ClientID: "camera-1", ClientSecret: "02"}, "admin")
+	if err != nil {
+		t.Fatalf("Failed to create pairing code: %v", err)
+	}
+	if _, err := service.Redeem(first.Code); !IsInvalidPairingCodeError(err) {
+		t.Errorf("Expected superseded code to be rejected, got %v", err)
+	}
+
+	service.now = func() time.Time { return time.Now().Add(16 * time.Minute) }
+	if _, err := service.Redeem(second.Code); !IsInvalidPairingCodeError(err) {
+		t.Errorf("Expected expired code to be rejected, got %v", err)
+	}
+
+	if _, err := service.Redeem("AAAA-BBBB-CCCC"); !IsInvalidPairingCodeError(err) {
+		t.Errorf("Expected unknown code to be rejected, got %v", err)
+	}
+	if _, err := service.Redeem("short"); !IsInvalidPairingCodeError(err) {
+		t.Errorf("Expected malformed code to be rejected, got %v", err)
+	}
+}
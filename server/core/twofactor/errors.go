@@ -0,0 +1,49 @@
+package twofactor
+
+// Error types for two-factor authentication
+type InvalidCodeError struct{}
+
+type NotEnabledError struct{}
+
+type AlreadyEnabledError struct{}
+
+func (e *InvalidCodeError) Error() string {
+	return "invalid two-factor authentication code"
+}
+
+func (e *NotEnabledError) Error() string {
+	return "two-factor authentication is not enabled"
+}
+
+func (e *AlreadyEnabledError) Error() string {
+	return "two-factor authentication is already enabled"
+}
+
+// helper functions for error handling
+
+func IsInvalidCodeError(err error) bool {
+	_, ok := err.(*InvalidCodeError)
+	return ok
+}
+
+func IsNotEnabledError(err error) bool {
+	_, ok := err.(*NotEnabledError)
+	return ok
+}
+
+func IsAlreadyEnabledError(err error) bool {
+	_, ok := err.(*AlreadyEnabledError)
+	return ok
+}
+
+func NewInvalidCodeError() error {
+	return &InvalidCodeError{}
+}
+
+func NewNotEnabledError() error {
+	return &NotEnabledError{}
+}
+
+func NewAlreadyEnabledError() error {
+	return &AlreadyEnabledError{}
+}
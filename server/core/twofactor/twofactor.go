@@ -0,0 +1,30 @@
+package twofactor
+
+import "time"
+
+// TwoFactor holds the TOTP enrollment of a dashboard account
+type TwoFactor struct {
+	ID              string    // Unique identifier for the enrollment
+	UserID          string    // ID of the dashboard user, empty for the built-in admin account
+	EncryptedSecret string    // TOTP secret encrypted with the MEK (base 64 encoded)
+	LastUsedStep    int64     // Last accepted TOTP time step, used to reject replayed codes
+	CreatedAt       time.Time // Timestamp when 2FA was enabled
+	UpdatedAt       time.Time // Timestamp when the enrollment was last updated
+}
+
+// RecoveryCode is a single-use code that can replace a TOTP code, e.g. when the authenticator device is lost
+type RecoveryCode struct {
+	ID        string     // Unique identifier for the recovery code
+	UserID    string     // ID of the dashboard user the code belongs to
+	CodeHash  string     // Hashed recovery code (base 64 encoded)
+	CodeSalt  string     // Salt used for hashing the recovery code (base 64 encoded)
+	CreatedAt time.Time  // Timestamp when the recovery code was generated
+	UsedAt    *time.Time // Timestamp when the recovery code was used, nil if unused
+}
+
+// Enrollment is a pending TOTP enrollment that has not been confirmed yet
+type Enrollment struct {
+	Secret    string // Base32 TOTP secret for manual entry into an authenticator app
+	URL       string // otpauth:// URL encoded in the QR code
+	QRCodePNG []byte // PNG image of the QR code
+}
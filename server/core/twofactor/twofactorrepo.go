@@ -0,0 +1,225 @@
+package twofactor
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type TwoFactorRepository interface {
+	// Get retrieves the TOTP enrollment of a user. Returns nil if 2FA is not enabled for the user.
+	Get(userID string) (*TwoFactor, error)
+	// Save creates or replaces the TOTP enrollment of twoFactor.UserID together with its recovery codes
+	Save(twoFactor *TwoFactor, recoveryCodes []*RecoveryCode) error
+	// UpdateLastUsedStep records the last accepted TOTP time step
+	UpdateLastUsedStep(id string, step int64) error
+	// Delete removes the TOTP enrollment and all recovery codes of a user
+	Delete(userID string) error
+	// GetUnusedRecoveryCodes retrieves all recovery codes of a user that have not been used yet
+	GetUnusedRecoveryCodes(userID string) ([]*RecoveryCode, error)
+	// MarkRecoveryCodeUsed marks a recovery code as used
+	MarkRecoveryCodeUsed(code *RecoveryCode) error
+}
+
+// SQLiteTwoFactorRepository implements TwoFactorRepository using SQLite
+type SQLiteTwoFactorRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteTwoFactorRepository creates a new SQLite-based TwoFactorRepository
+func NewSQLiteTwoFactorRepository(db *sql.DB) (*SQLiteTwoFactorRepository, error) {
+	repo := &SQLiteTwoFactorRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLiteTwoFactorRepository) createTables() error {
+	createTwoFactorTable := `
+	CREATE TABLE IF NOT EXISTS two_factor (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL DEFAULT '',
+		encrypted_secret TEXT NOT NULL,
+		last_used_step INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);`
+
+	if _, err := r.db.Exec(createTwoFactorTable); err != nil {
+		return err
+	}
+
+	createRecoveryCodesTable := `
+	CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL DEFAULT '',
+		code_hash TEXT NOT NULL,
+		code_salt TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		used_at TEXT
+	);`
+
+	if _, err := r.db.Exec(createRecoveryCodesTable); err != nil {
+		return err
+	}
+
+	// Enrollments created before multiple dashboard users belong to the built-in admin account
+	db.AddColumn(r.db, "two_factor", "user_id", "TEXT NOT NULL DEFAULT ''")
+	db.AddColumn(r.db, "two_factor_recovery_codes", "user_id", "TEXT NOT NULL DEFAULT ''")
+
+	return nil
+}
+
+// Get retrieves the TOTP enrollment of a user
+// Returns nil if 2FA is not enabled for the user (this is not an error)
+func (r *SQLiteTwoFactorRepository) Get(userID string) (*TwoFactor, error) {
+	query := `
+	SELECT id, user_id, encrypted_secret, last_used_step, created_at, updated_at
+	FROM two_factor WHERE user_id = ? LIMIT 1`
+
+	twoFactor := &TwoFactor{}
+	var createdAtStr, updatedAtStr string
+	err := r.db.QueryRow(query, userID).Scan(
+		&twoFactor.ID, &twoFactor.UserID, &twoFactor.EncryptedSecret, &twoFactor.LastUsedStep, &createdAtStr, &updatedAtStr,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get two-factor enrollment: %w", err)
+	}
+
+	twoFactor.CreatedAt, err = db.StringToTime(createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+	}
+
+	twoFactor.UpdatedAt, err = db.StringToTime(updatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at timestamp: %w", err)
+	}
+
+	return twoFactor, nil
+}
+
+// Save creates or replaces the TOTP enrollment of twoFactor.UserID together with its recovery codes
+func (r *SQLiteTwoFactorRepository) Save(twoFactor *TwoFactor, recoveryCodes []*RecoveryCode) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// There is only ever one enrollment per user, so any previous one is replaced
+	if _, err := tx.Exec(`DELETE FROM two_factor WHERE user_id = ?`, twoFactor.UserID); err != nil {
+		return fmt.Errorf("failed to delete previous two-factor enrollment: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, twoFactor.UserID); err != nil {
+		return fmt.Errorf("failed to delete previous recovery codes: %w", err)
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO two_factor (id, user_id, encrypted_secret, last_used_step, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)`,
+		twoFactor.ID, twoFactor.UserID, twoFactor.EncryptedSecret, twoFactor.LastUsedStep,
+		db.TimeToString(twoFactor.CreatedAt), db.TimeToString(twoFactor.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create two-factor enrollment: %w", err)
+	}
+
+	for _, code := range recoveryCodes {
+		_, err := tx.Exec(`
+		INSERT INTO two_factor_recovery_codes (id, user_id, code_hash, code_salt, created_at, used_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+			code.ID, twoFactor.UserID, code.CodeHash, code.CodeSalt, db.TimeToString(code.CreatedAt), db.TimePtrToString(code.UsedAt),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateLastUsedStep records the last accepted TOTP time step
+func (r *SQLiteTwoFactorRepository) UpdateLastUsedStep(id string, step int64) error {
+	result, err := r.db.Exec(`UPDATE two_factor SET last_used_step = ? WHERE id = ?`, step, id)
+	if err != nil {
+		return fmt.Errorf("failed to update last used step: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("two-factor enrollment with ID %s not found", id)
+	}
+
+	return nil
+}
+
+// Delete removes the TOTP enrollment and all recovery codes of a user
+func (r *SQLiteTwoFactorRepository) Delete(userID string) error {
+	if _, err := r.db.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := r.db.Exec(`DELETE FROM two_factor WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete two-factor enrollment: %w", err)
+	}
+	return nil
+}
+
+// GetUnusedRecoveryCodes retrieves all recovery codes of a user that have not been used yet
+func (r *SQLiteTwoFactorRepository) GetUnusedRecoveryCodes(userID string) ([]*RecoveryCode, error) {
+	rows, err := r.db.Query(`
+	SELECT id, user_id, code_hash, code_salt, created_at
+	FROM two_factor_recovery_codes WHERE user_id = ? AND used_at IS NULL ORDER BY created_at ASC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recovery codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []*RecoveryCode
+	for rows.Next() {
+		code := &RecoveryCode{}
+		var createdAtStr string
+		if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash, &code.CodeSalt, &createdAtStr); err != nil {
+			return nil, fmt.Errorf("failed to scan recovery code row: %w", err)
+		}
+
+		code.CreatedAt, err = db.StringToTime(createdAtStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// MarkRecoveryCodeUsed marks a recovery code as used
+func (r *SQLiteTwoFactorRepository) MarkRecoveryCodeUsed(code *RecoveryCode) error {
+	result, err := r.db.Exec(`UPDATE two_factor_recovery_codes SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		db.TimePtrToString(code.UsedAt), code.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark recovery code as used: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("recovery code with ID %s not found or already used", code.ID)
+	}
+
+	return nil
+}
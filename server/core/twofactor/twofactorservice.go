@@ -0,0 +1,351 @@
+package twofactor
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/yeti47/cryospy/server/core/ccc/codes"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+const (
+	issuer             = "CryoSpy"
+	totpPeriod         = 30 // Seconds per TOTP time step
+	totpSkew           = 1  // Number of time steps accepted before and after the current one
+	qrCodeSize         = 256
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10 // Characters per recovery code, formatted as two groups of five
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Skew:      0,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+type TwoFactorService interface {
+	// IsEnabled reports whether TOTP 2FA is enabled for a dashboard user
+	IsEnabled(userID string) (bool, error)
+	// BeginEnrollment generates a new TOTP secret and QR code. Nothing is stored until the enrollment is confirmed.
+	BeginEnrollment(accountName string) (*Enrollment, error)
+	// EnrollmentForSecret rebuilds a pending enrollment for a secret, e.g. after a wrong confirmation code
+	EnrollmentForSecret(accountName, secret string) (*Enrollment, error)
+	// ConfirmEnrollment verifies a code for the pending secret, stores the secret encrypted with the MEK
+	// and returns newly generated recovery codes. The recovery codes are not stored in plain text.
+	ConfirmEnrollment(userID string, mek []byte, secret, code string) (recoveryCodes []string, err error)
+	// Verify checks a TOTP code or an unused recovery code. A recovery code can only be used once.
+	Verify(userID string, mek []byte, code string) error
+	// Disable turns off 2FA (requires a valid TOTP or recovery code)
+	Disable(userID string, mek []byte, code string) error
+	// Remove turns off 2FA without a code, e.g. when the dashboard user is deleted
+	Remove(userID string) error
+	// GetRemainingRecoveryCodeCount returns the number of unused recovery codes
+	GetRemainingRecoveryCodeCount(userID string) (int, error)
+}
+
+type twoFactorService struct {
+	logger    logging.Logger
+	repo      TwoFactorRepository
+	encryptor encryption.Encryptor
+	now       func() time.Time
+}
+
+func NewTwoFactorService(logger logging.Logger, repo TwoFactorRepository, encryptor encryption.Encryptor) *twoFactorService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &twoFactorService{
+		logger:    logger,
+		repo:      repo,
+		encryptor: encryptor,
+		now:       func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (s *twoFactorService) IsEnabled(userID string) (bool, error) {
+	twoFactor, err := s.repo.Get(userID)
+	if err != nil {
+		s.logger.Error("Failed to get two-factor enrollment", err)
+		return false, err
+	}
+	return twoFactor != nil, nil
+}
+
+func (s *twoFactorService) BeginEnrollment(accountName string) (*Enrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Period:      totpPeriod,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+	if err != nil {
+		s.logger.Error("Failed to generate TOTP secret", err)
+		return nil, err
+	}
+
+	return s.enrollmentFromKey(key)
+}
+
+func (s *twoFactorService) EnrollmentForSecret(accountName, secret string) (*Enrollment, error) {
+	rawSecret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return nil, NewInvalidCodeError()
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Period:      totpPeriod,
+		Secret:      rawSecret,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+	if err != nil {
+		s.logger.Error("Failed to rebuild TOTP key", err)
+		return nil, err
+	}
+
+	return s.enrollmentFromKey(key)
+}
+
+func (s *twoFactorService) ConfirmEnrollment(userID string, mek []byte, secret, code string) ([]string, error) {
+	existing, err := s.repo.Get(userID)
+	if err != nil {
+		s.logger.Error("Failed to get two-factor enrollment", err)
+		return nil, err
+	}
+	if existing != nil {
+		return nil, NewAlreadyEnabledError()
+	}
+
+	step, ok := s.matchTotpCode(secret, code, 0)
+	if !ok {
+		return nil, NewInvalidCodeError()
+	}
+
+	encryptedSecret, err := s.encryptor.Encrypt([]byte(secret), mek)
+	if err != nil {
+		s.logger.Error("Failed to encrypt TOTP secret", err)
+		return nil, err
+	}
+
+	now := s.now()
+	twoFactor := &TwoFactor{
+		ID:              uuid.New().String(),
+		UserID:          userID,
+		EncryptedSecret: base64.StdEncoding.EncodeToString(encryptedSecret),
+		LastUsedStep:    step,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	plainCodes, recoveryCodes, err := s.generateRecoveryCodes(userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Save(twoFactor, recoveryCodes); err != nil {
+		s.logger.Error("Failed to save two-factor enrollment", err)
+		return nil, err
+	}
+
+	s.logger.Info("Two-factor authentication enabled", "userId", userID)
+	return plainCodes, nil
+}
+
+func (s *twoFactorService) Verify(userID string, mek []byte, code string) error {
+	twoFactor, err := s.repo.Get(userID)
+	if err != nil {
+		s.logger.Error("Failed to get two-factor enrollment", err)
+		return err
+	}
+	if twoFactor == nil {
+		return NewNotEnabledError()
+	}
+
+	code = strings.TrimSpace(code)
+
+	// A six-digit code is a TOTP code; anything else can only be a recovery code
+	if len(code) == int(totpOpts.Digits) {
+		secret, err := s.decryptSecret(twoFactor, mek)
+		if err != nil {
+			return err
+		}
+
+		step, ok := s.matchTotpCode(secret, code, twoFactor.LastUsedStep)
+		if !ok {
+			return NewInvalidCodeError()
+		}
+
+		if err := s.repo.UpdateLastUsedStep(twoFactor.ID, step); err != nil {
+			s.logger.Error("Failed to record used TOTP step", err)
+			return err
+		}
+		return nil
+	}
+
+	return s.useRecoveryCode(userID, code)
+}
+
+func (s *twoFactorService) Disable(userID string, mek []byte, code string) error {
+	if err := s.Verify(userID, mek, code); err != nil {
+		return err
+	}
+
+	return s.Remove(userID)
+}
+
+func (s *twoFactorService) Remove(userID string) error {
+	if err := s.repo.Delete(userID); err != nil {
+		s.logger.Error("Failed to delete two-factor enrollment", err)
+		return err
+	}
+
+	s.logger.Info("Two-factor authentication disabled", "userId", userID)
+	return nil
+}
+
+func (s *twoFactorService) GetRemainingRecoveryCodeCount(userID string) (int, error) {
+	recoveryCodes, err := s.repo.GetUnusedRecoveryCodes(userID)
+	if err != nil {
+		s.logger.Error("Failed to get recovery codes", err)
+		return 0, err
+	}
+	return len(recoveryCodes), nil
+}
+
+func (s *twoFactorService) enrollmentFromKey(key *otp.Key) (*Enrollment, error) {
+	image, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		s.logger.Error("Failed to render TOTP QR code", err)
+		return nil, err
+	}
+
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		s.logger.Error("Failed to encode TOTP QR code", err)
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret:    key.Secret(),
+		URL:       key.URL(),
+		QRCodePNG: qrCode.Bytes(),
+	}, nil
+}
+
+// matchTotpCode checks a code against the time steps around now and returns the matching step.
+// Steps up to and including lastUsedStep are rejected so that a code cannot be replayed.
+func (s *twoFactorService) matchTotpCode(secret, code string, lastUsedStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	currentStep := s.now().Unix() / totpPeriod
+
+	for step := currentStep - totpSkew; step <= currentStep+totpSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpOpts)
+		if err != nil {
+			s.logger.Warn("Failed to generate TOTP code", "error", err)
+			return 0, false
+		}
+		if expected == code {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *twoFactorService) decryptSecret(twoFactor *TwoFactor, mek []byte) (string, error) {
+	encryptedSecret, err := base64.StdEncoding.DecodeString(twoFactor.EncryptedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := s.encryptor.Decrypt(encryptedSecret, mek)
+	if err != nil {
+		s.logger.Error("Failed to decrypt TOTP secret", err)
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+func (s *twoFactorService) useRecoveryCode(userID, code string) error {
+	normalized := codes.Normalize(code)
+	if normalized == "" {
+		return NewInvalidCodeError()
+	}
+
+	recoveryCodes, err := s.repo.GetUnusedRecoveryCodes(userID)
+	if err != nil {
+		s.logger.Error("Failed to get recovery codes", err)
+		return err
+	}
+
+	for _, recoveryCode := range recoveryCodes {
+		hash, err := base64.StdEncoding.DecodeString(recoveryCode.CodeHash)
+		if err != nil {
+			return err
+		}
+		salt, err := base64.StdEncoding.DecodeString(recoveryCode.CodeSalt)
+		if err != nil {
+			return err
+		}
+
+		if s.encryptor.CompareHash(hash, []byte(normalized), salt) {
+			usedAt := s.now()
+			recoveryCode.UsedAt = &usedAt
+			if err := s.repo.MarkRecoveryCodeUsed(recoveryCode); err != nil {
+				s.logger.Error("Failed to mark recovery code as used", err)
+				return err
+			}
+			s.logger.Info("Two-factor recovery code used", "remaining", len(recoveryCodes)-1)
+			return nil
+		}
+	}
+
+	return NewInvalidCodeError()
+}
+
+func (s *twoFactorService) generateRecoveryCodes(userID string, now time.Time) ([]string, []*RecoveryCode, error) {
+	plainCodes := make([]string, 0, recoveryCodeCount)
+	recoveryCodes := make([]*RecoveryCode, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		code, err := codes.Generate(recoveryCodeLength, recoveryCodeLength/2)
+		if err != nil {
+			s.logger.Error("Failed to generate recovery code", err)
+			return nil, nil, err
+		}
+
+		hash, salt, err := s.encryptor.Hash([]byte(codes.Normalize(code)))
+		if err != nil {
+			s.logger.Error("Failed to hash recovery code", err)
+			return nil, nil, err
+		}
+
+		plainCodes = append(plainCodes, code)
+		recoveryCodes = append(recoveryCodes, &RecoveryCode{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			CodeHash:  base64.StdEncoding.EncodeToString(hash),
+			CodeSalt:  base64.StdEncoding.EncodeToString(salt),
+			CreatedAt: now,
+		})
+	}
+
+	return plainCodes, recoveryCodes, nil
+}
@@ -0,0 +1,215 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func setupTestTwoFactorService(t *testing.T) (*twoFactorService, []byte, func()) {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+
+	repo, err := NewSQLiteTwoFactorRepository(testDB)
+	if err != nil {
+		testDB.Close()
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	mek, err := encryptor.GenerateKey()
+	if err != nil {
+		testDB.Close()
+		t.Fatalf("Failed to generate MEK: %v", err)
+	}
+
+	service := NewTwoFactorService(nil, repo, encryptor)
+	return service, mek, func() { testDB.Close() }
+}
+
+func enroll(t *testing.T, service *twoFactorService, mek []byte) (string, []string) {
+	return enrollUser(t, service, "", mek)
+}
+
+func enrollUser(t *testing.T, service *twoFactorService, userID string, mek []byte) (string, []string) {
+	enrollment, err := service.BeginEnrollment("admin")
+	if err != nil {
+		t.Fatalf("Failed to begin enrollment: %v", err)
+	}
+	if len(enrollment.QRCodePNG) == 0 || enrollment.URL == "" {
+		t.Fatal("Expected a QR code and otpauth URL")
+	}
+
+	code, err := totp.GenerateCodeCustom(enrollment.Secret, service.now(), totpOpts)
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	recoveryCodes, err := service.ConfirmEnrollment(userID, mek, enrollment.Secret, code)
+	if err != nil {
+		t.Fatalf("Failed to confirm enrollment: %v", err)
+	}
+	return enrollment.Secret, recoveryCodes
+}
+
+func TestTwoFactorService_EnrollAndVerify(t *testing.T) {
+	service, mek, cleanup := setupTestTwoFactorService(t)
+	defer cleanup()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	if enabled, _ := service.IsEnabled(""); enabled {
+		t.Fatal("Expected 2FA to be disabled initially")
+	}
+
+	secret, recoveryCodes := enroll(t, service, mek)
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(recoveryCodes))
+	}
+	if enabled, _ := service.IsEnabled(""); !enabled {
+		t.Fatal("Expected 2FA to be enabled")
+	}
+
+	// The code used for enrollment cannot be replayed
+	code, _ := totp.GenerateCodeCustom(secret, now, totpOpts)
+	if err := service.Verify("", mek, code); !IsInvalidCodeError(err) {
+		t.Errorf("Expected replayed code to be rejected, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	code, _ = totp.GenerateCodeCustom(secret, now, totpOpts)
+	if err := service.Verify("", mek, code); err != nil {
+		t.Errorf("Expected current code to be accepted, got %v", err)
+	}
+
+	if err := service.Verify("", mek, "000000"); !IsInvalidCodeError(err) {
+		t.Errorf("Expected wrong code to be rejected, got %v", err)
+	}
+}
+
+func TestTwoFactorService_RecoveryCodes(t *testing.T) {
+	service, mek, cleanup := setupTestTwoFactorService(t)
+	defer cleanup()
+
+	_, recoveryCodes := enroll(t, service, mek)
+
+	// Recovery codes are accepted regardless of case and separators, but only once
+	if err := service.Verify("", mek, " "+lowerNoDash(recoveryCodes[0])+" "); err != nil {
+		t.Fatalf("Expected recovery code to be accepted, got %v", err)
+	}
+	if err := service.Verify("", mek, recoveryCodes[0]); !IsInvalidCodeError(err) {
+		t.Errorf("Expected used recovery code to be rejected, got %v", err)
+	}
+
+	remaining, err := service.GetRemainingRecoveryCodeCount("")
+	if err != nil {
+		t.Fatalf("Failed to count recovery codes: %v", err)
+	}
+	if remaining != recoveryCodeCount-1 {
+		t.Errorf("Expected %d remaining recovery codes, got %d", recoveryCodeCount-1, remaining)
+	}
+}
+
+func TestTwoFactorService_Disable(t *testing.T) {
+	service, mek, cleanup := setupTestTwoFactorService(t)
+	defer cleanup()
+
+	_, recoveryCodes := enroll(t, service, mek)
+
+	if err := service.Disable("", mek, "wrong-code"); !IsInvalidCodeError(err) {
+		t.Errorf("Expected disable with wrong code to fail, got %v", err)
+	}
+
+	if err := service.Disable("", mek, recoveryCodes[1]); err != nil {
+		t.Fatalf("Failed to disable 2FA: %v", err)
+	}
+
+	if enabled, _ := service.IsEnabled(""); enabled {
+		t.Error("Expected 2FA to be disabled")
+	}
+	if err := service.Verify("", mek, recoveryCodes[2]); !IsNotEnabledError(err) {
+		t.Errorf("Expected NotEnabledError, got %v", err)
+	}
+}
+
+func TestTwoFactorService_SecretRequiresMek(t *testing.T) {
+	service, mek, cleanup := setupTestTwoFactorService(t)
+	defer cleanup()
+
+	secret, _ := enroll(t, service, mek)
+
+	otherMek, _ := encryption.NewAESEncryptor().GenerateKey()
+	code, _ := totp.GenerateCodeCustom(secret, service.now().Add(time.Minute), totpOpts)
+	service.now = func() time.Time { return time.Now().UTC().Add(time.Minute) }
+
+	if err := service.Verify("", otherMek, code); err == nil {
+		t.Error("Expected verification with a different MEK to fail")
+	}
+}
+
+func TestTwoFactorService_PerUser(t *testing.T) {
+	service, mek, cleanup := setupTestTwoFactorService(t)
+	defer cleanup()
+
+	_, adminCodes := enroll(t, service, mek)
+	_, userCodes := enrollUser(t, service, "user-1", mek)
+
+	// Recovery codes of one account cannot be used for another
+	if err := service.Verify("user-1", mek, adminCodes[0]); !IsInvalidCodeError(err) {
+		t.Errorf("Expected recovery code of another account to be rejected, got %v", err)
+	}
+	if err := service.Verify("user-1", mek, userCodes[0]); err != nil {
+		t.Errorf("Expected own recovery code to be accepted, got %v", err)
+	}
+
+	if err := service.Remove("user-1"); err != nil {
+		t.Fatalf("Failed to remove 2FA: %v", err)
+	}
+	if enabled, _ := service.IsEnabled("user-1"); enabled {
+		t.Error("Expected 2FA to be removed for the user")
+	}
+	if enabled, _ := service.IsEnabled(""); !enabled {
+		t.Error("Expected 2FA of the admin account to be unaffected")
+	}
+	if enabled, _ := service.IsEnabled("user-2"); enabled {
+		t.Error("Expected 2FA to be disabled for a user without enrollment")
+	}
+}
+
+func lowerNoDash(code string) string {
+	result := make([]rune, 0, len(code))
+	for _, r := range code {
+		if r == '-' {
+			continue
+		}
+		if r >= 'A' && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		result = append(result, r)
+	}
+	return string(result)
+}
+
+func TestTwoFactorService_EnrollmentForSecret(t *testing.T) {
+	service, _, cleanup := setupTestTwoFactorService(t)
+	defer cleanup()
+
+	enrollment, err := service.BeginEnrollment("admin")
+	if err != nil {
+		t.Fatalf("Failed to begin enrollment: %v", err)
+	}
+
+	rebuilt, err := service.EnrollmentForSecret("admin", enrollment.Secret)
+	if err != nil {
+		t.Fatalf("Failed to rebuild enrollment: %v", err)
+	}
+	if rebuilt.Secret != enrollment.Secret || rebuilt.URL != enrollment.URL {
+		t.Errorf("Expected rebuilt enrollment to match, got %q / %q", rebuilt.URL, enrollment.URL)
+	}
+}
@@ -0,0 +1,72 @@
+package users
+
+// Error types for user operations
+type UserNotFoundError struct {
+	ID string
+}
+
+type UserAlreadyExistsError struct {
+	Username string
+}
+
+type InvalidCredentialsError struct{}
+
+type UserValidationError struct {
+	Message string
+}
+
+func (e *UserNotFoundError) Error() string {
+	return "User not found: " + e.ID
+}
+
+func (e *UserAlreadyExistsError) Error() string {
+	return "User already exists: " + e.Username
+}
+
+func (e *InvalidCredentialsError) Error() string {
+	return "Invalid username or password"
+}
+
+func (e *UserValidationError) Error() string {
+	return "User validation failed: " + e.Message
+}
+
+// helper functions for error handling
+
+func IsUserNotFoundError(err error) bool {
+	_, ok := err.(*UserNotFoundError)
+	return ok
+}
+
+func IsUserAlreadyExistsError(err error) bool {
+	_, ok := err.(*UserAlreadyExistsError)
+	return ok
+}
+
+func IsInvalidCredentialsError(err error) bool {
+	_, ok := err.(*InvalidCredentialsError)
+	return ok
+}
+
+func IsUserValidationError(err error) bool {
+	_, ok := err.(*UserValidationError)
+	return ok
+}
+
+// helper functions to create errors
+
+func NewUserNotFoundError(id string) error {
+	return &UserNotFoundError{ID: id}
+}
+
+func NewUserAlreadyExistsError(username string) error {
+	return &UserAlreadyExistsError{Username: username}
+}
+
+func NewInvalidCredentialsError() error {
+	return &InvalidCredentialsError{}
+}
+
+func NewUserValidationError(message string) error {
+	return &UserValidationError{Message: message}
+}
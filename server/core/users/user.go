@@ -0,0 +1,48 @@
+package users
+
+import (
+	"slices"
+	"time"
+)
+
+// Role controls what a dashboard user is allowed to do
+type Role string
+
+const (
+	// RoleViewer can watch clips and live streams
+	RoleViewer Role = "viewer"
+	// RoleOperator can additionally delete clips and change client settings
+	RoleOperator Role = "operator"
+	// RoleAdmin can additionally manage clients, users and keys
+	RoleAdmin Role = "admin"
+)
+
+// Roles lists all roles from least to most privileged
+var Roles = []Role{RoleViewer, RoleOperator, RoleAdmin}
+
+// AdminUsername is the name of the built-in admin account that is unlocked by the primary MEK password,
+// an additional password slot or a recovery key. It cannot be used for other users.
+const AdminUsername = "admin"
+
+// IsValid reports whether the role is known
+func (r Role) IsValid() bool {
+	return slices.Contains(Roles, r)
+}
+
+// Allows reports whether the role grants at least the permissions of the required role
+func (r Role) Allows(required Role) bool {
+	rank := slices.Index(Roles, r)
+	return rank >= 0 && rank >= slices.Index(Roles, required)
+}
+
+// User is a dashboard account with its own password wrap of the MEK
+type User struct {
+	ID                     string    // Unique identifier for the user
+	Username               string    // Login name, unique regardless of case
+	Role                   Role      // Permissions of the user
+	MekID                  string    // ID of the MEK this user's wrap unlocks
+	EncryptedEncryptionKey string    // MEK encrypted with key derived from the user's password (base 64 encoded)
+	EncryptionKeySalt      string    // Salt used for deriving the encryption key (base 64 encoded)
+	CreatedAt              time.Time // Timestamp when the user was created
+	UpdatedAt              time.Time // Timestamp when the user was last updated
+}
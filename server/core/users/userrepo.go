@@ -0,0 +1,201 @@
+package users
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type UserRepository interface {
+	// GetByID retrieves a user by ID. Returns nil if the user does not exist.
+	GetByID(id string) (*User, error)
+	// GetByUsername retrieves a user by username, ignoring case. Returns nil if the user does not exist.
+	GetByUsername(username string) (*User, error)
+	// GetAll retrieves all users ordered by username
+	GetAll() ([]*User, error)
+	// Create adds a new user
+	Create(user *User) error
+	// Update updates the role and password wrap of a user
+	Update(user *User) error
+	// Delete removes a user together with its password wrap
+	Delete(id string) error
+}
+
+// SQLiteUserRepository implements UserRepository using SQLite
+type SQLiteUserRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteUserRepository creates a new SQLite-based UserRepository
+func NewSQLiteUserRepository(db *sql.DB) (*SQLiteUserRepository, error) {
+	repo := &SQLiteUserRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLiteUserRepository) createTables() error {
+	createUsersTable := `
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		role TEXT NOT NULL,
+		mek_id TEXT NOT NULL,
+		encrypted_encryption_key TEXT NOT NULL,
+		encryption_key_salt TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);`
+
+	_, err := r.db.Exec(createUsersTable)
+	return err
+}
+
+const userColumns = `id, username, role, mek_id, encrypted_encryption_key, encryption_key_salt, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	user := &User{}
+	var role, createdAtStr, updatedAtStr string
+
+	err := row.Scan(
+		&user.ID, &user.Username, &role, &user.MekID,
+		&user.EncryptedEncryptionKey, &user.EncryptionKeySalt,
+		&createdAtStr, &updatedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = Role(role)
+
+	user.CreatedAt, err = db.StringToTime(createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+	}
+
+	user.UpdatedAt, err = db.StringToTime(updatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at timestamp: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetByID retrieves a user by ID
+// Returns nil if the user does not exist (this is not an error)
+func (r *SQLiteUserRepository) GetByID(id string) (*User, error) {
+	row := r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetByUsername retrieves a user by username, ignoring case
+// Returns nil if the user does not exist (this is not an error)
+func (r *SQLiteUserRepository) GetByUsername(username string) (*User, error) {
+	row := r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, username)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetAll retrieves all users ordered by username
+func (r *SQLiteUserRepository) GetAll() ([]*User, error) {
+	rows, err := r.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY username ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// Create adds a new user
+func (r *SQLiteUserRepository) Create(user *User) error {
+	_, err := r.db.Exec(`
+	INSERT INTO users (`+userColumns+`)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, string(user.Role), user.MekID,
+		user.EncryptedEncryptionKey, user.EncryptionKeySalt,
+		db.TimeToString(user.CreatedAt), db.TimeToString(user.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// Update updates the role and password wrap of a user
+func (r *SQLiteUserRepository) Update(user *User) error {
+	result, err := r.db.Exec(`
+	UPDATE users
+	SET role = ?, mek_id = ?, encrypted_encryption_key = ?, encryption_key_salt = ?, updated_at = ?
+	WHERE id = ?`,
+		string(user.Role), user.MekID, user.EncryptedEncryptionKey, user.EncryptionKeySalt,
+		db.TimeToString(user.UpdatedAt), user.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user with ID %s not found", user.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a user together with its password wrap
+func (r *SQLiteUserRepository) Delete(id string) error {
+	result, err := r.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user with ID %s not found", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package users
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+const maxUsernameLength = 64
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+type CreateUserRequest struct {
+	Username string
+	Password string
+	Role     Role
+}
+
+type UserService interface {
+	// GetUser retrieves a user by ID. Returns nil if the user does not exist.
+	GetUser(id string) (*User, error)
+	// GetUsers retrieves all users
+	GetUsers() ([]*User, error)
+	// CreateUser creates a user whose password wraps the given (already unlocked) MEK
+	CreateUser(mek []byte, req CreateUserRequest) (*User, error)
+	// Authenticate unlocks the MEK with the password of a user and returns both
+	Authenticate(username, password string) (*User, []byte, error)
+	// UpdateRole changes the role of a user
+	UpdateRole(id string, role Role) (*User, error)
+	// ChangePassword re-wraps the MEK with a new password (requires the current password)
+	ChangePassword(id, currentPassword, newPassword string) error
+	// ResetPassword re-wraps the given (already unlocked) MEK with a new password, e.g. when an admin resets it
+	ResetPassword(mek []byte, id, newPassword string) error
+	// DeleteUser removes a user and its password wrap. The user can no longer unlock the MEK afterwards.
+	DeleteUser(id string) error
+}
+
+type userService struct {
+	logger     logging.Logger
+	repo       UserRepository
+	mekService encryption.MekService
+	encryptor  encryption.Encryptor
+}
+
+func NewUserService(logger logging.Logger, repo UserRepository, mekService encryption.MekService, encryptor encryption.Encryptor) *userService {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &userService{
+		logger:     logger,
+		repo:       repo,
+		mekService: mekService,
+		encryptor:  encryptor,
+	}
+}
+
+func (s *userService) GetUser(id string) (*User, error) {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve user", err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *userService) GetUsers() ([]*User, error) {
+	users, err := s.repo.GetAll()
+	if err != nil {
+		s.logger.Error("Failed to retrieve users", err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (s *userService) CreateUser(mek []byte, req CreateUserRequest) (*User, error) {
+	username := strings.TrimSpace(req.Username)
+	s.logger.Info("Creating dashboard user", "username", username, "role", req.Role)
+
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if !req.Role.IsValid() {
+		return nil, NewUserValidationError("unknown role: " + string(req.Role))
+	}
+	if req.Password == "" {
+		return nil, NewUserValidationError("password cannot be empty")
+	}
+
+	existing, err := s.repo.GetByUsername(username)
+	if err != nil {
+		s.logger.Error("Failed to check for existing user", err)
+		return nil, err
+	}
+	if existing != nil {
+		return nil, NewUserAlreadyExistsError(username)
+	}
+
+	storedMek, err := s.mekService.GetMek()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	user := &User{
+		ID:        uuid.NewString(),
+		Username:  username,
+		Role:      req.Role,
+		MekID:     storedMek.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.wrapMek(user, mek, req.Password); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(user); err != nil {
+		s.logger.Error("Failed to create user in repository", err)
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	s.logger.Info("Dashboard user created", "userId", user.ID, "username", user.Username)
+	return user, nil
+}
+
+func (s *userService) Authenticate(username, password string) (*User, []byte, error) {
+	user, err := s.repo.GetByUsername(strings.TrimSpace(username))
+	if err != nil {
+		s.logger.Error("Failed to retrieve user for authentication", err)
+		return nil, nil, err
+	}
+
+	if user == nil {
+		// Derive a key anyway so that unknown usernames cannot be told apart by response time
+		if salt, err := s.encryptor.GenerateSalt(); err == nil {
+			s.encryptor.DeriveKeyFromSecret([]byte(password), salt)
+		}
+		return nil, nil, NewInvalidCredentialsError()
+	}
+
+	mekValue, err := s.unwrapMek(user, password)
+	if err != nil {
+		return nil, nil, NewInvalidCredentialsError()
+	}
+
+	return user, mekValue, nil
+}
+
+func (s *userService) UpdateRole(id string, role Role) (*User, error) {
+	if !role.IsValid() {
+		return nil, NewUserValidationError("unknown role: " + string(role))
+	}
+
+	user, err := s.getExistingUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = role
+	user.UpdatedAt = time.Now().UTC()
+
+	if err := s.repo.Update(user); err != nil {
+		s.logger.Error("Failed to update user role", err)
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	s.logger.Info("Dashboard user role changed", "userId", id, "role", role)
+	return user, nil
+}
+
+func (s *userService) ChangePassword(id, currentPassword, newPassword string) error {
+	user, err := s.getExistingUser(id)
+	if err != nil {
+		return err
+	}
+
+	mekValue, err := s.unwrapMek(user, currentPassword)
+	if err != nil {
+		return NewInvalidCredentialsError()
+	}
+
+	return s.rewrap(user, mekValue, newPassword)
+}
+
+func (s *userService) ResetPassword(mek []byte, id, newPassword string) error {
+	user, err := s.getExistingUser(id)
+	if err != nil {
+		return err
+	}
+
+	return s.rewrap(user, mek, newPassword)
+}
+
+func (s *userService) DeleteUser(id string) error {
+	if _, err := s.getExistingUser(id); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		s.logger.Error("Failed to delete user from repository", err)
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	s.logger.Info("Dashboard user deleted", "userId", id)
+	return nil
+}
+
+func (s *userService) getExistingUser(id string) (*User, error) {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve user", err)
+		return nil, err
+	}
+	if user == nil {
+		return nil, NewUserNotFoundError(id)
+	}
+	return user, nil
+}
+
+func (s *userService) rewrap(user *User, mek []byte, newPassword string) error {
+	if newPassword == "" {
+		return NewUserValidationError("password cannot be empty")
+	}
+
+	if err := s.wrapMek(user, mek, newPassword); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now().UTC()
+
+	if err := s.repo.Update(user); err != nil {
+		s.logger.Error("Failed to update user password", err)
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	s.logger.Info("Dashboard user password changed", "userId", user.ID)
+	return nil
+}
+
+// wrapMek encrypts the MEK with a key derived from the password and stores the result on the user
+func (s *userService) wrapMek(user *User, mek []byte, password string) error {
+	salt, err := s.encryptor.GenerateSalt()
+	if err != nil {
+		s.logger.Error("Failed to generate salt for user MEK wrap", err)
+		return err
+	}
+
+	key, err := s.encryptor.DeriveKeyFromSecret([]byte(password), salt)
+	if err != nil {
+		s.logger.Error("Failed to derive key for user MEK wrap", err)
+		return err
+	}
+
+	encryptedKey, err := s.encryptor.Encrypt(mek, key)
+	if err != nil {
+		s.logger.Error("Failed to encrypt MEK for user", err)
+		return err
+	}
+
+	user.EncryptedEncryptionKey = base64.StdEncoding.EncodeToString(encryptedKey)
+	user.EncryptionKeySalt = base64.StdEncoding.EncodeToString(salt)
+	return nil
+}
+
+func (s *userService) unwrapMek(user *User, password string) ([]byte, error) {
+	return encryption.DecryptMek(&encryption.Mek{
+		ID:                     user.MekID,
+		EncryptedEncryptionKey: user.EncryptedEncryptionKey,
+		EncryptionKeySalt:      user.EncryptionKeySalt,
+	}, password, s.encryptor)
+}
+
+func validateUsername(username string) error {
+	if username == "" {
+		return NewUserValidationError("username cannot be empty")
+	}
+	if len(username) > maxUsernameLength {
+		return NewUserValidationError(fmt.Sprintf("username cannot be longer than %d characters", maxUsernameLength))
+	}
+	if !usernamePattern.MatchString(username) {
+		return NewUserValidationError("username may only contain letters, digits, dots, dashes and underscores")
+	}
+	if strings.EqualFold(username, AdminUsername) {
+		return NewUserValidationError("username is reserved for the built-in admin account")
+	}
+	return nil
+}
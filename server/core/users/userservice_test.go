@@ -0,0 +1,180 @@
+package users
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func setupTestUserService(t *testing.T) (*userService, []byte, func()) {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	mekRepo, err := encryption.NewSQLiteMekRepository(testDB)
+	if err != nil {
+		testDB.Close()
+		t.Fatalf("NewSQLiteMekRepository() failed: %v", err)
+	}
+	userRepo, err := NewSQLiteUserRepository(testDB)
+	if err != nil {
+		testDB.Close()
+		t.Fatalf("NewSQLiteUserRepository() failed: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	mekService := encryption.NewMekService(logging.NopLogger, mekRepo, encryptor)
+	if _, err := mekService.CreateMek("admin-password"); err != nil {
+		testDB.Close()
+		t.Fatalf("CreateMek() failed: %v", err)
+	}
+	mek, err := mekService.UnlockMek("admin-password")
+	if err != nil {
+		testDB.Close()
+		t.Fatalf("UnlockMek() failed: %v", err)
+	}
+
+	return NewUserService(logging.NopLogger, userRepo, mekService, encryptor), mek, func() { testDB.Close() }
+}
+
+func TestRole_Allows(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		expected bool
+	}{
+		{RoleAdmin, RoleViewer, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RoleOperator, RoleViewer, true},
+		{RoleOperator, RoleAdmin, false},
+		{RoleViewer, RoleOperator, false},
+		{Role("unknown"), RoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.expected {
+			t.Errorf("%s.Allows(%s) = %v, expected %v", tt.role, tt.required, got, tt.expected)
+		}
+	}
+}
+
+func TestUserService_CreateAndAuthenticate(t *testing.T) {
+	service, mek, cleanup := setupTestUserService(t)
+	defer cleanup()
+
+	user, err := service.CreateUser(mek, CreateUserRequest{Username: "alice", Password: "alice-password", Role: RoleViewer})
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	// Usernames are matched regardless of case
+	authenticated, unlocked, err := service.Authenticate("Alice", "alice-password")
+	if err != nil {
+		t.Fatalf("Authenticate() failed: %v", err)
+	}
+	if authenticated.ID != user.ID || authenticated.Role != RoleViewer {
+		t.Errorf("Authenticate() returned unexpected user %+v", authenticated)
+	}
+	if !bytes.Equal(unlocked, mek) {
+		t.Error("Authenticate() returned a different MEK value")
+	}
+
+	if _, _, err := service.Authenticate("alice", "wrong-password"); !IsInvalidCredentialsError(err) {
+		t.Errorf("Expected InvalidCredentialsError for wrong password, got %v", err)
+	}
+	if _, _, err := service.Authenticate("bob", "alice-password"); !IsInvalidCredentialsError(err) {
+		t.Errorf("Expected InvalidCredentialsError for unknown user, got %v", err)
+	}
+}
+
+func TestUserService_CreateUser_Validation(t *testing.T) {
+	service, mek, cleanup := setupTestUserService(t)
+	defer cleanup()
+
+	if _, err := service.CreateUser(mek, CreateUserRequest{Username: "alice", Password: "pw", Role: RoleViewer}); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  CreateUserRequest
+	}{
+		{"reserved admin name", CreateUserRequest{Username: "Admin", Password: "pw", Role: RoleAdmin}},
+		{"invalid characters", CreateUserRequest{Username: "al ice", Password: "pw", Role: RoleViewer}},
+		{"unknown role", CreateUserRequest{Username: "bob", Password: "pw", Role: Role("owner")}},
+		{"empty password", CreateUserRequest{Username: "bob", Password: "", Role: RoleViewer}},
+	}
+
+	for _, tt := range tests {
+		if _, err := service.CreateUser(mek, tt.req); !IsUserValidationError(err) {
+			t.Errorf("%s: expected UserValidationError, got %v", tt.name, err)
+		}
+	}
+
+	if _, err := service.CreateUser(mek, CreateUserRequest{Username: "ALICE", Password: "pw", Role: RoleViewer}); !IsUserAlreadyExistsError(err) {
+		t.Errorf("Expected UserAlreadyExistsError for duplicate username, got %v", err)
+	}
+}
+
+func TestUserService_ChangeAndResetPassword(t *testing.T) {
+	service, mek, cleanup := setupTestUserService(t)
+	defer cleanup()
+
+	user, err := service.CreateUser(mek, CreateUserRequest{Username: "alice", Password: "old-password", Role: RoleOperator})
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	if err := service.ChangePassword(user.ID, "wrong-password", "new-password"); !IsInvalidCredentialsError(err) {
+		t.Errorf("Expected InvalidCredentialsError, got %v", err)
+	}
+	if err := service.ChangePassword(user.ID, "old-password", "new-password"); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if _, _, err := service.Authenticate("alice", "old-password"); !IsInvalidCredentialsError(err) {
+		t.Error("Expected old password to be rejected after change")
+	}
+
+	if err := service.ResetPassword(mek, user.ID, "reset-password"); err != nil {
+		t.Fatalf("ResetPassword() failed: %v", err)
+	}
+	_, unlocked, err := service.Authenticate("alice", "reset-password")
+	if err != nil {
+		t.Fatalf("Authenticate() after reset failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, mek) {
+		t.Error("Reset password unlocked a different MEK value")
+	}
+}
+
+func TestUserService_UpdateRoleAndDelete(t *testing.T) {
+	service, mek, cleanup := setupTestUserService(t)
+	defer cleanup()
+
+	user, err := service.CreateUser(mek, CreateUserRequest{Username: "alice", Password: "pw", Role: RoleViewer})
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	updated, err := service.UpdateRole(user.ID, RoleAdmin)
+	if err != nil {
+		t.Fatalf("UpdateRole() failed: %v", err)
+	}
+	if updated.Role != RoleAdmin {
+		t.Errorf("Expected role admin, got %s", updated.Role)
+	}
+
+	if err := service.DeleteUser(user.ID); err != nil {
+		t.Fatalf("DeleteUser() failed: %v", err)
+	}
+	if _, _, err := service.Authenticate("alice", "pw"); !IsInvalidCredentialsError(err) {
+		t.Errorf("Expected deleted user to be unable to unlock the MEK, got %v", err)
+	}
+	if err := service.DeleteUser(user.ID); !IsUserNotFoundError(err) {
+		t.Errorf("Expected UserNotFoundError, got %v", err)
+	}
+}
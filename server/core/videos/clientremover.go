@@ -0,0 +1,503 @@
+package videos
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+const (
+	// purgeProgressInterval is the number of clips after which the progress of a purge job is stored
+	purgeProgressInterval = 20
+	// purgeExportIndexName is the name of the file in an export that lists the exported clips
+	purgeExportIndexName = "clips.csv"
+	// purgeExportSuffix ends the names of export files, which hold a zip file encrypted with the MEK
+	purgeExportSuffix = ".zip.enc"
+)
+
+// unsafeFileNameChars matches the characters that are replaced in the names of export files
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// ClientRemover deletes clients together with their clips. Deleting a client on its own would leave its
+// clips behind without an owner, so they are either kept in an archived client record or purged.
+type ClientRemover interface {
+	// ArchiveClient deletes a client but keeps its clips, which are moved to a new archived client record.
+	// Returns the archived record.
+	ArchiveClient(clientID string) (*clients.Client, error)
+	// StartPurge disables a client and starts a background job that deletes all of its clips and then the client.
+	// If export is set, the clips are first written to a zip file in the export directory, which is encrypted with
+	// the MEK from the store like the clips themselves. The client is only deleted once all of its clips are gone.
+	StartPurge(clientID string, export bool, mekStore encryption.MekStore, requestedBy string) (*PurgeJob, error)
+	// ResumePurges continues the purge jobs that were interrupted by a restart. Jobs that were still exporting
+	// cannot be resumed without the MEK and are marked as failed, so that they can be started again.
+	ResumePurges()
+	// GetPurgeJobs returns the most recent purge jobs, newest first
+	GetPurgeJobs(limit int) ([]*PurgeJob, error)
+	// GetPurgeJob returns a purge job by ID, or nil if it does not exist
+	GetPurgeJob(jobID string) (*PurgeJob, error)
+	// OpenExport opens the export of a purge job once it is finished, decrypting it with the MEK from the store.
+	// Returns the name under which the zip file is downloaded.
+	OpenExport(jobID string, mekStore encryption.MekStore) (string, io.ReadCloser, error)
+	// DeleteExport deletes the export file of a purge job
+	DeleteExport(jobID string) error
+	// DeleteExpiredExports deletes the exports of the purge jobs that finished longer ago than the lifetime
+	DeleteExpiredExports(lifetime time.Duration)
+}
+
+type clientRemover struct {
+	logger        logging.Logger
+	clientService clients.ClientService
+	clipRepo      ClipRepository
+	jobRepo       PurgeJobRepository
+	encryptor     encryption.Encryptor
+	exportDir     string
+	now           func() time.Time
+
+	mu      sync.Mutex
+	running map[string]bool // IDs of the clients with a running purge job
+	wg      sync.WaitGroup  // Running purge jobs, waited for in tests
+}
+
+// NewClientRemover creates a new ClientRemover. Exports are written to exportDir, which is created when needed.
+func NewClientRemover(logger logging.Logger, clientService clients.ClientService, clipRepo ClipRepository, jobRepo PurgeJobRepository, encryptor encryption.Encryptor, exportDir string) *clientRemover {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &clientRemover{
+		logger:        logger,
+		clientService: clientService,
+		clipRepo:      clipRepo,
+		jobRepo:       jobRepo,
+		encryptor:     encryptor,
+		exportDir:     exportDir,
+		now:           time.Now,
+		running:       make(map[string]bool),
+	}
+}
+
+func (r *clientRemover) ArchiveClient(clientID string) (*clients.Client, error) {
+	if r.isRunning(clientID) {
+		return nil, clients.NewClientValidationError(fmt.Sprintf("client %s is being purged", clientID))
+	}
+
+	archived, err := r.clientService.ArchiveClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	moved, err := r.clipRepo.ReassignClient(context.Background(), clientID, archived.ID)
+	if err != nil {
+		// The original client is disabled but still exists, so archiving can simply be tried again
+		r.logger.Error("Failed to move clips to archived client", err)
+		return nil, err
+	}
+
+	if err := r.clientService.DeleteClient(clientID); err != nil {
+		r.logger.Error("Failed to delete archived client", err)
+		return nil, err
+	}
+
+	r.logger.Info("Client deleted, clips kept in archived client", "clientId", clientID, "archivedId", archived.ID, "clips", moved)
+	return archived, nil
+}
+
+func (r *clientRemover) StartPurge(clientID string, export bool, mekStore encryption.MekStore, requestedBy string) (*PurgeJob, error) {
+	client, err := r.clientService.GetClient(clientID)
+	if err != nil {
+		r.logger.Error("Failed to retrieve client", err)
+		return nil, err
+	}
+	if client == nil {
+		return nil, clients.NewClientNotFoundError(clientID)
+	}
+
+	// The MEK is taken now, because the job outlives the dashboard request and its session
+	var mek []byte
+	if export {
+		mek, err = mekStore.GetMek()
+		if err != nil {
+			r.logger.Error("Failed to get MEK for export", err)
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running[clientID] {
+		return nil, clients.NewClientValidationError(fmt.Sprintf("client %s is already being purged", clientID))
+	}
+
+	// Disable the client first, so that no new clips arrive while the old ones are deleted
+	if err := r.clientService.DisableClient(clientID); err != nil {
+		r.logger.Error("Failed to disable client", err)
+		return nil, err
+	}
+
+	now := r.now().UTC()
+	job := &PurgeJob{
+		ID:          uuid.NewString(),
+		ClientID:    clientID,
+		ClientName:  client.Name(),
+		Export:      export,
+		Status:      PurgeJobStatusPending,
+		RequestedBy: requestedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := r.jobRepo.Create(context.Background(), job); err != nil {
+		r.logger.Error("Failed to create purge job", err)
+		return nil, err
+	}
+
+	r.logger.Info("Purge started", "job", job.ID, "clientId", clientID, "export", export, "requestedBy", requestedBy)
+	r.startJob(job, mek)
+	return job, nil
+}
+
+func (r *clientRemover) ResumePurges() {
+	ctx := context.Background()
+
+	jobs, err := r.jobRepo.GetUnfinished(ctx)
+	if err != nil {
+		r.logger.Error("Failed to get unfinished purge jobs", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range jobs {
+		if r.running[job.ClientID] {
+			continue
+		}
+
+		if job.Export && job.Status != PurgeJobStatusDeleting {
+			r.removeExportFile(job)
+			job.ExportFile = ""
+			r.fail(ctx, job, fmt.Errorf("interrupted by a restart before the export was finished, no clips were deleted"))
+			continue
+		}
+
+		r.logger.Info("Resuming purge", "job", job.ID, "clientId", job.ClientID)
+		r.startJob(job, nil)
+	}
+}
+
+func (r *clientRemover) GetPurgeJobs(limit int) ([]*PurgeJob, error) {
+	jobs, err := r.jobRepo.GetRecent(context.Background(), limit)
+	if err != nil {
+		r.logger.Error("Failed to get purge jobs", err)
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *clientRemover) GetPurgeJob(jobID string) (*PurgeJob, error) {
+	job, err := r.jobRepo.GetByID(context.Background(), jobID)
+	if err != nil {
+		r.logger.Error("Failed to get purge job", err)
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *clientRemover) OpenExport(jobID string, mekStore encryption.MekStore) (string, io.ReadCloser, error) {
+	job, err := r.GetPurgeJob(jobID)
+	if err != nil {
+		return "", nil, err
+	}
+	// The export file is only complete once the job has moved on from exporting
+	if job == nil || job.ExportFile == "" || job.Status == PurgeJobStatusPending || job.Status == PurgeJobStatusExporting {
+		return "", nil, fmt.Errorf("purge job %s has no export", jobID)
+	}
+
+	mek, err := mekStore.GetMek()
+	if err != nil {
+		r.logger.Error("Failed to get MEK for export", err)
+		return "", nil, err
+	}
+
+	file, err := os.Open(filepath.Join(r.exportDir, job.ExportFile))
+	if err != nil {
+		r.logger.Error("Failed to open export file", err)
+		return "", nil, err
+	}
+
+	export := struct {
+		io.Reader
+		io.Closer
+	}{encryption.NewDecryptingReader(file, r.encryptor, mek), file}
+	return strings.TrimSuffix(job.ExportFile, purgeExportSuffix) + ".zip", export, nil
+}
+
+func (r *clientRemover) DeleteExport(jobID string) error {
+	ctx := context.Background()
+
+	job, err := r.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		r.logger.Error("Failed to get purge job", err)
+		return err
+	}
+	if job == nil || job.ExportFile == "" {
+		return nil
+	}
+	if !job.Status.IsFinal() {
+		return fmt.Errorf("purge job %s is still running", jobID)
+	}
+
+	r.removeExportFile(job)
+	job.ExportFile = ""
+	job.UpdatedAt = r.now().UTC()
+	if err := r.jobRepo.Update(ctx, job); err != nil {
+		r.logger.Error("Failed to update purge job", err)
+		return err
+	}
+
+	r.logger.Info("Purge export deleted", "job", jobID)
+	return nil
+}
+
+func (r *clientRemover) DeleteExpiredExports(lifetime time.Duration) {
+	jobs, err := r.jobRepo.GetWithExport(context.Background())
+	if err != nil {
+		r.logger.Error("Failed to get purge jobs with exports", err)
+		return
+	}
+
+	now := r.now()
+	for _, job := range jobs {
+		if job.FinishedAt == nil || now.Sub(*job.FinishedAt) < lifetime {
+			continue
+		}
+		if err := r.DeleteExport(job.ID); err != nil {
+			r.logger.Error("Failed to delete expired export", err, "job", job.ID)
+			continue
+		}
+		r.logger.Info("Expired purge export deleted", "job", job.ID, "clientId", job.ClientID)
+	}
+}
+
+// isRunning reports whether a purge job of the client is running
+func (r *clientRemover) isRunning(clientID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running[clientID]
+}
+
+// startJob runs the job in the background. The caller must hold the mutex.
+func (r *clientRemover) startJob(job *PurgeJob, mek []byte) {
+	r.running[job.ClientID] = true
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, job.ClientID)
+			r.mu.Unlock()
+		}()
+
+		r.run(job, mek)
+	}()
+}
+
+// run exports the clips if requested, deletes them and finally deletes the client
+func (r *clientRemover) run(job *PurgeJob, mek []byte) {
+	ctx := context.Background()
+
+	if job.Export && job.Status != PurgeJobStatusDeleting {
+		if err := r.export(ctx, job, mek); err != nil {
+			r.removeExportFile(job)
+			job.ExportFile = ""
+			r.fail(ctx, job, err)
+			return
+		}
+	}
+
+	if err := r.deleteClips(ctx, job); err != nil {
+		r.fail(ctx, job, err)
+		return
+	}
+
+	if err := r.clientService.DeleteClient(job.ClientID); err != nil {
+		r.fail(ctx, job, fmt.Errorf("failed to delete client: %w", err))
+		return
+	}
+
+	now := r.now().UTC()
+	job.Status = PurgeJobStatusCompleted
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	if err := r.jobRepo.Update(ctx, job); err != nil {
+		r.logger.Error("Failed to update purge job", err)
+	}
+
+	r.logger.Info("Purge completed", "job", job.ID, "clientId", job.ClientID, "clips", job.TotalClips)
+}
+
+// export writes the clips of the client and an index of them to a zip file. The clips are only decrypted in
+// memory, since the zip file is encrypted with the MEK while it is written.
+func (r *clientRemover) export(ctx context.Context, job *PurgeJob, mek []byte) error {
+	ids, err := r.clipRepo.GetIDsByClientID(ctx, job.ClientID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(r.exportDir, 0700); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	job.Status = PurgeJobStatusExporting
+	job.TotalClips = len(ids)
+	job.ProcessedClips = 0
+	job.ExportFile = fmt.Sprintf("%s-%s"+purgeExportSuffix, unsafeFileNameChars.ReplaceAllString(job.ClientID, "_"), job.CreatedAt.Format("20060102-150405"))
+	r.saveProgress(ctx, job)
+
+	file, err := os.OpenFile(filepath.Join(r.exportDir, job.ExportFile), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	encrypted := encryption.NewEncryptingWriter(file, r.encryptor, mek)
+	archive := zip.NewWriter(encrypted)
+	index := [][]string{{"id", "file", "timestamp", "duration_seconds", "has_motion", "width", "height", "mime_type", "settings_version"}}
+	names := make(map[string]bool, len(ids))
+
+	for _, id := range ids {
+		clip, err := r.clipRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if clip == nil {
+			continue // Deleted in the meantime
+		}
+
+		video, err := r.encryptor.Decrypt(clip.EncryptedVideo, mek)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt clip %s: %w", clip.ID, err)
+		}
+
+		// Titles are only unique per second, so a clash is resolved with the clip ID
+		name := clip.Title
+		if names[name] {
+			name = clip.ID + "_" + clip.Title
+		}
+		names[name] = true
+
+		entry, err := archive.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to add clip to export: %w", err)
+		}
+		if _, err := entry.Write(video); err != nil {
+			return fmt.Errorf("failed to write clip to export: %w", err)
+		}
+
+		index = append(index, []string{
+			clip.ID, name, clip.TimeStamp.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(clip.Duration.Seconds(), 'f', -1, 64), strconv.FormatBool(clip.HasMotion),
+			strconv.Itoa(clip.VideoWidth), strconv.Itoa(clip.VideoHeight), clip.VideoMimeType, strconv.Itoa(clip.SettingsVersion),
+		})
+
+		job.ProcessedClips++
+		if job.ProcessedClips%purgeProgressInterval == 0 {
+			r.saveProgress(ctx, job)
+		}
+	}
+
+	entry, err := archive.Create(purgeExportIndexName)
+	if err != nil {
+		return fmt.Errorf("failed to add index to export: %w", err)
+	}
+	if err := csv.NewWriter(entry).WriteAll(index); err != nil {
+		return fmt.Errorf("failed to write index to export: %w", err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish export: %w", err)
+	}
+	if err := encrypted.Close(); err != nil {
+		return fmt.Errorf("failed to finish export: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to finish export: %w", err)
+	}
+
+	r.logger.Info("Clips exported", "job", job.ID, "clientId", job.ClientID, "clips", job.ProcessedClips, "file", job.ExportFile)
+	return nil
+}
+
+// deleteClips deletes all clips of the client
+func (r *clientRemover) deleteClips(ctx context.Context, job *PurgeJob) error {
+	ids, err := r.clipRepo.GetIDsByClientID(ctx, job.ClientID)
+	if err != nil {
+		return err
+	}
+
+	job.Status = PurgeJobStatusDeleting
+	job.TotalClips = len(ids)
+	job.ProcessedClips = 0
+	r.saveProgress(ctx, job)
+
+	for _, id := range ids {
+		if err := r.clipRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		job.ProcessedClips++
+		if job.ProcessedClips%purgeProgressInterval == 0 {
+			r.saveProgress(ctx, job)
+		}
+	}
+
+	r.saveProgress(ctx, job)
+	return nil
+}
+
+// saveProgress stores the progress of the job. A failure is only logged, since the job itself can go on.
+func (r *clientRemover) saveProgress(ctx context.Context, job *PurgeJob) {
+	job.UpdatedAt = r.now().UTC()
+	if err := r.jobRepo.Update(ctx, job); err != nil {
+		r.logger.Error("Failed to update purge job progress", err)
+	}
+}
+
+// fail marks the job as failed
+func (r *clientRemover) fail(ctx context.Context, job *PurgeJob, cause error) {
+	r.logger.Error("Purge failed", cause, "job", job.ID, "clientId", job.ClientID)
+
+	now := r.now().UTC()
+	job.Status = PurgeJobStatusFailed
+	job.Error = cause.Error()
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	if err := r.jobRepo.Update(ctx, job); err != nil {
+		r.logger.Error("Failed to update purge job", err)
+	}
+}
+
+// removeExportFile deletes the export file of the job, if there is one
+func (r *clientRemover) removeExportFile(job *PurgeJob) {
+	if job.ExportFile == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(r.exportDir, job.ExportFile)); err != nil && !os.IsNotExist(err) {
+		r.logger.Error("Failed to remove export file", err)
+	}
+}
@@ -0,0 +1,332 @@
+package videos
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+type staticMekStore struct {
+	mek []byte
+}
+
+func (s *staticMekStore) GetMek() ([]byte, error) { return s.mek, nil }
+func (s *staticMekStore) SetMek(mek []byte) error { s.mek = mek; return nil }
+func (s *staticMekStore) ClearMek() error         { s.mek = nil; return nil }
+
+type removerTestEnv struct {
+	remover       *clientRemover
+	clientService clients.ClientService
+	clipRepo      *SQLiteClipRepository
+	jobRepo       *SQLitePurgeJobRepository
+	encryptor     encryption.Encryptor
+	mekStore      *staticMekStore
+	exportDir     string
+}
+
+func setupClientRemover(t *testing.T) *removerTestEnv {
+	testDB, err := db.NewInMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	clientRepo, err := clients.NewSQLiteClientRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create client repository: %v", err)
+	}
+	versionRepo, err := clients.NewSQLiteSettingsVersionRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create settings version repository: %v", err)
+	}
+	groupRepo, err := clients.NewSQLiteClientGroupRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create client group repository: %v", err)
+	}
+	clipRepo, err := NewSQLiteClipRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create clip repository: %v", err)
+	}
+	jobRepo, err := NewSQLitePurgeJobRepository(testDB)
+	if err != nil {
+		t.Fatalf("Failed to create purge job repository: %v", err)
+	}
+
+	encryptor := encryption.NewAESEncryptor()
+	mek, _ := encryptor.GenerateKey()
+	clientService := clients.NewClientService(nil, clientRepo, versionRepo, groupRepo, encryptor)
+	exportDir := filepath.Join(t.TempDir(), "exports")
+
+	return &removerTestEnv{
+		remover:       NewClientRemover(nil, clientService, clipRepo, jobRepo, encryptor, exportDir),
+		clientService: clientService,
+		clipRepo:      clipRepo,
+		jobRepo:       jobRepo,
+		encryptor:     encryptor,
+		mekStore:      &staticMekStore{mek: mek},
+		exportDir:     exportDir,
+	}
+}
+
+// addClient creates a client with the given number of encrypted clips
+func (e *removerTestEnv) addClient(t *testing.T, id string, clipCount int) {
+	_, _, err := e.clientService.CreateClient(clients.CreateClientRequest{
+		ID:                    id,
+		StorageLimitMegabytes: 1024,
+		ClipDurationSeconds:   60,
+		OutputFormat:          "mp4",
+		OutputCodec:           "libx264",
+		VideoBitRate:          "1000k",
+		CaptureCodec:          "MJPG",
+		CaptureFrameRate:      15,
+	}, e.mekStore, "admin")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	start := time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)
+	for i := range clipCount {
+		video, err := e.encryptor.Encrypt([]byte(fmt.Sprintf("video %s %d", id, i)), e.mekStore.mek)
+		if err != nil {
+			t.Fatalf("Failed to encrypt video: %v", err)
+		}
+		clip := createTestClip()
+		clip.ID = fmt.Sprintf("%s-clip-%d", id, i)
+		clip.ClientID = id
+		clip.Title = "20250701T120000Z_60s_motion.mp4" // same title for all clips to exercise name clashes
+		clip.TimeStamp = start.Add(time.Duration(i) * time.Minute)
+		clip.EncryptedVideo = video
+		if err := e.clipRepo.Add(context.Background(), clip); err != nil {
+			t.Fatalf("Failed to add clip: %v", err)
+		}
+	}
+}
+
+func TestClientRemover_ArchiveClient(t *testing.T) {
+	env := setupClientRemover(t)
+	env.addClient(t, "front-door", 3)
+
+	archived, err := env.remover.ArchiveClient("front-door")
+	if err != nil {
+		t.Fatalf("Failed to archive client: %v", err)
+	}
+
+	if client, _ := env.clientService.GetClient("front-door"); client != nil {
+		t.Error("Expected the original client to be deleted")
+	}
+	stored, _ := env.clientService.GetClient(archived.ID)
+	if stored == nil || !stored.IsArchived() {
+		t.Fatalf("Expected the archived record to be stored, got %+v", stored)
+	}
+
+	ids, _ := env.clipRepo.GetIDsByClientID(context.Background(), archived.ID)
+	if len(ids) != 3 {
+		t.Errorf("Expected the 3 clips to be kept by the archived record, got %d", len(ids))
+	}
+}
+
+func TestClientRemover_PurgeWithExport(t *testing.T) {
+	env := setupClientRemover(t)
+	env.addClient(t, "front-door", 25)
+	env.addClient(t, "back-door", 2)
+
+	job, err := env.remover.StartPurge("front-door", true, env.mekStore, "alice")
+	if err != nil {
+		t.Fatalf("Failed to start purge: %v", err)
+	}
+	env.remover.wg.Wait()
+
+	job, _ = env.remover.GetPurgeJob(job.ID)
+	if job.Status != PurgeJobStatusCompleted || job.ProcessedClips != 25 || job.FinishedAt == nil {
+		t.Fatalf("Expected the job to complete after deleting 25 clips, got %+v", job)
+	}
+	if job.RequestedBy != "alice" || job.Percent() != 100 {
+		t.Errorf("Unexpected job details: %+v", job)
+	}
+
+	if client, _ := env.clientService.GetClient("front-door"); client != nil {
+		t.Error("Expected the client to be deleted")
+	}
+	if ids, _ := env.clipRepo.GetIDsByClientID(context.Background(), "front-door"); len(ids) != 0 {
+		t.Errorf("Expected all clips of the client to be deleted, got %d", len(ids))
+	}
+	if ids, _ := env.clipRepo.GetIDsByClientID(context.Background(), "back-door"); len(ids) != 2 {
+		t.Errorf("Expected the clips of other clients to be kept, got %d", len(ids))
+	}
+
+	path := filepath.Join(env.exportDir, job.ExportFile)
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read export file: %v", err)
+	}
+	if bytes.Contains(stored, []byte("video front-door")) || bytes.Contains(stored, []byte(purgeExportIndexName)) {
+		t.Error("Expected the export file to be encrypted")
+	}
+
+	name, export, err := env.remover.OpenExport(job.ID, env.mekStore)
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+	content, err := io.ReadAll(export)
+	export.Close()
+	if err != nil {
+		t.Fatalf("Failed to decrypt export: %v", err)
+	}
+	if name != "front-door-"+job.CreatedAt.Format("20060102-150405")+".zip" {
+		t.Errorf("Unexpected download name %q", name)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+
+	if len(archive.File) != 26 {
+		t.Fatalf("Expected 25 clips and an index in the export, got %d files", len(archive.File))
+	}
+	first, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatalf("Failed to open exported clip: %v", err)
+	}
+	clipContent, _ := io.ReadAll(first)
+	first.Close()
+	if string(clipContent) != "video front-door 0" {
+		t.Errorf("Expected the exported clip to be decrypted, got %q", clipContent)
+	}
+	if archive.File[1].Name != "front-door-clip-1_20250701T120000Z_60s_motion.mp4" {
+		t.Errorf("Expected clashing titles to be prefixed with the clip ID, got %q", archive.File[1].Name)
+	}
+	if archive.File[25].Name != purgeExportIndexName {
+		t.Errorf("Expected the index as the last file, got %q", archive.File[25].Name)
+	}
+
+	if err := env.remover.DeleteExport(job.ID); err != nil {
+		t.Fatalf("Failed to delete export: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the export file to be removed, got %v", err)
+	}
+	if _, _, err := env.remover.OpenExport(job.ID, env.mekStore); err == nil {
+		t.Error("Expected no export after the export was deleted")
+	}
+}
+
+func TestClientRemover_DeleteExpiredExports(t *testing.T) {
+	env := setupClientRemover(t)
+	env.addClient(t, "front-door", 2)
+	env.addClient(t, "back-door", 2)
+
+	finished := time.Date(2025, time.July, 1, 12, 0, 0, 0, time.UTC)
+	env.remover.now = func() time.Time { return finished }
+	oldJob, _ := env.remover.StartPurge("front-door", true, env.mekStore, "alice")
+	env.remover.wg.Wait()
+
+	env.remover.now = func() time.Time { return finished.Add(20 * time.Hour) }
+	newJob, _ := env.remover.StartPurge("back-door", true, env.mekStore, "alice")
+	env.remover.wg.Wait()
+
+	env.remover.now = func() time.Time { return finished.Add(25 * time.Hour) }
+	env.remover.DeleteExpiredExports(24 * time.Hour)
+
+	if job, _ := env.remover.GetPurgeJob(oldJob.ID); job.ExportFile != "" {
+		t.Errorf("Expected the export finished 25 hours ago to be deleted, got %q", job.ExportFile)
+	}
+	if job, _ := env.remover.GetPurgeJob(newJob.ID); job.ExportFile == "" {
+		t.Error("Expected the export finished 5 hours ago to be kept")
+	}
+	files, _ := os.ReadDir(env.exportDir)
+	if len(files) != 1 {
+		t.Errorf("Expected one export file to be left, got %d", len(files))
+	}
+}
+
+func TestClientRemover_PurgeWithoutExport(t *testing.T) {
+	env := setupClientRemover(t)
+	env.addClient(t, "front-door", 2)
+
+	job, err := env.remover.StartPurge("front-door", false, nil, "alice")
+	if err != nil {
+		t.Fatalf("Failed to start purge: %v", err)
+	}
+	env.remover.wg.Wait()
+
+	job, _ = env.remover.GetPurgeJob(job.ID)
+	if job.Status != PurgeJobStatusCompleted || job.ExportFile != "" {
+		t.Errorf("Expected the job to complete without an export, got %+v", job)
+	}
+	if _, err := os.Stat(env.exportDir); !os.IsNotExist(err) {
+		t.Error("Expected no export directory to be created")
+	}
+
+	if _, err := env.remover.StartPurge("missing", false, nil, "alice"); !clients.IsClientNotFoundError(err) {
+		t.Errorf("Expected ClientNotFoundError for a missing client, got %v", err)
+	}
+}
+
+func TestClientRemover_ExportFailureKeepsClips(t *testing.T) {
+	env := setupClientRemover(t)
+	env.addClient(t, "front-door", 2)
+
+	wrongMek, _ := env.encryptor.GenerateKey()
+	job, err := env.remover.StartPurge("front-door", true, &staticMekStore{mek: wrongMek}, "alice")
+	if err != nil {
+		t.Fatalf("Failed to start purge: %v", err)
+	}
+	env.remover.wg.Wait()
+
+	job, _ = env.remover.GetPurgeJob(job.ID)
+	if job.Status != PurgeJobStatusFailed || job.Error == "" || job.ExportFile != "" {
+		t.Errorf("Expected the job to fail without an export, got %+v", job)
+	}
+	if ids, _ := env.clipRepo.GetIDsByClientID(context.Background(), "front-door"); len(ids) != 2 {
+		t.Errorf("Expected the clips to be kept after a failed export, got %d", len(ids))
+	}
+	client, _ := env.clientService.GetClient("front-door")
+	if client == nil || !client.IsDisabled {
+		t.Error("Expected the client to be kept, disabled")
+	}
+	if entries, _ := os.ReadDir(env.exportDir); len(entries) != 0 {
+		t.Errorf("Expected the partial export to be removed, got %d files", len(entries))
+	}
+}
+
+func TestClientRemover_ResumePurges(t *testing.T) {
+	env := setupClientRemover(t)
+	env.addClient(t, "front-door", 2)
+	env.addClient(t, "back-door", 2)
+
+	now := time.Now().UTC()
+	deleting := &PurgeJob{ID: "deleting", ClientID: "front-door", Status: PurgeJobStatusDeleting, TotalClips: 3, ProcessedClips: 1, CreatedAt: now, UpdatedAt: now}
+	exporting := &PurgeJob{ID: "exporting", ClientID: "back-door", Export: true, Status: PurgeJobStatusExporting, CreatedAt: now, UpdatedAt: now}
+	for _, job := range []*PurgeJob{deleting, exporting} {
+		if err := env.jobRepo.Create(context.Background(), job); err != nil {
+			t.Fatalf("Failed to create purge job: %v", err)
+		}
+	}
+
+	env.remover.ResumePurges()
+	env.remover.wg.Wait()
+
+	if job, _ := env.remover.GetPurgeJob("deleting"); job.Status != PurgeJobStatusCompleted {
+		t.Errorf("Expected the interrupted deletion to be resumed, got %+v", job)
+	}
+	if client, _ := env.clientService.GetClient("front-door"); client != nil {
+		t.Error("Expected the client of the resumed job to be deleted")
+	}
+
+	if job, _ := env.remover.GetPurgeJob("exporting"); job.Status != PurgeJobStatusFailed {
+		t.Errorf("Expected the interrupted export to fail, got %+v", job)
+	}
+	if ids, _ := env.clipRepo.GetIDsByClientID(context.Background(), "back-door"); len(ids) != 2 {
+		t.Errorf("Expected the clips of the interrupted export to be kept, got %d", len(ids))
+	}
+}
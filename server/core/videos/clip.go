@@ -19,6 +19,7 @@ type Clip struct {
 	ThumbnailWidth     int
 	ThumbnailHeight    int
 	ThumbnailMimeType  string
+	SettingsVersion    int // Version of the client settings the clip was recorded with, 0 if unknown
 }
 
 // ClipInfo represents metadata about a clip without the actual video data
@@ -36,11 +37,13 @@ type ClipInfo struct {
 	ThumbnailWidth    int
 	ThumbnailHeight   int
 	ThumbnailMimeType string
+	SettingsVersion   int
 }
 
 // ClipQuery represents query parameters for searching clips
 type ClipQuery struct {
-	ClientID  string // empty string means no filter, otherwise filter by specific client
+	ClientID  string   // empty string means no filter, otherwise filter by specific client
+	ClientIDs []string // nil means no filter, otherwise filter by any of the given clients (an empty slice matches no clips)
 	StartTime *time.Time
 	EndTime   *time.Time
 	HasMotion *bool // nil means no filter, true/false means filter by motion
@@ -64,6 +67,7 @@ type DecryptedClip struct {
 	ThumbnailWidth    int
 	ThumbnailHeight   int
 	ThumbnailMimeType string
+	SettingsVersion   int
 }
 
 // Thumbnail represents thumbnail data with its metadata
@@ -13,15 +13,18 @@ import (
 )
 
 type CreateClipRequest struct {
-	TimeStamp time.Time     `json:"time_stamp"`
-	Duration  time.Duration `json:"duration"`
-	HasMotion bool          `json:"has_motion"`
-	Video     []byte        `json:"video"`
+	TimeStamp       time.Time     `json:"time_stamp"`
+	Duration        time.Duration `json:"duration"`
+	HasMotion       bool          `json:"has_motion"`
+	Video           []byte        `json:"video"`
+	SettingsVersion int           `json:"settings_version"` // Version of the client settings the clip was recorded with, 0 if unknown
+	Timezone        string        `json:"timezone"`         // IANA timezone of the camera in which the title shows the time, empty for UTC
 }
 
 type ClipCreator interface {
-	// CreateClip creates a new video clip with the given details
-	CreateClip(req CreateClipRequest, clientID, clientSecret string) (*Clip, error)
+	// CreateClip creates a new video clip with the given details.
+	// The credential is the hex-encoded client secret, or an access token if the MEK provider supports them.
+	CreateClip(req CreateClipRequest, clientID, clientCredential string) (*Clip, error)
 }
 
 type clipCreator struct {
@@ -48,7 +51,7 @@ func NewClipCreator(logger logging.Logger, storageManager StorageManager, encryp
 	}
 }
 
-func (s *clipCreator) CreateClip(req CreateClipRequest, clientID, clientSecret string) (*Clip, error) {
+func (s *clipCreator) CreateClip(req CreateClipRequest, clientID, clientCredential string) (*Clip, error) {
 	// Validate request
 	if req.Duration <= 0 {
 		return nil, errors.New("invalid duration")
@@ -58,7 +61,7 @@ func (s *clipCreator) CreateClip(req CreateClipRequest, clientID, clientSecret s
 	}
 
 	// Uncover the MEK for the client (verify client first before expensive operations)
-	mek, err := s.mekProvider.UncoverMek(clientID, clientSecret)
+	mek, err := s.mekProvider.UncoverMek(clientID, clientCredential)
 	if err != nil {
 		s.logger.Error("Failed to uncover MEK", err)
 		return nil, err
@@ -75,14 +78,14 @@ func (s *clipCreator) CreateClip(req CreateClipRequest, clientID, clientSecret s
 	clipID := uuid.New().String()
 
 	// Create title in the specified format
-	timestampUtc := req.TimeStamp.UTC().Format("2006-01-02T15-04-05")
+	timestamp := titleTimestamp(req.TimeStamp, req.Timezone)
 	durationSeconds := fmt.Sprintf("%.0f", req.Duration.Seconds())
 	motionStr := "nomotion"
 	if req.HasMotion {
 		motionStr = "motion"
 	}
 
-	title := fmt.Sprintf("%s_%ss_%s.%s", timestampUtc, durationSeconds, motionStr, videoMeta.Extension)
+	title := fmt.Sprintf("%s_%ss_%s.%s", timestamp, durationSeconds, motionStr, videoMeta.Extension)
 
 	// Encrypt video data
 	encryptedVideo, err := s.encryptor.Encrypt(req.Video, mek)
@@ -131,6 +134,7 @@ func (s *clipCreator) CreateClip(req CreateClipRequest, clientID, clientSecret s
 		ThumbnailWidth:     thumbnailWidth,
 		ThumbnailHeight:    thumbnailHeight,
 		ThumbnailMimeType:  thumbnailMimeType,
+		SettingsVersion:    req.SettingsVersion,
 	}
 
 	// Save clip to repository
@@ -143,3 +147,14 @@ func (s *clipCreator) CreateClip(req CreateClipRequest, clientID, clientSecret s
 	s.logger.Info(fmt.Sprintf("Successfully created clip %s for client %s", clipID, clientID))
 	return clip, nil
 }
+
+// titleTimestamp formats the timestamp of a clip for its title. Without a timezone the time is shown in UTC, as titles
+// always were. In the camera's timezone the UTC offset is appended, so that titles stay unambiguous.
+func titleTimestamp(t time.Time, timezone string) string {
+	if timezone != "" {
+		if location, err := time.LoadLocation(timezone); err == nil {
+			return t.In(location).Format("2006-01-02T15-04-05-0700")
+		}
+	}
+	return t.UTC().Format("2006-01-02T15-04-05")
+}
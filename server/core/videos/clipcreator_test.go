@@ -0,0 +1,26 @@
+package videos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTitleTimestamp(t *testing.T) {
+	timestamp := time.Date(2025, time.July, 1, 20, 30, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		timezone string
+		expected string
+	}{
+		"no timezone":      {"", "2025-07-01T20-30-00"},
+		"unknown timezone": {"Mars/Olympus_Mons", "2025-07-01T20-30-00"},
+		"camera timezone":  {"Europe/Berlin", "2025-07-01T22-30-00+0200"},
+		"negative offset":  {"America/New_York", "2025-07-01T16-30-00-0400"},
+	}
+
+	for name, test := range tests {
+		if got := titleTimestamp(timestamp, test.timezone); got != test.expected {
+			t.Errorf("%s: expected %q, got %q", name, test.expected, got)
+		}
+	}
+}
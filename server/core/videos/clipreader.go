@@ -182,6 +182,7 @@ func (r *clipReader) decryptClip(clip *Clip, mek []byte) (*DecryptedClip, error)
 		ThumbnailWidth:    clip.ThumbnailWidth,
 		ThumbnailHeight:   clip.ThumbnailHeight,
 		ThumbnailMimeType: clip.ThumbnailMimeType,
+		SettingsVersion:   clip.SettingsVersion,
 	}, nil
 }
 
@@ -41,6 +41,12 @@ type ClipRepository interface {
 
 	// GetOldestClips retrieves the oldest clips for a client, limited by the specified count
 	GetOldestClips(ctx context.Context, clientID string, limit int) ([]*Clip, error)
+
+	// GetIDsByClientID retrieves the IDs of all clips of a client, oldest first
+	GetIDsByClientID(ctx context.Context, clientID string) ([]string, error)
+
+	// ReassignClient moves all clips of a client to another client and returns the number of moved clips
+	ReassignClient(ctx context.Context, fromClientID, toClientID string) (int, error)
 }
 
 // SQLiteClipRepository implements ClipRepository using SQLite
@@ -75,18 +81,26 @@ func (r *SQLiteClipRepository) createTables() error {
 		encrypted_thumbnail BLOB,
 		thumbnail_width INTEGER NOT NULL,
 		thumbnail_height INTEGER NOT NULL,
-		thumbnail_mime_type TEXT NOT NULL
+		thumbnail_mime_type TEXT NOT NULL,
+		settings_version INTEGER NOT NULL DEFAULT 0
 	);`
 
 	_, err := r.db.Exec(createClipsTable)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Add new columns if they don't exist, to support migration from older versions
+	db.AddColumn(r.db, "clips", "settings_version", "INTEGER NOT NULL DEFAULT 0")
+
+	return nil
 }
 
 // GetByID retrieves a Clip by its ID
 func (r *SQLiteClipRepository) GetByID(ctx context.Context, id string) (*Clip, error) {
 	query := `
 	SELECT id, client_id, title, timestamp, duration, has_motion, encrypted_video, video_width, video_height, video_mime_type,
-		   encrypted_thumbnail, thumbnail_width, thumbnail_height, thumbnail_mime_type
+		   encrypted_thumbnail, thumbnail_width, thumbnail_height, thumbnail_mime_type, settings_version
 	FROM clips WHERE id = ?`
 
 	row := r.db.QueryRowContext(ctx, query, id)
@@ -98,7 +112,7 @@ func (r *SQLiteClipRepository) GetByID(ctx context.Context, id string) (*Clip, e
 	err := row.Scan(
 		&clip.ID, &clip.ClientID, &clip.Title, &timestampStr, &durationNanos, &hasMotionInt, &clip.EncryptedVideo,
 		&clip.VideoWidth, &clip.VideoHeight, &clip.VideoMimeType,
-		&clip.EncryptedThumbnail, &clip.ThumbnailWidth, &clip.ThumbnailHeight, &clip.ThumbnailMimeType,
+		&clip.EncryptedThumbnail, &clip.ThumbnailWidth, &clip.ThumbnailHeight, &clip.ThumbnailMimeType, &clip.SettingsVersion,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -122,7 +136,7 @@ func (r *SQLiteClipRepository) GetByID(ctx context.Context, id string) (*Clip, e
 func (r *SQLiteClipRepository) GetInfoByID(ctx context.Context, id string) (*ClipInfo, error) {
 	query := `
 	SELECT id, client_id, title, timestamp, duration, has_motion, LENGTH(encrypted_video) as video_size,
-		   video_width, video_height, video_mime_type, thumbnail_width, thumbnail_height, thumbnail_mime_type
+		   video_width, video_height, video_mime_type, thumbnail_width, thumbnail_height, thumbnail_mime_type, settings_version
 	FROM clips WHERE id = ?`
 
 	row := r.db.QueryRowContext(ctx, query, id)
@@ -134,7 +148,7 @@ func (r *SQLiteClipRepository) GetInfoByID(ctx context.Context, id string) (*Cli
 	err := row.Scan(
 		&clipInfo.ID, &clipInfo.ClientID, &clipInfo.Title, &timestampStr, &durationNanos, &hasMotionInt, &clipInfo.VideoSize,
 		&clipInfo.VideoWidth, &clipInfo.VideoHeight, &clipInfo.VideoMimeType,
-		&clipInfo.ThumbnailWidth, &clipInfo.ThumbnailHeight, &clipInfo.ThumbnailMimeType,
+		&clipInfo.ThumbnailWidth, &clipInfo.ThumbnailHeight, &clipInfo.ThumbnailMimeType, &clipInfo.SettingsVersion,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -180,7 +194,7 @@ func (r *SQLiteClipRepository) Query(ctx context.Context, query ClipQuery) ([]*C
 		err := rows.Scan(
 			&clip.ID, &clip.ClientID, &clip.Title, &timestampStr, &durationNanos, &hasMotionInt, &clip.EncryptedVideo,
 			&clip.VideoWidth, &clip.VideoHeight, &clip.VideoMimeType,
-			&clip.EncryptedThumbnail, &clip.ThumbnailWidth, &clip.ThumbnailHeight, &clip.ThumbnailMimeType,
+			&clip.EncryptedThumbnail, &clip.ThumbnailWidth, &clip.ThumbnailHeight, &clip.ThumbnailMimeType, &clip.SettingsVersion,
 		)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan clip: %w", err)
@@ -204,8 +218,8 @@ func (r *SQLiteClipRepository) Query(ctx context.Context, query ClipQuery) ([]*C
 func (r *SQLiteClipRepository) Add(ctx context.Context, clip *Clip) error {
 	query := `
 	INSERT INTO clips (id, client_id, title, timestamp, duration, has_motion, encrypted_video, video_width, video_height, video_mime_type,
-					   encrypted_thumbnail, thumbnail_width, thumbnail_height, thumbnail_mime_type)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+					   encrypted_thumbnail, thumbnail_width, thumbnail_height, thumbnail_mime_type, settings_version)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	// Convert bool to int for has_motion
 	hasMotionInt := db.BoolToInt(clip.HasMotion)
@@ -213,7 +227,7 @@ func (r *SQLiteClipRepository) Add(ctx context.Context, clip *Clip) error {
 	_, err := r.db.ExecContext(ctx, query,
 		clip.ID, clip.ClientID, clip.Title, db.TimeToString(clip.TimeStamp), int64(clip.Duration), hasMotionInt, clip.EncryptedVideo,
 		clip.VideoWidth, clip.VideoHeight, clip.VideoMimeType,
-		clip.EncryptedThumbnail, clip.ThumbnailWidth, clip.ThumbnailHeight, clip.ThumbnailMimeType,
+		clip.EncryptedThumbnail, clip.ThumbnailWidth, clip.ThumbnailHeight, clip.ThumbnailMimeType, clip.SettingsVersion,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to add clip: %w", err)
@@ -269,7 +283,7 @@ func (r *SQLiteClipRepository) QueryInfo(ctx context.Context, query ClipQuery) (
 		err := rows.Scan(
 			&clipInfo.ID, &clipInfo.ClientID, &clipInfo.Title, &timestampStr, &durationNanos, &hasMotionInt, &clipInfo.VideoSize,
 			&clipInfo.VideoWidth, &clipInfo.VideoHeight, &clipInfo.VideoMimeType,
-			&clipInfo.ThumbnailWidth, &clipInfo.ThumbnailHeight, &clipInfo.ThumbnailMimeType,
+			&clipInfo.ThumbnailWidth, &clipInfo.ThumbnailHeight, &clipInfo.ThumbnailMimeType, &clipInfo.SettingsVersion,
 		)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan clip info: %w", err)
@@ -320,6 +334,13 @@ func (r *SQLiteClipRepository) getQueryCount(ctx context.Context, query ClipQuer
 		args = append(args, query.ClientID)
 	}
 
+	if query.ClientIDs != nil {
+		conditions = append(conditions, clientIDsCondition(len(query.ClientIDs)))
+		for _, clientID := range query.ClientIDs {
+			args = append(args, clientID)
+		}
+	}
+
 	if query.StartTime != nil {
 		conditions = append(conditions, "timestamp >= ?")
 		args = append(args, db.TimeToString(*query.StartTime))
@@ -348,15 +369,23 @@ func (r *SQLiteClipRepository) getQueryCount(ctx context.Context, query ClipQuer
 	return count, nil
 }
 
+// clientIDsCondition returns an SQL condition matching any of count client IDs
+func clientIDsCondition(count int) string {
+	if count == 0 {
+		return "0"
+	}
+	return "client_id IN (" + strings.TrimSuffix(strings.Repeat("?, ", count), ", ") + ")"
+}
+
 // buildQuerySQL builds the SQL query and arguments based on ClipQuery parameters
 func (r *SQLiteClipRepository) buildQuerySQL(query ClipQuery, metadataOnly bool) (string, []interface{}) {
 	var selectClause string
 	if metadataOnly {
 		selectClause = `SELECT id, client_id, title, timestamp, duration, has_motion, LENGTH(encrypted_video) as video_size, video_width, video_height, video_mime_type,
-						thumbnail_width, thumbnail_height, thumbnail_mime_type`
+						thumbnail_width, thumbnail_height, thumbnail_mime_type, settings_version`
 	} else {
 		selectClause = `SELECT id, client_id, title, timestamp, duration, has_motion, encrypted_video, video_width, video_height, video_mime_type,
-						encrypted_thumbnail, thumbnail_width, thumbnail_height, thumbnail_mime_type`
+						encrypted_thumbnail, thumbnail_width, thumbnail_height, thumbnail_mime_type, settings_version`
 	}
 
 	sqlQuery := selectClause + " FROM clips"
@@ -368,6 +397,13 @@ func (r *SQLiteClipRepository) buildQuerySQL(query ClipQuery, metadataOnly bool)
 		args = append(args, query.ClientID)
 	}
 
+	if query.ClientIDs != nil {
+		conditions = append(conditions, clientIDsCondition(len(query.ClientIDs)))
+		for _, clientID := range query.ClientIDs {
+			args = append(args, clientID)
+		}
+	}
+
 	if query.StartTime != nil {
 		conditions = append(conditions, "timestamp >= ?")
 		args = append(args, db.TimeToString(*query.StartTime))
@@ -456,3 +492,37 @@ func (r *SQLiteClipRepository) GetOldestClips(ctx context.Context, clientID stri
 	}
 	return clips, nil
 }
+
+// GetIDsByClientID retrieves the IDs of all clips of a client, oldest first
+func (r *SQLiteClipRepository) GetIDsByClientID(ctx context.Context, clientID string) ([]string, error) {
+	const query = `SELECT id FROM clips WHERE client_id = ? ORDER BY timestamp ASC`
+	rows, err := r.db.QueryContext(ctx, query, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clip IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ReassignClient moves all clips of a client to another client and returns the number of moved clips
+func (r *SQLiteClipRepository) ReassignClient(ctx context.Context, fromClientID, toClientID string) (int, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE clips SET client_id = ? WHERE client_id = ?`, toClientID, fromClientID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reassign clips: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(rowsAffected), nil
+}
@@ -2,6 +2,7 @@ package videos
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -44,6 +45,7 @@ func createTestClip() *Clip {
 		ThumbnailWidth:     320,
 		ThumbnailHeight:    240,
 		ThumbnailMimeType:  "image/jpeg",
+		SettingsVersion:    3,
 	}
 }
 
@@ -94,6 +96,9 @@ func TestSQLiteClipRepository_Add(t *testing.T) {
 	if retrieved.VideoWidth != clip.VideoWidth {
 		t.Errorf("Expected video width %d, got %d", clip.VideoWidth, retrieved.VideoWidth)
 	}
+	if retrieved.SettingsVersion != clip.SettingsVersion {
+		t.Errorf("Expected settings version %d, got %d", clip.SettingsVersion, retrieved.SettingsVersion)
+	}
 }
 
 func TestSQLiteClipRepository_GetByID_NotFound(t *testing.T) {
@@ -242,6 +247,23 @@ func TestSQLiteClipRepository_Query(t *testing.T) {
 	if client1Clips[0].ID != "clip-3" || client1Clips[1].ID != "clip-1" {
 		t.Error("Client-1 clips not ordered correctly")
 	}
+
+	// Test query with ClientIDs filter
+	_, multiClientCount, err := repo.Query(ctx, ClipQuery{ClientIDs: []string{"client-1", "client-2"}})
+	if err != nil {
+		t.Fatalf("Failed to query clips for multiple clients: %v", err)
+	}
+	if multiClientCount != totalCount {
+		t.Errorf("Expected total count %d for both clients, got %d", totalCount, multiClientCount)
+	}
+
+	noClientClips, noClientCount, err := repo.Query(ctx, ClipQuery{ClientIDs: []string{}})
+	if err != nil {
+		t.Fatalf("Failed to query clips for no clients: %v", err)
+	}
+	if len(noClientClips) != 0 || noClientCount != 0 {
+		t.Errorf("Expected no clips for an empty client list, got %d", noClientCount)
+	}
 }
 
 func TestSQLiteClipRepository_QueryInfo(t *testing.T) {
@@ -1085,3 +1107,46 @@ func TestSQLiteClipRepository_GetOldestClips(t *testing.T) {
 		t.Errorf("Expected 1 clip for client-b after deleting client-a clips, got %d", len(clientBAfterDelete))
 	}
 }
+
+func TestSQLiteClipRepository_ReassignClient(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, clientID := range []string{"client-123", "client-123", "client-456"} {
+		clip := createTestClip()
+		clip.ID = fmt.Sprintf("clip-%d", i)
+		clip.ClientID = clientID
+		clip.TimeStamp = now.Add(time.Duration(-i) * time.Minute)
+		if err := repo.Add(ctx, clip); err != nil {
+			t.Fatalf("Failed to add clip: %v", err)
+		}
+	}
+
+	ids, err := repo.GetIDsByClientID(ctx, "client-123")
+	if err != nil {
+		t.Fatalf("Failed to get clip IDs: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "clip-1" || ids[1] != "clip-0" {
+		t.Errorf("Expected the clips of client-123 oldest first, got %v", ids)
+	}
+
+	moved, err := repo.ReassignClient(ctx, "client-123", "client-123-archived")
+	if err != nil {
+		t.Fatalf("Failed to reassign clips: %v", err)
+	}
+	if moved != 2 {
+		t.Errorf("Expected 2 clips to be moved, got %d", moved)
+	}
+
+	if ids, _ := repo.GetIDsByClientID(ctx, "client-123"); len(ids) != 0 {
+		t.Errorf("Expected no clips left on client-123, got %v", ids)
+	}
+	if ids, _ := repo.GetIDsByClientID(ctx, "client-123-archived"); len(ids) != 2 {
+		t.Errorf("Expected 2 clips on the archived client, got %v", ids)
+	}
+	if ids, _ := repo.GetIDsByClientID(ctx, "client-456"); len(ids) != 1 {
+		t.Errorf("Expected the clips of other clients to stay, got %v", ids)
+	}
+}
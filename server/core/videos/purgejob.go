@@ -0,0 +1,48 @@
+package videos
+
+import "time"
+
+// PurgeJobStatus is the progress of a purge job
+type PurgeJobStatus string
+
+const (
+	PurgeJobStatusPending   PurgeJobStatus = "pending"   // Created, not started yet
+	PurgeJobStatusExporting PurgeJobStatus = "exporting" // Writing the clips to the export file
+	PurgeJobStatusDeleting  PurgeJobStatus = "deleting"  // Deleting the clips
+	PurgeJobStatusCompleted PurgeJobStatus = "completed" // All clips and the client are deleted
+	PurgeJobStatusFailed    PurgeJobStatus = "failed"    // Stopped by an error, the client and its remaining clips are kept
+)
+
+// IsFinal reports whether the job can no longer change
+func (s PurgeJobStatus) IsFinal() bool {
+	return s == PurgeJobStatusCompleted || s == PurgeJobStatusFailed
+}
+
+// PurgeJob is the background deletion of a client together with all of its clips, optionally after
+// exporting the clips. Jobs are kept after they are done, so that the outcome can be looked up.
+type PurgeJob struct {
+	ID             string
+	ClientID       string
+	ClientName     string // Display name of the client when the job was started, the client itself is gone afterwards
+	Export         bool   // Whether the clips are exported before they are deleted
+	Status         PurgeJobStatus
+	TotalClips     int        // Number of clips handled in the current phase
+	ProcessedClips int        // Number of clips exported or deleted so far in the current phase
+	ExportFile     string     // Name of the export file in the export directory, empty if there is none
+	Error          string     // Reason the job failed, empty otherwise
+	RequestedBy    string     // Dashboard user who started the job
+	CreatedAt      time.Time  // Time the job was started
+	UpdatedAt      time.Time  // Time the progress was last updated
+	FinishedAt     *time.Time // Time the job completed or failed
+}
+
+// Percent returns the progress of the current phase in percent
+func (j *PurgeJob) Percent() int {
+	if j.Status == PurgeJobStatusCompleted {
+		return 100
+	}
+	if j.TotalClips == 0 {
+		return 0
+	}
+	return j.ProcessedClips * 100 / j.TotalClips
+}
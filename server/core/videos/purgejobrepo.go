@@ -0,0 +1,200 @@
+package videos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/yeti47/cryospy/server/core/ccc/db"
+)
+
+type PurgeJobRepository interface {
+	// Create stores a new purge job
+	Create(ctx context.Context, job *PurgeJob) error
+	// Update stores the progress and outcome of a purge job
+	Update(ctx context.Context, job *PurgeJob) error
+	// GetByID retrieves a purge job, or nil if it does not exist
+	GetByID(ctx context.Context, id string) (*PurgeJob, error)
+	// GetRecent retrieves the most recent purge jobs, newest first
+	GetRecent(ctx context.Context, limit int) ([]*PurgeJob, error)
+	// GetUnfinished retrieves the purge jobs that are neither completed nor failed, oldest first
+	GetUnfinished(ctx context.Context) ([]*PurgeJob, error)
+	// GetWithExport retrieves the purge jobs that still have an export file, oldest first
+	GetWithExport(ctx context.Context) ([]*PurgeJob, error)
+}
+
+// SQLitePurgeJobRepository implements PurgeJobRepository using SQLite
+type SQLitePurgeJobRepository struct {
+	db *sql.DB
+}
+
+// NewSQLitePurgeJobRepository creates a new SQLite-based PurgeJobRepository
+func NewSQLitePurgeJobRepository(db *sql.DB) (*SQLitePurgeJobRepository, error) {
+	repo := &SQLitePurgeJobRepository{db: db}
+	if err := repo.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return repo, nil
+}
+
+// createTables ensures that the required tables exist
+func (r *SQLitePurgeJobRepository) createTables() error {
+	createPurgeJobsTable := `
+	CREATE TABLE IF NOT EXISTS client_purge_jobs (
+		id TEXT PRIMARY KEY,
+		client_id TEXT NOT NULL,
+		client_name TEXT NOT NULL DEFAULT '',
+		export INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		total_clips INTEGER NOT NULL DEFAULT 0,
+		processed_clips INTEGER NOT NULL DEFAULT 0,
+		export_file TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		requested_by TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		finished_at TEXT
+	);`
+
+	_, err := r.db.Exec(createPurgeJobsTable)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// purgeJobColumns lists the columns selected for a PurgeJob, in the order expected by scanPurgeJob
+const purgeJobColumns = `id, client_id, client_name, export, status, total_clips, processed_clips, export_file, error, requested_by,
+		created_at, updated_at, finished_at`
+
+func scanPurgeJob(row rowScanner) (*PurgeJob, error) {
+	job := &PurgeJob{}
+	var exportInt int
+	var createdAtStr, updatedAtStr string
+	var finishedAtStr sql.NullString
+	err := row.Scan(
+		&job.ID, &job.ClientID, &job.ClientName, &exportInt, &job.Status, &job.TotalClips, &job.ProcessedClips,
+		&job.ExportFile, &job.Error, &job.RequestedBy, &createdAtStr, &updatedAtStr, &finishedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Export = db.IntToBool(exportInt)
+
+	job.CreatedAt, err = db.StringToTime(createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at timestamp: %w", err)
+	}
+
+	job.UpdatedAt, err = db.StringToTime(updatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at timestamp: %w", err)
+	}
+
+	if finishedAtStr.Valid {
+		finishedAt, err := db.StringToTime(finishedAtStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse finished_at timestamp: %w", err)
+		}
+		job.FinishedAt = &finishedAt
+	}
+
+	return job, nil
+}
+
+func (r *SQLitePurgeJobRepository) Create(ctx context.Context, job *PurgeJob) error {
+	query := `
+	INSERT INTO client_purge_jobs (id, client_id, client_name, export, status, total_clips, processed_clips, export_file, error,
+		requested_by, created_at, updated_at, finished_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		job.ID, job.ClientID, job.ClientName, db.BoolToInt(job.Export), job.Status, job.TotalClips, job.ProcessedClips,
+		job.ExportFile, job.Error, job.RequestedBy,
+		db.TimeToString(job.CreatedAt), db.TimeToString(job.UpdatedAt), db.TimePtrToString(job.FinishedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create purge job: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLitePurgeJobRepository) Update(ctx context.Context, job *PurgeJob) error {
+	query := `
+	UPDATE client_purge_jobs
+	SET status = ?, total_clips = ?, processed_clips = ?, export_file = ?, error = ?, updated_at = ?, finished_at = ?
+	WHERE id = ?`
+
+	result, err := r.db.ExecContext(ctx, query,
+		job.Status, job.TotalClips, job.ProcessedClips, job.ExportFile, job.Error,
+		db.TimeToString(job.UpdatedAt), db.TimePtrToString(job.FinishedAt),
+		job.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update purge job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("purge job with ID %s not found", job.ID)
+	}
+
+	return nil
+}
+
+func (r *SQLitePurgeJobRepository) GetByID(ctx context.Context, id string) (*PurgeJob, error) {
+	query := `SELECT ` + purgeJobColumns + ` FROM client_purge_jobs WHERE id = ?`
+
+	job, err := scanPurgeJob(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get purge job: %w", err)
+	}
+
+	return job, nil
+}
+
+func (r *SQLitePurgeJobRepository) GetRecent(ctx context.Context, limit int) ([]*PurgeJob, error) {
+	query := `SELECT ` + purgeJobColumns + ` FROM client_purge_jobs ORDER BY created_at DESC LIMIT ?`
+	return r.queryPurgeJobs(ctx, query, limit)
+}
+
+func (r *SQLitePurgeJobRepository) GetUnfinished(ctx context.Context) ([]*PurgeJob, error) {
+	query := `SELECT ` + purgeJobColumns + ` FROM client_purge_jobs WHERE status NOT IN (?, ?) ORDER BY created_at`
+	return r.queryPurgeJobs(ctx, query, PurgeJobStatusCompleted, PurgeJobStatusFailed)
+}
+
+func (r *SQLitePurgeJobRepository) GetWithExport(ctx context.Context) ([]*PurgeJob, error) {
+	query := `SELECT ` + purgeJobColumns + ` FROM client_purge_jobs WHERE export_file != '' ORDER BY created_at`
+	return r.queryPurgeJobs(ctx, query)
+}
+
+func (r *SQLitePurgeJobRepository) queryPurgeJobs(ctx context.Context, query string, args ...any) ([]*PurgeJob, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purge jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*PurgeJob
+	for rows.Next() {
+		job, err := scanPurgeJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purge job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
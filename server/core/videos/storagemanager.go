@@ -7,7 +7,7 @@ import (
 
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
 	"github.com/yeti47/cryospy/server/core/clients"
-	"github.com/yeti47/cryospy/server/core/notifications"
+	"github.com/yeti47/cryospy/server/core/events"
 )
 
 const bytesInMegabyte = 1024 * 1024
@@ -18,38 +18,42 @@ type StorageInfo struct {
 	UsagePercent        float64 // Percentage of storage used (0-100)
 }
 
+// StorageManagerSettings holds the settings of the storage manager
+type StorageManagerSettings struct {
+	WarningThreshold float64 // Fraction of the storage limit above which capacity warnings are published (0 for none)
+}
+
 type StorageManager interface {
 	StoreClip(ctx context.Context, clip *Clip) error
 	GetStorageInfo(ctx context.Context, clientID string) (*StorageInfo, error)
 }
 
 type storageManager struct {
-	logger         logging.Logger
-	clipRepo       ClipRepository
-	clientRepo     clients.ClientRepository
-	notifier       notifications.StorageNotifier
-	motionNotifier notifications.MotionNotifier
+	logger     logging.Logger
+	clipRepo   ClipRepository
+	clientRepo clients.ClientRepository
+	bus        events.Bus
+	settings   StorageManagerSettings
 
 	// Mutex map for per-client storage limit operations only
 	clientStorageMutexes sync.Map // map[string]*sync.Mutex
 }
 
-func NewStorageManager(logger logging.Logger, clipRepo ClipRepository, clientRepo clients.ClientRepository, notifier notifications.StorageNotifier, motionNotifier notifications.MotionNotifier) StorageManager {
+// NewStorageManager creates a StorageManager that publishes stored and evicted clips, detected motion and the
+// storage capacity of clients on the bus
+func NewStorageManager(logger logging.Logger, clipRepo ClipRepository, clientRepo clients.ClientRepository, bus events.Bus, settings StorageManagerSettings) StorageManager {
 	if logger == nil {
 		logger = logging.NopLogger
 	}
-	if notifier == nil {
-		notifier = notifications.NopStorageNotifier
-	}
-	if motionNotifier == nil {
-		motionNotifier = notifications.NopMotionNotifier
+	if bus == nil {
+		bus = events.NopBus
 	}
 	return &storageManager{
 		logger:               logger,
 		clipRepo:             clipRepo,
 		clientRepo:           clientRepo,
-		notifier:             notifier,
-		motionNotifier:       motionNotifier,
+		bus:                  bus,
+		settings:             settings,
 		clientStorageMutexes: sync.Map{},
 	}
 }
@@ -79,14 +83,7 @@ func (s *storageManager) StoreClip(ctx context.Context, clip *Clip) error {
 			return err
 		}
 
-		// Send motion notification if clip has motion
-		if clip.HasMotion {
-			err = s.motionNotifier.NotifyMotionDetected(clip.ClientID, clip.Title, clip.TimeStamp)
-			if err != nil {
-				s.logger.Warn("failed to send motion detection notification", "error", err, "client_id", clip.ClientID)
-			}
-		}
-
+		s.publishClipStored(clip)
 		return nil
 	}
 
@@ -107,19 +104,13 @@ func (s *storageManager) StoreClip(ctx context.Context, clip *Clip) error {
 
 	capacityExceeded := (usageMegaBytes + newClipSizeMegaBytes) > totalMegaBytes
 
-	if s.notifier.ShouldWarn(usageMegaBytes, totalMegaBytes) && !capacityExceeded {
-		err := s.notifier.NotifyCapacityWarning(clip.ClientID, usageMegaBytes, totalMegaBytes)
-		if err != nil {
-			s.logger.Warn("failed to send capacity warning", "error", err, "client_id", clip.ClientID)
-		}
+	if s.shouldWarn(usageMegaBytes, totalMegaBytes) && !capacityExceeded {
+		s.bus.Publish(events.CapacityWarning{ClientID: clip.ClientID, UsedMegaBytes: usageMegaBytes, TotalMegaBytes: totalMegaBytes})
 	}
 
 	if capacityExceeded {
 		s.logger.Warn("storage capacity exceeded, deleting oldest clips", "client_id", clip.ClientID)
-		err := s.notifier.NotifyCapacityReached(clip.ClientID, usageMegaBytes, totalMegaBytes)
-		if err != nil {
-			s.logger.Warn("failed to send capacity reached notification", "error", err, "client_id", clip.ClientID)
-		}
+		s.bus.Publish(events.CapacityReached{ClientID: clip.ClientID, UsedMegaBytes: usageMegaBytes, TotalMegaBytes: totalMegaBytes})
 	}
 
 	for (usageMegaBytes + newClipSizeMegaBytes) > totalMegaBytes {
@@ -146,6 +137,7 @@ func (s *storageManager) StoreClip(ctx context.Context, clip *Clip) error {
 			break
 		}
 		s.logger.Info("deleted oldest clip to free up space", "clip_id", oldestClip.ID, "client_id", clip.ClientID)
+		s.bus.Publish(events.ClipEvicted{ClientID: oldestClip.ClientID, ClipID: oldestClip.ID, ClipTitle: oldestClip.Title, TimeStamp: oldestClip.TimeStamp})
 
 		// Refresh usage after deletion
 		usageBytes, err = s.clipRepo.GetTotalStorageUsage(ctx, clip.ClientID)
@@ -162,15 +154,31 @@ func (s *storageManager) StoreClip(ctx context.Context, clip *Clip) error {
 		return err
 	}
 
-	// Send motion notification if clip has motion
-	if clip.HasMotion {
-		err = s.motionNotifier.NotifyMotionDetected(clip.ClientID, clip.Title, clip.TimeStamp)
-		if err != nil {
-			s.logger.Warn("failed to send motion detection notification", "error", err, "client_id", clip.ClientID)
-		}
+	s.publishClipStored(clip)
+	return nil
+}
+
+// shouldWarn returns true if the storage usage is above the warning threshold
+func (s *storageManager) shouldWarn(usedMegaBytes int64, totalMegaBytes int64) bool {
+	if s.settings.WarningThreshold <= 0 || totalMegaBytes == 0 {
+		return false
 	}
+	return float64(usedMegaBytes)/float64(totalMegaBytes) >= s.settings.WarningThreshold
+}
 
-	return nil
+// publishClipStored publishes a stored clip, and the detected motion if the clip has motion
+func (s *storageManager) publishClipStored(clip *Clip) {
+	s.bus.Publish(events.ClipStored{
+		ClientID:  clip.ClientID,
+		ClipID:    clip.ID,
+		ClipTitle: clip.Title,
+		TimeStamp: clip.TimeStamp,
+		HasMotion: clip.HasMotion,
+		SizeBytes: int64(len(clip.EncryptedVideo)),
+	})
+	if clip.HasMotion {
+		s.bus.Publish(events.MotionDetected{ClientID: clip.ClientID, ClipID: clip.ID, ClipTitle: clip.Title, TimeStamp: clip.TimeStamp})
+	}
 }
 
 func (s *storageManager) GetStorageInfo(ctx context.Context, clientID string) (*StorageInfo, error) {
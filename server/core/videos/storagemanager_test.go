@@ -11,95 +11,52 @@ import (
 	"github.com/yeti47/cryospy/server/core/ccc/db"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
 	"github.com/yeti47/cryospy/server/core/clients"
-	"github.com/yeti47/cryospy/server/core/notifications"
+	"github.com/yeti47/cryospy/server/core/events"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// mockStorageNotifier is a test implementation of StorageNotifier
-type mockStorageNotifier struct {
-	shouldWarnThreshold float64
-	capacityWarnings    []capacityNotification
-	capacityReached     []capacityNotification
-	shouldWarnCalled    []shouldWarnCall
+// recordingBus is a test implementation of events.Bus that records the published events
+type recordingBus struct {
+	mutex     sync.Mutex
+	published []events.Event
 }
 
-type capacityNotification struct {
-	clientID       string
-	usedMegaBytes  int64
-	totalMegaBytes int64
+func (b *recordingBus) Publish(event events.Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.published = append(b.published, event)
 }
 
-type shouldWarnCall struct {
-	usedMegaBytes  int64
-	totalMegaBytes int64
-	result         bool
-}
-
-func newMockStorageNotifier(shouldWarnThreshold float64) *mockStorageNotifier {
-	return &mockStorageNotifier{
-		shouldWarnThreshold: shouldWarnThreshold,
-		capacityWarnings:    make([]capacityNotification, 0),
-		capacityReached:     make([]capacityNotification, 0),
-		shouldWarnCalled:    make([]shouldWarnCall, 0),
+func (b *recordingBus) Subscribe(name string, handler events.Handler, eventTypes ...string) {}
+func (b *recordingBus) Close()                                                              {}
+
+// publishedOfType returns the published events of type T
+func publishedOfType[T events.Event](b *recordingBus) []T {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var matching []T
+	for _, event := range b.published {
+		if e, ok := event.(T); ok {
+			matching = append(matching, e)
+		}
 	}
+	return matching
 }
 
-func (m *mockStorageNotifier) NotifyCapacityReached(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
-	m.capacityReached = append(m.capacityReached, capacityNotification{
-		clientID:       clientID,
-		usedMegaBytes:  usedMegaBytes,
-		totalMegaBytes: totalMegaBytes,
-	})
-	return nil
-}
-
-func (m *mockStorageNotifier) NotifyCapacityWarning(clientID string, usedMegaBytes int64, totalMegaBytes int64) error {
-	m.capacityWarnings = append(m.capacityWarnings, capacityNotification{
-		clientID:       clientID,
-		usedMegaBytes:  usedMegaBytes,
-		totalMegaBytes: totalMegaBytes,
-	})
-	return nil
-}
-
-func (m *mockStorageNotifier) ShouldWarn(usedMegaBytes int64, totalMegaBytes int64) bool {
-	result := float64(usedMegaBytes)/float64(totalMegaBytes) >= m.shouldWarnThreshold
-	m.shouldWarnCalled = append(m.shouldWarnCalled, shouldWarnCall{
-		usedMegaBytes:  usedMegaBytes,
-		totalMegaBytes: totalMegaBytes,
-		result:         result,
-	})
-	return result
-}
-
-// mockMotionNotifier is a test implementation of MotionNotifier
-type mockMotionNotifier struct {
-	motionNotifications []motionNotification
-}
-
-type motionNotification struct {
-	clientID  string
-	clipTitle string
-	timestamp time.Time
+func (b *recordingBus) capacityWarnings() []events.CapacityWarning {
+	return publishedOfType[events.CapacityWarning](b)
 }
 
-func newMockMotionNotifier() *mockMotionNotifier {
-	return &mockMotionNotifier{
-		motionNotifications: make([]motionNotification, 0),
-	}
+func (b *recordingBus) capacityReached() []events.CapacityReached {
+	return publishedOfType[events.CapacityReached](b)
 }
 
-func (m *mockMotionNotifier) NotifyMotionDetected(clientID string, clipTitle string, timestamp time.Time) error {
-	m.motionNotifications = append(m.motionNotifications, motionNotification{
-		clientID:  clientID,
-		clipTitle: clipTitle,
-		timestamp: timestamp,
-	})
-	return nil
+func (b *recordingBus) motionDetected() []events.MotionDetected {
+	return publishedOfType[events.MotionDetected](b)
 }
 
-func setupStorageManagerTest(t *testing.T) (*storageManager, *SQLiteClipRepository, *clients.SQLiteClientRepository, *mockStorageNotifier, *mockMotionNotifier, func()) {
+func setupStorageManagerTest(t *testing.T) (*storageManager, *SQLiteClipRepository, *clients.SQLiteClientRepository, *recordingBus, func()) {
 	// Create in-memory database
 	testDB, err := db.NewInMemoryDB()
 	if err != nil {
@@ -119,17 +76,15 @@ func setupStorageManagerTest(t *testing.T) (*storageManager, *SQLiteClipReposito
 		t.Fatalf("Failed to create client repository: %v", err)
 	}
 
-	// Create mock notifiers
-	storageNotifier := newMockStorageNotifier(0.8) // 80% threshold
-	motionNotifier := newMockMotionNotifier()
+	bus := &recordingBus{}
 
 	// Create storage manager
 	sm := &storageManager{
 		logger:               logging.NopLogger,
 		clipRepo:             clipRepo,
 		clientRepo:           clientRepo,
-		notifier:             storageNotifier,
-		motionNotifier:       motionNotifier,
+		bus:                  bus,
+		settings:             StorageManagerSettings{WarningThreshold: 0.8},
 		clientStorageMutexes: sync.Map{},
 	}
 
@@ -137,11 +92,11 @@ func setupStorageManagerTest(t *testing.T) (*storageManager, *SQLiteClipReposito
 		testDB.Close()
 	}
 
-	return sm, clipRepo, clientRepo, storageNotifier, motionNotifier, cleanup
+	return sm, clipRepo, clientRepo, bus, cleanup
 }
 
 // setupConcurrencyTest creates a test environment with SQLite optimizations for concurrency testing
-func setupConcurrencyTest(t *testing.T) (*storageManager, *SQLiteClipRepository, *clients.SQLiteClientRepository, *mockStorageNotifier, *mockMotionNotifier, func()) {
+func setupConcurrencyTest(t *testing.T) (*storageManager, *SQLiteClipRepository, *clients.SQLiteClientRepository, *recordingBus, func()) {
 	// Create in-memory database with SQLite optimizations for concurrency
 	// Use shared cache to allow multiple connections to the same in-memory database
 	dbConn, err := sql.Open("sqlite3", "file::memory:?cache=shared&_journal_mode=WAL&_busy_timeout=30000&_synchronous=NORMAL&_cache_size=10000")
@@ -167,17 +122,15 @@ func setupConcurrencyTest(t *testing.T) (*storageManager, *SQLiteClipRepository,
 		t.Fatalf("Failed to create client repository: %v", err)
 	}
 
-	// Create mock notifiers
-	storageNotifier := newMockStorageNotifier(0.8) // 80% threshold
-	motionNotifier := newMockMotionNotifier()
+	bus := &recordingBus{}
 
 	// Create storage manager
 	sm := &storageManager{
 		logger:               logging.NopLogger,
 		clipRepo:             clipRepo,
 		clientRepo:           clientRepo,
-		notifier:             storageNotifier,
-		motionNotifier:       motionNotifier,
+		bus:                  bus,
+		settings:             StorageManagerSettings{WarningThreshold: 0.8},
 		clientStorageMutexes: sync.Map{},
 	}
 
@@ -185,7 +138,7 @@ func setupConcurrencyTest(t *testing.T) (*storageManager, *SQLiteClipRepository,
 		dbConn.Close()
 	}
 
-	return sm, clipRepo, clientRepo, storageNotifier, motionNotifier, cleanup
+	return sm, clipRepo, clientRepo, bus, cleanup
 }
 
 func createTestClientForStorage(id string, storageLimitMB int) *clients.Client {
@@ -232,7 +185,7 @@ func createTestClipForStorage(id, clientID string, videoSizeBytes int) *Clip {
 }
 
 func TestStorageManager_StoreClip_UnlimitedStorage(t *testing.T) {
-	sm, _, clientRepo, notifier, _, cleanup := setupStorageManagerTest(t)
+	sm, _, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -253,16 +206,16 @@ func TestStorageManager_StoreClip_UnlimitedStorage(t *testing.T) {
 	}
 
 	// Verify no notifications were sent
-	if len(notifier.capacityWarnings) > 0 {
-		t.Errorf("Expected no capacity warnings, got %d", len(notifier.capacityWarnings))
+	if len(bus.capacityWarnings()) > 0 {
+		t.Errorf("Expected no capacity warnings, got %d", len(bus.capacityWarnings()))
 	}
-	if len(notifier.capacityReached) > 0 {
-		t.Errorf("Expected no capacity reached notifications, got %d", len(notifier.capacityReached))
+	if len(bus.capacityReached()) > 0 {
+		t.Errorf("Expected no capacity reached notifications, got %d", len(bus.capacityReached()))
 	}
 }
 
 func TestStorageManager_StoreClip_WithinLimits(t *testing.T) {
-	sm, _, clientRepo, notifier, _, cleanup := setupStorageManagerTest(t)
+	sm, _, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -283,13 +236,13 @@ func TestStorageManager_StoreClip_WithinLimits(t *testing.T) {
 	}
 
 	// Verify no capacity reached notifications
-	if len(notifier.capacityReached) > 0 {
-		t.Errorf("Expected no capacity reached notifications, got %d", len(notifier.capacityReached))
+	if len(bus.capacityReached()) > 0 {
+		t.Errorf("Expected no capacity reached notifications, got %d", len(bus.capacityReached()))
 	}
 }
 
 func TestStorageManager_StoreClip_WarningThreshold(t *testing.T) {
-	sm, clipRepo, clientRepo, notifier, _, cleanup := setupStorageManagerTest(t)
+	sm, clipRepo, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -320,29 +273,29 @@ func TestStorageManager_StoreClip_WarningThreshold(t *testing.T) {
 	}
 
 	// Verify warning was sent (current usage of 8MB is at 80% threshold)
-	if len(notifier.capacityWarnings) != 1 {
-		t.Errorf("Expected 1 capacity warning, got %d", len(notifier.capacityWarnings))
+	if len(bus.capacityWarnings()) != 1 {
+		t.Errorf("Expected 1 capacity warning, got %d", len(bus.capacityWarnings()))
 	} else {
-		warning := notifier.capacityWarnings[0]
-		if warning.clientID != "client-warning" {
-			t.Errorf("Expected client ID 'client-warning', got '%s'", warning.clientID)
+		warning := bus.capacityWarnings()[0]
+		if warning.ClientID != "client-warning" {
+			t.Errorf("Expected client ID 'client-warning', got '%s'", warning.ClientID)
 		}
-		if warning.usedMegaBytes != 8 { // Current usage before adding the new clip
-			t.Errorf("Expected used MB to be 8, got %d", warning.usedMegaBytes)
+		if warning.UsedMegaBytes != 8 { // Current usage before adding the new clip
+			t.Errorf("Expected used MB to be 8, got %d", warning.UsedMegaBytes)
 		}
-		if warning.totalMegaBytes != 10 {
-			t.Errorf("Expected total MB to be 10, got %d", warning.totalMegaBytes)
+		if warning.TotalMegaBytes != 10 {
+			t.Errorf("Expected total MB to be 10, got %d", warning.TotalMegaBytes)
 		}
 	}
 
 	// Verify no capacity reached notifications
-	if len(notifier.capacityReached) > 0 {
-		t.Errorf("Expected no capacity reached notifications, got %d", len(notifier.capacityReached))
+	if len(bus.capacityReached()) > 0 {
+		t.Errorf("Expected no capacity reached notifications, got %d", len(bus.capacityReached()))
 	}
 }
 
 func TestStorageManager_StoreClip_CapacityExceeded_DeletesOldestClips(t *testing.T) {
-	sm, clipRepo, clientRepo, notifier, _, cleanup := setupStorageManagerTest(t)
+	sm, clipRepo, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -390,8 +343,14 @@ func TestStorageManager_StoreClip_CapacityExceeded_DeletesOldestClips(t *testing
 	}
 
 	// Verify capacity reached notification was sent
-	if len(notifier.capacityReached) != 1 {
-		t.Errorf("Expected 1 capacity reached notification, got %d", len(notifier.capacityReached))
+	if len(bus.capacityReached()) != 1 {
+		t.Errorf("Expected 1 capacity reached notification, got %d", len(bus.capacityReached()))
+	}
+
+	// Verify the eviction of the oldest clip was published
+	evicted := publishedOfType[events.ClipEvicted](bus)
+	if len(evicted) != 1 || evicted[0].ClipID != "oldest-clip" || evicted[0].ClientID != "client-exceeded" {
+		t.Errorf("Expected the oldest clip to be published as evicted, got %+v", evicted)
 	}
 
 	// Verify the oldest clip was deleted
@@ -432,7 +391,7 @@ func TestStorageManager_StoreClip_CapacityExceeded_DeletesOldestClips(t *testing
 }
 
 func TestStorageManager_StoreClip_CapacityExceeded_NoOldClipsToDelete(t *testing.T) {
-	sm, _, clientRepo, notifier, _, cleanup := setupStorageManagerTest(t)
+	sm, _, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -453,8 +412,8 @@ func TestStorageManager_StoreClip_CapacityExceeded_NoOldClipsToDelete(t *testing
 	}
 
 	// Verify capacity reached notification was sent
-	if len(notifier.capacityReached) != 1 {
-		t.Errorf("Expected 1 capacity reached notification, got %d", len(notifier.capacityReached))
+	if len(bus.capacityReached()) != 1 {
+		t.Errorf("Expected 1 capacity reached notification, got %d", len(bus.capacityReached()))
 	}
 
 	// Verify the clip was still added (even though it exceeds capacity)
@@ -468,7 +427,7 @@ func TestStorageManager_StoreClip_CapacityExceeded_NoOldClipsToDelete(t *testing
 }
 
 func TestStorageManager_StoreClip_ClientNotFound(t *testing.T) {
-	sm, _, _, _, _, cleanup := setupStorageManagerTest(t)
+	sm, _, _, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -483,7 +442,7 @@ func TestStorageManager_StoreClip_ClientNotFound(t *testing.T) {
 }
 
 func TestStorageManager_StoreClip_WarningNotSentWhenCapacityExceeded(t *testing.T) {
-	sm, clipRepo, clientRepo, notifier, _, cleanup := setupStorageManagerTest(t)
+	sm, clipRepo, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -514,18 +473,18 @@ func TestStorageManager_StoreClip_WarningNotSentWhenCapacityExceeded(t *testing.
 	}
 
 	// Verify no warning was sent (because capacity was exceeded)
-	if len(notifier.capacityWarnings) > 0 {
-		t.Errorf("Expected no capacity warnings when capacity exceeded, got %d", len(notifier.capacityWarnings))
+	if len(bus.capacityWarnings()) > 0 {
+		t.Errorf("Expected no capacity warnings when capacity exceeded, got %d", len(bus.capacityWarnings()))
 	}
 
 	// Verify capacity reached notification was sent
-	if len(notifier.capacityReached) != 1 {
-		t.Errorf("Expected 1 capacity reached notification, got %d", len(notifier.capacityReached))
+	if len(bus.capacityReached()) != 1 {
+		t.Errorf("Expected 1 capacity reached notification, got %d", len(bus.capacityReached()))
 	}
 }
 
 func TestStorageManager_StoreClip_MultipleClipDeletionLoop(t *testing.T) {
-	sm, clipRepo, clientRepo, _, _, cleanup := setupStorageManagerTest(t)
+	sm, clipRepo, clientRepo, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -594,23 +553,23 @@ func TestStorageManager_StoreClip_MultipleClipDeletionLoop(t *testing.T) {
 }
 
 func TestNewStorageManager_WithNilLogger(t *testing.T) {
-	_, clipRepo, clientRepo, _, _, cleanup := setupStorageManagerTest(t)
+	_, clipRepo, clientRepo, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
-	sm := NewStorageManager(nil, clipRepo, clientRepo, nil, nil)
+	sm := NewStorageManager(nil, clipRepo, clientRepo, nil, StorageManagerSettings{})
 
-	// Verify that the storage manager was created successfully with NopLogger and NopStorageNotifier
+	// Verify that the storage manager was created successfully with NopLogger and NopBus
 	smImpl := sm.(*storageManager)
 	if smImpl.logger != logging.NopLogger {
 		t.Error("Expected NopLogger to be used when nil logger is provided")
 	}
-	if smImpl.notifier != notifications.NopStorageNotifier {
-		t.Error("Expected NopStorageNotifier to be used when nil notifier is provided")
+	if smImpl.bus != events.NopBus {
+		t.Error("Expected NopBus to be used when nil bus is provided")
 	}
 }
 
 func TestStorageManager_StoreClip_ExactCapacityLimit(t *testing.T) {
-	sm, _, clientRepo, notifier, _, cleanup := setupStorageManagerTest(t)
+	sm, _, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -631,8 +590,8 @@ func TestStorageManager_StoreClip_ExactCapacityLimit(t *testing.T) {
 	}
 
 	// Since the new clip exactly matches the limit, there should be no capacity exceeded
-	if len(notifier.capacityReached) > 0 {
-		t.Errorf("Expected no capacity reached notifications for exact limit, got %d", len(notifier.capacityReached))
+	if len(bus.capacityReached()) > 0 {
+		t.Errorf("Expected no capacity reached notifications for exact limit, got %d", len(bus.capacityReached()))
 	}
 
 	// Verify the clip was added
@@ -646,7 +605,7 @@ func TestStorageManager_StoreClip_ExactCapacityLimit(t *testing.T) {
 }
 
 func TestStorageManager_ConcurrentUploads(t *testing.T) {
-	sm, clipRepo, clientRepo, notifier, _, cleanup := setupConcurrencyTest(t)
+	sm, clipRepo, clientRepo, bus, cleanup := setupConcurrencyTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -731,7 +690,7 @@ func TestStorageManager_ConcurrentUploads(t *testing.T) {
 	}
 
 	// Verify that capacity reached notifications were sent
-	if len(notifier.capacityReached) == 0 {
+	if len(bus.capacityReached()) == 0 {
 		t.Error("Expected capacity reached notifications to be sent")
 	}
 
@@ -752,7 +711,7 @@ func TestStorageManager_ConcurrentUploadsStressTest(t *testing.T) {
 		t.Skip("Skipping stress test in short mode")
 	}
 
-	sm, clipRepo, clientRepo, _, _, cleanup := setupConcurrencyTest(t)
+	sm, clipRepo, clientRepo, _, cleanup := setupConcurrencyTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -834,7 +793,7 @@ func TestStorageManager_ConcurrentUploadsStressTest(t *testing.T) {
 }
 
 func TestStorageManager_ConcurrentStorageLimitRaceCondition(t *testing.T) {
-	sm, clipRepo, clientRepo, _, _, cleanup := setupConcurrencyTest(t)
+	sm, clipRepo, clientRepo, _, cleanup := setupConcurrencyTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -967,7 +926,7 @@ func TestStorageManager_ConcurrentStorageLimitRaceCondition(t *testing.T) {
 }
 
 func TestStorageManager_GetStorageInfo_UnlimitedStorage(t *testing.T) {
-	sm, clipRepo, clientRepo, _, _, cleanup := setupStorageManagerTest(t)
+	sm, clipRepo, clientRepo, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -1011,7 +970,7 @@ func TestStorageManager_GetStorageInfo_UnlimitedStorage(t *testing.T) {
 }
 
 func TestStorageManager_GetStorageInfo_LimitedStorage(t *testing.T) {
-	sm, clipRepo, clientRepo, _, _, cleanup := setupStorageManagerTest(t)
+	sm, clipRepo, clientRepo, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -1057,7 +1016,7 @@ func TestStorageManager_GetStorageInfo_LimitedStorage(t *testing.T) {
 }
 
 func TestStorageManager_GetStorageInfo_EmptyStorage(t *testing.T) {
-	sm, _, clientRepo, _, _, cleanup := setupStorageManagerTest(t)
+	sm, _, clientRepo, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -1091,7 +1050,7 @@ func TestStorageManager_GetStorageInfo_EmptyStorage(t *testing.T) {
 }
 
 func TestStorageManager_GetStorageInfo_FullStorage(t *testing.T) {
-	sm, clipRepo, clientRepo, _, _, cleanup := setupStorageManagerTest(t)
+	sm, clipRepo, clientRepo, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -1137,7 +1096,7 @@ func TestStorageManager_GetStorageInfo_FullStorage(t *testing.T) {
 }
 
 func TestStorageManager_GetStorageInfo_ClientNotFound(t *testing.T) {
-	sm, _, _, _, _, cleanup := setupStorageManagerTest(t)
+	sm, _, _, _, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -1155,7 +1114,7 @@ func TestStorageManager_GetStorageInfo_ClientNotFound(t *testing.T) {
 }
 
 func TestStorageManager_MotionNotification(t *testing.T) {
-	sm, _, clientRepo, _, motionNotifier, cleanup := setupStorageManagerTest(t)
+	sm, _, clientRepo, bus, cleanup := setupStorageManagerTest(t)
 	defer cleanup()
 
 	ctx := context.Background()
@@ -1179,18 +1138,18 @@ func TestStorageManager_MotionNotification(t *testing.T) {
 	}
 
 	// Verify motion notification was sent
-	if len(motionNotifier.motionNotifications) != 1 {
-		t.Errorf("Expected 1 motion notification, got %d", len(motionNotifier.motionNotifications))
+	if len(bus.motionDetected()) != 1 {
+		t.Errorf("Expected 1 motion notification, got %d", len(bus.motionDetected()))
 	} else {
-		notification := motionNotifier.motionNotifications[0]
-		if notification.clientID != "client-motion" {
-			t.Errorf("Expected client ID 'client-motion', got '%s'", notification.clientID)
+		notification := bus.motionDetected()[0]
+		if notification.ClientID != "client-motion" {
+			t.Errorf("Expected client ID 'client-motion', got '%s'", notification.ClientID)
 		}
-		if notification.clipTitle != clipWithMotion.Title {
-			t.Errorf("Expected clip title '%s', got '%s'", clipWithMotion.Title, notification.clipTitle)
+		if notification.ClipTitle != clipWithMotion.Title {
+			t.Errorf("Expected clip title '%s', got '%s'", clipWithMotion.Title, notification.ClipTitle)
 		}
-		if !notification.timestamp.Equal(clipWithMotion.TimeStamp) {
-			t.Errorf("Expected timestamp %v, got %v", clipWithMotion.TimeStamp, notification.timestamp)
+		if !notification.TimeStamp.Equal(clipWithMotion.TimeStamp) {
+			t.Errorf("Expected timestamp %v, got %v", clipWithMotion.TimeStamp, notification.TimeStamp)
 		}
 	}
 
@@ -1206,7 +1165,13 @@ func TestStorageManager_MotionNotification(t *testing.T) {
 	}
 
 	// Verify no additional motion notification was sent
-	if len(motionNotifier.motionNotifications) != 1 {
-		t.Errorf("Expected still 1 motion notification (no new ones), got %d", len(motionNotifier.motionNotifications))
+	if len(bus.motionDetected()) != 1 {
+		t.Errorf("Expected still 1 motion notification (no new ones), got %d", len(bus.motionDetected()))
+	}
+
+	// Verify both clips were published as stored
+	stored := publishedOfType[events.ClipStored](bus)
+	if len(stored) != 2 || !stored[0].HasMotion || stored[1].HasMotion || stored[1].SizeBytes != 1*1024*1024 {
+		t.Errorf("Expected both clips to be published as stored, got %+v", stored)
 	}
 }
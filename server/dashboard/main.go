@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"text/template"
 	"time"
 
@@ -14,17 +17,31 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/commands"
 	"github.com/yeti47/cryospy/server/core/config"
 	"github.com/yeti47/cryospy/server/core/encryption"
+	"github.com/yeti47/cryospy/server/core/events"
+	"github.com/yeti47/cryospy/server/core/notifications"
+	"github.com/yeti47/cryospy/server/core/pairing"
 	"github.com/yeti47/cryospy/server/core/streaming"
+	"github.com/yeti47/cryospy/server/core/twofactor"
+	"github.com/yeti47/cryospy/server/core/users"
 	"github.com/yeti47/cryospy/server/core/videos"
 	dashboard_sessions "github.com/yeti47/cryospy/server/dashboard/sessions"
 	"github.com/yeti47/cryospy/server/dashboard/web/handlers"
 	"github.com/yeti47/cryospy/server/dashboard/web/middleware"
 
+	"github.com/yeti47/cryospy/server/core/ccc/auth"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/ccc/pki"
 )
 
+// shutdownTimeout is how long a shutdown waits for the requests in flight, such as live streams
+const shutdownTimeout = 30 * time.Second
+
+// exportCleanupInterval is how often the exports of deleted clients are checked for expiry
+const exportCleanupInterval = time.Hour
+
 func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig("")
@@ -62,24 +79,121 @@ func main() {
 		logger.Error("Failed to create MEK repository", err)
 		os.Exit(1)
 	}
+	twoFactorRepo, err := twofactor.NewSQLiteTwoFactorRepository(dbConn)
+	if err != nil {
+		logger.Error("Failed to create two-factor repository", err)
+		os.Exit(1)
+	}
+	userRepo, err := users.NewSQLiteUserRepository(dbConn)
+	if err != nil {
+		logger.Error("Failed to create user repository", err)
+		os.Exit(1)
+	}
 	clientRepo, err := clients.NewSQLiteClientRepository(dbConn)
 	if err != nil {
 		logger.Error("Failed to create client repository", err)
 		os.Exit(1)
 	}
+	clientGroupRepo, err := clients.NewSQLiteClientGroupRepository(dbConn)
+	if err != nil {
+		logger.Error("Failed to create client group repository", err)
+		os.Exit(1)
+	}
+	settingsVersionRepo, err := clients.NewSQLiteSettingsVersionRepository(dbConn)
+	if err != nil {
+		logger.Error("Failed to create settings version repository", err)
+		os.Exit(1)
+	}
 	clipRepo, err := videos.NewSQLiteClipRepository(dbConn)
 	if err != nil {
 		logger.Error("Failed to create clip repository", err)
 		os.Exit(1)
 	}
+	purgeJobRepo, err := videos.NewSQLitePurgeJobRepository(dbConn)
+	if err != nil {
+		logger.Error("Failed to create purge job repository", err)
+		os.Exit(1)
+	}
 
 	// Set up services
 	encryptor := encryption.NewAESEncryptor()
 	mekService := encryption.NewMekService(logger, mekRepo, encryptor)
-	clientService := clients.NewClientService(logger, clientRepo, encryptor)
+	twoFactorService := twofactor.NewTwoFactorService(logger, twoFactorRepo, encryptor)
+	userService := users.NewUserService(logger, userRepo, mekService, encryptor)
+	clientService := clients.NewClientService(logger, clientRepo, settingsVersionRepo, clientGroupRepo, encryptor)
+	clientGroupService := clients.NewClientGroupService(logger, clientGroupRepo, clientRepo, settingsVersionRepo)
+
+	armStateRepo, err := clients.NewSQLiteArmStateRepository(dbConn)
+	if err != nil {
+		log.Fatalf("Failed to create arm state repository: %v", err)
+	}
+	armModeService := clients.NewArmModeService(logger, armStateRepo, clientRepo)
+
+	// The capture server records the heartbeats and sends the offline notifications, the dashboard only shows them
+	heartbeatSettings := config.DefaultHeartbeatSettings()
+	if cfg.HeartbeatSettings != nil {
+		heartbeatSettings = *cfg.HeartbeatSettings
+	}
+	heartbeatRepo, err := clients.NewSQLiteHeartbeatRepository(dbConn)
+	if err != nil {
+		log.Fatalf("Failed to create heartbeat repository: %v", err)
+	}
+	heartbeatService := clients.NewHeartbeatService(logger, heartbeatRepo, clientRepo, nil, clients.HeartbeatSettings{
+		OfflineAfter: time.Duration(heartbeatSettings.OfflineAfterMinutes) * time.Minute,
+	})
+
+	// The dashboard issues commands, the capture server hands them out to the clients
+	commandSettings := config.DefaultCommandSettings()
+	if cfg.CommandSettings != nil {
+		commandSettings = *cfg.CommandSettings
+	}
+	commandRepo, err := commands.NewSQLiteCommandRepository(dbConn)
+	if err != nil {
+		log.Fatalf("Failed to create command repository: %v", err)
+	}
+	commandService := commands.NewCommandService(logger, commandRepo, clientRepo, encryptor, nil, commands.CommandSettings{
+		PendingLifetime: time.Duration(commandSettings.PendingLifetimeMinutes) * time.Minute,
+		ResultTimeout:   time.Duration(commandSettings.ResultTimeoutMinutes) * time.Minute,
+	})
+
+	pairingSettings := config.DefaultPairingSettings()
+	if cfg.PairingSettings != nil {
+		pairingSettings = *cfg.PairingSettings
+	}
+	pairingServiceSettings := pairing.PairingSettings{
+		CodeLifetime: time.Duration(pairingSettings.CodeLifetimeMinutes) * time.Minute,
+		ServerURL:    pairingSettings.CaptureServerURL,
+	}
+
+	// Issue client certificates from the CryoSpy CA if the capture server requires them
+	var certService clients.ClientCertificateService
+	if cfg.ClientCertificatesEnabled() {
+		ca, err := pki.LoadOrCreateCertificateAuthority(logger, cfg.ResolveCADirectory())
+		if err != nil {
+			logger.Error("Failed to load certificate authority", err)
+			os.Exit(1)
+		}
+		validity := time.Duration(cfg.CaptureTLSSettings.ClientCertificateValidityDays) * 24 * time.Hour
+		certService = clients.NewClientCertificateService(logger, clientRepo, ca, validity)
+
+		// Devices pin the CA while enrolling, since they do not have the CA bundle yet
+		pairingServiceSettings.CAFingerprint = ca.Fingerprint()
+	}
+
+	pairingRepo, err := pairing.NewSQLitePairingCodeRepository(dbConn)
+	if err != nil {
+		logger.Error("Failed to create pairing code repository", err)
+		os.Exit(1)
+	}
+	pairingService := pairing.NewPairingService(logger, pairingRepo, encryptor, pairingServiceSettings)
 	clipReader := videos.NewClipReader(logger, clipRepo, encryptor)
 	clipDeleter := videos.NewClipDeleter(logger, clipRepo)
-	storageManager := videos.NewStorageManager(logger, clipRepo, clientRepo, nil, nil)
+	storageManager := videos.NewStorageManager(logger, clipRepo, clientRepo, nil, videos.StorageManagerSettings{})
+
+	// Purges of deleted clients run in the dashboard; the ones interrupted by a restart continue here
+	clientRemover := videos.NewClientRemover(logger, clientService, clipRepo, purgeJobRepo, encryptor, cfg.ResolveExportDirectory())
+	clientRemover.ResumePurges()
+	go deleteExpiredExports(clientRemover, cfg.ResolveExportLifetime(), exportCleanupInterval)
 
 	// Set up streaming services
 	normalizationSettings := streaming.DefaultNormalizationSettings()
@@ -112,8 +226,109 @@ func main() {
 		logger.Error("Failed to get or create session key", err)
 		os.Exit(1)
 	}
-	sessionStore := sessions.NewCookieStore(sessionKey)
-	mekStoreFactory := dashboard_sessions.NewMekStoreFactory(sessionStore)
+	sessionSettings := config.DefaultDashboardSessionSettings()
+	if cfg.DashboardSessionSettings != nil {
+		sessionSettings = *cfg.DashboardSessionSettings
+	}
+	var sessionPersistence dashboard_sessions.SessionPersistence
+	if sessionSettings.PersistSessions {
+		sessionPersistence, err = dashboard_sessions.NewEncryptedFileSessionPersistence(sessionSettings.PersistencePath, sessionKey, encryptor)
+		if err != nil {
+			logger.Error("Failed to set up session persistence", err)
+			os.Exit(1)
+		}
+	}
+	sessionStore, err := dashboard_sessions.NewMemorySessionStore(logger, dashboard_sessions.SessionSettings{
+		IdleTimeout:      time.Duration(sessionSettings.IdleTimeoutMinutes) * time.Minute,
+		AbsoluteLifetime: time.Duration(sessionSettings.AbsoluteLifetimeHours) * time.Hour,
+	}, sessionPersistence)
+	if err != nil {
+		logger.Error("Failed to create session store", err)
+		os.Exit(1)
+	}
+
+	// The cookie only carries the signed session ID; the MEK stays in the server-side session store
+	cookieStore := sessions.NewCookieStore(sessionKey)
+	cookieStore.Options.HttpOnly = true
+	cookieStore.Options.SameSite = http.SameSiteLaxMode
+	cookieStore.Options.MaxAge = sessionSettings.AbsoluteLifetimeHours * 3600
+	sessionCookie := dashboard_sessions.NewSessionCookie(cookieStore)
+	mekStoreFactory := dashboard_sessions.NewMekStoreFactory(sessionStore, sessionCookie)
+	pendingLoginStore := dashboard_sessions.NewMemoryPendingLoginStore(dashboard_sessions.DefaultPendingLoginSettings())
+	// The recovery key created during setup has to be acknowledged within this time, or the admin logs in with the password
+	pendingSetupStore := dashboard_sessions.NewMemoryPendingSetupStore(30 * time.Minute)
+
+	// The capture server records authentication failures and blocks IP addresses.
+	// The dashboard only reviews and lifts blocks, so the tracking settings do not apply here.
+	ipBlocklist, err := auth.NewSQLiteFailureTracker(logger, dbConn, auth.AutoDisableSettings{}, auth.IPBlockSettings{})
+	if err != nil {
+		logger.Error("Failed to create IP blocklist", err)
+		os.Exit(1)
+	}
+
+	// Set up brute-force protection for the login
+	loginSettings := config.DefaultDashboardLoginSettings()
+	if cfg.DashboardLoginSettings != nil {
+		loginSettings = *cfg.DashboardLoginSettings
+	}
+	loginThrottle := auth.NewMemoryLoginThrottle(auth.LoginThrottleSettings{
+		BaseDelay:        time.Duration(loginSettings.BaseDelaySeconds) * time.Second,
+		MaxDelay:         time.Duration(loginSettings.MaxDelaySeconds) * time.Second,
+		LockoutThreshold: loginSettings.LockoutThreshold,
+		LockoutDuration:  time.Duration(loginSettings.LockoutMinutes) * time.Minute,
+		TimeWindow:       time.Duration(loginSettings.TimeWindowMinutes) * time.Minute,
+	})
+
+	// Lockouts are published on the bus and reported to the same recipient as capture client authentication failures.
+	// The bus is closed on shutdown, after the last request has been handled.
+	eventBus := events.NewBus(logger, events.BusSettings{})
+	if cfg.SMTPSettings != nil && cfg.AuthEventSettings != nil && cfg.AuthEventSettings.NotificationRecipient != "" {
+		emailSender := notifications.NewSmtpSender(
+			cfg.SMTPSettings.Host,
+			cfg.SMTPSettings.Port,
+			cfg.SMTPSettings.Username,
+			cfg.SMTPSettings.Password,
+			cfg.SMTPSettings.FromAddr,
+		)
+		emailNotifier := notifications.NewEmailAuthNotifier(notifications.AuthNotificationSettings{
+			Recipient:        cfg.AuthEventSettings.NotificationRecipient,
+			MinInterval:      time.Duration(cfg.AuthEventSettings.MinIntervalMinutes) * time.Minute,
+			FailureThreshold: cfg.AuthEventSettings.NotificationThreshold,
+		}, emailSender, nil, logger) // Lockouts concern dashboard accounts, so no client directory is needed
+		notifications.SubscribeNotifiers(eventBus, "email", logger, notifications.Notifiers{Auth: emailNotifier})
+		logger.Info("Dashboard login lockout notifications enabled", "recipient", cfg.AuthEventSettings.NotificationRecipient)
+	}
+	if webhookSettings := cfg.WebhookSettings; webhookSettings != nil && len(webhookSettings.URLs) > 0 {
+		// The capture server posts all other events; the dashboard only reports its own lockouts
+		webhookNotifier := notifications.NewWebhookNotifier(notifications.WebhookSettings{
+			URLs:         webhookSettings.URLs,
+			Secret:       webhookSettings.Secret,
+			Events:       webhookSettings.Events,
+			MinInterval:  time.Duration(webhookSettings.MinIntervalMinutes) * time.Minute,
+			MaxRetries:   webhookSettings.MaxRetries,
+			RetryBackoff: time.Duration(webhookSettings.RetryBackoffSeconds) * time.Second,
+			Timeout:      time.Duration(webhookSettings.TimeoutSeconds) * time.Second,
+		}, nil, logger)
+		notifications.SubscribeNotifiers(eventBus, "webhook", logger, notifications.Notifiers{Auth: webhookNotifier})
+		logger.Info("Dashboard login lockout webhooks enabled", "urls", len(webhookSettings.URLs))
+	}
+	if pushSettings := cfg.PushNotificationSettings; pushSettings != nil {
+		subscribePushNotifier := func(sender notifications.PushSender, priorities map[string]int) {
+			pushNotifier := notifications.NewPushNotifier(notifications.PushNotificationSettings{
+				MinInterval: time.Duration(pushSettings.MinIntervalMinutes) * time.Minute,
+				Priorities:  priorities,
+			}, sender, nil, logger)
+			notifications.SubscribeNotifiers(eventBus, "push", logger, notifications.Notifiers{Auth: pushNotifier})
+		}
+		if ntfy := pushSettings.Ntfy; ntfy != nil {
+			subscribePushNotifier(notifications.NewNtfySender(ntfy.ServerURL, ntfy.Topic, ntfy.AccessToken), ntfy.Priorities)
+			logger.Info("Dashboard login lockout ntfy notifications enabled", "topic", ntfy.Topic)
+		}
+		if gotify := pushSettings.Gotify; gotify != nil {
+			subscribePushNotifier(notifications.NewGotifySender(gotify.ServerURL, gotify.AppToken), gotify.Priorities)
+			logger.Info("Dashboard login lockout Gotify notifications enabled", "server", gotify.ServerURL)
+		}
+	}
 
 	// Set up Gin engine
 	router := initializeGin(cfg)
@@ -121,25 +336,59 @@ func main() {
 	// Serve static files
 	router.Static("/static", "web/static")
 
-	// Set up templates
-	router.HTMLRender = createTemplateRenderer()
+	// Set up templates. Every template can reach the CSRF token of the request as .CSRFToken.
+	router.HTMLRender = middleware.NewCSRFRenderer(createTemplateRenderer())
 
 	// Set up handlers
-	authHandler := handlers.NewAuthHandler(logger, mekService, mekStoreFactory)
-	clientHandler := handlers.NewClientHandler(logger, clientService, storageManager, mekStoreFactory)
-	clipHandler := handlers.NewClipHandler(logger, clipReader, clipDeleter, clientService, mekStoreFactory)
-	streamHandler := handlers.NewStreamHandler(logger, streamingService, clientService, mekStoreFactory)
+	authHandler := handlers.NewAuthHandler(logger, mekService, userService, twoFactorService, mekStoreFactory, sessionStore, pendingLoginStore, pendingSetupStore, sessionCookie, loginThrottle, eventBus)
+	clientHandler := handlers.NewClientHandler(logger, clientService, clientGroupService, storageManager, mekStoreFactory, certService, pairingService, heartbeatService, clientRemover)
+	clientRemovalHandler := handlers.NewClientRemovalHandler(logger, clientService, clientRemover, clipReader, storageManager, mekStoreFactory)
+	clipHandler := handlers.NewClipHandler(logger, clipReader, clipDeleter, clientService, clientGroupService, mekStoreFactory)
+	streamHandler := handlers.NewStreamHandler(logger, streamingService, clientService, clientGroupService, mekStoreFactory)
+	groupHandler := handlers.NewGroupHandler(logger, clientService, clientGroupService)
+	scheduleHandler := handlers.NewScheduleHandler(logger, clientService)
+	clientDetailsHandler := handlers.NewClientDetailsHandler(logger, clientService)
+	bulkEditHandler := handlers.NewBulkEditHandler(logger, clientService)
+	settingsHistoryHandler := handlers.NewSettingsHistoryHandler(logger, clientService)
+	armHandler := handlers.NewArmHandler(logger, armModeService, clientService)
+	commandHandler := handlers.NewCommandHandler(logger, commandService, clientService, mekStoreFactory)
+	keyHandler := handlers.NewKeyHandler(logger, mekService)
+	sessionHandler := handlers.NewSessionHandler(logger, sessionStore, sessionCookie)
+	twoFactorHandler := handlers.NewTwoFactorHandler(logger, twoFactorService, mekStoreFactory)
+	blocklistHandler := handlers.NewBlocklistHandler(logger, ipBlocklist, clientService)
+	userHandler := handlers.NewUserHandler(logger, userService, twoFactorService, sessionStore, mekStoreFactory)
 
 	// Set up middleware
-	authMiddleware := middleware.NewAuthMiddleware(logger, mekService, mekStoreFactory)
+	authMiddleware := middleware.NewAuthMiddleware(logger, mekService, userService, sessionStore, sessionCookie)
+	requireOperator := authMiddleware.RequireRole(users.RoleOperator)
+	requireAdmin := authMiddleware.RequireRole(users.RoleAdmin)
+	csrfMiddleware := middleware.NewCSRFMiddleware(logger, sessionCookie)
+
+	// The automation API authenticates with a bearer token instead of a session, so it is registered ahead of the CSRF check
+	if cfg.AutomationAPISettings != nil && cfg.AutomationAPISettings.Token != "" {
+		apiTokenMiddleware := middleware.NewAPITokenMiddleware(logger, cfg.AutomationAPISettings.Token)
+		apiGroup := router.Group("/api")
+		apiGroup.Use(apiTokenMiddleware.RequireToken)
+		{
+			apiGroup.GET("/arm-mode", armHandler.GetArmModeAPI)
+			apiGroup.PUT("/arm-mode", armHandler.SetArmModeAPI)
+		}
+		logger.Info("Automation API enabled")
+	}
+
+	// Every route below, including login and setup, requires a CSRF token on POST requests
+	router.Use(csrfMiddleware.Protect)
 
 	// Public routes (authentication)
 	authGroup := router.Group("/auth")
 	{
 		authGroup.GET("/login", authHandler.ShowLogin)
 		authGroup.POST("/login", authHandler.Login)
+		authGroup.GET("/2fa", authHandler.ShowTwoFactor)
+		authGroup.POST("/2fa", authHandler.VerifyTwoFactor)
 		authGroup.GET("/setup", authHandler.ShowSetup)
 		authGroup.POST("/setup", authHandler.Setup)
+		authGroup.POST("/setup/confirm", authHandler.ConfirmRecoveryKey)
 		authGroup.GET("/logout", authHandler.Logout)
 	}
 
@@ -158,14 +407,48 @@ func main() {
 		})
 
 		clientGroup := authedGroup.Group("/clients")
+		clientGroup.Use(requireOperator)
 		{
 			clientGroup.GET("", clientHandler.ListClients)
-			clientGroup.GET("/new", clientHandler.ShowNewClientForm)
-			clientGroup.POST("/new", clientHandler.CreateClient)
+			clientGroup.GET("/new", requireAdmin, clientHandler.ShowNewClientForm)
+			clientGroup.POST("/new", requireAdmin, clientHandler.CreateClient)
+			clientGroup.POST("/clone", requireAdmin, clientHandler.CloneClient)
+			clientGroup.GET("/bulk", bulkEditHandler.ShowBulkEdit)
+			clientGroup.POST("/bulk", bulkEditHandler.BulkEdit)
+			clientGroup.GET("/purges/:jobId", clientRemovalHandler.GetPurgeJob)
+			clientGroup.GET("/purges/:jobId/export", requireAdmin, clientRemovalHandler.DownloadExport)
+			clientGroup.POST("/purges/:jobId/export/delete", requireAdmin, clientRemovalHandler.DeleteExport)
 			clientGroup.POST("/:id/settings", clientHandler.UpdateClientSettings)
+			clientGroup.GET("/:id/details", clientDetailsHandler.ShowDetails)
+			clientGroup.POST("/:id/details", clientDetailsHandler.UpdateDetails)
+			clientGroup.GET("/:id/schedule", scheduleHandler.ShowSchedule)
+			clientGroup.POST("/:id/schedule", scheduleHandler.UpdateSchedule)
+			clientGroup.GET("/:id/settings-history", settingsHistoryHandler.ShowHistory)
+			clientGroup.POST("/:id/settings-history/:version/rollback", settingsHistoryHandler.Rollback)
+			clientGroup.GET("/:id/commands", commandHandler.ShowCommands)
+			clientGroup.POST("/:id/commands", requireAdmin, commandHandler.IssueCommand)
+			clientGroup.GET("/:id/commands/:commandId/attachment", commandHandler.GetAttachment)
 			clientGroup.POST("/:id/disable", clientHandler.DisableClient)
 			clientGroup.POST("/:id/enable", clientHandler.EnableClient)
-			clientGroup.POST("/:id/delete", clientHandler.DeleteClient)
+			clientGroup.GET("/:id/delete", requireAdmin, clientRemovalHandler.ShowDelete)
+			clientGroup.POST("/:id/delete", requireAdmin, clientRemovalHandler.Delete)
+			clientGroup.POST("/:id/rotate-secret", requireAdmin, clientHandler.RotateClientSecret)
+		}
+
+		groupsGroup := authedGroup.Group("/groups")
+		groupsGroup.Use(requireOperator)
+		{
+			groupsGroup.GET("", groupHandler.ListGroups)
+			groupsGroup.POST("", groupHandler.CreateGroup)
+			groupsGroup.POST("/:id", groupHandler.UpdateGroup)
+			groupsGroup.POST("/:id/delete", groupHandler.DeleteGroup)
+		}
+
+		armGroup := authedGroup.Group("/arm")
+		{
+			armGroup.GET("", armHandler.ShowArmMode)
+			armGroup.POST("", requireOperator, armHandler.SetArmMode)
+			armGroup.POST("/behaviors", requireOperator, armHandler.UpdateBehaviors)
 		}
 
 		clipGroup := authedGroup.Group("/clips")
@@ -175,7 +458,7 @@ func main() {
 			clipGroup.GET("/:id/thumbnail", clipHandler.GetThumbnail)
 			clipGroup.GET("/:id/video", clipHandler.GetVideo)
 			clipGroup.GET("/:id/download", clipHandler.DownloadVideo)
-			clipGroup.POST("/delete", clipHandler.DeleteClips)
+			clipGroup.POST("/delete", requireOperator, clipHandler.DeleteClips)
 		}
 
 		streamGroup := authedGroup.Group("/stream")
@@ -185,13 +468,99 @@ func main() {
 			streamGroup.GET("/:clientId/playlist.m3u8", streamHandler.GetPlaylist)
 			streamGroup.GET("/:clientId/segments/:clipId", streamHandler.GetSegment)
 		}
+
+		sessionGroup := authedGroup.Group("/sessions")
+		{
+			sessionGroup.GET("", sessionHandler.ListSessions)
+			sessionGroup.POST("/revoke", sessionHandler.RevokeSession)
+			sessionGroup.POST("/revoke-all", sessionHandler.RevokeAllSessions)
+		}
+
+		securityGroup := authedGroup.Group("/security")
+		{
+			securityGroup.GET("/2fa", twoFactorHandler.ShowTwoFactor)
+			securityGroup.POST("/2fa/enroll", twoFactorHandler.BeginEnrollment)
+			securityGroup.POST("/2fa/confirm", twoFactorHandler.ConfirmEnrollment)
+			securityGroup.POST("/2fa/disable", twoFactorHandler.Disable)
+		}
+
+		keyGroup := authedGroup.Group("/keys")
+		keyGroup.Use(requireAdmin)
+		{
+			keyGroup.GET("", keyHandler.ListKeys)
+			keyGroup.POST("/password", keyHandler.AddPasswordSlot)
+			keyGroup.POST("/recovery", keyHandler.AddRecoveryKeySlot)
+			keyGroup.POST("/primary", keyHandler.ChangePassword)
+			keyGroup.POST("/:id/delete", keyHandler.RemoveSlot)
+		}
+
+		userGroup := authedGroup.Group("/users")
+		userGroup.Use(requireAdmin)
+		{
+			userGroup.GET("", userHandler.ListUsers)
+			userGroup.POST("", userHandler.CreateUser)
+			userGroup.POST("/:id/role", userHandler.UpdateRole)
+			userGroup.POST("/:id/password", userHandler.ResetPassword)
+			userGroup.POST("/:id/delete", userHandler.DeleteUser)
+		}
+
+		blocklistGroup := authedGroup.Group("/blocklist")
+		blocklistGroup.Use(requireAdmin)
+		{
+			blocklistGroup.GET("", blocklistHandler.ListBlocks)
+			blocklistGroup.POST("/unblock", blocklistHandler.Unblock)
+		}
+
+		accountGroup := authedGroup.Group("/account")
+		{
+			accountGroup.GET("", userHandler.ShowAccount)
+			accountGroup.POST("/password", userHandler.ChangePassword)
+		}
 	}
 
-	// Start server
+	// Start server. SIGINT and SIGTERM shut it down gracefully.
 	addr := fmt.Sprintf("%s:%d", cfg.WebAddr, cfg.WebPort)
-	logger.Info("Starting server on " + addr)
-	if err := router.Run(addr); err != nil {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		logger.Info("Starting server on " + addr)
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErrors:
 		logger.Error("Failed to start server", err)
+		return
+	case <-ctx.Done():
+	}
+
+	// Closing the bus after the last request waits until the lockout notifications have been sent
+	logger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down server gracefully", err)
+	}
+	eventBus.Close()
+	logger.Info("Server stopped")
+}
+
+// deleteExpiredExports deletes the exports of deleted clients that were not downloaded in time, for as long as the
+// dashboard runs
+func deleteExpiredExports(clientRemover videos.ClientRemover, lifetime, interval time.Duration) {
+	clientRemover.DeleteExpiredExports(lifetime)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		clientRemover.DeleteExpiredExports(lifetime)
 	}
 }
 
@@ -263,6 +632,20 @@ func createTemplateRenderer() multitemplate.Renderer {
 
 			return fmt.Sprintf("%dm %ds", minutes, remainingSeconds)
 		},
+		"formatUptime": func(seconds int64) string {
+			d := time.Duration(seconds) * time.Second
+			days := int(d.Hours()) / 24
+			hours := int(d.Hours()) % 24
+			minutes := int(d.Minutes()) % 60
+
+			if days > 0 {
+				return fmt.Sprintf("%dd %dh", days, hours)
+			}
+			if hours > 0 {
+				return fmt.Sprintf("%dh %dm", hours, minutes)
+			}
+			return fmt.Sprintf("%dm", minutes)
+		},
 		"formatStoragePercent": func(percent float64) string {
 			return fmt.Sprintf("%.1f%%", percent)
 		},
@@ -290,13 +673,29 @@ func createTemplateRenderer() multitemplate.Renderer {
 	r.AddFromFilesFuncs("layout", funcMap, "web/templates/layout.html")
 	r.AddFromFilesFuncs("home", funcMap, "web/templates/layout.html", "web/templates/home.html")
 	r.AddFromFilesFuncs("login", funcMap, "web/templates/layout.html", "web/templates/login.html")
+	r.AddFromFilesFuncs("login-2fa", funcMap, "web/templates/layout.html", "web/templates/login-2fa.html")
 	r.AddFromFilesFuncs("setup", funcMap, "web/templates/layout.html", "web/templates/setup.html")
 	r.AddFromFilesFuncs("clients", funcMap, "web/templates/layout.html", "web/templates/clients.html")
 	r.AddFromFilesFuncs("new-client", funcMap, "web/templates/layout.html", "web/templates/new-client.html")
+	r.AddFromFilesFuncs("bulk-edit", funcMap, "web/templates/layout.html", "web/templates/bulk-edit.html")
+	r.AddFromFilesFuncs("client-delete", funcMap, "web/templates/layout.html", "web/templates/client-delete.html")
+	r.AddFromFilesFuncs("client-details", funcMap, "web/templates/layout.html", "web/templates/client-details.html")
+	r.AddFromFilesFuncs("client-schedule", funcMap, "web/templates/layout.html", "web/templates/client-schedule.html")
+	r.AddFromFilesFuncs("client-commands", funcMap, "web/templates/layout.html", "web/templates/client-commands.html")
+	r.AddFromFilesFuncs("settings-history", funcMap, "web/templates/layout.html", "web/templates/settings-history.html")
+	r.AddFromFilesFuncs("arm", funcMap, "web/templates/layout.html", "web/templates/arm.html")
+	r.AddFromFilesFuncs("groups", funcMap, "web/templates/layout.html", "web/templates/groups.html")
+	r.AddFromFilesFuncs("client-secret", funcMap, "web/templates/layout.html", "web/templates/client-secret.html")
 	r.AddFromFilesFuncs("clips", funcMap, "web/templates/layout.html", "web/templates/clips.html")
 	r.AddFromFilesFuncs("clip-detail", funcMap, "web/templates/layout.html", "web/templates/clip-detail.html")
 	r.AddFromFilesFuncs("stream-selection", funcMap, "web/templates/layout.html", "web/templates/stream-selection.html")
 	r.AddFromFilesFuncs("stream", funcMap, "web/templates/layout.html", "web/templates/stream.html")
+	r.AddFromFilesFuncs("keys", funcMap, "web/templates/layout.html", "web/templates/keys.html")
+	r.AddFromFilesFuncs("two-factor", funcMap, "web/templates/layout.html", "web/templates/two-factor.html")
+	r.AddFromFilesFuncs("users", funcMap, "web/templates/layout.html", "web/templates/users.html")
+	r.AddFromFilesFuncs("account", funcMap, "web/templates/layout.html", "web/templates/account.html")
+	r.AddFromFilesFuncs("sessions", funcMap, "web/templates/layout.html", "web/templates/sessions.html")
+	r.AddFromFilesFuncs("blocklist", funcMap, "web/templates/layout.html", "web/templates/blocklist.html")
 	r.AddFromFilesFuncs("error", funcMap, "web/templates/layout.html", "web/templates/error.html")
 	return r
 }
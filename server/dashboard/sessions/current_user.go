@@ -0,0 +1,45 @@
+package sessions
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/users"
+)
+
+const currentUserKey = "cryospy.currentUser"
+
+// CurrentUser is the authenticated account of a request together with its current role
+type CurrentUser struct {
+	Identity
+	Role      users.Role
+	SessionID string
+}
+
+// Can reports whether the user has at least the permissions of the required role
+func (u *CurrentUser) Can(required users.Role) bool {
+	return u != nil && u.Role.Allows(required)
+}
+
+// IsAdmin reports whether the user may manage clients, users and keys
+func (u *CurrentUser) IsAdmin() bool {
+	return u.Can(users.RoleAdmin)
+}
+
+// CanOperate reports whether the user may delete clips and change client settings
+func (u *CurrentUser) CanOperate() bool {
+	return u.Can(users.RoleOperator)
+}
+
+// SetCurrentUser stores the authenticated account in the request context
+func SetCurrentUser(c *gin.Context, user *CurrentUser) {
+	c.Set(currentUserKey, user)
+}
+
+// GetCurrentUser returns the authenticated account of the request, or nil if there is none
+func GetCurrentUser(c *gin.Context) *CurrentUser {
+	value, ok := c.Get(currentUserKey)
+	if !ok {
+		return nil
+	}
+	user, _ := value.(*CurrentUser)
+	return user
+}
@@ -4,67 +4,77 @@ import (
 	"errors"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"github.com/yeti47/cryospy/server/core/encryption"
 )
 
-const (
-	sessionName = "cryospy-dashboard-session"
-	mekKey      = "mek"
-)
-
-// GorillaMekStore implements the MekStore interface using gorilla sessions
-type GorillaMekStore struct {
-	store   sessions.Store
-	request *gin.Context
+// SessionMekStore implements the MekStore interface using the server-side session store.
+// The MEK never leaves the server; the browser only holds the session ID.
+type SessionMekStore struct {
+	sessionStore  SessionStore
+	sessionCookie *SessionCookie
+	request       *gin.Context
 }
 
-// NewGorillaMekStore creates a new GorillaMekStore for a specific request
-func NewGorillaMekStore(store sessions.Store, c *gin.Context) encryption.MekStore {
-	return &GorillaMekStore{
-		store:   store,
-		request: c,
+// NewSessionMekStore creates a new SessionMekStore for a specific request
+func NewSessionMekStore(sessionStore SessionStore, sessionCookie *SessionCookie, c *gin.Context) encryption.MekStore {
+	return &SessionMekStore{
+		sessionStore:  sessionStore,
+		sessionCookie: sessionCookie,
+		request:       c,
 	}
 }
 
-// GetMek retrieves the MEK from the session
-func (s *GorillaMekStore) GetMek() ([]byte, error) {
-	session, err := s.store.Get(s.request.Request, sessionName)
+// GetMek retrieves the MEK from the session of the request
+func (s *SessionMekStore) GetMek() ([]byte, error) {
+	id, err := s.sessionCookie.GetSessionID(s.request)
 	if err != nil {
 		return nil, err
 	}
-
-	value, ok := session.Values[mekKey]
-	if !ok {
+	if id == "" {
 		return nil, errors.New("MEK not found in session")
 	}
 
-	mek, ok := value.([]byte)
-	if !ok {
-		return nil, errors.New("invalid MEK format in session")
+	session, err := s.sessionStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, errors.New("MEK not found in session")
 	}
 
-	return mek, nil
+	return session.Mek, nil
 }
 
-// SetMek sets the MEK in the session
-func (s *GorillaMekStore) SetMek(mekValue []byte) error {
-	session, err := s.store.Get(s.request.Request, sessionName)
-	if err != nil {
-		return err
+// SetMek starts a new session of the built-in admin account holding the MEK
+func (s *SessionMekStore) SetMek(mekValue []byte) error {
+	return StartSession(s.sessionStore, s.sessionCookie, s.request, mekValue, AdminIdentity())
+}
+
+// ClearMek revokes the session of the request
+func (s *SessionMekStore) ClearMek() error {
+	id, err := s.sessionCookie.GetSessionID(s.request)
+	if err == nil && id != "" {
+		if err := s.sessionStore.Revoke(id); err != nil {
+			return err
+		}
 	}
 
-	session.Values[mekKey] = mekValue
-	return session.Save(s.request.Request, s.request.Writer)
+	return s.sessionCookie.Clear(s.request)
 }
 
-// ClearMek removes the MEK from the session
-func (s *GorillaMekStore) ClearMek() error {
-	session, err := s.store.Get(s.request.Request, sessionName)
+// StartSession starts a new session of an account holding the MEK and stores its ID in the cookie.
+// Any previous session of the request is revoked so that session IDs are never reused across logins.
+func StartSession(sessionStore SessionStore, sessionCookie *SessionCookie, c *gin.Context, mekValue []byte, identity Identity) error {
+	if previousID, err := sessionCookie.GetSessionID(c); err == nil && previousID != "" {
+		if err := sessionStore.Revoke(previousID); err != nil {
+			return err
+		}
+	}
+
+	session, err := sessionStore.Create(mekValue, identity, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		return err
 	}
 
-	delete(session.Values, mekKey)
-	return session.Save(s.request.Request, s.request.Writer)
+	return sessionCookie.SetSessionID(c, session.ID)
 }
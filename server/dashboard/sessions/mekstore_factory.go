@@ -2,7 +2,6 @@ package sessions
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"github.com/yeti47/cryospy/server/core/encryption"
 )
 
@@ -10,8 +9,8 @@ import (
 type MekStoreFactory func(c *gin.Context) encryption.MekStore
 
 // NewMekStoreFactory creates a new MekStoreFactory.
-func NewMekStoreFactory(store sessions.Store) MekStoreFactory {
+func NewMekStoreFactory(sessionStore SessionStore, sessionCookie *SessionCookie) MekStoreFactory {
 	return func(c *gin.Context) encryption.MekStore {
-		return NewGorillaMekStore(store, c)
+		return NewSessionMekStore(sessionStore, sessionCookie, c)
 	}
 }
@@ -0,0 +1,122 @@
+package sessions
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// PendingLoginSettings controls how long a half-completed login stays valid
+type PendingLoginSettings struct {
+	Lifetime    time.Duration // Time allowed for entering the second factor
+	MaxAttempts int           // Number of wrong codes after which the pending login is discarded
+}
+
+// DefaultPendingLoginSettings returns the default settings for pending logins
+func DefaultPendingLoginSettings() PendingLoginSettings {
+	return PendingLoginSettings{
+		Lifetime:    5 * time.Minute,
+		MaxAttempts: 5,
+	}
+}
+
+// PendingLogin is a login that passed the password check but still needs a second factor
+type PendingLogin struct {
+	Mek       []byte   // MEK unlocked by the password
+	Identity  Identity // Account that logs in
+	createdAt time.Time
+	attempts  int
+}
+
+// PendingLoginStore keeps the unlocked MEK on the server between the password step and the second factor.
+// The MEK is only turned into a session once the second factor has been verified.
+type PendingLoginStore interface {
+	// Create stores the MEK and account of a login that passed the password check and returns its ID
+	Create(mek []byte, identity Identity) (string, error)
+	// Get returns a pending login, or nil if it does not exist or has expired
+	Get(id string) *PendingLogin
+	// RecordFailedAttempt counts a wrong code and reports whether the pending login is still usable
+	RecordFailedAttempt(id string) bool
+	// Delete removes a pending login
+	Delete(id string)
+}
+
+type memoryPendingLoginStore struct {
+	settings PendingLoginSettings
+	mu       sync.Mutex
+	logins   map[string]*PendingLogin
+	now      func() time.Time
+}
+
+// NewMemoryPendingLoginStore creates a PendingLoginStore that keeps pending logins in memory
+func NewMemoryPendingLoginStore(settings PendingLoginSettings) *memoryPendingLoginStore {
+	return &memoryPendingLoginStore{
+		settings: settings,
+		logins:   make(map[string]*PendingLogin),
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (s *memoryPendingLoginStore) Create(mek []byte, identity Identity) (string, error) {
+	id, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for existingID, login := range s.logins {
+		if s.isExpired(login, now) {
+			delete(s.logins, existingID)
+		}
+	}
+
+	s.logins[id] = &PendingLogin{Mek: slices.Clone(mek), Identity: identity, createdAt: now}
+	return id, nil
+}
+
+func (s *memoryPendingLoginStore) Get(id string) *PendingLogin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, ok := s.logins[id]
+	if !ok {
+		return nil
+	}
+	if s.isExpired(login, s.now()) {
+		delete(s.logins, id)
+		return nil
+	}
+	copied := *login
+	return &copied
+}
+
+func (s *memoryPendingLoginStore) RecordFailedAttempt(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, ok := s.logins[id]
+	if !ok {
+		return false
+	}
+
+	login.attempts++
+	if s.settings.MaxAttempts > 0 && login.attempts >= s.settings.MaxAttempts {
+		delete(s.logins, id)
+		return false
+	}
+	return true
+}
+
+func (s *memoryPendingLoginStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.logins, id)
+}
+
+func (s *memoryPendingLoginStore) isExpired(login *PendingLogin, now time.Time) bool {
+	return s.settings.Lifetime > 0 && now.Sub(login.createdAt) > s.settings.Lifetime
+}
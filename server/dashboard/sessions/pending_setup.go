@@ -0,0 +1,88 @@
+package sessions
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// PendingSetup is a completed setup whose recovery key has not been acknowledged yet
+type PendingSetup struct {
+	Mek         []byte // MEK unlocked by the setup password
+	RecoveryKey string // Recovery key created during setup, shown until it is acknowledged
+	createdAt   time.Time
+}
+
+// PendingSetupStore keeps the result of the setup on the server until the recovery key is acknowledged,
+// so that the confirmation does not need to carry any secret.
+type PendingSetupStore interface {
+	// Create stores the unlocked MEK and the recovery key of a setup and returns its ID
+	Create(mek []byte, recoveryKey string) (string, error)
+	// Get returns a pending setup, or nil if it does not exist or has expired
+	Get(id string) *PendingSetup
+	// Delete removes a pending setup
+	Delete(id string)
+}
+
+type memoryPendingSetupStore struct {
+	lifetime time.Duration
+	mu       sync.Mutex
+	setups   map[string]*PendingSetup
+	now      func() time.Time
+}
+
+// NewMemoryPendingSetupStore creates a PendingSetupStore that keeps pending setups in memory for the given lifetime
+func NewMemoryPendingSetupStore(lifetime time.Duration) *memoryPendingSetupStore {
+	return &memoryPendingSetupStore{
+		lifetime: lifetime,
+		setups:   make(map[string]*PendingSetup),
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (s *memoryPendingSetupStore) Create(mek []byte, recoveryKey string) (string, error) {
+	id, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for existingID, setup := range s.setups {
+		if s.isExpired(setup, now) {
+			delete(s.setups, existingID)
+		}
+	}
+
+	s.setups[id] = &PendingSetup{Mek: slices.Clone(mek), RecoveryKey: recoveryKey, createdAt: now}
+	return id, nil
+}
+
+func (s *memoryPendingSetupStore) Get(id string) *PendingSetup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	setup, ok := s.setups[id]
+	if !ok {
+		return nil
+	}
+	if s.isExpired(setup, s.now()) {
+		delete(s.setups, id)
+		return nil
+	}
+	copied := *setup
+	return &copied
+}
+
+func (s *memoryPendingSetupStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.setups, id)
+}
+
+func (s *memoryPendingSetupStore) isExpired(setup *PendingSetup, now time.Time) bool {
+	return s.lifetime > 0 && now.Sub(setup.createdAt) > s.lifetime
+}
@@ -0,0 +1,91 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/users"
+)
+
+const sessionIDLength = 32 // 256 bits of randomness per session ID
+
+// Session is an authenticated dashboard session that is held on the server.
+// Only the session ID is sent to the browser.
+type Session struct {
+	ID         string    `json:"id"`           // Random session ID, stored in the session cookie
+	UserID     string    `json:"user_id"`      // ID of the dashboard user, empty for the built-in admin account
+	Username   string    `json:"username"`     // Name of the dashboard user at login
+	Mek        []byte    `json:"mek"`          // Decrypted MEK unlocked at login
+	CreatedAt  time.Time `json:"created_at"`   // Time of login
+	LastSeenAt time.Time `json:"last_seen_at"` // Time of the last authenticated request
+	RemoteAddr string    `json:"remote_addr"`  // Client IP address at login
+	UserAgent  string    `json:"user_agent"`   // Browser user agent at login
+}
+
+// Identity identifies the dashboard account a session belongs to
+type Identity struct {
+	UserID   string // ID of the dashboard user, empty for the built-in admin account
+	Username string // Name of the dashboard user
+}
+
+// AdminIdentity returns the identity of the built-in admin account, which is unlocked by the
+// primary MEK password, an additional password slot or a recovery key
+func AdminIdentity() Identity {
+	return Identity{Username: users.AdminUsername}
+}
+
+// IsBuiltInAdmin reports whether the identity is the built-in admin account
+func (i Identity) IsBuiltInAdmin() bool {
+	return i.UserID == ""
+}
+
+// Identity returns the account the session belongs to.
+// Sessions created before multiple dashboard users belong to the built-in admin account.
+func (s *Session) Identity() Identity {
+	if s.UserID == "" {
+		return AdminIdentity()
+	}
+	return Identity{UserID: s.UserID, Username: s.Username}
+}
+
+// SessionSettings controls when sessions expire
+type SessionSettings struct {
+	IdleTimeout      time.Duration // Maximum time between two requests (0 for no idle timeout)
+	AbsoluteLifetime time.Duration // Maximum time since login (0 for no absolute lifetime)
+}
+
+// IsExpired reports whether the session has expired at the given time
+func (s *Session) IsExpired(now time.Time, settings SessionSettings) bool {
+	if settings.IdleTimeout > 0 && now.Sub(s.LastSeenAt) > settings.IdleTimeout {
+		return true
+	}
+	if settings.AbsoluteLifetime > 0 && now.Sub(s.CreatedAt) > settings.AbsoluteLifetime {
+		return true
+	}
+	return false
+}
+
+// ExpiresAt returns the time at which the session expires if there is no further activity.
+// The zero time is returned if the session never expires.
+func (s *Session) ExpiresAt(settings SessionSettings) time.Time {
+	var expiresAt time.Time
+	if settings.IdleTimeout > 0 {
+		expiresAt = s.LastSeenAt.Add(settings.IdleTimeout)
+	}
+	if settings.AbsoluteLifetime > 0 {
+		absoluteExpiry := s.CreatedAt.Add(settings.AbsoluteLifetime)
+		if expiresAt.IsZero() || absoluteExpiry.Before(expiresAt) {
+			expiresAt = absoluteExpiry
+		}
+	}
+	return expiresAt
+}
+
+func generateSessionID() (string, error) {
+	id := make([]byte, sessionIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
@@ -0,0 +1,145 @@
+package sessions
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	sessionName  = "cryospy-dashboard-session"
+	sessionIDKey = "sid"
+	pendingIDKey = "pending"
+	setupIDKey   = "setup"
+	csrfTokenKey = "csrf"
+	legacyMekKey = "mek" // older versions kept the MEK itself in the cookie
+)
+
+// SessionCookie reads and writes the session ID in the signed session cookie
+type SessionCookie struct {
+	store sessions.Store
+}
+
+// NewSessionCookie creates a SessionCookie backed by a gorilla cookie store
+func NewSessionCookie(store sessions.Store) *SessionCookie {
+	return &SessionCookie{store: store}
+}
+
+// GetSessionID returns the session ID of the request, or an empty string if there is none
+func (s *SessionCookie) GetSessionID(c *gin.Context) (string, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return "", err
+	}
+
+	id, _ := session.Values[sessionIDKey].(string)
+	return id, nil
+}
+
+// SetSessionID stores the session ID in the cookie
+func (s *SessionCookie) SetSessionID(c *gin.Context, id string) error {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil && session == nil {
+		return err
+	}
+
+	delete(session.Values, legacyMekKey)
+	delete(session.Values, pendingIDKey)
+	delete(session.Values, setupIDKey)
+	// A new CSRF token is issued after login so that a token seen before login cannot be reused
+	delete(session.Values, csrfTokenKey)
+	session.Values[sessionIDKey] = id
+	return session.Save(c.Request, c.Writer)
+}
+
+// Clear removes the session ID from the cookie
+func (s *SessionCookie) Clear(c *gin.Context) error {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil && session == nil {
+		return err
+	}
+
+	delete(session.Values, legacyMekKey)
+	delete(session.Values, sessionIDKey)
+	delete(session.Values, pendingIDKey)
+	delete(session.Values, setupIDKey)
+	delete(session.Values, csrfTokenKey)
+	session.Options.MaxAge = -1
+	return session.Save(c.Request, c.Writer)
+}
+
+// GetPendingLoginID returns the ID of a login that is waiting for its second factor, or an empty string
+func (s *SessionCookie) GetPendingLoginID(c *gin.Context) (string, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return "", err
+	}
+
+	id, _ := session.Values[pendingIDKey].(string)
+	return id, nil
+}
+
+// SetPendingLoginID stores the ID of a login that is waiting for its second factor
+func (s *SessionCookie) SetPendingLoginID(c *gin.Context, id string) error {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil && session == nil {
+		return err
+	}
+
+	if id == "" {
+		delete(session.Values, pendingIDKey)
+	} else {
+		session.Values[pendingIDKey] = id
+	}
+	return session.Save(c.Request, c.Writer)
+}
+
+// GetPendingSetupID returns the ID of a setup whose recovery key has not been acknowledged, or an empty string
+func (s *SessionCookie) GetPendingSetupID(c *gin.Context) (string, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return "", err
+	}
+
+	id, _ := session.Values[setupIDKey].(string)
+	return id, nil
+}
+
+// SetPendingSetupID stores the ID of a setup whose recovery key has not been acknowledged
+func (s *SessionCookie) SetPendingSetupID(c *gin.Context, id string) error {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil && session == nil {
+		return err
+	}
+
+	if id == "" {
+		delete(session.Values, setupIDKey)
+	} else {
+		session.Values[setupIDKey] = id
+	}
+	return session.Save(c.Request, c.Writer)
+}
+
+// EnsureCSRFToken returns the CSRF token of the cookie session, issuing a new one if necessary.
+// The token lives in the signed cookie, so it also covers the login and setup forms before a session exists.
+func (s *SessionCookie) EnsureCSRFToken(c *gin.Context) (string, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil && session == nil {
+		return "", err
+	}
+
+	if token, ok := session.Values[csrfTokenKey].(string); ok && token != "" {
+		return token, nil
+	}
+
+	// CSRF tokens have the same strength as session IDs
+	token, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	session.Values[csrfTokenKey] = token
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		return "", err
+	}
+	return token, nil
+}
@@ -0,0 +1,141 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+const sessionFileName = "cryospy_sessions.enc"
+
+// SessionPersistence stores sessions so that they survive a restart
+type SessionPersistence interface {
+	// Load returns all stored sessions, or none if nothing has been stored yet
+	Load() ([]*Session, error)
+	// Save replaces the stored sessions
+	Save(sessions []*Session) error
+}
+
+// sessionFile is the on-disk layout of the encrypted session file
+type sessionFile struct {
+	Salt []byte `json:"salt"` // Salt for deriving the file key from the session key
+	Data []byte `json:"data"` // AES-GCM encrypted JSON array of sessions
+}
+
+type encryptedFileSessionPersistence struct {
+	path       string
+	sessionKey []byte
+	encryptor  encryption.Encryptor
+	mu         sync.Mutex
+	salt       []byte
+	key        []byte
+}
+
+// NewEncryptedFileSessionPersistence creates a SessionPersistence that encrypts sessions at rest.
+// The file key is derived from the dashboard session key, so the file is useless without it.
+// If path is empty, the file is stored next to the session key in the user's home directory.
+func NewEncryptedFileSessionPersistence(path string, sessionKey []byte, encryptor encryption.Encryptor) (*encryptedFileSessionPersistence, error) {
+	if path == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, "cryospy", sessionFileName)
+	}
+
+	return &encryptedFileSessionPersistence{
+		path:       path,
+		sessionKey: sessionKey,
+		encryptor:  encryptor,
+	}, nil
+}
+
+func (p *encryptedFileSessionPersistence) Load() ([]*Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var file sessionFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode session file: %w", err)
+	}
+
+	if err := p.useSalt(file.Salt); err != nil {
+		return nil, err
+	}
+
+	decrypted, err := p.encryptor.Decrypt(file.Data, p.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session file: %w", err)
+	}
+
+	var sessions []*Session
+	if err := json.Unmarshal(decrypted, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to decode sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (p *encryptedFileSessionPersistence) Save(sessions []*Session) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.key == nil {
+		salt, err := p.encryptor.GenerateSalt()
+		if err != nil {
+			return fmt.Errorf("failed to generate session file salt: %w", err)
+		}
+		if err := p.useSalt(salt); err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := json.Marshal(sessions)
+	if err != nil {
+		return fmt.Errorf("failed to encode sessions: %w", err)
+	}
+
+	encrypted, err := p.encryptor.Encrypt(plaintext, p.key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt sessions: %w", err)
+	}
+
+	content, err := json.Marshal(sessionFile{Salt: p.salt, Data: encrypted})
+	if err != nil {
+		return fmt.Errorf("failed to encode session file: %w", err)
+	}
+
+	// Write to a temporary file first so that a crash never leaves a truncated session file behind
+	tmpPath := p.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0600); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := os.Rename(tmpPath, p.path); err != nil {
+		return fmt.Errorf("failed to replace session file: %w", err)
+	}
+
+	return nil
+}
+
+// useSalt derives the file key for the given salt. The caller must hold the lock.
+func (p *encryptedFileSessionPersistence) useSalt(salt []byte) error {
+	key, err := p.encryptor.DeriveKeyFromSecret(p.sessionKey, salt)
+	if err != nil {
+		return fmt.Errorf("failed to derive session file key: %w", err)
+	}
+	p.salt = salt
+	p.key = key
+	return nil
+}
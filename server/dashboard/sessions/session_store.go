@@ -0,0 +1,214 @@
+package sessions
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+// SessionStore keeps authenticated dashboard sessions on the server
+type SessionStore interface {
+	// Create starts a new session of an account holding the given MEK
+	Create(mek []byte, identity Identity, remoteAddr, userAgent string) (*Session, error)
+	// Get retrieves an active session by its ID and records the activity.
+	// Returns nil if the session does not exist or has expired.
+	Get(id string) (*Session, error)
+	// List returns all active sessions, most recently used first
+	List() ([]*Session, error)
+	// Revoke ends a single session
+	Revoke(id string) error
+	// RevokeAll ends every session of every account
+	RevokeAll() error
+	// RevokeUser ends every session of a dashboard user (an empty ID means the built-in admin account)
+	RevokeUser(userID string) error
+	// Settings returns the expiry settings of the store
+	Settings() SessionSettings
+}
+
+type memorySessionStore struct {
+	logger      logging.Logger
+	settings    SessionSettings
+	persistence SessionPersistence
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	now         func() time.Time
+}
+
+// NewMemorySessionStore creates a SessionStore that keeps sessions in memory.
+// If persistence is not nil, sessions are loaded from it and saved on every login and logout,
+// so they survive a restart of the dashboard.
+func NewMemorySessionStore(logger logging.Logger, settings SessionSettings, persistence SessionPersistence) (*memorySessionStore, error) {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	store := &memorySessionStore{
+		logger:      logger,
+		settings:    settings,
+		persistence: persistence,
+		sessions:    make(map[string]*Session),
+		now:         func() time.Time { return time.Now().UTC() },
+	}
+
+	if persistence != nil {
+		persisted, err := persistence.Load()
+		if err != nil {
+			return nil, err
+		}
+		now := store.now()
+		for _, session := range persisted {
+			if !session.IsExpired(now, settings) {
+				store.sessions[session.ID] = session
+			}
+		}
+		logger.Info("Loaded persisted dashboard sessions", "count", len(store.sessions))
+	}
+
+	return store, nil
+}
+
+func (s *memorySessionStore) Create(mek []byte, identity Identity, remoteAddr, userAgent string) (*Session, error) {
+	id, err := generateSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	session := &Session{
+		ID:         id,
+		UserID:     identity.UserID,
+		Username:   identity.Username,
+		Mek:        slices.Clone(mek),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		RemoteAddr: remoteAddr,
+		UserAgent:  userAgent,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired(now)
+	s.sessions[id] = session
+
+	if err := s.persist(); err != nil {
+		delete(s.sessions, id)
+		return nil, err
+	}
+
+	copied := *session
+	return &copied, nil
+}
+
+func (s *memorySessionStore) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+
+	now := s.now()
+	if session.IsExpired(now, s.settings) {
+		delete(s.sessions, id)
+		if err := s.persist(); err != nil {
+			s.logger.Error("Failed to persist sessions after expiry", err)
+		}
+		return nil, nil
+	}
+
+	// Activity is only persisted with the next login or logout to avoid writing on every request
+	session.LastSeenAt = now
+
+	copied := *session
+	return &copied, nil
+}
+
+func (s *memorySessionStore) List() ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired(s.now())
+
+	result := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		copied := *session
+		result = append(result, &copied)
+	}
+
+	slices.SortFunc(result, func(a, b *Session) int {
+		return b.LastSeenAt.Compare(a.LastSeenAt)
+	})
+
+	return result, nil
+}
+
+func (s *memorySessionStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[id]; !ok {
+		return nil
+	}
+
+	delete(s.sessions, id)
+	return s.persist()
+}
+
+func (s *memorySessionStore) RevokeAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = make(map[string]*Session)
+	return s.persist()
+}
+
+func (s *memorySessionStore) RevokeUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revoked := 0
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+			revoked++
+		}
+	}
+
+	if revoked == 0 {
+		return nil
+	}
+
+	s.logger.Info("Revoked dashboard sessions of user", "userId", userID, "count", revoked)
+	return s.persist()
+}
+
+func (s *memorySessionStore) Settings() SessionSettings {
+	return s.settings
+}
+
+// pruneExpired removes expired sessions. The caller must hold the lock.
+func (s *memorySessionStore) pruneExpired(now time.Time) {
+	for id, session := range s.sessions {
+		if session.IsExpired(now, s.settings) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// persist saves all sessions if persistence is enabled. The caller must hold the lock.
+func (s *memorySessionStore) persist() error {
+	if s.persistence == nil {
+		return nil
+	}
+
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+
+	return s.persistence.Save(sessions)
+}
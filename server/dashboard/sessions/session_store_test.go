@@ -0,0 +1,167 @@
+package sessions
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yeti47/cryospy/server/core/encryption"
+)
+
+func TestMemorySessionStore_CreateGetRevoke(t *testing.T) {
+	store, err := NewMemorySessionStore(nil, SessionSettings{IdleTimeout: time.Hour}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+
+	mek := []byte("0123456789abcdef0123456789abcdef")
+	session, err := store.Create(mek, AdminIdentity(), "127.0.0.1", "test-agent")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if session.ID == "" {
+		t.Fatal("Expected a session ID")
+	}
+
+	retrieved, err := store.Get(session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if retrieved == nil || string(retrieved.Mek) != string(mek) {
+		t.Fatal("Expected session with the MEK")
+	}
+
+	if err := store.Revoke(session.ID); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+	if retrieved, _ := store.Get(session.ID); retrieved != nil {
+		t.Error("Expected revoked session to be gone")
+	}
+}
+
+func TestMemorySessionStore_Expiry(t *testing.T) {
+	store, err := NewMemorySessionStore(nil, SessionSettings{IdleTimeout: 10 * time.Minute, AbsoluteLifetime: time.Hour}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	session, err := store.Create([]byte("mek"), AdminIdentity(), "", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Regular activity keeps the session alive until the absolute lifetime is reached
+	for range 6 {
+		now = now.Add(9 * time.Minute)
+		if retrieved, _ := store.Get(session.ID); retrieved == nil {
+			t.Fatalf("Expected session to be active at %v", now)
+		}
+	}
+
+	now = now.Add(9 * time.Minute)
+	if retrieved, _ := store.Get(session.ID); retrieved != nil {
+		t.Error("Expected session to expire after its absolute lifetime")
+	}
+
+	idle, _ := store.Create([]byte("mek"), AdminIdentity(), "", "")
+	now = now.Add(11 * time.Minute)
+	if retrieved, _ := store.Get(idle.ID); retrieved != nil {
+		t.Error("Expected session to expire after the idle timeout")
+	}
+}
+
+func TestMemorySessionStore_RevokeAll(t *testing.T) {
+	store, err := NewMemorySessionStore(nil, SessionSettings{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+
+	for range 3 {
+		if _, err := store.Create([]byte("mek"), AdminIdentity(), "", ""); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	if sessions, _ := store.List(); len(sessions) != 3 {
+		t.Fatalf("Expected 3 sessions, got %d", len(sessions))
+	}
+
+	if err := store.RevokeAll(); err != nil {
+		t.Fatalf("Failed to revoke all sessions: %v", err)
+	}
+
+	if sessions, _ := store.List(); len(sessions) != 0 {
+		t.Errorf("Expected no sessions, got %d", len(sessions))
+	}
+}
+
+func TestMemorySessionStore_RevokeUser(t *testing.T) {
+	store, err := NewMemorySessionStore(nil, SessionSettings{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+
+	alice := Identity{UserID: "user-1", Username: "alice"}
+	aliceSession, _ := store.Create([]byte("mek"), alice, "", "")
+	adminSession, _ := store.Create([]byte("mek"), AdminIdentity(), "", "")
+
+	if err := store.RevokeUser(alice.UserID); err != nil {
+		t.Fatalf("Failed to revoke sessions of user: %v", err)
+	}
+
+	if retrieved, _ := store.Get(aliceSession.ID); retrieved != nil {
+		t.Error("Expected session of the revoked user to be gone")
+	}
+	retrieved, _ := store.Get(adminSession.ID)
+	if retrieved == nil {
+		t.Fatal("Expected session of another account to remain")
+	}
+	if identity := retrieved.Identity(); !identity.IsBuiltInAdmin() || identity.Username != "admin" {
+		t.Errorf("Expected built-in admin identity, got %+v", identity)
+	}
+}
+
+func TestMemorySessionStore_EncryptedPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.enc")
+	sessionKey := []byte("a session key that is long enough for testing purposes")
+	encryptor := encryption.NewAESEncryptor()
+
+	persistence, err := NewEncryptedFileSessionPersistence(path, sessionKey, encryptor)
+	if err != nil {
+		t.Fatalf("Failed to create persistence: %v", err)
+	}
+
+	store, err := NewMemorySessionStore(nil, SessionSettings{IdleTimeout: time.Hour}, persistence)
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+
+	session, err := store.Create([]byte("secret-mek"), AdminIdentity(), "10.0.0.1", "agent")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// A new store with the same key restores the session
+	reloadedPersistence, _ := NewEncryptedFileSessionPersistence(path, sessionKey, encryptor)
+	reloaded, err := NewMemorySessionStore(nil, SessionSettings{IdleTimeout: time.Hour}, reloadedPersistence)
+	if err != nil {
+		t.Fatalf("Failed to reload session store: %v", err)
+	}
+
+	retrieved, err := reloaded.Get(session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if retrieved == nil || string(retrieved.Mek) != "secret-mek" || retrieved.RemoteAddr != "10.0.0.1" {
+		t.Fatalf("Expected restored session, got %+v", retrieved)
+	}
+
+	// A different key cannot read the file
+	wrongPersistence, _ := NewEncryptedFileSessionPersistence(path, []byte("another key"), encryptor)
+	if _, err := NewMemorySessionStore(nil, SessionSettings{}, wrongPersistence); err == nil {
+		t.Error("Expected loading with a different session key to fail")
+	}
+}
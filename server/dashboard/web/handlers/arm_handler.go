@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// apiActor is recorded as the originator of arm mode changes made through the automation API
+const apiActor = "api"
+
+// ArmHandler switches the system-wide arm mode and edits how clients behave in each mode
+type ArmHandler struct {
+	logger         logging.Logger
+	armModeService clients.ArmModeService
+	clientService  clients.ClientService
+}
+
+func NewArmHandler(logger logging.Logger, armModeService clients.ArmModeService, clientService clients.ClientService) *ArmHandler {
+	return &ArmHandler{
+		logger:         logger,
+		armModeService: armModeService,
+		clientService:  clientService,
+	}
+}
+
+// ShowArmMode handles GET /arm
+func (h *ArmHandler) ShowArmMode(c *gin.Context) {
+	h.renderArmMode(c, http.StatusOK, "")
+}
+
+// SetArmMode handles POST /arm
+func (h *ArmHandler) SetArmMode(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	mode := clients.ArmMode(c.PostForm("mode"))
+
+	if _, err := h.armModeService.SetArmMode(mode, sessions.GetCurrentUser(c).Username); err != nil {
+		if clients.IsClientValidationError(err) {
+			h.renderArmMode(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.renderArmMode(c, http.StatusInternalServerError, "Failed to change the arm mode.")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/arm")
+}
+
+// UpdateBehaviors handles POST /arm/behaviors. The form holds the Home and Away behaviour of every client.
+func (h *ArmHandler) UpdateBehaviors(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	allClients, err := h.clientService.GetClients()
+	if err != nil {
+		h.logger.Error("Failed to list clients", err)
+		h.renderArmMode(c, http.StatusInternalServerError, "Failed to load clients.")
+		return
+	}
+	allClients = withoutArchived(allClients)
+
+	for _, client := range allClients {
+		home := parseArmBehaviorForm(c, clients.ArmModeHome, client.ID)
+		away := parseArmBehaviorForm(c, clients.ArmModeAway, client.ID)
+		if home == client.HomeBehavior && away == client.AwayBehavior {
+			continue
+		}
+
+		if err := h.armModeService.UpdateClientArmBehavior(client.ID, home, away); err != nil {
+			if clients.IsClientValidationError(err) {
+				h.renderArmMode(c, http.StatusBadRequest, client.ID+": "+err.Error())
+				return
+			}
+			h.renderArmMode(c, http.StatusInternalServerError, "Failed to save the behaviour of client "+client.ID+".")
+			return
+		}
+	}
+
+	h.logger.Info("Client arm behaviours updated", "by", sessions.GetCurrentUser(c).Username)
+	c.Redirect(http.StatusFound, "/arm")
+}
+
+func parseArmBehaviorForm(c *gin.Context, mode clients.ArmMode, clientID string) clients.ArmBehavior {
+	prefix := string(mode) + "_"
+	return clients.ArmBehavior{
+		RecordingMode:           clients.RecordingMode(c.PostForm(prefix + "recording_" + clientID)),
+		MuteMotionNotifications: c.PostForm(prefix+"mute_"+clientID) == "on",
+	}
+}
+
+func (h *ArmHandler) renderArmMode(c *gin.Context, status int, errorMessage string) {
+	state, err := h.armModeService.GetArmState()
+	if err != nil {
+		errorMessage = "Failed to load the arm mode."
+		status = http.StatusInternalServerError
+		state = &clients.ArmState{}
+	}
+
+	allClients, err := h.clientService.GetClients()
+	if err != nil {
+		h.logger.Error("Failed to list clients", err)
+		errorMessage = "Failed to load clients."
+		status = http.StatusInternalServerError
+	}
+	allClients = withoutArchived(allClients)
+
+	c.HTML(status, "arm", gin.H{
+		"Title":    "Arm Mode",
+		"State":    state,
+		"ArmModes": clients.SupportedArmModes(),
+		"Clients":  allClients,
+		"RecordingModes": []clients.RecordingMode{
+			clients.RecordingModeMotionOnly, clients.RecordingModeContinuous, clients.RecordingModeOff,
+		},
+		"Error":       errorMessage,
+		"CurrentUser": sessions.GetCurrentUser(c),
+	})
+}
+
+// armStateResponse is the arm state as returned by the automation API
+type armStateResponse struct {
+	Mode      clients.ArmMode `json:"mode"`
+	ChangedAt *time.Time      `json:"changed_at,omitempty"`
+	ChangedBy string          `json:"changed_by,omitempty"`
+}
+
+func newArmStateResponse(state *clients.ArmState) armStateResponse {
+	response := armStateResponse{Mode: state.Mode, ChangedBy: state.ChangedBy}
+	if !state.ChangedAt.IsZero() {
+		response.ChangedAt = &state.ChangedAt
+	}
+	return response
+}
+
+// GetArmModeAPI handles GET /api/arm-mode
+func (h *ArmHandler) GetArmModeAPI(c *gin.Context) {
+	state, err := h.armModeService.GetArmState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newArmStateResponse(state))
+}
+
+// SetArmModeAPI handles PUT /api/arm-mode with a JSON body such as {"mode": "away"}
+func (h *ArmHandler) SetArmModeAPI(c *gin.Context) {
+	var request struct {
+		Mode clients.ArmMode `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	state, err := h.armModeService.SetArmMode(request.Mode, apiActor)
+	if err != nil {
+		if clients.IsClientValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newArmStateResponse(state))
+}
@@ -1,25 +1,54 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/auth"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
 	"github.com/yeti47/cryospy/server/core/encryption"
+	"github.com/yeti47/cryospy/server/core/events"
+	"github.com/yeti47/cryospy/server/core/twofactor"
+	"github.com/yeti47/cryospy/server/core/users"
 	"github.com/yeti47/cryospy/server/dashboard/sessions"
 )
 
 type AuthHandler struct {
-	logger          logging.Logger
-	mekService      encryption.MekService
-	mekStoreFactory sessions.MekStoreFactory
+	logger           logging.Logger
+	mekService       encryption.MekService
+	userService      users.UserService
+	twoFactorService twofactor.TwoFactorService
+	mekStoreFactory  sessions.MekStoreFactory
+	sessionStore     sessions.SessionStore
+	pendingLogins    sessions.PendingLoginStore
+	pendingSetups    sessions.PendingSetupStore
+	sessionCookie    *sessions.SessionCookie
+	loginThrottle    auth.LoginThrottle
+	eventBus         events.Bus
 }
 
-func NewAuthHandler(logger logging.Logger, mekService encryption.MekService, mekStoreFactory sessions.MekStoreFactory) *AuthHandler {
+func NewAuthHandler(logger logging.Logger, mekService encryption.MekService, userService users.UserService, twoFactorService twofactor.TwoFactorService, mekStoreFactory sessions.MekStoreFactory, sessionStore sessions.SessionStore, pendingLogins sessions.PendingLoginStore, pendingSetups sessions.PendingSetupStore, sessionCookie *sessions.SessionCookie, loginThrottle auth.LoginThrottle, eventBus events.Bus) *AuthHandler {
+	if loginThrottle == nil {
+		loginThrottle = auth.NopLoginThrottle
+	}
+	if eventBus == nil {
+		eventBus = events.NopBus
+	}
 	return &AuthHandler{
-		logger:          logger,
-		mekService:      mekService,
-		mekStoreFactory: mekStoreFactory,
+		logger:           logger,
+		mekService:       mekService,
+		userService:      userService,
+		twoFactorService: twoFactorService,
+		mekStoreFactory:  mekStoreFactory,
+		sessionStore:     sessionStore,
+		pendingLogins:    pendingLogins,
+		pendingSetups:    pendingSetups,
+		sessionCookie:    sessionCookie,
+		loginThrottle:    loginThrottle,
+		eventBus:         eventBus,
 	}
 }
 
@@ -30,53 +59,144 @@ func (h *AuthHandler) ShowLogin(c *gin.Context) {
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
+	username := strings.TrimSpace(c.PostForm("username"))
 	password := c.PostForm("password")
 	if password == "" {
 		c.HTML(http.StatusBadRequest, "login", gin.H{
-			"Title": "Login",
-			"Error": "Password is required",
+			"Title":    "Login",
+			"Username": username,
+			"Error":    "Password is required",
 		})
 		return
 	}
 
-	// This is a simplified login. We just need to decrypt the MEK with the password.
-	// The MEK is not stored in the session yet. We need to get it from the database.
-	mek, err := h.mekService.GetMek()
-	if err != nil {
-		h.logger.Error("Failed to get MEK during login", err)
-		c.HTML(http.StatusInternalServerError, "login", gin.H{
-			"Title": "Login",
-			"Error": "An internal error occurred.",
+	account := loginAccount(username)
+	if wait := h.loginThrottle.Check(account, c.ClientIP(), time.Now()); wait > 0 {
+		c.HTML(http.StatusTooManyRequests, "login", gin.H{
+			"Title":    "Login",
+			"Username": username,
+			"Error":    throttledMessage(wait),
 		})
 		return
 	}
 
-	// Decrypt the MEK to verify the password
-	encryptor := encryption.NewAESEncryptor()
-	decryptedMek, err := encryption.DecryptMek(mek, password, encryptor)
+	decryptedMek, identity, err := h.unlock(username, password)
 	if err != nil {
-		h.logger.Warn("Failed login attempt", "error", err)
-		c.HTML(http.StatusUnauthorized, "login", gin.H{
+		if encryption.IsInvalidMekSecretError(err) || users.IsInvalidCredentialsError(err) {
+			h.logger.Warn("Failed login attempt", "username", username, "error", err)
+			status, message := h.recordLoginFailure(c, account, "Invalid username or password")
+			c.HTML(status, "login", gin.H{
+				"Title":    "Login",
+				"Username": username,
+				"Error":    message,
+			})
+			return
+		}
+		h.logger.Error("Failed to unlock MEK during login", err)
+		c.HTML(http.StatusInternalServerError, "login", gin.H{
 			"Title": "Login",
-			"Error": "Invalid password",
+			"Error": "An internal error occurred.",
 		})
 		return
 	}
 
-	// On success, store the DECRYPTED MEK in the session
-	mekStore := h.mekStoreFactory(c)
-	if err := mekStore.SetMek(decryptedMek); err != nil {
-		h.logger.Error("Failed to set MEK in session", err)
+	twoFactorPending, err := h.beginSession(c, decryptedMek, identity)
+	if err != nil {
+		h.logger.Error("Failed to start session", err)
 		c.HTML(http.StatusInternalServerError, "login", gin.H{
 			"Title": "Login",
 			"Error": "Failed to start session.",
 		})
 		return
 	}
+	if twoFactorPending {
+		c.Redirect(http.StatusFound, "/auth/2fa")
+		return
+	}
 
+	h.loginThrottle.RecordSuccess(account)
+	h.logger.Info("Dashboard login", "username", identity.Username)
 	c.Redirect(http.StatusFound, "/")
 }
 
+// beginSession logs in with an unlocked MEK. With 2FA enabled for the account, the MEK is held server-side until
+// the second factor is verified, and true is returned; otherwise the session starts right away.
+func (h *AuthHandler) beginSession(c *gin.Context, mek []byte, identity sessions.Identity) (bool, error) {
+	twoFactorEnabled, err := h.twoFactorService.IsEnabled(identity.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check two-factor authentication: %w", err)
+	}
+
+	if twoFactorEnabled {
+		pendingID, err := h.pendingLogins.Create(mek, identity)
+		if err != nil {
+			return false, fmt.Errorf("failed to start two-factor login: %w", err)
+		}
+		if err := h.sessionCookie.SetPendingLoginID(c, pendingID); err != nil {
+			return false, fmt.Errorf("failed to start two-factor login: %w", err)
+		}
+		return true, nil
+	}
+
+	if err := sessions.StartSession(h.sessionStore, h.sessionCookie, c, mek, identity); err != nil {
+		return false, fmt.Errorf("failed to set MEK in session: %w", err)
+	}
+	return false, nil
+}
+
+// unlock decrypts the MEK with the credentials of a dashboard account.
+// Without a username, or with the name of the built-in admin account, the password is tried against
+// the primary wrap and every additional key slot, so a recovery key can be entered as well.
+func (h *AuthHandler) unlock(username, password string) ([]byte, sessions.Identity, error) {
+	if username == "" || strings.EqualFold(username, users.AdminUsername) {
+		mek, err := h.mekService.UnlockMek(password)
+		return mek, sessions.AdminIdentity(), err
+	}
+
+	user, mek, err := h.userService.Authenticate(username, password)
+	if err != nil {
+		return nil, sessions.Identity{}, err
+	}
+	return mek, sessions.Identity{UserID: user.ID, Username: user.Username}, nil
+}
+
+// loginAccount returns the name under which failed logins are counted.
+// An empty username logs in to the built-in admin account.
+func loginAccount(username string) string {
+	if username == "" {
+		return users.AdminUsername
+	}
+	return username
+}
+
+// recordLoginFailure counts a failed login for the account and the client IP.
+// If the failure locks the login, the lockout is published on the event bus for the notifiers.
+// Returns the status code and message to show instead of the given message if the next attempt is delayed.
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, account, message string) (int, string) {
+	result := h.loginThrottle.RecordFailure(account, c.ClientIP(), time.Now())
+
+	if result.Locked {
+		h.logger.Warn("Dashboard login locked after repeated failures", "account", account, "failures", result.AccountFailures, "sourceIPs", result.SourceIPs, "lockedUntil", result.LockedUntil)
+		h.eventBus.Publish(events.DashboardLoginLockout{
+			Account:      account,
+			FailureCount: result.AccountFailures,
+			SourceIPs:    result.SourceIPs,
+			LockedUntil:  result.LockedUntil,
+		})
+		return http.StatusTooManyRequests, throttledMessage(result.RetryAfter)
+	}
+
+	return http.StatusUnauthorized, message
+}
+
+// throttledMessage tells the user how long to wait before the next login attempt
+func throttledMessage(wait time.Duration) string {
+	if wait > time.Minute {
+		return fmt.Sprintf("Too many failed login attempts. Please try again in %d minutes.", int((wait+time.Minute-1)/time.Minute))
+	}
+	return fmt.Sprintf("Too many failed login attempts. Please try again in %d seconds.", int((wait+time.Second-1)/time.Second))
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
 	mekStore := h.mekStoreFactory(c)
 	if err := mekStore.ClearMek(); err != nil {
@@ -87,12 +207,29 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 }
 
 func (h *AuthHandler) ShowSetup(c *gin.Context) {
+	// A setup that is waiting for its recovery key to be acknowledged shows the key again
+	if pending := h.pendingSetup(c); pending != nil {
+		c.HTML(http.StatusOK, "setup", gin.H{
+			"Title":       "Setup",
+			"RecoveryKey": pending.RecoveryKey,
+		})
+		return
+	}
+
+	if !h.requireSetupOpen(c) {
+		return
+	}
+
 	c.HTML(http.StatusOK, "setup", gin.H{
 		"Title": "Setup",
 	})
 }
 
 func (h *AuthHandler) Setup(c *gin.Context) {
+	if !h.requireSetupOpen(c) {
+		return
+	}
+
 	password := c.PostForm("password")
 	confirmPassword := c.PostForm("confirm_password")
 
@@ -113,8 +250,7 @@ func (h *AuthHandler) Setup(c *gin.Context) {
 	}
 
 	// Create a new MEK
-	mek, err := h.mekService.CreateMek(password)
-	if err != nil {
+	if _, err := h.mekService.CreateMek(password); err != nil {
 		h.logger.Error("Failed to create MEK", err)
 		c.HTML(http.StatusInternalServerError, "setup", gin.H{
 			"Title": "Setup",
@@ -123,28 +259,207 @@ func (h *AuthHandler) Setup(c *gin.Context) {
 		return
 	}
 
-	// Decrypt the MEK for session storage
-	encryptor := encryption.NewAESEncryptor()
-	decryptedMek, err := encryption.DecryptMek(mek, password, encryptor)
+	// Generate an offline recovery key so that recordings survive a forgotten password
+	_, recoveryKey, err := h.mekService.AddRecoveryKeySlot(password, "Recovery key (setup)")
 	if err != nil {
-		h.logger.Error("Failed to decrypt newly created MEK", err)
+		h.logger.Error("Failed to create recovery key", err)
 		c.HTML(http.StatusInternalServerError, "setup", gin.H{
 			"Title": "Setup",
-			"Error": "Failed to initialize encryption.",
+			"Error": "Failed to create recovery key.",
 		})
 		return
 	}
 
-	// Store the DECRYPTED MEK in the session to log the user in
-	mekStore := h.mekStoreFactory(c)
-	if err := mekStore.SetMek(decryptedMek); err != nil {
-		h.logger.Error("Failed to set MEK in session after setup", err)
+	// The user is only logged in after acknowledging that the recovery key was stored. Until then, the unlocked MEK
+	// and the recovery key stay on the server, tied to this browser.
+	decryptedMek, err := h.mekService.UnlockMek(password)
+	if err == nil {
+		var setupID string
+		setupID, err = h.pendingSetups.Create(decryptedMek, recoveryKey)
+		if err == nil {
+			err = h.sessionCookie.SetPendingSetupID(c, setupID)
+		}
+	}
+	if err != nil {
+		h.logger.Error("Failed to store pending setup", err)
 		c.HTML(http.StatusInternalServerError, "setup", gin.H{
 			"Title": "Setup",
+			"Error": "The encryption key was created, but the session could not be started. Please log in with your password.",
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "setup", gin.H{
+		"Title":       "Setup",
+		"RecoveryKey": recoveryKey,
+	})
+}
+
+// ConfirmRecoveryKey completes the setup of this browser once the recovery key was acknowledged. Without a pending
+// setup, i.e. after the setup is complete, it does not exist.
+func (h *AuthHandler) ConfirmRecoveryKey(c *gin.Context) {
+	setupID, _ := h.sessionCookie.GetPendingSetupID(c)
+	pending := h.pendingSetup(c)
+	if pending == nil {
+		h.renderNotFound(c)
+		return
+	}
+
+	if c.PostForm("acknowledged") != "on" {
+		c.HTML(http.StatusBadRequest, "setup", gin.H{
+			"Title":       "Setup",
+			"RecoveryKey": pending.RecoveryKey,
+			"Error":       "Please confirm that you have stored the recovery key.",
+		})
+		return
+	}
+
+	h.pendingSetups.Delete(setupID)
+	if err := h.sessionCookie.SetPendingSetupID(c, ""); err != nil {
+		h.logger.Warn("Failed to clear pending setup from cookie", "error", err)
+	}
+
+	// The new admin session goes through the same two-factor step as a login
+	twoFactorPending, err := h.beginSession(c, pending.Mek, sessions.AdminIdentity())
+	if err != nil {
+		h.logger.Error("Failed to start session after setup", err)
+		c.HTML(http.StatusInternalServerError, "login", gin.H{
+			"Title": "Login",
+			"Error": "Failed to start session. Please log in with your password.",
+		})
+		return
+	}
+	if twoFactorPending {
+		c.Redirect(http.StatusFound, "/auth/2fa")
+		return
+	}
+
+	h.logger.Info("Dashboard login after setup", "username", users.AdminUsername)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// pendingSetup returns the setup of this browser that is waiting for its recovery key to be acknowledged, or nil
+func (h *AuthHandler) pendingSetup(c *gin.Context) *sessions.PendingSetup {
+	setupID, err := h.sessionCookie.GetPendingSetupID(c)
+	if err != nil || setupID == "" {
+		return nil
+	}
+	return h.pendingSetups.Get(setupID)
+}
+
+// requireSetupOpen answers with 404 once a MEK exists, so that the setup cannot be repeated. Returns false if the
+// request was answered.
+func (h *AuthHandler) requireSetupOpen(c *gin.Context) bool {
+	_, err := h.mekService.GetMek()
+	if err == nil {
+		h.renderNotFound(c)
+		return false
+	}
+	if _, ok := err.(*encryption.MekNotFoundError); !ok {
+		h.logger.Error("Failed to check for an existing MEK", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "An internal error occurred.",
+		})
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) renderNotFound(c *gin.Context) {
+	c.HTML(http.StatusNotFound, "error", gin.H{
+		"Title":   "Error",
+		"Message": "Page not found",
+	})
+}
+
+func (h *AuthHandler) ShowTwoFactor(c *gin.Context) {
+	if h.pendingLogin(c) == nil {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+
+	c.HTML(http.StatusOK, "login-2fa", gin.H{
+		"Title": "Two-Factor Authentication",
+	})
+}
+
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	pendingID, _ := h.sessionCookie.GetPendingLoginID(c)
+	pending := h.pendingLogin(c)
+	if pending == nil {
+		c.HTML(http.StatusUnauthorized, "login", gin.H{
+			"Title": "Login",
+			"Error": "Your login has expired. Please enter your password again.",
+		})
+		return
+	}
+
+	account := loginAccount(pending.Identity.Username)
+	if wait := h.loginThrottle.Check(account, c.ClientIP(), time.Now()); wait > 0 {
+		c.HTML(http.StatusTooManyRequests, "login-2fa", gin.H{
+			"Title": "Two-Factor Authentication",
+			"Error": throttledMessage(wait),
+		})
+		return
+	}
+
+	code := c.PostForm("code")
+	if code == "" {
+		c.HTML(http.StatusBadRequest, "login-2fa", gin.H{
+			"Title": "Two-Factor Authentication",
+			"Error": "Code is required",
+		})
+		return
+	}
+
+	if err := h.twoFactorService.Verify(pending.Identity.UserID, pending.Mek, code); err != nil {
+		if !twofactor.IsInvalidCodeError(err) {
+			h.logger.Error("Failed to verify two-factor code", err)
+			c.HTML(http.StatusInternalServerError, "login-2fa", gin.H{
+				"Title": "Two-Factor Authentication",
+				"Error": "An internal error occurred.",
+			})
+			return
+		}
+
+		h.logger.Warn("Failed two-factor login attempt", "error", err)
+		status, message := h.recordLoginFailure(c, account, "Invalid code")
+		if !h.pendingLogins.RecordFailedAttempt(pendingID) {
+			c.HTML(http.StatusUnauthorized, "login", gin.H{
+				"Title": "Login",
+				"Error": "Too many invalid codes. Please log in again.",
+			})
+			return
+		}
+		c.HTML(status, "login-2fa", gin.H{
+			"Title": "Two-Factor Authentication",
+			"Error": message,
+		})
+		return
+	}
+
+	h.pendingLogins.Delete(pendingID)
+	h.loginThrottle.RecordSuccess(account)
+
+	if err := sessions.StartSession(h.sessionStore, h.sessionCookie, c, pending.Mek, pending.Identity); err != nil {
+		h.logger.Error("Failed to set MEK in session", err)
+		c.HTML(http.StatusInternalServerError, "login", gin.H{
+			"Title": "Login",
 			"Error": "Failed to start session.",
 		})
 		return
 	}
 
+	h.logger.Info("Dashboard login", "username", pending.Identity.Username)
 	c.Redirect(http.StatusFound, "/")
 }
+
+// pendingLogin returns the login that is waiting for its second factor, or nil
+func (h *AuthHandler) pendingLogin(c *gin.Context) *sessions.PendingLogin {
+	pendingID, err := h.sessionCookie.GetPendingLoginID(c)
+	if err != nil || pendingID == "" {
+		return nil
+	}
+	return h.pendingLogins.Get(pendingID)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/auth"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// recentFailureLimit is the number of capture API authentication failures shown on the blocklist page
+const recentFailureLimit = 100
+
+type BlocklistHandler struct {
+	logger        logging.Logger
+	ipBlocklist   auth.IPBlocklist
+	clientService clients.ClientService
+}
+
+func NewBlocklistHandler(logger logging.Logger, ipBlocklist auth.IPBlocklist, clientService clients.ClientService) *BlocklistHandler {
+	return &BlocklistHandler{
+		logger:        logger,
+		ipBlocklist:   ipBlocklist,
+		clientService: clientService,
+	}
+}
+
+// failureView is an authentication failure as shown on the blocklist page
+type failureView struct {
+	auth.FailureRecord
+	KnownClient bool // False if the client ID does not belong to any client, e.g. when IDs are being guessed
+}
+
+// ListBlocks shows the IP addresses that are blocked from the capture API and the most recent authentication failures
+func (h *BlocklistHandler) ListBlocks(c *gin.Context) {
+	h.renderBlocks(c, http.StatusOK, "")
+}
+
+func (h *BlocklistHandler) Unblock(c *gin.Context) {
+	ip := c.PostForm("ip")
+	if ip == "" {
+		c.Redirect(http.StatusFound, "/blocklist")
+		return
+	}
+
+	if err := h.ipBlocklist.Unblock(ip, time.Now()); err != nil {
+		h.logger.Error("Failed to unblock IP address", err)
+		h.renderBlocks(c, http.StatusInternalServerError, "Failed to unblock IP address.")
+		return
+	}
+
+	h.logger.Info("IP address unblocked", "ip", ip, "by", sessions.GetCurrentUser(c).Username)
+	c.Redirect(http.StatusFound, "/blocklist")
+}
+
+func (h *BlocklistHandler) renderBlocks(c *gin.Context, status int, errorMessage string) {
+	blocks, err := h.ipBlocklist.GetActiveBlocks(time.Now())
+	if err != nil {
+		h.logger.Error("Failed to list blocked IP addresses", err)
+		errorMessage = "Failed to load blocked IP addresses."
+		status = http.StatusInternalServerError
+	}
+
+	failures, err := h.ipBlocklist.GetRecentFailures(recentFailureLimit)
+	if err != nil {
+		h.logger.Error("Failed to list authentication failures", err)
+		errorMessage = "Failed to load authentication failures."
+		status = http.StatusInternalServerError
+	}
+
+	knownClients := make(map[string]bool)
+	if allClients, err := h.clientService.GetClients(); err != nil {
+		h.logger.Error("Failed to list clients", err)
+	} else {
+		for _, client := range allClients {
+			knownClients[client.ID] = true
+		}
+	}
+
+	views := make([]failureView, len(failures))
+	for i, failure := range failures {
+		views[i] = failureView{FailureRecord: failure, KnownClient: knownClients[failure.ClientID]}
+	}
+
+	c.HTML(status, "blocklist", gin.H{
+		"Title":    "Blocklist",
+		"Blocks":   blocks,
+		"Failures": views,
+		"Error":    errorMessage,
+	})
+}
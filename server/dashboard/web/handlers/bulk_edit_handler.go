@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// BulkEditHandler applies a selection of settings to several clients at once
+type BulkEditHandler struct {
+	logger        logging.Logger
+	clientService clients.ClientService
+}
+
+func NewBulkEditHandler(logger logging.Logger, clientService clients.ClientService) *BulkEditHandler {
+	return &BulkEditHandler{
+		logger:        logger,
+		clientService: clientService,
+	}
+}
+
+// bulkSettingField is the view model of one setting in the bulk edit form
+type bulkSettingField struct {
+	Key     string   // Setting key, also the name of the form field
+	Label   string   // Label shown next to the field
+	Kind    string   // "number", "decimal", "checkbox" or "select"
+	Options []string // Choices of a select
+}
+
+// bulkResultRow is the view model of the outcome for one client
+type bulkResultRow struct {
+	clients.BulkUpdateResult
+	Name  string // Display name of the client
+	Error string // Error message, empty on success
+}
+
+// bulkEditForm holds the submitted form, so that it can be shown again with the results
+type bulkEditForm struct {
+	Selected map[string]bool   // IDs of the selected clients
+	Applied  map[string]bool   // Keys of the settings to apply
+	Values   map[string]string // Submitted values by setting key
+}
+
+// ShowBulkEdit handles GET /clients/bulk
+func (h *BulkEditHandler) ShowBulkEdit(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	h.renderBulkEdit(c, http.StatusOK, bulkEditForm{}, nil, "")
+}
+
+// BulkEdit handles POST /clients/bulk
+func (h *BulkEditHandler) BulkEdit(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	ids := c.PostFormArray("client")
+	keys := c.PostFormArray("apply")
+	form := bulkEditForm{
+		Selected: make(map[string]bool, len(ids)),
+		Applied:  make(map[string]bool, len(keys)),
+		Values:   make(map[string]string, len(keys)),
+	}
+	for _, id := range ids {
+		form.Selected[id] = true
+	}
+	for _, key := range clients.SettingKeys() {
+		form.Values[key] = c.PostForm(key)
+	}
+
+	if len(ids) == 0 || len(keys) == 0 {
+		h.renderBulkEdit(c, http.StatusBadRequest, form, nil, "Select at least one client and one setting to apply.")
+		return
+	}
+
+	var settings clients.ClientSettings
+	for _, key := range keys {
+		form.Applied[key] = true
+		if err := settings.SetValue(key, c.PostForm(key)); err != nil {
+			h.renderBulkEdit(c, http.StatusBadRequest, form, nil, err.Error())
+			return
+		}
+	}
+
+	username := sessions.GetCurrentUser(c).Username
+	results, err := h.clientService.BulkUpdateClientSettings(ids, settings, keys, username)
+	if err != nil {
+		if clients.IsClientValidationError(err) {
+			h.renderBulkEdit(c, http.StatusBadRequest, form, nil, err.Error())
+			return
+		}
+		h.logger.Error("Failed to bulk update client settings", err)
+		h.renderBulkEdit(c, http.StatusInternalServerError, form, nil, "Failed to update client settings.")
+		return
+	}
+
+	h.logger.Info("Client settings bulk updated", "clients", len(ids), "settings", strings.Join(keys, ","), "by", username)
+	h.renderBulkEdit(c, http.StatusOK, form, results, "")
+}
+
+func (h *BulkEditHandler) renderBulkEdit(c *gin.Context, status int, form bulkEditForm, results []clients.BulkUpdateResult, errorMessage string) {
+	clientList, err := h.clientService.GetClients()
+	if err != nil {
+		h.logger.Error("Failed to get clients", err)
+		if errorMessage == "" {
+			errorMessage = "Failed to load clients."
+		}
+	}
+	clientList = withoutArchived(clientList)
+
+	names := make(map[string]string, len(clientList))
+	for _, client := range clientList {
+		names[client.ID] = client.Name()
+	}
+	rows := make([]bulkResultRow, len(results))
+	for i, result := range results {
+		rows[i] = bulkResultRow{BulkUpdateResult: result, Name: names[result.ClientID]}
+		if rows[i].Name == "" {
+			rows[i].Name = result.ClientID
+		}
+		if result.Err != nil {
+			rows[i].Error = result.Err.Error()
+		}
+	}
+
+	c.HTML(status, "bulk-edit", gin.H{
+		"Title":       "Clients",
+		"Clients":     clientList,
+		"Fields":      h.settingFields(),
+		"Form":        form,
+		"Results":     rows,
+		"Error":       errorMessage,
+		"CurrentUser": sessions.GetCurrentUser(c),
+	})
+}
+
+// settingFields lists the settings offered for bulk editing, in the order of clients.SettingKeys
+func (h *BulkEditHandler) settingFields() []bulkSettingField {
+	return []bulkSettingField{
+		{Key: "storage_limit", Label: "Storage Limit (MB)", Kind: "number"},
+		{Key: "clip_duration", Label: "Clip Duration (seconds)", Kind: "number"},
+		{Key: "motion_only", Label: "Motion Only", Kind: "checkbox"},
+		{Key: "grayscale", Label: "Grayscale", Kind: "checkbox"},
+		{Key: "downscale_resolution", Label: "Downscale Resolution", Kind: "select", Options: h.clientService.GetSupportedDownscaleResolutions()},
+		{Key: "output_format", Label: "Output Format", Kind: "select", Options: h.clientService.GetSupportedOutputFormats()},
+		{Key: "output_codec", Label: "Output Codec", Kind: "select", Options: h.clientService.GetSupportedOutputCodecs()},
+		{Key: "video_bitrate", Label: "Video Bitrate", Kind: "select", Options: h.clientService.GetSupportedVideoBitrates()},
+		{Key: "motion_min_area", Label: "Motion Min Area", Kind: "number"},
+		{Key: "motion_max_frames", Label: "Motion Max Frames", Kind: "number"},
+		{Key: "motion_warm_up_frames", Label: "Motion Warm Up Frames", Kind: "number"},
+		{Key: "motion_min_width", Label: "Motion Min Width", Kind: "number"},
+		{Key: "motion_min_height", Label: "Motion Min Height", Kind: "number"},
+		{Key: "motion_min_aspect", Label: "Motion Min Aspect", Kind: "decimal"},
+		{Key: "motion_max_aspect", Label: "Motion Max Aspect", Kind: "decimal"},
+		{Key: "motion_mog_history", Label: "MOG2 History", Kind: "number"},
+		{Key: "motion_mog_var_thresh", Label: "MOG2 Var Threshold", Kind: "decimal"},
+		{Key: "capture_codec", Label: "Capture Codec", Kind: "select", Options: h.clientService.GetSupportedCaptureCodecs()},
+		{Key: "capture_frame_rate", Label: "Capture Frame Rate", Kind: "decimal"},
+	}
+}
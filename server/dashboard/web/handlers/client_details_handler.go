@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// ClientDetailsHandler edits the descriptive details of clients: display name, location, timezone and notes
+type ClientDetailsHandler struct {
+	logger        logging.Logger
+	clientService clients.ClientService
+}
+
+func NewClientDetailsHandler(logger logging.Logger, clientService clients.ClientService) *ClientDetailsHandler {
+	return &ClientDetailsHandler{
+		logger:        logger,
+		clientService: clientService,
+	}
+}
+
+// ShowDetails handles GET /clients/:id/details
+func (h *ClientDetailsHandler) ShowDetails(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	h.renderDetails(c, http.StatusOK, client, clients.UpdateClientDetailsRequest{
+		ID:          client.ID,
+		DisplayName: client.DisplayName,
+		Location:    client.Location,
+		Timezone:    client.Timezone,
+		Notes:       client.Notes,
+	}, "")
+}
+
+// UpdateDetails handles POST /clients/:id/details
+func (h *ClientDetailsHandler) UpdateDetails(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	req := clients.UpdateClientDetailsRequest{
+		ID:          client.ID,
+		DisplayName: c.PostForm("display_name"),
+		Location:    c.PostForm("location"),
+		Timezone:    c.PostForm("timezone"),
+		Notes:       c.PostForm("notes"),
+	}
+	username := sessions.GetCurrentUser(c).Username
+
+	if err := h.clientService.UpdateClientDetails(req, username); err != nil {
+		if clients.IsClientValidationError(err) {
+			h.renderDetails(c, http.StatusBadRequest, client, req, err.Error())
+			return
+		}
+		h.logger.Error("Failed to update client details", err)
+		h.renderDetails(c, http.StatusInternalServerError, client, req, "Failed to save details.")
+		return
+	}
+
+	h.logger.Info("Client details updated", "clientId", client.ID, "by", username)
+	c.Redirect(http.StatusFound, "/clients")
+}
+
+func (h *ClientDetailsHandler) getClient(c *gin.Context) (*clients.Client, bool) {
+	client, err := h.clientService.GetClient(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to get client", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Failed to load client",
+		})
+		return nil, false
+	}
+	if client == nil {
+		c.HTML(http.StatusNotFound, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Client not found",
+		})
+		return nil, false
+	}
+	return client, true
+}
+
+func (h *ClientDetailsHandler) renderDetails(c *gin.Context, status int, client *clients.Client, details clients.UpdateClientDetailsRequest, errorMessage string) {
+	c.HTML(status, "client-details", gin.H{
+		"Title":       "Clients",
+		"Client":      client,
+		"Details":     details,
+		"Error":       errorMessage,
+		"CurrentUser": sessions.GetCurrentUser(c),
+	})
+}
@@ -2,34 +2,96 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/hex"
+	"html/template"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
 	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/pairing"
+	"github.com/yeti47/cryospy/server/core/users"
 	"github.com/yeti47/cryospy/server/core/videos"
 	"github.com/yeti47/cryospy/server/dashboard/sessions"
 )
 
+// maxListedPurgeJobs limits the purge jobs shown on the clients page
+const maxListedPurgeJobs = 10
+
 type ClientHandler struct {
-	logger          logging.Logger
-	clientService   clients.ClientService
-	storageManager  videos.StorageManager
-	mekStoreFactory sessions.MekStoreFactory
+	logger           logging.Logger
+	clientService    clients.ClientService
+	storageManager   videos.StorageManager
+	mekStoreFactory  sessions.MekStoreFactory
+	certService      clients.ClientCertificateService // nil unless capture clients need certificates
+	pairingService   pairing.PairingService
+	groupService     clients.ClientGroupService
+	heartbeatService clients.HeartbeatService
+	clientRemover    videos.ClientRemover
 }
 
-func NewClientHandler(logger logging.Logger, clientService clients.ClientService, storageManager videos.StorageManager, mekStoreFactory sessions.MekStoreFactory) *ClientHandler {
+func NewClientHandler(logger logging.Logger, clientService clients.ClientService, groupService clients.ClientGroupService, storageManager videos.StorageManager, mekStoreFactory sessions.MekStoreFactory, certService clients.ClientCertificateService, pairingService pairing.PairingService, heartbeatService clients.HeartbeatService, clientRemover videos.ClientRemover) *ClientHandler {
 	return &ClientHandler{
-		logger:          logger,
-		clientService:   clientService,
-		storageManager:  storageManager,
-		mekStoreFactory: mekStoreFactory,
+		logger:           logger,
+		clientService:    clientService,
+		groupService:     groupService,
+		storageManager:   storageManager,
+		mekStoreFactory:  mekStoreFactory,
+		certService:      certService,
+		pairingService:   pairingService,
+		heartbeatService: heartbeatService,
+		clientRemover:    clientRemover,
 	}
 }
 
+// issueCredentials adds the credentials of a client with a new secret to the template data: the secret itself,
+// a client certificate if capture clients need one, and a pairing code that hands out both to a capture device.
+// Certificate and pairing code are optional for the page: if they fail, the secret is shown anyway together with an error.
+func (h *ClientHandler) issueCredentials(c *gin.Context, clientID string, secret []byte, data gin.H) {
+	credentials := pairing.Credentials{
+		ClientID:     clientID,
+		ClientSecret: hex.EncodeToString(secret),
+	}
+	data["Secret"] = credentials.ClientSecret
+
+	if h.certService != nil {
+		issued, err := h.certService.IssueCertificate(clientID)
+		if err != nil {
+			h.logger.Error("Failed to issue client certificate", err)
+			data["CertificateError"] = "Failed to issue a client certificate. Rotate the secret to try again."
+			return
+		}
+
+		credentials.ClientCertificate = string(issued.CertificatePEM)
+		credentials.ClientKey = string(issued.PrivateKeyPEM)
+		credentials.CABundle = string(h.certService.CACertificatePEM())
+		data["Certificate"] = credentials.ClientCertificate
+		data["CertificateKey"] = credentials.ClientKey
+		data["CertificateExpiresAt"] = issued.ExpiresAt
+		data["CABundle"] = credentials.CABundle
+	}
+
+	code, err := h.pairingService.CreateCode(credentials, sessions.GetCurrentUser(c).Username)
+	if err != nil {
+		h.logger.Error("Failed to create pairing code", err)
+		data["PairingError"] = "Failed to create a pairing code. Enter the credentials on the device manually."
+		return
+	}
+
+	data["PairingCode"] = code.Code
+	data["PairingURI"] = code.URI
+	data["PairingQRCode"] = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(code.QRCodePNG))
+	data["PairingExpiresAt"] = code.ExpiresAt
+}
+
 func (h *ClientHandler) ListClients(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
 	clientList, err := h.clientService.GetClients()
 	if err != nil {
 		h.logger.Error("Failed to get clients", err)
@@ -40,40 +102,95 @@ func (h *ClientHandler) ListClients(c *gin.Context) {
 		return
 	}
 
-	// Create a structure to hold client and storage info
+	heartbeats, err := h.heartbeatService.GetHeartbeats()
+	if err != nil {
+		h.logger.Warn("Failed to get client heartbeats", "error", err)
+		heartbeats = map[string]*clients.Heartbeat{}
+	}
+	now := time.Now()
+
+	// Create a structure to hold client, storage and health info
 	type ClientWithStorage struct {
 		*clients.Client
 		StorageInfo *videos.StorageInfo
+		Heartbeat   *clients.Heartbeat // nil if the client has never sent one
+		Status      clients.ClientStatus
+		Purging     bool // Whether a purge job is deleting the client and its clips
 	}
 
-	clientsWithStorage := make([]ClientWithStorage, len(clientList))
-	for i, client := range clientList {
+	purgeJobs, err := h.clientRemover.GetPurgeJobs(maxListedPurgeJobs)
+	if err != nil {
+		h.logger.Warn("Failed to get purge jobs", "error", err)
+		purgeJobs = []*videos.PurgeJob{}
+	}
+	purging := make(map[string]bool)
+	for _, job := range purgeJobs {
+		if !job.Status.IsFinal() {
+			purging[job.ClientID] = true
+		}
+	}
+
+	// Archived clients only hold the clips of deleted clients and are listed separately
+	clientsWithStorage := make([]ClientWithStorage, 0, len(clientList))
+	archivedClients := make([]ClientWithStorage, 0)
+	for _, client := range clientList {
 		storageInfo, err := h.storageManager.GetStorageInfo(context.Background(), client.ID)
 		if err != nil {
 			h.logger.Warn("Failed to get storage info for client", "client_id", client.ID, "error", err)
 			// Continue with nil storage info - we'll handle this in the template
 			storageInfo = nil
 		}
-		clientsWithStorage[i] = ClientWithStorage{
+		if client.IsArchived() {
+			archivedClients = append(archivedClients, ClientWithStorage{Client: client, StorageInfo: storageInfo, Purging: purging[client.ID]})
+			continue
+		}
+		heartbeat := heartbeats[client.ID]
+		clientsWithStorage = append(clientsWithStorage, ClientWithStorage{
 			Client:      client,
 			StorageInfo: storageInfo,
-		}
+			Heartbeat:   heartbeat,
+			Status:      heartbeat.Status(now, h.heartbeatService.OfflineAfter()),
+			Purging:     purging[client.ID],
+		})
+	}
+
+	groups, err := h.groupService.GetGroups()
+	if err != nil {
+		h.logger.Error("Failed to get client groups", err)
+		groups = []*clients.ClientGroup{}
 	}
 
 	c.HTML(http.StatusOK, "clients", gin.H{
 		"Title":                  "Clients",
 		"Clients":                clientsWithStorage,
+		"ArchivedClients":        archivedClients,
+		"PurgeJobs":              purgeJobs,
+		"Groups":                 groups,
 		"SupportedResolutions":   h.clientService.GetSupportedDownscaleResolutions(),
 		"SupportedCaptureCodecs": h.clientService.GetSupportedCaptureCodecs(),
 		"SupportedOutputCodecs":  h.clientService.GetSupportedOutputCodecs(),
 		"SupportedOutputFormats": h.clientService.GetSupportedOutputFormats(),
 		"SupportedVideoBitrates": h.clientService.GetSupportedVideoBitrates(),
+		"CurrentUser":            sessions.GetCurrentUser(c),
 	})
 }
 
 func (h *ClientHandler) ShowNewClientForm(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	// Existing clients are offered as templates; ?from=<id> preselects one
+	clientList, err := h.clientService.GetClients()
+	if err != nil {
+		h.logger.Error("Failed to get clients", err)
+		clientList = []*clients.Client{}
+	}
+
 	c.HTML(http.StatusOK, "new-client", gin.H{
 		"Title":                  "New Client",
+		"Clients":                withoutArchived(clientList),
+		"CloneSource":            c.Query("from"),
 		"SupportedResolutions":   h.clientService.GetSupportedDownscaleResolutions(),
 		"SupportedCaptureCodecs": h.clientService.GetSupportedCaptureCodecs(),
 		"SupportedOutputCodecs":  h.clientService.GetSupportedOutputCodecs(),
@@ -83,6 +200,10 @@ func (h *ClientHandler) ShowNewClientForm(c *gin.Context) {
 }
 
 func (h *ClientHandler) CreateClient(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
 	id := c.PostForm("id")
 	storageLimitStr := c.PostForm("storage_limit")
 	clipDurationStr := c.PostForm("clip_duration")
@@ -217,7 +338,7 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 	}
 
 	mekStore := h.mekStoreFactory(c)
-	client, secret, err := h.clientService.CreateClient(req, mekStore)
+	client, secret, err := h.clientService.CreateClient(req, mekStore, sessions.GetCurrentUser(c).Username)
 	if err != nil {
 		if clients.IsClientValidationError(err) {
 			c.HTML(http.StatusBadRequest, "new-client", gin.H{
@@ -239,14 +360,60 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "new-client", gin.H{
+	data := gin.H{
 		"Title":  "New Client",
 		"Client": client,
-		"Secret": hex.EncodeToString(secret),
-	})
+	}
+	h.issueCredentials(c, client.ID, secret, data)
+	c.HTML(http.StatusOK, "new-client", data)
+}
+
+// CloneClient handles POST /clients/clone. The new client is a copy of an existing one with its own credentials.
+func (h *ClientHandler) CloneClient(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	sourceID := c.PostForm("source_id")
+	mekStore := h.mekStoreFactory(c)
+	client, secret, err := h.clientService.CloneClient(sourceID, c.PostForm("id"), mekStore, sessions.GetCurrentUser(c).Username)
+	if err != nil {
+		status, message := http.StatusInternalServerError, "Failed to create client."
+		switch {
+		case clients.IsClientValidationError(err), clients.IsClientAlreadyExistsError(err), clients.IsClientNotFoundError(err):
+			status, message = http.StatusBadRequest, err.Error()
+		default:
+			h.logger.Error("Failed to clone client", err)
+		}
+		clientList, _ := h.clientService.GetClients()
+		c.HTML(status, "new-client", gin.H{
+			"Title":                  "New Client",
+			"Error":                  message,
+			"Clients":                withoutArchived(clientList),
+			"CloneSource":            sourceID,
+			"SupportedResolutions":   h.clientService.GetSupportedDownscaleResolutions(),
+			"SupportedCaptureCodecs": h.clientService.GetSupportedCaptureCodecs(),
+			"SupportedOutputCodecs":  h.clientService.GetSupportedOutputCodecs(),
+			"SupportedOutputFormats": h.clientService.GetSupportedOutputFormats(),
+			"SupportedVideoBitrates": h.clientService.GetSupportedVideoBitrates(),
+		})
+		return
+	}
+
+	h.logger.Info("Client cloned", "source", sourceID, "id", client.ID)
+	data := gin.H{
+		"Title":  "New Client",
+		"Client": client,
+	}
+	h.issueCredentials(c, client.ID, secret, data)
+	c.HTML(http.StatusOK, "new-client", data)
 }
 
 func (h *ClientHandler) UpdateClientSettings(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
 	id := c.Param("id")
 	storageLimitStr := c.PostForm("storage_limit")
 	clipDurationStr := c.PostForm("clip_duration")
@@ -380,14 +547,17 @@ func (h *ClientHandler) UpdateClientSettings(c *gin.Context) {
 		CaptureFrameRate:      captureFrameRate,
 	}
 
-	err = h.clientService.UpdateClientSettings(req)
+	username := sessions.GetCurrentUser(c).Username
+	err = h.clientService.UpdateClientSettingsAndGroup(req, c.PostForm("group_id"), c.PostFormArray("override"), username)
 	if err != nil {
-		if clients.IsClientValidationError(err) {
+		if clients.IsClientValidationError(err) || clients.IsClientGroupNotFoundError(err) {
 			clientList, _ := h.clientService.GetClients()
+			groups, _ := h.groupService.GetGroups()
 			c.HTML(http.StatusBadRequest, "clients", gin.H{
 				"Title":                  "Clients",
 				"Error":                  err.Error(),
-				"Clients":                clientList,
+				"Clients":                withoutArchived(clientList),
+				"Groups":                 groups,
 				"SupportedResolutions":   h.clientService.GetSupportedDownscaleResolutions(),
 				"SupportedCaptureCodecs": h.clientService.GetSupportedCaptureCodecs(),
 				"SupportedOutputCodecs":  h.clientService.GetSupportedOutputCodecs(),
@@ -407,29 +577,11 @@ func (h *ClientHandler) UpdateClientSettings(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/clients")
 }
 
-func (h *ClientHandler) DeleteClient(c *gin.Context) {
-	id := c.Param("id")
-	if id == "" {
-		c.HTML(http.StatusBadRequest, "clients", gin.H{
-			"Title": "Clients",
-			"Error": "Client ID is required.",
-		})
-		return
-	}
-
-	if err := h.clientService.DeleteClient(id); err != nil {
-		h.logger.Error("Failed to delete client", err)
-		c.HTML(http.StatusInternalServerError, "clients", gin.H{
-			"Title": "Clients",
-			"Error": "Failed to delete client.",
-		})
+func (h *ClientHandler) DisableClient(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
 		return
 	}
 
-	c.Redirect(http.StatusFound, "/clients")
-}
-
-func (h *ClientHandler) DisableClient(c *gin.Context) {
 	id := c.Param("id")
 
 	if err := h.clientService.DisableClient(id); err != nil {
@@ -446,6 +598,10 @@ func (h *ClientHandler) DisableClient(c *gin.Context) {
 }
 
 func (h *ClientHandler) EnableClient(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
 	id := c.Param("id")
 
 	if err := h.clientService.EnableClient(id); err != nil {
@@ -460,3 +616,48 @@ func (h *ClientHandler) EnableClient(c *gin.Context) {
 	h.logger.Info("Client enabled", "clientId", id)
 	c.Redirect(http.StatusFound, "/clients")
 }
+
+func (h *ClientHandler) RotateClientSecret(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	id := c.Param("id")
+	gracePeriodHoursStr := c.DefaultPostForm("grace_period_hours", "0")
+
+	gracePeriodHours, err := strconv.Atoi(gracePeriodHoursStr)
+	if err != nil || gracePeriodHours < 0 {
+		c.HTML(http.StatusBadRequest, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Invalid grace period.",
+		})
+		return
+	}
+	gracePeriod := time.Duration(gracePeriodHours) * time.Hour
+
+	mekStore := h.mekStoreFactory(c)
+	client, secret, err := h.clientService.RotateClientSecret(id, gracePeriod, mekStore)
+	if err != nil {
+		if clients.IsClientNotFoundError(err) {
+			c.HTML(http.StatusNotFound, "error", gin.H{
+				"Title":   "Error",
+				"Message": "Client not found.",
+			})
+			return
+		}
+		h.logger.Error("Failed to rotate client secret", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Failed to rotate client secret.",
+		})
+		return
+	}
+
+	h.logger.Info("Client secret rotated", "clientId", id, "gracePeriod", gracePeriod)
+	data := gin.H{
+		"Title":  "Client Secret",
+		"Client": client,
+	}
+	h.issueCredentials(c, client.ID, secret, data)
+	c.HTML(http.StatusOK, "client-secret", data)
+}
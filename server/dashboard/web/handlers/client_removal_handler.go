@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/core/videos"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// Ways to deal with the clips of a deleted client, as submitted by the delete form
+const (
+	removalModeKeep   = "keep"   // Move the clips to an archived client record
+	removalModeExport = "export" // Export the clips, then purge them
+	removalModePurge  = "purge"  // Purge the clips right away
+)
+
+// ClientRemovalHandler deletes clients together with their clips and serves the exports of purged clients
+type ClientRemovalHandler struct {
+	logger          logging.Logger
+	clientService   clients.ClientService
+	clientRemover   videos.ClientRemover
+	clipReader      videos.ClipReader
+	storageManager  videos.StorageManager
+	mekStoreFactory sessions.MekStoreFactory
+}
+
+func NewClientRemovalHandler(logger logging.Logger, clientService clients.ClientService, clientRemover videos.ClientRemover, clipReader videos.ClipReader, storageManager videos.StorageManager, mekStoreFactory sessions.MekStoreFactory) *ClientRemovalHandler {
+	return &ClientRemovalHandler{
+		logger:          logger,
+		clientService:   clientService,
+		clientRemover:   clientRemover,
+		clipReader:      clipReader,
+		storageManager:  storageManager,
+		mekStoreFactory: mekStoreFactory,
+	}
+}
+
+// purgeJobResponse is the progress of a purge job as polled by the clients page
+type purgeJobResponse struct {
+	Status         videos.PurgeJobStatus `json:"status"`
+	Percent        int                   `json:"percent"`
+	TotalClips     int                   `json:"total_clips"`
+	ProcessedClips int                   `json:"processed_clips"`
+	HasExport      bool                  `json:"has_export"`
+	Error          string                `json:"error,omitempty"`
+}
+
+// ShowDelete handles GET /clients/:id/delete
+func (h *ClientRemovalHandler) ShowDelete(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	h.renderDelete(c, http.StatusOK, client, "")
+}
+
+// Delete handles POST /clients/:id/delete
+func (h *ClientRemovalHandler) Delete(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	username := sessions.GetCurrentUser(c).Username
+	mode := c.PostForm("mode")
+
+	var err error
+	switch mode {
+	case removalModeKeep:
+		if client.IsArchived() {
+			h.renderDelete(c, http.StatusBadRequest, client, "The clips of an archived client can only be exported or purged.")
+			return
+		}
+		_, err = h.clientRemover.ArchiveClient(client.ID)
+	case removalModeExport, removalModePurge:
+		_, err = h.clientRemover.StartPurge(client.ID, mode == removalModeExport, h.mekStoreFactory(c), username)
+	default:
+		h.renderDelete(c, http.StatusBadRequest, client, "Choose what happens to the clips of the client.")
+		return
+	}
+
+	if err != nil {
+		if clients.IsClientValidationError(err) {
+			h.renderDelete(c, http.StatusBadRequest, client, err.Error())
+			return
+		}
+		h.logger.Error("Failed to delete client", err)
+		h.renderDelete(c, http.StatusInternalServerError, client, "Failed to delete client.")
+		return
+	}
+
+	h.logger.Info("Client deletion started", "clientId", client.ID, "mode", mode, "by", username)
+	c.Redirect(http.StatusFound, "/clients")
+}
+
+// GetPurgeJob handles GET /clients/purges/:jobId
+func (h *ClientRemovalHandler) GetPurgeJob(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	job, err := h.clientRemover.GetPurgeJob(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purge job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, purgeJobResponse{
+		Status:         job.Status,
+		Percent:        job.Percent(),
+		TotalClips:     job.TotalClips,
+		ProcessedClips: job.ProcessedClips,
+		HasExport:      job.ExportFile != "",
+		Error:          job.Error,
+	})
+}
+
+// DownloadExport handles GET /clients/purges/:jobId/export
+func (h *ClientRemovalHandler) DownloadExport(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	jobID := c.Param("jobId")
+	name, export, err := h.clientRemover.OpenExport(jobID, h.mekStoreFactory(c))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Export not found",
+		})
+		return
+	}
+	defer export.Close()
+
+	// The export is decrypted while it is streamed, so it never lies on the server in plaintext
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, export); err != nil {
+		// The export is kept, so that the download can be tried again
+		h.logger.Error("Failed to stream purge export", err, "job", jobID)
+		return
+	}
+
+	h.logger.Info("Purge export downloaded", "job", jobID, "by", sessions.GetCurrentUser(c).Username)
+	if err := h.clientRemover.DeleteExport(jobID); err != nil {
+		h.logger.Error("Failed to delete downloaded purge export", err, "job", jobID)
+	}
+}
+
+// DeleteExport handles POST /clients/purges/:jobId/export/delete
+func (h *ClientRemovalHandler) DeleteExport(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	if err := h.clientRemover.DeleteExport(c.Param("jobId")); err != nil {
+		h.logger.Error("Failed to delete purge export", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Failed to delete the export",
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/clients")
+}
+
+func (h *ClientRemovalHandler) getClient(c *gin.Context) (*clients.Client, bool) {
+	client, err := h.clientService.GetClient(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to get client", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Failed to load client",
+		})
+		return nil, false
+	}
+	if client == nil {
+		c.HTML(http.StatusNotFound, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Client not found",
+		})
+		return nil, false
+	}
+	return client, true
+}
+
+func (h *ClientRemovalHandler) renderDelete(c *gin.Context, status int, client *clients.Client, errorMessage string) {
+	_, clipCount, err := h.clipReader.QueryClipInfos(videos.ClipQuery{ClientID: client.ID, Page: 1, PageSize: 1})
+	if err != nil {
+		h.logger.Warn("Failed to count clips of client", "client_id", client.ID, "error", err)
+	}
+	storageInfo, err := h.storageManager.GetStorageInfo(context.Background(), client.ID)
+	if err != nil {
+		h.logger.Warn("Failed to get storage info for client", "client_id", client.ID, "error", err)
+		storageInfo = nil
+	}
+
+	c.HTML(status, "client-delete", gin.H{
+		"Title":       "Clients",
+		"Client":      client,
+		"ClipCount":   clipCount,
+		"StorageInfo": storageInfo,
+		"Error":       errorMessage,
+		"CurrentUser": sessions.GetCurrentUser(c),
+	})
+}
+
+// withoutArchived removes the archived records of deleted clients, for pages that only concern clients that still record
+func withoutArchived(clientList []*clients.Client) []*clients.Client {
+	active := make([]*clients.Client, 0, len(clientList))
+	for _, client := range clientList {
+		if !client.IsArchived() {
+			active = append(active, client)
+		}
+	}
+	return active
+}
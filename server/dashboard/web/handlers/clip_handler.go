@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
 	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
 	"github.com/yeti47/cryospy/server/core/videos"
 	"github.com/yeti47/cryospy/server/dashboard/sessions"
 )
@@ -19,15 +20,17 @@ type ClipHandler struct {
 	clipReader      videos.ClipReader
 	clipDeleter     videos.ClipDeleter
 	clientService   clients.ClientService
+	groupService    clients.ClientGroupService
 	mekStoreFactory sessions.MekStoreFactory
 }
 
-func NewClipHandler(logger logging.Logger, clipReader videos.ClipReader, clipDeleter videos.ClipDeleter, clientService clients.ClientService, mekStoreFactory sessions.MekStoreFactory) *ClipHandler {
+func NewClipHandler(logger logging.Logger, clipReader videos.ClipReader, clipDeleter videos.ClipDeleter, clientService clients.ClientService, groupService clients.ClientGroupService, mekStoreFactory sessions.MekStoreFactory) *ClipHandler {
 	return &ClipHandler{
 		logger:          logger,
 		clipReader:      clipReader,
 		clipDeleter:     clipDeleter,
 		clientService:   clientService,
+		groupService:    groupService,
 		mekStoreFactory: mekStoreFactory,
 	}
 }
@@ -47,6 +50,20 @@ func (h *ClipHandler) ListClips(c *gin.Context) {
 		query.ClientID = clientID
 	}
 
+	// Group filter
+	if groupID := c.Query("groupId"); groupID != "" {
+		memberIDs, err := h.groupService.GetMemberIDs(groupID)
+		if err != nil {
+			h.logger.Error("Failed to get group members", err)
+			c.HTML(http.StatusInternalServerError, "clips", gin.H{
+				"Title": "Clips",
+				"Error": "Failed to load clips.",
+			})
+			return
+		}
+		query.ClientIDs = append([]string{}, memberIDs...) // Non-nil, so that an empty group matches no clips
+	}
+
 	// Start datetime filter
 	if startDateTimeStr := c.Query("startDateTime"); startDateTimeStr != "" {
 		if startDateTime, err := time.Parse("2006-01-02T15:04", startDateTimeStr); err == nil {
@@ -87,9 +104,16 @@ func (h *ClipHandler) ListClips(c *gin.Context) {
 		clientList = []*clients.Client{}
 	}
 
+	groups, err := h.groupService.GetGroups()
+	if err != nil {
+		h.logger.Error("Failed to get client groups", err)
+		groups = []*clients.ClientGroup{}
+	}
+
 	// Prepare current filter values for the template
 	filterValues := gin.H{
 		"ClientID":      c.Query("clientId"),
+		"GroupID":       c.Query("groupId"),
 		"StartDateTime": c.Query("startDateTime"),
 		"EndDateTime":   c.Query("endDateTime"),
 		"HasMotion":     c.Query("hasMotion"),
@@ -103,7 +127,10 @@ func (h *ClipHandler) ListClips(c *gin.Context) {
 		"PageSize":     pageSize,
 		"TotalPages":   (total + pageSize - 1) / pageSize,
 		"Clients":      clientList,
+		"ClientsByID":  clientsByID(clientList),
+		"Groups":       groups,
 		"FilterValues": filterValues,
+		"CurrentUser":  sessions.GetCurrentUser(c),
 	})
 }
 
@@ -158,9 +185,17 @@ func (h *ClipHandler) ViewClip(c *gin.Context) {
 		return
 	}
 
+	// The client provides the name and timezone the clip is shown with. Without it, the clip is still shown.
+	client, err := h.clientService.GetClient(clipInfo.ClientID)
+	if err != nil {
+		h.logger.Error("Failed to get client", err, "clientID", clipInfo.ClientID)
+	}
+
 	c.HTML(http.StatusOK, "clip-detail", gin.H{
-		"Title": "Clip Detail - " + clipInfo.Title,
-		"Clip":  clipInfo,
+		"Title":       "Clip Detail - " + clipInfo.Title,
+		"Clip":        clipInfo,
+		"Client":      client,
+		"CurrentUser": sessions.GetCurrentUser(c),
 	})
 }
 
@@ -233,6 +268,10 @@ func (h *ClipHandler) DownloadVideo(c *gin.Context) {
 }
 
 func (h *ClipHandler) DeleteClips(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
 	var request videos.DeleteClipsRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		h.logger.Error("Failed to bind JSON for clip deletion", err)
@@ -254,3 +293,12 @@ func (h *ClipHandler) DeleteClips(c *gin.Context) {
 	// Return the response with details about which clips were deleted and which failed
 	c.JSON(http.StatusOK, response)
 }
+
+// clientsByID indexes clients by their IDs, so that templates can show clips with the names of their clients
+func clientsByID(clientList []*clients.Client) map[string]*clients.Client {
+	byID := make(map[string]*clients.Client, len(clientList))
+	for _, client := range clientList {
+		byID[client.ID] = client
+	}
+	return byID
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/commands"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// commandHistoryLimit is the number of past commands shown for a client
+const commandHistoryLimit = 50
+
+// CommandHandler sends commands to capture clients and shows their results
+type CommandHandler struct {
+	logger          logging.Logger
+	commandService  commands.CommandService
+	clientService   clients.ClientService
+	mekStoreFactory sessions.MekStoreFactory
+}
+
+func NewCommandHandler(logger logging.Logger, commandService commands.CommandService, clientService clients.ClientService, mekStoreFactory sessions.MekStoreFactory) *CommandHandler {
+	return &CommandHandler{
+		logger:          logger,
+		commandService:  commandService,
+		clientService:   clientService,
+		mekStoreFactory: mekStoreFactory,
+	}
+}
+
+// ShowCommands handles GET /clients/:id/commands
+func (h *CommandHandler) ShowCommands(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	h.renderCommands(c, http.StatusOK, client, "")
+}
+
+// IssueCommand handles POST /clients/:id/commands
+func (h *CommandHandler) IssueCommand(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	commandType := commands.CommandType(c.PostForm("type"))
+	if _, err := h.commandService.IssueCommand(client.ID, commandType, sessions.GetCurrentUser(c).Username); err != nil {
+		if commands.IsCommandValidationError(err) {
+			h.renderCommands(c, http.StatusBadRequest, client, err.Error())
+			return
+		}
+		h.logger.Error("Failed to issue command", err)
+		h.renderCommands(c, http.StatusInternalServerError, client, "Failed to send command.")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/clients/"+client.ID+"/commands")
+}
+
+// GetAttachment handles GET /clients/:id/commands/:commandId/attachment
+func (h *CommandHandler) GetAttachment(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	command, err := h.commandService.GetCommand(c.Param("commandId"))
+	if err != nil {
+		if commands.IsCommandNotFoundError(err) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if command.ClientID != c.Param("id") {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	attachment, err := h.commandService.GetAttachment(command.ID, h.mekStoreFactory(c))
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if attachment == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Data(http.StatusOK, attachment.MimeType, attachment.Data)
+}
+
+func (h *CommandHandler) getClient(c *gin.Context) (*clients.Client, bool) {
+	client, err := h.clientService.GetClient(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to get client", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Failed to load client",
+		})
+		return nil, false
+	}
+	if client == nil {
+		c.HTML(http.StatusNotFound, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Client not found",
+		})
+		return nil, false
+	}
+	return client, true
+}
+
+func (h *CommandHandler) renderCommands(c *gin.Context, status int, client *clients.Client, errorMessage string) {
+	history, err := h.commandService.GetCommands(client.ID, commandHistoryLimit)
+	if err != nil {
+		h.logger.Error("Failed to get commands", err)
+		if errorMessage == "" {
+			errorMessage = "Failed to load command history."
+		}
+	}
+
+	// Reload the page while the client has not answered yet
+	inProgress := false
+	for _, command := range history {
+		if !command.Status.IsFinal() {
+			inProgress = true
+			break
+		}
+	}
+
+	c.HTML(status, "client-commands", gin.H{
+		"Title":        "Clients",
+		"Client":       client,
+		"Commands":     history,
+		"CommandTypes": commands.SupportedCommandTypes(),
+		"InProgress":   inProgress,
+		"Error":        errorMessage,
+		"CurrentUser":  sessions.GetCurrentUser(c),
+	})
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// GroupHandler manages client groups and their settings templates
+type GroupHandler struct {
+	logger        logging.Logger
+	clientService clients.ClientService
+	groupService  clients.ClientGroupService
+}
+
+func NewGroupHandler(logger logging.Logger, clientService clients.ClientService, groupService clients.ClientGroupService) *GroupHandler {
+	return &GroupHandler{
+		logger:        logger,
+		clientService: clientService,
+		groupService:  groupService,
+	}
+}
+
+// groupForm is the view model of a group settings form
+type groupForm struct {
+	*clients.ClientGroup
+	FormID    string   // Prefix of the element IDs in the form
+	MemberIDs []string // IDs of the clients in the group
+	Options   gin.H    // Supported values of the select fields
+}
+
+// newGroupDefaults returns the template values pre-filled in the form for a new group (the same defaults as for a new client)
+func newGroupDefaults() *clients.ClientGroup {
+	return &clients.ClientGroup{
+		StorageLimitMegabytes: 1024,
+		ClipDurationSeconds:   60,
+		OutputFormat:          "mp4",
+		OutputCodec:           "libx264",
+		VideoBitRate:          "1000k",
+		MotionMinArea:         1000,
+		MotionMaxFrames:       300,
+		MotionWarmUpFrames:    30,
+		MotionMinWidth:        20,
+		MotionMinHeight:       20,
+		MotionMinAspect:       0.3,
+		MotionMaxAspect:       3.0,
+		MotionMogHistory:      500,
+		MotionMogVarThresh:    16.0,
+		CaptureCodec:          "MJPG",
+		CaptureFrameRate:      15.0,
+	}
+}
+
+// ListGroups handles GET /groups
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+	h.renderGroups(c, http.StatusOK, "")
+}
+
+// CreateGroup handles POST /groups
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	settings, err := parseClientSettingsForm(c)
+	if err != nil {
+		h.renderGroups(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(clients.ClientGroupRequest{
+		Name:        c.PostForm("name"),
+		Description: c.PostForm("description"),
+		Settings:    settings,
+	})
+	if err != nil {
+		if clients.IsClientValidationError(err) {
+			h.renderGroups(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.logger.Error("Failed to create client group", err)
+		h.renderGroups(c, http.StatusInternalServerError, "Failed to create group.")
+		return
+	}
+
+	h.logger.Info("Client group created", "id", group.ID, "name", group.Name, "by", sessions.GetCurrentUser(c).Username)
+	c.Redirect(http.StatusFound, "/groups")
+}
+
+// UpdateGroup handles POST /groups/:id. The template is applied to all members right away.
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	settings, err := parseClientSettingsForm(c)
+	if err != nil {
+		h.renderGroups(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.groupService.UpdateGroup(clients.ClientGroupRequest{
+		ID:          c.Param("id"),
+		Name:        c.PostForm("name"),
+		Description: c.PostForm("description"),
+		Settings:    settings,
+	}, sessions.GetCurrentUser(c).Username)
+	if err != nil {
+		if clients.IsClientValidationError(err) || clients.IsClientGroupNotFoundError(err) {
+			h.renderGroups(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.logger.Error("Failed to update client group", err)
+		h.renderGroups(c, http.StatusInternalServerError, "Failed to update group.")
+		return
+	}
+
+	h.logger.Info("Client group updated", "id", c.Param("id"), "by", sessions.GetCurrentUser(c).Username)
+	c.Redirect(http.StatusFound, "/groups")
+}
+
+// DeleteGroup handles POST /groups/:id/delete
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	if err := h.groupService.DeleteGroup(c.Param("id")); err != nil {
+		h.logger.Error("Failed to delete client group", err)
+		h.renderGroups(c, http.StatusInternalServerError, "Failed to delete group.")
+		return
+	}
+
+	h.logger.Info("Client group deleted", "id", c.Param("id"), "by", sessions.GetCurrentUser(c).Username)
+	c.Redirect(http.StatusFound, "/groups")
+}
+
+func (h *GroupHandler) renderGroups(c *gin.Context, status int, errorMessage string) {
+	options := gin.H{
+		"SupportedResolutions":   h.clientService.GetSupportedDownscaleResolutions(),
+		"SupportedCaptureCodecs": h.clientService.GetSupportedCaptureCodecs(),
+		"SupportedOutputCodecs":  h.clientService.GetSupportedOutputCodecs(),
+		"SupportedOutputFormats": h.clientService.GetSupportedOutputFormats(),
+		"SupportedVideoBitrates": h.clientService.GetSupportedVideoBitrates(),
+	}
+	data := gin.H{
+		"Title":       "Groups",
+		"Error":       errorMessage,
+		"NewGroup":    groupForm{ClientGroup: newGroupDefaults(), FormID: "new", Options: options},
+		"CurrentUser": sessions.GetCurrentUser(c),
+	}
+
+	groups, err := h.groupService.GetGroups()
+	if err != nil {
+		h.logger.Error("Failed to get client groups", err)
+		data["Error"] = "Failed to load groups."
+		c.HTML(http.StatusInternalServerError, "groups", data)
+		return
+	}
+
+	forms := make([]groupForm, len(groups))
+	for i, group := range groups {
+		memberIDs, err := h.groupService.GetMemberIDs(group.ID)
+		if err != nil {
+			h.logger.Warn("Failed to get group members", "group_id", group.ID, "error", err)
+		}
+		forms[i] = groupForm{ClientGroup: group, FormID: group.ID, MemberIDs: memberIDs, Options: options}
+	}
+	data["Groups"] = forms
+
+	c.HTML(status, "groups", data)
+}
+
+// parseClientSettingsForm reads the settings fields shared by the client and group settings forms
+func parseClientSettingsForm(c *gin.Context) (clients.UpdateClientSettingsRequest, error) {
+	req := clients.UpdateClientSettingsRequest{
+		MotionOnly:          c.PostForm("motion_only") == "on",
+		Grayscale:           c.PostForm("grayscale") == "on",
+		DownscaleResolution: c.PostForm("downscale_resolution"),
+		OutputFormat:        c.PostForm("output_format"),
+		OutputCodec:         c.PostForm("output_codec"),
+		VideoBitRate:        c.PostForm("video_bitrate"),
+		CaptureCodec:        c.PostForm("capture_codec"),
+	}
+
+	ints := []struct {
+		field string
+		label string
+		dest  *int
+	}{
+		{"storage_limit", "storage limit", &req.StorageLimitMegabytes},
+		{"clip_duration", "clip duration", &req.ClipDurationSeconds},
+		{"motion_min_area", "motion min area", &req.MotionMinArea},
+		{"motion_max_frames", "motion max frames", &req.MotionMaxFrames},
+		{"motion_warm_up_frames", "motion warm up frames", &req.MotionWarmUpFrames},
+		{"motion_min_width", "motion min width", &req.MotionMinWidth},
+		{"motion_min_height", "motion min height", &req.MotionMinHeight},
+		{"motion_mog_history", "motion MOG history", &req.MotionMogHistory},
+	}
+	for _, f := range ints {
+		value, err := strconv.Atoi(c.PostForm(f.field))
+		if err != nil {
+			return req, fmt.Errorf("Invalid %s.", f.label)
+		}
+		*f.dest = value
+	}
+
+	floats := []struct {
+		field string
+		label string
+		dest  *float64
+	}{
+		{"motion_min_aspect", "motion min aspect", &req.MotionMinAspect},
+		{"motion_max_aspect", "motion max aspect", &req.MotionMaxAspect},
+		{"motion_mog_var_thresh", "motion MOG var thresh", &req.MotionMogVarThresh},
+		{"capture_frame_rate", "capture frame rate", &req.CaptureFrameRate},
+	}
+	for _, f := range floats {
+		value, err := strconv.ParseFloat(c.PostForm(f.field), 64)
+		if err != nil {
+			return req, fmt.Errorf("Invalid %s.", f.label)
+		}
+		*f.dest = value
+	}
+
+	return req, nil
+}
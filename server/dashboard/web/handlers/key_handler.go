@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/encryption"
+	"github.com/yeti47/cryospy/server/core/users"
+)
+
+// KeyHandler manages the secrets that unlock the MEK: the primary password and additional key slots
+type KeyHandler struct {
+	logger     logging.Logger
+	mekService encryption.MekService
+}
+
+func NewKeyHandler(logger logging.Logger, mekService encryption.MekService) *KeyHandler {
+	return &KeyHandler{
+		logger:     logger,
+		mekService: mekService,
+	}
+}
+
+func (h *KeyHandler) ListKeys(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	h.renderKeys(c, http.StatusOK, gin.H{})
+}
+
+func (h *KeyHandler) AddPasswordSlot(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	currentPassword := c.PostForm("current_password")
+	label := c.PostForm("label")
+	newPassword := c.PostForm("new_password")
+	confirmPassword := c.PostForm("confirm_password")
+
+	if currentPassword == "" || newPassword == "" || confirmPassword == "" {
+		h.renderKeys(c, http.StatusBadRequest, gin.H{"Error": "All password fields are required."})
+		return
+	}
+
+	if newPassword != confirmPassword {
+		h.renderKeys(c, http.StatusBadRequest, gin.H{"Error": "Passwords do not match."})
+		return
+	}
+
+	slot, err := h.mekService.AddPasswordSlot(currentPassword, label, newPassword)
+	if err != nil {
+		h.renderSlotError(c, "Failed to add password slot", err)
+		return
+	}
+
+	h.logger.Info("Added password key slot", "slot_id", slot.ID)
+	c.Redirect(http.StatusFound, "/keys")
+}
+
+func (h *KeyHandler) AddRecoveryKeySlot(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	currentPassword := c.PostForm("current_password")
+	label := c.PostForm("label")
+
+	if currentPassword == "" {
+		h.renderKeys(c, http.StatusBadRequest, gin.H{"Error": "Current password is required."})
+		return
+	}
+
+	slot, recoveryKey, err := h.mekService.AddRecoveryKeySlot(currentPassword, label)
+	if err != nil {
+		h.renderSlotError(c, "Failed to add recovery key slot", err)
+		return
+	}
+
+	h.logger.Info("Added recovery key slot", "slot_id", slot.ID)
+	h.renderKeys(c, http.StatusOK, gin.H{
+		"RecoveryKey":      recoveryKey,
+		"RecoveryKeyLabel": slot.Label,
+	})
+}
+
+func (h *KeyHandler) RemoveSlot(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	slotID := c.Param("id")
+	currentPassword := c.PostForm("current_password")
+
+	if currentPassword == "" {
+		h.renderKeys(c, http.StatusBadRequest, gin.H{"Error": "Current password is required."})
+		return
+	}
+
+	if err := h.mekService.RemoveMekSlot(currentPassword, slotID); err != nil {
+		h.renderSlotError(c, "Failed to remove key slot", err)
+		return
+	}
+
+	h.logger.Info("Removed key slot", "slot_id", slotID)
+	c.Redirect(http.StatusFound, "/keys")
+}
+
+func (h *KeyHandler) ChangePassword(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	currentPassword := c.PostForm("current_password")
+	newPassword := c.PostForm("new_password")
+	confirmPassword := c.PostForm("confirm_password")
+
+	if currentPassword == "" || newPassword == "" || confirmPassword == "" {
+		h.renderKeys(c, http.StatusBadRequest, gin.H{"Error": "All password fields are required."})
+		return
+	}
+
+	if newPassword != confirmPassword {
+		h.renderKeys(c, http.StatusBadRequest, gin.H{"Error": "Passwords do not match."})
+		return
+	}
+
+	if _, err := h.mekService.ChangeMekPassword(currentPassword, newPassword); err != nil {
+		h.renderSlotError(c, "Failed to change primary password", err)
+		return
+	}
+
+	h.logger.Info("Changed primary MEK password")
+	h.renderKeys(c, http.StatusOK, gin.H{"Success": "Primary password changed."})
+}
+
+// renderSlotError maps key slot errors to user-facing messages
+func (h *KeyHandler) renderSlotError(c *gin.Context, logMessage string, err error) {
+	switch {
+	case encryption.IsInvalidMekSecretError(err):
+		h.logger.Warn(logMessage, "error", err)
+		h.renderKeys(c, http.StatusUnauthorized, gin.H{"Error": "Current password is incorrect."})
+	case encryption.IsMekSlotNotFoundError(err):
+		h.logger.Warn(logMessage, "error", err)
+		h.renderKeys(c, http.StatusNotFound, gin.H{"Error": "Key slot not found."})
+	default:
+		h.logger.Error(logMessage, err)
+		h.renderKeys(c, http.StatusInternalServerError, gin.H{"Error": "An internal error occurred."})
+	}
+}
+
+func (h *KeyHandler) renderKeys(c *gin.Context, status int, data gin.H) {
+	data["Title"] = "Keys"
+
+	mek, err := h.mekService.GetMek()
+	if err != nil {
+		h.logger.Error("Failed to get MEK", err)
+		data["Error"] = "Failed to load keys."
+		c.HTML(http.StatusInternalServerError, "keys", data)
+		return
+	}
+
+	slots, err := h.mekService.GetMekSlots()
+	if err != nil {
+		h.logger.Error("Failed to get key slots", err)
+		data["Error"] = "Failed to load keys."
+		c.HTML(http.StatusInternalServerError, "keys", data)
+		return
+	}
+
+	data["Mek"] = mek
+	data["Slots"] = slots
+	c.HTML(status, "keys", data)
+}
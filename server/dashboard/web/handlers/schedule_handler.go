@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// blankScheduleRows is the number of empty rows offered in the schedule editor for new entries
+const blankScheduleRows = 3
+
+// ScheduleHandler edits the weekly recording schedules of clients
+type ScheduleHandler struct {
+	logger        logging.Logger
+	clientService clients.ClientService
+}
+
+func NewScheduleHandler(logger logging.Logger, clientService clients.ClientService) *ScheduleHandler {
+	return &ScheduleHandler{
+		logger:        logger,
+		clientService: clientService,
+	}
+}
+
+// scheduleRow is the view model of one row in the schedule editor
+type scheduleRow struct {
+	Index int
+	clients.ScheduleEntry
+}
+
+// HasDay reports whether the row's range starts on the given day
+func (r scheduleRow) HasDay(day time.Weekday) bool {
+	return slices.Contains(r.Days, day)
+}
+
+// ShowSchedule handles GET /clients/:id/schedule
+func (h *ScheduleHandler) ShowSchedule(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	h.renderSchedule(c, http.StatusOK, client, client.Timezone, client.Schedule, "")
+}
+
+// UpdateSchedule handles POST /clients/:id/schedule
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	timezone := c.PostForm("timezone")
+	schedule := parseScheduleForm(c)
+	username := sessions.GetCurrentUser(c).Username
+
+	if err := h.clientService.UpdateClientSchedule(client.ID, timezone, schedule, username); err != nil {
+		if clients.IsClientValidationError(err) {
+			h.renderSchedule(c, http.StatusBadRequest, client, timezone, schedule, err.Error())
+			return
+		}
+		h.logger.Error("Failed to update client schedule", err)
+		h.renderSchedule(c, http.StatusInternalServerError, client, timezone, schedule, "Failed to save schedule.")
+		return
+	}
+
+	h.logger.Info("Client schedule updated", "clientId", client.ID, "entries", len(schedule), "by", username)
+	c.Redirect(http.StatusFound, "/clients/"+client.ID+"/schedule")
+}
+
+func (h *ScheduleHandler) getClient(c *gin.Context) (*clients.Client, bool) {
+	client, err := h.clientService.GetClient(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to get client", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Failed to load client",
+		})
+		return nil, false
+	}
+	if client == nil {
+		c.HTML(http.StatusNotFound, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Client not found",
+		})
+		return nil, false
+	}
+	return client, true
+}
+
+func (h *ScheduleHandler) renderSchedule(c *gin.Context, status int, client *clients.Client, timezone string, schedule []clients.ScheduleEntry, errorMessage string) {
+	rows := make([]scheduleRow, 0, len(schedule)+blankScheduleRows)
+	for _, entry := range schedule {
+		rows = append(rows, scheduleRow{Index: len(rows), ScheduleEntry: entry})
+	}
+	for range blankScheduleRows {
+		rows = append(rows, scheduleRow{Index: len(rows)})
+	}
+
+	weekdays := make([]time.Weekday, 0, 7)
+	for day := time.Monday; len(weekdays) < 7; day = (day + 1) % 7 {
+		weekdays = append(weekdays, day)
+	}
+
+	c.HTML(status, "client-schedule", gin.H{
+		"Title":    "Clients",
+		"Client":   client,
+		"Timezone": timezone,
+		"Rows":     rows,
+		"RowCount": len(rows),
+		"Weekdays": weekdays,
+		"Modes": []clients.RecordingMode{
+			clients.RecordingModeMotionOnly, clients.RecordingModeContinuous, clients.RecordingModeOff,
+		},
+		"Sensitivities": []clients.MotionSensitivity{
+			clients.MotionSensitivityDefault, clients.MotionSensitivityLow, clients.MotionSensitivityHigh,
+		},
+		"Error":       errorMessage,
+		"CurrentUser": sessions.GetCurrentUser(c),
+	})
+}
+
+// parseScheduleForm reads the rows of the schedule editor. Rows without a start time are left out.
+func parseScheduleForm(c *gin.Context) []clients.ScheduleEntry {
+	rowCount, _ := strconv.Atoi(c.PostForm("rows"))
+
+	var schedule []clients.ScheduleEntry
+	for i := 0; i < rowCount; i++ {
+		prefix := strconv.Itoa(i)
+		start := c.PostForm("start_" + prefix)
+		if start == "" {
+			continue
+		}
+
+		var days []time.Weekday
+		for _, value := range c.PostFormArray("days_" + prefix) {
+			if day, err := strconv.Atoi(value); err == nil {
+				days = append(days, time.Weekday(day))
+			}
+		}
+		slices.Sort(days)
+
+		schedule = append(schedule, clients.ScheduleEntry{
+			Days:        days,
+			Start:       start,
+			End:         c.PostForm("end_" + prefix),
+			Mode:        clients.RecordingMode(c.PostForm("mode_" + prefix)),
+			Sensitivity: clients.MotionSensitivity(c.PostForm("sensitivity_" + prefix)),
+		})
+	}
+
+	return schedule
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+type SessionHandler struct {
+	logger        logging.Logger
+	sessionStore  sessions.SessionStore
+	sessionCookie *sessions.SessionCookie
+}
+
+func NewSessionHandler(logger logging.Logger, sessionStore sessions.SessionStore, sessionCookie *sessions.SessionCookie) *SessionHandler {
+	return &SessionHandler{
+		logger:        logger,
+		sessionStore:  sessionStore,
+		sessionCookie: sessionCookie,
+	}
+}
+
+// sessionView is a session as shown on the sessions page, without the MEK
+type sessionView struct {
+	*sessions.Session
+	Account   string
+	IsCurrent bool
+	ExpiresAt string
+}
+
+// ListSessions shows the sessions of the current user. Admins see the sessions of every account.
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	user := sessions.GetCurrentUser(c)
+	activeSessions, err := h.visibleSessions(user)
+	if err != nil {
+		h.logger.Error("Failed to list sessions", err)
+		c.HTML(http.StatusInternalServerError, "sessions", gin.H{
+			"Title": "Sessions",
+			"Error": "Failed to load sessions.",
+		})
+		return
+	}
+
+	currentID, _ := h.sessionCookie.GetSessionID(c)
+	settings := h.sessionStore.Settings()
+
+	views := make([]sessionView, len(activeSessions))
+	for i, session := range activeSessions {
+		expiresAt := "Never"
+		if expiry := session.ExpiresAt(settings); !expiry.IsZero() {
+			expiresAt = expiry.Local().Format("2006-01-02 15:04:05")
+		}
+		views[i] = sessionView{
+			Session:   session,
+			Account:   session.Identity().Username,
+			IsCurrent: session.ID == currentID,
+			ExpiresAt: expiresAt,
+		}
+	}
+
+	c.HTML(http.StatusOK, "sessions", gin.H{
+		"Title":       "Sessions",
+		"Sessions":    views,
+		"CurrentUser": user,
+	})
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	id := c.PostForm("session_id")
+	if id == "" {
+		c.Redirect(http.StatusFound, "/sessions")
+		return
+	}
+
+	// Users other than admins can only end their own sessions
+	user := sessions.GetCurrentUser(c)
+	if !user.IsAdmin() {
+		visible, err := h.visibleSessions(user)
+		if err != nil {
+			h.logger.Error("Failed to list sessions", err)
+			c.HTML(http.StatusInternalServerError, "sessions", gin.H{
+				"Title": "Sessions",
+				"Error": "Failed to revoke session.",
+			})
+			return
+		}
+		if !slices.ContainsFunc(visible, func(session *sessions.Session) bool { return session.ID == id }) {
+			c.Redirect(http.StatusFound, "/sessions")
+			return
+		}
+	}
+
+	if err := h.sessionStore.Revoke(id); err != nil {
+		h.logger.Error("Failed to revoke session", err)
+		c.HTML(http.StatusInternalServerError, "sessions", gin.H{
+			"Title": "Sessions",
+			"Error": "Failed to revoke session.",
+		})
+		return
+	}
+
+	h.logger.Info("Dashboard session revoked")
+
+	currentID, _ := h.sessionCookie.GetSessionID(c)
+	if id == currentID {
+		if err := h.sessionCookie.Clear(c); err != nil {
+			h.logger.Error("Failed to clear session cookie", err)
+		}
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/sessions")
+}
+
+// RevokeAllSessions logs out every session of the current user. Admins log out every account.
+func (h *SessionHandler) RevokeAllSessions(c *gin.Context) {
+	user := sessions.GetCurrentUser(c)
+
+	var err error
+	if user.IsAdmin() {
+		err = h.sessionStore.RevokeAll()
+	} else {
+		err = h.sessionStore.RevokeUser(user.UserID)
+	}
+	if err != nil {
+		h.logger.Error("Failed to revoke all sessions", err)
+		c.HTML(http.StatusInternalServerError, "sessions", gin.H{
+			"Title": "Sessions",
+			"Error": "Failed to log out everywhere.",
+		})
+		return
+	}
+
+	h.logger.Info("Dashboard sessions revoked", "username", user.Username, "allAccounts", user.IsAdmin())
+
+	if err := h.sessionCookie.Clear(c); err != nil {
+		h.logger.Error("Failed to clear session cookie", err)
+	}
+	c.Redirect(http.StatusFound, "/auth/login")
+}
+
+// visibleSessions returns the sessions the user may see and end
+func (h *SessionHandler) visibleSessions(user *sessions.CurrentUser) ([]*sessions.Session, error) {
+	activeSessions, err := h.sessionStore.List()
+	if err != nil || user.IsAdmin() {
+		return activeSessions, err
+	}
+
+	return slices.DeleteFunc(activeSessions, func(session *sessions.Session) bool {
+		return session.UserID != user.UserID
+	}), nil
+}
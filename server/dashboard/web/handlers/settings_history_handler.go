@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/clients"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// SettingsHistoryHandler shows the settings versions of clients, compares them and rolls settings back
+type SettingsHistoryHandler struct {
+	logger        logging.Logger
+	clientService clients.ClientService
+}
+
+func NewSettingsHistoryHandler(logger logging.Logger, clientService clients.ClientService) *SettingsHistoryHandler {
+	return &SettingsHistoryHandler{
+		logger:        logger,
+		clientService: clientService,
+	}
+}
+
+// settingsVersionRow is the view model of one version in the settings history
+type settingsVersionRow struct {
+	*clients.SettingsVersion
+	Changes   []clients.SettingChange // Changes from the previous version, empty for the first version
+	IsCurrent bool                    // Whether these are the settings the client currently has
+}
+
+// settingsComparison is the view model of the diff between two versions
+type settingsComparison struct {
+	From    int
+	To      int
+	Changes []clients.SettingChange
+}
+
+// ShowHistory handles GET /clients/:id/settings-history[?from=<version>&to=<version>]
+func (h *SettingsHistoryHandler) ShowHistory(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	h.renderHistory(c, http.StatusOK, client, "")
+}
+
+// Rollback handles POST /clients/:id/settings-history/:version/rollback
+func (h *SettingsHistoryHandler) Rollback(c *gin.Context) {
+	if !authorize(c, users.RoleOperator) {
+		return
+	}
+
+	client, ok := h.getClient(c)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		h.renderHistory(c, http.StatusBadRequest, client, "Invalid version.")
+		return
+	}
+
+	username := sessions.GetCurrentUser(c).Username
+	if err := h.clientService.RollbackClientSettings(client.ID, version, username); err != nil {
+		if clients.IsClientValidationError(err) {
+			h.renderHistory(c, http.StatusBadRequest, client, err.Error())
+			return
+		}
+		h.logger.Error("Failed to roll back client settings", err)
+		h.renderHistory(c, http.StatusInternalServerError, client, "Failed to roll back settings.")
+		return
+	}
+
+	h.logger.Info("Client settings rolled back", "id", client.ID, "version", version, "by", username)
+	c.Redirect(http.StatusFound, "/clients/"+client.ID+"/settings-history")
+}
+
+func (h *SettingsHistoryHandler) getClient(c *gin.Context) (*clients.Client, bool) {
+	client, err := h.clientService.GetClient(c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to get client", err)
+		c.HTML(http.StatusInternalServerError, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Failed to load client",
+		})
+		return nil, false
+	}
+	if client == nil {
+		c.HTML(http.StatusNotFound, "error", gin.H{
+			"Title":   "Error",
+			"Message": "Client not found",
+		})
+		return nil, false
+	}
+	return client, true
+}
+
+func (h *SettingsHistoryHandler) renderHistory(c *gin.Context, status int, client *clients.Client, errorMessage string) {
+	versions, err := h.clientService.GetSettingsVersions(client.ID)
+	if err != nil {
+		h.logger.Error("Failed to get settings versions", err)
+		if errorMessage == "" {
+			errorMessage = "Failed to load settings history."
+		}
+	}
+
+	// Versions are ordered newest first, so each version is compared with the one after it
+	rows := make([]settingsVersionRow, len(versions))
+	byNumber := make(map[int]*clients.SettingsVersion, len(versions))
+	for i, version := range versions {
+		rows[i] = settingsVersionRow{SettingsVersion: version, IsCurrent: version.Version == client.SettingsVersion}
+		if i+1 < len(versions) {
+			rows[i].Changes = clients.DiffSettings(versions[i+1].Settings, version.Settings)
+		}
+		byNumber[version.Version] = version
+	}
+
+	var comparison *settingsComparison
+	from, fromErr := strconv.Atoi(c.Query("from"))
+	to, toErr := strconv.Atoi(c.Query("to"))
+	if fromErr == nil && toErr == nil {
+		if byNumber[from] != nil && byNumber[to] != nil {
+			comparison = &settingsComparison{
+				From:    from,
+				To:      to,
+				Changes: clients.DiffSettings(byNumber[from].Settings, byNumber[to].Settings),
+			}
+		} else if errorMessage == "" {
+			errorMessage = "The versions to compare do not exist."
+		}
+	}
+
+	c.HTML(status, "settings-history", gin.H{
+		"Title":      "Clients",
+		"Client":     client,
+		"Versions":   rows,
+		"Comparison": comparison,
+		"Error":      errorMessage,
+	})
+}
@@ -16,14 +16,16 @@ type StreamHandler struct {
 	logger           logging.Logger
 	streamingService streaming.StreamingService
 	clientService    clients.ClientService
+	groupService     clients.ClientGroupService
 	mekStoreFactory  sessions.MekStoreFactory
 }
 
-func NewStreamHandler(logger logging.Logger, streamingService streaming.StreamingService, clientService clients.ClientService, mekStoreFactory sessions.MekStoreFactory) *StreamHandler {
+func NewStreamHandler(logger logging.Logger, streamingService streaming.StreamingService, clientService clients.ClientService, groupService clients.ClientGroupService, mekStoreFactory sessions.MekStoreFactory) *StreamHandler {
 	return &StreamHandler{
 		logger:           logger,
 		streamingService: streamingService,
 		clientService:    clientService,
+		groupService:     groupService,
 		mekStoreFactory:  mekStoreFactory,
 	}
 }
@@ -31,7 +33,7 @@ func NewStreamHandler(logger logging.Logger, streamingService streaming.Streamin
 // ShowStreamSelection displays the stream selection page
 func (h *StreamHandler) ShowStreamSelection(c *gin.Context) {
 	// Get all clients for selection
-	clients, err := h.clientService.GetClients()
+	clientList, err := h.clientService.GetClients()
 	if err != nil {
 		h.logger.Error("Failed to get clients", err)
 		c.HTML(http.StatusInternalServerError, "error", gin.H{
@@ -40,10 +42,31 @@ func (h *StreamHandler) ShowStreamSelection(c *gin.Context) {
 		})
 		return
 	}
+	clientList = withoutArchived(clientList)
+
+	groups, err := h.groupService.GetGroups()
+	if err != nil {
+		h.logger.Error("Failed to get client groups", err)
+		groups = []*clients.ClientGroup{}
+	}
+
+	// Only offer the members of the selected group
+	groupID := c.Query("groupId")
+	if groupID != "" {
+		var members []*clients.Client
+		for _, client := range clientList {
+			if client.GroupID == groupID {
+				members = append(members, client)
+			}
+		}
+		clientList = members
+	}
 
 	c.HTML(http.StatusOK, "stream-selection", gin.H{
 		"Title":   "Stream Selection",
-		"Clients": clients,
+		"Clients": clientList,
+		"Groups":  groups,
+		"GroupID": groupID,
 	})
 }
 
@@ -85,7 +108,7 @@ func (h *StreamHandler) ShowStream(c *gin.Context) {
 	}
 
 	c.HTML(http.StatusOK, "stream", gin.H{
-		"Title":     "Stream - " + client.ID,
+		"Title":     "Stream - " + client.Name(),
 		"Client":    client,
 		"StartTime": startTime.Format(time.RFC3339),
 		"RefTime":   refTime.Format(time.RFC3339),
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/twofactor"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+type TwoFactorHandler struct {
+	logger           logging.Logger
+	twoFactorService twofactor.TwoFactorService
+	mekStoreFactory  sessions.MekStoreFactory
+}
+
+func NewTwoFactorHandler(logger logging.Logger, twoFactorService twofactor.TwoFactorService, mekStoreFactory sessions.MekStoreFactory) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		logger:           logger,
+		twoFactorService: twoFactorService,
+		mekStoreFactory:  mekStoreFactory,
+	}
+}
+
+func (h *TwoFactorHandler) ShowTwoFactor(c *gin.Context) {
+	h.renderStatus(c, http.StatusOK, gin.H{})
+}
+
+func (h *TwoFactorHandler) BeginEnrollment(c *gin.Context) {
+	enrollment, err := h.twoFactorService.BeginEnrollment(sessions.GetCurrentUser(c).Username)
+	if err != nil {
+		h.logger.Error("Failed to begin two-factor enrollment", err)
+		h.renderStatus(c, http.StatusInternalServerError, gin.H{"Error": "Failed to start enrollment."})
+		return
+	}
+
+	h.renderEnrollment(c, http.StatusOK, enrollment.Secret, enrollment.QRCodePNG, "")
+}
+
+func (h *TwoFactorHandler) ConfirmEnrollment(c *gin.Context) {
+	secret := c.PostForm("secret")
+	code := c.PostForm("code")
+
+	if secret == "" {
+		c.Redirect(http.StatusFound, "/security/2fa")
+		return
+	}
+
+	mek, err := h.mekStoreFactory(c).GetMek()
+	if err != nil {
+		h.logger.Error("Failed to get MEK from session", err)
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+
+	user := sessions.GetCurrentUser(c)
+	recoveryCodes, err := h.twoFactorService.ConfirmEnrollment(user.UserID, mek, secret, code)
+	if err != nil {
+		switch {
+		case twofactor.IsInvalidCodeError(err):
+			h.renderEnrollmentForSecret(c, secret, "Invalid code. Check the time on your device and try again.")
+		case twofactor.IsAlreadyEnabledError(err):
+			h.renderStatus(c, http.StatusConflict, gin.H{"Error": "Two-factor authentication is already enabled."})
+		default:
+			h.logger.Error("Failed to confirm two-factor enrollment", err)
+			h.renderStatus(c, http.StatusInternalServerError, gin.H{"Error": "Failed to enable two-factor authentication."})
+		}
+		return
+	}
+
+	h.logger.Info("Two-factor authentication enabled for the dashboard", "username", user.Username)
+	h.renderStatus(c, http.StatusOK, gin.H{"RecoveryCodes": recoveryCodes})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	code := c.PostForm("code")
+	if code == "" {
+		h.renderStatus(c, http.StatusBadRequest, gin.H{"Error": "Code is required."})
+		return
+	}
+
+	mek, err := h.mekStoreFactory(c).GetMek()
+	if err != nil {
+		h.logger.Error("Failed to get MEK from session", err)
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+
+	user := sessions.GetCurrentUser(c)
+	if err := h.twoFactorService.Disable(user.UserID, mek, code); err != nil {
+		switch {
+		case twofactor.IsInvalidCodeError(err):
+			h.renderStatus(c, http.StatusUnauthorized, gin.H{"Error": "Invalid code."})
+		case twofactor.IsNotEnabledError(err):
+			h.renderStatus(c, http.StatusConflict, gin.H{"Error": "Two-factor authentication is not enabled."})
+		default:
+			h.logger.Error("Failed to disable two-factor authentication", err)
+			h.renderStatus(c, http.StatusInternalServerError, gin.H{"Error": "Failed to disable two-factor authentication."})
+		}
+		return
+	}
+
+	h.logger.Info("Two-factor authentication disabled for the dashboard", "username", user.Username)
+	h.renderStatus(c, http.StatusOK, gin.H{"Success": "Two-factor authentication disabled."})
+}
+
+// renderEnrollmentForSecret re-renders the enrollment page for a secret that was already shown
+func (h *TwoFactorHandler) renderEnrollmentForSecret(c *gin.Context, secret, errorMessage string) {
+	enrollment, err := h.twoFactorService.EnrollmentForSecret(sessions.GetCurrentUser(c).Username, secret)
+	if err != nil {
+		h.logger.Error("Failed to render two-factor enrollment", err)
+		h.renderStatus(c, http.StatusInternalServerError, gin.H{"Error": "Failed to start enrollment."})
+		return
+	}
+	h.renderEnrollment(c, http.StatusBadRequest, enrollment.Secret, enrollment.QRCodePNG, errorMessage)
+}
+
+func (h *TwoFactorHandler) renderEnrollment(c *gin.Context, status int, secret string, qrCodePNG []byte, errorMessage string) {
+	c.HTML(status, "two-factor", gin.H{
+		"Title":      "Two-Factor",
+		"Enrolling":  true,
+		"Secret":     secret,
+		"QRCodeData": template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCodePNG)),
+		"Error":      errorMessage,
+	})
+}
+
+func (h *TwoFactorHandler) renderStatus(c *gin.Context, status int, data gin.H) {
+	data["Title"] = "Two-Factor"
+
+	userID := sessions.GetCurrentUser(c).UserID
+	enabled, err := h.twoFactorService.IsEnabled(userID)
+	if err != nil {
+		h.logger.Error("Failed to check two-factor authentication", err)
+		data["Error"] = "Failed to load two-factor status."
+		c.HTML(http.StatusInternalServerError, "two-factor", data)
+		return
+	}
+	data["Enabled"] = enabled
+
+	if enabled {
+		remaining, err := h.twoFactorService.GetRemainingRecoveryCodeCount(userID)
+		if err != nil {
+			h.logger.Error("Failed to count recovery codes", err)
+		}
+		data["RemainingRecoveryCodes"] = remaining
+	}
+
+	c.HTML(status, "two-factor", data)
+}
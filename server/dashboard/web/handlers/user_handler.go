@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/core/twofactor"
+	"github.com/yeti47/cryospy/server/core/users"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+// UserHandler manages dashboard user accounts and lets every user change their own password
+type UserHandler struct {
+	logger           logging.Logger
+	userService      users.UserService
+	twoFactorService twofactor.TwoFactorService
+	sessionStore     sessions.SessionStore
+	mekStoreFactory  sessions.MekStoreFactory
+}
+
+func NewUserHandler(logger logging.Logger, userService users.UserService, twoFactorService twofactor.TwoFactorService, sessionStore sessions.SessionStore, mekStoreFactory sessions.MekStoreFactory) *UserHandler {
+	return &UserHandler{
+		logger:           logger,
+		userService:      userService,
+		twoFactorService: twoFactorService,
+		sessionStore:     sessionStore,
+		mekStoreFactory:  mekStoreFactory,
+	}
+}
+
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	h.renderUsers(c, http.StatusOK, gin.H{})
+}
+
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	username := c.PostForm("username")
+	password := c.PostForm("password")
+	confirmPassword := c.PostForm("confirm_password")
+	role := users.Role(c.PostForm("role"))
+
+	if username == "" || password == "" || confirmPassword == "" {
+		h.renderUsers(c, http.StatusBadRequest, gin.H{"Error": "Username and password are required."})
+		return
+	}
+
+	if password != confirmPassword {
+		h.renderUsers(c, http.StatusBadRequest, gin.H{"Error": "Passwords do not match."})
+		return
+	}
+
+	// The new user's password wraps the MEK of the admin's session
+	mek, err := h.mekStoreFactory(c).GetMek()
+	if err != nil {
+		h.logger.Error("Failed to get MEK from session", err)
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+
+	user, err := h.userService.CreateUser(mek, users.CreateUserRequest{
+		Username: username,
+		Password: password,
+		Role:     role,
+	})
+	if err != nil {
+		h.renderUserError(c, "Failed to create user", err)
+		return
+	}
+
+	h.logger.Info("Dashboard user created", "username", user.Username, "role", user.Role, "by", sessions.GetCurrentUser(c).Username)
+	h.renderUsers(c, http.StatusOK, gin.H{"Success": "User '" + user.Username + "' created."})
+}
+
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	id := c.Param("id")
+	role := users.Role(c.PostForm("role"))
+
+	user, err := h.userService.UpdateRole(id, role)
+	if err != nil {
+		h.renderUserError(c, "Failed to update user role", err)
+		return
+	}
+
+	h.logger.Info("Dashboard user role changed", "username", user.Username, "role", user.Role, "by", sessions.GetCurrentUser(c).Username)
+	c.Redirect(http.StatusFound, "/users")
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	id := c.Param("id")
+	newPassword := c.PostForm("new_password")
+	confirmPassword := c.PostForm("confirm_password")
+
+	if newPassword == "" || newPassword != confirmPassword {
+		h.renderUsers(c, http.StatusBadRequest, gin.H{"Error": "New passwords are required and must match."})
+		return
+	}
+
+	mek, err := h.mekStoreFactory(c).GetMek()
+	if err != nil {
+		h.logger.Error("Failed to get MEK from session", err)
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+
+	if err := h.userService.ResetPassword(mek, id, newPassword); err != nil {
+		h.renderUserError(c, "Failed to reset user password", err)
+		return
+	}
+
+	// Whoever knew the old password must not stay logged in
+	if err := h.sessionStore.RevokeUser(id); err != nil {
+		h.logger.Error("Failed to revoke sessions after password reset", err)
+	}
+
+	h.renderUsers(c, http.StatusOK, gin.H{"Success": "Password reset. The user has been logged out."})
+}
+
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	if !authorize(c, users.RoleAdmin) {
+		return
+	}
+
+	id := c.Param("id")
+
+	// Removing the user deletes its wrap of the MEK, so its password can no longer unlock anything
+	if err := h.userService.DeleteUser(id); err != nil {
+		h.renderUserError(c, "Failed to delete user", err)
+		return
+	}
+
+	if err := h.sessionStore.RevokeUser(id); err != nil {
+		h.logger.Error("Failed to revoke sessions of deleted user", err)
+	}
+	if err := h.twoFactorService.Remove(id); err != nil {
+		h.logger.Error("Failed to remove two-factor enrollment of deleted user", err)
+	}
+
+	h.logger.Info("Dashboard user deleted", "userId", id, "by", sessions.GetCurrentUser(c).Username)
+	c.Redirect(http.StatusFound, "/users")
+}
+
+func (h *UserHandler) ShowAccount(c *gin.Context) {
+	h.renderAccount(c, http.StatusOK, gin.H{})
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	user := sessions.GetCurrentUser(c)
+	if user.IsBuiltInAdmin() {
+		// The built-in admin account is unlocked by the primary password, which is managed on the keys page
+		c.Redirect(http.StatusFound, "/keys")
+		return
+	}
+
+	currentPassword := c.PostForm("current_password")
+	newPassword := c.PostForm("new_password")
+	confirmPassword := c.PostForm("confirm_password")
+
+	if currentPassword == "" || newPassword == "" || confirmPassword == "" {
+		h.renderAccount(c, http.StatusBadRequest, gin.H{"Error": "All password fields are required."})
+		return
+	}
+
+	if newPassword != confirmPassword {
+		h.renderAccount(c, http.StatusBadRequest, gin.H{"Error": "Passwords do not match."})
+		return
+	}
+
+	if err := h.userService.ChangePassword(user.UserID, currentPassword, newPassword); err != nil {
+		if users.IsInvalidCredentialsError(err) {
+			h.logger.Warn("Failed to change own password", "username", user.Username, "error", err)
+			h.renderAccount(c, http.StatusUnauthorized, gin.H{"Error": "Current password is incorrect."})
+			return
+		}
+		h.logger.Error("Failed to change own password", err)
+		h.renderAccount(c, http.StatusInternalServerError, gin.H{"Error": "An internal error occurred."})
+		return
+	}
+
+	h.renderAccount(c, http.StatusOK, gin.H{"Success": "Password changed."})
+}
+
+// renderUserError maps user errors to user-facing messages
+func (h *UserHandler) renderUserError(c *gin.Context, logMessage string, err error) {
+	switch {
+	case users.IsUserValidationError(err):
+		h.logger.Warn(logMessage, "error", err)
+		h.renderUsers(c, http.StatusBadRequest, gin.H{"Error": err.Error()})
+	case users.IsUserAlreadyExistsError(err):
+		h.logger.Warn(logMessage, "error", err)
+		h.renderUsers(c, http.StatusConflict, gin.H{"Error": "A user with this name already exists."})
+	case users.IsUserNotFoundError(err):
+		h.logger.Warn(logMessage, "error", err)
+		h.renderUsers(c, http.StatusNotFound, gin.H{"Error": "User not found."})
+	default:
+		h.logger.Error(logMessage, err)
+		h.renderUsers(c, http.StatusInternalServerError, gin.H{"Error": "An internal error occurred."})
+	}
+}
+
+func (h *UserHandler) renderUsers(c *gin.Context, status int, data gin.H) {
+	data["Title"] = "Users"
+	data["Roles"] = users.Roles
+	data["AdminUsername"] = users.AdminUsername
+
+	userList, err := h.userService.GetUsers()
+	if err != nil {
+		h.logger.Error("Failed to get users", err)
+		data["Error"] = "Failed to load users."
+		c.HTML(http.StatusInternalServerError, "users", data)
+		return
+	}
+
+	data["Users"] = userList
+	c.HTML(status, "users", data)
+}
+
+func (h *UserHandler) renderAccount(c *gin.Context, status int, data gin.H) {
+	data["Title"] = "Account"
+	data["CurrentUser"] = sessions.GetCurrentUser(c)
+	c.HTML(status, "account", data)
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+)
+
+// APITokenMiddleware authenticates requests of home automation systems by a static bearer token.
+// These requests carry no session cookie, so they are neither subject to CSRF checks nor tied to a dashboard user.
+type APITokenMiddleware struct {
+	logger logging.Logger
+	token  string
+}
+
+func NewAPITokenMiddleware(logger logging.Logger, token string) *APITokenMiddleware {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &APITokenMiddleware{
+		logger: logger,
+		token:  token,
+	}
+}
+
+// RequireToken rejects requests without an "Authorization: Bearer <token>" header carrying the configured token
+func (m *APITokenMiddleware) RequireToken(c *gin.Context) {
+	submitted, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || m.token == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(m.token)) != 1 {
+		m.logger.Warn("Rejected API request with missing or invalid token", "path", c.Request.URL.Path, "ip", c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Next()
+}
@@ -6,20 +6,25 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/yeti47/cryospy/server/core/ccc/logging"
 	"github.com/yeti47/cryospy/server/core/encryption"
+	"github.com/yeti47/cryospy/server/core/users"
 	"github.com/yeti47/cryospy/server/dashboard/sessions"
 )
 
 type AuthMiddleware struct {
-	logger          logging.Logger
-	mekService      encryption.MekService
-	mekStoreFactory sessions.MekStoreFactory
+	logger        logging.Logger
+	mekService    encryption.MekService
+	userService   users.UserService
+	sessionStore  sessions.SessionStore
+	sessionCookie *sessions.SessionCookie
 }
 
-func NewAuthMiddleware(logger logging.Logger, mekService encryption.MekService, mekStoreFactory sessions.MekStoreFactory) *AuthMiddleware {
+func NewAuthMiddleware(logger logging.Logger, mekService encryption.MekService, userService users.UserService, sessionStore sessions.SessionStore, sessionCookie *sessions.SessionCookie) *AuthMiddleware {
 	return &AuthMiddleware{
-		logger:          logger,
-		mekService:      mekService,
-		mekStoreFactory: mekStoreFactory,
+		logger:        logger,
+		mekService:    mekService,
+		userService:   userService,
+		sessionStore:  sessionStore,
+		sessionCookie: sessionCookie,
 	}
 }
 
@@ -38,24 +43,73 @@ func (m *AuthMiddleware) RequireAuth(c *gin.Context) {
 		return
 	}
 
-	// Check if the user is authenticated (MEK in session)
-	mekStore := m.mekStoreFactory(c)
-	sessionMek, err := mekStore.GetMek()
-	if err != nil || len(sessionMek) == 0 {
+	// Check if the user is authenticated (session with a MEK)
+	session := m.activeSession(c)
+	if session == nil || len(session.Mek) == 0 {
 		m.logger.Info("User not authenticated, redirecting to login.")
 		c.Redirect(http.StatusFound, "/auth/login")
 		c.Abort()
 		return
 	}
 
+	// The role is looked up on every request so that role changes and removed users take effect immediately
+	identity := session.Identity()
+	role := users.RoleAdmin
+	if !identity.IsBuiltInAdmin() {
+		user, err := m.userService.GetUser(identity.UserID)
+		if err != nil {
+			m.logger.Error("Failed to get user for auth check", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			m.logger.Info("User of session no longer exists, redirecting to login.", "userId", identity.UserID)
+			if err := m.sessionStore.Revoke(session.ID); err != nil {
+				m.logger.Error("Failed to revoke session of removed user", err)
+			}
+			c.Redirect(http.StatusFound, "/auth/login")
+			c.Abort()
+			return
+		}
+		identity.Username = user.Username
+		role = user.Role
+	}
+
+	sessions.SetCurrentUser(c, &sessions.CurrentUser{
+		Identity:  identity,
+		Role:      role,
+		SessionID: session.ID,
+	})
+
 	c.Next()
 }
 
+// RequireRole rejects requests of users whose role does not grant the required permissions.
+// It must be used after RequireAuth.
+func (m *AuthMiddleware) RequireRole(required users.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := sessions.GetCurrentUser(c)
+		if !user.Can(required) {
+			username := ""
+			if user != nil {
+				username = user.Username
+			}
+			m.logger.Warn("Access denied", "path", c.Request.URL.Path, "username", username, "requiredRole", required)
+			c.HTML(http.StatusForbidden, "error", gin.H{
+				"Title":   "Access Denied",
+				"Message": "Your account does not have permission to access this page.",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func (m *AuthMiddleware) RedirectIfAuth(c *gin.Context) {
-	// If a MEK is in the session, the user is authenticated. Redirect to dashboard.
-	mekStore := m.mekStoreFactory(c)
-	sessionMek, err := mekStore.GetMek()
-	if err == nil && len(sessionMek) > 0 {
+	// If the request has an active session, the user is authenticated. Redirect to dashboard.
+	if session := m.activeSession(c); session != nil && len(session.Mek) > 0 {
 		c.Redirect(http.StatusFound, "/")
 		c.Abort()
 		return
@@ -63,3 +117,18 @@ func (m *AuthMiddleware) RedirectIfAuth(c *gin.Context) {
 
 	c.Next()
 }
+
+// activeSession returns the session of the request, or nil if there is none
+func (m *AuthMiddleware) activeSession(c *gin.Context) *sessions.Session {
+	id, err := m.sessionCookie.GetSessionID(c)
+	if err != nil || id == "" {
+		return nil
+	}
+
+	session, err := m.sessionStore.Get(id)
+	if err != nil {
+		m.logger.Error("Failed to get session", err)
+		return nil
+	}
+	return session
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/yeti47/cryospy/server/core/ccc/logging"
+	"github.com/yeti47/cryospy/server/dashboard/sessions"
+)
+
+const (
+	// CSRFFormField is the name of the hidden form field that carries the CSRF token
+	CSRFFormField = "csrf_token"
+	// CSRFHeader is the request header that carries the CSRF token for script requests
+	CSRFHeader = "X-CSRF-Token"
+	// csrfTemplateKey is the template data key under which the CSRF token is available
+	csrfTemplateKey = "CSRFToken"
+)
+
+// CSRFMiddleware rejects state-changing requests that were not sent from a dashboard page
+type CSRFMiddleware struct {
+	logger        logging.Logger
+	sessionCookie *sessions.SessionCookie
+}
+
+func NewCSRFMiddleware(logger logging.Logger, sessionCookie *sessions.SessionCookie) *CSRFMiddleware {
+	if logger == nil {
+		logger = logging.NopLogger
+	}
+
+	return &CSRFMiddleware{
+		logger:        logger,
+		sessionCookie: sessionCookie,
+	}
+}
+
+// Protect issues a CSRF token for the cookie session and verifies it on every state-changing request.
+// Such requests must also come from the dashboard's own origin if the browser reports one.
+func (m *CSRFMiddleware) Protect(c *gin.Context) {
+	token, err := m.sessionCookie.EnsureCSRFToken(c)
+	if err != nil {
+		m.logger.Error("Failed to issue CSRF token", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// Templates rendered for this request pick up the token from the writer (see NewCSRFRenderer)
+	c.Writer = &csrfResponseWriter{ResponseWriter: c.Writer, token: token}
+
+	if isSafeMethod(c.Request.Method) {
+		c.Next()
+		return
+	}
+
+	if !isSameOrigin(c.Request) {
+		m.logger.Warn("Rejected cross-origin request", "path", c.Request.URL.Path, "origin", c.GetHeader("Origin"), "referer", c.GetHeader("Referer"), "ip", c.ClientIP())
+		m.reject(c)
+		return
+	}
+
+	submitted := c.GetHeader(CSRFHeader)
+	if submitted == "" {
+		submitted = c.PostForm(CSRFFormField)
+	}
+	if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+		m.logger.Warn("Rejected request with missing or invalid CSRF token", "path", c.Request.URL.Path, "ip", c.ClientIP())
+		m.reject(c)
+		return
+	}
+
+	c.Next()
+}
+
+func (m *CSRFMiddleware) reject(c *gin.Context) {
+	message := "The request could not be verified. This happens if the form was submitted from another site or your session changed in the meantime. Please reload the page and try again."
+
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": message})
+		return
+	}
+
+	c.HTML(http.StatusForbidden, "error", gin.H{
+		"Title":   "Request Rejected",
+		"Message": message,
+	})
+	c.Abort()
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isSameOrigin compares the Origin header, or the Referer header if there is no Origin, with the requested host.
+// Requests without either header are left to the token check, since some browsers and proxies strip them.
+func isSameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+		if source == "" {
+			return true
+		}
+	}
+
+	// Browsers send "null" for opaque origins, such as sandboxed frames
+	if source == "null" {
+		return false
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// csrfResponseWriter carries the CSRF token of the request to the template renderer
+type csrfResponseWriter struct {
+	gin.ResponseWriter
+	token string
+}
+
+func (w *csrfResponseWriter) CSRFToken() string {
+	return w.token
+}
+
+type csrfRenderer struct {
+	inner render.HTMLRender
+}
+
+// NewCSRFRenderer wraps an HTML renderer so that every template can reach the CSRF token of the
+// request as .CSRFToken, without each handler passing it along
+func NewCSRFRenderer(inner render.HTMLRender) render.HTMLRender {
+	return &csrfRenderer{inner: inner}
+}
+
+func (r *csrfRenderer) Instance(name string, data any) render.Render {
+	return &csrfRender{inner: r.inner, name: name, data: data}
+}
+
+type csrfRender struct {
+	inner render.HTMLRender
+	name  string
+	data  any
+}
+
+func (r *csrfRender) Render(w http.ResponseWriter) error {
+	token := ""
+	if tw, ok := w.(interface{ CSRFToken() string }); ok {
+		token = tw.CSRFToken()
+	}
+
+	data := r.data
+	switch values := data.(type) {
+	case gin.H:
+		values[csrfTemplateKey] = token
+	case map[string]any:
+		values[csrfTemplateKey] = token
+	case nil:
+		data = gin.H{csrfTemplateKey: token}
+	}
+
+	return r.inner.Instance(r.name, data).Render(w)
+}
+
+func (r *csrfRender) WriteContentType(w http.ResponseWriter) {
+	r.inner.Instance(r.name, r.data).WriteContentType(w)
+}
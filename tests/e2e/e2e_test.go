@@ -13,6 +13,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -216,7 +217,15 @@ func setupEnvironment(serverDataDir, clientConfigDir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create client repo: %w", err)
 	}
-	clientService := clients.NewClientService(logging.NopLogger, clientRepo, encryptor)
+	settingsVersionRepo, err := clients.NewSQLiteSettingsVersionRepository(db)
+	if err != nil {
+		return fmt.Errorf("failed to create settings version repo: %w", err)
+	}
+	clientGroupRepo, err := clients.NewSQLiteClientGroupRepository(db)
+	if err != nil {
+		return fmt.Errorf("failed to create client group repo: %w", err)
+	}
+	clientService := clients.NewClientService(logging.NopLogger, clientRepo, settingsVersionRepo, clientGroupRepo, encryptor)
 
 	mekStore := &staticMekStore{mek: decryptedMek}
 
@@ -243,7 +252,7 @@ func setupEnvironment(serverDataDir, clientConfigDir string) error {
 		CaptureFrameRate:      15.0,
 	}
 
-	clientEntity, secret, err := clientService.CreateClient(req, mekStore)
+	clientEntity, secret, err := clientService.CreateClient(req, mekStore, "e2e")
 	if err != nil {
 		return fmt.Errorf("failed to create client: %w", err)
 	}
@@ -332,11 +341,37 @@ func verifyClips(t *testing.T) error {
 	}
 
 	// 1. Login
+	// The login form carries a CSRF token that is bound to the session cookie
 	loginURL := "http://localhost:8080/auth/login"
+	resp, err := client.Get(loginURL)
+	if err != nil {
+		return fmt.Errorf("failed to load login page: %w", err)
+	}
+	loginPage, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read login page: %w", err)
+	}
+	match := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindSubmatch(loginPage)
+	if match == nil {
+		return fmt.Errorf("no CSRF token found on login page")
+	}
+
 	data := url.Values{}
 	data.Set("password", "test-password")
+	data.Set("csrf_token", string(match[1]))
+
+	req, err := http.NewRequest("POST", loginURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create login request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Add the cookie manually for the same reason as below
+	for _, cookie := range resp.Cookies() {
+		req.AddCookie(cookie)
+	}
 
-	resp, err := client.PostForm(loginURL, data)
+	resp, err = client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to login: %w", err)
 	}
@@ -358,7 +393,7 @@ func verifyClips(t *testing.T) error {
 
 	// 2. Get Clips
 	clipsURL := "http://localhost:8080/clips"
-	req, err := http.NewRequest("GET", clipsURL, nil)
+	req, err = http.NewRequest("GET", clipsURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}